@@ -0,0 +1,367 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui/html"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"k8s.io/klog/v2"
+)
+
+// addSubcommands adds the chat, run, serve, session, report, bootstrap,
+// config and auth subcommands.
+// The flag-toggled modes of the root command (--quiet, --mcp-server,
+// --ui-type=web, --list-sessions, --delete-session) are kept as aliases.
+func addSubcommands(rootCmd *cobra.Command, opt *Options) {
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "chat [query]",
+		Short: "Start an interactive chat, optionally with an initial query",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := *opt
+			o.Quiet = false
+			return RunRootCommand(cmd.Context(), o, args)
+		},
+	})
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "run [query]",
+		Short: "Run a single query non-interactively and exit",
+		Long:  "Run a single query non-interactively and exit. The query is read from the argument, from stdin, or from both (the argument is then a prefix of stdin).",
+		Example: `  kubectl-ai run "fetch logs for nginx app in hello namespace"
+  cat error.log | kubectl-ai run "explain the error"`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := *opt
+			o.Quiet = true
+			return RunRootCommand(cmd.Context(), o, args)
+		},
+	})
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve kubectl-ai to other clients",
+	}
+	serveCmd.AddCommand(&cobra.Command{
+		Use:   "mcp",
+		Short: "Run as an MCP server exposing the kubectl-ai tools (same as --mcp-server)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := *opt
+			o.MCPServer = true
+			return RunRootCommand(cmd.Context(), o, nil)
+		},
+	})
+	serveCmd.AddCommand(&cobra.Command{
+		Use:   "web [query]",
+		Short: "Serve the web UI on --ui-listen-address (same as --ui-type=web)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := *opt
+			o.UIType = ui.UITypeWeb
+			o.Quiet = false
+			return RunRootCommand(cmd.Context(), o, args)
+		},
+	})
+	rootCmd.AddCommand(serveCmd)
+
+	sessionCmd := &cobra.Command{
+		Use:   "session",
+		Short: "Manage saved sessions",
+	}
+	var listTags []string
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List saved sessions (same as --list-sessions)",
+		Example: "  kubectl-ai session list --tag incident-1234",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleListSessions(listTags)
+		},
+	}
+	listCmd.Flags().StringArrayVar(&listTags, "tag", nil, "only list the sessions with all the tags")
+	sessionCmd.AddCommand(listCmd)
+	sessionCmd.AddCommand(&cobra.Command{
+		Use:   "delete <session-id>",
+		Short: "Delete a saved session (same as --delete-session)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleDeleteSession(args[0])
+		},
+	})
+	sessionCmd.AddCommand(&cobra.Command{
+		Use:   "export <session-id>",
+		Short: "Print a saved session, with its metadata and messages, as JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleExportSession(cmd.OutOrStdout(), args[0])
+		},
+	})
+	pruneDryRun := false
+	pruneCmd := &cobra.Command{
+		Use:     "prune",
+		Short:   "Delete the saved sessions exceeding the retention settings, old working directories and oversized trace files",
+		Long:    "Delete the saved sessions exceeding --max-sessions, --max-session-age-days and --max-sessions-size-mb, except those in use, the temporary working directories of the processes that exited a day ago or more, and the trace files larger than --max-trace-size-mb. The same cleanup runs at startup.",
+		Example: "  kubectl-ai session prune --max-session-age-days=30 --dry-run",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handlePruneSessions(cmd.OutOrStdout(), opt, pruneDryRun)
+		},
+	}
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "only list what would be deleted")
+	sessionCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(sessionCmd)
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:     "report <session-id>",
+		Short:   "Print a standalone HTML incident report of a saved session",
+		Long:    "Print a standalone HTML incident report of a saved session, with its timeline, the commands run and their diffs, the final summary and the cost, e.g. to attach it to a postmortem.",
+		Example: "  kubectl-ai report 20250807-510872 > incident.html",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleReport(cmd.OutOrStdout(), args[0])
+		},
+	})
+
+	rootCmd.AddCommand(newBootstrapCommand(opt))
+
+	rootCmd.AddCommand(newConfigCommand(opt))
+
+	authCmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage the API keys of the LLM providers in the OS keychain",
+		Long:  "Manage the API keys of the LLM providers in the OS keychain (macOS Keychain, Windows Credential Manager or Secret Service), so that they don't have to be set in shell profiles. Keys in the keychain take precedence over environment variables.",
+	}
+	authCmd.AddCommand(&cobra.Command{
+		Use:     "login <provider>",
+		Short:   "Store the API key of a provider in the OS keychain",
+		Long:    "Store the API key of a provider in the OS keychain. The key is prompted for, or read from stdin when it is not a terminal.",
+		Example: "  kubectl-ai auth login gemini\n  echo $OPENAI_API_KEY | kubectl-ai auth login openai",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleAuthLogin(cmd.InOrStdin(), cmd.ErrOrStderr(), args[0])
+		},
+	})
+	authCmd.AddCommand(&cobra.Command{
+		Use:   "logout <provider>",
+		Short: "Delete the API key of a provider from the OS keychain",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return gollm.DeleteAPIKey(args[0])
+		},
+	})
+	authCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the providers and where their API keys are found",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleAuthList(cmd.OutOrStdout())
+		},
+	})
+	rootCmd.AddCommand(authCmd)
+}
+
+// handleAuthLogin stores the API key of a provider, prompting for it without
+// echo if in is a terminal.
+func handleAuthLogin(in io.Reader, out io.Writer, provider string) error {
+	var key string
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprintf(out, "API key for %s: ", provider)
+		b, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(out)
+		if err != nil {
+			return fmt.Errorf("failed to read API key: %w", err)
+		}
+		key = string(b)
+	} else {
+		b, err := io.ReadAll(in)
+		if err != nil {
+			return fmt.Errorf("failed to read API key: %w", err)
+		}
+		key = string(b)
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("empty API key")
+	}
+	if err := gollm.SetAPIKey(provider, key); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Stored the API key of %s in the keychain.\n", provider)
+	return nil
+}
+
+// handleAuthList prints where the API key of each provider is found.
+func handleAuthList(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tAPI KEY")
+	for _, status := range gollm.ListAPIKeys() {
+		source := "not set"
+		switch status.Source {
+		case gollm.APIKeySourceKeyring:
+			source = "keychain"
+		case gollm.APIKeySourceEnv:
+			source = "env " + status.EnvVar
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", status.Provider, source)
+	}
+	return tw.Flush()
+}
+
+// newBootstrapCommand returns the bootstrap command, which runs a session
+// with an ephemeral kubeconfig limited to the selected namespaces and verbs.
+func newBootstrapCommand(opt *Options) *cobra.Command {
+	access := &tools.ScopedAccess{}
+	keep := false
+	cmd := &cobra.Command{
+		Use:   "bootstrap [query]",
+		Short: "Run a session with a time-bound kubeconfig scoped to the selected namespaces and verbs",
+		Long: `Run a session with a time-bound kubeconfig scoped to the selected namespaces and verbs.
+
+Using the current (admin) kubeconfig, bootstrap creates a ServiceAccount and, in
+each selected namespace, a Role granting the selected verbs and a RoleBinding.
+The session then runs with an ephemeral kubeconfig authenticating with a token
+of the ServiceAccount that expires after --duration. The objects are deleted
+when the session ends, unless --keep is set.
+
+The admin kubeconfig stays readable by the user running kubectl-ai, so the
+session only runs kubectl commands, possibly piped to filters like grep or jq,
+and denies those selecting other credentials (--kubeconfig, --context, --user,
+--server, --token, --as, KUBECONFIG=, ...) or reading files outside of its
+working directory. The commands of the bash tool and of the custom tools are
+held to the same rules. This is a guard against the agent, not a sandbox: anything run
+outside of the session keeps the admin access.`,
+		Example: `  kubectl-ai bootstrap --namespaces=shop,payments
+  kubectl-ai bootstrap --namespaces=shop --verbs=get,list,watch,patch --resources=deployments.apps,pods --duration=30m`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBootstrap(cmd.Context(), *opt, access, keep, args)
+		},
+	}
+	cmd.Flags().StringSliceVar(&access.Namespaces, "namespaces", nil, "namespaces the agent can access, the service account is created in the first one")
+	cmd.Flags().StringSliceVar(&access.Verbs, "verbs", []string{"get", "list", "watch"}, "verbs the agent is allowed")
+	cmd.Flags().StringSliceVar(&access.Resources, "resources", nil, "resources the agent can access, as resource or resource.group (e.g. deployments.apps); all resources if empty")
+	cmd.Flags().DurationVar(&access.Duration, "duration", time.Hour, "validity of the token of the agent")
+	cmd.Flags().BoolVar(&keep, "keep", false, "keep the service account, roles and role bindings when the session ends")
+	cmd.MarkFlagRequired("namespaces")
+	return cmd
+}
+
+// runBootstrap creates the scoped access with the kubeconfig of opt, and runs
+// the session with the ephemeral kubeconfig.
+func runBootstrap(ctx context.Context, opt Options, access *tools.ScopedAccess, keep bool, args []string) error {
+	if err := resolveKubeConfigPath(&opt); err != nil {
+		return fmt.Errorf("failed to resolve kubeconfig path: %w", err)
+	}
+	// The access is created in the selected context, the session then uses the scoped kubeconfig as is.
+	cleanupKubeconfig, err := applyKubeconfigSelection(ctx, &opt)
+	if err != nil {
+		return err
+	}
+	defer cleanupKubeconfig()
+	access.Name = fmt.Sprintf("kubectl-ai-%s", time.Now().Format("20060102-150405"))
+
+	dir, err := os.MkdirTemp("", "kubectl-ai-bootstrap-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+
+	adminOpt := tools.InvokeToolOptions{Kubeconfig: opt.KubeConfigPath, WorkDir: dir}
+	if err := tools.CreateScopedKubeconfig(ctx, adminOpt, access, kubeconfig); err != nil {
+		// Delete what may have been created before the failure.
+		if err := tools.DeleteScopedAccess(context.Background(), adminOpt, access); err != nil {
+			klog.Warningf("failed to clean up service account %s: %v", access.Name, err)
+		}
+		return fmt.Errorf("failed to create scoped kubeconfig: %w", err)
+	}
+	if !keep {
+		defer func() {
+			// The session context may be cancelled by then.
+			if err := tools.DeleteScopedAccess(context.Background(), adminOpt, access); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to delete service account %s: %v\n", access.Name, err)
+			}
+		}()
+	}
+	klog.Infof("running with service account %s/%s, expiring in %s", access.Namespaces[0], access.Name, access.Duration)
+
+	// The selection flags name the contexts of the admin kubeconfig, not of the scoped one.
+	opt.KubeConfigPath, opt.KubeContext, opt.KubeCluster, opt.KubeUser = kubeconfig, "", "", ""
+	opt.confineKubeconfig = true
+	return RunRootCommand(ctx, opt, args)
+}
+
+// sessionExport is the JSON document printed by `session export`.
+type sessionExport struct {
+	ID       string             `json:"id"`
+	Metadata *sessions.Metadata `json:"metadata"`
+	Messages []*api.Message     `json:"messages"`
+}
+
+// handleReport writes the HTML report of a session to w.
+func handleReport(w io.Writer, sessionID string) error {
+	manager, err := sessions.NewSessionManager()
+	if err != nil {
+		return fmt.Errorf("failed to create session manager: %w", err)
+	}
+
+	session, metadata, err := manager.GetSessionInfo(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load session %s: %w", sessionID, err)
+	}
+
+	report := html.NewReport(session.ID, metadata, session.ChatMessages())
+	if err := report.Write(w); err != nil {
+		return fmt.Errorf("failed to render the report of session %s: %w", sessionID, err)
+	}
+	return nil
+}
+
+// handleExportSession writes a session as JSON to w.
+func handleExportSession(w io.Writer, sessionID string) error {
+	manager, err := sessions.NewSessionManager()
+	if err != nil {
+		return fmt.Errorf("failed to create session manager: %w", err)
+	}
+
+	session, metadata, err := manager.GetSessionInfo(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load session %s: %w", sessionID, err)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(sessionExport{ID: session.ID, Metadata: metadata, Messages: session.ChatMessages()}); err != nil {
+		return fmt.Errorf("failed to export session %s: %w", sessionID, err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// systemConfigPath is the config file shared by the users of a host, e.g.
+// installed by the administrators. User config files take precedence.
+const systemConfigPath = "/etc/kubectl-ai/config.yaml"
+
+// projectConfigName is the config file of a project, found in the current
+// directory or its parents, e.g. at the root of the repository of the
+// manifests of a team.
+const projectConfigName = ".kubectl-ai.yaml"
+
+// projectConfig is the subset of the options a project config can set. They
+// add to the system and user configs, rather than replace them, and can't
+// loosen them, e.g. skip the permissions: a project config comes with the
+// repository it is found in. The settings running commands (toolConfigPaths,
+// hooks and answerValidators) are ignored unless the directory of the project
+// is in the trustedProjects of the user config. Relative paths are relative
+// to the directory of the project config.
+type projectConfig struct {
+	// Namespace is the default namespace of the project, instead of the one of the kubeconfig context.
+	Namespace string `json:"namespace,omitempty"`
+	// ExtraPromptPaths are prompt fragments about the project, e.g. its conventions.
+	ExtraPromptPaths []string `json:"extraPromptPaths,omitempty"`
+	PromptPacks      []string `json:"promptPacks,omitempty"`
+	// ToolConfigPaths are the custom tools of the project, only loaded for trusted projects.
+	ToolConfigPaths []string `json:"toolConfigPaths,omitempty"`
+	// DisableTools are the built-in tools the project doesn't use.
+	DisableTools []string `json:"disableTools,omitempty"`
+	// The policies of the project. Hooks and AnswerValidators are only loaded for trusted projects.
+	Hooks               []agent.Hook                   `json:"hooks,omitempty"`
+	ContextEnvironments []agent.ContextEnvironment     `json:"contextEnvironments,omitempty"`
+	FreezeWindows       []agent.FreezeWindow           `json:"freezeWindows,omitempty"`
+	AnswerValidators    []agent.CommandValidatorConfig `json:"answerValidators,omitempty"`
+}
+
+// configFiles returns the config files in increasing order of precedence:
+// the system config, the user config and the project config, if they exist.
+func configFiles() ([]string, error) {
+	paths := []string{systemConfigPath}
+	for _, configPath := range defaultConfigPaths {
+		expandedPath, err := expandPathPlaceholders(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w (for config file path %q)", err, configPath)
+		}
+		// The user config directory is often ~/.config.
+		if !slices.Contains(paths, expandedPath) {
+			paths = append(paths, expandedPath)
+		}
+	}
+	if dir, err := os.Getwd(); err == nil {
+		if projectPath := findProjectConfig(dir); projectPath != "" {
+			paths = append(paths, projectPath)
+		}
+	}
+	return paths, nil
+}
+
+// findProjectConfig returns the project config of dir or of its closest
+// parent, or "" if there is none.
+func findProjectConfig(dir string) string {
+	for {
+		p := filepath.Join(dir, projectConfigName)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadProjectConfig merges a project config over the options.
+func (o *Options) loadProjectConfig(projectPath string, b []byte) error {
+	var project projectConfig
+	if err := yaml.UnmarshalStrict(b, &project); err != nil {
+		return fmt.Errorf("parsing project configuration (it can only set namespace, extraPromptPaths, promptPacks, toolConfigPaths, disableTools, hooks, contextEnvironments, freezeWindows and answerValidators): %w", err)
+	}
+	dir := filepath.Dir(projectPath)
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) || strings.HasPrefix(p, "{") {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	if project.Namespace != "" {
+		o.Namespace = project.Namespace
+	}
+	for _, p := range project.ExtraPromptPaths {
+		o.ExtraPromptPaths = append(o.ExtraPromptPaths, resolve(p))
+	}
+	o.PromptPacks = append(o.PromptPacks, project.PromptPacks...)
+	o.DisableTools = append(o.DisableTools, project.DisableTools...)
+	o.ContextEnvironments = append(o.ContextEnvironments, project.ContextEnvironments...)
+	o.FreezeWindows = append(o.FreezeWindows, project.FreezeWindows...)
+
+	if !o.trustsProject(dir) {
+		if len(project.ToolConfigPaths) > 0 || len(project.Hooks) > 0 || len(project.AnswerValidators) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: ignoring the toolConfigPaths, hooks and answerValidators of %q, add %q to trustedProjects in your config to load them\n", projectPath, dir)
+		}
+		return nil
+	}
+	for _, p := range project.ToolConfigPaths {
+		o.ToolConfigPaths = append(o.ToolConfigPaths, resolve(p))
+	}
+	o.Hooks = append(o.Hooks, project.Hooks...)
+	o.AnswerValidators = append(o.AnswerValidators, project.AnswerValidators...)
+	return nil
+}
+
+// trustsProject reports whether dir is one of the trusted projects.
+func (o *Options) trustsProject(dir string) bool {
+	for _, trusted := range o.TrustedProjects {
+		p, err := expandPathPlaceholders(trusted)
+		if err != nil {
+			continue
+		}
+		if p, err = filepath.Abs(p); err == nil && p == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// newConfigCommand returns the config command, which inspects the layered
+// configuration.
+func newConfigCommand(opt *Options) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "effective",
+		Short: "Print the configuration merged from the config files and the flags",
+		Long: fmt.Sprintf("Print the configuration merged from, in increasing order of precedence: the system config (%s), the user config (%s), "+
+			"the project config (%s, in the current directory or its closest parent) and the flags.", systemConfigPath, defaultConfigPaths[0], projectConfigName),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printEffectiveConfig(cmd.OutOrStdout(), *opt)
+		},
+	})
+	return configCmd
+}
+
+func printEffectiveConfig(w io.Writer, opt Options) error {
+	if opt.UIOIDCClientSecret != "" {
+		opt.UIOIDCClientSecret = "<redacted>"
+	}
+	b, err := yaml.Marshal(opt)
+	if err != nil {
+		return err
+	}
+	if len(opt.configFiles) == 0 {
+		fmt.Fprintln(w, "# No config files, defaults and flags only.")
+	} else {
+		fmt.Fprintln(w, "# Merged from, in increasing order of precedence:")
+		for _, f := range opt.configFiles {
+			fmt.Fprintf(w, "#   %s\n", f)
+		}
+		fmt.Fprintln(w, "#   flags")
+	}
+	_, err = w.Write(b)
+	return err
+}
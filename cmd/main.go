@@ -18,17 +18,23 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
@@ -40,6 +46,7 @@ import (
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui/html"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/yaml"
@@ -72,7 +79,10 @@ func BuildRootCommand(opt *Options) (*cobra.Command, error) {
 		},
 	})
 
-	if err := opt.bindCLIFlags(rootCmd.Flags()); err != nil {
+	addSubcommands(rootCmd, opt)
+
+	// Flags are shared by the subcommands.
+	if err := opt.bindCLIFlags(rootCmd.PersistentFlags()); err != nil {
 		return nil, err
 	}
 	return rootCmd, nil
@@ -81,51 +91,268 @@ func BuildRootCommand(opt *Options) (*cobra.Command, error) {
 type Options struct {
 	ProviderID string `json:"llmProvider,omitempty"`
 	ModelID    string `json:"model,omitempty"`
+	// FastModel answers simple queries, e.g. listing resources, when set. Other queries use ModelID.
+	FastModel string `json:"fastModel,omitempty"`
+	// RouterModel classifies the queries the routing heuristic is unsure about.
+	RouterModel string `json:"routerModel,omitempty"`
 	// SkipPermissions is a flag to skip asking for confirmation before executing kubectl commands
 	// that modifies resources in the cluster.
 	SkipPermissions bool `json:"skipPermissions,omitempty"`
+	// ReadOnly refuses the tool calls that modify, or may modify, resources instead of asking for approval.
+	ReadOnly bool `json:"readOnly,omitempty"`
 	// EnableToolUseShim is a flag to enable tool use shim.
 	// TODO(droot): figure out a better way to discover if the model supports tool use
 	// and set this automatically.
 	EnableToolUseShim bool `json:"enableToolUseShim,omitempty"`
+	// ToolUseShimFallback switches to the tool-use shim when the model turns
+	// out not to support tools.
+	ToolUseShimFallback bool `json:"toolUseShimFallback,omitempty"`
 	// Quiet flag indicates if the agent should run in non-interactive mode.
 	// It requires a query to be provided as a positional argument.
 	Quiet     bool `json:"quiet,omitempty"`
 	MCPServer bool `json:"mcpServer,omitempty"`
 	MCPClient bool `json:"mcpClient,omitempty"`
+	// StreamOutput prints the text of the model as it is generated in quiet mode,
+	// instead of rendering the complete answer at the end.
+	StreamOutput bool `json:"streamOutput,omitempty"`
+	// StdinAs is what piped input is: "query" appends it to the query, "context"
+	// attaches it to the query as reference material, labelled StdinLabel.
+	StdinAs    string `json:"stdinAs,omitempty"`
+	StdinLabel string `json:"stdinLabel,omitempty"`
 	// ExternalTools enables discovery and exposure of external MCP tools (only works with --mcp-server)
 	ExternalTools bool `json:"externalTools,omitempty"`
 	MaxIterations int  `json:"maxIterations,omitempty"`
+	// MaxContinuations is the number of times a response cut off by the output token limit is continued.
+	MaxContinuations int `json:"maxContinuations,omitempty"`
+	// KubectlOutputBudget is the number of tokens above which kubectl listings are run again
+	// in a more compact format, e.g. -o name. Zero disables it.
+	KubectlOutputBudget int `json:"kubectlOutputBudget,omitempty"`
+	// MaxDuration bounds the wall-clock time of each query, e.g. "10m". Empty means no limit.
+	MaxDuration string `json:"maxDuration,omitempty"`
+	// MaxCost is the budget in USD of the session, after which the queries are stopped. 0 means no limit.
+	MaxCost float64 `json:"maxCost,omitempty"`
+	// MaxOutputTokens caps the number of tokens of each response of the model. Zero uses the default of the provider.
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+	// PromptLog is what is logged of the queries, and of the prompts and
+	// responses of the model, in the logs and the trace: off, metadata,
+	// sampled or full. PromptLogSamplePercent is the percentage of the
+	// queries whose content is logged in the sampled mode.
+	PromptLog              string  `json:"promptLog,omitempty"`
+	PromptLogSamplePercent float64 `json:"promptLogSamplePercent,omitempty"`
 	// MCPServerMode is the mode of the MCP server. only works with --mcp-server.
 	MCPServerMode string `json:"mcpServerMode,omitempty"`
 	// Set the SSEndpoint port for the MCP server. only works with --mcp-server and --mcp-server-mode=sse.
 	SSEndpointPort int `json:"sseEndpointPort,omitempty"`
+	// MCPTenantsConfig is the path to a file mapping bearer tokens to per-tenant kubeconfig and policy.
+	// only works with --mcp-server and --mcp-server-mode=sse.
+	MCPTenantsConfig string `json:"mcpTenantsConfig,omitempty"`
+	// MCPCompositeTools exposes the composite tools (diagnose_pod, summarize_namespace and check_rollout)
+	// in MCP server mode, answered by bounded read-only agent loops with the LLM provider.
+	MCPCompositeTools bool `json:"mcpCompositeTools,omitempty"`
+	// MCPCompositeMaxIterations bounds the model turns of each composite tool call.
+	MCPCompositeMaxIterations int `json:"mcpCompositeMaxIterations,omitempty"`
 	// KubeConfigPath is the path to the kubeconfig file.
 	// If not provided, the default kubeconfig path will be used.
 	KubeConfigPath string `json:"kubeConfigPath,omitempty"`
+	// KubeContext, KubeCluster and KubeUser select the context of the kubeconfig
+	// and override its cluster and user, like the flags of kubectl.
+	KubeContext string `json:"context,omitempty"`
+	KubeCluster string `json:"cluster,omitempty"`
+	KubeUser    string `json:"user,omitempty"`
+	// Namespace overrides the namespace of the context, e.g. the namespace of
+	// a project set in its .kubectl-ai.yaml.
+	Namespace string `json:"namespace,omitempty"`
 
 	PromptTemplateFilePath string   `json:"promptTemplateFilePath,omitempty"`
 	ExtraPromptPaths       []string `json:"extraPromptPaths,omitempty"`
 	TracePath              string   `json:"tracePath,omitempty"`
 	RemoveWorkDir          bool     `json:"removeWorkDir,omitempty"`
 	ToolConfigPaths        []string `json:"toolConfigPaths,omitempty"`
+	// PromptPacks are the names of prompt packs shipped with kubectl-ai, e.g. gke, added to the extra prompts.
+	PromptPacks []string `json:"promptPacks,omitempty"`
+	// DisableTools lists the built-in tools removed from the tools of the agent and of the MCP server, e.g. bash.
+	DisableTools []string `json:"disableTools,omitempty"`
+	// KubectlPlugins lists the kubectl plugins on PATH (e.g. neat, tree) exposed as tools.
+	KubectlPlugins []string `json:"kubectlPlugins,omitempty"`
+	// PprofAddr is the address to serve the runtime profiles on, disabled if empty.
+	PprofAddr string `json:"pprofAddr,omitempty"`
+
+	// WorkDir is a persistent working directory for tools, used instead of a temporary directory.
+	WorkDir string `json:"workDir,omitempty"`
+	// Env holds environment variables set for every tool invocation, e.g. AWS_PROFILE.
+	Env map[string]string `json:"env,omitempty"`
+	// Hooks are commands run on agent events (pre-tool-exec, post-tool-exec, on-session-end),
+	// receiving the event as JSON on stdin. Only configurable in the config file.
+	Hooks []agent.Hook `json:"hooks,omitempty"`
+	// TrustedProjects are the directories whose project config (.kubectl-ai.yaml) can add hooks,
+	// custom tools and answer validators, which run commands. Only configurable in the user or system config file.
+	TrustedProjects []string `json:"trustedProjects,omitempty"`
+	// ContextEnvironments classify the kubeconfig contexts by name pattern, e.g. *prod* as production.
+	// Sessions on a production context must be confirmed at startup. Only configurable in the config file.
+	ContextEnvironments []agent.ContextEnvironment `json:"contextEnvironments,omitempty"`
+	// Mode is the operating mode, e.g. auditor, adapting the tools, the approvals and the prompt to the
+	// role of the user. If empty, the mode of the context is selected with ContextModes.
+	Mode string `json:"mode,omitempty"`
+	// ContextModes select the operating mode by kubeconfig context name pattern, e.g. auditor on *prod*.
+	// Only configurable in the config file.
+	ContextModes []agent.ContextMode `json:"contextModes,omitempty"`
+	// FreezeWindows are the change freezes, e.g. Fri 18:00 to Mon 08:00 in the production namespaces,
+	// during which changes can only be approved with a reason. Only configurable in the config file.
+	FreezeWindows []agent.FreezeWindow `json:"freezeWindows,omitempty"`
+	// Policies give a verdict on the tool calls matching CEL expressions: allow, deny or ask.
+	// The first matching rule wins. Only configurable in the config file.
+	Policies []tools.PolicyRule `json:"policies,omitempty"`
+	// Aliases are queries run by typing their name, e.g. restarts for "list the pods restarting in all namespaces".
+	// Only configurable in the config file.
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// ConfirmContext confirms in advance that the session runs on the named production context.
+	ConfirmContext string `json:"confirmContext,omitempty"`
+	// ValidateAnswers enables the built-in checks of final answers, shown as warnings.
+	ValidateAnswers bool `json:"validateAnswers,omitempty"`
+	// InjectNotes gives the notes pinned to the session to the model with every query.
+	InjectNotes bool `json:"injectNotes,omitempty"`
+	// Language is the language of the answers: auto for the language of each query, off, or a language name.
+	Language string `json:"language,omitempty"`
+	// WatchDesktopNotifications shows a desktop notification when a watch registered with watch_until ends.
+	WatchDesktopNotifications bool `json:"watchDesktopNotifications,omitempty"`
+	// WatchWebhook receives the events of the watches registered with watch_until as JSON.
+	WatchWebhook string `json:"watchWebhook,omitempty"`
+	// VerifyRemediation re-runs the checks that observed a symptom after a fix, and reports whether it is gone.
+	VerifyRemediation bool `json:"verifyRemediation,omitempty"`
+	// RecordChanges records the changes made by the agent on the objects they modify: none, event or annotation.
+	RecordChanges string `json:"recordChanges,omitempty"`
+	// RBACPreflight checks the permissions of the commands requiring approval, and lets the model re-plan those not allowed.
+	RBACPreflight bool `json:"rbacPreflight,omitempty"`
+	// CheckVersionSkew warns at startup if kubectl is outside of the supported version skew of the cluster.
+	CheckVersionSkew bool `json:"checkVersionSkew,omitempty"`
+	// AnswerValidators are commands checking final answers, e.g. for organization rules,
+	// receiving the answer as JSON on stdin. Only configurable in the config file.
+	AnswerValidators []agent.CommandValidatorConfig `json:"answerValidators,omitempty"`
+
+	// JobImage is the kubectl-ai image of the remediation jobs created with "job run".
+	JobImage string `json:"jobImage,omitempty"`
+	// JobNamespace is the namespace of the remediation jobs, the current namespace if empty.
+	JobNamespace string `json:"jobNamespace,omitempty"`
+	// JobServiceAccount is the service account the remediation jobs run as.
+	JobServiceAccount string `json:"jobServiceAccount,omitempty"`
+	// JobSecret is a secret holding the LLM provider credentials of the remediation jobs.
+	JobSecret string `json:"jobSecret,omitempty"`
+
+	// NoCache disables the caching of the answers of read-only queries.
+	NoCache bool `json:"noCache,omitempty"`
+	// CacheTTLSeconds is the time the answers of read-only queries are reused for.
+	CacheTTLSeconds int `json:"cacheTTLSeconds,omitempty"`
+	// NoPromptCache disables the caching of the system prompt and of the tool definitions across invocations.
+	NoPromptCache bool `json:"noPromptCache,omitempty"`
+
+	// FanOutConcurrency is the number of investigations of the "fanout" command run at the same time.
+	FanOutConcurrency int `json:"fanOutConcurrency,omitempty"`
+	// FanOutMaxIterations is the number of model turns of each investigation of the "fanout" command.
+	FanOutMaxIterations int `json:"fanOutMaxIterations,omitempty"`
+
+	// GitOpsRepository is the HTTPS URL of the Git repository of the manifests of the cluster. Changes of resources
+	// managed by Argo CD or Flux are then made in pull requests to it, instead of in the cluster.
+	GitOpsRepository string `json:"gitopsRepository,omitempty"`
+	// GitOpsBaseBranch is the branch the pull requests are opened against.
+	GitOpsBaseBranch string `json:"gitopsBaseBranch,omitempty"`
+	// GitOpsPath is the directory of the manifests, or of the kustomize overlay, of the cluster in the repository.
+	GitOpsPath string `json:"gitopsPath,omitempty"`
+	// GitOpsProvider is github or gitlab, guessed from the repository if empty.
+	GitOpsProvider string `json:"gitopsProvider,omitempty"`
+	// GitOpsAPIURL is the API URL of self-hosted GitHub or GitLab instances.
+	GitOpsAPIURL string `json:"gitopsAPIURL,omitempty"`
 
 	// UIType is the type of user interface to use.
 	UIType ui.Type `json:"uiType,omitempty"`
+	// Notify alerts the user of the TUI when an approval is required while the
+	// terminal is not focused: with the bell, and a terminal and desktop notification.
+	Notify bool `json:"notify,omitempty"`
 	// UIListenAddress is the address to listen for the web UI.
 	UIListenAddress string `json:"uiListenAddress,omitempty"`
+	// UIOIDCIssuer is the OpenID Connect identity provider the users of the web UI
+	// log in with. The web UI requires no login if empty.
+	UIOIDCIssuer       string `json:"uiOIDCIssuer,omitempty"`
+	UIOIDCClientID     string `json:"uiOIDCClientID,omitempty"`
+	UIOIDCClientSecret string `json:"uiOIDCClientSecret,omitempty"`
+	// UIOIDCRedirectURL is the callback URL registered with the identity provider,
+	// derived from the requests if empty.
+	UIOIDCRedirectURL string `json:"uiOIDCRedirectURL,omitempty"`
+	// StreamFlushIntervalMS is the minimum time between partial text updates sent to the UI
+	// while the model is streaming. -1 uses the default of the UI, 0 disables partial updates.
+	StreamFlushIntervalMS int `json:"streamFlushIntervalMs,omitempty"`
+	// StreamFlushBytes sends a partial text update as soon as this much text is buffered.
+	// -1 uses the default of the UI.
+	StreamFlushBytes int `json:"streamFlushBytes,omitempty"`
+	// InputTokenPrice and OutputTokenPrice are the prices in USD of one million tokens,
+	// used to estimate the cost of the LLM calls. 0 uses the list prices of the model, if known.
+	InputTokenPrice  float64 `json:"inputTokenPrice,omitempty"`
+	OutputTokenPrice float64 `json:"outputTokenPrice,omitempty"`
+	// Appearance holds the theme and the accessibility options of the UIs, e.g. the
+	// screen-reader mode of the terminal. Only configurable in the config file.
+	Appearance ui.Appearance `json:"appearance,omitempty"`
 
 	// SkipVerifySSL is a flag to skip verifying the SSL certificate of the LLM provider.
 	SkipVerifySSL bool `json:"skipVerifySSL,omitempty"`
+	// LLMCABundle is a PEM file of certificate authorities trusted on top of
+	// those of the system, for the LLM provider, the webhooks and the MCP
+	// servers, e.g. the CA of a TLS-intercepting proxy.
+	LLMCABundle string `json:"llmCABundle,omitempty"`
+	// Offline disables the LLM provider and the other network calls, e.g. for
+	// air-gapped hosts. Only the features that don't need the model are available.
+	Offline bool `json:"offline,omitempty"`
+	// WebSearch lets the model search the web with the tool built into the provider,
+	// e.g. to look up CVEs or release notes. Only supported by gemini, vertexai and openai.
+	WebSearch bool `json:"webSearch,omitempty"`
+
+	// Gemini specific generation options, only used with the gemini and vertexai providers.
+	// GeminiThinkingBudget caps the thinking tokens of models that support thinking.
+	// Unset leaves the budget to the model, -1 enables dynamic thinking and 0 disables thinking.
+	GeminiThinkingBudget *int `json:"geminiThinkingBudget,omitempty"`
+	// GeminiSafetySettings maps harm categories to block thresholds, e.g. DANGEROUS_CONTENT: BLOCK_ONLY_HIGH.
+	GeminiSafetySettings map[string]string `json:"geminiSafetySettings,omitempty"`
+	// GeminiCandidateCount is the number of response candidates to generate.
+	// Only the first candidate is used, the others are billed all the same.
+	GeminiCandidateCount int `json:"geminiCandidateCount,omitempty"`
+
+	// Vertex AI specific options, only used with the vertexai provider.
+	// They take precedence over the environment and gcloud defaults.
+	VertexProject  string `json:"vertexProject,omitempty"`
+	VertexLocation string `json:"vertexLocation,omitempty"`
+	// VertexImpersonateServiceAccount is the email of a service account to impersonate.
+	VertexImpersonateServiceAccount string `json:"vertexImpersonateServiceAccount,omitempty"`
+	// VertexImpersonateDelegates is the delegation chain used to impersonate the service account.
+	VertexImpersonateDelegates []string `json:"vertexImpersonateDelegates,omitempty"`
 
 	// Session management options
 	ResumeSession string `json:"resumeSession,omitempty"`
 	NewSession    bool   `json:"newSession,omitempty"`
 	ListSessions  bool   `json:"listSessions,omitempty"`
 	DeleteSession string `json:"deleteSession,omitempty"`
+	// Retention of the saved sessions, applied at startup and by "session
+	// prune", zero values not limiting them.
+	MaxSessions       int `json:"maxSessions,omitempty"`
+	MaxSessionAgeDays int `json:"maxSessionAgeDays,omitempty"`
+	MaxSessionsSizeMB int `json:"maxSessionsSizeMB,omitempty"`
+	// MaxTraceSizeMB is the size above which the trace files are deleted.
+	MaxTraceSizeMB int `json:"maxTraceSizeMB,omitempty"`
+	// ForceTakeover resumes a session even if it is in use by another kubectl-ai process.
+	ForceTakeover bool `json:"forceTakeover,omitempty"`
+	// HistoryFidelity is how the saved messages of a resumed session are given back
+	// to the model: "full", or "digest" to elide the output of old tool calls.
+	HistoryFidelity string `json:"historyFidelity,omitempty"`
+	// Tags are added to the session, or filter the sessions listed with ListSessions.
+	Tags []string `json:"tags,omitempty"`
 
 	// ShowToolOutput is a flag to disable truncation of tool output in the terminal UI.
 	ShowToolOutput bool `json:"showToolOutput,omitempty"`
+	// RecordCast is the path of an asciicast file recording the terminal UI session, for replay with asciinema.
+	RecordCast string `json:"recordCast,omitempty"`
+
+	// configFiles are the config files loaded, in increasing order of precedence.
+	configFiles []string
+	// confineKubeconfig denies the tool calls that could use other credentials
+	// than the kubeconfig of the session, set by bootstrap.
+	confineKubeconfig bool
 }
 
 var defaultToolConfigPaths = []string{
@@ -143,38 +370,77 @@ func (o *Options) InitDefaults() {
 	o.ModelID = "gemini-2.5-pro"
 	// by default, confirm before executing kubectl commands that modify resources in the cluster.
 	o.SkipPermissions = false
+	o.ReadOnly = false
 	o.MCPServer = false
 	o.MCPClient = false
 	// by default, external tools are disabled (only works with --mcp-server)
 	o.ExternalTools = false
+	o.MCPCompositeMaxIterations = agent.DefaultCompositeMaxIterations
 	// We now default to our strongest model (gemini-2.5-pro-exp-03-25) which supports tool use natively.
 	// so we don't need shim.
 	o.EnableToolUseShim = false
+	o.ToolUseShimFallback = false
+	o.ValidateAnswers = true
+	o.VerifyRemediation = true
+	o.RecordChanges = string(agent.ChangeRecordNone)
+	o.RBACPreflight = true
+	o.CheckVersionSkew = true
+	o.InjectNotes = true
+	o.Notify = true
+	o.Language = agent.LanguageAuto
 	o.Quiet = false
+	o.StdinAs = stdinAsQuery
+	o.StdinLabel = "stdin"
 	o.MCPServer = false
 	o.MaxIterations = 20
+	o.MaxContinuations = 3
+	o.KubectlOutputBudget = 4000
+	o.FanOutConcurrency = 4
+	o.CacheTTLSeconds = int(agent.DefaultAnswerCacheTTL.Seconds())
+	o.FanOutMaxIterations = 10
 	o.KubeConfigPath = ""
 	o.PromptTemplateFilePath = ""
 	o.ExtraPromptPaths = []string{}
 	o.TracePath = filepath.Join(os.TempDir(), "kubectl-ai-trace.txt")
+	// By default, the content of the prompts is logged
+	o.PromptLog = string(gollm.PromptLogFull)
+	o.PromptLogSamplePercent = 10
 	o.RemoveWorkDir = false
+	// By default, tools run in a temporary working directory with the environment of kubectl-ai
+	o.WorkDir = ""
+	o.Env = map[string]string{}
 	o.ToolConfigPaths = defaultToolConfigPaths
 	// Default to terminal UI
 	o.UIType = ui.UITypeTerminal
 	// Default UI listen address for HTML UI
 	o.UIListenAddress = "localhost:8888"
+	// By default, each UI decides how often to render streamed text
+	o.StreamFlushIntervalMS = -1
+	o.StreamFlushBytes = -1
 	// Default to not skipping SSL verification
 	o.SkipVerifySSL = false
+	o.LLMCABundle = os.Getenv("LLM_CA_BUNDLE")
+	// By default, let the model decide on thinking and safety settings
+	o.GeminiThinkingBudget = nil
+	o.GeminiSafetySettings = map[string]string{}
+	o.GeminiCandidateCount = 0
 	// Default MCP server mode is stdio
 	o.MCPServerMode = "stdio"
 	// Default port for SSE endpoint
 	o.SSEndpointPort = 9080
+	// By default, all MCP clients share the same kubeconfig
+	o.MCPTenantsConfig = ""
 
 	// Session management options
 	o.ResumeSession = ""
 	o.NewSession = false
 	o.ListSessions = false
 	o.DeleteSession = ""
+	o.MaxSessions = 0
+	o.MaxSessionAgeDays = 90
+	o.MaxSessionsSizeMB = 1024
+	o.MaxTraceSizeMB = 100
+	o.HistoryFidelity = string(agent.HistoryFidelityDigest)
 
 	// By default, hide tool outputs
 	o.ShowToolOutput = false
@@ -187,28 +453,14 @@ func (o *Options) LoadConfiguration(b []byte) error {
 	return nil
 }
 
+// LoadConfigurationFile loads the system, user and project config files, in
+// increasing order of precedence, see configFiles.
 func (o *Options) LoadConfigurationFile() error {
-	configPaths := defaultConfigPaths
-	for _, configPath := range configPaths {
-		pathWithPlaceholdersExpanded := configPath
-
-		if strings.Contains(pathWithPlaceholdersExpanded, "{CONFIG}") {
-			configDir, err := os.UserConfigDir()
-			if err != nil {
-				return fmt.Errorf("getting user config directory (for config file path %q): %w", configPath, err)
-			}
-			pathWithPlaceholdersExpanded = strings.ReplaceAll(pathWithPlaceholdersExpanded, "{CONFIG}", configDir)
-		}
-
-		if strings.Contains(pathWithPlaceholdersExpanded, "{HOME}") {
-			homeDir, err := os.UserHomeDir()
-			if err != nil {
-				return fmt.Errorf("getting user home directory (for config file path %q): %w", configPath, err)
-			}
-			pathWithPlaceholdersExpanded = strings.ReplaceAll(pathWithPlaceholdersExpanded, "{HOME}", homeDir)
-		}
-
-		configPath = filepath.Clean(pathWithPlaceholdersExpanded)
+	configPaths, err := configFiles()
+	if err != nil {
+		return err
+	}
+	for i, configPath := range configPaths {
 		configBytes, err := os.ReadFile(configPath)
 		if err != nil {
 			if os.IsNotExist(err) {
@@ -216,11 +468,22 @@ func (o *Options) LoadConfigurationFile() error {
 			} else {
 				fmt.Fprintf(os.Stderr, "warning: could not load defaults from %q: %v\n", configPath, err)
 			}
-		} else if len(configBytes) > 0 {
-			if err := o.LoadConfiguration(configBytes); err != nil {
-				fmt.Fprintf(os.Stderr, "warning: error loading configuration from %q: %v\n", configPath, err)
-			}
+			continue
+		}
+		if len(configBytes) == 0 {
+			continue
+		}
+		isProject := i == len(configPaths)-1 && filepath.Base(configPath) == projectConfigName
+		if isProject {
+			err = o.loadProjectConfig(configPath, configBytes)
+		} else {
+			err = o.LoadConfiguration(configBytes)
 		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: error loading configuration from %q: %v\n", configPath, err)
+			continue
+		}
+		o.configFiles = append(o.configFiles, configPath)
 	}
 	return nil
 }
@@ -239,15 +502,16 @@ func main() {
 	}()
 
 	if err := run(ctx); err != nil {
-		// Don't print error if it's a context cancellation
-		if !errors.Is(err, context.Canceled) {
+		// Don't print error if it's a context cancellation, or was already shown by the UI
+		var reported *reportedError
+		if !errors.Is(err, context.Canceled) && !errors.As(err, &reported) {
 			fmt.Fprintln(os.Stderr, err)
 		}
 		// Exit with non-zero status code on error, unless it's a graceful shutdown.
 		if errors.Is(err, context.Canceled) {
 			os.Exit(0)
 		}
-		os.Exit(1)
+		os.Exit(api.ErrorCategoryOf(err).ExitCode())
 	}
 }
 
@@ -294,37 +558,246 @@ func run(ctx context.Context) error {
 
 func (opt *Options) bindCLIFlags(f *pflag.FlagSet) error {
 	f.IntVar(&opt.MaxIterations, "max-iterations", opt.MaxIterations, "maximum number of iterations agent will try before giving up")
+	f.IntVar(&opt.MaxContinuations, "max-continuations", opt.MaxContinuations, "maximum number of times the model is asked to continue a response cut off by the output token limit (0 to disable)")
+	f.IntVar(&opt.KubectlOutputBudget, "kubectl-output-budget", opt.KubectlOutputBudget, "number of tokens above which the listings of the kubectl tool are run again in a more compact format, e.g. -o name (0 to disable)")
+	f.StringVar(&opt.MaxDuration, "max-duration", opt.MaxDuration, "maximum wall-clock time of each query, e.g. 10m, after which the agent stops and summarizes its progress (no limit if empty)")
+	f.Float64Var(&opt.MaxCost, "max-cost", opt.MaxCost, "budget in USD of the session: queries are stopped when the estimated cost of the LLM calls reaches it (no limit if 0)")
+	f.IntVar(&opt.MaxOutputTokens, "max-output-tokens", opt.MaxOutputTokens, "maximum number of tokens of each response of the model (0 uses the default of the provider)")
 	f.StringVar(&opt.KubeConfigPath, "kubeconfig", opt.KubeConfigPath, "path to kubeconfig file")
+	f.StringVar(&opt.KubeContext, "context", opt.KubeContext, "name of the kubeconfig context to use")
+	f.StringVar(&opt.KubeCluster, "cluster", opt.KubeCluster, "name of the kubeconfig cluster to use, instead of the cluster of the context")
+	f.StringVar(&opt.KubeUser, "user", opt.KubeUser, "name of the kubeconfig user to use, instead of the user of the context")
+	f.StringVarP(&opt.Namespace, "namespace", "n", opt.Namespace, "namespace to use, instead of the namespace of the context")
 	f.StringVar(&opt.PromptTemplateFilePath, "prompt-template-file-path", opt.PromptTemplateFilePath, "path to custom prompt template file")
 	f.StringArrayVar(&opt.ExtraPromptPaths, "extra-prompt-paths", opt.ExtraPromptPaths, "extra prompt template paths")
+	f.StringArrayVar(&opt.PromptPacks, "prompt-pack", opt.PromptPacks, fmt.Sprintf("name of a prompt pack shipped with kubectl-ai, added to the extra prompts, can be repeated (one of %s)", strings.Join(agent.PromptPacks(), ", ")))
 	f.StringVar(&opt.TracePath, "trace-path", opt.TracePath, "path to the trace file")
+	f.StringVar(&opt.PromptLog, "prompt-log", opt.PromptLog, "what is logged of the queries and of the prompts and responses of the model, in the logs and the trace: off, metadata (sizes, tokens and latency only), sampled (the content of --prompt-log-sample-percent of the queries) or full")
+	f.Float64Var(&opt.PromptLogSamplePercent, "prompt-log-sample-percent", opt.PromptLogSamplePercent, "percentage of the queries whose content is logged with --prompt-log=sampled")
+	f.StringVar(&opt.PprofAddr, "pprof-addr", opt.PprofAddr, "address to serve the runtime profiles on under /debug/pprof/, e.g. localhost:6060 (disabled if empty)")
 	f.BoolVar(&opt.RemoveWorkDir, "remove-workdir", opt.RemoveWorkDir, "remove the temporary working directory after execution")
+	f.StringVar(&opt.WorkDir, "workdir", opt.WorkDir, "persistent working directory for tools (a temporary directory is created if empty)")
+	f.StringToStringVar(&opt.Env, "env", opt.Env, "environment variables set for every tool invocation, as NAME=VALUE pairs, e.g. AWS_PROFILE=dev")
 
 	f.StringVar(&opt.ProviderID, "llm-provider", opt.ProviderID, "language model provider")
 	f.StringVar(&opt.ModelID, "model", opt.ModelID, "language model e.g. gemini-2.0-flash-thinking-exp-01-21, gemini-2.0-flash")
+	f.StringVar(&opt.FastModel, "fast-model", opt.FastModel, "fast and cheap model answering simple queries such as listing or describing resources, other queries use --model; routing is disabled if empty")
+	f.StringVar(&opt.RouterModel, "router-model", opt.RouterModel, "small model classifying the queries the routing heuristic is unsure about, which use --model if empty")
 	f.BoolVar(&opt.SkipPermissions, "skip-permissions", opt.SkipPermissions, "(dangerous) skip asking for confirmation before executing kubectl commands that modify resources")
+	f.BoolVar(&opt.ReadOnly, "read-only", opt.ReadOnly, "refuse the tool calls that modify, or may modify, resources instead of asking for approval, e.g. during the triage of incidents in production. The model is told why, to plan around them")
 	f.BoolVar(&opt.MCPServer, "mcp-server", opt.MCPServer, "run in MCP server mode")
 	f.BoolVar(&opt.ExternalTools, "external-tools", opt.ExternalTools, "in MCP server mode, discover and expose external MCP tools")
 	f.StringArrayVar(&opt.ToolConfigPaths, "custom-tools-config", opt.ToolConfigPaths, "path to custom tools config file or directory")
+	f.StringVar(&opt.Mode, "mode", opt.Mode, fmt.Sprintf("operating mode adapting the tools, the approvals and the prompt to your role, one of %s (the mode of the context in contextModes if empty)", strings.Join(agent.ModeNames(), ", ")))
+	f.StringSliceVar(&opt.DisableTools, "disable-tools", opt.DisableTools, "built-in tools the agent can't use, e.g. bash,node_debug")
+	f.StringSliceVar(&opt.KubectlPlugins, "kubectl-plugins", opt.KubectlPlugins, "kubectl plugins found on PATH to expose as tools, e.g. neat,tree")
 	f.BoolVar(&opt.MCPClient, "mcp-client", opt.MCPClient, "enable MCP client mode to connect to external MCP servers")
 	f.StringVar(&opt.MCPServerMode, "mcp-server-mode", opt.MCPServerMode, "mode of the MCP server. Supported values: stdio, sse")
 	f.IntVar(&opt.SSEndpointPort, "sse-endpoint-port", opt.SSEndpointPort, "port for the SSE endpoint in MCP server mode (only works with --mcp-server and --mcp-server-mode=sse)")
+	f.BoolVar(&opt.MCPCompositeTools, "mcp-composite-tools", opt.MCPCompositeTools, "in MCP server mode, expose the diagnose_pod, summarize_namespace and check_rollout tools, answered by read-only agent loops with the LLM provider")
+	f.IntVar(&opt.MCPCompositeMaxIterations, "mcp-composite-max-iterations", opt.MCPCompositeMaxIterations, "maximum number of model turns of each composite tool call (only works with --mcp-composite-tools)")
+	f.StringVar(&opt.MCPTenantsConfig, "mcp-tenants-config", opt.MCPTenantsConfig, "path to a file mapping bearer tokens to per-tenant kubeconfig and policy (only works with --mcp-server and --mcp-server-mode=sse)")
 	f.BoolVar(&opt.EnableToolUseShim, "enable-tool-use-shim", opt.EnableToolUseShim, "enable tool use shim")
+	f.BoolVar(&opt.ToolUseShimFallback, "tool-use-shim-fallback", opt.ToolUseShimFallback, "switch to the tool use shim for the session when the model rejects or ignores the tools")
+	f.StringVar(&opt.Language, "language", opt.Language, "language of the answers: auto to answer in the language of each query, off to let the model choose, or a language name, e.g. French. kubectl commands are never translated")
+	f.BoolVar(&opt.WatchDesktopNotifications, "watch-desktop-notifications", opt.WatchDesktopNotifications, "show a desktop notification when a watch registered with the watch_until tool ends (notify-send on Linux, osascript on macOS)")
+	f.StringVar(&opt.WatchWebhook, "watch-webhook", opt.WatchWebhook, "URL receiving the events of the watches registered with the watch_until tool as JSON POST requests")
+	f.BoolVar(&opt.InjectNotes, "inject-notes", opt.InjectNotes, "give the notes pinned to the session with the note command to the model with every query")
+	f.BoolVar(&opt.VerifyRemediation, "verify-remediation", opt.VerifyRemediation, "after changing resources, re-run the read-only commands that observed the symptom and report whether it is verified fixed or persists")
+	f.StringVar(&opt.RecordChanges, "record-changes", opt.RecordChanges, "record the changes made by kubectl-ai on the objects they modify, with the session and the approver, for the operators looking at them later. Supported values: none, event (a Kubernetes Event on the object), annotation (the kubectl-ai/session, kubectl-ai/approver and kubectl-ai/change annotations)")
+	f.BoolVar(&opt.RBACPreflight, "rbac-preflight", opt.RBACPreflight, "before asking for approval, check with kubectl auth can-i that the current identity can run the commands, and let the model re-plan those it cannot")
+	f.BoolVar(&opt.CheckVersionSkew, "check-version-skew", opt.CheckVersionSkew, "at startup, warn the user and the model if kubectl is more than one minor version older or newer than the cluster")
+	f.BoolVar(&opt.ValidateAnswers, "validate-answers", opt.ValidateAnswers, "check final answers for missing resources, unexecuted commands and contradictions with tool outputs, and show warnings")
+	f.StringVar(&opt.JobImage, "job-image", opt.JobImage, "kubectl-ai image used to run approved plans as Kubernetes Jobs with the \"job run\" command")
+	f.StringVar(&opt.JobNamespace, "job-namespace", opt.JobNamespace, "namespace of the remediation jobs (defaults to the current namespace)")
+	f.StringVar(&opt.JobServiceAccount, "job-service-account", opt.JobServiceAccount, "service account the remediation jobs run as")
+	f.StringVar(&opt.JobSecret, "job-secret", opt.JobSecret, "secret with the LLM provider credentials (e.g. GEMINI_API_KEY) set as environment variables of the remediation jobs")
+	f.BoolVar(&opt.NoCache, "no-cache", opt.NoCache, "always ask the model, instead of reusing the cached answer of the same read-only query whose resources didn't change")
+	f.IntVar(&opt.CacheTTLSeconds, "cache-ttl-seconds", opt.CacheTTLSeconds, "number of seconds the answers of read-only queries are cached for")
+	f.BoolVar(&opt.NoPromptCache, "no-prompt-cache", opt.NoPromptCache, "render the system prompt and build the tool definitions at startup, instead of reusing the ones cached for the same prompt template and tools")
+	f.IntVar(&opt.FanOutConcurrency, "fanout-concurrency", opt.FanOutConcurrency, "number of namespaces or clusters investigated at the same time by the \"fanout\" command")
+	f.IntVar(&opt.FanOutMaxIterations, "fanout-max-iterations", opt.FanOutMaxIterations, "maximum number of model turns of each investigation of the \"fanout\" command")
+	f.StringVar(&opt.GitOpsRepository, "gitops-repo", opt.GitOpsRepository, "HTTPS URL of the Git repository of the manifests: changes of resources managed by Argo CD or Flux are made in pull requests to it instead of in the cluster (the token is read from the KUBECTL_AI_GITOPS_TOKEN environment variable)")
+	f.StringVar(&opt.GitOpsBaseBranch, "gitops-base-branch", opt.GitOpsBaseBranch, "branch the GitOps pull requests are opened against (main if empty)")
+	f.StringVar(&opt.GitOpsPath, "gitops-path", opt.GitOpsPath, "directory of the manifests, or of the kustomize overlay, of the cluster in the GitOps repository (the whole repository if empty)")
+	f.StringVar(&opt.GitOpsProvider, "gitops-provider", opt.GitOpsProvider, "host of the GitOps repository, github or gitlab (guessed from the repository URL if empty)")
+	f.StringVar(&opt.GitOpsAPIURL, "gitops-api-url", opt.GitOpsAPIURL, "API URL of a self-hosted GitHub or GitLab instance hosting the GitOps repository")
 	f.BoolVar(&opt.Quiet, "quiet", opt.Quiet, "run in non-interactive mode, requires a query to be provided as a positional argument")
+	f.StringVar(&opt.StdinAs, "stdin-as", opt.StdinAs, "what piped input is: query to append it to the query, or context to attach it as reference material to the question given as argument, e.g. kubectl logs my-pod | kubectl-ai --stdin-as=context \"why does it crash?\"")
+	f.StringVar(&opt.StdinLabel, "stdin-label", opt.StdinLabel, "label of the input piped with --stdin-as=context, e.g. deployment.yaml")
+	f.BoolVar(&opt.StreamOutput, "stream-output", opt.StreamOutput, "in quiet mode, print the text of the model to stdout as it is generated, without rendering its markdown")
 
 	f.Var(&opt.UIType, "ui-type", "user interface type to use. Supported values: terminal, web, tui.")
+	f.BoolVar(&opt.Notify, "notify", opt.Notify, "in the TUI, ring the bell and show a notification when an approval is required while the terminal is not focused")
 	f.StringVar(&opt.UIListenAddress, "ui-listen-address", opt.UIListenAddress, "address to listen for the HTML UI.")
+	f.StringVar(&opt.UIOIDCIssuer, "ui-oidc-issuer", opt.UIOIDCIssuer, "URL of the OpenID Connect identity provider the users of the web UI log in with (no login if empty)")
+	f.StringVar(&opt.UIOIDCClientID, "ui-oidc-client-id", opt.UIOIDCClientID, "client ID of the web UI registered with the OpenID Connect identity provider")
+	f.StringVar(&opt.UIOIDCClientSecret, "ui-oidc-client-secret", opt.UIOIDCClientSecret, "client secret of the web UI registered with the OpenID Connect identity provider (defaults to the KUBECTL_AI_UI_OIDC_CLIENT_SECRET environment variable)")
+	f.StringVar(&opt.UIOIDCRedirectURL, "ui-oidc-redirect-url", opt.UIOIDCRedirectURL, "callback URL of the web UI registered with the OpenID Connect identity provider, ending with /auth/callback (derived from the requests if empty)")
+	f.IntVar(&opt.StreamFlushIntervalMS, "stream-flush-interval-ms", opt.StreamFlushIntervalMS, "minimum milliseconds between partial text updates sent to the UI while streaming (-1 uses the UI default, 0 disables partial updates)")
+	f.IntVar(&opt.StreamFlushBytes, "stream-flush-bytes", opt.StreamFlushBytes, "send a partial text update to the UI once this many bytes are buffered (-1 uses the UI default)")
+	f.Float64Var(&opt.InputTokenPrice, "input-token-price", opt.InputTokenPrice, "price in USD of one million input tokens, to estimate the cost of the LLM calls (0 uses the list prices of the model, if known)")
+	f.Float64Var(&opt.OutputTokenPrice, "output-token-price", opt.OutputTokenPrice, "price in USD of one million output tokens, to estimate the cost of the LLM calls (0 uses the list prices of the model, if known)")
 	f.BoolVar(&opt.SkipVerifySSL, "skip-verify-ssl", opt.SkipVerifySSL, "skip verifying the SSL certificate of the LLM provider")
+	f.StringVar(&opt.LLMCABundle, "llm-ca-bundle", opt.LLMCABundle, "PEM file of certificate authorities to trust for the LLM provider, webhooks and MCP servers, on top of those of the system (env LLM_CA_BUNDLE)")
+	f.BoolVar(&opt.Offline, "offline", opt.Offline, "make no calls to the LLM provider or other network services, and only provide the features that don't need the model (meta commands, snippets, cached answers); queries fail with an error")
+	f.BoolVar(&opt.WebSearch, "web-search", opt.WebSearch, "let the model search the web with the tool built into the provider (Google Search for gemini and vertexai, web search of the search models for openai), and cite its sources")
+	f.Var(optionalInt{&opt.GeminiThinkingBudget}, "gemini-thinking-budget", "maximum number of thinking tokens for gemini models that support thinking (-1 enables dynamic thinking, 0 disables thinking; left to the model if unset)")
+	f.StringToStringVar(&opt.GeminiSafetySettings, "gemini-safety-settings", opt.GeminiSafetySettings, "gemini safety settings as category=threshold pairs, e.g. DANGEROUS_CONTENT=BLOCK_ONLY_HIGH")
+	f.IntVar(&opt.GeminiCandidateCount, "gemini-candidate-count", opt.GeminiCandidateCount, "number of response candidates gemini models should generate (0 uses the model default); only the first one is used, but all are billed")
+	f.StringVar(&opt.VertexProject, "vertex-project", opt.VertexProject, "GCP project used by the vertexai provider (defaults to GOOGLE_CLOUD_PROJECT or the gcloud project)")
+	f.StringVar(&opt.VertexLocation, "vertex-location", opt.VertexLocation, "GCP location used by the vertexai provider (defaults to GOOGLE_CLOUD_LOCATION, GOOGLE_CLOUD_REGION or us-central1)")
+	f.StringVar(&opt.VertexImpersonateServiceAccount, "vertex-impersonate-service-account", opt.VertexImpersonateServiceAccount, "email of a service account to impersonate with the application default credentials when using the vertexai provider")
+	f.StringSliceVar(&opt.VertexImpersonateDelegates, "vertex-impersonate-delegates", opt.VertexImpersonateDelegates, "delegation chain of service accounts used to impersonate --vertex-impersonate-service-account")
 	f.BoolVar(&opt.ShowToolOutput, "show-tool-output", opt.ShowToolOutput, "show tool output in the terminal UI")
+	f.StringVar(&opt.ConfirmContext, "confirm-context", opt.ConfirmContext, "name of the kubeconfig context, confirming that the session runs on it when it is classified as production, e.g. in scripts")
+	f.StringVar(&opt.RecordCast, "record-cast", opt.RecordCast, "path of an asciicast file recording the terminal UI session, to replay it with asciinema play (no recording if empty)")
 
 	f.StringVar(&opt.ResumeSession, "resume-session", opt.ResumeSession, "ID of session to resume (use 'latest' for the most recent session)")
 	f.BoolVar(&opt.NewSession, "new-session", opt.NewSession, "create a new session")
 	f.BoolVar(&opt.ListSessions, "list-sessions", opt.ListSessions, "list all available sessions")
 	f.StringVar(&opt.DeleteSession, "delete-session", opt.DeleteSession, "delete a session by ID")
+	f.IntVar(&opt.MaxSessions, "max-sessions", opt.MaxSessions, "number of saved sessions kept, the least recently accessed are deleted at startup (0 for no limit)")
+	f.IntVar(&opt.MaxSessionAgeDays, "max-session-age-days", opt.MaxSessionAgeDays, "delete the saved sessions not accessed for this many days at startup (0 for no limit)")
+	f.IntVar(&opt.MaxSessionsSizeMB, "max-sessions-size-mb", opt.MaxSessionsSizeMB, "total size of the saved sessions in MiB, the least recently accessed are deleted at startup to fit (0 for no limit)")
+	f.IntVar(&opt.MaxTraceSizeMB, "max-trace-size-mb", opt.MaxTraceSizeMB, "delete the trace files larger than this many MiB at startup (0 for no limit)")
+	f.BoolVar(&opt.ForceTakeover, "force-takeover", opt.ForceTakeover, "resume the session even if it is in use by another process, which can no longer write to it")
+	f.StringArrayVar(&opt.Tags, "tag", opt.Tags, "tag the session, e.g. incident-1234, can be repeated; with --list-sessions, only list the sessions with all the tags")
+	f.StringVar(&opt.HistoryFidelity, "history-fidelity", opt.HistoryFidelity, "how the history of a resumed session is given to the model. Supported values: full, digest (the output of old tool calls is replaced by its first lines)")
+
+	return nil
+}
 
+// trustCABundle trusts the CA bundle for the connections using the default
+// HTTP transport, e.g. to the webhooks, the MCP servers and the APIs of the
+// http tools. The LLM providers trust it with gollm.WithCABundle.
+func trustCABundle(path string) error {
+	rootCAs, err := gollm.LoadCABundle(path)
+	if err != nil {
+		return err
+	}
+	transport := http.DefaultTransport.(*http.Transport)
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	tools.SetHTTPTransport(transport)
 	return nil
 }
 
+// optionalInt is an int flag that is unset by default.
+type optionalInt struct {
+	value **int
+}
+
+func (o optionalInt) Set(s string) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*o.value = &v
+	return nil
+}
+
+func (o optionalInt) String() string {
+	if o.value == nil || *o.value == nil {
+		return ""
+	}
+	return strconv.Itoa(**o.value)
+}
+
+func (o optionalInt) Type() string {
+	return "int"
+}
+
+// geminiOptions converts the gemini specific flags to gollm generation options.
+func (opt *Options) geminiOptions() gollm.GeminiOptions {
+	geminiOpts := gollm.GeminiOptions{
+		SafetySettings: opt.GeminiSafetySettings,
+		CandidateCount: int32(opt.GeminiCandidateCount),
+	}
+	if opt.GeminiThinkingBudget != nil {
+		budget := int32(*opt.GeminiThinkingBudget)
+		geminiOpts.ThinkingBudget = &budget
+	}
+	return geminiOpts
+}
+
+// promptLogOptions returns what is logged of the prompts.
+func (opt *Options) promptLogOptions() gollm.PromptLogOptions {
+	return gollm.PromptLogOptions{Mode: gollm.PromptLogMode(opt.PromptLog), SamplePercent: opt.PromptLogSamplePercent}
+}
+
+// llmClientOptions returns the options of the LLM client.
+func (opt *Options) llmClientOptions(promptLog gollm.PromptLogOptions) []gollm.Option {
+	var clientOpts []gollm.Option
+	if opt.SkipVerifySSL {
+		clientOpts = append(clientOpts, gollm.WithSkipVerifySSL())
+	}
+	if opt.LLMCABundle != "" {
+		clientOpts = append(clientOpts, gollm.WithCABundle(opt.LLMCABundle))
+	}
+	if opt.WebSearch {
+		clientOpts = append(clientOpts, gollm.WithWebSearch())
+	}
+	if opt.MaxOutputTokens > 0 {
+		clientOpts = append(clientOpts, gollm.WithMaxOutputTokens(opt.MaxOutputTokens))
+	}
+	clientOpts = append(clientOpts, gollm.WithPromptLog(promptLog))
+	clientOpts = append(clientOpts, gollm.WithGeminiOptions(opt.geminiOptions()))
+	clientOpts = append(clientOpts, gollm.WithVertexOptions(gollm.VertexOptions{
+		Project:                   opt.VertexProject,
+		Location:                  opt.VertexLocation,
+		ImpersonateServiceAccount: opt.VertexImpersonateServiceAccount,
+		ImpersonateDelegates:      opt.VertexImpersonateDelegates,
+	}))
+	return clientOpts
+}
+
+// streamOptions returns how streamed text is batched for the selected UI.
+// The terminal UI renders markdown once the response is complete, so it doesn't
+// get partial updates unless it streams the output; the web UI redraws the whole page on every
+// update, so it is batched more aggressively than the TUI.
+func (opt *Options) streamOptions() agent.StreamOptions {
+	var streamOpts agent.StreamOptions
+	switch opt.UIType {
+	case ui.UITypeWeb:
+		streamOpts = agent.StreamOptions{FlushInterval: 100 * time.Millisecond, FlushBytes: 4096}
+	case ui.UITypeTUI:
+		streamOpts = agent.StreamOptions{FlushInterval: 50 * time.Millisecond, FlushBytes: 1024}
+	case ui.UITypeTerminal:
+		if opt.StreamOutput {
+			streamOpts = agent.StreamOptions{FlushInterval: 100 * time.Millisecond, FlushBytes: 256}
+		}
+	}
+	if opt.StreamFlushIntervalMS >= 0 {
+		streamOpts.FlushInterval = time.Duration(opt.StreamFlushIntervalMS) * time.Millisecond
+	}
+	if opt.StreamFlushBytes >= 0 {
+		streamOpts.FlushBytes = opt.StreamFlushBytes
+	}
+	return streamOpts
+}
+
+// gitOpsOptions returns the configuration of the GitOps mode.
+func (opt *Options) gitOpsOptions() agent.GitOpsOptions {
+	return agent.GitOpsOptions{
+		Repository: opt.GitOpsRepository,
+		BaseBranch: opt.GitOpsBaseBranch,
+		Path:       opt.GitOpsPath,
+		Provider:   opt.GitOpsProvider,
+		APIURL:     opt.GitOpsAPIURL,
+		Token:      os.Getenv("KUBECTL_AI_GITOPS_TOKEN"),
+	}
+}
+
+// jobRunnerOptions returns the configuration of the remediation jobs.
+func (opt *Options) jobRunnerOptions() agent.JobRunnerOptions {
+	return agent.JobRunnerOptions{
+		Image:          opt.JobImage,
+		Namespace:      opt.JobNamespace,
+		ServiceAccount: opt.JobServiceAccount,
+		Secret:         opt.JobSecret,
+	}
+}
+
 func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	var err error // Declare err once for the whole function
 
@@ -332,12 +805,83 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	if opt.ExternalTools && !opt.MCPServer {
 		return fmt.Errorf("--external-tools can only be used with --mcp-server")
 	}
+	if opt.MCPCompositeTools && (!opt.MCPServer || opt.Offline) {
+		return fmt.Errorf("--mcp-composite-tools can only be used with --mcp-server, without --offline")
+	}
+	if opt.MCPTenantsConfig != "" && (!opt.MCPServer || opt.MCPServerMode != "sse") {
+		return fmt.Errorf("--mcp-tenants-config can only be used with --mcp-server and --mcp-server-mode=sse")
+	}
+	if opt.RecordCast != "" && (opt.MCPServer || opt.UIType != ui.UITypeTerminal) {
+		return fmt.Errorf("--record-cast can only be used with the terminal UI")
+	}
+	if opt.StreamOutput && (!opt.Quiet || opt.MCPServer || opt.UIType != ui.UITypeTerminal) {
+		return fmt.Errorf("--stream-output can only be used with --quiet and the terminal UI")
+	}
+	if opt.StdinAs != stdinAsQuery && opt.StdinAs != stdinAsContext {
+		return fmt.Errorf("invalid --stdin-as %q, expected %s or %s", opt.StdinAs, stdinAsQuery, stdinAsContext)
+	}
+	if opt.Offline && (opt.WebSearch || opt.MCPClient || opt.ExternalTools || opt.WatchWebhook != "") {
+		return fmt.Errorf("--offline cannot be used with --web-search, --mcp-client, --external-tools or --watch-webhook")
+	}
+	historyFidelity, err := agent.ParseHistoryFidelity(opt.HistoryFidelity)
+	if err != nil {
+		return fmt.Errorf("invalid --history-fidelity: %w", err)
+	}
+	recordChanges, err := agent.ParseChangeRecord(opt.RecordChanges)
+	if err != nil {
+		return fmt.Errorf("invalid --record-changes: %w", err)
+	}
+	if opt.GeminiThinkingBudget != nil && *opt.GeminiThinkingBudget < -1 {
+		return fmt.Errorf("invalid --gemini-thinking-budget %d: expected -1 for dynamic thinking, 0 to disable thinking, or a number of tokens", *opt.GeminiThinkingBudget)
+	}
+	promptLog := opt.promptLogOptions()
+	if err := promptLog.Validate(); err != nil {
+		return fmt.Errorf("invalid --prompt-log: %w", err)
+	}
+	for _, tag := range opt.Tags {
+		if err := agent.ValidateTag(tag); err != nil {
+			return fmt.Errorf("invalid --tag: %w", err)
+		}
+	}
+
+	if opt.LLMCABundle != "" {
+		if err := trustCABundle(opt.LLMCABundle); err != nil {
+			return fmt.Errorf("invalid --llm-ca-bundle: %w", err)
+		}
+	}
 
 	// resolve kubeconfig path with priority: flag/env > KUBECONFIG > default path
 	if err = resolveKubeConfigPath(&opt); err != nil {
 		return fmt.Errorf("failed to resolve kubeconfig path: %w", err)
 	}
 
+	mode, err := resolveMode(opt)
+	if err != nil {
+		return err
+	}
+	disableTools := opt.DisableTools
+	if mode != nil {
+		if mode.StrictApprovals && opt.SkipPermissions {
+			return fmt.Errorf("--skip-permissions cannot be used in the %s mode, which requires the approval of every change", mode.Name)
+		}
+		disableTools = append(slices.Clone(disableTools), mode.DisableTools...)
+	}
+	// Before the custom tools, which may replace them.
+	if err := tools.DisableTools(disableTools); err != nil {
+		return fmt.Errorf("invalid --disable-tools: %w", err)
+	}
+	// The tabs of the TUI select their contexts in the kubeconfig of the user.
+	kubeconfigPath := opt.KubeConfigPath
+	cleanupKubeconfig, err := applyKubeconfigSelection(ctx, &opt)
+	if err != nil {
+		return err
+	}
+	defer cleanupKubeconfig()
+
+	if opt.PprofAddr != "" {
+		startPprofServer(opt.PprofAddr)
+	}
+
 	if opt.MCPServer {
 		if err = startMCPServer(ctx, opt); err != nil {
 			return fmt.Errorf("failed to start MCP server: %w", err)
@@ -346,7 +890,7 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	}
 
 	if opt.ListSessions {
-		return handleListSessions()
+		return handleListSessions(opt.Tags)
 	}
 
 	if opt.DeleteSession != "" {
@@ -357,6 +901,118 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		return fmt.Errorf("failed to process custom tools: %w", err)
 	}
 
+	if len(opt.KubectlPlugins) > 0 {
+		if err := tools.RegisterKubectlPlugins(ctx, opt.KubectlPlugins); err != nil {
+			klog.Warningf("Failed to register kubectl plugins: %v", err)
+		}
+	}
+
+	for i := range opt.Hooks {
+		if err := opt.Hooks[i].Validate(); err != nil {
+			return fmt.Errorf("invalid hook configuration: %w", err)
+		}
+	}
+
+	for i := range opt.ContextEnvironments {
+		if err := opt.ContextEnvironments[i].Validate(); err != nil {
+			return fmt.Errorf("invalid context environment configuration: %w", err)
+		}
+	}
+
+	for i := range opt.FreezeWindows {
+		if err := opt.FreezeWindows[i].Validate(); err != nil {
+			return fmt.Errorf("invalid freeze window configuration: %w", err)
+		}
+	}
+
+	var policy *tools.Policy
+	if len(opt.Policies) > 0 {
+		policy, err = tools.NewPolicy(opt.Policies)
+		if err != nil {
+			return fmt.Errorf("invalid policies configuration: %w", err)
+		}
+	}
+
+	if err := agent.ValidateAliases(opt.Aliases); err != nil {
+		return fmt.Errorf("invalid aliases configuration: %w", err)
+	}
+
+	if opt.GitOpsRepository != "" {
+		gitOps := opt.gitOpsOptions()
+		if err := gitOps.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := opt.Appearance.Validate(); err != nil {
+		return fmt.Errorf("invalid appearance configuration: %w", err)
+	}
+	if opt.UIOIDCIssuer != "" {
+		if opt.UIType != ui.UITypeWeb {
+			return fmt.Errorf("--ui-oidc-issuer can only be used with the web UI")
+		}
+		if opt.UIOIDCClientID == "" {
+			return fmt.Errorf("--ui-oidc-client-id is required with --ui-oidc-issuer")
+		}
+		if opt.UIOIDCClientSecret == "" {
+			opt.UIOIDCClientSecret = os.Getenv("KUBECTL_AI_UI_OIDC_CLIENT_SECRET")
+		}
+	}
+	if opt.Appearance.ScreenReader && opt.UIType == ui.UITypeTUI {
+		return fmt.Errorf("the screen-reader mode is not supported by the TUI, which redraws the screen: use the terminal UI")
+	}
+	if err := confirmProductionContext(opt); err != nil {
+		return err
+	}
+
+	var answerValidators []agent.AnswerValidator
+	for _, config := range opt.AnswerValidators {
+		validator, err := agent.NewCommandValidator(config)
+		if err != nil {
+			return fmt.Errorf("invalid answer validator configuration: %w", err)
+		}
+		answerValidators = append(answerValidators, validator)
+	}
+
+	var maxDuration time.Duration
+	if opt.MaxDuration != "" {
+		maxDuration, err = time.ParseDuration(opt.MaxDuration)
+		if err != nil || maxDuration <= 0 {
+			return fmt.Errorf("invalid --max-duration %q, expected a positive duration like 10m", opt.MaxDuration)
+		}
+	}
+	if opt.MaxCost < 0 {
+		return fmt.Errorf("invalid --max-cost %v, expected a positive amount in USD", opt.MaxCost)
+	}
+
+	// The prompts of the mode and of the prompt packs come first, so that the extra prompts of the user can refine them.
+	var extraPromptPaths []string
+	if mode != nil {
+		extraPromptPaths = append(extraPromptPaths, mode.PromptPath())
+	}
+	for _, name := range opt.PromptPacks {
+		packPath, err := agent.PromptPackPath(name)
+		if err != nil {
+			return fmt.Errorf("invalid --prompt-pack: %w", err)
+		}
+		extraPromptPaths = append(extraPromptPaths, packPath)
+	}
+	opt.ExtraPromptPaths = append(extraPromptPaths, opt.ExtraPromptPaths...)
+
+	var answerCache *agent.AnswerCache
+	if !opt.NoCache && opt.CacheTTLSeconds > 0 {
+		answerCache, err = agent.NewAnswerCache(time.Duration(opt.CacheTTLSeconds) * time.Second)
+		if err != nil {
+			return fmt.Errorf("creating answer cache: %w", err)
+		}
+	}
+	var promptCache *agent.PromptCache
+	if !opt.NoPromptCache {
+		promptCache, err = agent.NewPromptCache()
+		if err != nil {
+			return fmt.Errorf("creating prompt cache: %w", err)
+		}
+	}
+
 	// After reading stdin, it is consumed
 	var hasInputData bool
 	hasInputData, err = hasStdInData()
@@ -366,23 +1022,42 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 
 	// Handles positional args or stdin
 	var queryFromCmd string
-	queryFromCmd, err = resolveQueryInput(hasInputData, args)
-	if err != nil {
-		return fmt.Errorf("failed to resolve query input %w", err)
+	var stdinAttachments []api.Attachment
+	if hasInputData && opt.StdinAs == stdinAsContext {
+		attachment, err := readStdinAttachment(opt.StdinLabel)
+		if err != nil {
+			return err
+		}
+		stdinAttachments = append(stdinAttachments, attachment)
+		queryFromCmd, err = resolveQueryInput(false, args)
+		if err != nil {
+			return fmt.Errorf("failed to resolve query input %w", err)
+		}
+	} else {
+		queryFromCmd, err = resolveQueryInput(hasInputData, args)
+		if err != nil {
+			return fmt.Errorf("failed to resolve query input %w", err)
+		}
 	}
 
 	klog.Info("Application started", "pid", os.Getpid())
 
+	clientOpts := opt.llmClientOptions(promptLog)
+
 	var llmClient gollm.Client
-	if opt.SkipVerifySSL {
-		llmClient, err = gollm.NewClient(ctx, opt.ProviderID, gollm.WithSkipVerifySSL())
+	if opt.Offline {
+		klog.Info("Offline mode, the LLM provider is disabled")
+		llmClient = agent.NewOfflineClient()
 	} else {
-		llmClient, err = gollm.NewClient(ctx, opt.ProviderID)
-	}
-	if err != nil {
-		return fmt.Errorf("creating llm client: %w", err)
+		llmClient, err = gollm.NewClient(ctx, opt.ProviderID, clientOpts...)
+		if err != nil {
+			return fmt.Errorf("creating llm client: %w", err)
+		}
 	}
 	defer llmClient.Close()
+	if opt.WebSearch && !gollm.WebSearchEnabled(llmClient) {
+		return fmt.Errorf("--web-search is not supported by the %s provider", opt.ProviderID)
+	}
 
 	// Initialize session management
 	var chatStore api.ChatMessageStore
@@ -435,6 +1110,12 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		chatStore = sessions.NewInMemoryChatStore()
 	}
 
+	var keepSession string
+	if s, ok := chatStore.(*sessions.Session); ok {
+		keepSession = s.ID
+	}
+	pruneAtStartup(&opt, keepSession)
+
 	var recorder journal.Recorder
 	if opt.TracePath != "" {
 		var fileRecorder journal.Recorder
@@ -450,24 +1131,94 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		defer recorder.Close()
 	}
 
-	k8sAgent := &agent.Agent{
-		Model:              opt.ModelID,
-		Provider:           opt.ProviderID,
-		Kubeconfig:         opt.KubeConfigPath,
-		LLM:                llmClient,
-		MaxIterations:      opt.MaxIterations,
-		PromptTemplateFile: opt.PromptTemplateFilePath,
-		ExtraPromptPaths:   opt.ExtraPromptPaths,
-		Tools:              tools.Default(),
-		Recorder:           recorder,
-		RemoveWorkDir:      opt.RemoveWorkDir,
-		SkipPermissions:    opt.SkipPermissions,
-		EnableToolUseShim:  opt.EnableToolUseShim,
-		MCPClientEnabled:   opt.MCPClient,
-		RunOnce:            opt.Quiet,
-		InitialQuery:       queryFromCmd,
-		ChatMessageStore:   chatStore,
+	// The cast recorder adds the journal events to the recording as markers,
+	// before forwarding them to the journal.
+	var castRecorder *journal.CastRecorder
+	if opt.RecordCast != "" {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		castRecorder, err = journal.NewCastRecorder(opt.RecordCast, width, height, recorder)
+		if err != nil {
+			return fmt.Errorf("creating cast recorder: %w", err)
+		}
+		defer castRecorder.Close()
+		recorder = castRecorder
+	}
+
+	var router *gollm.ModelRouter
+	if opt.FastModel != "" {
+		router = &gollm.ModelRouter{
+			Client:          llmClient,
+			FastModel:       opt.FastModel,
+			StrongModel:     opt.ModelID,
+			ClassifierModel: opt.RouterModel,
+		}
+	}
+
+	// newAgent returns an agent with the options of the session, e.g. for the
+	// tabs of the TUI.
+	newAgent := func(kubeconfig string, chatStore api.ChatMessageStore, initialQuery string) *agent.Agent {
+		return &agent.Agent{
+			Model:                opt.ModelID,
+			Router:               router,
+			Offline:              opt.Offline,
+			Provider:             opt.ProviderID,
+			Kubeconfig:           kubeconfig,
+			LLM:                  llmClient,
+			MaxIterations:        opt.MaxIterations,
+			MaxContinuations:     opt.MaxContinuations,
+			KubectlOutputBudget:  opt.KubectlOutputBudget,
+			MaxDuration:          maxDuration,
+			MaxCost:              opt.MaxCost,
+			PromptTemplateFile:   opt.PromptTemplateFilePath,
+			ExtraPromptPaths:     opt.ExtraPromptPaths,
+			Tools:                tools.Default(),
+			CustomToolsPath:      customToolsSavePath(opt.ToolConfigPaths),
+			Recorder:             recorder,
+			RemoveWorkDir:        opt.RemoveWorkDir,
+			WorkDir:              opt.WorkDir,
+			Env:                  opt.Env,
+			Hooks:                opt.Hooks,
+			ContextEnvironments:  opt.ContextEnvironments,
+			FreezeWindows:        opt.FreezeWindows,
+			Policy:               policy,
+			Aliases:              opt.Aliases,
+			ValidateAnswers:      opt.ValidateAnswers,
+			AnswerValidators:     answerValidators,
+			VerifyRemediation:    opt.VerifyRemediation,
+			RecordChanges:        recordChanges,
+			InjectNotes:          opt.InjectNotes,
+			Language:             opt.Language,
+			WatchNotifications:   agent.WatchNotificationOptions{Desktop: opt.WatchDesktopNotifications, WebhookURL: opt.WatchWebhook},
+			Tags:                 opt.Tags,
+			JobRunner:            opt.jobRunnerOptions(),
+			AnswerCache:          answerCache,
+			PromptCache:          promptCache,
+			FanOut:               agent.FanOutOptions{MaxConcurrency: opt.FanOutConcurrency, MaxIterations: opt.FanOutMaxIterations},
+			GitOps:               opt.gitOpsOptions(),
+			SkipPermissions:      opt.SkipPermissions,
+			ReadOnly:             opt.ReadOnly || (mode != nil && mode.ReadOnly),
+			ConfineKubeconfig:    opt.confineKubeconfig,
+			StrictApprovals:      mode != nil && mode.StrictApprovals,
+			RBACPreflight:        opt.RBACPreflight,
+			CheckVersionSkew:     opt.CheckVersionSkew,
+			ForceSessionTakeover: opt.ForceTakeover,
+			HistoryFidelity:      historyFidelity,
+			EnableToolUseShim:    opt.EnableToolUseShim,
+			ToolUseShimFallback:  opt.ToolUseShimFallback,
+			MCPClientEnabled:     opt.MCPClient,
+			RunOnce:              opt.Quiet,
+			InitialQuery:         initialQuery,
+			ChatMessageStore:     chatStore,
+			Stream:               opt.streamOptions(),
+			TokenPrices:          agent.TokenPrices{Input: opt.InputTokenPrice, Output: opt.OutputTokenPrice},
+			PromptLog:            promptLog,
+		}
 	}
+	k8sAgent := newAgent(opt.KubeConfigPath, chatStore, queryFromCmd)
+	k8sAgent.InitialAttachments = stdinAttachments
 
 	err = k8sAgent.Init(ctx)
 	if err != nil {
@@ -480,17 +1231,47 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	case ui.UITypeTerminal:
 		// since stdin is already consumed, we use TTY for taking input from user
 		useTTYForInput := hasInputData
-		userInterface, err = ui.NewTerminalUI(k8sAgent, useTTYForInput, opt.ShowToolOutput, recorder)
+		terminalUI, err := ui.NewTerminalUI(k8sAgent, useTTYForInput, opt.ShowToolOutput, recorder)
 		if err != nil {
 			return fmt.Errorf("creating terminal UI: %w", err)
 		}
+		if castRecorder != nil {
+			terminalUI.RecordTo(castRecorder.Output())
+		}
+		if opt.StreamOutput {
+			terminalUI.StreamText()
+		}
+		if err := terminalUI.SetAppearance(opt.Appearance); err != nil {
+			return fmt.Errorf("creating terminal UI: %w", err)
+		}
+		userInterface = terminalUI
 	case ui.UITypeWeb:
-		userInterface, err = html.NewHTMLUserInterface(k8sAgent, opt.UIListenAddress, recorder)
+		webUI, err := html.NewHTMLUserInterface(k8sAgent, opt.UIListenAddress, recorder)
 		if err != nil {
 			return fmt.Errorf("creating web UI: %w", err)
 		}
+		webUI.SetAppearance(opt.Appearance)
+		if opt.UIOIDCIssuer != "" {
+			oidcConfig := html.OIDCConfig{
+				Issuer:       opt.UIOIDCIssuer,
+				ClientID:     opt.UIOIDCClientID,
+				ClientSecret: opt.UIOIDCClientSecret,
+				RedirectURL:  opt.UIOIDCRedirectURL,
+			}
+			if err := webUI.SetOIDC(ctx, oidcConfig); err != nil {
+				return fmt.Errorf("creating web UI: %w", err)
+			}
+		}
+		userInterface = webUI
 	case ui.UITypeTUI:
-		userInterface = ui.NewTUI(k8sAgent)
+		tui := ui.NewTUI(k8sAgent)
+		tui.EnableTabs(func(ctx context.Context, kubeContext string) (*agent.Agent, func(), error) {
+			return newTabAgent(ctx, opt, kubeconfigPath, kubeContext, sessionManager, newAgent)
+		})
+		if opt.Notify {
+			tui.EnableNotifications()
+		}
+		userInterface = tui
 	default:
 		return fmt.Errorf("user-interface mode %q is not known", opt.UIType)
 	}
@@ -501,31 +1282,21 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 func handleCustomTools(toolConfigPaths []string) error {
 	// resolve tool config paths, and then load and register custom tools from config files and dirs
 	for _, path := range toolConfigPaths {
-		pathWithPlaceholdersExpanded := path
-
-		if strings.Contains(pathWithPlaceholdersExpanded, "{CONFIG}") {
-			configDir, err := os.UserConfigDir()
-			if err != nil {
-				klog.Warningf("Failed to get user config directory for tools path %q: %v", path, err)
-				continue
-			}
-			pathWithPlaceholdersExpanded = strings.ReplaceAll(pathWithPlaceholdersExpanded, "{CONFIG}", configDir)
-		}
-
-		if strings.Contains(pathWithPlaceholdersExpanded, "{HOME}") {
-			homeDir, err := os.UserHomeDir()
-			if err != nil {
-				klog.Warningf("Failed to get user home directory for tools path %q: %v", path, err)
-				continue
-			}
-			pathWithPlaceholdersExpanded = strings.ReplaceAll(pathWithPlaceholdersExpanded, "{HOME}", homeDir)
+		cleanedPath, err := expandPathPlaceholders(path)
+		if err != nil {
+			klog.Warningf("Failed to resolve tools path %q: %v", path, err)
+			continue
 		}
 
-		cleanedPath := filepath.Clean(pathWithPlaceholdersExpanded)
-
 		klog.Infof("Attempting to load custom tools from processed path: %q (original value from config: %q)", cleanedPath, path)
 
 		if err := tools.LoadAndRegisterCustomTools(cleanedPath); err != nil {
+			var invalid *tools.InvalidCustomToolsError
+			if errors.As(err, &invalid) {
+				// None of the tools of an invalid file are registered, which
+				// must not go unnoticed.
+				return err
+			}
 			if errors.Is(err, os.ErrNotExist) && !slices.Contains(defaultToolConfigPaths, path) {
 				// user specified a directory that does not exist, we must error out
 				return fmt.Errorf("custom tools directory not found (original value: %q, processed path: %q)", path, cleanedPath)
@@ -537,6 +1308,42 @@ func handleCustomTools(toolConfigPaths []string) error {
 	return nil
 }
 
+// customToolsSavePath returns the custom tools configuration file, or
+// directory, the tools created in the session are saved to: the first of the
+// configured paths.
+func customToolsSavePath(toolConfigPaths []string) string {
+	for _, path := range toolConfigPaths {
+		expandedPath, err := expandPathPlaceholders(path)
+		if err != nil {
+			continue
+		}
+		return expandedPath
+	}
+	return ""
+}
+
+// expandPathPlaceholders replaces the {CONFIG} and {HOME} placeholders of a
+// path with the user config and home directories.
+func expandPathPlaceholders(path string) (string, error) {
+	if strings.Contains(path, "{CONFIG}") {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("getting user config directory: %w", err)
+		}
+		path = strings.ReplaceAll(path, "{CONFIG}", configDir)
+	}
+
+	if strings.Contains(path, "{HOME}") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("getting user home directory: %w", err)
+		}
+		path = strings.ReplaceAll(path, "{HOME}", homeDir)
+	}
+
+	return filepath.Clean(path), nil
+}
+
 // repl is a read-eval-print loop for the chat session.
 func repl(ctx context.Context, initialQuery string, ui ui.UI, agent *agent.Agent) error {
 	query := initialQuery
@@ -552,9 +1359,26 @@ func repl(ctx context.Context, initialQuery string, ui ui.UI, agent *agent.Agent
 		return fmt.Errorf("running UI: %w", err)
 	}
 
+	// In quiet mode, the exit code tells scripts why the query failed.
+	if agent.RunOnce {
+		if err := agent.LastError(); err != nil {
+			return &reportedError{err: err}
+		}
+	}
+
 	return nil
 }
 
+// reportedError is an error the UI already showed to the user, which only
+// sets the exit code.
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string { return e.err.Error() }
+
+func (e *reportedError) Unwrap() error { return e.err }
+
 // Redirect standard log output to our custom klog writer
 // This is primarily to suppress warning messages from
 // genai library https://github.com/googleapis/go-genai/blob/6ac4afc0168762dc3b7a4d940fc463cc1854f366/types.go#L1633
@@ -589,6 +1413,26 @@ func hasStdInData() (bool, error) {
 	return hasData, nil
 }
 
+// Values of --stdin-as.
+const (
+	stdinAsQuery   = "query"
+	stdinAsContext = "context"
+)
+
+// readStdinAttachment reads the piped input, attached to the query as
+// reference material with --stdin-as=context.
+func readStdinAttachment(label string) (api.Attachment, error) {
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return api.Attachment{}, fmt.Errorf("reading stdin: %w", err)
+	}
+	if strings.TrimSpace(string(b)) == "" {
+		return api.Attachment{}, fmt.Errorf("no context provided from stdin")
+	}
+	// The indentation of the first line is kept, e.g. of a YAML excerpt.
+	return api.Attachment{Label: label, Content: strings.TrimRight(string(b), " \t\r\n")}, nil
+}
+
 // resolveQueryInput determines the query input from positional args and/or stdin.
 // It supports:
 // - 1 positional arg only -> kubectl-ai "get pods"
@@ -639,6 +1483,140 @@ func resolveQueryInput(hasStdInData bool, args []string) (string, error) {
 	}
 }
 
+// startPprofServer serves the runtime profiles of net/http/pprof on addr,
+// e.g. to profile with `go tool pprof http://localhost:6060/debug/pprof/profile`.
+func startPprofServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		klog.Infof("serving runtime profiles on http://%s/debug/pprof/", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			klog.Warningf("pprof server stopped: %v", err)
+		}
+	}()
+}
+
+// applyKubeconfigSelection applies --context, --cluster, --user and --namespace: the
+// selected context is written to a temporary kubeconfig, used instead of the
+// kubeconfig of opt by the tools and the MCP server. It fails if the selection
+// is not defined in the kubeconfig. The returned function removes the
+// temporary kubeconfig.
+func applyKubeconfigSelection(ctx context.Context, opt *Options) (func(), error) {
+	selection := tools.KubeconfigSelection{Context: opt.KubeContext, Cluster: opt.KubeCluster, User: opt.KubeUser, Namespace: opt.Namespace}
+	if selection.IsZero() {
+		return func() {}, nil
+	}
+	dir, err := os.MkdirTemp("", "kubectl-ai-kubeconfig-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	if err := tools.WriteSelectedKubeconfig(ctx, tools.InvokeToolOptions{Kubeconfig: opt.KubeConfigPath, WorkDir: dir}, selection, kubeconfig); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("invalid kubeconfig selection: %w", err)
+	}
+	opt.KubeConfigPath = kubeconfig
+	opt.KubeContext, opt.KubeCluster, opt.KubeUser, opt.Namespace = "", "", "", ""
+	return func() { os.RemoveAll(dir) }, nil
+}
+
+// sessionKubeContext returns the kubeconfig context of the session: the
+// context selected with --context, or the current context of the kubeconfig.
+func sessionKubeContext(opt Options) (string, error) {
+	if opt.KubeContext != "" {
+		return opt.KubeContext, nil
+	}
+	kubeContext, err := tools.CurrentContext(opt.KubeConfigPath)
+	if err != nil {
+		return "", err
+	}
+	if kubeContext == "" {
+		return "", fmt.Errorf("kubeconfig %q has no current context", opt.KubeConfigPath)
+	}
+	return kubeContext, nil
+}
+
+// resolveMode returns the operating mode of the session: --mode, or the mode
+// of the kubeconfig context in the context modes. It returns nil without mode,
+// and fails if the context of the context modes can't be determined.
+func resolveMode(opt Options) (*agent.Mode, error) {
+	for i := range opt.ContextModes {
+		if err := opt.ContextModes[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid context mode configuration: %w", err)
+		}
+	}
+	name := opt.Mode
+	if name == "" && len(opt.ContextModes) > 0 {
+		// Without its context, the session could run without the restrictions
+		// of its mode.
+		kubeContext, err := sessionKubeContext(opt)
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine the kubeconfig context to select its mode, select the mode with --mode: %w", err)
+		}
+		name = agent.ModeForContext(opt.ContextModes, kubeContext)
+	}
+	if name == "" {
+		return nil, nil
+	}
+	mode, err := agent.LookupMode(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --mode: %w", err)
+	}
+	klog.Infof("Running in the %s mode", mode.Name)
+	return mode, nil
+}
+
+// confirmProductionContext asks the user to type the name of the kubeconfig
+// context of the session when it is classified as production, unless it was
+// confirmed with --confirm-context. A context that can't be determined must be
+// confirmed with --confirm-context.
+func confirmProductionContext(opt Options) error {
+	if len(opt.ContextEnvironments) == 0 {
+		return nil
+	}
+	kubeContext, err := sessionKubeContext(opt)
+	if err != nil {
+		// The context may be production: it must be confirmed all the same.
+		if opt.ConfirmContext == "" {
+			return fmt.Errorf("unable to determine the kubeconfig context to classify it, confirm it with --confirm-context: %w", err)
+		}
+		klog.Warningf("Unable to determine the kubeconfig context to classify it, continuing as confirmed with --confirm-context=%s: %v", opt.ConfirmContext, err)
+		return nil
+	}
+	if agent.ClassifyContext(opt.ContextEnvironments, kubeContext) != agent.EnvironmentProduction {
+		return nil
+	}
+
+	banner := fmt.Sprintf("\033[1;41m ⚠️  PRODUCTION \033[0m The kubeconfig context %q is classified as production.\n", kubeContext)
+	if opt.ConfirmContext != "" {
+		if opt.ConfirmContext != kubeContext {
+			return fmt.Errorf("--confirm-context=%s does not match the production context %q", opt.ConfirmContext, kubeContext)
+		}
+		fmt.Fprint(os.Stderr, banner)
+		return nil
+	}
+
+	// The confirmation is read from the terminal, as stdin may hold the query.
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("the kubeconfig context %q is classified as production, confirm it with --confirm-context=%s", kubeContext, kubeContext)
+	}
+	defer tty.Close()
+	fmt.Fprintf(tty, "%sType the name of the context to continue: ", banner)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading the confirmation of the production context: %w", err)
+	}
+	if strings.TrimSpace(line) != kubeContext {
+		return fmt.Errorf("the production context %q was not confirmed", kubeContext)
+	}
+	return nil
+}
+
 func resolveKubeConfigPath(opt *Options) error {
 	switch {
 	case opt.KubeConfigPath != "":
@@ -651,6 +1629,16 @@ func resolveKubeConfigPath(opt *Options) error {
 			return fmt.Errorf("failed to get user home directory: %w", err)
 		}
 		opt.KubeConfigPath = filepath.Join(home, ".kube", "config")
+		// In a pod without a kubeconfig, e.g. a sidecar or a web UI deployment,
+		// the tools use the credentials of the service account of the pod.
+		if _, err := os.Stat(opt.KubeConfigPath); errors.Is(err, fs.ErrNotExist) && tools.InCluster() {
+			kubeconfig := filepath.Join(os.TempDir(), "kubectl-ai-in-cluster-kubeconfig")
+			if err := tools.WriteInClusterKubeconfig(kubeconfig); err != nil {
+				return err
+			}
+			klog.Infof("Using the in-cluster service account, kubeconfig written to %s", kubeconfig)
+			opt.KubeConfigPath = kubeconfig
+		}
 	}
 
 	// We resolve the kubeconfig path to an absolute path, so we can run kubectl from any working directory.
@@ -670,15 +1658,48 @@ func startMCPServer(ctx context.Context, opt Options) error {
 	if err := os.MkdirAll(workDir, 0o755); err != nil {
 		return fmt.Errorf("error creating work directory: %w", err)
 	}
-	mcpServer, err := newKubectlMCPServer(ctx, opt.KubeConfigPath, tools.Default(), workDir, opt.ExternalTools, opt.MCPServerMode, opt.SSEndpointPort)
+	builtinTools := tools.Default()
+	serverTools := builtinTools
+	if opt.MCPCompositeTools {
+		llmClient, err := gollm.NewClient(ctx, opt.ProviderID, opt.llmClientOptions(opt.promptLogOptions())...)
+		if err != nil {
+			return fmt.Errorf("creating llm client: %w", err)
+		}
+		defer llmClient.Close()
+		// The composite tools investigate with the other tools, not with each other.
+		serverTools = tools.Tools{}
+		serverTools.Init()
+		for _, tool := range builtinTools.AllTools() {
+			serverTools.RegisterTool(tool)
+		}
+		for _, tool := range agent.CompositeTools(agent.CompositeToolOptions{
+			LLM:                 llmClient,
+			Model:               opt.ModelID,
+			Tools:               builtinTools,
+			MaxIterations:       opt.MCPCompositeMaxIterations,
+			KubectlOutputBudget: opt.KubectlOutputBudget,
+		}) {
+			serverTools.RegisterTool(tool)
+		}
+	}
+	mcpServer, err := newKubectlMCPServer(ctx, opt.KubeConfigPath, serverTools, workDir, opt.ExternalTools, opt.MCPServerMode, opt.SSEndpointPort)
 	if err != nil {
 		return fmt.Errorf("creating mcp server: %w", err)
 	}
+	if opt.MCPTenantsConfig != "" {
+		tenants, err := loadMCPTenants(opt.MCPTenantsConfig, opt.KubeConfigPath, workDir)
+		if err != nil {
+			return fmt.Errorf("loading mcp tenants: %w", err)
+		}
+		mcpServer.tenants = tenants
+	}
+	mcpServer.readOnly = opt.ReadOnly
 	return mcpServer.Serve(ctx)
 }
 
-// handleListSessions lists all available sessions with their metadata.
-func handleListSessions() error {
+// handleListSessions lists all available sessions with their metadata, or
+// only the sessions with all the tags if any.
+func handleListSessions(tags []string) error {
 	manager, err := sessions.NewSessionManager()
 	if err != nil {
 		return fmt.Errorf("failed to create session manager: %w", err)
@@ -689,28 +1710,63 @@ func handleListSessions() error {
 		return fmt.Errorf("failed to list sessions: %w", err)
 	}
 
-	if len(sessionList) == 0 {
-		fmt.Println("No sessions found.")
+	type listedSession struct {
+		session  *sessions.Session
+		metadata *sessions.Metadata
+	}
+	var listed []listedSession
+	for _, session := range sessionList {
+		metadata, err := session.LoadMetadata()
+		if err != nil {
+			if len(tags) == 0 {
+				listed = append(listed, listedSession{session: session})
+			}
+			continue
+		}
+		if metadata.HasTags(tags) {
+			listed = append(listed, listedSession{session, metadata})
+		}
+	}
+
+	if len(listed) == 0 {
+		if len(tags) > 0 {
+			fmt.Printf("No sessions tagged %s found.\n", strings.Join(tags, ", "))
+		} else {
+			fmt.Println("No sessions found.")
+		}
 		return nil
 	}
 
 	fmt.Println("Available sessions:")
-	fmt.Println("ID\t\tCreated\t\t\tLast Accessed\t\tModel\t\tProvider")
-	fmt.Println("--\t\t-------\t\t\t-------------\t\t-----\t\t--------")
+	fmt.Println("ID\t\tCreated\t\t\tLast Accessed\t\tModel\t\tProvider\tContext\t\tCommands\tTags")
+	fmt.Println("--\t\t-------\t\t\t-------------\t\t-----\t\t--------\t-------\t\t--------\t----")
 
-	for _, session := range sessionList {
-		metadata, err := session.LoadMetadata()
-		if err != nil {
+	for _, l := range listed {
+		session, metadata := l.session, l.metadata
+		if metadata == nil {
 			fmt.Printf("%s\t\t<error loading metadata>\n", session.ID)
 			continue
 		}
 
-		fmt.Printf("%s\t%s\t%s\t%s\t%s\n",
+		var kubeContext string
+		if metadata.Usage != nil {
+			kubeContext = metadata.Usage.Context
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\t%d\t\t%s\n",
 			session.ID,
 			metadata.CreatedAt.Format("2006-01-02 15:04:05"),
 			metadata.LastAccessed.Format("2006-01-02 15:04:05"),
 			metadata.ModelID,
-			metadata.ProviderID)
+			metadata.ProviderID,
+			kubeContext,
+			metadata.Usage.TotalCommands(),
+			strings.Join(metadata.Tags, ","))
+
+		if metadata.Usage != nil {
+			for _, change := range metadata.Usage.ResourcesModified {
+				fmt.Printf("\t\tmodified: %s\n", change)
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,101 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/spf13/pflag"
+)
+
+func TestResolveModeContext(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte("current-context: dev\ncontexts:\n- name: dev\n- name: prod-eu\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	contextModes := []agent.ContextMode{{Pattern: "*prod*", Mode: "auditor"}, {Pattern: "dev", Mode: "developer"}}
+
+	for _, tc := range []struct {
+		name    string
+		opt     Options
+		want    string
+		wantErr bool
+	}{
+		{name: "current context", opt: Options{KubeConfigPath: kubeconfig, ContextModes: contextModes}, want: "developer"},
+		{name: "--context", opt: Options{KubeConfigPath: kubeconfig, KubeContext: "prod-eu", ContextModes: contextModes}, want: "auditor"},
+		{name: "--mode", opt: Options{KubeConfigPath: kubeconfig, KubeContext: "prod-eu", Mode: "sre", ContextModes: contextModes}, want: "sre"},
+		{name: "unknown context", opt: Options{KubeConfigPath: filepath.Join(t.TempDir(), "missing"), ContextModes: contextModes}, wantErr: true},
+		{name: "no context modes", opt: Options{KubeConfigPath: filepath.Join(t.TempDir(), "missing")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mode, err := resolveMode(tc.opt)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("resolveMode() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			got := ""
+			if mode != nil {
+				got = mode.Name
+			}
+			if got != tc.want {
+				t.Errorf("resolveMode() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfirmProductionContextUnknown(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	environments := []agent.ContextEnvironment{{Pattern: "*prod*", Environment: agent.EnvironmentProduction}}
+
+	if err := confirmProductionContext(Options{KubeConfigPath: missing, ContextEnvironments: environments}); err == nil {
+		t.Errorf("confirmProductionContext() = nil for an unknown context, want it to require --confirm-context")
+	}
+	if err := confirmProductionContext(Options{KubeConfigPath: missing, ContextEnvironments: environments, ConfirmContext: "prod-eu"}); err != nil {
+		t.Errorf("confirmProductionContext() with --confirm-context = %v", err)
+	}
+	if err := confirmProductionContext(Options{KubeConfigPath: missing}); err != nil {
+		t.Errorf("confirmProductionContext() without environments = %v", err)
+	}
+}
+
+func TestGeminiThinkingBudget(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want *int32
+	}{
+		{nil, nil},
+		{[]string{"--gemini-thinking-budget=-1"}, ptr(int32(-1))},
+		{[]string{"--gemini-thinking-budget=0"}, ptr(int32(0))},
+		{[]string{"--gemini-thinking-budget=1024"}, ptr(int32(1024))},
+	} {
+		opt := Options{}
+		f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		f.Var(optionalInt{&opt.GeminiThinkingBudget}, "gemini-thinking-budget", "")
+		if err := f.Parse(tc.args); err != nil {
+			t.Fatalf("Parse(%v): %v", tc.args, err)
+		}
+		got := opt.geminiOptions().ThinkingBudget
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Errorf("thinking budget of %v = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
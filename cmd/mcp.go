@@ -17,8 +17,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/health"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/mcp"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	mcpgo "github.com/mark3labs/mcp-go/mcp"
@@ -34,21 +36,32 @@ type kubectlMCPServer struct {
 	mcpManager    *mcp.Manager // Add MCP manager for external tool calls
 	mcpServerMode string       // Server mode (e.g., "mcd", "sse")
 	sseEndpoint   int          // SSE endpoint for server mode
+	// tenants maps bearer tokens to per-tenant kubeconfig and policy (only in SSE mode).
+	// If empty, all clients share the server kubeconfig.
+	tenants []*mcpTenant
+	// tenantSessions are the tenants of the SSE sessions.
+	tenantSessions *mcpTenantSessions
+	// readOnly rejects the tool calls that may modify cluster resources, for all clients.
+	readOnly bool
 }
 
 func newKubectlMCPServer(ctx context.Context, kubectlConfig string, tools tools.Tools, workDir string, exposeExternalTools bool, serverMode string, sseEndpoint int) (*kubectlMCPServer, error) {
 	s := &kubectlMCPServer{
-		kubectlConfig: kubectlConfig,
-		workDir:       workDir,
-		server: server.NewMCPServer(
-			"kubectl-ai",
-			"0.0.1",
-			server.WithToolCapabilities(true),
-		),
-		tools:         tools,
-		mcpServerMode: serverMode,
-		sseEndpoint:   sseEndpoint,
+		kubectlConfig:  kubectlConfig,
+		workDir:        workDir,
+		tools:          tools,
+		mcpServerMode:  serverMode,
+		sseEndpoint:    sseEndpoint,
+		tenantSessions: &mcpTenantSessions{},
 	}
+	s.server = server.NewMCPServer(
+		"kubectl-ai",
+		"0.0.1",
+		server.WithToolCapabilities(true),
+		// Tenants only see the tools they are allowed to call.
+		server.WithToolFilter(filterTenantTools),
+		server.WithHooks(s.tenantSessions.hooks()),
+	)
 
 	// Add built-in tools
 	for _, tool := range s.tools.AllTools() {
@@ -176,15 +189,53 @@ func (s *kubectlMCPServer) Serve(ctx context.Context) error {
 		sseServer := server.NewSSEServer(s.server)
 		endpoint := fmt.Sprintf(":%d", s.sseEndpoint)
 		klog.Infof("Listening for SSE connections on port %d", s.sseEndpoint)
-		return sseServer.Start(endpoint)
+		var handler http.Handler = sseServer
+		if len(s.tenants) > 0 {
+			klog.Infof("Multi-tenant mode enabled with %d tenants", len(s.tenants))
+			handler = tenantAuthMiddleware(s.tenants, s.tenantSessions, sseServer)
+		}
+		httpServer := &http.Server{
+			Addr:    endpoint,
+			Handler: health.NewChecker(s.healthChecks()...).Handler(handler),
+		}
+		return httpServer.ListenAndServe()
 	}
 
 	return server.ServeStdio(s.server)
 }
 
+// healthChecks returns the checks of the readiness endpoint of the SSE server:
+// the kubeconfig of the server or of each tenant, and the external MCP servers.
+func (s *kubectlMCPServer) healthChecks() []health.Check {
+	var checks []health.Check
+	if len(s.tenants) == 0 {
+		checks = append(checks, health.Kubeconfig(s.kubectlConfig))
+	}
+	for _, tenant := range s.tenants {
+		check := health.Kubeconfig(tenant.kubeconfigPath)
+		check.Name += "/" + tenant.Name
+		checks = append(checks, check)
+	}
+	if s.mcpManager != nil {
+		checks = append(checks, health.MCPServers(func() *mcp.Manager { return s.mcpManager }))
+	}
+	return checks
+}
+
 func (s *kubectlMCPServer) handleToolCall(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
 	toolName := request.Params.Name
 
+	if len(s.tenants) > 0 {
+		tenant := tenantFromContext(ctx)
+		if tenant == nil {
+			return toolCallError("unauthenticated tool call"), nil
+		}
+		if !tenant.allowsTool(toolName) {
+			klog.Warningf("Tenant %q is not allowed to call tool %q", tenant.Name, toolName)
+			return toolCallError(fmt.Sprintf("tool %q is not allowed for tenant %q", toolName, tenant.Name)), nil
+		}
+	}
+
 	// First, try to find the tool in our built-in tools collection
 	builtinTool := s.tools.Lookup(toolName)
 	if builtinTool != nil {
@@ -210,9 +261,15 @@ func (s *kubectlMCPServer) handleToolCall(ctx context.Context, request mcpgo.Cal
 
 // handleBuiltinToolCall handles calls to built-in kubectl-ai tools
 func (s *kubectlMCPServer) handleBuiltinToolCall(ctx context.Context, request mcpgo.CallToolRequest, tool tools.Tool) (*mcpgo.CallToolResult, error) {
-	// Set up context for built-in tools
-	ctx = context.WithValue(ctx, tools.KubeconfigKey, s.kubectlConfig)
-	ctx = context.WithValue(ctx, tools.WorkDirKey, s.workDir)
+	// Run the tools with the tenant's kubeconfig and working directory.
+	// checkConfined below keeps the tenants from selecting others.
+	kubeconfig, workDir := s.kubectlConfig, s.workDir
+	tenant := tenantFromContext(ctx)
+	if tenant != nil {
+		kubeconfig, workDir = tenant.kubeconfigPath, tenant.workDir
+	}
+	ctx = context.WithValue(ctx, tools.KubeconfigKey, kubeconfig)
+	ctx = context.WithValue(ctx, tools.WorkDirKey, workDir)
 
 	// Convert arguments to the expected type
 	args, ok := request.Params.Arguments.(map[string]any)
@@ -228,6 +285,25 @@ func (s *kubectlMCPServer) handleBuiltinToolCall(ctx context.Context, request mc
 		}, nil
 	}
 
+	if tenant != nil {
+		if err := tenant.checkConfined(args); err != nil {
+			klog.Warningf("Rejected tool call %q for tenant %q: %v", tool.Name(), tenant.Name, err)
+			return toolCallError(fmt.Sprintf("tenant %q can only use its own kubeconfig and working directory: %v", tenant.Name, err)), nil
+		}
+	}
+	if tenant != nil && tenant.ReadOnly {
+		if modifies := tool.CheckModifiesResource(args); modifies != "no" {
+			klog.Warningf("Rejected tool call %q for read-only tenant %q (modifies resource: %s)", tool.Name(), tenant.Name, modifies)
+			return toolCallError(fmt.Sprintf("tenant %q is read-only and this call may modify resources (modifies resource: %s)", tenant.Name, modifies)), nil
+		}
+	}
+	if s.readOnly {
+		if modifies := tool.CheckModifiesResource(args); modifies != "no" {
+			klog.Warningf("Rejected tool call %q on read-only server (modifies resource: %s)", tool.Name(), modifies)
+			return toolCallError(fmt.Sprintf("the server is read-only and this call may modify resources (modifies resource: %s)", modifies)), nil
+		}
+	}
+
 	// Execute the built-in tool
 	result, err := tool.Run(ctx, args)
 	if err != nil {
@@ -265,6 +341,14 @@ func (s *kubectlMCPServer) handleBuiltinToolCall(ctx context.Context, request mc
 func (s *kubectlMCPServer) handleExternalMCPToolCall(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
 	toolName := request.Params.Name
 
+	// We cannot tell if external tools modify resources, so read-only tenants cannot call them
+	if tenant := tenantFromContext(ctx); tenant != nil && tenant.ReadOnly {
+		return toolCallError(fmt.Sprintf("tenant %q is read-only and cannot call external tool %q", tenant.Name, toolName)), nil
+	}
+	if s.readOnly {
+		return toolCallError(fmt.Sprintf("the server is read-only and cannot call external tool %q", toolName)), nil
+	}
+
 	// Find which server provides this tool
 	serverTools, err := s.mcpManager.ListAvailableTools(ctx)
 	if err != nil {
@@ -380,3 +464,16 @@ func (s *kubectlMCPServer) handleExternalMCPToolCall(ctx context.Context, reques
 		},
 	}, nil
 }
+
+// toolCallError builds an error result for a tool call.
+func toolCallError(msg string) *mcpgo.CallToolResult {
+	return &mcpgo.CallToolResult{
+		IsError: true,
+		Content: []mcpgo.Content{
+			mcpgo.TextContent{
+				Type: "text",
+				Text: msg,
+			},
+		},
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// mcpTenantsConfig is the format of the file passed with --mcp-tenants-config.
+// It maps the bearer tokens presented by MCP clients to tenants, so that each
+// client of the SSE server runs tools against its own kubeconfig.
+//
+// Example:
+//
+//	tenants:
+//	- name: team-a
+//	  tokens: ["s3cr3t"]
+//	  kubeconfig: /etc/kubectl-ai/team-a.kubeconfig
+//	  context: team-a-prod
+//	  readOnly: true
+//	  allowedTools: ["kubectl"]
+//	- name: platform
+//	  tokens: ["0th3r"]
+//	  allowShell: true
+type mcpTenantsConfig struct {
+	Tenants []*mcpTenant `json:"tenants"`
+}
+
+// mcpTenant describes the kubeconfig and policy applied to a single tenant.
+type mcpTenant struct {
+	// Name identifies the tenant in logs and its working directory.
+	Name string `json:"name"`
+	// Tokens are the bearer tokens that authenticate as this tenant.
+	Tokens []string `json:"tokens"`
+	// Kubeconfig is the kubeconfig used for the tenant's tool calls.
+	// Defaults to the kubeconfig of the server.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Context overrides the current-context of the tenant's kubeconfig.
+	Context string `json:"context,omitempty"`
+	// ReadOnly rejects tool calls that may modify cluster resources.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// AllowedTools restricts the tools the tenant can call. Empty allows all tools.
+	AllowedTools []string `json:"allowedTools,omitempty"`
+	// AllowShell gives the tenant the bash tool, and lets it run any shell
+	// command with the other tools. Shell commands can read the kubeconfigs
+	// and working directories of the other tenants, so it is only meant for
+	// trusted tenants.
+	AllowShell bool `json:"allowShell,omitempty"`
+
+	// kubeconfigPath is the resolved kubeconfig, after applying Context.
+	kubeconfigPath string
+	// workDir is the tenant's private working directory.
+	workDir string
+}
+
+var tenantNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// loadMCPTenants reads the tenants config and prepares a working directory
+// (and kubeconfig, if a context override is set) for each tenant.
+func loadMCPTenants(configPath string, defaultKubeconfig string, workDir string) ([]*mcpTenant, error) {
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading tenants config %q: %w", configPath, err)
+	}
+	var config mcpTenantsConfig
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("parsing tenants config %q: %w", configPath, err)
+	}
+	if len(config.Tenants) == 0 {
+		return nil, fmt.Errorf("tenants config %q does not define any tenants", configPath)
+	}
+
+	seenNames := make(map[string]bool)
+	seenTokens := make(map[string]string)
+	for _, tenant := range config.Tenants {
+		if !tenantNameRegexp.MatchString(tenant.Name) {
+			return nil, fmt.Errorf("invalid tenant name %q", tenant.Name)
+		}
+		if seenNames[tenant.Name] {
+			return nil, fmt.Errorf("duplicate tenant name %q", tenant.Name)
+		}
+		seenNames[tenant.Name] = true
+		if len(tenant.Tokens) == 0 {
+			return nil, fmt.Errorf("tenant %q has no tokens", tenant.Name)
+		}
+		for _, token := range tenant.Tokens {
+			if token == "" {
+				return nil, fmt.Errorf("tenant %q has an empty token", tenant.Name)
+			}
+			if other, ok := seenTokens[token]; ok {
+				return nil, fmt.Errorf("tenants %q and %q share a token", other, tenant.Name)
+			}
+			seenTokens[token] = tenant.Name
+		}
+
+		tenant.workDir = filepath.Join(workDir, "tenants", tenant.Name)
+		if err := os.MkdirAll(tenant.workDir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating work directory for tenant %q: %w", tenant.Name, err)
+		}
+
+		tenant.kubeconfigPath = tenant.Kubeconfig
+		if tenant.kubeconfigPath == "" {
+			tenant.kubeconfigPath = defaultKubeconfig
+		}
+		if tenant.Context != "" {
+			p, err := writeKubeconfigWithContext(tenant.kubeconfigPath, tenant.Context, tenant.workDir)
+			if err != nil {
+				return nil, fmt.Errorf("preparing kubeconfig for tenant %q: %w", tenant.Name, err)
+			}
+			tenant.kubeconfigPath = p
+		}
+	}
+	return config.Tenants, nil
+}
+
+// writeKubeconfigWithContext writes a copy of the kubeconfig into dir with its
+// current-context set to kubeContext, and returns the path of the copy.
+func writeKubeconfigWithContext(kubeconfigPath string, kubeContext string, dir string) (string, error) {
+	b, err := os.ReadFile(kubeconfigPath)
+	if err != nil {
+		return "", fmt.Errorf("reading kubeconfig %q: %w", kubeconfigPath, err)
+	}
+	var kubeconfig map[string]any
+	if err := yaml.Unmarshal(b, &kubeconfig); err != nil {
+		return "", fmt.Errorf("parsing kubeconfig %q: %w", kubeconfigPath, err)
+	}
+
+	found := false
+	contexts, _ := kubeconfig["contexts"].([]any)
+	for _, c := range contexts {
+		if m, ok := c.(map[string]any); ok && m["name"] == kubeContext {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("context %q not found in kubeconfig %q", kubeContext, kubeconfigPath)
+	}
+	kubeconfig["current-context"] = kubeContext
+
+	out, err := yaml.Marshal(kubeconfig)
+	if err != nil {
+		return "", fmt.Errorf("marshaling kubeconfig: %w", err)
+	}
+	p := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(p, out, 0o600); err != nil {
+		return "", fmt.Errorf("writing kubeconfig: %w", err)
+	}
+	return p, nil
+}
+
+// lookupToken returns the tenant authenticated by the given token, or nil.
+func lookupToken(tenants []*mcpTenant, token string) *mcpTenant {
+	if token == "" {
+		return nil
+	}
+	for _, tenant := range tenants {
+		for _, t := range tenant.Tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return tenant
+			}
+		}
+	}
+	return nil
+}
+
+// allowsTool checks the tenant policy for the named tool. The bash tool is
+// only allowed to the tenants with AllowShell.
+func (t *mcpTenant) allowsTool(name string) bool {
+	if name == "bash" && !t.AllowShell {
+		return false
+	}
+	return len(t.AllowedTools) == 0 || slices.Contains(t.AllowedTools, name)
+}
+
+// checkConfined returns an error unless the arguments of a tool call only use
+// the tenant's kubeconfig and working directory: commands may only run kubectl
+// (and filters like grep) without credential flags or KUBECONFIG=, and files
+// must be in the working directory.
+func (t *mcpTenant) checkConfined(args map[string]any) error {
+	if t.AllowShell {
+		return nil
+	}
+	return tools.CheckConfinedArguments(args)
+}
+
+// filterTenantTools hides from tools/list the tools the tenant of the request
+// is not allowed to call.
+func filterTenantTools(ctx context.Context, tools []mcpgo.Tool) []mcpgo.Tool {
+	tenant := tenantFromContext(ctx)
+	if tenant == nil {
+		return tools
+	}
+	var allowed []mcpgo.Tool
+	for _, tool := range tools {
+		if tenant.allowsTool(tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// mcpTenantSessions binds the SSE sessions to the tenants that opened them, so
+// that the token of a tenant can't post messages into the session of another.
+type mcpTenantSessions struct {
+	mu      sync.Mutex
+	tenants map[string]*mcpTenant
+}
+
+// hooks records the tenant of the sessions as they are registered, from the
+// context of the SSE request authenticated by tenantAuthMiddleware.
+func (s *mcpTenantSessions) hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.tenants == nil {
+			s.tenants = make(map[string]*mcpTenant)
+		}
+		s.tenants[session.SessionID()] = tenantFromContext(ctx)
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.tenants, session.SessionID())
+	})
+	return hooks
+}
+
+// owner returns the tenant that opened the session, or nil.
+func (s *mcpTenantSessions) owner(sessionID string) *mcpTenant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tenants[sessionID]
+}
+
+type mcpTenantContextKey struct{}
+
+func contextWithTenant(ctx context.Context, tenant *mcpTenant) context.Context {
+	return context.WithValue(ctx, mcpTenantContextKey{}, tenant)
+}
+
+func tenantFromContext(ctx context.Context) *mcpTenant {
+	tenant, _ := ctx.Value(mcpTenantContextKey{}).(*mcpTenant)
+	return tenant
+}
+
+// tenantAuthMiddleware authenticates every request with its bearer token
+// and attaches the matching tenant to the request context. Messages are only
+// accepted in the sessions opened by the same tenant.
+func tenantAuthMiddleware(tenants []*mcpTenant, sessions *mcpTenantSessions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		tenant := lookupToken(tenants, strings.TrimSpace(token))
+		if tenant == nil {
+			klog.Warningf("MCP request from %s rejected: unknown token", r.RemoteAddr)
+			http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		if sessionID := r.URL.Query().Get("sessionId"); sessionID != "" && sessions.owner(sessionID) != tenant {
+			klog.Warningf("MCP request from %s rejected: tenant %q is not the owner of session %q", r.RemoteAddr, tenant.Name, sessionID)
+			http.Error(w, "unknown session", http.StatusForbidden)
+			return
+		}
+		klog.V(2).Infof("MCP request %s %s authenticated as tenant %q", r.Method, r.URL.Path, tenant.Name)
+		next.ServeHTTP(w, r.WithContext(contextWithTenant(r.Context(), tenant)))
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+type fakeClientSession string
+
+func (s fakeClientSession) Initialize()                                           {}
+func (s fakeClientSession) Initialized() bool                                     { return true }
+func (s fakeClientSession) NotificationChannel() chan<- mcpgo.JSONRPCNotification { return nil }
+func (s fakeClientSession) SessionID() string                                     { return string(s) }
+
+func TestFilterTenantTools(t *testing.T) {
+	tools := []mcpgo.Tool{{Name: "kubectl"}, {Name: "bash"}}
+	restricted := &mcpTenant{Name: "team-a", AllowedTools: []string{"kubectl"}}
+	if got := filterTenantTools(contextWithTenant(context.Background(), restricted), tools); len(got) != 1 || got[0].Name != "kubectl" {
+		t.Errorf("filterTenantTools() = %v, want only kubectl", got)
+	}
+	if got := filterTenantTools(contextWithTenant(context.Background(), &mcpTenant{Name: "platform", AllowShell: true}), tools); len(got) != 2 {
+		t.Errorf("filterTenantTools() = %v, want all the tools", got)
+	}
+}
+
+func TestTenantToolCallsConfined(t *testing.T) {
+	s := &kubectlMCPServer{tools: tools.Default()}
+	teamA := &mcpTenant{Name: "team-a", ReadOnly: true, kubeconfigPath: "/etc/kubectl-ai/team-a.kubeconfig", workDir: t.TempDir()}
+	ctx := contextWithTenant(context.Background(), teamA)
+
+	if teamA.allowsTool("bash") || !(&mcpTenant{AllowShell: true}).allowsTool("bash") {
+		t.Errorf("the bash tool should only be allowed to the tenants with allowShell")
+	}
+	for _, command := range []string{
+		"kubectl get secrets -A --kubeconfig=/etc/kubectl-ai/team-b.kubeconfig -o yaml",
+		"kubectl get secrets -A --server=https://x --token=abc",
+		"KUBECONFIG=/etc/kubectl-ai/team-b.kubeconfig kubectl get secrets -A",
+		"kubectl get pods; cat ../team-b/kubeconfig",
+	} {
+		request := mcpgo.CallToolRequest{}
+		request.Params.Name = "kubectl"
+		request.Params.Arguments = map[string]any{"command": command, "modifies_resource": "no"}
+		result, err := s.handleToolCall(ctx, request)
+		if err != nil {
+			t.Fatalf("handleToolCall(%q): %v", command, err)
+		}
+		if !result.IsError || !strings.Contains(result.Content[0].(mcpgo.TextContent).Text, "its own kubeconfig") {
+			t.Errorf("handleToolCall(%q) = %+v, want the call rejected", command, result)
+		}
+	}
+}
+
+func TestTenantAuthMiddlewareSessions(t *testing.T) {
+	teamA := &mcpTenant{Name: "team-a", Tokens: []string{"token-a"}}
+	teamB := &mcpTenant{Name: "team-b", Tokens: []string{"token-b"}}
+	sessions := &mcpTenantSessions{}
+	hooks := sessions.hooks()
+	hooks.RegisterSession(contextWithTenant(context.Background(), teamA), fakeClientSession("session-a"))
+
+	handler := tenantAuthMiddleware([]*mcpTenant{teamA, teamB}, sessions, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		token  string
+		target string
+		want   int
+	}{
+		{"token-a", "/message?sessionId=session-a", http.StatusOK},
+		{"token-b", "/message?sessionId=session-a", http.StatusForbidden},
+		{"token-a", "/message?sessionId=unknown", http.StatusForbidden},
+		{"token-b", "/sse", http.StatusOK},
+		{"token-c", "/sse", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodPost, tc.target, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s with %s: status %d, want %d", tc.target, tc.token, rec.Code, tc.want)
+		}
+	}
+
+	hooks.UnregisterSession(context.Background(), fakeClientSession("session-a"))
+	if sessions.owner("session-a") != nil {
+		t.Errorf("the session is still bound to its tenant once unregistered")
+	}
+}
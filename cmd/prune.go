@@ -0,0 +1,151 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"k8s.io/klog/v2"
+)
+
+// tracePrefix is the prefix of the trace files pruned in the temporary
+// directory, the default trace path being kubectl-ai-trace.txt.
+const tracePrefix = "kubectl-ai-trace"
+
+// pruneReport lists what pruneStorage deleted.
+type pruneReport struct {
+	Sessions []sessions.PrunedSession
+	WorkDirs []agent.PrunedWorkDir
+	// Traces are the paths of the trace files.
+	Traces []string
+	// Freed is the number of bytes freed by the sessions and trace files.
+	Freed int64
+}
+
+func (r *pruneReport) empty() bool {
+	return len(r.Sessions) == 0 && len(r.WorkDirs) == 0 && len(r.Traces) == 0
+}
+
+// sessionRetention returns the retention policy of the saved sessions.
+func (opt *Options) sessionRetention() sessions.RetentionPolicy {
+	return sessions.RetentionPolicy{
+		MaxSessions:  opt.MaxSessions,
+		MaxAge:       time.Duration(opt.MaxSessionAgeDays) * 24 * time.Hour,
+		MaxTotalSize: int64(opt.MaxSessionsSizeMB) << 20,
+	}
+}
+
+// pruneStorage deletes the saved sessions exceeding the retention policy,
+// except keepSession, the working directories left by the processes that
+// exited, and the trace files larger than --max-trace-size-mb. With dryRun,
+// nothing is deleted.
+func pruneStorage(opt *Options, keepSession string, dryRun bool) (*pruneReport, error) {
+	report := &pruneReport{}
+	var errs []error
+
+	manager, err := sessions.NewSessionManager()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("creating session manager: %w", err))
+	} else if report.Sessions, err = manager.Prune(opt.sessionRetention(), keepSession, time.Now(), dryRun); err != nil {
+		errs = append(errs, fmt.Errorf("pruning sessions: %w", err))
+	}
+	for _, s := range report.Sessions {
+		report.Freed += s.Size
+	}
+
+	if report.WorkDirs, err = agent.PruneWorkDirs(time.Now(), dryRun); err != nil {
+		errs = append(errs, fmt.Errorf("pruning working directories: %w", err))
+	}
+
+	if opt.MaxTraceSizeMB > 0 {
+		traces, _ := filepath.Glob(filepath.Join(os.TempDir(), tracePrefix+"*"))
+		if opt.TracePath != "" && !strings.HasPrefix(filepath.Base(opt.TracePath), tracePrefix) {
+			traces = append(traces, opt.TracePath)
+		}
+		for _, trace := range traces {
+			fi, err := os.Stat(trace)
+			if err != nil || fi.IsDir() || fi.Size() <= int64(opt.MaxTraceSizeMB)<<20 {
+				continue
+			}
+			if !dryRun {
+				if err := os.Remove(trace); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			report.Traces = append(report.Traces, trace)
+			report.Freed += fi.Size()
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+// pruneAtStartup applies the retention settings, without failing the command.
+func pruneAtStartup(opt *Options, keepSession string) {
+	report, err := pruneStorage(opt, keepSession, false)
+	if err != nil {
+		klog.Warningf("error pruning old sessions and files: %v", err)
+	}
+	if !report.empty() {
+		klog.Infof("Pruned %d sessions, %d working directories and %d trace files, freeing %s", len(report.Sessions), len(report.WorkDirs), len(report.Traces), formatSize(report.Freed))
+	}
+}
+
+// handlePruneSessions implements "kubectl-ai session prune".
+func handlePruneSessions(w io.Writer, opt *Options, dryRun bool) error {
+	report, err := pruneStorage(opt, "", dryRun)
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+	}
+	for _, s := range report.Sessions {
+		fmt.Fprintf(w, "%s session %s (last accessed %s, %s): %s\n", verb, s.ID, s.LastAccessed.Format("2006-01-02 15:04"), formatSize(s.Size), s.Reason)
+	}
+	for _, d := range report.WorkDirs {
+		fmt.Fprintf(w, "%s working directory %s (last modified %s)\n", verb, d.Path, d.ModTime.Format("2006-01-02 15:04"))
+	}
+	for _, trace := range report.Traces {
+		fmt.Fprintf(w, "%s trace file %s\n", verb, trace)
+	}
+	if report.empty() {
+		fmt.Fprintln(w, "Nothing to prune.")
+	} else if dryRun {
+		fmt.Fprintf(w, "Pruning would free %s.\n", formatSize(report.Freed))
+	} else {
+		fmt.Fprintf(w, "Freed %s.\n", formatSize(report.Freed))
+	}
+	return err
+}
+
+// formatSize formats a number of bytes, e.g. 12.3 MiB.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,80 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+// newTabAgent starts the agent of a new tab of the TUI, with a session of its
+// own: a new persisted session if sessions are persisted, an in-memory one
+// otherwise. The agent uses the given context of the kubeconfig of the user,
+// or the kubeconfig of the first tab if empty. The returned function closes
+// the agent.
+func newTabAgent(ctx context.Context, opt Options, kubeconfigPath string, kubeContext string, sessionManager *sessions.SessionManager, newAgent func(kubeconfig string, chatStore api.ChatMessageStore, initialQuery string) *agent.Agent) (*agent.Agent, func(), error) {
+	kubeconfig := opt.KubeConfigPath
+	cleanup := func() {}
+	if kubeContext != "" {
+		// Production contexts are confirmed by typing their name at startup,
+		// which the TUI can't ask for.
+		if agent.ClassifyContext(opt.ContextEnvironments, kubeContext) == agent.EnvironmentProduction && kubeContext != opt.ConfirmContext {
+			return nil, nil, fmt.Errorf("the context %q is classified as production: start kubectl-ai with --confirm-context=%s to open it in a tab", kubeContext, kubeContext)
+		}
+		dir, err := os.MkdirTemp("", "kubectl-ai-kubeconfig-")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		cleanup = func() { os.RemoveAll(dir) }
+		kubeconfig = filepath.Join(dir, "kubeconfig")
+		selection := tools.KubeconfigSelection{Context: kubeContext, Namespace: opt.Namespace}
+		if err := tools.WriteSelectedKubeconfig(ctx, tools.InvokeToolOptions{Kubeconfig: kubeconfigPath, WorkDir: dir}, selection, kubeconfig); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("invalid kubeconfig context: %w", err)
+		}
+	}
+
+	var chatStore api.ChatMessageStore = sessions.NewInMemoryChatStore()
+	if sessionManager != nil {
+		session, err := sessionManager.NewSession(sessions.Metadata{ProviderID: opt.ProviderID, ModelID: opt.ModelID})
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to create a new session: %w", err)
+		}
+		chatStore = session
+	}
+
+	k8sAgent := newAgent(kubeconfig, chatStore, "")
+	if err := k8sAgent.Init(ctx); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("starting k8s agent: %w", err)
+	}
+	if err := k8sAgent.Run(ctx, ""); err != nil {
+		k8sAgent.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("running agent: %w", err)
+	}
+	return k8sAgent, func() {
+		k8sAgent.Close()
+		cleanup()
+	}, nil
+}
@@ -0,0 +1,32 @@
+#!/usr/bin/env bash
+# Copyright 2025 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Runs the tool tests against a local API server (etcd + kube-apiserver + kubectl),
+# installed with setup-envtest. ENVTEST_K8S_VERSION selects the Kubernetes version.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+
+REPO_ROOT="$(git rev-parse --show-toplevel)"
+cd ${REPO_ROOT}
+
+ENVTEST_K8S_VERSION=${ENVTEST_K8S_VERSION:-1.31.x}
+
+export KUBEBUILDER_ASSETS="$(go run sigs.k8s.io/controller-runtime/tools/setup-envtest@release-0.19 use ${ENVTEST_K8S_VERSION} -p path)"
+echo "Using envtest binaries from ${KUBEBUILDER_ASSETS}"
+
+go test ./pkg/tools/... -run '^TestEnvtest' -v
@@ -17,6 +17,7 @@ package gollm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"slices"
@@ -30,8 +31,6 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/cognitiveservices/armcognitiveservices"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
-
-	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 )
 
 func init() {
@@ -51,6 +50,8 @@ func azureOpenAIFactory(ctx context.Context, opts ClientOptions) (Client, error)
 type AzureOpenAIClient struct {
 	client   *azopenai.Client
 	endpoint string
+	// maxOutputTokens caps the tokens of each response, if positive.
+	maxOutputTokens int
 }
 
 var _ Client = &AzureOpenAIClient{}
@@ -67,13 +68,14 @@ func NewAzureOpenAIClient(ctx context.Context, opts ClientOptions) (*AzureOpenAI
 		return nil, fmt.Errorf("AZURE_OPENAI_ENDPOINT environment variable not set")
 	}
 	azureOpenAIClient := AzureOpenAIClient{
-		endpoint: azureOpenAIEndpoint,
+		endpoint:        azureOpenAIEndpoint,
+		maxOutputTokens: opts.MaxOutputTokens,
 	}
 
 	// Create a custom HTTP client (supports SkipVerifySSL)
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
+	httpClient := createCustomHTTPClient(opts)
 
-	azureOpenAIKey := os.Getenv("AZURE_OPENAI_API_KEY")
+	azureOpenAIKey := providerAPIKey("azopenai")
 	clientOpts := &azopenai.ClientOptions{
 		ClientOptions: azcore.ClientOptions{
 			Transport: httpClient,
@@ -205,8 +207,9 @@ func (c *AzureOpenAIClient) SetResponseSchema(schema *Schema) error {
 
 func (c *AzureOpenAIClient) StartChat(systemPrompt string, model string) Chat {
 	return &AzureOpenAIChat{
-		client: c.client,
-		model:  model,
+		client:          c.client,
+		model:           model,
+		maxOutputTokens: c.maxOutputTokens,
 		history: []azopenai.ChatRequestMessageClassification{
 			&azopenai.ChatRequestSystemMessage{Content: azopenai.NewChatRequestSystemMessageContent(systemPrompt)},
 		},
@@ -230,6 +233,8 @@ type AzureOpenAIChat struct {
 	model   string
 	history []azopenai.ChatRequestMessageClassification
 	tools   []azopenai.ChatCompletionsToolDefinitionClassification
+
+	maxOutputTokens int
 }
 
 func (c *AzureOpenAIChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
@@ -250,11 +255,15 @@ func (c *AzureOpenAIChat) Send(ctx context.Context, contents ...any) (ChatRespon
 		}
 	}
 
-	resp, err := c.client.GetChatCompletions(ctx, azopenai.ChatCompletionsOptions{
+	req := azopenai.ChatCompletionsOptions{
 		DeploymentName: &c.model,
 		Messages:       c.history,
 		Tools:          c.tools,
-	}, nil)
+	}
+	if c.maxOutputTokens > 0 {
+		req.MaxTokens = ptrTo(int32(c.maxOutputTokens))
+	}
+	resp, err := c.client.GetChatCompletions(ctx, req, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -266,11 +275,14 @@ func (c *AzureOpenAIChat) Send(ctx context.Context, contents ...any) (ChatRespon
 }
 
 func (c *AzureOpenAIChat) IsRetryableError(err error) bool {
-	// TODO: Implement this
-	return false
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return isRetryableStatus(respErr.StatusCode)
+	}
+	return DefaultIsRetryableError(err)
 }
 
-func (c *AzureOpenAIChat) Initialize(messages []*api.Message) error {
+func (c *AzureOpenAIChat) Initialize(messages []*Message) error {
 	klog.Warning("chat history persistence is not supported for provider 'azopenai', using in-memory chat history")
 	return nil
 }
@@ -305,6 +317,25 @@ func (r *AzureOpenAIChatResponse) UsageMetadata() any {
 	return r.azureOpenAIResponse.Usage
 }
 
+func (r *AzureOpenAIChatResponse) TokenUsage() *Usage {
+	usage := r.azureOpenAIResponse.Usage
+	if usage == nil {
+		return nil
+	}
+	return &Usage{
+		InputTokens:  int32Value(usage.PromptTokens),
+		OutputTokens: int32Value(usage.CompletionTokens),
+		TotalTokens:  int32Value(usage.TotalTokens),
+	}
+}
+
+func int32Value(p *int32) int {
+	if p == nil {
+		return 0
+	}
+	return int(*p)
+}
+
 func (r *AzureOpenAIChatResponse) Candidates() []Candidate {
 	var candidates []Candidate
 	for _, candidate := range r.azureOpenAIResponse.Choices {
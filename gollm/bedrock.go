@@ -19,11 +19,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
@@ -47,6 +47,8 @@ func newBedrockClientFactory(ctx context.Context, opts ClientOptions) (Client, e
 // BedrockClient implements the gollm.Client interface for AWS Bedrock models
 type BedrockClient struct {
 	client *bedrockruntime.Client
+	// maxOutputTokens caps the tokens of each response, if positive.
+	maxOutputTokens int
 }
 
 // Ensure BedrockClient implements the Client interface
@@ -58,7 +60,11 @@ func NewBedrockClient(ctx context.Context, opts ClientOptions) (*BedrockClient,
 	configCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	cfg, err := config.LoadDefaultConfig(configCtx)
+	var loadOptions []func(*config.LoadOptions) error
+	if httpClient := createCustomHTTPClient(opts); httpClient != http.DefaultClient {
+		loadOptions = append(loadOptions, config.WithHTTPClient(httpClient))
+	}
+	cfg, err := config.LoadDefaultConfig(configCtx, loadOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -69,7 +75,8 @@ func NewBedrockClient(ctx context.Context, opts ClientOptions) (*BedrockClient,
 	}
 
 	return &BedrockClient{
-		client: bedrockruntime.NewFromConfig(cfg),
+		client:          bedrockruntime.NewFromConfig(cfg),
+		maxOutputTokens: opts.MaxOutputTokens,
 	}, nil
 }
 
@@ -141,37 +148,24 @@ type bedrockChat struct {
 	functionDefs []*FunctionDefinition
 }
 
-func (cs *bedrockChat) Initialize(history []*api.Message) error {
+func (cs *bedrockChat) Initialize(history []*Message) error {
 	cs.messages = make([]types.Message, 0, len(history))
 
 	for _, msg := range history {
-		// Convert api.Message to types.Message
 		var role types.ConversationRole
-		switch msg.Source {
-		case api.MessageSourceUser:
+		switch msg.Role {
+		case RoleUser:
 			role = types.ConversationRoleUser
-		case api.MessageSourceModel, api.MessageSourceAgent:
+		case RoleModel:
 			role = types.ConversationRoleAssistant
 		default:
-			// Skip unknown message sources
-			continue
-		}
-
-		// Convert payload to string content
-		var content string
-		if msg.Type == api.MessageTypeText && msg.Payload != nil {
-			if textPayload, ok := msg.Payload.(string); ok {
-				content = textPayload
-			} else {
-				// Try to convert other types to string
-				content = fmt.Sprintf("%v", msg.Payload)
-			}
-		} else {
-			// Skip non-text messages for now
+			// Skip unknown roles
 			continue
 		}
 
-		if content == "" {
+		// Only text messages are restored for now
+		content, ok := msg.Content.(string)
+		if !ok || content == "" {
 			continue
 		}
 
@@ -188,6 +182,34 @@ func (cs *bedrockChat) Initialize(history []*api.Message) error {
 	return nil
 }
 
+// bedrockPromptCaching reports whether the model caches the prefixes of the
+// requests ending with a cache point.
+func bedrockPromptCaching(model string) bool {
+	return strings.Contains(model, "anthropic.claude")
+}
+
+// system returns the system prompt, followed by a cache point for the models
+// caching it, as it is the same for all the requests of the chat.
+func (c *bedrockChat) system() []types.SystemContentBlock {
+	system := []types.SystemContentBlock{
+		&types.SystemContentBlockMemberText{Value: c.systemPrompt},
+	}
+	if bedrockPromptCaching(c.model) {
+		system = append(system, &types.SystemContentBlockMemberCachePoint{Value: types.CachePointBlock{Type: types.CachePointTypeDefault}})
+	}
+	return system
+}
+
+// inferenceConfig returns the inference parameters of the requests, with
+// responses of at most 4096 tokens by default.
+func (c *bedrockChat) inferenceConfig() *types.InferenceConfiguration {
+	maxTokens := int32(4096)
+	if c.client.maxOutputTokens > 0 {
+		maxTokens = int32(c.client.maxOutputTokens)
+	}
+	return &types.InferenceConfiguration{MaxTokens: aws.Int32(maxTokens)}
+}
+
 // Send sends a message to the chat and returns the response
 func (c *bedrockChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
 	if len(contents) == 0 {
@@ -201,18 +223,14 @@ func (c *bedrockChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 
 	// Prepare the request
 	input := &bedrockruntime.ConverseInput{
-		ModelId:  aws.String(c.model),
-		Messages: c.messages,
-		InferenceConfig: &types.InferenceConfiguration{
-			MaxTokens: aws.Int32(4096),
-		},
+		ModelId:         aws.String(c.model),
+		Messages:        c.messages,
+		InferenceConfig: c.inferenceConfig(),
 	}
 
 	// Add system prompt if provided
 	if c.systemPrompt != "" {
-		input.System = []types.SystemContentBlock{
-			&types.SystemContentBlockMemberText{Value: c.systemPrompt},
-		}
+		input.System = c.system()
 	}
 
 	// Add tool configuration if functions are defined
@@ -255,18 +273,14 @@ func (c *bedrockChat) SendStreaming(ctx context.Context, contents ...any) (ChatR
 
 	// Prepare the streaming request
 	input := &bedrockruntime.ConverseStreamInput{
-		ModelId:  aws.String(c.model),
-		Messages: c.messages,
-		InferenceConfig: &types.InferenceConfiguration{
-			MaxTokens: aws.Int32(4096),
-		},
+		ModelId:         aws.String(c.model),
+		Messages:        c.messages,
+		InferenceConfig: c.inferenceConfig(),
 	}
 
 	// Add system prompt if provided
 	if c.systemPrompt != "" {
-		input.System = []types.SystemContentBlock{
-			&types.SystemContentBlockMemberText{Value: c.systemPrompt},
-		}
+		input.System = c.system()
 	}
 
 	// Add tool configuration if functions are defined
@@ -421,9 +435,30 @@ func (c *bedrockChat) SendStreaming(ctx context.Context, contents ...any) (ChatR
 	}, nil
 }
 
+// bedrockPendingCalls returns the tool uses of the last message of the
+// history, if it is a response of the model.
+func bedrockPendingCalls(messages []types.Message) []FunctionCall {
+	if len(messages) == 0 || messages[len(messages)-1].Role != types.ConversationRoleAssistant {
+		return nil
+	}
+	var calls []FunctionCall
+	for _, block := range messages[len(messages)-1].Content {
+		if toolUse, ok := block.(*types.ContentBlockMemberToolUse); ok {
+			calls = append(calls, FunctionCall{ID: aws.ToString(toolUse.Value.ToolUseId), Name: aws.ToString(toolUse.Value.Name)})
+		}
+	}
+	return calls
+}
+
 // addContentsToHistory processes and appends user messages to chat history
 // following AWS Bedrock Converse API patterns
 func (c *bedrockChat) addContentsToHistory(contents []any) error {
+	// Each tool use of the last response must be answered, with the tool
+	// results before any other content.
+	contents, err := orderFunctionCallResults(bedrockPendingCalls(c.messages), contents)
+	if err != nil {
+		return err
+	}
 	var contentBlocks []types.ContentBlock
 
 	for _, content := range contents {
@@ -440,6 +475,9 @@ func (c *bedrockChat) addContentsToHistory(contents []any) error {
 					if errorBool, isBool := errorVal.(bool); isBool && errorBool {
 						status = types.ToolResultStatusError
 					}
+					if errorStr, isString := errorVal.(string); isString && errorStr != "" {
+						status = types.ToolResultStatusError
+					}
 				}
 				// Check for status field
 				if statusVal, hasStatus := c.Result["status"]; hasStatus {
@@ -512,6 +550,10 @@ func (c *bedrockChat) SetFunctionDefinitions(functions []*FunctionDefinition) er
 		tools = append(tools, &types.ToolMemberToolSpec{Value: toolSpec})
 	}
 
+	if bedrockPromptCaching(c.model) && len(tools) > 0 {
+		tools = append(tools, &types.ToolMemberCachePoint{Value: types.CachePointBlock{Type: types.CachePointTypeDefault}})
+	}
+
 	c.toolConfig = &types.ToolConfiguration{
 		Tools: tools,
 		ToolChoice: &types.ToolChoiceMemberAny{
@@ -541,6 +583,26 @@ func (r *bedrockResponse) UsageMetadata() any {
 	return nil
 }
 
+// TokenUsage returns the tokens used by the response
+func (r *bedrockResponse) TokenUsage() *Usage {
+	if r.output == nil {
+		return nil
+	}
+	return bedrockUsage(r.output.Usage)
+}
+
+// bedrockUsage converts the usage reported by Bedrock
+func bedrockUsage(usage *types.TokenUsage) *Usage {
+	if usage == nil {
+		return nil
+	}
+	return &Usage{
+		InputTokens:  int(aws.ToInt32(usage.InputTokens)),
+		OutputTokens: int(aws.ToInt32(usage.OutputTokens)),
+		TotalTokens:  int(aws.ToInt32(usage.TotalTokens)),
+	}
+}
+
 // Candidates returns the candidate responses
 func (r *bedrockResponse) Candidates() []Candidate {
 	if r.output == nil || r.output.Output == nil {
@@ -573,6 +635,11 @@ func (r *bedrockStreamResponse) UsageMetadata() any {
 	return r.usage
 }
 
+// TokenUsage returns the tokens used by the response, only set on the last chunk
+func (r *bedrockStreamResponse) TokenUsage() *Usage {
+	return bedrockUsage(r.usage)
+}
+
 // Candidates returns the candidate responses for streaming
 func (r *bedrockStreamResponse) Candidates() []Candidate {
 	if r.content == "" && r.usage == nil && len(r.toolUses) == 0 {
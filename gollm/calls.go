@@ -0,0 +1,86 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+)
+
+// Models can make several function calls in one response. Providers then
+// expect exactly one result per call in the next request, referring to the
+// call by its ID, and most of them in the order of the calls and before any
+// other content: OpenAI rejects a conversation where an assistant message with
+// tool calls isn't followed by a tool message for each of them, Gemini
+// requires as many function responses as function calls, and Bedrock requires
+// the tool results first in the user message.
+
+// notRunResult is the result of a function call left without a result, e.g.
+// because an earlier call of the same response failed and stopped the agent.
+var notRunResult = map[string]any{
+	"error":  "The function call was not run.",
+	"status": "skipped",
+}
+
+// orderFunctionCallResults arranges the contents sent in reply to the function
+// calls of the last response of the model: one result per call, in the order
+// of the calls, followed by the other contents. Calls without a result are
+// answered with an error. Results are matched to calls by ID, or by name for
+// providers not giving IDs to calls. Results not matching any call are an
+// error. Contents are returned unchanged when the model made no call.
+func orderFunctionCallResults(calls []FunctionCall, contents []any) ([]any, error) {
+	if len(calls) == 0 {
+		return contents, nil
+	}
+
+	var results []FunctionCallResult
+	var others []any
+	for _, content := range contents {
+		if result, ok := content.(FunctionCallResult); ok {
+			results = append(results, result)
+		} else {
+			others = append(others, content)
+		}
+	}
+
+	ordered := make([]any, 0, len(calls)+len(others))
+	used := make([]bool, len(results))
+	for _, call := range calls {
+		match := -1
+		for i, result := range results {
+			if used[i] {
+				continue
+			}
+			if (call.ID != "" && result.ID == call.ID) || (call.ID == "" && result.ID == "" && result.Name == call.Name) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			klog.Warningf("No result for function call %q (ID %q), telling the model it was not run", call.Name, call.ID)
+			ordered = append(ordered, FunctionCallResult{ID: call.ID, Name: call.Name, Result: notRunResult})
+			continue
+		}
+		used[match] = true
+		ordered = append(ordered, results[match])
+	}
+	for i, result := range results {
+		if !used[i] {
+			return nil, fmt.Errorf("result of function call %q (ID %q) doesn't answer any call of the model", result.Name, result.ID)
+		}
+	}
+	return append(ordered, others...), nil
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+func TestOrderFunctionCallResults(t *testing.T) {
+	resultA := FunctionCallResult{ID: "a", Name: "kubectl", Result: map[string]any{"stdout": "pods"}}
+	resultB := FunctionCallResult{ID: "b", Name: "bash", Result: map[string]any{"stdout": "nodes"}}
+	notRunA := FunctionCallResult{ID: "a", Name: "kubectl", Result: notRunResult}
+	calls := []FunctionCall{{ID: "a", Name: "kubectl"}, {ID: "b", Name: "bash"}}
+
+	tests := []struct {
+		name     string
+		calls    []FunctionCall
+		contents []any
+		want     []any
+		wantErr  bool
+	}{
+		{
+			name:     "results in the order of the calls, before the text",
+			calls:    calls,
+			contents: []any{"also check the nodes", resultB, resultA},
+			want:     []any{resultA, resultB, "also check the nodes"},
+		},
+		{
+			name:     "call without result",
+			calls:    calls,
+			contents: []any{resultB},
+			want:     []any{notRunA, resultB},
+		},
+		{
+			name:  "calls without IDs matched by name",
+			calls: []FunctionCall{{Name: "kubectl"}, {Name: "bash"}, {Name: "kubectl"}},
+			contents: []any{
+				FunctionCallResult{Name: "bash", Result: map[string]any{"n": 1}},
+				FunctionCallResult{Name: "kubectl", Result: map[string]any{"n": 2}},
+				FunctionCallResult{Name: "kubectl", Result: map[string]any{"n": 3}},
+			},
+			want: []any{
+				FunctionCallResult{Name: "kubectl", Result: map[string]any{"n": 2}},
+				FunctionCallResult{Name: "bash", Result: map[string]any{"n": 1}},
+				FunctionCallResult{Name: "kubectl", Result: map[string]any{"n": 3}},
+			},
+		},
+		{
+			name:     "result of an unknown call",
+			calls:    calls,
+			contents: []any{resultA, resultB, FunctionCallResult{ID: "c", Name: "kubectl"}},
+			wantErr:  true,
+		},
+		{
+			name:     "duplicate result",
+			calls:    calls,
+			contents: []any{resultA, resultB, resultA},
+			wantErr:  true,
+		},
+		{
+			name:     "no call",
+			contents: []any{"hello", resultA},
+			want:     []any{"hello", resultA},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := orderFunctionCallResults(tt.calls, tt.contents)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("orderFunctionCallResults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderFunctionCallResults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParallelFunctionCalls checks that the providers reply to a response of
+// the model with two function calls with one result per call, in the order of
+// the calls and before the text of the user, when the agent sends the result
+// of the second call only, after the text.
+func TestParallelFunctionCalls(t *testing.T) {
+	contents := []any{
+		"also check the nodes",
+		FunctionCallResult{ID: "b", Name: "bash", Result: map[string]any{"stdout": "nodes"}},
+	}
+	want := []string{"a", "b", "text"}
+
+	tests := []struct {
+		provider string
+		// reply adds the contents to a history ending with a response of the
+		// model calling kubectl (ID "a") then bash (ID "b"), and returns the
+		// sequence of the added tool results (their ID) and texts.
+		reply func(t *testing.T) ([]string, error)
+	}{
+		{
+			provider: "openai",
+			reply: func(t *testing.T) ([]string, error) {
+				cs := &openAIChatSession{history: []openai.ChatCompletionMessageParamUnion{openAIToolCalls()}}
+				err := cs.addContentsToHistory(contents)
+				return openAISequence(cs.history[1:]), err
+			},
+		},
+		{
+			provider: "grok",
+			reply: func(t *testing.T) ([]string, error) {
+				cs := &grokChatSession{history: []openai.ChatCompletionMessageParamUnion{openAIToolCalls()}}
+				err := cs.addContentsToHistory(contents)
+				return openAISequence(cs.history[1:]), err
+			},
+		},
+		{
+			provider: "gemini",
+			reply: func(t *testing.T) ([]string, error) {
+				history := []*genai.Content{{Role: "model", Parts: []*genai.Part{
+					{FunctionCall: &genai.FunctionCall{ID: "a", Name: "kubectl"}},
+					{FunctionCall: &genai.FunctionCall{ID: "b", Name: "bash"}},
+				}}}
+				ordered, err := orderFunctionCallResults(geminiPendingCalls(history), contents)
+				if err != nil {
+					return nil, err
+				}
+				parts, err := (&GeminiChat{}).partsToGemini(ordered...)
+				var sequence []string
+				for _, part := range parts {
+					if part.FunctionResponse != nil {
+						sequence = append(sequence, part.FunctionResponse.ID)
+					} else {
+						sequence = append(sequence, "text")
+					}
+				}
+				return sequence, err
+			},
+		},
+		{
+			provider: "bedrock",
+			reply: func(t *testing.T) ([]string, error) {
+				c := &bedrockChat{messages: []types.Message{{
+					Role: types.ConversationRoleAssistant,
+					Content: []types.ContentBlock{
+						&types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{ToolUseId: aws.String("a"), Name: aws.String("kubectl")}},
+						&types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{ToolUseId: aws.String("b"), Name: aws.String("bash")}},
+					},
+				}}}
+				err := c.addContentsToHistory(contents)
+				var sequence []string
+				for _, block := range c.messages[len(c.messages)-1].Content {
+					if result, ok := block.(*types.ContentBlockMemberToolResult); ok {
+						sequence = append(sequence, aws.ToString(result.Value.ToolUseId))
+					} else {
+						sequence = append(sequence, "text")
+					}
+				}
+				return sequence, err
+			},
+		},
+		{
+			provider: "cohere",
+			reply: func(t *testing.T) ([]string, error) {
+				c := &cohereChat{history: []cohereMessage{{Role: "assistant", ToolCalls: []cohereToolCall{
+					{ID: "a", Function: cohereFunctionCall{Name: "kubectl"}},
+					{ID: "b", Function: cohereFunctionCall{Name: "bash"}},
+				}}}}
+				err := c.addContents(contents)
+				var sequence []string
+				for _, message := range c.history[1:] {
+					if message.Role == "tool" {
+						sequence = append(sequence, message.ToolCallID)
+					} else {
+						sequence = append(sequence, "text")
+					}
+				}
+				return sequence, err
+			},
+		},
+		{
+			provider: "watsonx",
+			reply: func(t *testing.T) ([]string, error) {
+				c := &watsonxChat{history: []watsonxMessage{{Role: "assistant", ToolCalls: []watsonxToolCall{
+					{ID: "a", Function: watsonxFunctionCall{Name: "kubectl"}},
+					{ID: "b", Function: watsonxFunctionCall{Name: "bash"}},
+				}}}}
+				err := c.addContents(contents)
+				var sequence []string
+				for _, message := range c.history[1:] {
+					if message.Role == "tool" {
+						sequence = append(sequence, message.ToolCallID)
+					} else {
+						sequence = append(sequence, "text")
+					}
+				}
+				return sequence, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			got, err := tt.reply(t)
+			if err != nil {
+				t.Fatalf("reply error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("reply = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// openAIToolCalls returns an assistant message calling kubectl then bash.
+func openAIToolCalls() openai.ChatCompletionMessageParamUnion {
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+		ToolCalls: []openai.ChatCompletionMessageToolCallParam{
+			{ID: "a", Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "kubectl"}},
+			{ID: "b", Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "bash"}},
+		},
+	}}
+}
+
+func openAISequence(messages []openai.ChatCompletionMessageParamUnion) []string {
+	var sequence []string
+	for _, message := range messages {
+		if message.OfTool != nil {
+			sequence = append(sequence, message.OfTool.ToolCallID)
+		} else {
+			sequence = append(sequence, "text")
+		}
+	}
+	return sequence
+}
@@ -0,0 +1,609 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	cohereDefaultBaseURL = "https://api.cohere.com"
+	cohereDefaultModel   = "command-a-03-2025"
+)
+
+func init() {
+	if err := RegisterProvider("cohere", cohereFactory); err != nil {
+		klog.Fatalf("Failed to register cohere provider: %v", err)
+	}
+}
+
+// cohereFactory is the provider factory function for Cohere.
+func cohereFactory(ctx context.Context, opts ClientOptions) (Client, error) {
+	return NewCohereClient(ctx, opts)
+}
+
+// CohereClient implements the gollm.Client interface for the Command models
+// of Cohere, with the v2 Chat API.
+type CohereClient struct {
+	baseURL        *url.URL
+	apiKey         string
+	httpClient     *http.Client
+	responseSchema *Schema
+	// maxOutputTokens caps the tokens of each response, if positive.
+	maxOutputTokens int
+}
+
+var _ Client = &CohereClient{}
+
+// NewCohereClient creates a new client for Cohere.
+// The API key is read from the OS keychain, or else from COHERE_API_KEY or
+// CO_API_KEY (the variable of the Cohere SDKs). COHERE_BASE_URL overrides the
+// URL of the API, e.g. for a private deployment.
+func NewCohereClient(ctx context.Context, opts ClientOptions) (*CohereClient, error) {
+	apiKey := providerAPIKey("cohere")
+	if apiKey == "" {
+		apiKey = os.Getenv("CO_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, errors.New("Cohere API key not found. Set via COHERE_API_KEY env var or `kubectl-ai auth login cohere`")
+	}
+
+	host := os.Getenv("COHERE_BASE_URL")
+	if host == "" {
+		host = cohereDefaultBaseURL
+	}
+	baseURL, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("parsing COHERE_BASE_URL %q: %w", host, err)
+	}
+
+	return &CohereClient{
+		baseURL:         baseURL,
+		apiKey:          apiKey,
+		httpClient:      createCustomHTTPClient(opts),
+		maxOutputTokens: opts.MaxOutputTokens,
+	}, nil
+}
+
+func (c *CohereClient) Close() error {
+	return nil
+}
+
+// newRequest builds a request to the API, with a JSON body if req is not nil.
+func (c *CohereClient) newRequest(ctx context.Context, method, relativePath string, req any) (*http.Request, error) {
+	var body []byte
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("building json body: %w", err)
+		}
+		body = b
+	}
+	u := c.baseURL.JoinPath(relativePath)
+	if PromptContentLogged(ctx) {
+		klog.V(2).Infof("sending %s request to %v: %s", method, u.String(), string(body))
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("building http request: %w", err)
+	}
+	httpRequest.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("X-Client-Name", "kubectl-ai")
+	return httpRequest, nil
+}
+
+// do sends a request and decodes the JSON response.
+func (c *CohereClient) do(ctx context.Context, method, relativePath string, req any, response any) error {
+	httpRequest, err := c.newRequest(ctx, method, relativePath, req)
+	if err != nil {
+		return err
+	}
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("performing http request: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return newHTTPStatusError(httpResponse)
+	}
+	if err := json.NewDecoder(httpResponse.Body).Decode(response); err != nil {
+		return fmt.Errorf("unmarshalling json response: %w", err)
+	}
+	return nil
+}
+
+func (c *CohereClient) GenerateCompletion(ctx context.Context, request *CompletionRequest) (CompletionResponse, error) {
+	req := &cohereChatRequest{
+		Model:    getCohereModel(request.Model),
+		Messages: []cohereMessage{{Role: "user", Content: request.Prompt}},
+	}
+	if c.responseSchema != nil {
+		req.ResponseFormat = &cohereResponseFormat{Type: "json_object", JSONSchema: c.responseSchema}
+	}
+
+	resp := &cohereChatResponse{}
+	if err := c.do(ctx, http.MethodPost, "v2/chat", req, resp); err != nil {
+		return nil, fmt.Errorf("cohere chat failed: %w", err)
+	}
+	return &simpleCompletionResponse{content: resp.Message.text()}, nil
+}
+
+func (c *CohereClient) SetResponseSchema(responseSchema *Schema) error {
+	c.responseSchema = responseSchema
+	return nil
+}
+
+func (c *CohereClient) ListModels(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := c.do(ctx, http.MethodGet, "v1/models?endpoint=chat&page_size=1000", nil, &resp); err != nil {
+		return nil, fmt.Errorf("listing cohere models: %w", err)
+	}
+	var models []string
+	for _, model := range resp.Models {
+		models = append(models, model.Name)
+	}
+	return models, nil
+}
+
+func (c *CohereClient) StartChat(systemPrompt, model string) Chat {
+	chat := &cohereChat{
+		client: c,
+		model:  getCohereModel(model),
+	}
+	if systemPrompt != "" {
+		chat.history = append(chat.history, cohereMessage{Role: "system", Content: systemPrompt})
+	}
+	return chat
+}
+
+// getCohereModel returns the model to use, from the flag, COHERE_MODEL or the default.
+func getCohereModel(model string) string {
+	if model != "" {
+		return model
+	}
+	if model := os.Getenv("COHERE_MODEL"); model != "" {
+		return model
+	}
+	return cohereDefaultModel
+}
+
+type cohereChat struct {
+	client  *CohereClient
+	model   string
+	history []cohereMessage
+	tools   []cohereTool
+}
+
+var _ Chat = &cohereChat{}
+
+func (c *cohereChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
+	c.tools = nil
+	for _, fnDef := range functionDefinitions {
+		c.tools = append(c.tools, cohereTool{
+			Type: "function",
+			Function: cohereFunction{
+				Name:        fnDef.Name,
+				Description: fnDef.Description,
+				Parameters:  fnDef.Parameters,
+			},
+		})
+	}
+	return nil
+}
+
+// coherePendingCalls returns the tool calls of the last message of the history,
+// if it is a response of the model.
+func coherePendingCalls(history []cohereMessage) []FunctionCall {
+	if len(history) == 0 || history[len(history)-1].Role != "assistant" {
+		return nil
+	}
+	var calls []FunctionCall
+	for _, toolCall := range history[len(history)-1].ToolCalls {
+		calls = append(calls, FunctionCall{ID: toolCall.ID, Name: toolCall.Function.Name})
+	}
+	return calls
+}
+
+// addContents appends the user messages and the results of tool calls to the history.
+func (c *cohereChat) addContents(contents []any) error {
+	// Each tool call of the last response must be answered, in order.
+	contents, err := orderFunctionCallResults(coherePendingCalls(c.history), contents)
+	if err != nil {
+		return err
+	}
+	for _, content := range contents {
+		switch v := content.(type) {
+		case string:
+			c.history = append(c.history, cohereMessage{Role: "user", Content: v})
+		case FunctionCallResult:
+			resultJSON, err := json.Marshal(v.Result)
+			if err != nil {
+				return fmt.Errorf("marshalling function call result: %w", err)
+			}
+			c.history = append(c.history, cohereMessage{Role: "tool", Content: string(resultJSON), ToolCallID: v.ID})
+		default:
+			return fmt.Errorf("unsupported content type: %T", v)
+		}
+	}
+	return nil
+}
+
+func (c *cohereChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	if err := c.addContents(contents); err != nil {
+		return nil, err
+	}
+
+	req := &cohereChatRequest{
+		Model:     c.model,
+		Messages:  c.history,
+		Tools:     c.tools,
+		MaxTokens: c.client.maxOutputTokens,
+	}
+	resp := &cohereChatResponse{}
+	if err := c.client.do(ctx, http.MethodPost, "v2/chat", req, resp); err != nil {
+		return nil, fmt.Errorf("cohere chat failed: %w", err)
+	}
+	if PromptContentLogged(ctx) {
+		klog.V(2).Infof("received response from cohere: %+v", resp)
+	}
+
+	c.history = append(c.history, cohereMessage{
+		Role:      "assistant",
+		Content:   resp.Message.text(),
+		ToolCalls: resp.Message.ToolCalls,
+		ToolPlan:  resp.Message.ToolPlan,
+	})
+
+	functionCalls, err := cohereFunctionCalls(resp.Message.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	return &cohereResponse{
+		raw:          resp,
+		text:         resp.Message.text(),
+		calls:        functionCalls,
+		finishReason: resp.FinishReason,
+		usage:        resp.Usage.toUsage(),
+	}, nil
+}
+
+func (c *cohereChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	if err := c.addContents(contents); err != nil {
+		return nil, err
+	}
+
+	req := &cohereChatRequest{
+		Model:     c.model,
+		Messages:  c.history,
+		Tools:     c.tools,
+		Stream:    true,
+		MaxTokens: c.client.maxOutputTokens,
+	}
+	httpRequest, err := c.client.newRequest(ctx, http.MethodPost, "v2/chat", req)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Accept", "text/event-stream")
+	httpResponse, err := c.client.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("performing http request: %w", err)
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		defer httpResponse.Body.Close()
+		return nil, fmt.Errorf("cohere chat failed: %w", newHTTPStatusError(httpResponse))
+	}
+
+	return func(yield func(ChatResponse, error) bool) {
+		defer httpResponse.Body.Close()
+
+		var text, toolPlan strings.Builder
+		var toolCalls []cohereToolCall
+		defer func() {
+			// The history gets what was received, even if the stream was interrupted.
+			if text.Len() == 0 && len(toolCalls) == 0 {
+				return
+			}
+			c.history = append(c.history, cohereMessage{
+				Role:      "assistant",
+				Content:   text.String(),
+				ToolCalls: toolCalls,
+				ToolPlan:  toolPlan.String(),
+			})
+		}()
+
+		for data, err := range serverSentEvents(httpResponse.Body) {
+			if err != nil {
+				yield(nil, fmt.Errorf("cohere streaming error: %w", err))
+				return
+			}
+			var event cohereStreamEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				yield(nil, fmt.Errorf("parsing cohere stream event: %w", err))
+				return
+			}
+
+			response := &cohereResponse{raw: &event}
+			switch event.Type {
+			case "content-delta":
+				response.text = event.Delta.Message.Content.Text
+				text.WriteString(response.text)
+			case "tool-plan-delta":
+				toolPlan.WriteString(event.Delta.Message.ToolPlan)
+				continue
+			case "tool-call-start":
+				toolCalls = append(toolCalls, event.Delta.Message.ToolCalls)
+				continue
+			case "tool-call-delta":
+				if n := len(toolCalls); n > 0 {
+					toolCalls[n-1].Function.Arguments += event.Delta.Message.ToolCalls.Function.Arguments
+				}
+				continue
+			case "tool-call-end":
+				if len(toolCalls) == 0 {
+					continue
+				}
+				calls, err := cohereFunctionCalls(toolCalls[len(toolCalls)-1:])
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				response.calls = calls
+			case "message-end":
+				response.finishReason = event.Delta.FinishReason
+				response.usage = event.Delta.Usage.toUsage()
+			default:
+				continue
+			}
+			if !yield(response, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+func (c *cohereChat) IsRetryableError(err error) bool {
+	return DefaultIsRetryableError(err)
+}
+
+func (c *cohereChat) Initialize(messages []*Message) error {
+	klog.Warning("chat history persistence is not supported for provider 'cohere', using in-memory chat history")
+	return nil
+}
+
+// cohereFunctionCalls converts the tool calls of the model.
+func cohereFunctionCalls(toolCalls []cohereToolCall) ([]FunctionCall, error) {
+	var calls []FunctionCall
+	for _, toolCall := range toolCalls {
+		call := FunctionCall{ID: toolCall.ID, Name: toolCall.Function.Name, Arguments: map[string]any{}}
+		if toolCall.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &call.Arguments); err != nil {
+				return nil, fmt.Errorf("parsing function call arguments: %w", err)
+			}
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// cohereResponse is a response of the model, or a chunk of it when streaming.
+type cohereResponse struct {
+	raw          any
+	text         string
+	calls        []FunctionCall
+	finishReason string
+	usage        *Usage
+}
+
+var _ ChatResponse = &cohereResponse{}
+
+func (r *cohereResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&RecordChatResponse{Raw: r.raw})
+}
+
+func (r *cohereResponse) UsageMetadata() any {
+	if r.usage == nil {
+		return nil
+	}
+	return r.usage
+}
+
+// TokenUsage returns the tokens used by the response, reported by the last chunk when streaming.
+func (r *cohereResponse) TokenUsage() *Usage {
+	return r.usage
+}
+
+func (r *cohereResponse) Candidates() []Candidate {
+	return []Candidate{&cohereCandidate{response: r}}
+}
+
+type cohereCandidate struct {
+	response *cohereResponse
+}
+
+func (c *cohereCandidate) String() string {
+	return fmt.Sprintf("cohereCandidate{text=%q, calls=%v}", c.response.text, c.response.calls)
+}
+
+func (c *cohereCandidate) Parts() []Part {
+	var parts []Part
+	if c.response.text != "" {
+		parts = append(parts, &cohereTextPart{text: c.response.text})
+	}
+	if len(c.response.calls) > 0 {
+		parts = append(parts, &cohereFunctionCallsPart{calls: c.response.calls})
+	}
+	return parts
+}
+
+// FinishReason returns why the model stopped generating the candidate.
+func (c *cohereCandidate) FinishReason() FinishReason {
+	switch c.response.finishReason {
+	case "":
+		return FinishReasonUnknown
+	case "COMPLETE", "STOP_SEQUENCE", "TOOL_CALL":
+		return FinishReasonStop
+	case "MAX_TOKENS":
+		return FinishReasonMaxTokens
+	default:
+		return FinishReasonOther
+	}
+}
+
+type cohereTextPart struct {
+	text string
+}
+
+func (p *cohereTextPart) AsText() (string, bool) {
+	return p.text, true
+}
+
+func (p *cohereTextPart) AsFunctionCalls() ([]FunctionCall, bool) {
+	return nil, false
+}
+
+type cohereFunctionCallsPart struct {
+	calls []FunctionCall
+}
+
+func (p *cohereFunctionCallsPart) AsText() (string, bool) {
+	return "", false
+}
+
+func (p *cohereFunctionCallsPart) AsFunctionCalls() ([]FunctionCall, bool) {
+	return p.calls, true
+}
+
+// See https://docs.cohere.com/reference/chat
+
+type cohereChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []cohereMessage       `json:"messages"`
+	Tools          []cohereTool          `json:"tools,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	ResponseFormat *cohereResponseFormat `json:"response_format,omitempty"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+}
+
+type cohereResponseFormat struct {
+	Type       string  `json:"type"`
+	JSONSchema *Schema `json:"json_schema,omitempty"`
+}
+
+type cohereMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content,omitempty"`
+	ToolCalls  []cohereToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	ToolPlan   string           `json:"tool_plan,omitempty"`
+}
+
+type cohereTool struct {
+	Type     string         `json:"type"`
+	Function cohereFunction `json:"function"`
+}
+
+type cohereFunction struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Parameters  *Schema `json:"parameters,omitempty"`
+}
+
+type cohereToolCall struct {
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function cohereFunctionCall `json:"function"`
+}
+
+type cohereFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+type cohereChatResponse struct {
+	ID           string                `json:"id"`
+	FinishReason string                `json:"finish_reason"`
+	Message      cohereResponseMessage `json:"message"`
+	Usage        *cohereUsage          `json:"usage,omitempty"`
+}
+
+type cohereResponseMessage struct {
+	Role      string           `json:"role"`
+	Content   []cohereContent  `json:"content,omitempty"`
+	ToolCalls []cohereToolCall `json:"tool_calls,omitempty"`
+	ToolPlan  string           `json:"tool_plan,omitempty"`
+}
+
+// text returns the text of the content of the message.
+func (m *cohereResponseMessage) text() string {
+	var sb strings.Builder
+	for _, content := range m.Content {
+		if content.Type == "text" {
+			sb.WriteString(content.Text)
+		}
+	}
+	return sb.String()
+}
+
+type cohereContent struct {
+	Type string `json:"type,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+type cohereUsage struct {
+	Tokens struct {
+		InputTokens  float64 `json:"input_tokens"`
+		OutputTokens float64 `json:"output_tokens"`
+	} `json:"tokens"`
+}
+
+func (u *cohereUsage) toUsage() *Usage {
+	if u == nil {
+		return nil
+	}
+	input, output := int(u.Tokens.InputTokens), int(u.Tokens.OutputTokens)
+	return &Usage{InputTokens: input, OutputTokens: output, TotalTokens: input + output}
+}
+
+// cohereStreamEvent is an event of a streamed response, see
+// https://docs.cohere.com/reference/chat-stream
+type cohereStreamEvent struct {
+	Type  string `json:"type"`
+	Index int    `json:"index,omitempty"`
+	Delta struct {
+		Message struct {
+			Content   cohereContent  `json:"content,omitempty"`
+			ToolPlan  string         `json:"tool_plan,omitempty"`
+			ToolCalls cohereToolCall `json:"tool_calls,omitempty"`
+		} `json:"message,omitempty"`
+		FinishReason string       `json:"finish_reason,omitempty"`
+		Usage        *cohereUsage `json:"usage,omitempty"`
+	} `json:"delta,omitempty"`
+}
@@ -0,0 +1,221 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// newTestCohereChat returns a chat with a fake Cohere API answering with the
+// response, and the requests received by the API.
+func newTestCohereChat(t *testing.T, opts ClientOptions, response string) (Chat, *[]cohereChatRequest) {
+	t.Helper()
+	var requests []cohereChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization header = %q, want the API key", got)
+		}
+		var req cohereChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req)
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	keyring.MockInit()
+	t.Setenv("COHERE_API_KEY", "test-key")
+	t.Setenv("COHERE_BASE_URL", server.URL)
+	client, err := NewCohereClient(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("NewCohereClient() error: %v", err)
+	}
+	chat := client.StartChat("You are a Kubernetes assistant.", "command-r")
+	if err := chat.SetFunctionDefinitions([]*FunctionDefinition{{Name: "kubectl", Description: "Runs kubectl"}}); err != nil {
+		t.Fatalf("SetFunctionDefinitions() error: %v", err)
+	}
+	return chat, &requests
+}
+
+func TestCohereSend(t *testing.T) {
+	chat, requests := newTestCohereChat(t, ClientOptions{MaxOutputTokens: 1024}, `{
+		"id": "1",
+		"finish_reason": "TOOL_CALL",
+		"message": {
+			"role": "assistant",
+			"tool_plan": "I will list the pods.",
+			"tool_calls": [{"id": "call-1", "type": "function", "function": {"name": "kubectl", "arguments": "{\"command\":\"kubectl get pods\"}"}}]
+		},
+		"usage": {"tokens": {"input_tokens": 100, "output_tokens": 20}}
+	}`)
+
+	resp, err := chat.Send(context.Background(), "list the pods")
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	candidate := resp.Candidates()[0]
+	calls, ok := candidate.Parts()[0].AsFunctionCalls()
+	if !ok {
+		t.Fatalf("Send() returned no function calls: %v", candidate)
+	}
+	wantCalls := []FunctionCall{{ID: "call-1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}}}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("function calls = %+v, want %+v", calls, wantCalls)
+	}
+	if got := CandidateFinishReason(candidate); got != FinishReasonStop {
+		t.Errorf("finish reason = %q, want %q", got, FinishReasonStop)
+	}
+	if got, want := ResponseUsage(resp), (&Usage{InputTokens: 100, OutputTokens: 20, TotalTokens: 120}); !reflect.DeepEqual(got, want) {
+		t.Errorf("usage = %+v, want %+v", got, want)
+	}
+
+	if _, err := chat.Send(context.Background(), FunctionCallResult{ID: "call-1", Name: "kubectl", Result: map[string]any{"stdout": "web-1"}}); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	wantMessages := []cohereMessage{
+		{Role: "system", Content: "You are a Kubernetes assistant."},
+		{Role: "user", Content: "list the pods"},
+		{Role: "assistant", ToolPlan: "I will list the pods.", ToolCalls: []cohereToolCall{{ID: "call-1", Type: "function", Function: cohereFunctionCall{Name: "kubectl", Arguments: `{"command":"kubectl get pods"}`}}}},
+		{Role: "tool", Content: `{"stdout":"web-1"}`, ToolCallID: "call-1"},
+	}
+	if got := (*requests)[1].Messages; !reflect.DeepEqual(got, wantMessages) {
+		t.Errorf("messages of the second request = %+v, want %+v", got, wantMessages)
+	}
+	if got := (*requests)[1].Model; got != "command-r" {
+		t.Errorf("model = %q, want command-r", got)
+	}
+	if got := (*requests)[1].MaxTokens; got != 1024 {
+		t.Errorf("max_tokens = %d, want 1024", got)
+	}
+}
+
+func TestCohereSendStreaming(t *testing.T) {
+	chat, requests := newTestCohereChat(t, ClientOptions{}, `event: message-start
+data: {"type":"message-start","delta":{"message":{"role":"assistant"}}}
+
+event: content-delta
+data: {"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"Listing "}}}}
+
+event: content-delta
+data: {"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"pods."}}}}
+
+event: tool-call-start
+data: {"type":"tool-call-start","index":0,"delta":{"message":{"tool_calls":{"id":"call-1","type":"function","function":{"name":"kubectl","arguments":""}}}}}
+
+event: tool-call-delta
+data: {"type":"tool-call-delta","index":0,"delta":{"message":{"tool_calls":{"function":{"arguments":"{\"command\":"}}}}}
+
+event: tool-call-delta
+data: {"type":"tool-call-delta","index":0,"delta":{"message":{"tool_calls":{"function":{"arguments":"\"kubectl get pods\"}"}}}}}
+
+event: tool-call-end
+data: {"type":"tool-call-end","index":0}
+
+event: message-end
+data: {"type":"message-end","delta":{"finish_reason":"TOOL_CALL","usage":{"tokens":{"input_tokens":10,"output_tokens":5}}}}
+
+`)
+
+	iterator, err := chat.SendStreaming(context.Background(), "list the pods")
+	if err != nil {
+		t.Fatalf("SendStreaming() error: %v", err)
+	}
+	var text string
+	var calls []FunctionCall
+	var finishReason FinishReason
+	var usage *Usage
+	for resp, err := range iterator {
+		if err != nil {
+			t.Fatalf("streaming error: %v", err)
+		}
+		for _, part := range resp.Candidates()[0].Parts() {
+			if s, ok := part.AsText(); ok {
+				text += s
+			}
+			if c, ok := part.AsFunctionCalls(); ok {
+				calls = append(calls, c...)
+			}
+		}
+		if reason := CandidateFinishReason(resp.Candidates()[0]); reason != FinishReasonUnknown {
+			finishReason = reason
+		}
+		if u := ResponseUsage(resp); u != nil {
+			usage = u
+		}
+	}
+
+	if text != "Listing pods." {
+		t.Errorf("text = %q, want %q", text, "Listing pods.")
+	}
+	wantCalls := []FunctionCall{{ID: "call-1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}}}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("function calls = %+v, want %+v", calls, wantCalls)
+	}
+	if finishReason != FinishReasonStop {
+		t.Errorf("finish reason = %q, want %q", finishReason, FinishReasonStop)
+	}
+	if want := (&Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}); !reflect.DeepEqual(usage, want) {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+	if !(*requests)[0].Stream {
+		t.Errorf("request is not streamed")
+	}
+
+	history := chat.(*cohereChat).history
+	wantLast := cohereMessage{Role: "assistant", Content: "Listing pods.", ToolCalls: []cohereToolCall{{ID: "call-1", Type: "function", Function: cohereFunctionCall{Name: "kubectl", Arguments: `{"command":"kubectl get pods"}`}}}}
+	if got := history[len(history)-1]; !reflect.DeepEqual(got, wantLast) {
+		t.Errorf("last message of the history = %+v, want %+v", got, wantLast)
+	}
+}
+
+func TestCohereErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"You are using a Trial key, which is limited to 10 API calls / minute."}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	keyring.MockInit()
+	t.Setenv("COHERE_API_KEY", "test-key")
+	t.Setenv("COHERE_BASE_URL", server.URL)
+	client, err := NewCohereClient(context.Background(), ClientOptions{})
+	if err != nil {
+		t.Fatalf("NewCohereClient() error: %v", err)
+	}
+	chat := client.StartChat("", "")
+	_, err = chat.Send(context.Background(), "hello")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Send() error = %v, want an APIError with status 429", err)
+	}
+	if !chat.IsRetryableError(err) {
+		t.Errorf("IsRetryableError(%v) = false, want true", err)
+	}
+
+	t.Setenv("COHERE_API_KEY", "")
+	t.Setenv("CO_API_KEY", "")
+	if _, err := NewCohereClient(context.Background(), ClientOptions{}); err == nil {
+		t.Errorf("NewCohereClient() without an API key: want error")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// The stable API of the module, see "Versioning" in the README. Changing
+// these declarations is a breaking change, which needs a new major version.
+type (
+	stableClient interface {
+		io.Closer
+		StartChat(systemPrompt, model string) Chat
+		GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error)
+		SetResponseSchema(schema *Schema) error
+		ListModels(ctx context.Context) ([]string, error)
+	}
+	stableChat interface {
+		Send(ctx context.Context, contents ...any) (ChatResponse, error)
+		SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error)
+		SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error
+		IsRetryableError(error) bool
+		Initialize(messages []*Message) error
+	}
+	stableChatResponse interface {
+		UsageMetadata() any
+		Candidates() []Candidate
+	}
+	stableCandidate interface {
+		fmt.Stringer
+		Parts() []Part
+	}
+	stablePart interface {
+		AsText() (string, bool)
+		AsFunctionCalls() ([]FunctionCall, bool)
+	}
+)
+
+// Interfaces are assigned both ways: a method added to them would break the
+// implementations outside of the module.
+var (
+	_ Client       = stableClient(nil)
+	_ stableClient = Client(nil)
+	_ Chat         = stableChat(nil)
+	_ stableChat   = Chat(nil)
+
+	_ ChatResponse       = stableChatResponse(nil)
+	_ stableChatResponse = ChatResponse(nil)
+	_ Candidate          = stableCandidate(nil)
+	_ stableCandidate    = Candidate(nil)
+	_ Part               = stablePart(nil)
+	_ stablePart         = Part(nil)
+
+	_ func(ctx context.Context, providerID string, opts ...Option) (Client, error) = NewClient
+	_ func(id string, factoryFunc FactoryFunc) error                               = RegisterProvider
+	_ func(err error) bool                                                         = IsToolsNotSupported
+	_ error                                                                        = ErrToolsNotSupported
+
+	_ = FunctionCall{ID: "", Name: "", Arguments: map[string]any{}}
+	_ = FunctionCallResult{ID: "", Name: "", Result: map[string]any{}}
+	_ = FunctionDefinition{Name: "", Description: "", Parameters: &Schema{}}
+	_ = Message{Role: RoleUser, Content: ""}
+)
+
+// TestInitialize checks that the chats of every provider accept the history
+// of a previous conversation.
+func TestInitialize(t *testing.T) {
+	history := []*Message{
+		{Role: RoleUser, Content: "why is nginx down?"},
+		{Role: RoleModel, Content: "The image tag doesn't exist."},
+		{Role: "tool", Content: "skipped"},
+	}
+
+	tests := []struct {
+		provider string
+		chat     Chat
+		// restored returns the roles and texts of the history of the chat,
+		// for the providers that restore it.
+		restored func(chat Chat) []string
+	}{
+		{
+			provider: "gemini",
+			chat:     &GeminiChat{},
+			restored: func(chat Chat) []string {
+				var got []string
+				for _, content := range chat.(*GeminiChat).history {
+					got = append(got, content.Role+": "+content.Parts[0].Text)
+				}
+				return got
+			},
+		},
+		{
+			provider: "bedrock",
+			chat:     &bedrockChat{},
+			restored: func(chat Chat) []string {
+				var got []string
+				for _, message := range chat.(*bedrockChat).messages {
+					got = append(got, string(message.Role)+": "+message.Content[0].(*types.ContentBlockMemberText).Value)
+				}
+				return got
+			},
+		},
+		{provider: "openai", chat: &openAIChatSession{}},
+		{provider: "azopenai", chat: &AzureOpenAIChat{}},
+		{provider: "grok", chat: &grokChatSession{}},
+		{provider: "ollama", chat: &OllamaChat{}},
+		{provider: "llamacpp", chat: &LlamaCppChat{}},
+		{provider: "cohere", chat: &cohereChat{}},
+		{provider: "watsonx", chat: &watsonxChat{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			if err := tt.chat.Initialize(history); err != nil {
+				t.Fatalf("Initialize() error = %v", err)
+			}
+			if tt.restored == nil {
+				return
+			}
+			want := []string{"user: why is nginx down?", "model: The image tag doesn't exist."}
+			if tt.provider == "bedrock" {
+				want = []string{"user: why is nginx down?", "assistant: The image tag doesn't exist."}
+			}
+			if got := tt.restored(tt.chat); !reflect.DeepEqual(got, want) {
+				t.Errorf("restored history = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,799 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	ollama "github.com/ollama/ollama/api"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"google.golang.org/genai"
+)
+
+// The conformance suite checks the behaviors of chats the agent relies on,
+// against a fake API answering with responses in the wire format of each
+// provider, as recorded from the real APIs. New providers must be added to
+// conformanceProviders, and pass every behavior they don't declare as
+// unsupported.
+
+// Behaviors of the conformance suite.
+const (
+	behaviorText               = "text"
+	behaviorStreamingText      = "streaming text"
+	behaviorToolCalls          = "multiple tool calls"
+	behaviorStreamingToolCalls = "streaming multiple tool calls"
+	behaviorFunctionResults    = "function results"
+	behaviorHistory            = "history initialization"
+	behaviorRetry              = "retry classification"
+)
+
+// fakeResponse is a response of the fake API.
+type fakeResponse struct {
+	status      int
+	contentType string
+	header      http.Header
+	body        []byte
+}
+
+// jsonResponse returns a successful response with the JSON body.
+func jsonResponse(body string) fakeResponse {
+	return fakeResponse{status: http.StatusOK, contentType: "application/json", body: []byte(body)}
+}
+
+// sseResponse returns a stream of server-sent events with the data.
+func sseResponse(data ...string) fakeResponse {
+	var b strings.Builder
+	for _, d := range data {
+		fmt.Fprintf(&b, "data: %s\n\n", d)
+	}
+	return fakeResponse{status: http.StatusOK, contentType: "text/event-stream", body: []byte(b.String())}
+}
+
+// fakeAPI answers the requests with queued responses, in order, and records
+// the bodies of the requests.
+type fakeAPI struct {
+	*httptest.Server
+	t *testing.T
+
+	mu        sync.Mutex
+	responses []fakeResponse
+	requests  []string
+}
+
+func newFakeAPI(t *testing.T, routes map[string]http.HandlerFunc) *fakeAPI {
+	api := &fakeAPI{t: t}
+	mux := http.NewServeMux()
+	for pattern, handler := range routes {
+		mux.HandleFunc(pattern, handler)
+	}
+	mux.HandleFunc("/", api.serve)
+	api.Server = httptest.NewServer(mux)
+	t.Cleanup(api.Close)
+	return api
+}
+
+// tls serves the fake API over TLS, with a certificate trusted by the client
+// of the server.
+func (a *fakeAPI) tls(t *testing.T) {
+	if a.TLS != nil {
+		return
+	}
+	handler := a.Config.Handler
+	a.Close()
+	a.Server = httptest.NewTLSServer(handler)
+	t.Cleanup(a.Close)
+}
+
+// queue adds responses to send, in order.
+func (a *fakeAPI) queue(responses ...fakeResponse) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.responses = append(a.responses, responses...)
+}
+
+// lastRequest returns the body of the last request.
+func (a *fakeAPI) lastRequest() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.requests) == 0 {
+		return ""
+	}
+	return a.requests[len(a.requests)-1]
+}
+
+func (a *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	a.mu.Lock()
+	a.requests = append(a.requests, string(body))
+	if len(a.responses) == 0 {
+		a.mu.Unlock()
+		a.t.Errorf("unexpected request %s %s: %s", r.Method, r.URL, body)
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+		return
+	}
+	response := a.responses[0]
+	a.responses = a.responses[1:]
+	a.mu.Unlock()
+
+	for key, values := range response.header {
+		w.Header()[key] = values
+	}
+	w.Header().Set("Content-Type", response.contentType)
+	w.WriteHeader(response.status)
+	w.Write(response.body)
+}
+
+// conformanceProvider adapts a provider to the conformance suite.
+type conformanceProvider struct {
+	name string
+	// routes are served by the fake API besides the chat, e.g. to issue tokens.
+	routes map[string]http.HandlerFunc
+	// newChat returns a chat with the fake API. Clients must not retry
+	// failed requests themselves.
+	newChat func(t *testing.T, api *fakeAPI) Chat
+	// callIDs is whether the API identifies the tool calls.
+	callIDs bool
+	// text returns a response of the model made of the chunks of text.
+	text func(stream bool, chunks ...string) fakeResponse
+	// toolCalls returns a response of the model calling the functions.
+	toolCalls func(stream bool, calls ...FunctionCall) fakeResponse
+	// error returns an error of the API with the HTTP status.
+	error func(status int) fakeResponse
+	// unsupported are the behaviors the provider doesn't implement yet,
+	// with the reason.
+	unsupported map[string]string
+}
+
+// conformanceCalls are the tool calls of the model in the suite.
+var conformanceCalls = []FunctionCall{
+	{ID: "call-1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}},
+	{ID: "call-2", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get nodes"}},
+}
+
+// conformanceBehaviors are the behaviors checked for every provider.
+var conformanceBehaviors = []struct {
+	name string
+	run  func(t *testing.T, p conformanceProvider, api *fakeAPI, chat Chat)
+}{
+	{
+		name: behaviorText,
+		run: func(t *testing.T, p conformanceProvider, api *fakeAPI, chat Chat) {
+			api.queue(p.text(false, "The pods are running."))
+			text, calls, err := conformanceSend(chat, false, "list the pods")
+			if err != nil {
+				t.Fatalf("Send() error: %v", err)
+			}
+			if text != "The pods are running." || len(calls) != 0 {
+				t.Errorf("Send() = %q with calls %+v, want the text only", text, calls)
+			}
+		},
+	},
+	{
+		name: behaviorStreamingText,
+		run: func(t *testing.T, p conformanceProvider, api *fakeAPI, chat Chat) {
+			api.queue(p.text(true, "The pods ", "are running."))
+			text, calls, err := conformanceSend(chat, true, "list the pods")
+			if err != nil {
+				t.Fatalf("SendStreaming() error: %v", err)
+			}
+			if text != "The pods are running." || len(calls) != 0 {
+				t.Errorf("SendStreaming() = %q with calls %+v, want the text only", text, calls)
+			}
+		},
+	},
+	{
+		name: behaviorToolCalls,
+		run: func(t *testing.T, p conformanceProvider, api *fakeAPI, chat Chat) {
+			api.queue(p.toolCalls(false, conformanceCalls...))
+			_, calls, err := conformanceSend(chat, false, "list the pods and the nodes")
+			if err != nil {
+				t.Fatalf("Send() error: %v", err)
+			}
+			checkConformanceCalls(t, p, calls)
+		},
+	},
+	{
+		name: behaviorStreamingToolCalls,
+		run: func(t *testing.T, p conformanceProvider, api *fakeAPI, chat Chat) {
+			api.queue(p.toolCalls(true, conformanceCalls...))
+			_, calls, err := conformanceSend(chat, true, "list the pods and the nodes")
+			if err != nil {
+				t.Fatalf("SendStreaming() error: %v", err)
+			}
+			checkConformanceCalls(t, p, calls)
+		},
+	},
+	{
+		name: behaviorFunctionResults,
+		run: func(t *testing.T, p conformanceProvider, api *fakeAPI, chat Chat) {
+			api.queue(p.toolCalls(false, conformanceCalls...), p.text(false, "web-1 runs on node-1."))
+			_, calls, err := conformanceSend(chat, false, "list the pods and the nodes")
+			if err != nil {
+				t.Fatalf("Send() error: %v", err)
+			}
+			if len(calls) != 2 {
+				t.Fatalf("Send() returned %d calls, want 2", len(calls))
+			}
+			// The results are sent in another order than the calls.
+			results := []any{
+				FunctionCallResult{ID: calls[1].ID, Name: calls[1].Name, Result: map[string]any{"stdout": "node/node-1"}},
+				FunctionCallResult{ID: calls[0].ID, Name: calls[0].Name, Result: map[string]any{"stdout": "pod/web-1"}},
+			}
+			text, _, err := conformanceSend(chat, false, results...)
+			if err != nil {
+				t.Fatalf("Send() of the results error: %v", err)
+			}
+			if text != "web-1 runs on node-1." {
+				t.Errorf("Send() of the results = %q, want the answer of the model", text)
+			}
+			request := api.lastRequest()
+			for _, want := range []string{"pod/web-1", "node/node-1"} {
+				if !strings.Contains(request, want) {
+					t.Errorf("request doesn't contain the result %q: %s", want, request)
+				}
+			}
+		},
+	},
+	{
+		name: behaviorHistory,
+		run: func(t *testing.T, p conformanceProvider, api *fakeAPI, chat Chat) {
+			history := []*Message{
+				{Role: RoleUser, Content: "why is nginx down?"},
+				{Role: RoleModel, Content: "The image tag doesn't exist."},
+			}
+			if err := chat.Initialize(history); err != nil {
+				t.Fatalf("Initialize() error: %v", err)
+			}
+			api.queue(p.text(false, "Use nginx:1.27."))
+			if _, _, err := conformanceSend(chat, false, "which tag should I use?"); err != nil {
+				t.Fatalf("Send() error: %v", err)
+			}
+			request := api.lastRequest()
+			last := -1
+			for _, want := range []string{"why is nginx down?", "The image tag doesn't exist.", "which tag should I use?"} {
+				i := strings.Index(request, want)
+				if i <= last {
+					t.Fatalf("request doesn't contain %q after the previous messages: %s", want, request)
+				}
+				last = i
+			}
+		},
+	},
+	{
+		name: behaviorRetry,
+		run: func(t *testing.T, p conformanceProvider, api *fakeAPI, _ Chat) {
+			for _, tc := range []struct {
+				status    int
+				retryable bool
+			}{
+				{status: http.StatusTooManyRequests, retryable: true},
+				{status: http.StatusServiceUnavailable, retryable: true},
+				{status: http.StatusBadRequest, retryable: false},
+				{status: http.StatusUnauthorized, retryable: false},
+			} {
+				for _, stream := range []bool{false, true} {
+					chat := p.newChat(t, api)
+					api.queue(p.error(tc.status))
+					_, _, err := conformanceSend(chat, stream, "list the pods")
+					if err == nil {
+						t.Fatalf("error %d (streaming: %v) was not returned", tc.status, stream)
+					}
+					if got := chat.IsRetryableError(err); got != tc.retryable {
+						t.Errorf("IsRetryableError() of error %d (streaming: %v) = %v, want %v: %v", tc.status, stream, got, tc.retryable, err)
+					}
+				}
+			}
+		},
+	},
+}
+
+// conformanceSend sends the contents, and returns the text and the function
+// calls of the response.
+func conformanceSend(chat Chat, stream bool, contents ...any) (string, []FunctionCall, error) {
+	var text strings.Builder
+	var calls []FunctionCall
+	collect := func(resp ChatResponse) {
+		if resp == nil || len(resp.Candidates()) == 0 {
+			return
+		}
+		for _, part := range resp.Candidates()[0].Parts() {
+			if s, ok := part.AsText(); ok {
+				text.WriteString(s)
+			}
+			if c, ok := part.AsFunctionCalls(); ok {
+				calls = append(calls, c...)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if !stream {
+		resp, err := chat.Send(ctx, contents...)
+		if err != nil {
+			return "", nil, err
+		}
+		collect(resp)
+		return text.String(), calls, nil
+	}
+	iterator, err := chat.SendStreaming(ctx, contents...)
+	if err != nil {
+		return "", nil, err
+	}
+	for resp, err := range iterator {
+		if err != nil {
+			return "", nil, err
+		}
+		collect(resp)
+	}
+	return text.String(), calls, nil
+}
+
+func checkConformanceCalls(t *testing.T, p conformanceProvider, calls []FunctionCall) {
+	t.Helper()
+	want := conformanceCalls
+	if !p.callIDs {
+		want = nil
+		for _, call := range conformanceCalls {
+			call.ID = ""
+			want = append(want, call)
+		}
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("function calls = %+v, want %+v", calls, want)
+	}
+}
+
+func TestProviderConformance(t *testing.T) {
+	for _, p := range conformanceProviders() {
+		t.Run(p.name, func(t *testing.T) {
+			for _, behavior := range conformanceBehaviors {
+				t.Run(behavior.name, func(t *testing.T) {
+					if reason, ok := p.unsupported[behavior.name]; ok {
+						t.Skipf("not supported by %s: %s", p.name, reason)
+					}
+					api := newFakeAPI(t, p.routes)
+					chat := p.newChat(t, api)
+					behavior.run(t, p, api, chat)
+				})
+			}
+		})
+	}
+}
+
+// conformanceDefinitions are the functions declared to the model.
+var conformanceDefinitions = []*FunctionDefinition{{
+	Name:        "kubectl",
+	Description: "Runs a kubectl command",
+	Parameters: &Schema{
+		Type:       TypeObject,
+		Properties: map[string]*Schema{"command": {Type: TypeString, Description: "The kubectl command"}},
+		Required:   []string{"command"},
+	},
+}}
+
+// startConformanceChat starts a chat with the client, declaring the functions.
+func startConformanceChat(t *testing.T, client Client, model string) Chat {
+	t.Helper()
+	chat := client.StartChat("You are a Kubernetes assistant.", model)
+	if err := chat.SetFunctionDefinitions(conformanceDefinitions); err != nil {
+		t.Fatalf("SetFunctionDefinitions() error: %v", err)
+	}
+	return chat
+}
+
+// argumentsJSON returns the arguments of a call in JSON.
+func argumentsJSON(call FunctionCall) string {
+	b, _ := json.Marshal(call.Arguments)
+	return string(b)
+}
+
+// jsonString returns s quoted in JSON.
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// openAIConformance encodes the responses of the chat completions API of
+// OpenAI, also implemented by Grok, watsonx.ai and llama.cpp.
+var openAIConformance = struct {
+	text      func(stream bool, chunks ...string) fakeResponse
+	toolCalls func(stream bool, calls ...FunctionCall) fakeResponse
+	error     func(status int) fakeResponse
+}{
+	text: func(stream bool, chunks ...string) fakeResponse {
+		if !stream {
+			return jsonResponse(fmt.Sprintf(`{"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"gpt-4.1",`+
+				`"choices":[{"index":0,"message":{"role":"assistant","content":%s},"finish_reason":"stop"}],`+
+				`"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`, jsonString(strings.Join(chunks, ""))))
+		}
+		var events []string
+		for _, chunk := range chunks {
+			events = append(events, fmt.Sprintf(`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{"role":"assistant","content":%s}}]}`, jsonString(chunk)))
+		}
+		events = append(events, `{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`, "[DONE]")
+		return sseResponse(events...)
+	},
+	toolCalls: func(stream bool, calls ...FunctionCall) fakeResponse {
+		if !stream {
+			var toolCalls []string
+			for _, call := range calls {
+				toolCalls = append(toolCalls, fmt.Sprintf(`{"id":%q,"type":"function","function":{"name":%q,"arguments":%s}}`, call.ID, call.Name, jsonString(argumentsJSON(call))))
+			}
+			return jsonResponse(fmt.Sprintf(`{"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"gpt-4.1",`+
+				`"choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[%s]},"finish_reason":"tool_calls"}],`+
+				`"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`, strings.Join(toolCalls, ",")))
+		}
+		var events []string
+		for i, call := range calls {
+			// The arguments are streamed in two chunks.
+			arguments := argumentsJSON(call)
+			events = append(events,
+				fmt.Sprintf(`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":%d,"id":%q,"type":"function","function":{"name":%q,"arguments":%s}}]}}]}`, i, call.ID, call.Name, jsonString(arguments[:5])),
+				fmt.Sprintf(`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{"tool_calls":[{"index":%d,"function":{"arguments":%s}}]}}]}`, i, jsonString(arguments[5:])))
+		}
+		events = append(events, `{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`, "[DONE]")
+		return sseResponse(events...)
+	},
+	error: func(status int) fakeResponse {
+		return fakeResponse{
+			status:      status,
+			contentType: "application/json",
+			body:        []byte(fmt.Sprintf(`{"error":{"message":"the request failed with status %d","type":"error","code":null}}`, status)),
+		}
+	},
+}
+
+// withoutDone removes the [DONE] event ending the streams of OpenAI.
+func withoutDone(response fakeResponse) fakeResponse {
+	response.body = bytes.TrimSuffix(response.body, []byte("data: [DONE]\n\n"))
+	return response
+}
+
+// conformanceProviders returns the providers checked by the conformance suite.
+func conformanceProviders() []conformanceProvider {
+	return []conformanceProvider{
+		{
+			name: "openai",
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				client := &OpenAIClient{client: openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(api.URL), option.WithMaxRetries(0))}
+				return startConformanceChat(t, client, "gpt-4.1")
+			},
+			callIDs:     true,
+			text:        openAIConformance.text,
+			toolCalls:   openAIConformance.toolCalls,
+			error:       openAIConformance.error,
+			unsupported: map[string]string{behaviorHistory: "Initialize doesn't restore the history"},
+		},
+		{
+			name: "grok",
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				client := &GrokClient{client: openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(api.URL), option.WithMaxRetries(0))}
+				return startConformanceChat(t, client, "grok-3-beta")
+			},
+			callIDs:     true,
+			text:        openAIConformance.text,
+			toolCalls:   openAIConformance.toolCalls,
+			error:       openAIConformance.error,
+			unsupported: map[string]string{behaviorHistory: "Initialize doesn't restore the history"},
+		},
+		{
+			name: "watsonx",
+			routes: map[string]http.HandlerFunc{
+				"POST /identity/token": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, `{"access_token":"token-1","expiration":%d}`, time.Now().Add(time.Hour).Unix())
+				},
+			},
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				u, err := url.Parse(api.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				client := &WatsonxClient{baseURL: u, iamURL: u, httpClient: http.DefaultClient, apiKey: "test-key", projectID: "project-1"}
+				return startConformanceChat(t, client, "ibm/granite-3-3-8b-instruct")
+			},
+			callIDs: true,
+			// Streams of watsonx.ai end without a [DONE] event.
+			text: func(stream bool, chunks ...string) fakeResponse {
+				return withoutDone(openAIConformance.text(stream, chunks...))
+			},
+			toolCalls: func(stream bool, calls ...FunctionCall) fakeResponse {
+				return withoutDone(openAIConformance.toolCalls(stream, calls...))
+			},
+			error:       openAIConformance.error,
+			unsupported: map[string]string{behaviorHistory: "Initialize doesn't restore the history"},
+		},
+		{
+			name: "cohere",
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				u, err := url.Parse(api.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				client := &CohereClient{baseURL: u, httpClient: http.DefaultClient, apiKey: "test-key"}
+				return startConformanceChat(t, client, "command-r")
+			},
+			callIDs: true,
+			text: func(stream bool, chunks ...string) fakeResponse {
+				if !stream {
+					return jsonResponse(fmt.Sprintf(`{"id":"1","finish_reason":"COMPLETE","message":{"role":"assistant","content":[{"type":"text","text":%s}]},`+
+						`"usage":{"tokens":{"input_tokens":10,"output_tokens":5}}}`, jsonString(strings.Join(chunks, ""))))
+				}
+				events := []string{`{"type":"message-start","delta":{"message":{"role":"assistant"}}}`}
+				for _, chunk := range chunks {
+					events = append(events, fmt.Sprintf(`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":%s}}}}`, jsonString(chunk)))
+				}
+				events = append(events, `{"type":"message-end","delta":{"finish_reason":"COMPLETE","usage":{"tokens":{"input_tokens":10,"output_tokens":5}}}}`)
+				return sseResponse(events...)
+			},
+			toolCalls: func(stream bool, calls ...FunctionCall) fakeResponse {
+				if !stream {
+					var toolCalls []string
+					for _, call := range calls {
+						toolCalls = append(toolCalls, fmt.Sprintf(`{"id":%q,"type":"function","function":{"name":%q,"arguments":%s}}`, call.ID, call.Name, jsonString(argumentsJSON(call))))
+					}
+					return jsonResponse(fmt.Sprintf(`{"id":"1","finish_reason":"TOOL_CALL","message":{"role":"assistant","tool_plan":"I will list them.","tool_calls":[%s]},`+
+						`"usage":{"tokens":{"input_tokens":10,"output_tokens":5}}}`, strings.Join(toolCalls, ",")))
+				}
+				events := []string{`{"type":"message-start","delta":{"message":{"role":"assistant"}}}`}
+				for i, call := range calls {
+					events = append(events,
+						fmt.Sprintf(`{"type":"tool-call-start","index":%d,"delta":{"message":{"tool_calls":{"id":%q,"type":"function","function":{"name":%q,"arguments":""}}}}}`, i, call.ID, call.Name),
+						fmt.Sprintf(`{"type":"tool-call-delta","index":%d,"delta":{"message":{"tool_calls":{"function":{"arguments":%s}}}}}`, i, jsonString(argumentsJSON(call))),
+						fmt.Sprintf(`{"type":"tool-call-end","index":%d}`, i))
+				}
+				events = append(events, `{"type":"message-end","delta":{"finish_reason":"TOOL_CALL","usage":{"tokens":{"input_tokens":10,"output_tokens":5}}}}`)
+				return sseResponse(events...)
+			},
+			error: func(status int) fakeResponse {
+				return fakeResponse{status: status, contentType: "application/json", body: []byte(fmt.Sprintf(`{"message":"the request failed with status %d"}`, status))}
+			},
+			unsupported: map[string]string{behaviorHistory: "Initialize doesn't restore the history"},
+		},
+		{
+			name: "gemini",
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+					APIKey:      "test-key",
+					Backend:     genai.BackendGeminiAPI,
+					HTTPOptions: genai.HTTPOptions{BaseURL: api.URL},
+				})
+				if err != nil {
+					t.Fatalf("genai.NewClient() error: %v", err)
+				}
+				return startConformanceChat(t, &GoogleAIClient{client: client}, "gemini-2.5-pro")
+			},
+			// The Gemini API doesn't identify the calls, their results are
+			// matched by name.
+			callIDs: false,
+			text: func(stream bool, chunks ...string) fakeResponse {
+				response := func(text, finishReason string) string {
+					return fmt.Sprintf(`{"candidates":[{"content":{"role":"model","parts":[{"text":%s}]}%s,"index":0}],`+
+						`"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15}}`, jsonString(text), finishReason)
+				}
+				if !stream {
+					return jsonResponse(response(strings.Join(chunks, ""), `,"finishReason":"STOP"`))
+				}
+				var events []string
+				for i, chunk := range chunks {
+					finishReason := ""
+					if i == len(chunks)-1 {
+						finishReason = `,"finishReason":"STOP"`
+					}
+					events = append(events, response(chunk, finishReason))
+				}
+				return sseResponse(events...)
+			},
+			toolCalls: func(stream bool, calls ...FunctionCall) fakeResponse {
+				var parts []string
+				for _, call := range calls {
+					parts = append(parts, fmt.Sprintf(`{"functionCall":{"name":%q,"args":%s}}`, call.Name, argumentsJSON(call)))
+				}
+				response := fmt.Sprintf(`{"candidates":[{"content":{"role":"model","parts":[%s]},"finishReason":"STOP","index":0}],`+
+					`"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15}}`, strings.Join(parts, ","))
+				if !stream {
+					return jsonResponse(response)
+				}
+				// Function calls are streamed in a single chunk.
+				return sseResponse(response)
+			},
+			error: func(status int) fakeResponse {
+				return fakeResponse{
+					status:      status,
+					contentType: "application/json",
+					body:        []byte(fmt.Sprintf(`{"error":{"code":%d,"message":"the request failed","status":%q}}`, status, http.StatusText(status))),
+				}
+			},
+		},
+		{
+			name: "bedrock",
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				client := &BedrockClient{client: bedrockruntime.New(bedrockruntime.Options{
+					Region:       "us-east-1",
+					BaseEndpoint: aws.String(api.URL),
+					Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+						return aws.Credentials{AccessKeyID: "test-key", SecretAccessKey: "test-secret"}, nil
+					}),
+					Retryer: aws.NopRetryer{},
+				})}
+				return startConformanceChat(t, client, "us.anthropic.claude-sonnet-4-20250514-v1:0")
+			},
+			callIDs:   true,
+			text:      bedrockConformanceText,
+			toolCalls: bedrockConformanceToolCalls,
+			error: func(status int) fakeResponse {
+				errorType := map[int]string{
+					http.StatusTooManyRequests:    "ThrottlingException",
+					http.StatusServiceUnavailable: "ServiceUnavailableException",
+					http.StatusBadRequest:         "ValidationException",
+					http.StatusUnauthorized:       "UnrecognizedClientException",
+				}[status]
+				return fakeResponse{
+					status:      status,
+					contentType: "application/json",
+					header:      http.Header{"X-Amzn-Errortype": {errorType}},
+					body:        []byte(`{"message":"the request failed"}`),
+				}
+			},
+		},
+		{
+			name: "azopenai",
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				// API keys are only sent over TLS.
+				api.tls(t)
+				client, err := azopenai.NewClientForOpenAI(api.URL, azcore.NewKeyCredential("test-key"), &azopenai.ClientOptions{
+					ClientOptions: azcore.ClientOptions{Retry: policy.RetryOptions{MaxRetries: -1}, Transport: api.Client()},
+				})
+				if err != nil {
+					t.Fatalf("azopenai.NewClientForOpenAI() error: %v", err)
+				}
+				return startConformanceChat(t, &AzureOpenAIClient{client: client}, "gpt-4.1")
+			},
+			text: func(stream bool, chunks ...string) fakeResponse {
+				return openAIConformance.text(false, chunks...)
+			},
+			toolCalls: func(stream bool, calls ...FunctionCall) fakeResponse {
+				return openAIConformance.toolCalls(false, calls...)
+			},
+			error: openAIConformance.error,
+			unsupported: map[string]string{
+				behaviorHistory: "Initialize doesn't restore the history",
+			},
+		},
+		{
+			name: "ollama",
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				u, err := url.Parse(api.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return startConformanceChat(t, &OllamaClient{client: ollama.NewClient(u, http.DefaultClient)}, "gemma3:latest")
+			},
+			text: func(stream bool, chunks ...string) fakeResponse {
+				return jsonResponse(fmt.Sprintf(`{"model":"gemma3:latest","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":%s},"done_reason":"stop","done":true}`, jsonString(strings.Join(chunks, ""))))
+			},
+			toolCalls: func(stream bool, calls ...FunctionCall) fakeResponse {
+				var toolCalls []string
+				for _, call := range calls {
+					toolCalls = append(toolCalls, fmt.Sprintf(`{"function":{"name":%q,"arguments":%s}}`, call.Name, argumentsJSON(call)))
+				}
+				return jsonResponse(fmt.Sprintf(`{"model":"gemma3:latest","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":"","tool_calls":[%s]},"done_reason":"stop","done":true}`, strings.Join(toolCalls, ",")))
+			},
+			error: func(status int) fakeResponse {
+				return fakeResponse{status: status, contentType: "application/json", body: []byte(`{"error":"the request failed"}`)}
+			},
+			unsupported: map[string]string{
+				behaviorHistory: "Initialize doesn't restore the history",
+				behaviorRetry:   "the Ollama client drops the status of responses reporting an error",
+			},
+		},
+		{
+			name: "llamacpp",
+			newChat: func(t *testing.T, api *fakeAPI) Chat {
+				u, err := url.Parse(api.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return startConformanceChat(t, &LlamaCppClient{baseURL: u, httpClient: http.DefaultClient}, "")
+			},
+			text: func(stream bool, chunks ...string) fakeResponse {
+				return openAIConformance.text(false, chunks...)
+			},
+			toolCalls: func(stream bool, calls ...FunctionCall) fakeResponse {
+				return openAIConformance.toolCalls(false, calls...)
+			},
+			error:       openAIConformance.error,
+			unsupported: map[string]string{behaviorHistory: "Initialize doesn't restore the history"},
+		},
+	}
+}
+
+// bedrockEvents encodes the events of a ConverseStream response of Bedrock,
+// in the AWS event stream format. Each event is its type and its payload.
+func bedrockEvents(events ...[2]string) fakeResponse {
+	var b bytes.Buffer
+	encoder := eventstream.NewEncoder()
+	for _, event := range events {
+		message := eventstream.Message{
+			Headers: eventstream.Headers{
+				{Name: ":message-type", Value: eventstream.StringValue("event")},
+				{Name: ":event-type", Value: eventstream.StringValue(event[0])},
+				{Name: ":content-type", Value: eventstream.StringValue("application/json")},
+			},
+			Payload: []byte(event[1]),
+		}
+		if err := encoder.Encode(&b, message); err != nil {
+			panic(err)
+		}
+	}
+	return fakeResponse{status: http.StatusOK, contentType: "application/vnd.amazon.eventstream", body: b.Bytes()}
+}
+
+func bedrockConformanceText(stream bool, chunks ...string) fakeResponse {
+	if !stream {
+		return jsonResponse(fmt.Sprintf(`{"output":{"message":{"role":"assistant","content":[{"text":%s}]}},"stopReason":"end_turn",`+
+			`"usage":{"inputTokens":10,"outputTokens":5,"totalTokens":15},"metrics":{"latencyMs":100}}`, jsonString(strings.Join(chunks, ""))))
+	}
+	events := [][2]string{{"messageStart", `{"role":"assistant"}`}}
+	for _, chunk := range chunks {
+		events = append(events, [2]string{"contentBlockDelta", fmt.Sprintf(`{"contentBlockIndex":0,"delta":{"text":%s}}`, jsonString(chunk))})
+	}
+	events = append(events,
+		[2]string{"contentBlockStop", `{"contentBlockIndex":0}`},
+		[2]string{"messageStop", `{"stopReason":"end_turn"}`},
+		[2]string{"metadata", `{"usage":{"inputTokens":10,"outputTokens":5,"totalTokens":15},"metrics":{"latencyMs":100}}`})
+	return bedrockEvents(events...)
+}
+
+func bedrockConformanceToolCalls(stream bool, calls ...FunctionCall) fakeResponse {
+	if !stream {
+		var content []string
+		for _, call := range calls {
+			content = append(content, fmt.Sprintf(`{"toolUse":{"toolUseId":%q,"name":%q,"input":%s}}`, call.ID, call.Name, argumentsJSON(call)))
+		}
+		return jsonResponse(fmt.Sprintf(`{"output":{"message":{"role":"assistant","content":[%s]}},"stopReason":"tool_use",`+
+			`"usage":{"inputTokens":10,"outputTokens":5,"totalTokens":15},"metrics":{"latencyMs":100}}`, strings.Join(content, ",")))
+	}
+	events := [][2]string{{"messageStart", `{"role":"assistant"}`}}
+	for i, call := range calls {
+		events = append(events,
+			[2]string{"contentBlockStart", fmt.Sprintf(`{"contentBlockIndex":%d,"start":{"toolUse":{"toolUseId":%q,"name":%q}}}`, i, call.ID, call.Name)},
+			[2]string{"contentBlockDelta", fmt.Sprintf(`{"contentBlockIndex":%d,"delta":{"toolUse":{"input":%s}}}`, i, jsonString(argumentsJSON(call)))},
+			[2]string{"contentBlockStop", fmt.Sprintf(`{"contentBlockIndex":%d}`, i)})
+	}
+	events = append(events,
+		[2]string{"messageStop", `{"stopReason":"tool_use"}`},
+		[2]string{"metadata", `{"usage":{"inputTokens":10,"outputTokens":5,"totalTokens":15},"metrics":{"latencyMs":100}}`})
+	return bedrockEvents(events...)
+}
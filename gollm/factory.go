@@ -15,10 +15,13 @@
 package gollm
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand/v2"
 	"net"
 	"net/http"
@@ -28,8 +31,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
-
 	"k8s.io/klog/v2"
 )
 
@@ -53,6 +54,23 @@ func (r *registry) listProviders() []string {
 type ClientOptions struct {
 	URL           *url.URL
 	SkipVerifySSL bool
+	// CABundle is a PEM file of the certificate authorities trusted for the
+	// endpoints of the provider, on top of those of the system, e.g. the CA
+	// of a TLS-intercepting proxy. NewClient loads it into rootCAs.
+	CABundle string
+	rootCAs  *x509.CertPool
+	// Gemini holds generation options used by the gemini and vertexai providers.
+	Gemini GeminiOptions
+	// Vertex selects the project, location and identity used by the vertexai provider.
+	Vertex VertexOptions
+	// WebSearch enables the web search tool built into the provider, if any,
+	// e.g. grounding with Google Search for Gemini.
+	WebSearch bool
+	// MaxOutputTokens caps the number of tokens of each response of chats.
+	// Zero uses the default of the provider.
+	MaxOutputTokens int
+	// PromptLog is what the client and its provider log of the prompts and responses.
+	PromptLog PromptLogOptions
 	// Extend with more options as needed
 }
 
@@ -66,6 +84,54 @@ func WithSkipVerifySSL() Option {
 	}
 }
 
+// WithCABundle trusts the certificate authorities of a PEM file for the
+// endpoints of the provider, on top of those of the system.
+func WithCABundle(path string) Option {
+	return func(o *ClientOptions) {
+		o.CABundle = path
+	}
+}
+
+// WithGeminiOptions sets the generation options used by the gemini and vertexai providers.
+func WithGeminiOptions(geminiOptions GeminiOptions) Option {
+	return func(o *ClientOptions) {
+		o.Gemini = geminiOptions
+	}
+}
+
+// WithWebSearch lets the model search the web with the tool built into the
+// provider. Providers without such a tool ignore it, see WebSearchEnabled.
+func WithWebSearch() Option {
+	return func(o *ClientOptions) {
+		o.WebSearch = true
+	}
+}
+
+// WithMaxOutputTokens caps the number of tokens of each response of chats.
+func WithMaxOutputTokens(maxOutputTokens int) Option {
+	return func(o *ClientOptions) {
+		o.MaxOutputTokens = maxOutputTokens
+	}
+}
+
+// VertexOptions configures the vertexai provider.
+// Empty fields fall back to the environment and gcloud defaults.
+type VertexOptions struct {
+	Project  string
+	Location string
+	// ImpersonateServiceAccount is the email of a service account to impersonate.
+	ImpersonateServiceAccount string
+	// ImpersonateDelegates is the delegation chain used to impersonate ImpersonateServiceAccount.
+	ImpersonateDelegates []string
+}
+
+// WithVertexOptions sets the options of the vertexai provider.
+func WithVertexOptions(vertexOptions VertexOptions) Option {
+	return func(o *ClientOptions) {
+		o.Vertex = vertexOptions
+	}
+}
+
 type FactoryFunc func(ctx context.Context, opts ClientOptions) (Client, error)
 
 func RegisterProvider(id string, factoryFunc FactoryFunc) error {
@@ -114,11 +180,26 @@ func (r *registry) NewClient(ctx context.Context, providerID string, opts ...Opt
 	if v := os.Getenv("LLM_SKIP_VERIFY_SSL"); v == "1" || strings.ToLower(v) == "true" {
 		clientOpts.SkipVerifySSL = true
 	}
+	clientOpts.CABundle = os.Getenv("LLM_CA_BUNDLE")
 	for _, opt := range opts {
 		opt(&clientOpts)
 	}
+	if err := clientOpts.PromptLog.Validate(); err != nil {
+		return nil, err
+	}
+	if clientOpts.CABundle != "" {
+		rootCAs, err := LoadCABundle(clientOpts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts.rootCAs = rootCAs
+	}
 
-	return factoryFunc(ctx, clientOpts)
+	client, err := factoryFunc(ctx, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &promptLogClient{Client: client, promptLog: clientOpts.PromptLog}, nil
 }
 
 /*
@@ -143,6 +224,9 @@ type APIError struct {
 	StatusCode int
 	Message    string
 	Err        error
+	// RetryAfter is the delay before the request can be retried, as reported
+	// by the rate-limit headers of the response, if any.
+	RetryAfter time.Duration
 }
 
 func (e *APIError) Error() string {
@@ -156,6 +240,49 @@ func (e *APIError) Unwrap() error {
 	return e.Err
 }
 
+// ErrToolsNotSupported reports that a model doesn't support tools (function
+// calling): its provider rejected the function definitions, or the model
+// ignored them. Use errors.Is to test for it, or IsToolsNotSupported for the
+// errors of the providers, which don't wrap it.
+var ErrToolsNotSupported = errors.New("the model does not support tools")
+
+// toolsNotSupportedPatterns recognize the errors of the providers rejecting
+// function definitions, for the models without tool support.
+var toolsNotSupportedPatterns = []string{
+	"does not support tools",            // ollama
+	"doesn't support tool use",          // bedrock
+	"function calling is not enabled",   // gemini, e.g. for gemma models
+	"tool choice requires",              // vLLM without --enable-auto-tool-choice
+	"tools param requires",              // llama.cpp without --jinja
+	"does not support function calling", // other OpenAI-compatible servers
+}
+
+// IsToolsNotSupported reports whether err is ErrToolsNotSupported, or an
+// error of a provider rejecting the function definitions of a model.
+func IsToolsNotSupported(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrToolsNotSupported) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range toolsNotSupportedPatterns {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// newHTTPStatusError returns the error of an unsuccessful response of a
+// provider API, as an *APIError for retries and error categories to use its
+// status code.
+func newHTTPStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return &APIError{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(body))}
+}
+
 // IsRetryableFunc defines the signature for functions that check if an error is retryable.
 // TODO (droot): Adjust the signature to allow underlying client to relay the backoff
 // delay etc. for example, Gemini's error codes contain retryDelay information.
@@ -169,14 +296,13 @@ func DefaultIsRetryableError(err error) bool {
 
 	var apiErr *APIError
 	if errors.As(err, &apiErr) {
-		switch apiErr.StatusCode {
-		case http.StatusConflict, http.StatusTooManyRequests,
-			http.StatusInternalServerError, http.StatusBadGateway,
-			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
-			return true
-		default:
-			return false
-		}
+		return isRetryableStatus(apiErr.StatusCode)
+	}
+
+	// Errors of the AWS SDK, among others, report the status of the response.
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.HTTPStatusCode())
 	}
 
 	var netErr net.Error
@@ -190,20 +316,49 @@ func DefaultIsRetryableError(err error) bool {
 	return false
 }
 
-// createCustomHTTPClient returns an *http.Client that optionally skips SSL certificate verification.
+// isRetryableStatus returns whether requests failing with the HTTP status
+// can succeed when retried.
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusConflict, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// createCustomHTTPClient returns an *http.Client that optionally skips SSL
+// certificate verification, or trusts the CA bundle of the options.
 // This is shared by all providers that need custom HTTP transport.
-func createCustomHTTPClient(skipVerify bool) *http.Client {
-	if !skipVerify {
+func createCustomHTTPClient(opts ClientOptions) *http.Client {
+	if !opts.SkipVerifySSL && opts.rootCAs == nil {
 		return http.DefaultClient
 	}
-	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: opts.SkipVerifySSL,
+		RootCAs:            opts.rootCAs,
 	}
+	return &http.Client{Transport: transport}
+}
+
+// LoadCABundle returns the certificate authorities of the system, with those
+// of a PEM file added.
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificate found in CA bundle %q", path)
+	}
+	return pool, nil
 }
 
 // RetryConfig holds the configuration for the retry mechanism (same as before)
@@ -275,6 +430,15 @@ func Retry[T any](
 		if config.Jitter {
 			waitTime += time.Duration(rand.Float64() * float64(backoff) / 2)
 		}
+		// Retrying before the rate limit is reset would fail again.
+		var apiErr *APIError
+		if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > waitTime {
+			if apiErr.RetryAfter > config.MaxBackoff {
+				log.Info("Not retrying, the rate limit is reset after the maximum backoff", "attempt", attempt, "retryAfter", apiErr.RetryAfter)
+				return zero, lastErr
+			}
+			waitTime = apiErr.RetryAfter
+		}
 
 		log.V(2).Info("Waiting before next retry attempt", "waitTime", waitTime, "nextAttempt", attempt+1, "maxAttempts", config.MaxAttempts)
 
@@ -343,6 +507,6 @@ func (rc *retryChat[C]) IsRetryableError(err error) bool {
 	return rc.underlying.IsRetryableError(err)
 }
 
-func (rc *retryChat[C]) Initialize(messages []*api.Message) error {
+func (rc *retryChat[C]) Initialize(messages []*Message) error {
 	return rc.underlying.Initialize(messages)
 }
@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsToolsNotSupported(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{StatusCode: 400, Message: `{"error":"registry.ollama.ai/library/gemma3:12b does not support tools"}`}, true},
+		{errors.New("Error 400, Message: Function calling is not enabled for models/gemma-3-27b-it, Status: INVALID_ARGUMENT"), true},
+		{errors.New(`"auto" tool choice requires --enable-auto-tool-choice and --tool-call-parser to be set`), true},
+		{errors.New("ValidationException: This model doesn't support tool use."), true},
+		{fmt.Errorf("sending: %w", ErrToolsNotSupported), true},
+		{&APIError{StatusCode: 400, Message: "invalid request"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsToolsNotSupported(tt.err); got != tt.want {
+			t.Errorf("IsToolsNotSupported(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rootCAs, err := LoadCABundle(bundle)
+	if err != nil {
+		t.Fatalf("LoadCABundle: %v", err)
+	}
+
+	if _, err := createCustomHTTPClient(ClientOptions{}).Get(server.URL); err == nil {
+		t.Errorf("the default client trusts the certificate of the test server")
+	}
+	resp, err := createCustomHTTPClient(ClientOptions{rootCAs: rootCAs}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET with the CA bundle: %v", err)
+	}
+	resp.Body.Close()
+
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{notPEM, filepath.Join(dir, "missing.pem")} {
+		if _, err := LoadCABundle(path); err == nil {
+			t.Errorf("LoadCABundle(%q) succeeded, want an error", path)
+		}
+	}
+}
@@ -25,12 +25,15 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"slices"
+	"sort"
 	"strings"
 
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/credentials/impersonate"
+	"cloud.google.com/go/auth/httptransport"
 	"google.golang.org/genai"
 
-	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
-
 	"k8s.io/klog/v2"
 )
 
@@ -44,30 +47,115 @@ func init() {
 }
 
 // geminiFactory is the provider factory function for Gemini.
-// Supports ClientOptions for consistency, but skipVerifySSL is not used.
+// Supports ClientOptions, including skipVerifySSL and the CA bundle.
 func geminiFactory(ctx context.Context, opts ClientOptions) (Client, error) {
-	opt := GeminiAPIClientOptions{}
+	opt := GeminiAPIClientOptions{
+		Generation: opts.geminiOptions(),
+		WebSearch:  opts.WebSearch,
+	}
+	if httpClient := createCustomHTTPClient(opts); httpClient != http.DefaultClient {
+		opt.HTTPClient = httpClient
+	}
 	return NewGeminiAPIClient(ctx, opt)
 }
 
+// GeminiOptions tunes the generation config used for chats with Gemini models.
+type GeminiOptions struct {
+	// ThinkingBudget caps the number of thinking tokens for models that support thinking (e.g. gemini-2.5-*).
+	// Nil uses the model default, 0 disables thinking and -1 enables dynamic thinking.
+	ThinkingBudget *int32
+	// SafetySettings maps a harm category (e.g. HARM_CATEGORY_DANGEROUS_CONTENT or DANGEROUS_CONTENT)
+	// to a block threshold (e.g. BLOCK_ONLY_HIGH).
+	SafetySettings map[string]string
+	// CandidateCount is the number of response candidates to generate. Zero uses the model default.
+	// The chat only keeps the first candidate in its history, and streamed responses only
+	// return it, while all the candidates are billed.
+	CandidateCount int32
+	// MaxOutputTokens caps the number of tokens of each response. Zero uses 8192.
+	MaxOutputTokens int32
+}
+
+// geminiOptions returns the Gemini generation options, with the options
+// common to all providers applied.
+func (o ClientOptions) geminiOptions() GeminiOptions {
+	generation := o.Gemini
+	if o.MaxOutputTokens > 0 {
+		generation.MaxOutputTokens = int32(o.MaxOutputTokens)
+	}
+	return generation
+}
+
+var (
+	geminiHarmCategories = map[genai.HarmCategory]bool{
+		genai.HarmCategoryHateSpeech:       true,
+		genai.HarmCategoryDangerousContent: true,
+		genai.HarmCategoryHarassment:       true,
+		genai.HarmCategorySexuallyExplicit: true,
+		genai.HarmCategoryCivicIntegrity:   true,
+	}
+	geminiHarmBlockThresholds = map[genai.HarmBlockThreshold]bool{
+		genai.HarmBlockThresholdBlockLowAndAbove:    true,
+		genai.HarmBlockThresholdBlockMediumAndAbove: true,
+		genai.HarmBlockThresholdBlockOnlyHigh:       true,
+		genai.HarmBlockThresholdBlockNone:           true,
+		genai.HarmBlockThresholdOff:                 true,
+	}
+)
+
+// safetySettings validates and converts the configured safety settings.
+func (o *GeminiOptions) safetySettings() ([]*genai.SafetySetting, error) {
+	var settings []*genai.SafetySetting
+	for category, threshold := range o.SafetySettings {
+		c := genai.HarmCategory(strings.ToUpper(strings.TrimSpace(category)))
+		if !strings.HasPrefix(string(c), "HARM_CATEGORY_") {
+			c = "HARM_CATEGORY_" + c
+		}
+		if !geminiHarmCategories[c] {
+			return nil, fmt.Errorf("unknown gemini harm category %q", category)
+		}
+		t := genai.HarmBlockThreshold(strings.ToUpper(strings.TrimSpace(threshold)))
+		if !geminiHarmBlockThresholds[t] {
+			return nil, fmt.Errorf("unknown gemini harm block threshold %q for category %q", threshold, category)
+		}
+		settings = append(settings, &genai.SafetySetting{Category: c, Threshold: t})
+	}
+	// Keep the request deterministic, map iteration order is random
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Category < settings[j].Category
+	})
+	return settings, nil
+}
+
 // GeminiAPIClientOptions are the options for the Gemini API client.
 type GeminiAPIClientOptions struct {
 	// API Key for GenAI. Required for BackendGeminiAPI.
 	APIKey string
+	// Generation tunes the generation config used for chats.
+	Generation GeminiOptions
+	// WebSearch grounds the responses of chats with Google Search.
+	WebSearch bool
+	// HTTPClient sends the requests, e.g. trusting a custom CA. Optional.
+	HTTPClient *http.Client
 }
 
 // NewGeminiAPIClient builds a client for the Gemini API.
 func NewGeminiAPIClient(ctx context.Context, opt GeminiAPIClientOptions) (*GoogleAIClient, error) {
 	apiKey := opt.APIKey
 	if apiKey == "" {
-		apiKey = os.Getenv("GEMINI_API_KEY")
+		apiKey = providerAPIKey("gemini")
 	}
 	if apiKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY environment variable not set")
+		return nil, fmt.Errorf("GEMINI_API_KEY environment variable not set, and no API key stored with `kubectl-ai auth login gemini`")
 	}
 	cc := &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
+		APIKey:     apiKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: opt.HTTPClient,
+	}
+
+	safetySettings, err := opt.Generation.safetySettings()
+	if err != nil {
+		return nil, err
 	}
 
 	client, err := genai.NewClient(ctx, cc)
@@ -76,7 +164,10 @@ func NewGeminiAPIClient(ctx context.Context, opt GeminiAPIClientOptions) (*Googl
 	}
 
 	return &GoogleAIClient{
-		client: client,
+		client:         client,
+		generation:     opt.Generation,
+		safetySettings: safetySettings,
+		webSearch:      opt.WebSearch,
 	}, nil
 }
 
@@ -86,15 +177,70 @@ type VertexAIClientOptions struct {
 	Project string
 	// GCP Location/Region for Vertex AI. Required for BackendVertexAI. See https://cloud.google.com/vertex-ai/docs/general/locations
 	Location string
+	// ImpersonateServiceAccount is the email of a service account to impersonate,
+	// using the application default credentials. Optional.
+	ImpersonateServiceAccount string
+	// ImpersonateDelegates is the delegation chain of service accounts used to
+	// impersonate ImpersonateServiceAccount. Optional.
+	ImpersonateDelegates []string
+	// Generation tunes the generation config used for chats.
+	Generation GeminiOptions
+	// WebSearch grounds the responses of chats with Google Search.
+	WebSearch bool
+	// HTTPClient sends the requests, e.g. trusting a custom CA, authenticated
+	// with the credentials. Optional.
+	HTTPClient *http.Client
 }
 
 // vertexaiViaGeminiFactory is the provider factory function for VertexAI via Gemini.
-// Supports ClientOptions for consistency, but skipVerifySSL is not used.
+// Supports ClientOptions, including skipVerifySSL and the CA bundle.
 func vertexaiViaGeminiFactory(ctx context.Context, opts ClientOptions) (Client, error) {
-	opt := VertexAIClientOptions{}
+	opt := VertexAIClientOptions{
+		Project:                   opts.Vertex.Project,
+		Location:                  opts.Vertex.Location,
+		ImpersonateServiceAccount: opts.Vertex.ImpersonateServiceAccount,
+		ImpersonateDelegates:      opts.Vertex.ImpersonateDelegates,
+		Generation:                opts.geminiOptions(),
+		WebSearch:                 opts.WebSearch,
+	}
+	if httpClient := createCustomHTTPClient(opts); httpClient != http.DefaultClient {
+		opt.HTTPClient = httpClient
+	}
 	return NewVertexAIClient(ctx, opt)
 }
 
+// cloudPlatformScope is the OAuth scope of the Vertex AI API.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// authenticatedHTTPClient returns a client sending the requests with the
+// transport of base, authenticated with the credentials of the config, or
+// the application default credentials.
+func authenticatedHTTPClient(ctx context.Context, cc *genai.ClientConfig, base *http.Client) (*http.Client, error) {
+	if cc.Credentials == nil {
+		creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+			Scopes: []string{cloudPlatformScope},
+			Client: base,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("finding default credentials: %w", err)
+		}
+		cc.Credentials = creds
+	}
+	quotaProjectID, err := cc.Credentials.QuotaProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting quota project ID: %w", err)
+	}
+	httpClient, err := httptransport.NewClient(&httptransport.Options{
+		Credentials:      cc.Credentials,
+		BaseRoundTripper: base.Transport,
+		Headers:          http.Header{"X-Goog-User-Project": []string{quotaProjectID}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("building HTTP client: %w", err)
+	}
+	return httpClient, nil
+}
+
 // findDefaultGCPProject gets the default GCP project ID from gcloud
 func findDefaultGCPProject(ctx context.Context) (string, error) {
 	log := klog.FromContext(ctx)
@@ -171,6 +317,34 @@ func NewVertexAIClient(ctx context.Context, opt VertexAIClientOptions) (*GoogleA
 		cc.Location = location
 	}
 
+	if opt.ImpersonateServiceAccount != "" {
+		log.Info("impersonating service account for vertex client", "serviceAccount", opt.ImpersonateServiceAccount, "delegates", opt.ImpersonateDelegates)
+		credentials, err := impersonate.NewCredentials(&impersonate.CredentialsOptions{
+			TargetPrincipal: opt.ImpersonateServiceAccount,
+			Delegates:       opt.ImpersonateDelegates,
+			Scopes:          []string{cloudPlatformScope},
+			Client:          opt.HTTPClient,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("impersonating service account %q: %w", opt.ImpersonateServiceAccount, err)
+		}
+		cc.Credentials = credentials
+	}
+
+	if opt.HTTPClient != nil {
+		// genai only authenticates the requests of the clients it creates.
+		httpClient, err := authenticatedHTTPClient(ctx, cc, opt.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+		cc.HTTPClient = httpClient
+	}
+
+	safetySettings, err := opt.Generation.safetySettings()
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := genai.NewClient(ctx, cc)
 
 	if err != nil {
@@ -178,7 +352,10 @@ func NewVertexAIClient(ctx context.Context, opt VertexAIClientOptions) (*GoogleA
 	}
 
 	return &GoogleAIClient{
-		client: client,
+		client:         client,
+		generation:     opt.Generation,
+		safetySettings: safetySettings,
+		webSearch:      opt.WebSearch,
 	}, nil
 }
 
@@ -189,10 +366,23 @@ type GoogleAIClient struct {
 
 	// responseSchema will constrain the output to match the given schema
 	responseSchema *genai.Schema
+
+	// generation holds the user-supplied generation options for chats
+	generation GeminiOptions
+	// safetySettings are the validated safety settings from generation
+	safetySettings []*genai.SafetySetting
+
+	// webSearch grounds the responses of chats with Google Search
+	webSearch bool
 }
 
 var _ Client = &GoogleAIClient{}
 
+// WebSearchEnabled returns true if chats are grounded with Google Search.
+func (c *GoogleAIClient) WebSearchEnabled() bool {
+	return c.webSearch
+}
+
 // ListModels lists the models available in the Gemini API.
 func (c *GoogleAIClient) ListModels(ctx context.Context) (modelNames []string, err error) {
 	for model, err := range c.client.Models.All(ctx) {
@@ -242,7 +432,9 @@ func (c *GoogleAIClient) GenerateCompletion(ctx context.Context, request *Comple
 		{Role: "user", Parts: []*genai.Part{{Text: request.Prompt}}},
 	}
 
-	log.Info("sending GenerateContent request to gemini", "content", content)
+	if PromptContentLogged(ctx) {
+		log.Info("sending GenerateContent request to gemini", "content", content)
+	}
 	result, err := c.client.Models.GenerateContent(ctx, request.Model, content, config)
 	if err != nil {
 		return nil, err
@@ -258,10 +450,14 @@ func (c *GoogleAIClient) StartChat(systemPrompt string, model string) Chat {
 	topK := float32(40)
 	topP := float32(0.95)
 	maxOutputTokens := int32(8192)
+	if c.generation.MaxOutputTokens > 0 {
+		maxOutputTokens = c.generation.MaxOutputTokens
+	}
 
 	chat := &GeminiChat{
-		model:  model,
-		client: c.client,
+		model:     model,
+		client:    c.client,
+		webSearch: c.webSearch,
 		genConfig: &genai.GenerateContentConfig{
 			SystemInstruction: &genai.Content{
 				Parts: []*genai.Part{
@@ -277,6 +473,19 @@ func (c *GoogleAIClient) StartChat(systemPrompt string, model string) Chat {
 		history: []*genai.Content{},
 	}
 
+	if c.generation.ThinkingBudget != nil {
+		chat.genConfig.ThinkingConfig = &genai.ThinkingConfig{
+			ThinkingBudget: c.generation.ThinkingBudget,
+		}
+	}
+	if c.generation.CandidateCount > 0 {
+		chat.genConfig.CandidateCount = c.generation.CandidateCount
+	}
+	chat.genConfig.SafetySettings = c.safetySettings
+	if c.webSearch {
+		chat.genConfig.Tools = []*genai.Tool{{GoogleSearch: &genai.GoogleSearch{}}}
+	}
+
 	if chat.model == "gemma-3-27b-it" {
 		// Note: gemma-3-27b-it does not allow system prompt
 		// xref: https://discuss.ai.google.dev/t/gemma-3-missing-features-despite-announcement/71692
@@ -301,6 +510,8 @@ type GeminiChat struct {
 	client    *genai.Client
 	history   []*genai.Content
 	genConfig *genai.GenerateContentConfig
+	// webSearch adds the Google Search tool to the function declarations.
+	webSearch bool
 }
 
 // SetFunctionDefinitions sets the function definitions for the chat.
@@ -326,6 +537,9 @@ func (c *GeminiChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefin
 			FunctionDeclarations: genaiFunctionDeclarations,
 		},
 	}
+	if c.webSearch {
+		c.genConfig.Tools = append(c.genConfig.Tools, &genai.Tool{GoogleSearch: &genai.GoogleSearch{}})
+	}
 	return nil
 }
 
@@ -372,6 +586,21 @@ func toGeminiSchema(schema *Schema) (*genai.Schema, error) {
 	return ret, nil
 }
 
+// geminiPendingCalls returns the function calls of the last content of the
+// history, if it is a response of the model.
+func geminiPendingCalls(history []*genai.Content) []FunctionCall {
+	if len(history) == 0 || history[len(history)-1] == nil || history[len(history)-1].Role != "model" {
+		return nil
+	}
+	var calls []FunctionCall
+	for _, part := range history[len(history)-1].Parts {
+		if part.FunctionCall != nil {
+			calls = append(calls, FunctionCall{ID: part.FunctionCall.ID, Name: part.FunctionCall.Name})
+		}
+	}
+	return calls
+}
+
 func (c *GeminiChat) partsToGemini(contents ...any) ([]*genai.Part, error) {
 	var parts []*genai.Part
 
@@ -387,6 +616,8 @@ func (c *GeminiChat) partsToGemini(contents ...any) ([]*genai.Part, error) {
 					Response: v.Result,
 				},
 			})
+		case ImagePart:
+			parts = append(parts, genai.NewPartFromBytes(v.Data, v.MIMEType))
 		default:
 			return nil, fmt.Errorf("unexpected type of content: %T", content)
 		}
@@ -398,8 +629,15 @@ func (c *GeminiChat) partsToGemini(contents ...any) ([]*genai.Part, error) {
 // It returns a ChatResponse object containing the response from the model.
 func (c *GeminiChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
 	log := klog.FromContext(ctx)
-	log.V(1).Info("sending LLM request", "user", contents)
+	if PromptContentLogged(ctx) {
+		log.V(1).Info("sending LLM request", "user", contents)
+	}
 
+	// Each function call of the last response must be answered.
+	contents, err := orderFunctionCallResults(geminiPendingCalls(c.history), contents)
+	if err != nil {
+		return nil, err
+	}
 	parts, err := c.partsToGemini(contents...)
 	if err != nil {
 		return nil, err
@@ -420,14 +658,23 @@ func (c *GeminiChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 	}
 	c.history = append(c.history, result.Candidates[0].Content)
 	geminiResponse := result
-	log.V(1).Info("got LLM response", "response", geminiResponse)
+	if PromptContentLogged(ctx) {
+		log.V(1).Info("got LLM response", "response", geminiResponse)
+	}
 	return &GeminiChatResponse{geminiResponse: geminiResponse}, nil
 }
 
 func (c *GeminiChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
 	log := klog.FromContext(ctx)
-	log.V(1).Info("sending LLM streaming request", "user", contents)
+	if PromptContentLogged(ctx) {
+		log.V(1).Info("sending LLM streaming request", "user", contents)
+	}
 
+	// Each function call of the last response must be answered.
+	contents, err := orderFunctionCallResults(geminiPendingCalls(c.history), contents)
+	if err != nil {
+		return nil, err
+	}
 	parts, err := c.partsToGemini(contents...)
 	if err != nil {
 		return nil, err
@@ -464,7 +711,11 @@ func (c *GeminiChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 			if content == nil || content.Parts == nil || len(content.Parts) == 0 {
 				// This happens when there is empty content with the finish reason (STOP) to indicate that streaming response is finished.
 				// xref: https://github.com/GoogleCloudPlatform/kubectl-ai/issues/306
-				log.V(1).Info("empty response probably with STOP finishedReason")
+				log.V(1).Info("empty response probably with STOP finishedReason", "finishReason", geminiResponse.Candidates[0].FinishReason)
+				if geminiResponse.Candidates[0].FinishReason == genai.FinishReasonMaxTokens {
+					// Let the caller know that the response was truncated.
+					yield(&GeminiChatResponse{geminiResponse: geminiResponse}, nil)
+				}
 				return
 			}
 			c.history = append(c.history, content)
@@ -476,38 +727,120 @@ func (c *GeminiChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 	}, nil
 }
 
-func (c *GeminiChat) Initialize(messages []*api.Message) error {
+// Initialize restores the history of a previous conversation, e.g. when a
+// session is resumed. Consecutive messages of the same role are merged into a
+// turn. The function calls of the model are restored with their results, and
+// the calls left without a result are answered as not run. Gemini rejects the
+// function responses that don't answer a call of the previous turn: the
+// results whose call is not in the history, e.g. saved with a description of
+// the call only, are restored as text, like the contents of other types.
+func (c *GeminiChat) Initialize(messages []*Message) error {
 	klog.Info("Initializing gemini chat")
 	c.history = make([]*genai.Content, 0, len(messages))
 	for _, msg := range messages {
-		content, err := c.messageToContent(msg)
-		if err != nil {
+		var role string
+		switch msg.Role {
+		case RoleUser:
+			role = "user"
+		case RoleModel:
+			role = "model"
+		default:
+			continue
+		}
+
+		var calls []FunctionCall
+		if last := len(c.history) - 1; role == "user" && last >= 0 {
+			if c.history[last].Role == "model" {
+				calls = geminiPendingCalls(c.history)
+			} else if last > 0 {
+				// Results following other user messages, e.g. the error of
+				// another call, still answer the calls of the model.
+				calls = geminiPendingCalls(c.history[:last])
+			}
+		}
+		parts := geminiHistoryParts(role, msg.Content, calls)
+		if len(parts) == 0 {
 			continue
 		}
-		c.history = append(c.history, content)
+		if last := len(c.history) - 1; last >= 0 && c.history[last].Role == role {
+			c.history[last].Parts = append(c.history[last].Parts, parts...)
+		} else {
+			c.history = append(c.history, &genai.Content{Role: role, Parts: parts})
+		}
 	}
+	answerGeminiCalls(c.history)
 	return nil
 }
 
-func (c *GeminiChat) messageToContent(msg *api.Message) (*genai.Content, error) {
-	var role string
-	switch msg.Source {
-	case api.MessageSourceUser:
-		role = "user"
-	case api.MessageSourceModel:
-		role = "model"
-	case api.MessageSourceAgent:
-		role = "user" // Treat agent messages as user messages for Gemini history
-	default:
-		return nil, fmt.Errorf("unknown message source: %s", msg.Source)
+// geminiHistoryParts converts the content of a message of the history to
+// parts. calls are the function calls of the model a result can answer.
+func geminiHistoryParts(role string, content any, calls []FunctionCall) []*genai.Part {
+	switch v := content.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []*genai.Part{genai.NewPartFromText(v)}
+	case ImagePart:
+		return []*genai.Part{genai.NewPartFromBytes(v.Data, v.MIMEType)}
+	case FunctionCall:
+		if role == "model" {
+			return []*genai.Part{{FunctionCall: &genai.FunctionCall{ID: v.ID, Name: v.Name, Args: v.Arguments}}}
+		}
+	case []FunctionCall:
+		if role == "model" {
+			var parts []*genai.Part
+			for _, call := range v {
+				parts = append(parts, geminiHistoryParts(role, call, nil)...)
+			}
+			return parts
+		}
+	case FunctionCallResult:
+		for _, call := range calls {
+			if (call.ID != "" && call.ID == v.ID) || (call.ID == "" && v.ID == "" && call.Name == v.Name) {
+				return []*genai.Part{{FunctionResponse: &genai.FunctionResponse{ID: v.ID, Name: v.Name, Response: v.Result}}}
+			}
+		}
+		b, err := json.Marshal(v.Result)
+		if err != nil {
+			return nil
+		}
+		return []*genai.Part{genai.NewPartFromText(fmt.Sprintf("Result of %s:\n%s", v.Name, b))}
+	case nil:
+		return nil
 	}
-
-	parts, err := c.partsToGemini(msg.Payload)
+	// e.g. the result of a tool call saved by the agent, or a call restored
+	// in a user message.
+	b, err := json.Marshal(content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert message payload to parts: %w", err)
+		klog.Warningf("skipping message of type %T from the restored history: %v", content, err)
+		return nil
 	}
+	return []*genai.Part{genai.NewPartFromText(string(b))}
+}
 
-	return &genai.Content{Role: role, Parts: parts}, nil
+// answerGeminiCalls answers the function calls of the model left without a
+// result in the following turn as not run. The calls of the last turn are
+// answered by the next Send.
+func answerGeminiCalls(history []*genai.Content) {
+	for i := 0; i+1 < len(history); i++ {
+		if history[i].Role != "model" {
+			continue
+		}
+		next := history[i+1]
+		var missing []*genai.Part
+		for _, call := range geminiPendingCalls(history[:i+1]) {
+			answered := slices.ContainsFunc(next.Parts, func(part *genai.Part) bool {
+				r := part.FunctionResponse
+				return r != nil && ((call.ID != "" && r.ID == call.ID) || (call.ID == "" && r.ID == "" && r.Name == call.Name))
+			})
+			if !answered {
+				missing = append(missing, &genai.Part{FunctionResponse: &genai.FunctionResponse{ID: call.ID, Name: call.Name, Response: notRunResult}})
+			}
+		}
+		// The results come first in the turn answering the calls.
+		next.Parts = append(missing, next.Parts...)
+	}
 }
 
 // GeminiChatResponse is a response from the Gemini API.
@@ -535,6 +868,20 @@ func (r *GeminiChatResponse) UsageMetadata() any {
 	return r.geminiResponse.UsageMetadata
 }
 
+// TokenUsage returns the tokens used by the response.
+// When streaming, every chunk reports the usage of the response so far.
+func (r *GeminiChatResponse) TokenUsage() *Usage {
+	usage := r.geminiResponse.UsageMetadata
+	if usage == nil {
+		return nil
+	}
+	return &Usage{
+		InputTokens:  int(usage.PromptTokenCount),
+		OutputTokens: int(usage.CandidatesTokenCount + usage.ThoughtsTokenCount),
+		TotalTokens:  int(usage.TotalTokenCount),
+	}
+}
+
 // Candidates returns the candidates for the response.
 func (r *GeminiChatResponse) Candidates() []Candidate {
 	var candidates []Candidate
@@ -575,6 +922,34 @@ func (r *GeminiCandidate) String() string {
 	return response.String()
 }
 
+// FinishReason returns why the model stopped generating the candidate.
+func (r *GeminiCandidate) FinishReason() FinishReason {
+	switch r.candidate.FinishReason {
+	case "", genai.FinishReasonUnspecified:
+		return FinishReasonUnknown
+	case genai.FinishReasonStop:
+		return FinishReasonStop
+	case genai.FinishReasonMaxTokens:
+		return FinishReasonMaxTokens
+	default:
+		return FinishReasonOther
+	}
+}
+
+// Citations returns the web pages the candidate was grounded with by Google Search.
+func (r *GeminiCandidate) Citations() []Citation {
+	if r.candidate.GroundingMetadata == nil {
+		return nil
+	}
+	var citations []Citation
+	for _, chunk := range r.candidate.GroundingMetadata.GroundingChunks {
+		if chunk.Web != nil && chunk.Web.URI != "" {
+			citations = append(citations, Citation{Title: chunk.Web.Title, URI: chunk.Web.URI})
+		}
+	}
+	return citations
+}
+
 // Parts returns the parts of the candidate.
 func (r *GeminiCandidate) Parts() []Part {
 	var parts []Part
@@ -0,0 +1,133 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestGeminiSafetySettings(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      map[string]string
+		expected      []*genai.SafetySetting
+		expectedError bool
+	}{
+		{
+			name:     "no settings",
+			settings: nil,
+			expected: nil,
+		},
+		{
+			name: "full and short category names",
+			settings: map[string]string{
+				"HARM_CATEGORY_HATE_SPEECH": "block_none",
+				"dangerous_content":         "BLOCK_ONLY_HIGH",
+			},
+			expected: []*genai.SafetySetting{
+				{Category: genai.HarmCategoryDangerousContent, Threshold: genai.HarmBlockThresholdBlockOnlyHigh},
+				{Category: genai.HarmCategoryHateSpeech, Threshold: genai.HarmBlockThresholdBlockNone},
+			},
+		},
+		{
+			name:          "unknown category",
+			settings:      map[string]string{"NOT_A_CATEGORY": "BLOCK_NONE"},
+			expectedError: true,
+		},
+		{
+			name:          "unknown threshold",
+			settings:      map[string]string{"HARASSMENT": "BLOCK_SOMETIMES"},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := GeminiOptions{SafetySettings: tt.settings}
+			got, err := opts.safetySettings()
+			if tt.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %d settings, got %d", len(tt.expected), len(got))
+			}
+			for i := range got {
+				if got[i].Category != tt.expected[i].Category || got[i].Threshold != tt.expected[i].Threshold {
+					t.Errorf("setting %d: expected %+v, got %+v", i, tt.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGeminiInitialize(t *testing.T) {
+	history := []*Message{
+		{Role: RoleUser, Content: "why is nginx down?"},
+		{Role: RoleModel, Content: FunctionCall{ID: "1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}}},
+		{Role: RoleUser, Content: FunctionCallResult{ID: "1", Name: "kubectl", Result: map[string]any{"stdout": "nginx ImagePullBackOff"}}},
+		{Role: RoleModel, Content: []FunctionCall{{ID: "2", Name: "kubectl"}, {ID: "3", Name: "bash"}}},
+		{Role: RoleUser, Content: "Tool call blocked"},
+		{Role: RoleUser, Content: FunctionCallResult{ID: "2", Name: "kubectl", Result: map[string]any{"stdout": "nginx:1.99"}}},
+		{Role: RoleModel, Content: "The image tag doesn't exist."},
+		// Saved by the agent: a description of the call, and its result.
+		{Role: RoleModel, Content: "kubectl describe pod nginx"},
+		{Role: RoleUser, Content: map[string]any{"stdout": "Failed to pull image"}},
+		{Role: RoleUser, Content: FunctionCallResult{ID: "9", Name: "kubectl", Result: map[string]any{"stdout": "no call"}}},
+		{Role: "tool", Content: "skipped"},
+	}
+	chat := &GeminiChat{}
+	if err := chat.Initialize(history); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	var got []string
+	for _, content := range chat.history {
+		var parts []string
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				parts = append(parts, "call "+part.FunctionCall.ID)
+			case part.FunctionResponse != nil:
+				parts = append(parts, fmt.Sprintf("result %s %v", part.FunctionResponse.ID, part.FunctionResponse.Response))
+			default:
+				parts = append(parts, part.Text)
+			}
+		}
+		got = append(got, content.Role+": "+strings.Join(parts, " | "))
+	}
+	want := []string{
+		"user: why is nginx down?",
+		"model: call 1",
+		"user: result 1 map[stdout:nginx ImagePullBackOff]",
+		"model: call 2 | call 3",
+		"user: result 3 map[error:The function call was not run. status:skipped] | Tool call blocked | result 2 map[stdout:nginx:1.99]",
+		"model: The image tag doesn't exist. | kubectl describe pod nginx",
+		"user: {\"stdout\":\"Failed to pull image\"} | Result of kubectl:\n{\"stdout\":\"no call\"}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restored history:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -24,8 +24,6 @@ import (
 	openai "github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 	"k8s.io/klog/v2"
-
-	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 )
 
 // Register the Grok provider factory on package initialization.
@@ -44,6 +42,8 @@ func newGrokClientFactory(ctx context.Context, opts ClientOptions) (Client, erro
 // GrokClient implements the gollm.Client interface for X.AI's Grok model.
 type GrokClient struct {
 	client openai.Client
+	// maxOutputTokens caps the tokens of each response, if positive.
+	maxOutputTokens int
 }
 
 // Ensure GrokClient implements the Client interface.
@@ -52,9 +52,9 @@ var _ Client = &GrokClient{}
 // NewGrokClient creates a new client for interacting with X.AI's Grok model.
 // Supports custom HTTP client and skipVerifySSL via ClientOptions.
 func NewGrokClient(ctx context.Context, opts ClientOptions) (*GrokClient, error) {
-	apiKey := os.Getenv("GROK_API_KEY")
+	apiKey := providerAPIKey("grok")
 	if apiKey == "" {
-		return nil, errors.New("GROK_API_KEY environment variable not set")
+		return nil, errors.New("GROK_API_KEY environment variable not set, and no API key stored with `kubectl-ai auth login grok`")
 	}
 
 	// Default API endpoint for X.AI
@@ -68,13 +68,14 @@ func NewGrokClient(ctx context.Context, opts ClientOptions) (*GrokClient, error)
 	}
 
 	// Use the OpenAI client with custom base URL and custom HTTP client
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
+	httpClient := createCustomHTTPClient(opts)
 	return &GrokClient{
 		client: openai.NewClient(
 			option.WithAPIKey(apiKey),
 			option.WithBaseURL(endpoint),
 			option.WithHTTPClient(httpClient),
 		),
+		maxOutputTokens: opts.MaxOutputTokens,
 	}, nil
 }
 
@@ -100,9 +101,10 @@ func (c *GrokClient) StartChat(systemPrompt, model string) Chat {
 	}
 
 	return &grokChatSession{
-		client:  c.client,
-		history: history,
-		model:   model,
+		client:          c.client,
+		history:         history,
+		model:           model,
+		maxOutputTokens: c.maxOutputTokens,
 	}
 }
 
@@ -124,7 +126,9 @@ func (r *simpleGrokCompletionResponse) UsageMetadata() any {
 // GenerateCompletion sends a completion request to the Grok API.
 func (c *GrokClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
 	klog.Infof("Grok GenerateCompletion called with model: %s", req.Model)
-	klog.V(1).Infof("Prompt:\n%s", req.Prompt)
+	if PromptContentLogged(ctx) {
+		klog.V(1).Infof("Prompt:\n%s", req.Prompt)
+	}
 
 	// Use the Chat Completions API as shown in examples
 	chatReq := openai.ChatCompletionNewParams{
@@ -174,6 +178,7 @@ type grokChatSession struct {
 	model               string
 	functionDefinitions []*FunctionDefinition            // Stored in gollm format
 	tools               []openai.ChatCompletionToolParam // Stored in OpenAI format
+	maxOutputTokens     int
 }
 
 // Ensure grokChatSession implements the Chat interface.
@@ -217,25 +222,8 @@ func (cs *grokChatSession) Send(ctx context.Context, contents ...any) (ChatRespo
 	klog.V(1).InfoS("grokChatSession.Send called", "model", cs.model, "history_len", len(cs.history))
 
 	// Append user message(s) to history
-	for _, content := range contents {
-		switch c := content.(type) {
-		case string:
-			klog.V(2).Infof("Adding user message to history: %s", c)
-			cs.history = append(cs.history, openai.UserMessage(c))
-		case FunctionCallResult:
-			klog.V(2).Infof("Adding tool call result to history: Name=%s, ID=%s", c.Name, c.ID)
-			// Marshal the result map into a JSON string for the message content
-			resultJSON, err := json.Marshal(c.Result)
-			if err != nil {
-				klog.Errorf("Failed to marshal function call result: %v", err)
-				return nil, fmt.Errorf("failed to marshal function call result %q: %w", c.Name, err)
-			}
-			cs.history = append(cs.history, openai.ToolMessage(string(resultJSON), c.ID))
-		default:
-			// TODO: Handle other content types if necessary?
-			klog.Warningf("Unhandled content type in Send: %T", content)
-			return nil, fmt.Errorf("unhandled content type: %T", content)
-		}
+	if err := cs.addContentsToHistory(contents); err != nil {
+		return nil, err
 	}
 
 	// Prepare the API request
@@ -247,13 +235,16 @@ func (cs *grokChatSession) Send(ctx context.Context, contents ...any) (ChatRespo
 		chatReq.Tools = cs.tools
 		// chatReq.ToolChoice = openai.ToolChoiceAuto // Or specify if needed
 	}
+	if cs.maxOutputTokens > 0 {
+		chatReq.MaxCompletionTokens = openai.Int(int64(cs.maxOutputTokens))
+	}
 
 	// Call the Grok API
 	klog.V(1).InfoS("Sending request to Grok Chat API", "model", cs.model, "messages", len(chatReq.Messages), "tools", len(chatReq.Tools))
 	completion, err := cs.client.Chat.Completions.New(ctx, chatReq)
 	if err != nil {
 		klog.Errorf("Grok ChatCompletion API error: %v", err)
-		return nil, fmt.Errorf("Grok chat completion failed: %w", err)
+		return nil, fmt.Errorf("Grok chat completion failed: %w", openAIAPIError(err))
 	}
 	klog.V(1).InfoS("Received response from Grok Chat API", "id", completion.ID, "choices", len(completion.Choices))
 
@@ -277,11 +268,14 @@ func (cs *grokChatSession) Send(ctx context.Context, contents ...any) (ChatRespo
 	return resp, nil
 }
 
-// SendStreaming sends the user message(s) and returns an iterator for the LLM response stream.
-func (cs *grokChatSession) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
-	klog.V(1).InfoS("Starting Grok streaming request", "model", cs.model, "streamingEnabled", true)
-
-	// Append user message(s) to history
+// addContentsToHistory appends the user messages and the results of tool
+// calls to the history.
+func (cs *grokChatSession) addContentsToHistory(contents []any) error {
+	// Each tool call of the last response must be answered, in order.
+	contents, err := orderFunctionCallResults(openAIPendingCalls(cs.history), contents)
+	if err != nil {
+		return err
+	}
 	for _, content := range contents {
 		switch c := content.(type) {
 		case string:
@@ -289,17 +283,29 @@ func (cs *grokChatSession) SendStreaming(ctx context.Context, contents ...any) (
 			cs.history = append(cs.history, openai.UserMessage(c))
 		case FunctionCallResult:
 			klog.V(2).Infof("Adding tool call result to history: Name=%s, ID=%s", c.Name, c.ID)
+			// Marshal the result map into a JSON string for the message content
 			resultJSON, err := json.Marshal(c.Result)
 			if err != nil {
 				klog.Errorf("Failed to marshal function call result: %v", err)
-				return nil, fmt.Errorf("failed to marshal function call result %q: %w", c.Name, err)
+				return fmt.Errorf("failed to marshal function call result %q: %w", c.Name, err)
 			}
 			cs.history = append(cs.history, openai.ToolMessage(string(resultJSON), c.ID))
 		default:
-			klog.Warningf("Unhandled content type in SendStreaming: %T", content)
-			return nil, fmt.Errorf("unhandled content type: %T", content)
+			klog.Warningf("Unhandled content type: %T", content)
+			return fmt.Errorf("unhandled content type: %T", content)
 		}
 	}
+	return nil
+}
+
+// SendStreaming sends the user message(s) and returns an iterator for the LLM response stream.
+func (cs *grokChatSession) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	klog.V(1).InfoS("Starting Grok streaming request", "model", cs.model, "streamingEnabled", true)
+
+	// Append user message(s) to history
+	if err := cs.addContentsToHistory(contents); err != nil {
+		return nil, err
+	}
 
 	// Prepare the API request
 	chatReq := openai.ChatCompletionNewParams{
@@ -309,6 +315,9 @@ func (cs *grokChatSession) SendStreaming(ctx context.Context, contents ...any) (
 	if len(cs.tools) > 0 {
 		chatReq.Tools = cs.tools
 	}
+	if cs.maxOutputTokens > 0 {
+		chatReq.MaxCompletionTokens = openai.Int(int64(cs.maxOutputTokens))
+	}
 
 	// Start the Grok streaming request
 	klog.V(1).InfoS("Sending streaming request to Grok API",
@@ -337,6 +346,19 @@ func (cs *grokChatSession) SendStreaming(ctx context.Context, contents ...any) (
 				accumulator: acc,
 			}
 
+			// Tool calls are reported once their arguments are complete, as
+			// they may be streamed over several chunks.
+			if tool, ok := acc.JustFinishedToolCall(); ok {
+				streamResponse.toolCalls = []openai.ChatCompletionMessageToolCall{{
+					ID:   tool.ID,
+					Type: "function",
+					Function: openai.ChatCompletionMessageToolCallFunction{
+						Name:      tool.Name,
+						Arguments: tool.Arguments,
+					},
+				}}
+			}
+
 			// Keep track of the last response to append to history
 			lastResponseChunk = streamResponse
 
@@ -350,7 +372,7 @@ func (cs *grokChatSession) SendStreaming(ctx context.Context, contents ...any) (
 		// Check for errors after streaming completes
 		if err := stream.Err(); err != nil {
 			klog.Errorf("Error in Grok streaming: %v", err)
-			yield(nil, fmt.Errorf("Grok streaming error: %w", err))
+			yield(nil, fmt.Errorf("Grok streaming error: %w", openAIAPIError(err)))
 			return
 		}
 
@@ -380,7 +402,7 @@ func (cs *grokChatSession) IsRetryableError(err error) bool {
 	return DefaultIsRetryableError(err)
 }
 
-func (cs *grokChatSession) Initialize(messages []*api.Message) error {
+func (cs *grokChatSession) Initialize(messages []*Message) error {
 	klog.Warning("chat history persistence is not supported for provider 'grok', using in-memory chat history")
 	return nil
 }
@@ -401,6 +423,14 @@ func (r *grokChatResponse) UsageMetadata() any {
 	return nil
 }
 
+// TokenUsage returns the tokens used by the response.
+func (r *grokChatResponse) TokenUsage() *Usage {
+	if r.grokCompletion == nil {
+		return nil
+	}
+	return openAIUsage(r.grokCompletion.Usage)
+}
+
 func (r *grokChatResponse) Candidates() []Candidate {
 	if r.grokCompletion == nil {
 		return nil
@@ -489,6 +519,8 @@ func (p *grokPart) AsFunctionCalls() ([]FunctionCall, bool) {
 type grokChatStreamResponse struct {
 	streamChunk openai.ChatCompletionChunk
 	accumulator openai.ChatCompletionAccumulator
+	// toolCalls are the tool calls finished with this chunk.
+	toolCalls []openai.ChatCompletionMessageToolCall
 }
 
 // Ensure the streaming response implements ChatResponse interface.
@@ -502,6 +534,11 @@ func (r *grokChatStreamResponse) UsageMetadata() any {
 	return nil
 }
 
+// TokenUsage returns the tokens used by the response, if available in the final chunk.
+func (r *grokChatStreamResponse) TokenUsage() *Usage {
+	return openAIUsage(r.accumulator.Usage)
+}
+
 // Candidates returns a slice with a single streaming candidate.
 func (r *grokChatStreamResponse) Candidates() []Candidate {
 	// Each streaming chunk gets converted to a candidate
@@ -511,7 +548,11 @@ func (r *grokChatStreamResponse) Candidates() []Candidate {
 
 	candidates := make([]Candidate, len(r.streamChunk.Choices))
 	for i, choice := range r.streamChunk.Choices {
-		candidates[i] = &grokStreamCandidate{streamChoice: choice}
+		candidate := &grokStreamCandidate{streamChoice: choice}
+		if i == 0 {
+			candidate.toolCalls = r.toolCalls
+		}
+		candidates[i] = candidate
 	}
 	return candidates
 }
@@ -519,6 +560,8 @@ func (r *grokChatStreamResponse) Candidates() []Candidate {
 // grokStreamCandidate adapts a streaming chunk choice to the Candidate interface.
 type grokStreamCandidate struct {
 	streamChoice openai.ChatCompletionChunkChoice
+	// toolCalls are the tool calls finished with this chunk.
+	toolCalls []openai.ChatCompletionMessageToolCall
 }
 
 // Ensure the streaming candidate implements Candidate interface.
@@ -542,25 +585,9 @@ func (c *grokStreamCandidate) Parts() []Part {
 	}
 
 	// Include tool calls if present
-	if len(c.streamChoice.Delta.ToolCalls) > 0 {
-		// Convert ChatCompletionToolCallDelta to ChatCompletionMessageToolCall
-		toolCalls := make([]openai.ChatCompletionMessageToolCall, 0, len(c.streamChoice.Delta.ToolCalls))
-		for _, delta := range c.streamChoice.Delta.ToolCalls {
-			// Create a new ChatCompletionMessageToolCall directly
-			toolCall := openai.ChatCompletionMessageToolCall{
-				ID: delta.ID,
-				Function: openai.ChatCompletionMessageToolCallFunction{
-					Name:      delta.Function.Name,
-					Arguments: delta.Function.Arguments,
-				},
-				Type: "function", // The type is always "function" for function calls
-			}
-
-			toolCalls = append(toolCalls, toolCall)
-		}
-
+	if len(c.toolCalls) > 0 {
 		parts = append(parts, &grokStreamPart{
-			toolCalls: toolCalls,
+			toolCalls: c.toolCalls,
 		})
 	}
 
@@ -20,8 +20,6 @@ import (
 	"fmt"
 	"io"
 	"iter"
-
-	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 )
 
 // Client is a client for a language model.
@@ -61,7 +59,28 @@ type Chat interface {
 	IsRetryableError(error) bool
 
 	// Initialize initializes the chat with a previous conversation history.
-	Initialize(messages []*api.Message) error
+	Initialize(messages []*Message) error
+}
+
+// Role is the author of a message of a conversation.
+type Role string
+
+const (
+	// RoleUser is the role of the messages sent to the LLM.
+	RoleUser Role = "user"
+	// RoleModel is the role of the responses of the LLM.
+	RoleModel Role = "model"
+)
+
+// Message is a message of a previous conversation, to restore the history of
+// a chat with Chat.Initialize.
+type Message struct {
+	Role Role `json:"role"`
+	// Content is the content of the message, of one of the types accepted by
+	// Chat.Send: a string, a FunctionCallResult or an ImagePart. The messages
+	// of the model can also be a FunctionCall or a []FunctionCall, for the
+	// providers restoring the function calls.
+	Content any `json:"content"`
 }
 
 // CompletionRequest is a request to generate a completion for a given prompt.
@@ -136,6 +155,15 @@ type FunctionCallResult struct {
 	Result map[string]any `json:"result,omitempty"`
 }
 
+// ImagePart is an image sent to the LLM along with the text of a message,
+// for models that accept images (e.g. a screenshot of a dashboard).
+// Providers that don't support images return an error when sending one.
+type ImagePart struct {
+	// MIMEType is the media type of the image, e.g. "image/png".
+	MIMEType string `json:"mimeType,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
 // ChatResponse is a generic chat response from the LLM.
 type ChatResponse interface {
 	UsageMetadata() any
@@ -148,6 +176,26 @@ type ChatResponse interface {
 // ChatResponseIterator is a streaming chat response from the LLM.
 type ChatResponseIterator iter.Seq2[ChatResponse, error]
 
+// Usage is the number of tokens used by a response, in a provider-independent form.
+type Usage struct {
+	// InputTokens is the number of tokens of the prompt, including the history.
+	InputTokens int `json:"inputTokens,omitempty"`
+	// OutputTokens is the number of tokens generated by the LLM, including thinking tokens.
+	OutputTokens int `json:"outputTokens,omitempty"`
+	TotalTokens  int `json:"totalTokens,omitempty"`
+}
+
+// ResponseUsage returns the tokens used by the response, if the provider reports it.
+// When streaming, the usage covers the response so far; depending on the provider,
+// it is reported by every chunk or only by the last one.
+// Responses can report it by implementing a TokenUsage() *Usage method.
+func ResponseUsage(response ChatResponse) *Usage {
+	if r, ok := response.(interface{ TokenUsage() *Usage }); ok {
+		return r.TokenUsage()
+	}
+	return nil
+}
+
 // Candidate is one of a set of candidate response from the LLM.
 type Candidate interface {
 	// String returns a string representation of the candidate.
@@ -157,6 +205,59 @@ type Candidate interface {
 	Parts() []Part
 }
 
+// FinishReason is the reason why the LLM stopped generating a candidate.
+type FinishReason string
+
+const (
+	// FinishReasonUnknown is returned when the provider doesn't report a reason,
+	// or when the candidate is not finished yet (e.g. an intermediate streaming chunk).
+	FinishReasonUnknown FinishReason = ""
+	// FinishReasonStop means the LLM completed its response.
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonMaxTokens means the response was cut off because it reached the
+	// maximum number of output tokens.
+	FinishReasonMaxTokens FinishReason = "max-tokens"
+	// FinishReasonOther covers the other reasons, e.g. safety filters.
+	FinishReasonOther FinishReason = "other"
+)
+
+// CandidateFinishReason returns why the LLM stopped generating the candidate,
+// if the provider reports it.
+// Candidates can report it by implementing a FinishReason() FinishReason method.
+func CandidateFinishReason(candidate Candidate) FinishReason {
+	if c, ok := candidate.(interface{ FinishReason() FinishReason }); ok {
+		return c.FinishReason()
+	}
+	return FinishReasonUnknown
+}
+
+// Citation is a web page the LLM used to ground a candidate, when web search is enabled.
+type Citation struct {
+	Title string `json:"title,omitempty"`
+	URI   string `json:"uri"`
+}
+
+// CandidateCitations returns the web pages the LLM used to ground the candidate,
+// if the provider reports them. When streaming, they are usually only reported by
+// the last chunk.
+// Candidates can report them by implementing a Citations() []Citation method.
+func CandidateCitations(candidate Candidate) []Citation {
+	if c, ok := candidate.(interface{ Citations() []Citation }); ok {
+		return c.Citations()
+	}
+	return nil
+}
+
+// WebSearchEnabled returns true if the client lets the model search the web,
+// i.e. web search was requested with WithWebSearch and the provider supports it.
+// Clients can report it by implementing a WebSearchEnabled() bool method.
+func WebSearchEnabled(client Client) bool {
+	if c, ok := client.(interface{ WebSearchEnabled() bool }); ok {
+		return c.WebSearchEnabled()
+	}
+	return false
+}
+
 // Part is a part of a candidate response from the LLM.
 // It can be a text response, or a function call.
 // A response may comprise multiple parts,
@@ -0,0 +1,123 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/zalando/go-keyring"
+	"k8s.io/klog/v2"
+)
+
+// keyringService is the service under which API keys are stored in the OS
+// keychain (macOS Keychain, Windows Credential Manager or Secret Service),
+// with the provider ID as the account.
+const keyringService = "kubectl-ai"
+
+// apiKeyEnvVars maps the providers authenticating with an API key to the
+// environment variable holding the key.
+var apiKeyEnvVars = map[string]string{
+	"azopenai": "AZURE_OPENAI_API_KEY",
+	"cohere":   "COHERE_API_KEY",
+	"gemini":   "GEMINI_API_KEY",
+	"grok":     "GROK_API_KEY",
+	"openai":   "OPENAI_API_KEY",
+	"watsonx":  "WATSONX_APIKEY",
+}
+
+// APIKeySource is where the API key of a provider is found.
+type APIKeySource string
+
+const (
+	APIKeySourceKeyring APIKeySource = "keyring"
+	APIKeySourceEnv     APIKeySource = "env"
+	APIKeySourceNone    APIKeySource = ""
+)
+
+// APIKeyStatus describes the API key of a provider.
+type APIKeyStatus struct {
+	Provider string
+	// EnvVar is the environment variable read if the key is not in the keyring.
+	EnvVar string
+	Source APIKeySource
+}
+
+// SetAPIKey stores the API key of a provider in the OS keychain.
+func SetAPIKey(provider, key string) error {
+	if _, ok := apiKeyEnvVars[provider]; !ok {
+		return fmt.Errorf("provider %q does not use an API key, supported providers are %v", provider, APIKeyProviders())
+	}
+	if err := keyring.Set(keyringService, provider, key); err != nil {
+		return fmt.Errorf("storing API key of %s in the keyring: %w", provider, err)
+	}
+	return nil
+}
+
+// DeleteAPIKey deletes the API key of a provider from the OS keychain.
+func DeleteAPIKey(provider string) error {
+	if err := keyring.Delete(keyringService, provider); err != nil {
+		if errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("no API key of %s in the keyring", provider)
+		}
+		return fmt.Errorf("deleting API key of %s from the keyring: %w", provider, err)
+	}
+	return nil
+}
+
+// APIKeyProviders returns the IDs of the providers authenticating with an API key.
+func APIKeyProviders() []string {
+	var providers []string
+	for provider := range apiKeyEnvVars {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// ListAPIKeys reports where the API key of each provider is found, without
+// returning the keys.
+func ListAPIKeys() []APIKeyStatus {
+	var statuses []APIKeyStatus
+	for _, provider := range APIKeyProviders() {
+		_, source := lookupAPIKey(provider)
+		statuses = append(statuses, APIKeyStatus{Provider: provider, EnvVar: apiKeyEnvVars[provider], Source: source})
+	}
+	return statuses
+}
+
+// providerAPIKey returns the API key of a provider, from the OS keychain or else
+// from its environment variable.
+func providerAPIKey(provider string) string {
+	key, _ := lookupAPIKey(provider)
+	return key
+}
+
+func lookupAPIKey(provider string) (string, APIKeySource) {
+	key, err := keyring.Get(keyringService, provider)
+	switch {
+	case err == nil && key != "":
+		return key, APIKeySourceKeyring
+	case err != nil && !errors.Is(err, keyring.ErrNotFound):
+		// e.g. no Secret Service on a headless machine, the environment is still used.
+		klog.V(2).Infof("reading API key of %s from the keyring: %v", provider, err)
+	}
+	if key := os.Getenv(apiKeyEnvVars[provider]); key != "" {
+		return key, APIKeySourceEnv
+	}
+	return "", APIKeySourceNone
+}
@@ -0,0 +1,80 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestProviderAPIKey(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("GEMINI_API_KEY", "env-gemini")
+	t.Setenv("GROK_API_KEY", "env-grok")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("AZURE_OPENAI_API_KEY", "")
+	t.Setenv("COHERE_API_KEY", "")
+	t.Setenv("WATSONX_APIKEY", "env-watsonx")
+
+	if err := SetAPIKey("gemini", "keyring-gemini"); err != nil {
+		t.Fatalf("SetAPIKey() error: %v", err)
+	}
+	if err := SetAPIKey("openai", "keyring-openai"); err != nil {
+		t.Fatalf("SetAPIKey() error: %v", err)
+	}
+	if err := SetAPIKey("ollama", "key"); err == nil {
+		t.Errorf("SetAPIKey(ollama): want error, ollama doesn't use an API key")
+	}
+
+	tests := []struct {
+		provider string
+		want     string
+	}{
+		{provider: "gemini", want: "keyring-gemini"}, // the keyring takes precedence
+		{provider: "openai", want: "keyring-openai"},
+		{provider: "grok", want: "env-grok"},
+		{provider: "azopenai", want: ""},
+		{provider: "watsonx", want: "env-watsonx"},
+	}
+	for _, tt := range tests {
+		if got := providerAPIKey(tt.provider); got != tt.want {
+			t.Errorf("providerAPIKey(%q) = %q, want %q", tt.provider, got, tt.want)
+		}
+	}
+
+	want := []APIKeyStatus{
+		{Provider: "azopenai", EnvVar: "AZURE_OPENAI_API_KEY", Source: APIKeySourceNone},
+		{Provider: "cohere", EnvVar: "COHERE_API_KEY", Source: APIKeySourceNone},
+		{Provider: "gemini", EnvVar: "GEMINI_API_KEY", Source: APIKeySourceKeyring},
+		{Provider: "grok", EnvVar: "GROK_API_KEY", Source: APIKeySourceEnv},
+		{Provider: "openai", EnvVar: "OPENAI_API_KEY", Source: APIKeySourceKeyring},
+		{Provider: "watsonx", EnvVar: "WATSONX_APIKEY", Source: APIKeySourceEnv},
+	}
+	if got := ListAPIKeys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListAPIKeys() = %+v, want %+v", got, want)
+	}
+
+	if err := DeleteAPIKey("gemini"); err != nil {
+		t.Fatalf("DeleteAPIKey() error: %v", err)
+	}
+	if got := providerAPIKey("gemini"); got != "env-gemini" {
+		t.Errorf("providerAPIKey(gemini) after DeleteAPIKey = %q, want the env var", got)
+	}
+	if err := DeleteAPIKey("gemini"); err == nil {
+		t.Errorf("DeleteAPIKey() of a missing key: want error")
+	}
+}
@@ -25,8 +25,6 @@ import (
 	"os"
 
 	"k8s.io/klog/v2"
-
-	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 )
 
 func init() {
@@ -45,6 +43,8 @@ type LlamaCppClient struct {
 	baseURL        *url.URL
 	httpClient     *http.Client
 	responseSchema *llamacppSchema
+	// maxOutputTokens caps the tokens of each response, if positive.
+	maxOutputTokens int
 }
 
 type LlamaCppChat struct {
@@ -70,11 +70,12 @@ func NewLlamaCppClient(ctx context.Context, opts ClientOptions) (*LlamaCppClient
 	}
 	klog.Infof("using llama.cpp with base url %v", baseURL.String())
 
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
+	httpClient := createCustomHTTPClient(opts)
 
 	return &LlamaCppClient{
-		baseURL:    baseURL,
-		httpClient: httpClient,
+		baseURL:         baseURL,
+		httpClient:      httpClient,
+		maxOutputTokens: opts.MaxOutputTokens,
 	}, nil
 }
 
@@ -107,7 +108,9 @@ func (c *LlamaCppClient) doRequest(ctx context.Context, httpMethod, relativePath
 		return fmt.Errorf("building json body: %w", err)
 	}
 	u := c.baseURL.JoinPath(relativePath)
-	klog.V(2).Infof("sending %s request to %v: %v", httpMethod, u.String(), string(body))
+	if PromptContentLogged(ctx) {
+		klog.V(2).Infof("sending %s request to %v: %v", httpMethod, u.String(), string(body))
+	}
 	httpRequest, err := http.NewRequestWithContext(ctx, httpMethod, u.String(), bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("building http request: %w", err)
@@ -126,7 +129,7 @@ func (c *LlamaCppClient) doRequest(ctx context.Context, httpMethod, relativePath
 	}
 
 	if httpResponse.StatusCode != 200 {
-		return fmt.Errorf("unexpected http status: %q with response %q", httpResponse.Status, string(b))
+		return &APIError{StatusCode: httpResponse.StatusCode, Message: string(bytes.TrimSpace(b))}
 	}
 
 	if err := json.Unmarshal(b, response); err != nil {
@@ -189,6 +192,12 @@ func (r *LlamaCppCompletionResponse) UsageMetadata() any {
 
 func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
 	log := klog.FromContext(ctx)
+	// Each tool call of the last response must be answered, in order. llama.cpp
+	// doesn't give IDs to tool calls, results are matched by name.
+	contents, err := orderFunctionCallResults(llamacppPendingCalls(c.history), contents)
+	if err != nil {
+		return nil, err
+	}
 	for _, content := range contents {
 		switch v := content.(type) {
 		case string:
@@ -218,7 +227,11 @@ func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 		Model:    c.model,
 		Messages: c.history,
 		// Stream:   ptrTo(false),
-		Tools: c.tools,
+		Tools:       c.tools,
+		CachePrompt: true,
+	}
+	if c.client.maxOutputTokens > 0 {
+		req.MaxTokens = ptrTo(c.client.maxOutputTokens)
 	}
 
 	var llmacppResponse *LlamaCppChatResponse
@@ -228,7 +241,9 @@ func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 		return nil, err
 	}
 
-	log.V(2).Info("received response from llama.cpp", "resp", resp)
+	if PromptContentLogged(ctx) {
+		log.V(2).Info("received response from llama.cpp", "resp", resp)
+	}
 	llmacppResponse = &LlamaCppChatResponse{
 		LlamaCppResponse: *resp,
 	}
@@ -281,6 +296,19 @@ func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 	return llmacppResponse, nil
 }
 
+// llamacppPendingCalls returns the tool calls of the last message of the
+// history, if it is a response of the model.
+func llamacppPendingCalls(history []llamacppChatMessage) []FunctionCall {
+	if len(history) == 0 || history[len(history)-1].Role != "assistant" {
+		return nil
+	}
+	var calls []FunctionCall
+	for _, toolCall := range history[len(history)-1].ToolCalls {
+		calls = append(calls, FunctionCall{Name: toolCall.Function.Name})
+	}
+	return calls
+}
+
 func (c *LlamaCppChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
 	// TODO: Implement streaming
 	response, err := c.Send(ctx, contents...)
@@ -291,11 +319,10 @@ func (c *LlamaCppChat) SendStreaming(ctx context.Context, contents ...any) (Chat
 }
 
 func (c *LlamaCppChat) IsRetryableError(err error) bool {
-	// TODO(droot): Implement this
-	return false
+	return DefaultIsRetryableError(err)
 }
 
-func (c *LlamaCppChat) Initialize(messages []*api.Message) error {
+func (c *LlamaCppChat) Initialize(messages []*Message) error {
 	klog.Warning("chat history persistence is not supported for provider 'llamacpp', using in-memory chat history")
 	return nil
 }
@@ -482,9 +509,12 @@ type llamacppTimings struct {
 }
 
 type llamacppChatRequest struct {
-	Model    string                `json:"model,omitempty"`
-	Messages []llamacppChatMessage `json:"messages,omitempty"`
-	Tools    []llamacppTool        `json:"tools,omitempty"`
+	Model     string                `json:"model,omitempty"`
+	Messages  []llamacppChatMessage `json:"messages,omitempty"`
+	Tools     []llamacppTool        `json:"tools,omitempty"`
+	MaxTokens *int                  `json:"max_tokens,omitempty"`
+	// CachePrompt reuses the KV cache of the common prefix with the previous request.
+	CachePrompt bool `json:"cache_prompt,omitempty"`
 }
 
 type llamacppChatResponse struct {
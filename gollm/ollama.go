@@ -17,13 +17,12 @@ package gollm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/ollama/ollama/api"
 	"github.com/ollama/ollama/envconfig"
 	"k8s.io/klog/v2"
-
-	kctlApi "github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 )
 
 func init() {
@@ -44,6 +43,8 @@ const (
 
 type OllamaClient struct {
 	client *api.Client
+	// maxOutputTokens caps the tokens of each response, if positive.
+	maxOutputTokens int
 }
 
 type OllamaChat struct {
@@ -51,6 +52,8 @@ type OllamaChat struct {
 	model   string
 	history []api.Message
 	tools   []api.Tool
+
+	maxOutputTokens int
 }
 
 var _ Client = &OllamaClient{}
@@ -59,11 +62,12 @@ var _ Client = &OllamaClient{}
 // Supports custom HTTP client and skipVerifySSL via ClientOptions if the SDK supports it.
 func NewOllamaClient(ctx context.Context, opts ClientOptions) (*OllamaClient, error) {
 	// Create custom HTTP client with SSL verification option from client options
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
+	httpClient := createCustomHTTPClient(opts)
 	client := api.NewClient(envconfig.Host(), httpClient)
 
 	return &OllamaClient{
-		client: client,
+		client:          client,
+		maxOutputTokens: opts.MaxOutputTokens,
 	}, nil
 }
 
@@ -113,8 +117,9 @@ func (c *OllamaClient) SetResponseSchema(schema *Schema) error {
 
 func (c *OllamaClient) StartChat(systemPrompt, model string) Chat {
 	return &OllamaChat{
-		client: c.client,
-		model:  model,
+		client:          c.client,
+		model:           model,
+		maxOutputTokens: c.maxOutputTokens,
 		history: []api.Message{
 			{
 				Role:    "system",
@@ -164,11 +169,16 @@ func (c *OllamaChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 		Stream: new(bool),
 		Tools:  c.tools,
 	}
+	if c.maxOutputTokens > 0 {
+		req.Options = map[string]any{"num_predict": c.maxOutputTokens}
+	}
 
 	var ollamaResponse *OllamaChatResponse
 
 	respFunc := func(resp api.ChatResponse) error {
-		log.Info("received response from ollama", "resp", resp)
+		if PromptContentLogged(ctx) {
+			log.Info("received response from ollama", "resp", resp)
+		}
 		ollamaResponse = &OllamaChatResponse{
 			ollamaResponse: resp,
 			candidates: []*OllamaCandidate{
@@ -191,13 +201,18 @@ func (c *OllamaChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 		return nil, err
 	}
 
-	log.Info("ollama response", "parsed_response", ollamaResponse)
+	if PromptContentLogged(ctx) {
+		log.Info("ollama response", "parsed_response", ollamaResponse)
+	}
 	return ollamaResponse, nil
 }
 
 func (c *OllamaChat) IsRetryableError(err error) bool {
-	// TODO(droot): Implement this
-	return false
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.StatusCode)
+	}
+	return DefaultIsRetryableError(err)
 }
 
 func (c *OllamaChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
@@ -209,7 +224,7 @@ func (c *OllamaChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 	return singletonChatResponseIterator(response), nil
 }
 
-func (c *OllamaChat) Initialize(messages []*kctlApi.Message) error {
+func (c *OllamaChat) Initialize(messages []*Message) error {
 	klog.Warning("chat history persistence is not supported for provider 'ollama', using in-memory chat history")
 	return nil
 }
@@ -16,38 +16,52 @@ package gollm
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	openai "github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 	"k8s.io/klog/v2"
-
-	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 )
 
 // Package-level env var storage (OpenAI env)
 var (
-	openAIAPIKey   string
-	openAIEndpoint string
-	openAIAPIBase  string
-	openAIModel    string
+	openAIEndpoint     string
+	openAIAPIBase      string
+	openAIModel        string
+	openAIOrganization string
+	openAIProject      string
+	openAIUser         string
 )
 
 // init reads and caches OpenAI environment variables:
-//   - OPENAI_API_KEY, OPENAI_ENDPOINT, OPENAI_API_BASE, OPENAI_MODEL
+//   - OPENAI_ENDPOINT, OPENAI_API_BASE, OPENAI_MODEL
+//   - OPENAI_ORG_ID, OPENAI_PROJECT (or OPENAI_PROJECT_ID), sent as headers
+//     to attribute the usage to an organization and a project
+//   - OPENAI_USER, the identifier of the end user sent with each request for
+//     abuse monitoring
 //
+// OPENAI_API_KEY is read when creating a client, after the OS keychain.
 // These serve as defaults; the model can be overridden by the Cobra --model flag.
 // After loading env values, it registers the OpenAI provider factory.
 func init() {
 	// Load environment variables
-	openAIAPIKey = os.Getenv("OPENAI_API_KEY")
 	openAIEndpoint = os.Getenv("OPENAI_ENDPOINT")
 	openAIAPIBase = os.Getenv("OPENAI_API_BASE")
 	openAIModel = os.Getenv("OPENAI_MODEL")
+	openAIOrganization = os.Getenv("OPENAI_ORG_ID")
+	openAIProject = os.Getenv("OPENAI_PROJECT")
+	if openAIProject == "" {
+		openAIProject = os.Getenv("OPENAI_PROJECT_ID")
+	}
+	openAIUser = os.Getenv("OPENAI_USER")
 
 	// Register "openai" as the provider ID
 	if err := RegisterProvider("openai", newOpenAIClientFactory); err != nil {
@@ -66,6 +80,12 @@ func init() {
 // OpenAIClient implements the gollm.Client interface for OpenAI models.
 type OpenAIClient struct {
 	client openai.Client
+	// webSearch enables the web search of the search models, e.g. gpt-4o-search-preview.
+	webSearch bool
+	// maxOutputTokens caps the tokens of each response, if positive.
+	maxOutputTokens int
+	// user identifies the end user in each request, if set.
+	user string
 }
 
 // Ensure OpenAIClient implements the Client interface.
@@ -74,10 +94,10 @@ var _ Client = &OpenAIClient{}
 // NewOpenAIClient creates a new client for interacting with OpenAI.
 // Supports custom HTTP client (e.g., for skipping SSL verification).
 func NewOpenAIClient(ctx context.Context, opts ClientOptions) (*OpenAIClient, error) {
-	// Get API key from loaded env var
-	apiKey := openAIAPIKey
+	// Get API key from the keyring, or else from OPENAI_API_KEY
+	apiKey := providerAPIKey("openai")
 	if apiKey == "" {
-		return nil, errors.New("OpenAI API key not found. Set via OPENAI_API_KEY env var")
+		return nil, errors.New("OpenAI API key not found. Set via OPENAI_API_KEY env var or `kubectl-ai auth login openai`")
 	}
 
 	// Set options for client creation
@@ -93,13 +113,22 @@ func NewOpenAIClient(ctx context.Context, opts ClientOptions) (*OpenAIClient, er
 		klog.Infof("Using custom OpenAI base URL: %s", baseURL)
 		options = append(options, option.WithBaseURL(baseURL))
 	}
+	if openAIOrganization != "" {
+		options = append(options, option.WithOrganization(openAIOrganization))
+	}
+	if openAIProject != "" {
+		options = append(options, option.WithProject(openAIProject))
+	}
 
 	// Support custom HTTP client (e.g., skip SSL verification)
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
+	httpClient := createCustomHTTPClient(opts)
 	options = append(options, option.WithHTTPClient(httpClient))
 
 	return &OpenAIClient{
-		client: openai.NewClient(options...),
+		client:          openai.NewClient(options...),
+		webSearch:       opts.WebSearch,
+		maxOutputTokens: opts.MaxOutputTokens,
+		user:            openAIUser,
 	}, nil
 }
 
@@ -109,6 +138,11 @@ func (c *OpenAIClient) Close() error {
 	return nil
 }
 
+// WebSearchEnabled returns true if chats ask the model to search the web.
+func (c *OpenAIClient) WebSearchEnabled() bool {
+	return c.webSearch
+}
+
 // StartChat starts a new chat session.
 func (c *OpenAIClient) StartChat(systemPrompt, model string) Chat {
 	// Get the model to use for this chat
@@ -123,9 +157,12 @@ func (c *OpenAIClient) StartChat(systemPrompt, model string) Chat {
 	}
 
 	return &openAIChatSession{
-		client:  c.client,
-		history: history,
-		model:   selectedModel,
+		client:          c.client,
+		history:         history,
+		model:           selectedModel,
+		webSearch:       c.webSearch,
+		maxOutputTokens: c.maxOutputTokens,
+		user:            c.user,
 		// functionDefinitions and tools will be set later via SetFunctionDefinitions
 	}
 }
@@ -148,18 +185,24 @@ func (r *simpleCompletionResponse) UsageMetadata() any {
 // GenerateCompletion sends a completion request to the OpenAI API.
 func (c *OpenAIClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
 	klog.Infof("OpenAI GenerateCompletion called with model: %s", req.Model)
-	klog.V(1).Infof("Prompt:\n%s", req.Prompt)
+	if PromptContentLogged(ctx) {
+		klog.V(1).Infof("Prompt:\n%s", req.Prompt)
+	}
 
 	// Use the Chat Completions API with the new v1.0.0 API
-	completion, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+	params := openai.ChatCompletionNewParams{
 		Model: openai.ChatModel(req.Model),
 		Messages: []openai.ChatCompletionMessageParamUnion{
 			openai.UserMessage(req.Prompt),
 		},
-	})
+	}
+	if c.user != "" {
+		params.User = openai.String(c.user)
+	}
+	completion, err := c.client.Chat.Completions.New(ctx, params)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate OpenAI completion: %w", err)
+		return nil, fmt.Errorf("failed to generate OpenAI completion: %w", openAIAPIError(err))
 	}
 
 	// Check if there are choices and a message
@@ -206,6 +249,9 @@ type openAIChatSession struct {
 	model               string
 	functionDefinitions []*FunctionDefinition            // Stored in gollm format
 	tools               []openai.ChatCompletionToolParam // Stored in OpenAI format
+	webSearch           bool
+	maxOutputTokens     int
+	user                string
 }
 
 // Ensure openAIChatSession implements the Chat interface.
@@ -256,14 +302,22 @@ func (cs *openAIChatSession) Send(ctx context.Context, contents ...any) (ChatRes
 	if len(cs.tools) > 0 {
 		chatReq.Tools = cs.tools
 	}
+	if cs.webSearch {
+		chatReq.WebSearchOptions = openAIWebSearchOptions()
+	}
+	if cs.maxOutputTokens > 0 {
+		chatReq.MaxCompletionTokens = openai.Int(int64(cs.maxOutputTokens))
+	}
+	if cs.user != "" {
+		chatReq.User = openai.String(cs.user)
+	}
 
 	// Call the OpenAI API
 	klog.V(1).InfoS("Sending request to OpenAI Chat API", "model", cs.model, "messages", len(chatReq.Messages), "tools", len(chatReq.Tools))
 	completion, err := cs.client.Chat.Completions.New(ctx, chatReq)
 	if err != nil {
-		// TODO: Check if error is retryable using cs.IsRetryableError
 		klog.Errorf("OpenAI ChatCompletion API error: %v", err)
-		return nil, fmt.Errorf("OpenAI chat completion failed: %w", err)
+		return nil, fmt.Errorf("OpenAI chat completion failed: %w", openAIAPIError(err))
 	}
 	klog.V(1).InfoS("Received response from OpenAI Chat API", "id", completion.ID, "choices", len(completion.Choices))
 
@@ -300,10 +354,23 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 	chatReq := openai.ChatCompletionNewParams{
 		Model:    openai.ChatModel(cs.model),
 		Messages: cs.history,
+		// Ask for a last chunk reporting the token usage of the response.
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{
+			IncludeUsage: openai.Bool(true),
+		},
 	}
 	if len(cs.tools) > 0 {
 		chatReq.Tools = cs.tools
 	}
+	if cs.webSearch {
+		chatReq.WebSearchOptions = openAIWebSearchOptions()
+	}
+	if cs.maxOutputTokens > 0 {
+		chatReq.MaxCompletionTokens = openai.Int(int64(cs.maxOutputTokens))
+	}
+	if cs.user != "" {
+		chatReq.User = openai.String(cs.user)
+	}
 
 	// Start the OpenAI streaming request
 	klog.V(1).InfoS("Sending streaming request to OpenAI API",
@@ -373,6 +440,7 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 					currentContent.WriteString(delta.Content)
 					streamResponse.content = delta.Content // Only set content if there's new content
 				}
+				streamResponse.citations = openAIDeltaCitations(delta)
 			}
 
 			// Keep track of the last response for history
@@ -383,8 +451,10 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 				toolCalls:   currentToolCalls,
 			}
 
-			// Only yield if there's actual content or tool calls to report
-			if streamResponse.content != "" || len(streamResponse.toolCalls) > 0 {
+			// Only yield if there's actual content, tool calls or citations to report,
+			// or to report why the response finished or its usage.
+			finished := len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != ""
+			if streamResponse.content != "" || len(streamResponse.toolCalls) > 0 || len(streamResponse.citations) > 0 || finished || chunk.Usage.TotalTokens > 0 {
 				if !yield(streamResponse, nil) {
 					return
 				}
@@ -394,7 +464,7 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 		// Check for errors after streaming completes
 		if err := stream.Err(); err != nil {
 			klog.Errorf("Error in OpenAI streaming: %v", err)
-			yield(nil, fmt.Errorf("OpenAI streaming error: %w", err))
+			yield(nil, fmt.Errorf("OpenAI streaming error: %w", openAIAPIError(err)))
 			return
 		}
 
@@ -416,14 +486,67 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 }
 
 // IsRetryableError determines if an error from the OpenAI API should be retried.
+// Requests exceeding the quota of the account are not, as retrying doesn't
+// help until the billing or the usage tier of the account changes.
 func (cs *openAIChatSession) IsRetryableError(err error) bool {
 	if err == nil {
 		return false
 	}
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.Code == "insufficient_quota" {
+		return false
+	}
 	return DefaultIsRetryableError(err)
 }
 
-func (cs *openAIChatSession) Initialize(messages []*api.Message) error {
+// openAIAPIError converts an error of the OpenAI API to an *APIError, with the
+// rate limits of the account and the delay before retrying reported by the
+// headers of the response.
+func openAIAPIError(err error) error {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.Response == nil {
+		return err
+	}
+	header := apiErr.Response.Header
+	result := &APIError{StatusCode: apiErr.StatusCode, Message: apiErr.Message, Err: err}
+	if apiErr.StatusCode == http.StatusTooManyRequests {
+		var limits []string
+		for _, resource := range []string{"requests", "tokens"} {
+			if limit := header.Get("x-ratelimit-limit-" + resource); limit != "" {
+				limits = append(limits, limit+" "+resource)
+			}
+		}
+		if len(limits) > 0 {
+			result.Message += " (rate limit of the account: " + strings.Join(limits, ", ") + ")"
+		}
+	}
+	result.RetryAfter = openAIRetryAfter(header)
+	return result
+}
+
+// openAIRetryAfter returns the delay before retrying a request, from the
+// retry-after headers, or else the reset time of the exhausted rate limits.
+func openAIRetryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	if seconds, err := strconv.ParseFloat(header.Get("retry-after"), 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	var retryAfter time.Duration
+	for _, resource := range []string{"requests", "tokens"} {
+		if header.Get("x-ratelimit-remaining-"+resource) != "0" {
+			continue
+		}
+		// Reset times are formatted as durations, e.g. 1s or 6m0s.
+		if reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + resource)); err == nil {
+			retryAfter = max(retryAfter, reset)
+		}
+	}
+	return retryAfter
+}
+
+func (cs *openAIChatSession) Initialize(messages []*Message) error {
 	klog.Warning("chat history persistence is not supported for provider 'openai', using in-memory chat history")
 	return nil
 }
@@ -444,6 +567,27 @@ func (r *openAIChatResponse) UsageMetadata() any {
 	return nil
 }
 
+// TokenUsage returns the tokens used by the response.
+func (r *openAIChatResponse) TokenUsage() *Usage {
+	if r.openaiCompletion == nil {
+		return nil
+	}
+	return openAIUsage(r.openaiCompletion.Usage)
+}
+
+// openAIUsage converts the usage reported by OpenAI-compatible APIs,
+// which is zero when not reported.
+func openAIUsage(usage openai.CompletionUsage) *Usage {
+	if usage.TotalTokens == 0 {
+		return nil
+	}
+	return &Usage{
+		InputTokens:  int(usage.PromptTokens),
+		OutputTokens: int(usage.CompletionTokens),
+		TotalTokens:  int(usage.TotalTokens),
+	}
+}
+
 func (r *openAIChatResponse) Candidates() []Candidate {
 	if r.openaiCompletion == nil {
 		return nil
@@ -478,6 +622,69 @@ func (c *openAICandidate) Parts() []Part {
 	return parts
 }
 
+// Citations returns the web pages cited by the candidate, when web search is enabled.
+func (c *openAICandidate) Citations() []Citation {
+	if c.openaiChoice == nil {
+		return nil
+	}
+	return openAICitations(c.openaiChoice.Message.Annotations)
+}
+
+// openAIWebSearchOptions enables the web search of the search models. The
+// options must not be empty to be sent, so the default context size is set.
+func openAIWebSearchOptions() openai.ChatCompletionNewParamsWebSearchOptions {
+	return openai.ChatCompletionNewParamsWebSearchOptions{SearchContextSize: "medium"}
+}
+
+// openAICitations converts the URL citations of a message.
+func openAICitations(annotations []openai.ChatCompletionMessageAnnotation) []Citation {
+	var citations []Citation
+	for _, annotation := range annotations {
+		if annotation.URLCitation.URL != "" {
+			citations = append(citations, Citation{Title: annotation.URLCitation.Title, URI: annotation.URLCitation.URL})
+		}
+	}
+	return citations
+}
+
+// openAIDeltaCitations returns the URL citations of a streamed delta. The SDK
+// doesn't decode the annotations of deltas, they are parsed from the raw JSON.
+func openAIDeltaCitations(delta openai.ChatCompletionChunkChoiceDelta) []Citation {
+	if _, ok := delta.JSON.ExtraFields["annotations"]; !ok {
+		return nil
+	}
+	var raw struct {
+		Annotations []openai.ChatCompletionMessageAnnotation `json:"annotations"`
+	}
+	if err := json.Unmarshal([]byte(delta.RawJSON()), &raw); err != nil {
+		klog.V(2).Infof("ignoring invalid annotations of streamed delta: %v", err)
+		return nil
+	}
+	return openAICitations(raw.Annotations)
+}
+
+// FinishReason returns why the model stopped generating the candidate.
+func (c *openAICandidate) FinishReason() FinishReason {
+	if c.openaiChoice == nil {
+		return FinishReasonUnknown
+	}
+	return openAIFinishReason(c.openaiChoice.FinishReason)
+}
+
+// openAIFinishReason converts an OpenAI finish reason.
+func openAIFinishReason(reason string) FinishReason {
+	switch reason {
+	case "":
+		return FinishReasonUnknown
+	case "stop", "tool_calls", "function_call":
+		return FinishReasonStop
+	case "length":
+		return FinishReasonMaxTokens
+	default:
+		return FinishReasonOther
+	}
+}
+
 // String provides a simple string representation for logging/debugging.
 func (c *openAICandidate) String() string {
 	if c.openaiChoice == nil {
@@ -513,11 +720,17 @@ type openAIChatStreamResponse struct {
 	accumulator openai.ChatCompletionAccumulator
 	content     string
 	toolCalls   []openai.ChatCompletionMessageToolCall
+	// citations are the URL citations of the chunk.
+	citations []Citation
 }
 
 // Update Candidates() to use accumulated content
 func (r *openAIChatStreamResponse) Candidates() []Candidate {
 	if len(r.streamChunk.Choices) == 0 {
+		if r.streamChunk.Usage.TotalTokens > 0 {
+			// The last chunk only reports the usage, with an empty candidate.
+			return []Candidate{&openAIStreamCandidate{}}
+		}
 		return nil
 	}
 
@@ -527,6 +740,7 @@ func (r *openAIChatStreamResponse) Candidates() []Candidate {
 			streamChoice: choice,
 			content:      r.content,
 			toolCalls:    r.toolCalls,
+			citations:    r.citations,
 		}
 	}
 	return candidates
@@ -537,6 +751,7 @@ type openAIStreamCandidate struct {
 	streamChoice openai.ChatCompletionChunkChoice
 	content      string // This will now be just the delta content
 	toolCalls    []openai.ChatCompletionMessageToolCall
+	citations    []Citation
 }
 
 // Update Parts() to handle delta content
@@ -560,6 +775,16 @@ func (c *openAIStreamCandidate) Parts() []Part {
 	return parts
 }
 
+// Citations returns the URL citations of the chunk.
+func (c *openAIStreamCandidate) Citations() []Citation {
+	return c.citations
+}
+
+// FinishReason is only set on the last chunk of the stream.
+func (c *openAIStreamCandidate) FinishReason() FinishReason {
+	return openAIFinishReason(c.streamChoice.FinishReason)
+}
+
 // Add UsageMetadata implementation
 func (r *openAIChatStreamResponse) UsageMetadata() any {
 	if r.accumulator.Usage.TotalTokens > 0 {
@@ -568,6 +793,11 @@ func (r *openAIChatStreamResponse) UsageMetadata() any {
 	return nil
 }
 
+// TokenUsage is only reported by the last chunk of the stream.
+func (r *openAIChatStreamResponse) TokenUsage() *Usage {
+	return openAIUsage(r.accumulator.Usage)
+}
+
 // Add String implementation
 func (c *openAIStreamCandidate) String() string {
 	return fmt.Sprintf("StreamingCandidate(Content: %q, ToolCalls: %d)",
@@ -768,6 +998,11 @@ func newOpenAIClientFactory(ctx context.Context, opts ClientOptions) (Client, er
 
 // addContentsToHistory processes and appends user messages to chat history
 func (cs *openAIChatSession) addContentsToHistory(contents []any) error {
+	// Each tool call of the last response must be answered, in order.
+	contents, err := orderFunctionCallResults(openAIPendingCalls(cs.history), contents)
+	if err != nil {
+		return err
+	}
 	for _, content := range contents {
 		switch c := content.(type) {
 		case string:
@@ -782,6 +1017,12 @@ func (cs *openAIChatSession) addContentsToHistory(contents []any) error {
 				return fmt.Errorf("failed to marshal function call result %q: %w", c.Name, err)
 			}
 			cs.history = append(cs.history, openai.ToolMessage(string(resultJSON), c.ID))
+		case ImagePart:
+			klog.V(2).Infof("Adding image to history: %s (%d bytes)", c.MIMEType, len(c.Data))
+			dataURL := "data:" + c.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(c.Data)
+			cs.history = append(cs.history, openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: dataURL}),
+			}))
 		default:
 			klog.Warningf("Unhandled content type: %T", content)
 			return fmt.Errorf("unhandled content type: %T", content)
@@ -790,6 +1031,19 @@ func (cs *openAIChatSession) addContentsToHistory(contents []any) error {
 	return nil
 }
 
+// openAIPendingCalls returns the tool calls of the last message of the
+// history, if it is a response of the model.
+func openAIPendingCalls(history []openai.ChatCompletionMessageParamUnion) []FunctionCall {
+	if len(history) == 0 || history[len(history)-1].OfAssistant == nil {
+		return nil
+	}
+	var calls []FunctionCall
+	for _, tc := range history[len(history)-1].OfAssistant.ToolCalls {
+		calls = append(calls, FunctionCall{ID: tc.ID, Name: tc.Function.Name})
+	}
+	return calls
+}
+
 // convertToolCallsToFunctionCalls converts OpenAI tool calls to gollm function calls
 func convertToolCallsToFunctionCalls(toolCalls []openai.ChatCompletionMessageToolCall) ([]FunctionCall, bool) {
 	if len(toolCalls) == 0 {
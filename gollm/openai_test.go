@@ -15,10 +15,18 @@
 package gollm
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
 )
 
 func TestConvertSchemaForOpenAI(t *testing.T) {
@@ -604,3 +612,173 @@ func TestConvertToolCallsToFunctionCalls(t *testing.T) {
 		})
 	}
 }
+
+func TestAddContentsToHistoryImage(t *testing.T) {
+	cs := &openAIChatSession{}
+	if err := cs.addContentsToHistory([]any{"what's wrong here?", ImagePart{MIMEType: "image/png", Data: []byte("png")}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cs.history) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(cs.history))
+	}
+	user := cs.history[1].OfUser
+	if user == nil || len(user.Content.OfArrayOfContentParts) != 1 {
+		t.Fatalf("expected a user message with one content part, got %+v", cs.history[1])
+	}
+	image := user.Content.OfArrayOfContentParts[0].OfImageURL
+	if image == nil {
+		t.Fatalf("expected an image content part")
+	}
+	if expected := "data:image/png;base64,cG5n"; image.ImageURL.URL != expected {
+		t.Errorf("expected URL %q, got %q", expected, image.ImageURL.URL)
+	}
+}
+
+func TestOpenAIFinishReason(t *testing.T) {
+	tests := []struct {
+		reason   string
+		expected FinishReason
+	}{
+		{reason: "", expected: FinishReasonUnknown},
+		{reason: "stop", expected: FinishReasonStop},
+		{reason: "tool_calls", expected: FinishReasonStop},
+		{reason: "length", expected: FinishReasonMaxTokens},
+		{reason: "content_filter", expected: FinishReasonOther},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.reason, func(t *testing.T) {
+			candidate := &openAIStreamCandidate{streamChoice: openai.ChatCompletionChunkChoice{FinishReason: tc.reason}}
+			if got := CandidateFinishReason(candidate); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamUsage(t *testing.T) {
+	tests := []struct {
+		name           string
+		chunk          openai.ChatCompletionChunk
+		wantUsage      *Usage
+		wantCandidates int
+	}{
+		{
+			name:           "content chunk",
+			chunk:          openai.ChatCompletionChunk{Choices: []openai.ChatCompletionChunkChoice{{Delta: openai.ChatCompletionChunkChoiceDelta{Content: "hi"}}}},
+			wantCandidates: 1,
+		},
+		{
+			name:           "usage chunk",
+			chunk:          openai.ChatCompletionChunk{Usage: openai.CompletionUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}},
+			wantUsage:      &Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+			wantCandidates: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			acc := openai.ChatCompletionAccumulator{}
+			acc.AddChunk(tc.chunk)
+			response := &openAIChatStreamResponse{streamChunk: tc.chunk, accumulator: acc}
+
+			if got := ResponseUsage(response); !reflect.DeepEqual(got, tc.wantUsage) {
+				t.Errorf("expected usage %+v, got %+v", tc.wantUsage, got)
+			}
+			if got := len(response.Candidates()); got != tc.wantCandidates {
+				t.Errorf("expected %d candidates, got %d", tc.wantCandidates, got)
+			}
+		})
+	}
+}
+
+func TestOpenAIDeltaCitations(t *testing.T) {
+	tests := []struct {
+		name  string
+		delta string
+		want  []Citation
+	}{
+		{
+			name:  "no annotations",
+			delta: `{"content":"hi"}`,
+		},
+		{
+			name:  "url citation",
+			delta: `{"content":"","annotations":[{"type":"url_citation","url_citation":{"start_index":0,"end_index":10,"title":"CVE-2025-1974","url":"https://nvd.nist.gov/vuln/detail/CVE-2025-1974"}}]}`,
+			want:  []Citation{{Title: "CVE-2025-1974", URI: "https://nvd.nist.gov/vuln/detail/CVE-2025-1974"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var delta openai.ChatCompletionChunkChoiceDelta
+			if err := delta.UnmarshalJSON([]byte(tc.delta)); err != nil {
+				t.Fatalf("unmarshaling delta: %v", err)
+			}
+			if got := openAIDeltaCitations(delta); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected citations %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenAIAccountHeadersAndRateLimits(t *testing.T) {
+	var gotHeader http.Header
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("x-ratelimit-limit-requests", "500")
+		w.Header().Set("x-ratelimit-limit-tokens", "30000")
+		w.Header().Set("x-ratelimit-remaining-requests", "0")
+		w.Header().Set("x-ratelimit-reset-requests", "1.5s")
+		w.Header().Set("x-ratelimit-remaining-tokens", "0")
+		w.Header().Set("x-ratelimit-reset-tokens", "2s")
+		w.WriteHeader(http.StatusTooManyRequests)
+		code := "rate_limit_exceeded"
+		if r.Header.Get("OpenAI-Project") == "exhausted" {
+			code = "insufficient_quota"
+		}
+		fmt.Fprintf(w, `{"error":{"message":"slow down","type":"requests","code":%q}}`, code)
+	}))
+	defer server.Close()
+
+	newChat := func(project string) *openAIChatSession {
+		client := openai.NewClient(
+			option.WithAPIKey("test"),
+			option.WithBaseURL(server.URL),
+			option.WithMaxRetries(0),
+			option.WithOrganization("org-1"),
+			option.WithProject(project),
+		)
+		return &openAIChatSession{client: client, model: "gpt-4.1", user: "alice-hash"}
+	}
+
+	chat := newChat("proj-1")
+	_, err := chat.Send(context.Background(), "hello")
+	if gotHeader.Get("OpenAI-Organization") != "org-1" || gotHeader.Get("OpenAI-Project") != "proj-1" {
+		t.Errorf("expected organization and project headers, got %v", gotHeader)
+	}
+	if gotBody["user"] != "alice-hash" {
+		t.Errorf("expected the user in the request, got %v", gotBody["user"])
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != 2*time.Second {
+		t.Errorf("expected a 429 retryable after 2s, got status %d after %v", apiErr.StatusCode, apiErr.RetryAfter)
+	}
+	if want := "slow down (rate limit of the account: 500 requests, 30000 tokens)"; apiErr.Message != want {
+		t.Errorf("expected message %q, got %q", want, apiErr.Message)
+	}
+	if !chat.IsRetryableError(err) {
+		t.Errorf("expected rate limited requests to be retryable")
+	}
+
+	chat = newChat("exhausted")
+	if _, err := chat.Send(context.Background(), "hello"); chat.IsRetryableError(err) {
+		t.Errorf("expected requests exceeding the quota not to be retryable: %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// PromptLogMode is what is logged of the prompts sent to the models and of
+// their responses, e.g. to comply with data-handling policies.
+type PromptLogMode string
+
+const (
+	// PromptLogOff logs nothing about the requests.
+	PromptLogOff PromptLogMode = "off"
+	// PromptLogMetadata logs the model, the size, the token usage and the
+	// latency of the requests, but not their content.
+	PromptLogMetadata PromptLogMode = "metadata"
+	// PromptLogSampled logs the metadata of the requests, and the content of
+	// a percentage of them.
+	PromptLogSampled PromptLogMode = "sampled"
+	// PromptLogFull logs the metadata and the content of the requests.
+	PromptLogFull PromptLogMode = "full"
+)
+
+// PromptLogModes are the modes of PromptLogOptions.
+var PromptLogModes = []PromptLogMode{PromptLogOff, PromptLogMetadata, PromptLogSampled, PromptLogFull}
+
+// PromptLogOptions configures the logging of the prompts and responses. The
+// zero value logs everything, like PromptLogFull.
+type PromptLogOptions struct {
+	Mode PromptLogMode
+	// SamplePercent is the percentage of the requests whose content is logged
+	// in the sampled mode, between 0 (excluded) and 100.
+	SamplePercent float64
+}
+
+// Validate checks the mode and the sample percentage.
+func (o PromptLogOptions) Validate() error {
+	switch o.Mode {
+	case "", PromptLogOff, PromptLogMetadata, PromptLogFull:
+		return nil
+	case PromptLogSampled:
+		if o.SamplePercent <= 0 || o.SamplePercent > 100 {
+			return fmt.Errorf("the sample percentage of the prompt log must be above 0 and at most 100, got %v", o.SamplePercent)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown prompt log mode %q, expected one of %v", o.Mode, PromptLogModes)
+	}
+}
+
+// PromptLogDecision is what is logged of a request.
+type PromptLogDecision struct {
+	Metadata bool
+	Content  bool
+}
+
+// Decide samples what is logged of a request, or of all the requests of a
+// query, see ContextWithPromptLog.
+func (o PromptLogOptions) Decide() PromptLogDecision {
+	switch o.Mode {
+	case PromptLogOff:
+		return PromptLogDecision{}
+	case PromptLogMetadata:
+		return PromptLogDecision{Metadata: true}
+	case PromptLogSampled:
+		return PromptLogDecision{Metadata: true, Content: rand.Float64()*100 < o.SamplePercent}
+	default:
+		return PromptLogDecision{Metadata: true, Content: true}
+	}
+}
+
+// WithPromptLog sets what the client and its provider log of the prompts and
+// responses. Everything is logged by default.
+func WithPromptLog(promptLog PromptLogOptions) Option {
+	return func(o *ClientOptions) {
+		o.PromptLog = promptLog
+	}
+}
+
+type promptLogKey struct{}
+
+// ContextWithPromptLog returns a context whose requests are logged as
+// decided, instead of being sampled one by one by the client, e.g. so that
+// all the requests of a sampled query are logged.
+func ContextWithPromptLog(ctx context.Context, decision PromptLogDecision) context.Context {
+	return context.WithValue(ctx, promptLogKey{}, decision)
+}
+
+// PromptContentLogged returns true if the content of the requests made with
+// ctx, and the queries they answer, may be logged. It is true if nothing was
+// decided, as clients log everything by default.
+func PromptContentLogged(ctx context.Context) bool {
+	decision, ok := ctx.Value(promptLogKey{}).(PromptLogDecision)
+	return !ok || decision.Content
+}
+
+// promptLogClient logs the requests of a client, and decides what its
+// provider logs of them, as configured by its PromptLogOptions. The providers
+// only log the content of the requests when PromptContentLogged.
+type promptLogClient struct {
+	Client
+	promptLog PromptLogOptions
+}
+
+var _ Client = &promptLogClient{}
+
+// WebSearchEnabled reports whether the wrapped client lets the model search the web.
+func (c *promptLogClient) WebSearchEnabled() bool {
+	return WebSearchEnabled(c.Client)
+}
+
+func (c *promptLogClient) decide(ctx context.Context) (context.Context, PromptLogDecision) {
+	if decision, ok := ctx.Value(promptLogKey{}).(PromptLogDecision); ok {
+		return ctx, decision
+	}
+	decision := c.promptLog.Decide()
+	return ContextWithPromptLog(ctx, decision), decision
+}
+
+func (c *promptLogClient) StartChat(systemPrompt, model string) Chat {
+	return &promptLogChat{Chat: c.Client.StartChat(systemPrompt, model), client: c, model: model}
+}
+
+func (c *promptLogClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	ctx, decision := c.decide(ctx)
+	start := time.Now()
+	response, err := c.Client.GenerateCompletion(ctx, req)
+	var text string
+	if err == nil && response != nil {
+		text = response.Response()
+	}
+	logExchange(ctx, decision, "completion", req.Model, req.Prompt, text, nil, time.Since(start), err)
+	return response, err
+}
+
+type promptLogChat struct {
+	Chat
+	client *promptLogClient
+	model  string
+}
+
+func (c *promptLogChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	ctx, decision := c.client.decide(ctx)
+	start := time.Now()
+	response, err := c.Chat.Send(ctx, contents...)
+	var text string
+	var usage *Usage
+	if err == nil && response != nil {
+		text, usage = responseText(response), ResponseUsage(response)
+	}
+	logExchange(ctx, decision, "chat", c.model, contents, text, usage, time.Since(start), err)
+	return response, err
+}
+
+func (c *promptLogChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	ctx, decision := c.client.decide(ctx)
+	start := time.Now()
+	stream, err := c.Chat.SendStreaming(ctx, contents...)
+	if err != nil {
+		logExchange(ctx, decision, "chat", c.model, contents, "", nil, time.Since(start), err)
+		return nil, err
+	}
+	return func(yield func(ChatResponse, error) bool) {
+		var text strings.Builder
+		var usage *Usage
+		var streamErr error
+		defer func() {
+			logExchange(ctx, decision, "chat", c.model, contents, text.String(), usage, time.Since(start), streamErr)
+		}()
+		for response, err := range stream {
+			if err != nil {
+				streamErr = err
+			} else if response != nil {
+				text.WriteString(responseText(response))
+				if u := ResponseUsage(response); u != nil {
+					usage = u
+				}
+			}
+			if !yield(response, err) {
+				return
+			}
+		}
+	}, nil
+}
+
+// responseText returns the text of the first candidate of a response.
+func responseText(response ChatResponse) string {
+	candidates := response.Candidates()
+	if len(candidates) == 0 {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range candidates[0].Parts() {
+		if s, ok := part.AsText(); ok {
+			text.WriteString(s)
+		}
+	}
+	return text.String()
+}
+
+// logExchange logs a request and its response, as decided.
+func logExchange(ctx context.Context, decision PromptLogDecision, kind, model string, prompt any, response string, usage *Usage, duration time.Duration, err error) {
+	if !decision.Metadata {
+		return
+	}
+	keysAndValues := []any{"kind", kind, "model", model, "durationMs", duration.Milliseconds()}
+	if usage != nil {
+		keysAndValues = append(keysAndValues, "inputTokens", usage.InputTokens, "outputTokens", usage.OutputTokens)
+	}
+	if decision.Content {
+		keysAndValues = append(keysAndValues, "prompt", prompt, "response", response)
+	} else {
+		// The size of the prompt as JSON, as it may hold images and function results.
+		b, _ := json.Marshal(prompt)
+		keysAndValues = append(keysAndValues, "promptBytes", len(b), "responseBytes", len(response))
+	}
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err.Error())
+	}
+	klog.FromContext(ctx).V(1).Info("LLM request", keysAndValues...)
+}
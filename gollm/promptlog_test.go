@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPromptLogOptions(t *testing.T) {
+	tests := []struct {
+		options PromptLogOptions
+		wantErr bool
+		want    PromptLogDecision
+	}{
+		{options: PromptLogOptions{}, want: PromptLogDecision{Metadata: true, Content: true}},
+		{options: PromptLogOptions{Mode: PromptLogFull}, want: PromptLogDecision{Metadata: true, Content: true}},
+		{options: PromptLogOptions{Mode: PromptLogMetadata}, want: PromptLogDecision{Metadata: true}},
+		{options: PromptLogOptions{Mode: PromptLogOff}, want: PromptLogDecision{}},
+		{options: PromptLogOptions{Mode: PromptLogSampled, SamplePercent: 100}, want: PromptLogDecision{Metadata: true, Content: true}},
+		{options: PromptLogOptions{Mode: PromptLogSampled}, wantErr: true},
+		{options: PromptLogOptions{Mode: PromptLogSampled, SamplePercent: 150}, wantErr: true},
+		{options: PromptLogOptions{Mode: "verbose"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := tt.options.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() = %v, want error: %v", tt.options, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got := tt.options.Decide(); got != tt.want {
+			t.Errorf("%+v.Decide() = %+v, want %+v", tt.options, got, tt.want)
+		}
+	}
+}
+
+// contentLoggedClient records whether its provider may log the content of its requests.
+type contentLoggedClient struct {
+	Client
+	contentLogged []bool
+}
+
+func (c *contentLoggedClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	c.contentLogged = append(c.contentLogged, PromptContentLogged(ctx))
+	return &AzureOpenAICompletionResponse{response: "ok"}, nil
+}
+
+func (c *contentLoggedClient) WebSearchEnabled() bool {
+	return true
+}
+
+func TestPromptLogClient(t *testing.T) {
+	provider := &contentLoggedClient{}
+	client := &promptLogClient{Client: provider, promptLog: PromptLogOptions{Mode: PromptLogMetadata}}
+
+	// The client decides for the requests made without a decision, e.g. of the query they answer.
+	ctx := context.Background()
+	if _, err := client.GenerateCompletion(ctx, &CompletionRequest{Prompt: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GenerateCompletion(ContextWithPromptLog(ctx, PromptLogDecision{Metadata: true, Content: true}), &CompletionRequest{Prompt: "sampled"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{false, true}; len(provider.contentLogged) != 2 || provider.contentLogged[0] != want[0] || provider.contentLogged[1] != want[1] {
+		t.Errorf("content logged by the provider = %v, want %v", provider.contentLogged, want)
+	}
+
+	if !WebSearchEnabled(client) {
+		t.Errorf("WebSearchEnabled() = false, want the wrapped client to be asked")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// QueryTier is the complexity class of a query.
+type QueryTier string
+
+const (
+	// QueryTierSimple queries are everyday lookups, e.g. listing or describing resources.
+	QueryTierSimple QueryTier = "simple"
+	// QueryTierComplex queries need an investigation, e.g. troubleshooting a failure.
+	QueryTierComplex QueryTier = "complex"
+)
+
+// maxSimpleQueryWords is the length above which queries are considered complex.
+const maxSimpleQueryWords = 25
+
+// complexQueryPattern matches words hinting at an investigation or a change.
+var complexQueryPattern = regexp.MustCompile(`(?i)\b(why|debug\w*|troubleshoot\w*|diagnos\w*|investigat\w*|fix\w*|root.?cause|not working|broken|fail\w*|crash\w*|error\w*|slow\w*|latency|oom\w*|evict\w*|stuck|optimi[sz]\w*|migrat\w*|upgrad\w*|compare|plan|design|secur\w*|audit\w*|scale|rollback|roll back|deploy|create|delete|apply|patch|restart|drain)\b`)
+
+// simpleQueryPattern matches everyday read-only questions.
+var simpleQueryPattern = regexp.MustCompile(`(?i)^\s*(get|list|show|describe|display|print|count|what('s| is| are)|which|how many|where|is there|are there|do i have|tell me)\b`)
+
+// RouteDecision is the model selected for a query, and why.
+type RouteDecision struct {
+	Model  string
+	Tier   QueryTier
+	Reason string
+}
+
+// ModelRouter selects, for each query, between a fast and cheap model and a
+// strong model. Queries are classified with a cheap heuristic, and the
+// classifier model, if set, is asked about the queries the heuristic is
+// unsure about. Unsure queries go to the strong model otherwise.
+type ModelRouter struct {
+	// Client is the client of the models.
+	Client Client
+	// FastModel answers simple queries.
+	FastModel string
+	// StrongModel answers complex queries.
+	StrongModel string
+	// ClassifierModel is a small model classifying the queries the heuristic is unsure about.
+	ClassifierModel string
+}
+
+const classifierPrompt = `Classify the following request to a Kubernetes assistant.
+Answer SIMPLE if it is an everyday lookup that a single read-only kubectl command
+answers (e.g. listing or describing resources), or COMPLEX if it needs an
+investigation, troubleshooting, several steps or changes to the cluster.
+Answer with a single word.
+
+Request: %s`
+
+// Route selects the model for the query, and logs the decision.
+func (r *ModelRouter) Route(ctx context.Context, query string) RouteDecision {
+	log := klog.FromContext(ctx)
+
+	tier, reason, sure := ClassifyQuery(query)
+	if !sure && r.ClassifierModel != "" && r.Client != nil {
+		if t, err := r.classify(ctx, query); err != nil {
+			log.Info("Query classification failed, using the heuristic", "err", err)
+		} else {
+			tier, reason = t, "classified by "+r.ClassifierModel
+		}
+	}
+
+	decision := RouteDecision{Model: r.StrongModel, Tier: tier, Reason: reason}
+	if tier == QueryTierSimple && r.FastModel != "" {
+		decision.Model = r.FastModel
+	}
+	log.Info("Routed query", "model", decision.Model, "tier", decision.Tier, "reason", decision.Reason)
+	return decision
+}
+
+// classify asks the classifier model for the tier of the query.
+func (r *ModelRouter) classify(ctx context.Context, query string) (QueryTier, error) {
+	response, err := r.Client.GenerateCompletion(ctx, &CompletionRequest{
+		Model:  r.ClassifierModel,
+		Prompt: fmt.Sprintf(classifierPrompt, query),
+	})
+	if err != nil {
+		return "", err
+	}
+	answer := strings.ToUpper(response.Response())
+	switch {
+	case strings.Contains(answer, "COMPLEX"):
+		return QueryTierComplex, nil
+	case strings.Contains(answer, "SIMPLE"):
+		return QueryTierSimple, nil
+	}
+	return "", fmt.Errorf("unexpected classification %q", strings.TrimSpace(response.Response()))
+}
+
+// ClassifyQuery classifies a query with a cheap heuristic. It returns the
+// tier, the reason, and whether the heuristic is sure; unsure queries are
+// classified as complex.
+func ClassifyQuery(query string) (QueryTier, string, bool) {
+	query = strings.TrimSpace(query)
+	if words := len(strings.Fields(query)); words > maxSimpleQueryWords {
+		return QueryTierComplex, fmt.Sprintf("long query (%d words)", words), true
+	}
+	if match := complexQueryPattern.FindString(query); match != "" {
+		return QueryTierComplex, fmt.Sprintf("mentions %q", strings.ToLower(match)), true
+	}
+	if simpleQueryPattern.MatchString(query) {
+		return QueryTierSimple, "read-only lookup", true
+	}
+	return QueryTierComplex, "unclassified query", false
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassifyQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		wantTier QueryTier
+		wantSure bool
+	}{
+		{query: "get pods in the shop namespace", wantTier: QueryTierSimple, wantSure: true},
+		{query: "list deployments", wantTier: QueryTierSimple, wantSure: true},
+		{query: "What is the image of deployment web?", wantTier: QueryTierSimple, wantSure: true},
+		{query: "how many nodes do I have", wantTier: QueryTierSimple, wantSure: true},
+		{query: "why is my pod crashing?", wantTier: QueryTierComplex, wantSure: true},
+		{query: "show me the errors of the web pods", wantTier: QueryTierComplex, wantSure: true},
+		{query: "delete the old jobs", wantTier: QueryTierComplex, wantSure: true},
+		{query: "list " + strings.Repeat("pods ", 30), wantTier: QueryTierComplex, wantSure: true},
+		{query: "nginx ingress", wantTier: QueryTierComplex, wantSure: false},
+	}
+
+	for _, tt := range tests {
+		tier, reason, sure := ClassifyQuery(tt.query)
+		if tier != tt.wantTier || sure != tt.wantSure {
+			t.Errorf("ClassifyQuery(%q) = %q, %q, %v, want %q, %v", tt.query, tier, reason, sure, tt.wantTier, tt.wantSure)
+		}
+		if reason == "" {
+			t.Errorf("ClassifyQuery(%q) gave no reason", tt.query)
+		}
+	}
+}
+
+// classifierClient is a client whose completions are the given answer.
+type classifierClient struct {
+	Client
+	answer string
+	err    error
+	model  string
+}
+
+func (c *classifierClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	c.model = req.Model
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &AzureOpenAICompletionResponse{response: c.answer}, nil
+}
+
+func TestModelRouterRoute(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		classifier string
+		client     *classifierClient
+		wantModel  string
+	}{
+		{
+			name:      "simple query",
+			query:     "get pods",
+			wantModel: "flash",
+		},
+		{
+			name:      "complex query",
+			query:     "troubleshoot the web deployment",
+			wantModel: "pro",
+		},
+		{
+			name:      "unsure query without classifier",
+			query:     "nginx ingress",
+			wantModel: "pro",
+		},
+		{
+			name:       "unsure query classified as simple",
+			query:      "nginx ingress",
+			classifier: "flash-lite",
+			client:     &classifierClient{answer: "SIMPLE"},
+			wantModel:  "flash",
+		},
+		{
+			name:       "unsure query classified as complex",
+			query:      "nginx ingress",
+			classifier: "flash-lite",
+			client:     &classifierClient{answer: " Complex.\n"},
+			wantModel:  "pro",
+		},
+		{
+			name:       "classifier error",
+			query:      "nginx ingress",
+			classifier: "flash-lite",
+			client:     &classifierClient{err: errors.New("quota exceeded")},
+			wantModel:  "pro",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := &ModelRouter{FastModel: "flash", StrongModel: "pro", ClassifierModel: tt.classifier}
+			if tt.client != nil {
+				router.Client = tt.client
+			}
+			got := router.Route(context.Background(), tt.query)
+			if got.Model != tt.wantModel {
+				t.Errorf("Route(%q) = %+v, want model %q", tt.query, got, tt.wantModel)
+			}
+			if tt.client != nil && tt.client.model != tt.classifier {
+				t.Errorf("classified with model %q, want %q", tt.client.model, tt.classifier)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// benchmarkToolSchema is the parameters schema of a typical tool, like the
+// kubectl tool: a few string and boolean parameters and a nested array.
+var benchmarkToolSchema = &Schema{
+	Type: TypeObject,
+	Properties: map[string]*Schema{
+		"command": {
+			Type:        TypeString,
+			Description: "The complete kubectl command to execute, including the kubectl prefix.",
+		},
+		"modifies_resource": {
+			Type:        TypeString,
+			Description: "Whether the command modifies a kubernetes resource, one of yes, no or unknown.",
+		},
+		"namespace": {Type: TypeString},
+		"watch":     {Type: TypeBoolean},
+		"labels": {
+			Type: TypeArray,
+			Items: &Schema{
+				Type: TypeObject,
+				Properties: map[string]*Schema{
+					"key":   {Type: TypeString},
+					"value": {Type: TypeString},
+				},
+				Required: []string{"key"},
+			},
+		},
+	},
+	Required: []string{"command", "modifies_resource"},
+}
+
+type benchmarkResponse struct {
+	Answer   string   `json:"answer"`
+	Commands []string `json:"commands,omitempty"`
+	Resolved bool     `json:"resolved"`
+	Steps    []struct {
+		Description string `json:"description"`
+		Retries     int    `json:"retries,omitempty"`
+	} `json:"steps"`
+}
+
+func BenchmarkBuildSchemaFor(b *testing.B) {
+	t := reflect.TypeOf(benchmarkResponse{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BuildSchemaFor(t)
+	}
+}
+
+func BenchmarkConvertSchemaForOpenAI(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := convertSchemaForOpenAI(benchmarkToolSchema); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToGeminiSchema(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := toGeminiSchema(benchmarkToolSchema); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
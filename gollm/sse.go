@@ -0,0 +1,67 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// maxServerSentEventSize bounds the size of the lines of a stream of
+// server-sent events, which carry a JSON chunk of the response each.
+const maxServerSentEventSize = 4 << 20
+
+// serverSentEvents returns the data of the server-sent events read from r,
+// as sent by the streaming APIs of the providers without a Go SDK.
+// Event types, IDs and comments are ignored.
+func serverSentEvents(r io.Reader) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxServerSentEventSize)
+
+		var data []byte
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				// A blank line dispatches the event.
+				if len(data) > 0 {
+					if !yield(data, nil) {
+						return
+					}
+					data = nil
+				}
+				continue
+			}
+			field, value, _ := bytes.Cut(line, []byte(":"))
+			if string(field) != "data" {
+				continue
+			}
+			if data != nil {
+				data = append(data, '\n')
+			}
+			data = append(data, bytes.TrimPrefix(value, []byte(" "))...)
+		}
+		if err := scanner.Err(); err != nil {
+			yield(nil, fmt.Errorf("reading event stream: %w", err))
+			return
+		}
+		if len(data) > 0 {
+			yield(data, nil)
+		}
+	}
+}
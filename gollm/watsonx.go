@@ -0,0 +1,673 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	watsonxDefaultURL     = "https://us-south.ml.cloud.ibm.com"
+	watsonxDefaultIAMURL  = "https://iam.cloud.ibm.com"
+	watsonxDefaultModel   = "ibm/granite-3-3-8b-instruct"
+	watsonxAPIVersion     = "2025-02-11"
+	watsonxTokenExpiryGap = time.Minute
+)
+
+func init() {
+	if err := RegisterProvider("watsonx", watsonxFactory); err != nil {
+		klog.Fatalf("Failed to register watsonx provider: %v", err)
+	}
+}
+
+// watsonxFactory is the provider factory function for IBM watsonx.ai.
+func watsonxFactory(ctx context.Context, opts ClientOptions) (Client, error) {
+	return NewWatsonxClient(ctx, opts)
+}
+
+// WatsonxClient implements the gollm.Client interface for the foundation
+// models of IBM watsonx.ai, with the text chat API.
+type WatsonxClient struct {
+	baseURL    *url.URL
+	iamURL     *url.URL
+	apiKey     string
+	projectID  string
+	spaceID    string
+	httpClient *http.Client
+	// maxOutputTokens caps the tokens of each response, if positive.
+	maxOutputTokens int
+
+	responseSchema *Schema
+
+	// tokenMu guards the IAM access token exchanged for the API key.
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+var _ Client = &WatsonxClient{}
+
+// NewWatsonxClient creates a new client for watsonx.ai, with the environment
+// variables of the watsonx.ai SDKs:
+//   - WATSONX_APIKEY: the IBM Cloud API key, unless stored in the OS keychain
+//   - WATSONX_URL: the endpoint of the region, defaults to Dallas
+//   - WATSONX_PROJECT_ID or WATSONX_SPACE_ID: the project or deployment space billed for the requests
+//
+// WATSONX_IAM_URL overrides the IAM endpoint exchanging the API key for tokens.
+func NewWatsonxClient(ctx context.Context, opts ClientOptions) (*WatsonxClient, error) {
+	apiKey := providerAPIKey("watsonx")
+	if apiKey == "" {
+		return nil, errors.New("watsonx API key not found. Set via WATSONX_APIKEY env var or `kubectl-ai auth login watsonx`")
+	}
+	projectID, spaceID := os.Getenv("WATSONX_PROJECT_ID"), os.Getenv("WATSONX_SPACE_ID")
+	if projectID == "" && spaceID == "" {
+		return nil, errors.New("watsonx project not found. Set WATSONX_PROJECT_ID or WATSONX_SPACE_ID env var")
+	}
+
+	baseURL, err := parseURLFromEnv("WATSONX_URL", watsonxDefaultURL)
+	if err != nil {
+		return nil, err
+	}
+	iamURL, err := parseURLFromEnv("WATSONX_IAM_URL", watsonxDefaultIAMURL)
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("using watsonx.ai with base url %v", baseURL.String())
+
+	return &WatsonxClient{
+		baseURL:         baseURL,
+		iamURL:          iamURL,
+		apiKey:          apiKey,
+		projectID:       projectID,
+		spaceID:         spaceID,
+		httpClient:      createCustomHTTPClient(opts),
+		maxOutputTokens: opts.MaxOutputTokens,
+	}, nil
+}
+
+// parseURLFromEnv parses the URL in the environment variable, or else the default URL.
+func parseURLFromEnv(envVar, defaultURL string) (*url.URL, error) {
+	s := os.Getenv(envVar)
+	if s == "" {
+		s = defaultURL
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s %q: %w", envVar, s, err)
+	}
+	return u, nil
+}
+
+func (c *WatsonxClient) Close() error {
+	return nil
+}
+
+// accessToken returns an IAM access token, exchanging the API key for a new
+// one when the current token is about to expire.
+func (c *WatsonxClient) accessToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token != "" && time.Now().Add(watsonxTokenExpiryGap).Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ibm:params:oauth:grant-type:apikey"},
+		"apikey":     {c.apiKey},
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, c.iamURL.JoinPath("identity/token").String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("building http request: %w", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpRequest.Header.Set("Accept", "application/json")
+
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return "", fmt.Errorf("requesting IAM token: %w", err)
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting IAM token: %w", newHTTPStatusError(httpResponse))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Expiration  int64  `json:"expiration"`
+	}
+	if err := json.NewDecoder(httpResponse.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("unmarshalling IAM token: %w", err)
+	}
+	c.token, c.tokenExpiry = token.AccessToken, time.Unix(token.Expiration, 0)
+	return c.token, nil
+}
+
+// newRequest builds a request to the API, with a JSON body if req is not nil.
+func (c *WatsonxClient) newRequest(ctx context.Context, method, relativePath string, query url.Values, req any) (*http.Request, error) {
+	var body []byte
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("building json body: %w", err)
+		}
+		body = b
+	}
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u := c.baseURL.JoinPath(relativePath)
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("version", watsonxAPIVersion)
+	u.RawQuery = query.Encode()
+	if PromptContentLogged(ctx) {
+		klog.V(2).Infof("sending %s request to %v: %s", method, u.String(), string(body))
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("building http request: %w", err)
+	}
+	httpRequest.Header.Set("Authorization", "Bearer "+token)
+	httpRequest.Header.Set("Content-Type", "application/json")
+	return httpRequest, nil
+}
+
+// do sends a request and decodes the JSON response.
+func (c *WatsonxClient) do(ctx context.Context, method, relativePath string, query url.Values, req any, response any) error {
+	httpRequest, err := c.newRequest(ctx, method, relativePath, query, req)
+	if err != nil {
+		return err
+	}
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("performing http request: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return newHTTPStatusError(httpResponse)
+	}
+	if err := json.NewDecoder(httpResponse.Body).Decode(response); err != nil {
+		return fmt.Errorf("unmarshalling json response: %w", err)
+	}
+	return nil
+}
+
+// newChatRequest returns a chat request billed to the project or space of the client.
+func (c *WatsonxClient) newChatRequest(model string, messages []watsonxMessage) *watsonxChatRequest {
+	return &watsonxChatRequest{
+		ModelID:   model,
+		ProjectID: c.projectID,
+		SpaceID:   c.spaceID,
+		Messages:  messages,
+		MaxTokens: c.maxOutputTokens,
+	}
+}
+
+func (c *WatsonxClient) GenerateCompletion(ctx context.Context, request *CompletionRequest) (CompletionResponse, error) {
+	req := c.newChatRequest(getWatsonxModel(request.Model), []watsonxMessage{watsonxUserMessage(request.Prompt)})
+	if c.responseSchema != nil {
+		// The schema itself is not enforced, only a JSON response is.
+		req.ResponseFormat = &watsonxResponseFormat{Type: "json_object"}
+	}
+
+	resp := &watsonxChatResponse{}
+	if err := c.do(ctx, http.MethodPost, "ml/v1/text/chat", nil, req, resp); err != nil {
+		return nil, fmt.Errorf("watsonx chat failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("received empty response from watsonx (no choices)")
+	}
+	return &simpleCompletionResponse{content: resp.Choices[0].Message.Content}, nil
+}
+
+func (c *WatsonxClient) SetResponseSchema(responseSchema *Schema) error {
+	c.responseSchema = responseSchema
+	return nil
+}
+
+func (c *WatsonxClient) ListModels(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Resources []struct {
+			ModelID string `json:"model_id"`
+		} `json:"resources"`
+	}
+	query := url.Values{"filters": {"function_text_chat"}, "limit": {"200"}}
+	if err := c.do(ctx, http.MethodGet, "ml/v1/foundation_model_specs", query, nil, &resp); err != nil {
+		return nil, fmt.Errorf("listing watsonx models: %w", err)
+	}
+	var models []string
+	for _, model := range resp.Resources {
+		models = append(models, model.ModelID)
+	}
+	return models, nil
+}
+
+func (c *WatsonxClient) StartChat(systemPrompt, model string) Chat {
+	chat := &watsonxChat{
+		client: c,
+		model:  getWatsonxModel(model),
+	}
+	if systemPrompt != "" {
+		chat.history = append(chat.history, watsonxMessage{Role: "system", Content: systemPrompt})
+	}
+	return chat
+}
+
+// getWatsonxModel returns the model to use, from the flag, WATSONX_MODEL or the default.
+func getWatsonxModel(model string) string {
+	if model != "" {
+		return model
+	}
+	if model := os.Getenv("WATSONX_MODEL"); model != "" {
+		return model
+	}
+	return watsonxDefaultModel
+}
+
+type watsonxChat struct {
+	client  *WatsonxClient
+	model   string
+	history []watsonxMessage
+	tools   []watsonxTool
+}
+
+var _ Chat = &watsonxChat{}
+
+func (c *watsonxChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
+	c.tools = nil
+	for _, fnDef := range functionDefinitions {
+		c.tools = append(c.tools, watsonxTool{
+			Type: "function",
+			Function: watsonxFunction{
+				Name:        fnDef.Name,
+				Description: fnDef.Description,
+				Parameters:  fnDef.Parameters,
+			},
+		})
+	}
+	return nil
+}
+
+// watsonxPendingCalls returns the tool calls of the last message of the history,
+// if it is a response of the model.
+func watsonxPendingCalls(history []watsonxMessage) []FunctionCall {
+	if len(history) == 0 || history[len(history)-1].Role != "assistant" {
+		return nil
+	}
+	var calls []FunctionCall
+	for _, toolCall := range history[len(history)-1].ToolCalls {
+		calls = append(calls, FunctionCall{ID: toolCall.ID, Name: toolCall.Function.Name})
+	}
+	return calls
+}
+
+// addContents appends the user messages and the results of tool calls to the history.
+func (c *watsonxChat) addContents(contents []any) error {
+	// Each tool call of the last response must be answered, in order.
+	contents, err := orderFunctionCallResults(watsonxPendingCalls(c.history), contents)
+	if err != nil {
+		return err
+	}
+	for _, content := range contents {
+		switch v := content.(type) {
+		case string:
+			c.history = append(c.history, watsonxUserMessage(v))
+		case FunctionCallResult:
+			resultJSON, err := json.Marshal(v.Result)
+			if err != nil {
+				return fmt.Errorf("marshalling function call result: %w", err)
+			}
+			c.history = append(c.history, watsonxMessage{Role: "tool", Content: string(resultJSON), ToolCallID: v.ID})
+		default:
+			return fmt.Errorf("unsupported content type: %T", v)
+		}
+	}
+	return nil
+}
+
+func (c *watsonxChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	if err := c.addContents(contents); err != nil {
+		return nil, err
+	}
+
+	req := c.client.newChatRequest(c.model, c.history)
+	req.Tools = c.tools
+	resp := &watsonxChatResponse{}
+	if err := c.client.do(ctx, http.MethodPost, "ml/v1/text/chat", nil, req, resp); err != nil {
+		return nil, fmt.Errorf("watsonx chat failed: %w", err)
+	}
+	if PromptContentLogged(ctx) {
+		klog.V(2).Infof("received response from watsonx: %+v", resp)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("received empty response from watsonx (no choices)")
+	}
+
+	choice := resp.Choices[0]
+	c.history = append(c.history, watsonxMessage{
+		Role:      "assistant",
+		Content:   choice.Message.Content,
+		ToolCalls: choice.Message.ToolCalls,
+	})
+
+	functionCalls, err := watsonxFunctionCalls(choice.Message.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	return &watsonxResponse{
+		raw:          resp,
+		text:         choice.Message.Content,
+		calls:        functionCalls,
+		finishReason: choice.FinishReason,
+		usage:        resp.Usage.toUsage(),
+	}, nil
+}
+
+func (c *watsonxChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	if err := c.addContents(contents); err != nil {
+		return nil, err
+	}
+
+	req := c.client.newChatRequest(c.model, c.history)
+	req.Tools = c.tools
+	httpRequest, err := c.client.newRequest(ctx, http.MethodPost, "ml/v1/text/chat_stream", nil, req)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Accept", "text/event-stream")
+	httpResponse, err := c.client.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("performing http request: %w", err)
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		defer httpResponse.Body.Close()
+		return nil, fmt.Errorf("watsonx chat failed: %w", newHTTPStatusError(httpResponse))
+	}
+
+	return func(yield func(ChatResponse, error) bool) {
+		defer httpResponse.Body.Close()
+
+		var text strings.Builder
+		// Tool calls are streamed in fragments, identified by their index.
+		var toolCalls []watsonxToolCall
+		defer func() {
+			// The history gets what was received, even if the stream was interrupted.
+			if text.Len() == 0 && len(toolCalls) == 0 {
+				return
+			}
+			c.history = append(c.history, watsonxMessage{
+				Role:      "assistant",
+				Content:   text.String(),
+				ToolCalls: toolCalls,
+			})
+		}()
+
+		for data, err := range serverSentEvents(httpResponse.Body) {
+			if err != nil {
+				yield(nil, fmt.Errorf("watsonx streaming error: %w", err))
+				return
+			}
+			var chunk watsonxStreamChunk
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				yield(nil, fmt.Errorf("parsing watsonx stream chunk: %w", err))
+				return
+			}
+
+			response := &watsonxResponse{raw: &chunk, usage: chunk.Usage.toUsage()}
+			if len(chunk.Choices) > 0 {
+				choice := chunk.Choices[0]
+				response.text = choice.Delta.Content
+				text.WriteString(choice.Delta.Content)
+				for _, fragment := range choice.Delta.ToolCalls {
+					for len(toolCalls) <= fragment.Index {
+						toolCalls = append(toolCalls, watsonxToolCall{Type: "function"})
+					}
+					toolCall := &toolCalls[fragment.Index]
+					if fragment.ID != "" {
+						toolCall.ID = fragment.ID
+					}
+					toolCall.Function.Name += fragment.Function.Name
+					toolCall.Function.Arguments += fragment.Function.Arguments
+				}
+				response.finishReason = choice.FinishReason
+				if choice.FinishReason != "" && len(toolCalls) > 0 {
+					// The tool calls are complete once the model is done.
+					calls, err := watsonxFunctionCalls(toolCalls)
+					if err != nil {
+						yield(nil, err)
+						return
+					}
+					response.calls = calls
+				}
+			}
+			if response.text == "" && len(response.calls) == 0 && response.finishReason == "" && response.usage == nil {
+				continue
+			}
+			if !yield(response, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+func (c *watsonxChat) IsRetryableError(err error) bool {
+	return DefaultIsRetryableError(err)
+}
+
+func (c *watsonxChat) Initialize(messages []*Message) error {
+	klog.Warning("chat history persistence is not supported for provider 'watsonx', using in-memory chat history")
+	return nil
+}
+
+// watsonxFunctionCalls converts the tool calls of the model.
+func watsonxFunctionCalls(toolCalls []watsonxToolCall) ([]FunctionCall, error) {
+	var calls []FunctionCall
+	for _, toolCall := range toolCalls {
+		call := FunctionCall{ID: toolCall.ID, Name: toolCall.Function.Name, Arguments: map[string]any{}}
+		if toolCall.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &call.Arguments); err != nil {
+				return nil, fmt.Errorf("parsing function call arguments: %w", err)
+			}
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// watsonxResponse is a response of the model, or a chunk of it when streaming.
+type watsonxResponse struct {
+	raw          any
+	text         string
+	calls        []FunctionCall
+	finishReason string
+	usage        *Usage
+}
+
+var _ ChatResponse = &watsonxResponse{}
+
+func (r *watsonxResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&RecordChatResponse{Raw: r.raw})
+}
+
+func (r *watsonxResponse) UsageMetadata() any {
+	if r.usage == nil {
+		return nil
+	}
+	return r.usage
+}
+
+// TokenUsage returns the tokens used by the response, reported by the last chunk when streaming.
+func (r *watsonxResponse) TokenUsage() *Usage {
+	return r.usage
+}
+
+func (r *watsonxResponse) Candidates() []Candidate {
+	return []Candidate{&watsonxCandidate{response: r}}
+}
+
+type watsonxCandidate struct {
+	response *watsonxResponse
+}
+
+func (c *watsonxCandidate) String() string {
+	return fmt.Sprintf("watsonxCandidate{text=%q, calls=%v}", c.response.text, c.response.calls)
+}
+
+func (c *watsonxCandidate) Parts() []Part {
+	var parts []Part
+	if c.response.text != "" {
+		parts = append(parts, &watsonxPart{text: c.response.text})
+	}
+	if len(c.response.calls) > 0 {
+		parts = append(parts, &watsonxPart{calls: c.response.calls})
+	}
+	return parts
+}
+
+// FinishReason returns why the model stopped generating the candidate.
+// watsonx.ai reports the finish reasons of the OpenAI API.
+func (c *watsonxCandidate) FinishReason() FinishReason {
+	return openAIFinishReason(c.response.finishReason)
+}
+
+type watsonxPart struct {
+	text  string
+	calls []FunctionCall
+}
+
+func (p *watsonxPart) AsText() (string, bool) {
+	return p.text, p.text != ""
+}
+
+func (p *watsonxPart) AsFunctionCalls() ([]FunctionCall, bool) {
+	return p.calls, len(p.calls) > 0
+}
+
+// See https://cloud.ibm.com/apidocs/watsonx-ai#text-chat
+
+type watsonxChatRequest struct {
+	ModelID        string                 `json:"model_id"`
+	ProjectID      string                 `json:"project_id,omitempty"`
+	SpaceID        string                 `json:"space_id,omitempty"`
+	Messages       []watsonxMessage       `json:"messages"`
+	Tools          []watsonxTool          `json:"tools,omitempty"`
+	ResponseFormat *watsonxResponseFormat `json:"response_format,omitempty"`
+	MaxTokens      int                    `json:"max_tokens,omitempty"`
+}
+
+type watsonxResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// watsonxMessage is a message of the chat. The content of user messages is a
+// list of parts, the content of the others a string.
+type watsonxMessage struct {
+	Role       string            `json:"role"`
+	Content    any               `json:"content,omitempty"`
+	ToolCalls  []watsonxToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+}
+
+func watsonxUserMessage(text string) watsonxMessage {
+	return watsonxMessage{Role: "user", Content: []watsonxContent{{Type: "text", Text: text}}}
+}
+
+type watsonxContent struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+type watsonxTool struct {
+	Type     string          `json:"type"`
+	Function watsonxFunction `json:"function"`
+}
+
+type watsonxFunction struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Parameters  *Schema `json:"parameters,omitempty"`
+}
+
+type watsonxToolCall struct {
+	Index    int                 `json:"index,omitempty"`
+	ID       string              `json:"id,omitempty"`
+	Type     string              `json:"type,omitempty"`
+	Function watsonxFunctionCall `json:"function"`
+}
+
+type watsonxFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+type watsonxChatResponse struct {
+	ID      string `json:"id"`
+	ModelID string `json:"model_id"`
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role      string            `json:"role"`
+			Content   string            `json:"content"`
+			ToolCalls []watsonxToolCall `json:"tool_calls,omitempty"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *watsonxUsage `json:"usage,omitempty"`
+}
+
+type watsonxStreamChunk struct {
+	ID      string `json:"id"`
+	ModelID string `json:"model_id"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role      string            `json:"role,omitempty"`
+			Content   string            `json:"content,omitempty"`
+			ToolCalls []watsonxToolCall `json:"tool_calls,omitempty"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason,omitempty"`
+	} `json:"choices"`
+	Usage *watsonxUsage `json:"usage,omitempty"`
+}
+
+type watsonxUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *watsonxUsage) toUsage() *Usage {
+	if u == nil || u.TotalTokens == 0 {
+		return nil
+	}
+	return &Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
@@ -0,0 +1,198 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+// newTestWatsonxChat returns a chat with a fake watsonx.ai and IAM API, the
+// chat API answering with the response, and the number of tokens issued by
+// the IAM API.
+func newTestWatsonxChat(t *testing.T, response string) (Chat, *int) {
+	t.Helper()
+	tokens := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /identity/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("apikey") != "test-key" {
+			http.Error(w, "invalid API key", http.StatusBadRequest)
+			return
+		}
+		tokens++
+		fmt.Fprintf(w, `{"access_token":"token-%d","expiration":%d}`, tokens, time.Now().Add(time.Hour).Unix())
+	})
+	chatHandler := func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token-1" {
+			t.Errorf("Authorization header = %q, want the IAM token", got)
+		}
+		if r.URL.Query().Get("version") == "" {
+			t.Errorf("request has no API version")
+		}
+		var req watsonxChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.ProjectID != "project-1" || req.ModelID != "ibm/granite-3-3-8b-instruct" {
+			t.Errorf("request project and model = %q, %q, want project-1 and the default model", req.ProjectID, req.ModelID)
+		}
+		fmt.Fprint(w, response)
+	}
+	mux.HandleFunc("POST /ml/v1/text/chat", chatHandler)
+	mux.HandleFunc("POST /ml/v1/text/chat_stream", chatHandler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	keyring.MockInit()
+	t.Setenv("WATSONX_APIKEY", "test-key")
+	t.Setenv("WATSONX_URL", server.URL)
+	t.Setenv("WATSONX_IAM_URL", server.URL)
+	t.Setenv("WATSONX_PROJECT_ID", "project-1")
+	t.Setenv("WATSONX_MODEL", "")
+	client, err := NewWatsonxClient(context.Background(), ClientOptions{})
+	if err != nil {
+		t.Fatalf("NewWatsonxClient() error: %v", err)
+	}
+	return client.StartChat("You are a Kubernetes assistant.", ""), &tokens
+}
+
+func TestWatsonxSend(t *testing.T) {
+	chat, tokens := newTestWatsonxChat(t, `{
+		"id": "chat-1",
+		"model_id": "ibm/granite-3-3-8b-instruct",
+		"choices": [{
+			"index": 0,
+			"message": {
+				"role": "assistant",
+				"tool_calls": [{"id": "call-1", "type": "function", "function": {"name": "kubectl", "arguments": "{\"command\":\"kubectl get pods\"}"}}]
+			},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 100, "completion_tokens": 20, "total_tokens": 120}
+	}`)
+
+	for i := 0; i < 2; i++ {
+		resp, err := chat.Send(context.Background(), "list the pods")
+		if err != nil {
+			t.Fatalf("Send() error: %v", err)
+		}
+		candidate := resp.Candidates()[0]
+		calls, ok := candidate.Parts()[0].AsFunctionCalls()
+		if !ok {
+			t.Fatalf("Send() returned no function calls: %v", candidate)
+		}
+		wantCalls := []FunctionCall{{ID: "call-1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}}}
+		if !reflect.DeepEqual(calls, wantCalls) {
+			t.Errorf("function calls = %+v, want %+v", calls, wantCalls)
+		}
+		if got := CandidateFinishReason(candidate); got != FinishReasonStop {
+			t.Errorf("finish reason = %q, want %q", got, FinishReasonStop)
+		}
+		if got, want := ResponseUsage(resp), (&Usage{InputTokens: 100, OutputTokens: 20, TotalTokens: 120}); !reflect.DeepEqual(got, want) {
+			t.Errorf("usage = %+v, want %+v", got, want)
+		}
+	}
+	if *tokens != 1 {
+		t.Errorf("IAM tokens issued = %d, want 1, the token is reused until it expires", *tokens)
+	}
+}
+
+func TestWatsonxSendStreaming(t *testing.T) {
+	chat, _ := newTestWatsonxChat(t, `id: 1
+event: message
+data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Listing "}}]}
+
+id: 2
+event: message
+data: {"choices":[{"index":0,"delta":{"content":"pods."}}]}
+
+id: 3
+event: message
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call-1","type":"function","function":{"name":"kubectl","arguments":"{\"command\":"}}]}}]}
+
+id: 4
+event: message
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"kubectl get pods\"}"}}]}}]}
+
+id: 5
+event: message
+data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}
+
+`)
+
+	iterator, err := chat.SendStreaming(context.Background(), "list the pods")
+	if err != nil {
+		t.Fatalf("SendStreaming() error: %v", err)
+	}
+	var text string
+	var calls []FunctionCall
+	var usage *Usage
+	for resp, err := range iterator {
+		if err != nil {
+			t.Fatalf("streaming error: %v", err)
+		}
+		for _, part := range resp.Candidates()[0].Parts() {
+			if s, ok := part.AsText(); ok {
+				text += s
+			}
+			if c, ok := part.AsFunctionCalls(); ok {
+				calls = append(calls, c...)
+			}
+		}
+		if u := ResponseUsage(resp); u != nil {
+			usage = u
+		}
+	}
+
+	if text != "Listing pods." {
+		t.Errorf("text = %q, want %q", text, "Listing pods.")
+	}
+	wantCalls := []FunctionCall{{ID: "call-1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}}}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("function calls = %+v, want %+v", calls, wantCalls)
+	}
+	if want := (&Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}); !reflect.DeepEqual(usage, want) {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+
+	history := chat.(*watsonxChat).history
+	wantLast := watsonxMessage{Role: "assistant", Content: "Listing pods.", ToolCalls: []watsonxToolCall{{ID: "call-1", Type: "function", Function: watsonxFunctionCall{Name: "kubectl", Arguments: `{"command":"kubectl get pods"}`}}}}
+	if got := history[len(history)-1]; !reflect.DeepEqual(got, wantLast) {
+		t.Errorf("last message of the history = %+v, want %+v", got, wantLast)
+	}
+}
+
+func TestNewWatsonxClientErrors(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("WATSONX_APIKEY", "")
+	if _, err := NewWatsonxClient(context.Background(), ClientOptions{}); err == nil {
+		t.Errorf("NewWatsonxClient() without an API key: want error")
+	}
+
+	t.Setenv("WATSONX_APIKEY", "test-key")
+	t.Setenv("WATSONX_PROJECT_ID", "")
+	t.Setenv("WATSONX_SPACE_ID", "")
+	if _, err := NewWatsonxClient(context.Background(), ClientOptions{}); err == nil {
+		t.Errorf("NewWatsonxClient() without a project or space: want error")
+	}
+}
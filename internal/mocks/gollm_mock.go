@@ -14,7 +14,6 @@ import (
 	reflect "reflect"
 
 	gollm "github.com/GoogleCloudPlatform/kubectl-ai/gollm"
-	api "github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -139,7 +138,7 @@ func (m *MockChat) EXPECT() *MockChatMockRecorder {
 }
 
 // Initialize mocks base method.
-func (m *MockChat) Initialize(messages []*api.Message) error {
+func (m *MockChat) Initialize(messages []*gollm.Message) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Initialize", messages)
 	ret0, _ := ret[0].(error)
@@ -0,0 +1,210 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/k8s-bench/pkg/model"
+	"k8s.io/klog/v2"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"shortCommit": func(commit string) string { return commit[:min(len(commit), 8)] },
+}).Parse(dashboardHTML))
+
+type DashboardConfig struct {
+	Store   string
+	Listen  string
+	MaxRuns int
+}
+
+// dashboardData is the trends of the runs in the store, rendered by the dashboard.
+type dashboardData struct {
+	Store     string
+	Generated time.Time
+	// Runs are the displayed runs, oldest first.
+	Runs   []model.Run
+	Models []modelTrend
+	// ChartWidth is the width of the charts, 20 per run.
+	ChartWidth int
+}
+
+// modelTrend is the results of a model across the displayed runs.
+type modelTrend struct {
+	Model string
+	// Points has the results of the model in each displayed run.
+	Points []trendPoint
+	Tasks  []taskTrend
+	// Regressions are the tasks that failed in the last run of the model,
+	// after succeeding in the run before.
+	Regressions []string
+	// Chart is the polyline of the success rates, in a 100-high chart.
+	Chart string
+}
+
+type trendPoint struct {
+	Passed int
+	Total  int
+}
+
+func (p trendPoint) Percent() int {
+	return calculatePercentage(p.Passed, p.Total)
+}
+
+type taskTrend struct {
+	Task string
+	// Results has the result of the task in each displayed run, empty if it didn't run.
+	Results    []string
+	Regression bool
+}
+
+func runServeDashboard(ctx context.Context) error {
+	config := DashboardConfig{
+		Listen:  "127.0.0.1:8080",
+		MaxRuns: 30,
+	}
+
+	// Set custom usage for 'serve-dashboard' subcommand
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve-dashboard --store <store> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Serve a dashboard of the trends of the runs published to a store.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.StringVar(&config.Store, "store", config.Store, "Store the runs were uploaded to: gs://bucket/prefix, an http(s) endpoint or a directory (required)")
+	flag.StringVar(&config.Listen, "listen", config.Listen, "Address to serve the dashboard on")
+	flag.IntVar(&config.MaxRuns, "max-runs", config.MaxRuns, "Number of most recent runs to show")
+	flag.Parse()
+
+	if config.Store == "" {
+		flag.Usage()
+		return fmt.Errorf("--store is required")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, req *http.Request) {
+		runs, err := loadRuns(req.Context(), config.Store)
+		if err != nil {
+			klog.Errorf("loading runs: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := buildTrends(runs, config.MaxRuns)
+		data.Store = config.Store
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardTemplate.Execute(w, data); err != nil {
+			klog.Errorf("rendering dashboard: %v", err)
+		}
+	})
+
+	server := &http.Server{
+		Addr:    config.Listen,
+		Handler: mux,
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+	fmt.Printf("Serving the dashboard of %s on http://%s\n", config.Store, config.Listen)
+	return server.ListenAndServe()
+}
+
+// buildTrends computes the trends of each model over the last maxRuns runs,
+// which are sorted oldest first.
+func buildTrends(runs []model.Run, maxRuns int) dashboardData {
+	if maxRuns > 0 && len(runs) > maxRuns {
+		runs = runs[len(runs)-maxRuns:]
+	}
+	data := dashboardData{Generated: time.Now(), Runs: runs, ChartWidth: len(runs) * 20}
+
+	byModel := make(map[string]*modelTrend)
+	// results[model][task][run] is the result of the task.
+	results := make(map[string]map[string][]string)
+	for i, run := range runs {
+		for _, result := range run.Results {
+			key := result.LLMConfig.ModelKey()
+			trend := byModel[key]
+			if trend == nil {
+				trend = &modelTrend{Model: key, Points: make([]trendPoint, len(runs))}
+				byModel[key] = trend
+				results[key] = make(map[string][]string)
+			}
+			trend.Points[i].Total++
+			success := strings.Contains(strings.ToLower(result.Result), "success")
+			if success {
+				trend.Points[i].Passed++
+			}
+			if results[key][result.Task] == nil {
+				results[key][result.Task] = make([]string, len(runs))
+			}
+			if success {
+				results[key][result.Task][i] = "success"
+			} else {
+				results[key][result.Task][i] = "fail"
+			}
+		}
+	}
+
+	for key, trend := range byModel {
+		// The last two runs in which the model was evaluated are compared.
+		var evaluated []int
+		for i, point := range trend.Points {
+			if point.Total > 0 {
+				evaluated = append(evaluated, i)
+			}
+		}
+
+		for task, taskResults := range results[key] {
+			tt := taskTrend{Task: task, Results: taskResults}
+			if n := len(evaluated); n >= 2 {
+				last, prev := evaluated[n-1], evaluated[n-2]
+				tt.Regression = taskResults[prev] == "success" && taskResults[last] == "fail"
+			}
+			if tt.Regression {
+				trend.Regressions = append(trend.Regressions, task)
+			}
+			trend.Tasks = append(trend.Tasks, tt)
+		}
+		sort.Slice(trend.Tasks, func(i, j int) bool { return trend.Tasks[i].Task < trend.Tasks[j].Task })
+		sort.Strings(trend.Regressions)
+
+		var points []string
+		for i, point := range trend.Points {
+			if point.Total > 0 {
+				points = append(points, fmt.Sprintf("%d,%d", i*20+10, 100-point.Percent()))
+			}
+		}
+		trend.Chart = strings.Join(points, " ")
+
+		data.Models = append(data.Models, *trend)
+	}
+	sort.Slice(data.Models, func(i, j int) bool { return data.Models[i].Model < data.Models[j].Model })
+
+	return data
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/k8s-bench/pkg/model"
+	"sigs.k8s.io/yaml"
+)
+
+// mutatingKubectlVerbs are the kubectl verbs that modify resources.
+var mutatingKubectlVerbs = map[string]bool{
+	"apply": true, "create": true, "replace": true, "delete": true,
+	"patch": true, "edit": true, "scale": true, "autoscale": true,
+	"expose": true, "run": true, "label": true, "annotate": true,
+	"taint": true, "drain": true, "cordon": true, "uncordon": true,
+	"set": true,
+}
+
+// mutatingRolloutVerbs are the kubectl rollout sub-commands that modify resources.
+var mutatingRolloutVerbs = map[string]bool{
+	"restart": true, "undo": true, "pause": true, "resume": true,
+}
+
+// kubectlValueFlags are the kubectl flags that may appear before the verb and
+// consume the following argument.
+var kubectlValueFlags = map[string]bool{
+	"-n": true, "--namespace": true, "--context": true, "--kubeconfig": true,
+}
+
+// traceEvent is the part of the events of the trace of kubectl-ai we score.
+type traceEvent struct {
+	Action  string `json:"action"`
+	Payload struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"payload"`
+}
+
+// scoreEfficiency scores the tool calls recorded in the trace of the agent.
+// Calls modifying resources are only counted for read-only tasks.
+func scoreEfficiency(tracePath string, readOnly bool) (*model.Efficiency, error) {
+	data, err := os.ReadFile(tracePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading trace: %w", err)
+	}
+
+	efficiency := &model.Efficiency{}
+	seen := make(map[string]bool)
+	for _, doc := range bytes.Split(data, []byte("\n---\n")) {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		var event traceEvent
+		if err := yaml.Unmarshal(doc, &event); err != nil {
+			return nil, fmt.Errorf("parsing trace event: %w", err)
+		}
+		if event.Action != "tool-request" {
+			continue
+		}
+		efficiency.ToolCalls++
+
+		arguments, err := json.Marshal(event.Payload.Arguments)
+		if err != nil {
+			return nil, fmt.Errorf("encoding tool call arguments: %w", err)
+		}
+		key := event.Payload.Name + string(arguments)
+		if seen[key] {
+			efficiency.RedundantCalls++
+			continue
+		}
+		seen[key] = true
+
+		command, _ := event.Payload.Arguments["command"].(string)
+		if readOnly && isMutatingCommand(command) {
+			efficiency.MutatingCalls++
+		}
+	}
+	return efficiency, nil
+}
+
+// isMutatingCommand reports whether a shell command runs a kubectl verb that
+// modifies resources.
+func isMutatingCommand(command string) bool {
+	replacer := strings.NewReplacer("|", " | ", ";", " ; ", "&", " & ")
+	fields := strings.Fields(replacer.Replace(command))
+	for i := 0; i < len(fields); i++ {
+		if name := filepath.Base(fields[i]); name != "kubectl" && name != "kubectl.exe" {
+			continue
+		}
+		for i++; i < len(fields); i++ {
+			arg := fields[i]
+			if !strings.HasPrefix(arg, "-") {
+				if mutatingKubectlVerbs[arg] {
+					return true
+				}
+				if arg == "rollout" && i+1 < len(fields) && mutatingRolloutVerbs[fields[i+1]] {
+					return true
+				}
+				break
+			}
+			if kubectlValueFlags[arg] {
+				i++
+			}
+		}
+	}
+	return false
+}
+
+// efficiencyIndex averages the efficiency index of the successful results that
+// were scored, and reports whether there were any.
+func efficiencyIndex(results []model.TaskResult) (int, bool) {
+	total, count := 0, 0
+	for _, result := range results {
+		if result.Efficiency == nil || !strings.Contains(strings.ToLower(result.Result), "success") {
+			continue
+		}
+		total += result.Efficiency.Index()
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return total / count, true
+}
@@ -26,6 +26,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/k8s-bench/pkg/model"
 	"k8s.io/klog/v2"
@@ -46,6 +47,10 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 	if config.Concurrency <= 0 {
 		config.Concurrency = 1
 	}
+	// When sharding across clusters, each worker owns one cluster
+	if len(config.KubeConfigs) > 0 {
+		config.Concurrency = len(config.KubeConfigs)
+	}
 
 	// Create a channel for tasks to be processed
 	type taskJob struct {
@@ -77,6 +82,11 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 		go func(workerID int) {
 			defer wg.Done()
 
+			workerConfig := config
+			if len(config.KubeConfigs) > 0 {
+				workerConfig.KubeConfig = config.KubeConfigs[workerID]
+			}
+
 			for job := range taskCh {
 				fmt.Printf("Worker %d: Evaluating task: %s\n", workerID, job.taskID)
 
@@ -102,7 +112,7 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 						log = logFile
 					}
 
-					result := evaluateTask(ctx, config, job.taskID, job.task, llmConfig, log)
+					result := evaluateTask(ctx, workerConfig, job.taskID, job.task, llmConfig, log)
 
 					if taskOutputDir != "" {
 						if err := writeToYAMLFile(filepath.Join(taskOutputDir, "results.yaml"), result); err != nil {
@@ -201,10 +211,13 @@ func loadTasks(config EvalConfig) (map[string]Task, error) {
 	return tasks, nil
 }
 
-func evaluateTask(ctx context.Context, config EvalConfig, taskID string, task Task, llmConfig model.LLMConfig, log io.Writer) model.TaskResult {
-	result := model.TaskResult{
-		Task:      taskID,
-		LLMConfig: llmConfig,
+// evaluateTask runs the task with the LLM configuration. The result is named,
+// for the run of the cleanup script to be recorded in it.
+func evaluateTask(ctx context.Context, config EvalConfig, taskID string, task Task, llmConfig model.LLMConfig, log io.Writer) (result model.TaskResult) {
+	result = model.TaskResult{
+		Task:       taskID,
+		LLMConfig:  llmConfig,
+		KubeConfig: config.KubeConfig,
 	}
 
 	taskOutputDir := filepath.Join(config.OutputDir, taskID, llmConfig.ID)
@@ -250,6 +263,13 @@ func evaluateTask(ctx context.Context, config EvalConfig, taskID string, task Ta
 		return result
 	}
 
+	efficiency, err := scoreEfficiency(filepath.Join(taskOutputDir, "trace.yaml"), task.ReadOnly)
+	if err != nil {
+		fmt.Printf("Warning: scoring efficiency failed for task %s: %v\n", taskID, err)
+	} else {
+		result.Efficiency = efficiency
+	}
+
 	var expectationFailures []model.Failure
 
 	if len(task.Expect) > 0 {
@@ -298,7 +318,7 @@ func evaluateTask(ctx context.Context, config EvalConfig, taskID string, task Ta
 		cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", x.kubeConfig))
 		fmt.Printf("\nRunning verifier for task %s\n", taskID)
 
-		err := x.runCommand(cmd)
+		err := x.runScript("verifier", cmd)
 		if err == nil {
 			verifierSucceeded = true
 		} else {
@@ -384,7 +404,7 @@ func (x *TaskExecution) runSetup(ctx context.Context) error {
 		cmd.Dir = x.taskDir
 		cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", x.kubeConfig))
 
-		if err := x.runCommand(cmd); err != nil {
+		if err := x.runScript("setup", cmd); err != nil {
 			return err
 		}
 	}
@@ -402,7 +422,7 @@ func (x *TaskExecution) runCleanup(ctx context.Context) error {
 		cmd.Dir = x.taskDir
 		cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", x.kubeConfig))
 
-		if err := x.runCommand(cmd); err != nil {
+		if err := x.runScript("cleanup", cmd); err != nil {
 			fmt.Printf("Warning: cleanup failed for task %s: %v\n", x.taskID, err)
 		}
 	}
@@ -482,6 +502,49 @@ func (x *TaskExecution) runCommand(cmd *exec.Cmd) error {
 	return nil
 }
 
+// runScript runs a script of the task, like runCommand, and records its run
+// in the result: its exit code, its duration, and its output, saved in the
+// <phase>-stdout.txt and <phase>-stderr.txt files of the task output directory.
+func (x *TaskExecution) runScript(phase string, cmd *exec.Cmd) error {
+	run := model.ScriptRun{
+		Phase:   phase,
+		Command: strings.Join(cmd.Args, " "),
+		Stdout:  phase + "-stdout.txt",
+		Stderr:  phase + "-stderr.txt",
+	}
+	stdout, err := os.Create(filepath.Join(x.taskOutputDir, run.Stdout))
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(filepath.Join(x.taskOutputDir, run.Stderr))
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	defer stderr.Close()
+
+	fmt.Printf("\nRunning command: %s\n", run.Command)
+	cmd.Stdout = io.MultiWriter(os.Stdout, stdout)
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	if x.log != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, x.log)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, x.log)
+	}
+	start := time.Now()
+	err = cmd.Run()
+	run.DurationSeconds = time.Since(start).Seconds()
+	run.ExitCode = cmd.ProcessState.ExitCode()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		run.Error = err.Error()
+	}
+	x.result.Scripts = append(x.result.Scripts, run)
+	if err != nil {
+		return fmt.Errorf("running command %v: %w", run.Command, err)
+	}
+	return nil
+}
+
 func printResults(allResults []model.TaskResult) {
 	fmt.Println("\nEvaluation Results:")
 	fmt.Println("==================")
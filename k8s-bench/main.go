@@ -35,6 +35,9 @@ type Task struct {
 	Cleanup    string `json:"cleanup,omitempty"`
 	Difficulty string `json:"difficulty"`
 	Disabled   bool   `json:"disabled,omitempty"`
+	// ReadOnly marks tasks that are answered without modifying resources.
+	// Tool calls modifying resources lower the efficiency of the agent.
+	ReadOnly bool `json:"readOnly,omitempty"`
 
 	Expect []Expectation `json:"expect,omitempty"`
 
@@ -94,8 +97,11 @@ type Expectation struct {
 }
 
 type EvalConfig struct {
-	LLMConfigs  []model.LLMConfig
-	KubeConfig  string
+	LLMConfigs []model.LLMConfig
+	KubeConfig string
+	// KubeConfigs are the kubeconfigs of pre-provisioned clusters to shard tasks across.
+	// When set, one task runs on each cluster at a time and KubeConfig is ignored.
+	KubeConfigs []string
 	TasksDir    string
 	TaskPattern string
 	AgentBin    string
@@ -108,6 +114,12 @@ type AnalyzeConfig struct {
 	InputDir          string
 	OutputFormat      string
 	IgnoreToolUseShim bool
+
+	// UploadTo is the store the results are published to, if set.
+	UploadTo string
+	// Commit and Release identify the evaluated kubectl-ai in the store.
+	Commit  string
+	Release string
 }
 
 func expandPath(path string) (string, error) {
@@ -139,8 +151,9 @@ func main() {
 func printUsage() {
 	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "Commands:\n")
-	fmt.Fprintf(os.Stderr, "  run       Run evaluation benchmarks\n")
-	fmt.Fprintf(os.Stderr, "  analyze   Analyze results from previous benchmark runs\n\n")
+	fmt.Fprintf(os.Stderr, "  run              Run evaluation benchmarks\n")
+	fmt.Fprintf(os.Stderr, "  analyze          Analyze results from previous benchmark runs\n")
+	fmt.Fprintf(os.Stderr, "  serve-dashboard  Serve the trends of the results uploaded to a store\n\n")
 	fmt.Fprintf(os.Stderr, "Run '%s <command> --help' for more information on a command.\n", os.Args[0])
 }
 
@@ -170,10 +183,12 @@ func run(ctx context.Context) error {
 	case "run":
 		return runEvals(ctx)
 	case "analyze":
-		return runAnalyze()
+		return runAnalyze(ctx)
+	case "serve-dashboard":
+		return runServeDashboard(ctx)
 	default:
 		printUsage()
-		return fmt.Errorf("unknown subcommand: %s, valid options are 'run' or 'analyze'", subCommand)
+		return fmt.Errorf("unknown subcommand: %s, valid options are 'run', 'analyze' or 'serve-dashboard'", subCommand)
 	}
 }
 
@@ -195,9 +210,11 @@ func runEvals(ctx context.Context) error {
 	defaultKubeConfig := "~/.kube/config"
 	enableToolUseShim := false
 	quiet := true
+	kubeconfigsDir := ""
 
 	flag.StringVar(&config.TasksDir, "tasks-dir", config.TasksDir, "Directory containing evaluation tasks")
 	flag.StringVar(&config.KubeConfig, "kubeconfig", config.KubeConfig, "Path to kubeconfig file")
+	flag.StringVar(&kubeconfigsDir, "kubeconfigs", kubeconfigsDir, "Directory of kubeconfig files, one per pre-provisioned cluster; tasks are sharded across the clusters, one task per cluster at a time")
 	flag.StringVar(&config.TaskPattern, "task-pattern", config.TaskPattern, "Pattern to filter tasks (e.g. 'pod' or 'redis')")
 	flag.StringVar(&config.AgentBin, "agent-bin", config.AgentBin, "Path to kubernetes agent binary")
 	flag.StringVar(&llmProvider, "llm-provider", llmProvider, "Specific LLM provider to evaluate (e.g. 'gemini' or 'ollama')")
@@ -218,6 +235,17 @@ func runEvals(ctx context.Context) error {
 	}
 	config.KubeConfig = expandedKubeconfig
 
+	if kubeconfigsDir != "" {
+		if config.Concurrency != 0 {
+			return fmt.Errorf("--concurrency cannot be used with --kubeconfigs, tasks run concurrently on each cluster")
+		}
+		config.KubeConfigs, err = listKubeconfigs(kubeconfigsDir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Sharding tasks across %d clusters from %s\n", len(config.KubeConfigs), kubeconfigsDir)
+	}
+
 	defaultModels := map[string][]string{
 		"gemini": {"gemini-2.5-pro"},
 	}
@@ -258,7 +286,9 @@ func runEvals(ctx context.Context) error {
 	}
 
 	// If concurrency is set to auto (0), use the number of tasks
-	if config.Concurrency == 0 {
+	if len(config.KubeConfigs) > 0 {
+		config.Concurrency = len(config.KubeConfigs)
+	} else if config.Concurrency == 0 {
 		config.Concurrency = len(tasks)
 		fmt.Printf("Auto-configuring concurrency to %d (number of tasks)\n", config.Concurrency)
 	}
@@ -270,7 +300,31 @@ func runEvals(ctx context.Context) error {
 	return nil
 }
 
-func runAnalyze() error {
+// listKubeconfigs returns the kubeconfig files in dir, sorted by name.
+// Hidden files and subdirectories are ignored.
+func listKubeconfigs(dir string) ([]string, error) {
+	dir, err := expandPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand kubeconfigs path %q: %w", dir, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfigs directory: %w", err)
+	}
+	var kubeconfigs []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		kubeconfigs = append(kubeconfigs, filepath.Join(dir, entry.Name()))
+	}
+	if len(kubeconfigs) == 0 {
+		return nil, fmt.Errorf("no kubeconfig files found in %q", dir)
+	}
+	return kubeconfigs, nil
+}
+
+func runAnalyze(ctx context.Context) error {
 	config := AnalyzeConfig{
 		InputDir:     "",
 		OutputFormat: "markdown",
@@ -289,6 +343,9 @@ func runAnalyze() error {
 	flag.StringVar(&config.OutputFormat, "output-format", config.OutputFormat, "Output format (markdown or json)")
 	flag.BoolVar(&config.IgnoreToolUseShim, "ignore-tool-use-shim", true, "Ignore tool use shim")
 	flag.StringVar(&resultsFilePath, "results-filepath", "", "Optional file path to write results to")
+	flag.StringVar(&config.UploadTo, "upload-to", config.UploadTo, "Optional store to upload the results to: gs://bucket/prefix, an http(s) endpoint or a directory")
+	flag.StringVar(&config.Commit, "commit", config.Commit, "Git commit of kubectl-ai recorded with uploaded results (defaults to the current commit)")
+	flag.StringVar(&config.Release, "release", config.Release, "kubectl-ai release recorded with uploaded results")
 	flag.Parse()
 
 	// Check if input-dir is provided
@@ -323,6 +380,20 @@ func runAnalyze() error {
 		}
 	}
 
+	if config.UploadTo != "" {
+		commit := config.Commit
+		if commit == "" {
+			commit = gitCommit(ctx)
+		}
+		now := time.Now()
+		run := model.NewRun(newRunID(now, commit), now, allResults)
+		run.Commit = commit
+		run.Release = config.Release
+		if err := uploadRun(ctx, config.UploadTo, run); err != nil {
+			return fmt.Errorf("uploading results: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -484,6 +555,38 @@ func printMarkdownResults(config AnalyzeConfig, results []model.TaskResult, resu
 		buffer.WriteString("\n\n")
 	}
 
+	// --- Model Efficiency ---
+	buffer.WriteString("## Model Efficiency\n\n")
+	buffer.WriteString("The efficiency index is the share of the tool calls of successful tasks that were neither redundant nor modifying resources in read-only tasks.\n\n")
+	buffer.WriteString("| Model | Avg Tool Calls | Redundant Calls | Mutating Calls (read-only tasks) | Efficiency Index |\n")
+	buffer.WriteString("|-------|----------------|-----------------|----------------------------------|------------------|\n")
+	for _, modelID := range models {
+		var modelResults []model.TaskResult
+		scored, toolCalls, redundantCalls, mutatingCalls := 0, 0, 0, 0
+		for _, result := range results {
+			if result.LLMConfig.ModelID != modelID {
+				continue
+			}
+			modelResults = append(modelResults, result)
+			if result.Efficiency != nil {
+				scored++
+				toolCalls += result.Efficiency.ToolCalls
+				redundantCalls += result.Efficiency.RedundantCalls
+				mutatingCalls += result.Efficiency.MutatingCalls
+			}
+		}
+		if scored == 0 {
+			buffer.WriteString(fmt.Sprintf("| %s | - | - | - | - |\n", modelID))
+			continue
+		}
+		index := "-"
+		if i, ok := efficiencyIndex(modelResults); ok {
+			index = fmt.Sprintf("%d", i)
+		}
+		buffer.WriteString(fmt.Sprintf("| %s | %.1f | %d | %d | %s |\n", modelID, float64(toolCalls)/float64(scored), redundantCalls, mutatingCalls, index))
+	}
+	buffer.WriteString("\n")
+
 	// --- Overall Summary ---
 	buffer.WriteString("## Overall Summary\n\n")
 	buffer.WriteString(fmt.Sprintf("- Total Runs: %d\n", totalCount))
@@ -600,6 +703,8 @@ func printMarkdownResults(config AnalyzeConfig, results []model.TaskResult, resu
 		}
 	}
 
+	writeFailedTaskScripts(&buffer, config, results)
+
 	// --- Footer ---
 	buffer.WriteString("---\n\n")
 	buffer.WriteString(fmt.Sprintf("_Report generated on %s_\n", time.Now().Format("January 2, 2006 at 3:04 PM")))
@@ -621,6 +726,76 @@ func printMarkdownResults(config AnalyzeConfig, results []model.TaskResult, resu
 	return nil
 }
 
+// failedScriptOutputLines is the number of lines of the output of failed
+// scripts shown in the report.
+const failedScriptOutputLines = 10
+
+// writeFailedTaskScripts lists the runs of the scripts of the failed tasks,
+// with the files holding their output and the end of the output of the
+// scripts that failed.
+func writeFailedTaskScripts(buffer *strings.Builder, config AnalyzeConfig, results []model.TaskResult) {
+	var failed []model.TaskResult
+	for _, result := range results {
+		if !strings.Contains(strings.ToLower(result.Result), "success") && len(result.Scripts) > 0 {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		if failed[i].LLMConfig.ModelID != failed[j].LLMConfig.ModelID {
+			return failed[i].LLMConfig.ModelID < failed[j].LLMConfig.ModelID
+		}
+		return failed[i].Task < failed[j].Task
+	})
+
+	buffer.WriteString("## Scripts of Failed Tasks\n\n")
+	for _, result := range failed {
+		taskOutputDir := filepath.Join(config.InputDir, result.Task, result.LLMConfig.ID)
+		buffer.WriteString(fmt.Sprintf("### %s (%s)\n\n", result.Task, result.LLMConfig.ModelID))
+		if result.Error != "" {
+			buffer.WriteString(fmt.Sprintf("Error: %s\n\n", result.Error))
+		}
+		buffer.WriteString("| Script | Exit Code | Duration | Output |\n")
+		buffer.WriteString("|--------|-----------|----------|--------|\n")
+		for _, run := range result.Scripts {
+			buffer.WriteString(fmt.Sprintf("| %s | %d | %.1fs | `%s`, `%s` |\n", run.Phase, run.ExitCode, run.DurationSeconds,
+				filepath.Join(taskOutputDir, run.Stdout), filepath.Join(taskOutputDir, run.Stderr)))
+		}
+		buffer.WriteString("\n")
+		for _, run := range result.Scripts {
+			if run.ExitCode == 0 {
+				continue
+			}
+			if run.Error != "" {
+				buffer.WriteString(fmt.Sprintf("The %s script couldn't be run: %s\n\n", run.Phase, run.Error))
+				continue
+			}
+			output := lastLines(filepath.Join(taskOutputDir, run.Stderr), failedScriptOutputLines)
+			if output == "" {
+				output = lastLines(filepath.Join(taskOutputDir, run.Stdout), failedScriptOutputLines)
+			}
+			if output != "" {
+				buffer.WriteString(fmt.Sprintf("Last lines of the output of the %s script:\n\n```\n%s\n```\n\n", run.Phase, output))
+			}
+		}
+	}
+}
+
+// lastLines returns the last n lines of a file, or "" if it can't be read.
+func lastLines(path string, n int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
 func calculatePercentage(part, total int) int {
 	if total == 0 {
 		return 0
@@ -28,6 +28,54 @@ type TaskResult struct {
 	// Error contains the error message, if there was an unexpected error during the execution of the test.
 	// This normally indicates an infrastructure failure, rather than a test failure.
 	Error string `json:"error"`
+
+	// KubeConfig is the kubeconfig the task was assigned to, which is useful
+	// to track down failures when tasks are sharded across clusters.
+	KubeConfig string `json:"kubeconfig,omitempty"`
+
+	// Efficiency scores the tool calls the agent made for the task, if its trace could be read.
+	Efficiency *Efficiency `json:"efficiency,omitempty"`
+
+	// Scripts are the runs of the setup, verifier and cleanup scripts of the
+	// task, in order, so that failed tasks can be debugged without rerunning them.
+	Scripts []ScriptRun `json:"scripts,omitempty"`
+}
+
+// ScriptRun is a run of a script of a task. Its output is saved in the
+// results directory of the task.
+type ScriptRun struct {
+	// Phase is setup, verifier or cleanup.
+	Phase   string `json:"phase"`
+	Command string `json:"command"`
+	// ExitCode is -1 if the script couldn't be run, or was killed.
+	ExitCode        int     `json:"exitCode"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Stdout and Stderr are the files holding the output of the script,
+	// relative to the results directory of the task.
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	// Error is set if the script couldn't be run.
+	Error string `json:"error,omitempty"`
+}
+
+// Efficiency scores how economically the agent used its tools for a task.
+type Efficiency struct {
+	// ToolCalls is the number of tool calls made by the agent.
+	ToolCalls int `json:"toolCalls"`
+	// RedundantCalls is the number of tool calls identical to an earlier call.
+	RedundantCalls int `json:"redundantCalls"`
+	// MutatingCalls is the number of other tool calls modifying resources,
+	// counted for read-only tasks only.
+	MutatingCalls int `json:"mutatingCalls"`
+}
+
+// Index is the share of the tool calls that were neither redundant nor
+// mutating resources for a read-only task, from 0 to 100.
+func (e *Efficiency) Index() int {
+	if e.ToolCalls == 0 {
+		return 100
+	}
+	return (e.ToolCalls - e.RedundantCalls - e.MutatingCalls) * 100 / e.ToolCalls
 }
 
 type Failure struct {
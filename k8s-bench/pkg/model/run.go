@@ -0,0 +1,56 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// Run is the results of a benchmark run, with the metadata needed to track
+// the results over time in a central store.
+type Run struct {
+	// ID identifies the run in the store.
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Commit is the git commit of kubectl-ai that was evaluated.
+	Commit string `json:"commit,omitempty"`
+	// Release is the kubectl-ai release that was evaluated, if any.
+	Release string `json:"release,omitempty"`
+	// Models are the evaluated models, as provider/model.
+	Models []string `json:"models"`
+
+	Results []TaskResult `json:"results"`
+}
+
+// ModelKey identifies the model of an LLM configuration across runs.
+func (c LLMConfig) ModelKey() string {
+	return c.ProviderID + "/" + c.ModelID
+}
+
+// NewRun returns a run of the results, recording the evaluated models.
+func NewRun(id string, timestamp time.Time, results []TaskResult) *Run {
+	seen := make(map[string]bool)
+	run := &Run{ID: id, Timestamp: timestamp, Results: results}
+	for _, result := range results {
+		if key := result.LLMConfig.ModelKey(); !seen[key] {
+			seen[key] = true
+			run.Models = append(run.Models, key)
+		}
+	}
+	sort.Strings(run.Models)
+	return run
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/k8s-bench/pkg/model"
+)
+
+// The results of runs are published to a store, which is one of:
+//   - a GCS location (gs://bucket/prefix), accessed with the gcloud CLI;
+//   - an HTTP endpoint, to which runs are POSTed as JSON, and which lists
+//     them as a JSON array on GET;
+//   - a local directory.
+//
+// Runs are stored as one <run-id>.json file in GCS and local directories.
+
+// storeTokenEnv is the environment variable holding the bearer token of HTTP stores.
+const storeTokenEnv = "K8S_BENCH_STORE_TOKEN"
+
+// newRunID returns the ID of a run at the given time of the given commit.
+func newRunID(timestamp time.Time, commit string) string {
+	id := timestamp.UTC().Format("20060102-150405")
+	if commit != "" {
+		id += "-" + commit[:min(len(commit), 12)]
+	}
+	return id
+}
+
+// gitCommit returns the commit checked out in the current directory, if any.
+func gitCommit(ctx context.Context) string {
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func isHTTPStore(store string) bool {
+	return strings.HasPrefix(store, "http://") || strings.HasPrefix(store, "https://")
+}
+
+// uploadRun publishes the run to the store.
+func uploadRun(ctx context.Context, store string, run *model.Run) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling run to JSON: %w", err)
+	}
+	name := run.ID + ".json"
+
+	switch {
+	case strings.HasPrefix(store, "gs://"):
+		dest := strings.TrimSuffix(store, "/") + "/" + name
+		cmd := exec.CommandContext(ctx, "gcloud", "storage", "cp", "-", dest)
+		cmd.Stdin = bytes.NewReader(data)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("copying run to %s: %w: %s", dest, err, out)
+		}
+	case isHTTPStore(store):
+		req, err := newStoreRequest(ctx, http.MethodPost, store, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("posting run to %s: %w", store, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return fmt.Errorf("posting run to %s: %s: %s", store, resp.Status, bytes.TrimSpace(body))
+		}
+	default:
+		dir, err := expandPath(store)
+		if err != nil {
+			return fmt.Errorf("failed to expand store path %q: %w", store, err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating store directory: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return fmt.Errorf("writing run: %w", err)
+		}
+	}
+
+	fmt.Printf("Uploaded run %s with %d results to %s\n", run.ID, len(run.Results), store)
+	return nil
+}
+
+// loadRuns returns the runs published to the store, oldest first.
+func loadRuns(ctx context.Context, store string) ([]model.Run, error) {
+	var runs []model.Run
+
+	switch {
+	case strings.HasPrefix(store, "gs://"):
+		src := strings.TrimSuffix(store, "/") + "/*.json"
+		cmd := exec.CommandContext(ctx, "gcloud", "storage", "cat", src)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("reading runs from %s: %w: %s", src, err, stderr.Bytes())
+		}
+		// The runs are concatenated JSON documents.
+		decoder := json.NewDecoder(bytes.NewReader(out))
+		for {
+			var run model.Run
+			if err := decoder.Decode(&run); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("parsing runs from %s: %w", src, err)
+			}
+			runs = append(runs, run)
+		}
+	case isHTTPStore(store):
+		req, err := newStoreRequest(ctx, http.MethodGet, store, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("listing runs from %s: %w", store, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("listing runs from %s: %s", store, resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&runs); err != nil {
+			return nil, fmt.Errorf("parsing runs from %s: %w", store, err)
+		}
+	default:
+		dir, err := expandPath(store)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand store path %q: %w", store, err)
+		}
+		paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading file %s: %w", path, err)
+			}
+			var run model.Run
+			if err := json.Unmarshal(data, &run); err != nil {
+				return nil, fmt.Errorf("parsing run from %s: %w", path, err)
+			}
+			runs = append(runs, run)
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Timestamp.Before(runs[j].Timestamp)
+	})
+	return runs, nil
+}
+
+func newStoreRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request to %s: %w", url, err)
+	}
+	if token := os.Getenv(storeTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
@@ -82,16 +82,23 @@ type unstructuredToCELAdapter struct {
 
 func (a *unstructuredToCELAdapter) NativeToValue(value any) ref.Val {
 	switch value := value.(type) {
+	case nil:
+		return celtypes.NullValue
+	case bool:
+		return celtypes.Bool(value)
 	case string:
 		return celtypes.String(value)
 	case int:
 		return celtypes.Int(value)
 	case int64:
 		return celtypes.Int(value)
+	case float64:
+		return celtypes.Double(value)
 	case map[string]any:
 		return celtypes.NewDynamicMap(a, value)
+	case []any:
+		return celtypes.NewDynamicList(a, value)
 	default:
-		klog.Fatalf("unhandled type %T", value)
-		return nil
+		return celtypes.DefaultTypeAdapter.NativeToValue(value)
 	}
 }
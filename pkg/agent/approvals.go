@@ -0,0 +1,356 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// The tool calls of an iteration that require confirmation are queued for
+// approval. Terminal UIs decide on all of them at once, while the web UI can
+// approve, decline or edit them one by one, and approve groups of identical
+// read-only calls together. Messages sent by the user meanwhile are queued,
+// and sent to the LLM with the results of the tool calls.
+
+// PendingApprovals returns the tool calls awaiting the decision of the user.
+func (c *Agent) PendingApprovals() []api.PendingApproval {
+	c.approvalsMu.Lock()
+	defer c.approvalsMu.Unlock()
+	return slices.Clone(c.approvals)
+}
+
+func (c *Agent) setPendingApprovals(approvals []api.PendingApproval) {
+	c.approvalsMu.Lock()
+	defer c.approvalsMu.Unlock()
+	c.approvals = approvals
+}
+
+// queueApprovals queues the pending tool calls requiring confirmation, and
+// returns their descriptions with a preview of their effect.
+func (c *Agent) queueApprovals(ctx context.Context) []string {
+	// IDs are unique across iterations, so that late decisions on the calls
+	// of a previous iteration are not applied to the new ones.
+	batch := uuid.New().String()[:8]
+	c.approvalIDs = map[string]int{}
+	c.decisions = map[int]*api.Approval{}
+
+	var descriptions []string
+	var approvals []api.PendingApproval
+	now := time.Now()
+	for i, call := range c.pendingFunctionCalls {
+		preview := c.commandPreview(ctx, call)
+		descriptions = append(descriptions, call.ParsedToolCall.Description()+preview)
+		if !c.requiresApproval(call, now) {
+			continue
+		}
+		approval := api.PendingApproval{
+			ID:          fmt.Sprintf("%s-%d", batch, i),
+			Description: call.ParsedToolCall.Description(),
+			Preview:     strings.TrimSpace(preview),
+			Group:       approvalGroup(call),
+		}
+		approval.Command, _ = call.FunctionCall.Arguments["command"].(string)
+		if freeze := c.activeFreeze(call, now); freeze != nil {
+			approval.Freeze = freeze.Name
+		}
+		c.approvalIDs[approval.ID] = i
+		approvals = append(approvals, approval)
+	}
+	c.setPendingApprovals(approvals)
+	return descriptions
+}
+
+// rejectForbiddenCalls checks the permissions of the current identity on the
+// kubectl commands of the pending calls requiring approval, and rejects all
+// the pending calls if some are not allowed, telling the LLM why so that it
+// re-plans instead of failing once approved. It reports whether the calls
+// were rejected.
+func (c *Agent) rejectForbiddenCalls(ctx context.Context) bool {
+	forbidden := map[int]string{}
+	for i, call := range c.pendingFunctionCalls {
+		if reason := c.forbiddenReason(ctx, call); reason != "" {
+			forbidden[i] = reason
+		}
+	}
+	if len(forbidden) == 0 {
+		return false
+	}
+
+	for i, call := range c.pendingFunctionCalls {
+		reason, ok := forbidden[i]
+		status := "forbidden"
+		if ok {
+			c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("  Not allowed: %s: %s\n", call.ParsedToolCall.Description(), reason))
+			reason = "Not run: " + reason + ". Find another way or tell the user which permission is missing."
+		} else {
+			reason = "Not run, because other commands of the same turn are not allowed."
+			status = "skipped"
+		}
+		if c.EnableToolUseShim {
+			c.currChatContent = append(c.currChatContent, fmt.Sprintf("Result of running %q:\n%s", call.FunctionCall.Name, reason))
+			continue
+		}
+		c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
+			ID:   call.FunctionCall.ID,
+			Name: call.FunctionCall.Name,
+			Result: map[string]any{
+				"error":     reason,
+				"status":    status,
+				"retryable": false,
+			},
+		})
+	}
+	return true
+}
+
+// forbiddenReason checks the permissions of the current identity on the
+// kubectl commands of a call that may modify resources, and returns why it is
+// not allowed, "" if it is or the check failed.
+func (c *Agent) forbiddenReason(ctx context.Context, call ToolCallAnalysis) string {
+	command, _ := call.FunctionCall.Arguments["command"].(string)
+	if call.ModifiesResourceStr == "no" || command == "" {
+		return ""
+	}
+	checks := tools.RequiredAccess(command)
+	if len(checks) == 0 {
+		return ""
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	denied, err := tools.CheckAccess(checkCtx, checks, tools.InvokeToolOptions{
+		Kubeconfig: c.Kubeconfig,
+		WorkDir:    c.workDir,
+		Env:        c.env,
+	})
+	if err != nil {
+		// The command is proposed as is, the API server has the last word.
+		klog.FromContext(ctx).Info("RBAC preflight failed", "command", command, "err", err)
+		return ""
+	}
+	var reasons []string
+	for j := range checks {
+		if reason, ok := denied[j]; ok {
+			reasons = append(reasons, reason)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// approvalGroup returns the shape of a read-only call, e.g. "bash: kubectl get
+// pods", shared by the calls that can be approved together, or "" for calls
+// that may modify resources.
+func approvalGroup(call ToolCallAnalysis) string {
+	command, _ := call.FunctionCall.Arguments["command"].(string)
+	if command == "" || !tools.IsReadOnlyPipeline(command) {
+		return ""
+	}
+	var shapes []string
+	for _, kc := range tools.ParseKubectlCommands(command) {
+		resourceType, _, _ := strings.Cut(kc.Resource, "/")
+		shapes = append(shapes, strings.Join(strings.Fields("kubectl "+kc.Verb+" "+kc.SubVerb+" "+resourceType), " "))
+	}
+	return call.FunctionCall.Name + ": " + strings.Join(shapes, ", ")
+}
+
+// awaitingDecisions reports whether pending tool calls still await the
+// decision of the user.
+func (c *Agent) awaitingDecisions() bool {
+	return c.decisions != nil
+}
+
+// undecidedCalls returns the indexes of the pending calls requiring
+// confirmation that were not decided yet.
+func (c *Agent) undecidedCalls() []int {
+	var indexes []int
+	now := time.Now()
+	for i, call := range c.pendingFunctionCalls {
+		if _, decided := c.decisions[i]; !decided && c.requiresApproval(call, now) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// choiceCalls returns the indexes of the pending calls a choice applies to.
+func (c *Agent) choiceCalls(choice *api.UserChoiceResponse) ([]int, error) {
+	if len(choice.CallIDs) == 0 {
+		return c.undecidedCalls(), nil
+	}
+	var indexes []int
+	for _, id := range choice.CallIDs {
+		i, ok := c.approvalIDs[id]
+		if _, decided := c.decisions[i]; !ok || decided {
+			return nil, fmt.Errorf("tool call %s is not awaiting approval", id)
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// decide records the decision on the pending calls, approval being nil for
+// the declined ones, and reports whether calls remain undecided.
+func (c *Agent) decide(indexes []int, approval *api.Approval) (undecided bool) {
+	if c.decisions == nil {
+		c.decisions = map[int]*api.Approval{}
+	}
+	for _, i := range indexes {
+		c.decisions[i] = approval
+	}
+
+	remaining := c.undecidedCalls()
+	var approvals []api.PendingApproval
+	for _, pending := range c.PendingApprovals() {
+		if slices.Contains(remaining, c.approvalIDs[pending.ID]) {
+			approvals = append(approvals, pending)
+		}
+	}
+	c.setPendingApprovals(approvals)
+	return len(remaining) > 0
+}
+
+// settleDecisions removes the declined calls from the pending calls once all
+// were decided, telling the LLM they were declined, and reports whether calls
+// remain to dispatch.
+func (c *Agent) settleDecisions() bool {
+	var remaining []ToolCallAnalysis
+	declined := 0
+	for i, call := range c.pendingFunctionCalls {
+		approval, decided := c.decisions[i]
+		if !decided || approval != nil {
+			call.Approval = approval
+			remaining = append(remaining, call)
+			continue
+		}
+		declined++
+		if call.UserInitiated {
+			continue
+		}
+		if c.EnableToolUseShim {
+			c.currChatContent = append(c.currChatContent, fmt.Sprintf("Result of running %q:\nUser declined to run this operation.", call.FunctionCall.Name))
+			continue
+		}
+		c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
+			ID:   call.FunctionCall.ID,
+			Name: call.FunctionCall.Name,
+			Result: map[string]any{
+				"error":     "User declined to run this operation.",
+				"status":    "declined",
+				"retryable": false,
+			},
+		})
+	}
+	c.pendingFunctionCalls = remaining
+	c.clearDecisions()
+
+	switch {
+	case declined > 0 && len(remaining) == 0:
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Operation was skipped. User declined to run this operation.")
+	case declined > 0:
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("%d operation(s) were skipped. User declined to run them.", declined))
+	}
+	return len(remaining) > 0
+}
+
+func (c *Agent) clearDecisions() {
+	c.decisions = nil
+	c.approvalIDs = nil
+	c.setPendingApprovals(nil)
+}
+
+// refusal returns why the guards of the main loop refuse a call without
+// asking for its approval, "" if they don't: the session is read-only and the
+// call modifies resources, the policy denies it, or the RBAC preflight finds
+// it forbidden. It applies them to the calls that skip the main loop, i.e. the
+// snippets and the edited calls.
+func (c *Agent) refusal(ctx context.Context, call ToolCallAnalysis) string {
+	if c.ReadOnly && call.ModifiesResourceStr != "no" {
+		return "the session is read-only, and it modifies or may modify resources"
+	}
+	if call.policyVerdict() == tools.PolicyDeny {
+		return policyDenial(call.Policy)
+	}
+	if c.RBACPreflight {
+		if reason := c.forbiddenReason(ctx, call); reason != "" {
+			return "not allowed: " + reason
+		}
+	}
+	return ""
+}
+
+// editPendingCall replaces the command of a pending call, as edited by the
+// user before approving it, checking the edited call like a new one: the
+// reason to break a freeze is required if it is frozen. The LLM is told about
+// the edit with the results.
+func (c *Agent) editPendingCall(ctx context.Context, i int, command, breakGlassReason string) error {
+	call := c.pendingFunctionCalls[i]
+	if _, ok := call.FunctionCall.Arguments["command"].(string); !ok {
+		return fmt.Errorf("the %s tool call has no command to edit", call.FunctionCall.Name)
+	}
+	edited := call.FunctionCall
+	edited.Arguments = maps.Clone(call.FunctionCall.Arguments)
+	edited.Arguments["command"] = command
+	analysis, err := c.analyzeToolCalls(ctx, []gollm.FunctionCall{edited})
+	if err != nil {
+		return err
+	}
+	if analysis[0].IsInteractive {
+		return analysis[0].IsInteractiveError
+	}
+	// The guards of the main loop ran on the command as proposed.
+	if reason := c.refusal(ctx, analysis[0]); reason != "" {
+		return fmt.Errorf("the edited command is refused: %s", reason)
+	}
+	if freeze := c.activeFreeze(analysis[0], time.Now()); freeze != nil && strings.TrimSpace(breakGlassReason) == "" {
+		return fmt.Errorf("changes are frozen by %q, a reason is required to approve the edited command", freeze.Name)
+	}
+	analysis[0].UserInitiated = call.UserInitiated
+	c.pendingFunctionCalls[i] = analysis[0]
+	c.queuedContent = append(c.queuedContent, fmt.Sprintf("Before approving it, I edited the command of your %s tool call to:\n%s", edited.Name, command))
+	return nil
+}
+
+// queueInput records a message sent by the user while tool calls await
+// approval, to send it to the LLM with their results.
+func (c *Agent) queueInput(ctx context.Context, query *api.UserInputResponse) {
+	if strings.TrimSpace(query.Query) == "" && len(query.Images) == 0 {
+		return
+	}
+	author := query.Author
+	if author == "" {
+		author = localApprover()
+	}
+	c.addUserMessage(ctx, describeUserInput(query), author)
+	c.queuedContent = append(c.queuedContent, withAuthor(query.Query, query.Author))
+	for _, image := range query.Images {
+		c.queuedContent = append(c.queuedContent, gollm.ImagePart{MIMEType: image.MIMEType, Data: image.Data})
+	}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "Tool calls are awaiting approval. Your message will be sent with their results.")
+}
+
+// confirmedApproval returns the approval of a call confirmed by the approver.
+func confirmedApproval(approver string) *api.Approval {
+	return &api.Approval{Approver: approver, Method: api.ApprovalMethodConfirmed, Timestamp: time.Now()}
+}
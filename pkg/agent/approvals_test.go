@@ -0,0 +1,220 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func TestApprovalGroup(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "kubectl get pods -n shop | grep web", want: "bash: kubectl get pods"},
+		{command: "kubectl get pod/web-1 -n shop -o yaml", want: "bash: kubectl get pod"},
+		{command: "kubectl rollout status deployment/web", want: "bash: kubectl rollout status deployment"},
+		{command: "kubectl delete pod web-1"},
+		{command: "kubectl get pods > pods.txt"},
+		{command: "cat /etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		call := ToolCallAnalysis{FunctionCall: gollm.FunctionCall{Name: "bash", Arguments: map[string]any{"command": tt.command}}}
+		if got := approvalGroup(call); got != tt.want {
+			t.Errorf("approvalGroup(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestQueuedApprovals(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("bash").AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).DoAndReturn(func(args map[string]any) string {
+		if strings.HasPrefix(args["command"].(string), "./restart.sh") {
+			return "yes"
+		}
+		return "no"
+	}).AnyTimes()
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	a := &Agent{
+		Tools:   ts,
+		session: &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:  make(chan any, 20),
+	}
+	var calls []gollm.FunctionCall
+	for i, command := range []string{"./restart.sh web", "./restart.sh api", "./restart.sh db", "ls"} {
+		calls = append(calls, gollm.FunctionCall{ID: string(rune('a' + i)), Name: "bash", Arguments: map[string]any{"command": command}})
+	}
+	var err error
+	a.pendingFunctionCalls, err = a.analyzeToolCalls(ctx, calls)
+	if err != nil {
+		t.Fatalf("analyzeToolCalls: %v", err)
+	}
+
+	if got := a.queueApprovals(ctx); len(got) != 4 {
+		t.Errorf("queueApprovals() returned %d descriptions, want 4", len(got))
+	}
+	pending := a.PendingApprovals()
+	if len(pending) != 3 || pending[0].Command != "./restart.sh web" {
+		t.Fatalf("PendingApprovals() = %+v, want the 3 restarts", pending)
+	}
+
+	// Approving a single call leaves the others pending.
+	if a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 1, CallIDs: []string{pending[0].ID}, Approver: "alice"}) {
+		t.Errorf("handleChoice() dispatched the calls while others await a decision")
+	}
+	if got := a.PendingApprovals(); !reflect.DeepEqual(got, pending[1:]) {
+		t.Errorf("PendingApprovals() = %+v, want %+v", got, pending[1:])
+	}
+
+	// Decisions on decided or unknown calls are ignored.
+	for _, id := range []string{pending[0].ID, "stale-0"} {
+		if a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 3, CallIDs: []string{id}}) || !a.awaitingDecisions() {
+			t.Errorf("handleChoice() applied a choice on call %s", id)
+		}
+	}
+
+	// Messages sent meanwhile are queued for the LLM.
+	a.queueInput(ctx, &api.UserInputResponse{Query: "skip the database if it is busy"})
+	if len(a.queuedContent) != 1 {
+		t.Errorf("queuedContent = %v, want the message of the user", a.queuedContent)
+	}
+
+	// The command of a call can be edited before approving it.
+	if a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 1, CallIDs: []string{pending[1].ID}, Command: "./restart.sh api --graceful"}) {
+		t.Errorf("handleChoice() dispatched the calls while others await a decision")
+	}
+	if got := a.pendingFunctionCalls[1].FunctionCall.Arguments["command"]; got != "./restart.sh api --graceful" {
+		t.Errorf("edited command = %v, want ./restart.sh api --graceful", got)
+	}
+	if calls[1].Arguments["command"] != "./restart.sh api" {
+		t.Errorf("editing the command modified the original call")
+	}
+	if len(a.queuedContent) != 2 {
+		t.Errorf("queuedContent = %v, want a note about the edit", a.queuedContent)
+	}
+
+	// Declining the last call dispatches the others.
+	if !a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 3, CallIDs: []string{pending[2].ID}}) {
+		t.Fatalf("handleChoice() didn't dispatch the approved calls")
+	}
+	if a.awaitingDecisions() || len(a.PendingApprovals()) != 0 {
+		t.Errorf("approvals still pending after all calls were decided")
+	}
+	var commands []string
+	for _, call := range a.pendingFunctionCalls {
+		commands = append(commands, call.FunctionCall.Arguments["command"].(string))
+	}
+	if want := []string{"./restart.sh web", "./restart.sh api --graceful", "ls"}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("dispatched commands = %q, want %q", commands, want)
+	}
+	if approval := a.pendingFunctionCalls[0].Approval; approval == nil || approval.Approver != "alice" {
+		t.Errorf("approval of the first call = %+v, want approved by alice", approval)
+	}
+	want := []any{gollm.FunctionCallResult{ID: "c", Name: "bash", Result: map[string]any{
+		"error":     "User declined to run this operation.",
+		"status":    "declined",
+		"retryable": false,
+	}}}
+	if !reflect.DeepEqual(a.currChatContent, want) {
+		t.Errorf("currChatContent = %v, want %v", a.currChatContent, want)
+	}
+}
+
+func TestRejectForbiddenCalls(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	// The fake kubectl only allows restarting deployments.
+	dir := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$3\" = patch ]; then echo yes; else echo no; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("bash").AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).Return("yes").AnyTimes()
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	a := &Agent{
+		Tools:   ts,
+		session: &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:  make(chan any, 20),
+		workDir: dir,
+	}
+	analyze := func(commands ...string) {
+		t.Helper()
+		var calls []gollm.FunctionCall
+		for i, command := range commands {
+			calls = append(calls, gollm.FunctionCall{ID: string(rune('a' + i)), Name: "bash", Arguments: map[string]any{"command": command}})
+		}
+		var err error
+		if a.pendingFunctionCalls, err = a.analyzeToolCalls(ctx, calls); err != nil {
+			t.Fatalf("analyzeToolCalls: %v", err)
+		}
+	}
+
+	analyze("kubectl rollout restart deployment/web -n shop")
+	if a.rejectForbiddenCalls(ctx) || len(a.currChatContent) != 0 {
+		t.Errorf("rejectForbiddenCalls() rejected allowed calls: %v", a.currChatContent)
+	}
+
+	analyze("kubectl rollout restart deployment/web -n shop", "kubectl delete pod web-0 -n shop")
+	if !a.rejectForbiddenCalls(ctx) {
+		t.Fatalf("rejectForbiddenCalls() didn't reject the forbidden call")
+	}
+	var statuses []any
+	for _, content := range a.currChatContent {
+		statuses = append(statuses, content.(gollm.FunctionCallResult).Result["status"])
+	}
+	if want := []any{"skipped", "forbidden"}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses of the results = %v, want %v", statuses, want)
+	}
+
+	// Commands edited before their approval are checked too.
+	a.RBACPreflight = true
+	analyze("kubectl rollout restart deployment/web -n shop")
+	if err := a.editPendingCall(ctx, 0, "kubectl delete pod web-0 -n shop", ""); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("editPendingCall() = %v, want the forbidden command rejected", err)
+	}
+	if got := a.pendingFunctionCalls[0].FunctionCall.Arguments["command"]; got != "kubectl rollout restart deployment/web -n shop" {
+		t.Errorf("command after a rejected edit = %v, want the original one", got)
+	}
+}
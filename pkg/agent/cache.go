@@ -0,0 +1,242 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultAnswerCacheTTL is the time answers are cached for by default.
+	DefaultAnswerCacheTTL = 5 * time.Minute
+	// maxCachedResources bounds the resources whose versions are checked
+	// before a cached answer is reused.
+	maxCachedResources = 20
+)
+
+// AnswerCache caches the answers of queries that only ran read-only tool
+// calls, so that asking the same question again, e.g. from a dashboard or a
+// script, doesn't call the model. Answers are keyed by the query, the model
+// and the cluster, and reused within the TTL as long as the resources read
+// by the kubectl get and describe commands of the query didn't change.
+type AnswerCache struct {
+	// Dir is the directory of the cached answers.
+	Dir string
+	// TTL is the time answers are reused for.
+	TTL time.Duration
+}
+
+// NewAnswerCache returns a cache of answers in ~/.kubectl-ai/cache.
+func NewAnswerCache(ttl time.Duration) (*AnswerCache, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return &AnswerCache{Dir: filepath.Join(homeDir, ".kubectl-ai", "cache"), TTL: ttl}, nil
+}
+
+// cachedAnswer is the file of a cached answer.
+type cachedAnswer struct {
+	Query     string           `json:"query"`
+	Answer    string           `json:"answer"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Resources []cachedResource `json:"resources,omitempty"`
+}
+
+// cachedResource is a resource read by the query, with the state of its
+// objects when the answer was cached.
+type cachedResource struct {
+	// Args are the kubectl arguments listing the uid and resourceVersion of the objects.
+	Args []string `json:"args"`
+	// State is a hash of the objects, or the error of kubectl.
+	State string `json:"state"`
+}
+
+// cacheableQuery tracks the tool calls of the current query, whose answer is
+// cached if they are all read-only.
+type cacheableQuery struct {
+	query string
+	// readOnly is false once a tool call that may change resources was requested.
+	readOnly bool
+	// commands are the kubectl commands that read resources.
+	commands []tools.KubectlCommand
+}
+
+// record accounts a tool call requested by the model for the query.
+func (q *cacheableQuery) record(call ToolCallAnalysis) {
+	if call.ModifiesResourceStr != "no" || call.IsInteractive {
+		q.readOnly = false
+		return
+	}
+	command, _ := call.FunctionCall.Arguments["command"].(string)
+	for _, kc := range tools.ParseKubectlCommands(command) {
+		if (kc.Verb == "get" || kc.Verb == "describe") && kc.Resource != "" && len(q.commands) < maxCachedResources {
+			q.commands = append(q.commands, kc)
+		}
+	}
+}
+
+// resourceArgs returns the kubectl arguments listing the uid and
+// resourceVersion of the objects read by a command.
+func resourceArgs(kc tools.KubectlCommand) []string {
+	jsonPath := "{range .items[*]}{.metadata.uid}={.metadata.resourceVersion} {end}"
+	if strings.Contains(kc.Resource, "/") {
+		jsonPath = "{.metadata.uid}={.metadata.resourceVersion}"
+	}
+	args := []string{"get", kc.Resource, "-o", "jsonpath=" + jsonPath}
+	if kc.AllNamespaces {
+		args = append(args, "--all-namespaces")
+	} else if kc.Namespace != "" {
+		args = append(args, "--namespace", kc.Namespace)
+	}
+	if kc.Selector != "" {
+		args = append(args, "--selector", kc.Selector)
+	}
+	return args
+}
+
+// resourceState returns the state of the objects listed with args, which
+// changes whenever one of them is created, updated or deleted.
+func (c *Agent) resourceState(ctx context.Context, args []string) string {
+	out, err := c.kubectlOutput(ctx, args...)
+	if err != nil {
+		return "error"
+	}
+	sum := sha256.Sum256(out)
+	return hex.EncodeToString(sum[:])
+}
+
+// answerCacheKey returns the key of the answers to the query, for the model
+// and the cluster and namespace of the current context.
+func (c *Agent) answerCacheKey(ctx context.Context, query string) (string, error) {
+	cluster, err := c.kubectlOutput(ctx, "config", "view", "--minify", "-o", "jsonpath={.clusters[0].cluster.server} {.contexts[0].context.user} {.contexts[0].context.namespace}")
+	if err != nil {
+		return "", fmt.Errorf("getting the cluster of the current context: %w", err)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{c.Provider, c.Model, string(cluster), strings.TrimSpace(query)}, "\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// answerFromCache posts the cached answer to the query, if there is one that
+// is still valid, and reports whether it did.
+func (c *Agent) answerFromCache(ctx context.Context, query string) bool {
+	if c.AnswerCache == nil {
+		return false
+	}
+	log := klog.FromContext(ctx)
+	key, err := c.answerCacheKey(ctx, query)
+	if err != nil {
+		log.V(2).Info("not looking up the answer cache", "error", err)
+		return false
+	}
+	path := filepath.Join(c.AnswerCache.Dir, key+".json")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Error(err, "error reading cached answer", "path", path)
+		}
+		return false
+	}
+	var cached cachedAnswer
+	if err := json.Unmarshal(b, &cached); err != nil || cached.Query != strings.TrimSpace(query) {
+		return false
+	}
+	age := time.Since(cached.CreatedAt)
+	if age > c.AnswerCache.TTL {
+		os.Remove(path)
+		return false
+	}
+	for _, resource := range cached.Resources {
+		if c.resourceState(ctx, resource.Args) != resource.State {
+			log.Info("cached answer is stale", "resource", strings.Join(resource.Args[:2], " "))
+			os.Remove(path)
+			return false
+		}
+	}
+
+	labeledText, snippets := LabelSnippets(cached.Answer)
+	if len(snippets) > 0 {
+		c.snippets = snippets
+	}
+	c.addMessage(api.MessageSourceModel, api.MessageTypeText, labeledText)
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("(Cached answer from %s ago, the resources it read are unchanged. Use --no-cache to ask the model again.)", age.Round(time.Second)))
+	return true
+}
+
+// cacheAnswer caches the answer of the current query if all its tool calls
+// were read-only.
+func (c *Agent) cacheAnswer(ctx context.Context, answer string) {
+	q := c.cacheable
+	c.cacheable = cacheableQuery{}
+	if c.AnswerCache == nil || !q.readOnly || q.query == "" || answer == "" {
+		return
+	}
+	log := klog.FromContext(ctx)
+	key, err := c.answerCacheKey(ctx, q.query)
+	if err != nil {
+		log.V(2).Info("not caching the answer", "error", err)
+		return
+	}
+
+	cached := cachedAnswer{Query: strings.TrimSpace(q.query), Answer: answer, CreatedAt: time.Now()}
+	for _, kc := range q.commands {
+		args := resourceArgs(kc)
+		cached.Resources = append(cached.Resources, cachedResource{Args: args, State: c.resourceState(ctx, args)})
+	}
+	b, err := json.Marshal(cached)
+	if err != nil {
+		log.Error(err, "error marshaling cached answer")
+		return
+	}
+	if err := writeCacheFile(c.AnswerCache.Dir, key+".json", b); err != nil {
+		log.Error(err, "error caching answer")
+	}
+}
+
+// writeCacheFile atomically writes a file of a cache, as concurrent
+// processes may write the same file.
+func writeCacheFile(dir, name string, b []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, strings.TrimSuffix(name, ".json")+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+func TestCacheableQueryRecord(t *testing.T) {
+	call := func(command, modifies string) ToolCallAnalysis {
+		return ToolCallAnalysis{
+			FunctionCall:        gollm.FunctionCall{Name: "kubectl", Arguments: map[string]any{"command": command}},
+			ModifiesResourceStr: modifies,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		calls        []ToolCallAnalysis
+		wantReadOnly bool
+		wantArgs     [][]string
+	}{
+		{
+			name: "read-only",
+			calls: []ToolCallAnalysis{
+				call("kubectl get pods -n shop -l app=web", "no"),
+				call("kubectl describe deployment web -n shop", "no"),
+				call("kubectl logs web-1 -n shop", "no"),
+			},
+			wantReadOnly: true,
+			wantArgs: [][]string{
+				{"get", "pods", "-o", "jsonpath={range .items[*]}{.metadata.uid}={.metadata.resourceVersion} {end}", "--namespace", "shop", "--selector", "app=web"},
+				{"get", "deployment/web", "-o", "jsonpath={.metadata.uid}={.metadata.resourceVersion}", "--namespace", "shop"},
+			},
+		},
+		{
+			name: "all namespaces",
+			calls: []ToolCallAnalysis{
+				call("kubectl get nodes; kubectl get pods -A", "no"),
+			},
+			wantReadOnly: true,
+			wantArgs: [][]string{
+				{"get", "nodes", "-o", "jsonpath={range .items[*]}{.metadata.uid}={.metadata.resourceVersion} {end}"},
+				{"get", "pods", "-o", "jsonpath={range .items[*]}{.metadata.uid}={.metadata.resourceVersion} {end}", "--all-namespaces"},
+			},
+		},
+		{
+			name: "modifying call",
+			calls: []ToolCallAnalysis{
+				call("kubectl get pods", "no"),
+				call("kubectl delete pod web-1", "yes"),
+			},
+			wantReadOnly: false,
+		},
+		{
+			name: "unknown call",
+			calls: []ToolCallAnalysis{
+				call("./cleanup.sh", "unknown"),
+			},
+			wantReadOnly: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := cacheableQuery{query: "is web healthy?", readOnly: true}
+			for _, c := range tt.calls {
+				q.record(c)
+			}
+			if q.readOnly != tt.wantReadOnly {
+				t.Errorf("readOnly = %v, want %v", q.readOnly, tt.wantReadOnly)
+			}
+			if !tt.wantReadOnly {
+				return
+			}
+			var args [][]string
+			for _, kc := range q.commands {
+				args = append(args, resourceArgs(kc))
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("resource arguments = %q, want %q", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCacheAnswerSkipsQueriesChangingResources(t *testing.T) {
+	a := &Agent{
+		AnswerCache: &AnswerCache{Dir: t.TempDir(), TTL: DefaultAnswerCacheTTL},
+		cacheable:   cacheableQuery{query: "restart web", readOnly: false},
+	}
+	a.cacheAnswer(context.Background(), "Restarted deployment/web.")
+	if a.cacheable.query != "" {
+		t.Errorf("tracked query wasn't reset")
+	}
+	entries, err := os.ReadDir(a.AnswerCache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("cache contains %v, want no answer cached", entries)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// ChangeRecord is how the changes made by the agent are recorded on the
+// objects they modify, so that the operators looking at an object later can
+// trace a change back to the session.
+type ChangeRecord string
+
+const (
+	// ChangeRecordNone doesn't record the changes on the objects.
+	ChangeRecordNone ChangeRecord = "none"
+	// ChangeRecordEvent creates an Event on each object changed, shown by
+	// kubectl describe until the events expire (1 hour by default).
+	ChangeRecordEvent ChangeRecord = "event"
+	// ChangeRecordAnnotation sets the kubectl-ai/session, kubectl-ai/approver
+	// and kubectl-ai/change annotations of each object changed, which stay
+	// until the next change.
+	ChangeRecordAnnotation ChangeRecord = "annotation"
+)
+
+// ParseChangeRecord parses the value of --record-changes.
+func ParseChangeRecord(s string) (ChangeRecord, error) {
+	switch r := ChangeRecord(s); r {
+	case ChangeRecordNone, ChangeRecordEvent, ChangeRecordAnnotation:
+		return r, nil
+	}
+	return "", fmt.Errorf("unsupported change record %q (supported values: %s, %s, %s)", s, ChangeRecordNone, ChangeRecordEvent, ChangeRecordAnnotation)
+}
+
+const (
+	// changeEventReason is the reason of the events recording changes.
+	changeEventReason = "ModifiedByKubectlAI"
+	// maxChangeMessageLength keeps the messages of the events, and the
+	// annotations, short. The API server rejects event messages over 1 KiB.
+	maxChangeMessageLength = 512
+)
+
+// changedObject is an object changed by a tool call, as given to kubectl.
+type changedObject struct {
+	// Resource is the type and name of the object, e.g. "deployment/web".
+	Resource  string
+	Namespace string
+	Context   string
+}
+
+// kubectlArgs returns the arguments of a kubectl command on the object.
+func (o changedObject) kubectlArgs(args ...string) []string {
+	args = append(args, o.Resource)
+	if o.Namespace != "" {
+		args = append(args, "-n", o.Namespace)
+	}
+	if o.Context != "" {
+		args = append(args, "--context", o.Context)
+	}
+	return args
+}
+
+// changedObjects returns the objects changed by a successful tool call that
+// can be identified: the objects applied with apply_manifest, and the objects
+// named in kubectl commands. Deleted objects, and the objects selected with
+// -l, --all or -f, are not.
+func changedObjects(call ToolCallAnalysis, output any, revision *tools.ManifestRevision) []changedObject {
+	var objects []changedObject
+	switch result := output.(type) {
+	case *tools.ApplyManifestResult:
+		if revision == nil || result.DryRun || result.Error != "" || len(result.Applied) == 0 {
+			return nil
+		}
+		for _, obj := range revision.Objects {
+			apiVersion, _ := obj["apiVersion"].(string)
+			kind, _ := obj["kind"].(string)
+			metadata, _ := obj["metadata"].(map[string]any)
+			name, _ := metadata["name"].(string)
+			namespace, _ := metadata["namespace"].(string)
+			ref := tools.ObjectRef{APIVersion: apiVersion, Kind: kind, Name: name, Namespace: namespace}
+			objects = append(objects, changedObject{Resource: ref.Resource(), Namespace: ref.Namespace})
+		}
+		for _, ref := range revision.Missing {
+			objects = append(objects, changedObject{Resource: ref.Resource(), Namespace: ref.Namespace})
+		}
+	case *tools.ExecResult:
+		if result == nil || result.ExitCode != 0 || result.Error != "" {
+			return nil
+		}
+		command, _ := call.FunctionCall.Arguments["command"].(string)
+		for _, kc := range tools.ParseKubectlCommands(command) {
+			if !kc.Modifies || kc.Verb == "delete" || !strings.Contains(kc.Resource, "/") ||
+				kc.Filename != "" || kc.Selector != "" || kc.All || kc.AllNamespaces {
+				continue
+			}
+			objects = append(objects, changedObject{Resource: kc.Resource, Namespace: kc.Namespace, Context: kc.Context})
+		}
+	}
+	return objects
+}
+
+// recordChangeOnObjects records the change made by a successful tool call on
+// the objects changed, if enabled. Failures are reported to the user, the
+// call itself succeeded.
+func (c *Agent) recordChangeOnObjects(ctx context.Context, call ToolCallAnalysis, output any, revision *tools.ManifestRevision) {
+	if c.RecordChanges == "" || c.RecordChanges == ChangeRecordNone {
+		return
+	}
+	objects := changedObjects(call, output, revision)
+	if len(objects) == 0 {
+		return
+	}
+	approval := c.toolCallApproval(call)
+	message := changeMessage(c.sessionID(), approval, call.ParsedToolCall.Description())
+	for _, obj := range objects {
+		var err error
+		switch c.RecordChanges {
+		case ChangeRecordEvent:
+			err = c.recordChangeEvent(ctx, obj, message, time.Now())
+		case ChangeRecordAnnotation:
+			err = c.annotateChange(ctx, obj, approval, message)
+		}
+		if err != nil {
+			klog.FromContext(ctx).Info("error recording a change", "object", obj.Resource, "err", err)
+			c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("Error recording the change on %s: %v", obj.Resource, err))
+		}
+	}
+}
+
+// changeMessage describes a change, e.g. "Modified by kubectl-ai session
+// 20250101-123456 approved by alice: kubectl scale deployment/web --replicas=3".
+func changeMessage(sessionID string, approval *api.Approval, description string) string {
+	var sb strings.Builder
+	sb.WriteString("Modified by kubectl-ai")
+	if sessionID != "" {
+		fmt.Fprintf(&sb, " session %s", sessionID)
+	}
+	if approval != nil {
+		fmt.Fprintf(&sb, " approved by %s", approval.Approver)
+		if approval.Method != api.ApprovalMethodConfirmed {
+			fmt.Fprintf(&sb, " (%s)", approval.Method)
+		}
+	}
+	fmt.Fprintf(&sb, ": %s", description)
+	message := sb.String()
+	if len(message) > maxChangeMessageLength {
+		message = strings.ToValidUTF8(message[:maxChangeMessageLength-len("…")], "") + "…"
+	}
+	return message
+}
+
+// recordChangeEvent creates an Event on the object, in its namespace, or in
+// the default namespace for cluster-scoped objects.
+func (c *Agent) recordChangeEvent(ctx context.Context, obj changedObject, message string, now time.Time) error {
+	out, err := c.kubectlOutput(ctx, obj.kubectlArgs("get", "-o", "json")...)
+	if err != nil {
+		return err
+	}
+	var target struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			UID       string `json:"uid"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(out, &target); err != nil {
+		return fmt.Errorf("parsing %s: %w", obj.Resource, err)
+	}
+	event, err := json.Marshal(changeEvent(target.APIVersion, target.Kind, target.Metadata.Name, target.Metadata.Namespace, target.Metadata.UID, message, now))
+	if err != nil {
+		return err
+	}
+	args := []string{"create", "-f", "-"}
+	if obj.Context != "" {
+		args = append(args, "--context", obj.Context)
+	}
+	_, err = c.kubectlOutputWithStdin(ctx, event, args...)
+	return err
+}
+
+// changeEvent returns the Event recording a change of an object.
+func changeEvent(apiVersion, kind, name, namespace, uid, message string, now time.Time) map[string]any {
+	involvedObject := map[string]any{"apiVersion": apiVersion, "kind": kind, "name": name, "uid": uid}
+	eventNamespace := "default"
+	if namespace != "" {
+		involvedObject["namespace"] = namespace
+		eventNamespace = namespace
+	}
+	timestamp := now.UTC().Format(time.RFC3339)
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]any{
+			"generateName": name + ".",
+			"namespace":    eventNamespace,
+		},
+		"involvedObject":     involvedObject,
+		"reason":             changeEventReason,
+		"message":            message,
+		"type":               "Normal",
+		"source":             map[string]any{"component": "kubectl-ai"},
+		"reportingComponent": "kubectl-ai",
+		"firstTimestamp":     timestamp,
+		"lastTimestamp":      timestamp,
+		"count":              1,
+	}
+}
+
+// annotateChange sets the annotations recording the last change of the
+// object, as on the remediation jobs.
+func (c *Agent) annotateChange(ctx context.Context, obj changedObject, approval *api.Approval, message string) error {
+	args := obj.kubectlArgs("annotate", "--overwrite")
+	if id := c.sessionID(); id != "" {
+		args = append(args, "kubectl-ai/session="+id)
+	}
+	if approval != nil {
+		args = append(args, "kubectl-ai/approver="+approval.Approver)
+	}
+	args = append(args, "kubectl-ai/change="+message)
+	_, err := c.kubectlOutput(ctx, args...)
+	return err
+}
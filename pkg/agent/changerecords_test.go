@@ -0,0 +1,164 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func TestChangedObjects(t *testing.T) {
+	call := func(command string) ToolCallAnalysis {
+		return ToolCallAnalysis{FunctionCall: gollm.FunctionCall{Name: "bash", Arguments: map[string]any{"command": command}}}
+	}
+	for _, tc := range []struct {
+		command string
+		result  *tools.ExecResult
+		want    []changedObject
+	}{
+		{"kubectl scale deployment web --replicas=3 -n shop", &tools.ExecResult{}, []changedObject{{Resource: "deployment/web", Namespace: "shop"}}},
+		{"kubectl rollout restart deployment/web --context prod && kubectl label node n1 pool=db", &tools.ExecResult{},
+			[]changedObject{{Resource: "deployment/web", Context: "prod"}, {Resource: "node/n1"}}},
+		{"kubectl scale deployment web --replicas=3", &tools.ExecResult{ExitCode: 1}, nil},
+		{"kubectl delete pod web-0", &tools.ExecResult{}, nil},
+		{"kubectl apply -f web.yaml", &tools.ExecResult{}, nil},
+		{"kubectl label pods -l app=web tier=frontend", &tools.ExecResult{}, nil},
+		{"kubectl get deployment web", &tools.ExecResult{}, nil},
+	} {
+		if got := changedObjects(call(tc.command), tc.result, nil); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("changedObjects(%q) = %+v, want %+v", tc.command, got, tc.want)
+		}
+	}
+
+	revision := &tools.ManifestRevision{
+		Objects: []map[string]any{{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]any{"name": "web", "namespace": "shop"}}},
+		Missing: []tools.ObjectRef{{APIVersion: "v1", Kind: "Service", Name: "web", Namespace: "shop"}},
+	}
+	want := []changedObject{{Resource: "Deployment.v1.apps/web", Namespace: "shop"}, {Resource: "Service/web", Namespace: "shop"}}
+	if got := changedObjects(ToolCallAnalysis{}, &tools.ApplyManifestResult{Applied: []string{"deployment.apps/web serverside-applied"}}, revision); !reflect.DeepEqual(got, want) {
+		t.Errorf("changedObjects() of an applied manifest = %+v, want %+v", got, want)
+	}
+	if got := changedObjects(ToolCallAnalysis{}, &tools.ApplyManifestResult{DryRun: true, Applied: []string{"deployment.apps/web serverside-applied (server dry run)"}}, revision); got != nil {
+		t.Errorf("changedObjects() of a dry-run = %+v, want none", got)
+	}
+}
+
+func TestChangeMessage(t *testing.T) {
+	approval := &api.Approval{Approver: "alice", Method: api.ApprovalMethodConfirmed}
+	if got, want := changeMessage("20250101-123456", approval, "kubectl scale deployment/web --replicas=3"),
+		"Modified by kubectl-ai session 20250101-123456 approved by alice: kubectl scale deployment/web --replicas=3"; got != want {
+		t.Errorf("changeMessage() = %q, want %q", got, want)
+	}
+	approval.Method = api.ApprovalMethodSkipPermissions
+	if got, want := changeMessage("", approval, "kubectl cordon node/n1"), "Modified by kubectl-ai approved by alice (skip-permissions): kubectl cordon node/n1"; got != want {
+		t.Errorf("changeMessage() = %q, want %q", got, want)
+	}
+	if got := changeMessage("", nil, strings.Repeat("é", 1000)); len(got) > maxChangeMessageLength || !strings.HasSuffix(got, "é…") {
+		t.Errorf("changeMessage() of a long command = %q (%d bytes), want it truncated", got, len(got))
+	}
+}
+
+func TestRecordChangeOnObjects(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	// The fake kubectl returns the object, and saves the events created and
+	// the annotations set.
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+get) echo '{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","namespace":"shop","uid":"1234"}}' ;;
+create) cat > "` + dir + `/event.json" ;;
+annotate) echo "$@" > "` + dir + `/annotate.txt" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("kubectl").AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).Return("yes").AnyTimes()
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	a := &Agent{
+		Tools:         ts,
+		RecordChanges: ChangeRecordEvent,
+		session:       &api.Session{ID: "20250101-123456", ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:        make(chan any, 20),
+		approval:      &api.Approval{Approver: "alice", Method: api.ApprovalMethodConfirmed},
+	}
+	calls, err := a.analyzeToolCalls(ctx, []gollm.FunctionCall{{Name: "kubectl", Arguments: map[string]any{"command": "kubectl scale deployment web --replicas=3 -n shop"}}})
+	if err != nil {
+		t.Fatalf("analyzeToolCalls: %v", err)
+	}
+
+	a.recordChangeOnObjects(ctx, calls[0], &tools.ExecResult{}, nil)
+	b, err := os.ReadFile(filepath.Join(dir, "event.json"))
+	if err != nil {
+		t.Fatalf("no event was created: %v", err)
+	}
+	var event map[string]any
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatal(err)
+	}
+	wantObject := map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web", "namespace": "shop", "uid": "1234"}
+	if !reflect.DeepEqual(event["involvedObject"], wantObject) || event["metadata"].(map[string]any)["namespace"] != "shop" || event["reason"] != changeEventReason {
+		t.Errorf("unexpected event %s", b)
+	}
+	if want := "Modified by kubectl-ai session 20250101-123456 approved by alice: kubectl scale deployment web --replicas=3 -n shop"; event["message"] != want {
+		t.Errorf("event message = %q, want %q", event["message"], want)
+	}
+
+	a.RecordChanges = ChangeRecordAnnotation
+	a.recordChangeOnObjects(ctx, calls[0], &tools.ExecResult{}, nil)
+	b, err = os.ReadFile(filepath.Join(dir, "annotate.txt"))
+	if err != nil {
+		t.Fatalf("the object was not annotated: %v", err)
+	}
+	if want := "annotate --overwrite deployment/web -n shop kubectl-ai/session=20250101-123456 kubectl-ai/approver=alice kubectl-ai/change=Modified by kubectl-ai"; !strings.HasPrefix(string(b), want) {
+		t.Errorf("kubectl %s, want kubectl %s...", b, want)
+	}
+}
+
+func TestChangeEventOfClusterScopedObject(t *testing.T) {
+	event := changeEvent("v1", "Node", "n1", "", "5678", "Modified by kubectl-ai", time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	if namespace := event["metadata"].(map[string]any)["namespace"]; namespace != "default" {
+		t.Errorf("event namespace = %v, want default", namespace)
+	}
+	if _, ok := event["involvedObject"].(map[string]any)["namespace"]; ok {
+		t.Errorf("the involved object of a cluster-scoped object has a namespace: %v", event["involvedObject"])
+	}
+	if event["firstTimestamp"] != "2025-01-01T12:00:00Z" {
+		t.Errorf("event firstTimestamp = %v", event["firstTimestamp"])
+	}
+}
@@ -0,0 +1,445 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+// The chaos tests run the agent loop against a scripted model and a fake
+// tool injecting failures, and check that the agent reports them and recovers:
+// it returns to the prompt and answers the next query.
+
+// chaosTurn scripts a request to the model.
+type chaosTurn struct {
+	// sendErr fails the request.
+	sendErr error
+	// responses are streamed, then streamErr if set.
+	responses []*fakeResponse
+	// noCandidates streams a response without candidates.
+	noCandidates bool
+	streamErr    error
+	// cancel cancels the context of the agent after the responses are
+	// streamed, the stream then failing with the error of the context.
+	cancel bool
+}
+
+func (turn chaosTurn) stream(ctx context.Context, cancel context.CancelFunc) gollm.ChatResponseIterator {
+	return func(yield func(gollm.ChatResponse, error) bool) {
+		for _, response := range turn.responses {
+			if !yield(response, nil) {
+				return
+			}
+		}
+		switch {
+		case turn.noCandidates:
+			yield(emptyResponse{}, nil)
+		case turn.cancel:
+			cancel()
+			yield(nil, ctx.Err())
+		case turn.streamErr != nil:
+			yield(nil, turn.streamErr)
+		}
+	}
+}
+
+// emptyResponse is a response without candidates.
+type emptyResponse struct{}
+
+func (emptyResponse) UsageMetadata() any            { return nil }
+func (emptyResponse) Candidates() []gollm.Candidate { return nil }
+
+// chaosTool is a read-only tool, named "probe", running run.
+type chaosTool struct {
+	tools.Tool
+	run func(ctx context.Context) (any, error)
+}
+
+func (t *chaosTool) Name() string        { return "probe" }
+func (t *chaosTool) Description() string { return "Probes the cluster." }
+func (t *chaosTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{Name: t.Name(), Description: t.Description()}
+}
+func (t *chaosTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+func (t *chaosTool) CheckModifiesResource(args map[string]any) string { return "no" }
+func (t *chaosTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	return t.run(ctx)
+}
+
+type chaosScenario struct {
+	name string
+	shim bool
+	// fallback enables the fallback to the shim, whose chat is the same mock.
+	fallback bool
+	// queries are sent in turn, each once the agent is back at the prompt.
+	queries []string
+	turns   []chaosTurn
+	// tool runs the calls of the probe tool.
+	tool  func(ctx context.Context) (any, error)
+	check func(t *testing.T, run *chaosRun)
+}
+
+// chaosRun is the outcome of a scenario.
+type chaosRun struct {
+	agent *Agent
+	// messages are the messages posted by the agent.
+	messages []*api.Message
+	// sent are the contents of the requests to the model.
+	sent [][]any
+}
+
+func (r *chaosRun) ofType(t api.MessageType) []*api.Message {
+	var messages []*api.Message
+	for _, message := range r.messages {
+		if message.Type == t {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// answers returns the text messages of the model.
+func (r *chaosRun) answers() []string {
+	var answers []string
+	for _, message := range r.ofType(api.MessageTypeText) {
+		if message.Source == api.MessageSourceModel {
+			answers = append(answers, message.Payload.(string))
+		}
+	}
+	return answers
+}
+
+// wantError checks that the agent reported a single error, of category.
+func (r *chaosRun) wantError(t *testing.T, category api.ErrorCategory, substr string) {
+	t.Helper()
+	var errs []*api.Message
+	for _, message := range r.ofType(api.MessageTypeError) {
+		if strings.HasPrefix(message.Payload.(string), "Error: ") {
+			errs = append(errs, message)
+		}
+	}
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1: %+v", len(errs), errs)
+	}
+	if errs[0].ErrorCategory != category {
+		t.Errorf("error category = %q, want %q", errs[0].ErrorCategory, category)
+	}
+	if text := errs[0].Payload.(string); !strings.Contains(text, substr) {
+		t.Errorf("error = %q, want it to contain %q", text, substr)
+	}
+}
+
+// runChaos runs a scenario until the agent is back at the prompt after the
+// last query. The mock fails the test on requests beyond the scripted turns.
+func runChaos(t *testing.T, tc chaosScenario) *chaosRun {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	run := &chaosRun{}
+	ctrl := gomock.NewController(t)
+	chat := mocks.NewMockChat(ctrl)
+	var calls []any
+	for _, turn := range tc.turns {
+		calls = append(calls, chat.EXPECT().SendStreaming(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, contents ...any) (gollm.ChatResponseIterator, error) {
+				run.sent = append(run.sent, contents)
+				if turn.sendErr != nil {
+					return nil, turn.sendErr
+				}
+				return turn.stream(ctx, cancel), nil
+			}))
+	}
+	gomock.InOrder(calls...)
+
+	var ts tools.Tools
+	ts.Init()
+	if tc.tool != nil {
+		ts.RegisterTool(&chaosTool{run: tc.tool})
+	}
+	run.agent = &Agent{
+		llmChat:           chat,
+		Tools:             ts,
+		MaxIterations:     10,
+		MaxContinuations:  3,
+		EnableToolUseShim: tc.shim,
+		Input:             make(chan any, 10),
+		Output:            make(chan any, 100),
+		session:           &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+	}
+	if tc.fallback {
+		client := mocks.NewMockClient(ctrl)
+		client.EXPECT().StartChat(gomock.Any(), gomock.Any()).Return(chat)
+		chat.EXPECT().Initialize(gomock.Any()).Return(nil)
+		run.agent.LLM = client
+		run.agent.ToolUseShimFallback = true
+	}
+	if err := run.agent.Run(ctx, tc.queries[0]); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	queries := tc.queries[1:]
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-run.agent.Output:
+			message := m.(*api.Message)
+			run.messages = append(run.messages, message)
+			if message.Type != api.MessageTypeUserInputRequest {
+				continue
+			}
+			if len(queries) == 0 {
+				return run
+			}
+			run.agent.Input <- &api.UserInputResponse{Query: queries[0]}
+			queries = queries[1:]
+		case <-timeout:
+			t.Fatalf("agent stuck in state %q, %d queries left", run.agent.AgentState(), len(queries))
+		}
+	}
+}
+
+func TestChaos(t *testing.T) {
+	answer := chaosTurn{responses: []*fakeResponse{{text: "All pods are running.", finishReason: gollm.FinishReasonStop}}}
+	probe := chaosTurn{responses: []*fakeResponse{{calls: []gollm.FunctionCall{{ID: "1", Name: "probe"}}}}}
+
+	tests := []chaosScenario{
+		{
+			name:    "rate limited request",
+			queries: []string{"list pods", "list pods again"},
+			turns: []chaosTurn{
+				{sendErr: &gollm.APIError{StatusCode: 429, Message: "quota exceeded"}},
+				answer,
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, api.ErrorCategoryProviderRateLimit, "Hint: ")
+				if got := run.sent[1]; len(got) != 1 || got[0] != "list pods again" {
+					t.Errorf("second request = %q, want only the second query", got)
+				}
+				if got := run.answers(); len(got) != 1 {
+					t.Errorf("answers = %q, want the answer to the second query", got)
+				}
+			},
+		},
+		{
+			name:    "rate limited stream",
+			queries: []string{"list pods", "list pods again"},
+			turns: []chaosTurn{
+				{responses: []*fakeResponse{{text: "The pods"}}, streamErr: errors.New("Error 429, Message: Resource exhausted")},
+				answer,
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, api.ErrorCategoryProviderRateLimit, "Resource exhausted")
+				if got := run.answers(); len(got) != 1 || got[0] != "All pods are running." {
+					t.Errorf("answers = %q, want only the answer to the second query", got)
+				}
+			},
+		},
+		{
+			name:    "error after a truncated response",
+			queries: []string{"describe the pods"},
+			turns: []chaosTurn{
+				{responses: []*fakeResponse{{text: "Pod a is running", finishReason: gollm.FinishReasonMaxTokens}}},
+				{responses: []*fakeResponse{{text: ", pod b"}}, streamErr: errors.New("connection reset by peer")},
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, "", "connection reset by peer")
+				// The complete part of the response is kept.
+				if got := run.answers(); len(got) != 1 || got[0] != "Pod a is running" {
+					t.Errorf("answers = %q, want the truncated response", got)
+				}
+			},
+		},
+		{
+			name:    "response without candidates",
+			queries: []string{"list pods", "list pods again"},
+			turns: []chaosTurn{
+				{noCandidates: true},
+				answer,
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, "", "no candidates in response")
+			},
+		},
+		{
+			name:    "call of an unknown tool",
+			queries: []string{"list pods", "list pods again"},
+			turns: []chaosTurn{
+				{responses: []*fakeResponse{{calls: []gollm.FunctionCall{{ID: "1", Name: "kubctl", Arguments: map[string]any{"command": "kubectl get pods"}}}}}},
+				answer,
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, "", "error parsing tool call")
+				if got := run.ofType(api.MessageTypeToolCallRequest); len(got) != 0 {
+					t.Errorf("tool calls = %+v, want none", got)
+				}
+			},
+		},
+		{
+			name:    "malformed ReAct JSON",
+			shim:    true,
+			queries: []string{"list pods", "list pods again"},
+			turns: []chaosTurn{
+				{responses: []*fakeResponse{{text: "```json\n{\"thought\": \"list the pods\", \"action\": {\"name\": \"kubectl\",\n```"}}},
+				{responses: []*fakeResponse{{text: "```json\n{\"thought\": \"done\", \"answer\": \"All pods are running.\"}\n```"}}},
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, "", "parsing ReAct response")
+				if got := run.answers(); len(got) != 1 || !strings.HasSuffix(got[0], "All pods are running.") {
+					t.Errorf("answers = %q, want the answer to the second query", got)
+				}
+			},
+		},
+		{
+			name:    "model rejecting the tools",
+			queries: []string{"list pods", "list pods again"},
+			turns: []chaosTurn{
+				{sendErr: &gollm.APIError{StatusCode: 400, Message: "registry.ollama.ai/library/gemma3:12b does not support tools"}},
+				answer,
+			},
+			tool: func(ctx context.Context) (any, error) { return nil, nil },
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, api.ErrorCategoryToolsNotSupported, "does not support tools")
+			},
+		},
+		{
+			name:    "empty answer",
+			queries: []string{"list pods", "list pods again"},
+			turns: []chaosTurn{
+				{responses: []*fakeResponse{{finishReason: gollm.FinishReasonStop}}},
+				answer,
+			},
+			tool: func(ctx context.Context) (any, error) { return nil, nil },
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, api.ErrorCategoryToolsNotSupported, "empty answer")
+			},
+		},
+		{
+			name:    "tool call written as text",
+			queries: []string{"list pods", "list pods again"},
+			turns: []chaosTurn{
+				{responses: []*fakeResponse{{text: "```json\n{\"name\": \"probe\", \"arguments\": {}}\n```"}}},
+				answer,
+			},
+			tool: func(ctx context.Context) (any, error) { return nil, nil },
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, api.ErrorCategoryToolsNotSupported, "wrote a call of the probe tool")
+			},
+		},
+		{
+			name:     "fallback to the shim",
+			fallback: true,
+			queries:  []string{"list pods"},
+			turns: []chaosTurn{
+				{sendErr: &gollm.APIError{StatusCode: 400, Message: "registry.ollama.ai/library/gemma3:12b does not support tools"}},
+				{responses: []*fakeResponse{{text: "```json\n{\"thought\": \"done\", \"answer\": \"All pods are running.\"}\n```"}}},
+			},
+			tool: func(ctx context.Context) (any, error) { return nil, nil },
+			check: func(t *testing.T, run *chaosRun) {
+				if errs := run.ofType(api.MessageTypeError); len(errs) != 0 {
+					t.Errorf("errors = %+v, want none", errs)
+				}
+				if !run.agent.EnableToolUseShim {
+					t.Errorf("the agent didn't switch to the shim")
+				}
+				// The query is sent again.
+				if len(run.sent) != 2 || len(run.sent[1]) != 1 || run.sent[1][0] != run.sent[0][0] {
+					t.Errorf("requests = %+v, want the query twice", run.sent)
+				}
+				if got := run.answers(); len(got) != 1 || !strings.HasSuffix(got[0], "All pods are running.") {
+					t.Errorf("answers = %q, want the answer of the shim", got)
+				}
+			},
+		},
+		{
+			name:    "tool timeout",
+			queries: []string{"list pods"},
+			turns:   []chaosTurn{probe, answer},
+			tool: func(ctx context.Context) (any, error) {
+				return &tools.ExecResult{Command: "kubectl get pods -w", Stdout: "a Running", StreamType: "timeout"}, nil
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				// The partial output is given to the model, which answers.
+				if len(run.sent) != 2 || len(run.sent[1]) != 1 {
+					t.Fatalf("requests = %+v, want the result of the tool call", run.sent)
+				}
+				result, ok := run.sent[1][0].(gollm.FunctionCallResult)
+				if !ok || result.Result["stdout"] != "a Running" {
+					t.Errorf("tool call result = %+v, want the partial output", run.sent[1][0])
+				}
+				responses := run.ofType(api.MessageTypeToolCallResponse)
+				if len(responses) != 1 || responses[0].ErrorCategory != api.ErrorCategoryToolTimeout {
+					t.Errorf("tool call responses = %+v, want a timeout", responses)
+				}
+				if got := run.answers(); len(got) != 1 {
+					t.Errorf("answers = %q, want an answer", got)
+				}
+			},
+		},
+		{
+			name:    "tool deadline exceeded",
+			queries: []string{"list pods", "list pods again"},
+			turns:   []chaosTurn{probe, answer},
+			tool: func(ctx context.Context) (any, error) {
+				return nil, context.DeadlineExceeded
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, api.ErrorCategoryToolTimeout, "deadline exceeded")
+				if got := run.sent[1]; len(got) != 1 || got[0] != "list pods again" {
+					t.Errorf("second request = %q, want only the second query", got)
+				}
+			},
+		},
+		{
+			name:    "cancellation mid-stream",
+			queries: []string{"list pods"},
+			turns: []chaosTurn{
+				{responses: []*fakeResponse{{text: "The pods"}}, cancel: true},
+			},
+			check: func(t *testing.T, run *chaosRun) {
+				run.wantError(t, "", "context canceled")
+				if state := run.agent.AgentState(); state != api.AgentStateDone {
+					t.Errorf("state = %q, want %q", state, api.AgentStateDone)
+				}
+				// The loop stopped: the next query is never read.
+				time.Sleep(50 * time.Millisecond)
+				run.agent.Input <- &api.UserInputResponse{Query: "list pods again"}
+				time.Sleep(50 * time.Millisecond)
+				if len(run.agent.Input) != 1 {
+					t.Errorf("the agent read the queries sent after the cancellation")
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.check(t, runChaos(t, tc))
+		})
+	}
+}
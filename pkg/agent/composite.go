@@ -0,0 +1,246 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// DefaultCompositeMaxIterations is the number of model turns of a composite
+// tool call by default.
+const DefaultCompositeMaxIterations = 10
+
+// CompositeToolOptions configures the composite tools.
+type CompositeToolOptions struct {
+	LLM   gollm.Client
+	Model string
+	// Tools are the tools the composite tools investigate with. Only their
+	// read-only calls are run.
+	Tools tools.Tools
+	// MaxIterations bounds the model turns of each call.
+	MaxIterations int
+	// KubectlOutputBudget is the output budget of the kubectl tool, see Agent.KubectlOutputBudget.
+	KubectlOutputBudget int
+}
+
+// compositePrompt is the system prompt of the composite tools.
+const compositePrompt = `You are a Kubernetes expert answering a request of another program, which
+can't ask you follow-up questions. kubectl is already configured for the cluster.
+
+Only run read-only commands: commands changing resources are refused.
+Investigate with the tools, then answer with a concise report, starting with
+a one-line verdict. Be factual and include the names and values that support
+the verdict, e.g. reasons, exit codes, images, counts, event messages.`
+
+// compositeTool is a high-level tool, e.g. diagnose_pod, answered by a
+// bounded agent loop running read-only tool calls, for the MCP clients to get
+// a report in one call instead of orchestrating kubectl calls themselves.
+type compositeTool struct {
+	name        string
+	description string
+	parameters  *gollm.Schema
+	// task returns the request investigated by the agent loop for the arguments.
+	task func(args map[string]any) (string, error)
+	opt  CompositeToolOptions
+}
+
+var _ tools.Tool = &compositeTool{}
+
+// CompositeTools returns the composite tools: diagnose_pod,
+// summarize_namespace and check_rollout.
+func CompositeTools(opt CompositeToolOptions) []tools.Tool {
+	namespace := &gollm.Schema{
+		Type:        gollm.TypeString,
+		Description: "The namespace. Defaults to the namespace of the kubeconfig context.",
+	}
+	return []tools.Tool{
+		&compositeTool{
+			name:        "diagnose_pod",
+			description: "Diagnoses why a pod is not running or not ready, e.g. crash loops, image pull errors, OOM kills, failed probes or scheduling failures, from its status, events, logs, node and owner. Returns a report with the root cause, the evidence and a suggested fix, which is not applied.",
+			parameters: &gollm.Schema{
+				Type: gollm.TypeObject,
+				Properties: map[string]*gollm.Schema{
+					"pod":       {Type: gollm.TypeString, Description: "The name of the pod."},
+					"namespace": namespace,
+				},
+				Required: []string{"pod"},
+			},
+			task: func(args map[string]any) (string, error) {
+				pod, err := compositeArg(args, "pod", true)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Diagnose the pod %q%s. Check its phase, the state and last state of its containers, its events, the logs of its failing containers (including the previous ones), its node and its owner. Report the root cause, the evidence, and the fix to apply.", pod, inNamespace(args)), nil
+			},
+			opt: opt,
+		},
+		&compositeTool{
+			name:        "summarize_namespace",
+			description: "Summarizes the state of a namespace: its workloads and whether they are healthy, the pods not running or restarting, the recent warning events, and the services without endpoints. Returns a report listing what needs attention first.",
+			parameters: &gollm.Schema{
+				Type: gollm.TypeObject,
+				Properties: map[string]*gollm.Schema{
+					"namespace": namespace,
+				},
+			},
+			task: func(args map[string]any) (string, error) {
+				return fmt.Sprintf("Summarize the state of the namespace%s: its deployments, statefulsets, daemonsets, jobs and cronjobs and whether they are healthy, the pods not running or restarting, the warning events of the last hour, and the services without endpoints. List what needs attention first.", inNamespace(args)), nil
+			},
+			opt: opt,
+		},
+		&compositeTool{
+			name:        "check_rollout",
+			description: "Checks the rollout of a deployment, statefulset or daemonset: whether the latest revision is rolled out, how many replicas are updated and available, and why the new pods are not ready if it is stuck. Returns a report with the status of the rollout and the blocking reason.",
+			parameters: &gollm.Schema{
+				Type: gollm.TypeObject,
+				Properties: map[string]*gollm.Schema{
+					"resource":  {Type: gollm.TypeString, Description: `The workload as kind/name, e.g. "deployment/web".`},
+					"namespace": namespace,
+				},
+				Required: []string{"resource"},
+			},
+			task: func(args map[string]any) (string, error) {
+				resource, err := compositeArg(args, "resource", true)
+				if err != nil {
+					return "", err
+				}
+				if !strings.Contains(resource, "/") {
+					return "", fmt.Errorf("resource %q must be kind/name, e.g. deployment/web", resource)
+				}
+				return fmt.Sprintf("Check the rollout of %s%s. Report whether its latest revision is rolled out, its updated, ready and available replicas against the desired ones, the image changes of the revision, and if it is stuck, why the new pods are not ready.", resource, inNamespace(args)), nil
+			},
+			opt: opt,
+		},
+	}
+}
+
+// compositeArg returns a string argument of a composite tool call.
+func compositeArg(args map[string]any, name string, required bool) (string, error) {
+	value, _ := args[name].(string)
+	value = strings.TrimSpace(value)
+	if value == "" && required {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	return value, nil
+}
+
+func inNamespace(args map[string]any) string {
+	if namespace, _ := compositeArg(args, "namespace", false); namespace != "" {
+		return fmt.Sprintf(" in the namespace %q", namespace)
+	}
+	return ""
+}
+
+func (t *compositeTool) Name() string {
+	return t.name
+}
+
+func (t *compositeTool) Description() string {
+	return t.description
+}
+
+func (t *compositeTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.name,
+		Description: t.description,
+		Parameters:  t.parameters,
+	}
+}
+
+func (t *compositeTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+// CheckModifiesResource returns "no": the agent loop only runs read-only tool calls.
+func (t *compositeTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// Run runs the agent loop of the call, with the kubeconfig and the working
+// directory of the context, and returns its report.
+func (t *compositeTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	task, err := t.task(args)
+	if err != nil {
+		return &tools.ExecResult{Error: err.Error()}, nil
+	}
+	log := klog.FromContext(ctx).WithValues("tool", t.name)
+
+	kubeconfig, _ := ctx.Value(tools.KubeconfigKey).(string)
+	parentDir, _ := ctx.Value(tools.WorkDirKey).(string)
+	workDir, err := os.MkdirTemp(parentDir, t.name+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating working directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+	opt := tools.InvokeToolOptions{Kubeconfig: kubeconfig, WorkDir: workDir, OutputBudget: t.opt.KubectlOutputBudget}
+
+	chat := t.opt.LLM.StartChat(compositePrompt, t.opt.Model)
+	var functionDefinitions []*gollm.FunctionDefinition
+	for _, tool := range t.opt.Tools.AllTools() {
+		functionDefinitions = append(functionDefinitions, tools.FunctionDefinitionOf(tool))
+	}
+	sort.Slice(functionDefinitions, func(i, j int) bool {
+		return functionDefinitions[i].Name < functionDefinitions[j].Name
+	})
+	if err := chat.SetFunctionDefinitions(functionDefinitions); err != nil {
+		return nil, fmt.Errorf("setting function definitions: %w", err)
+	}
+
+	maxIterations := t.opt.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultCompositeMaxIterations
+	}
+	report, err := runInvestigation(ctx, chat, task, maxIterations, func(call gollm.FunctionCall) map[string]any {
+		result := runCompositeToolCall(ctx, t.opt.Tools, call, opt)
+		log.V(2).Info("composite tool call", "tool", call.Name, "arguments", call.Arguments, "result", result)
+		return result
+	})
+	if err != nil {
+		return &tools.ExecResult{Error: err.Error()}, nil
+	}
+	return report, nil
+}
+
+// runCompositeToolCall runs a tool call of a composite tool, unless it may
+// change resources or is interactive. The model is told why a call was refused.
+func runCompositeToolCall(ctx context.Context, ts tools.Tools, call gollm.FunctionCall, opt tools.InvokeToolOptions) map[string]any {
+	toolCall, err := ts.ParseToolInvocation(ctx, call.Name, call.Arguments)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	if modifies := toolCall.GetTool().CheckModifiesResource(call.Arguments); modifies != "no" {
+		return map[string]any{"error": "refused: only read-only commands can run in this investigation"}
+	}
+	if interactive, _ := toolCall.GetTool().IsInteractive(call.Arguments); interactive {
+		return map[string]any{"error": "refused: interactive commands can't run in this investigation"}
+	}
+	output, err := toolCall.InvokeTool(ctx, opt)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	result, err := tools.ToolResultToMap(output)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCompositeTool(t *testing.T) {
+	ctx := context.WithValue(context.Background(), tools.WorkDirKey, t.TempDir())
+	ctrl := gomock.NewController(t)
+
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("kubectl").AnyTimes()
+	mt.EXPECT().FunctionDefinition().Return(&gollm.FunctionDefinition{Name: "kubectl"}).AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).DoAndReturn(func(args map[string]any) string {
+		if strings.HasPrefix(args["command"].(string), "kubectl delete") {
+			return "yes"
+		}
+		return "no"
+	}).AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().Run(gomock.Any(), gomock.Any()).Return("web-1   0/1   CrashLoopBackOff", nil).Times(1)
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	chat := mocks.NewMockChat(ctrl)
+	chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil)
+	gomock.InOrder(
+		chat.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, contents ...any) (gollm.ChatResponse, error) {
+			if task := contents[0].(string); !strings.Contains(task, `"web-1" in the namespace "shop"`) {
+				t.Errorf("task = %q, want the pod and its namespace", task)
+			}
+			return &fakeResponse{calls: []gollm.FunctionCall{
+				{ID: "1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pod web-1"}},
+				{ID: "2", Name: "kubectl", Arguments: map[string]any{"command": "kubectl delete pod web-1"}},
+			}}, nil
+		}),
+		chat.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, contents ...any) (gollm.ChatResponse, error) {
+			if len(contents) != 2 {
+				t.Fatalf("got %d tool call results, want 2", len(contents))
+			}
+			if refused := contents[1].(gollm.FunctionCallResult).Result["error"]; refused == nil {
+				t.Errorf("the deletion was not refused")
+			}
+			return &fakeResponse{text: "Verdict: web-1 crash loops."}, nil
+		}),
+	)
+	llm := mocks.NewMockClient(ctrl)
+	llm.EXPECT().StartChat(gomock.Any(), "test-model").Return(chat)
+
+	var diagnosePod tools.Tool
+	for _, tool := range CompositeTools(CompositeToolOptions{LLM: llm, Model: "test-model", Tools: ts}) {
+		if tool.CheckModifiesResource(nil) != "no" {
+			t.Errorf("%s may modify resources, want read-only", tool.Name())
+		}
+		if tool.Name() == "diagnose_pod" {
+			diagnosePod = tool
+		}
+	}
+
+	if result, _ := diagnosePod.Run(ctx, map[string]any{}); result.(*tools.ExecResult).Error == "" {
+		t.Errorf("Run() without a pod = %v, want an error", result)
+	}
+	result, err := diagnosePod.Run(ctx, map[string]any{"pod": "web-1", "namespace": "shop"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result != "Verdict: web-1 crash loops." {
+		t.Errorf("Run() = %v, want the report of the model", result)
+	}
+}
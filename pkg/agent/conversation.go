@@ -18,10 +18,14 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"maps"
 	"os"
+	"os/user"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -55,6 +59,10 @@ type Agent struct {
 	// InitialQuery is the initial query to the agent.
 	// If provided, the agent will run only once and then exit.
 	InitialQuery string
+	// InitialAttachments are attached to the initial query, or to the first
+	// query of the user if there is none, e.g. the input piped with
+	// --stdin-as=context.
+	InitialAttachments []api.Attachment
 
 	// tool calls that are pending execution
 	// These will typically be all the tool calls suggested by the LLM in the
@@ -67,29 +75,137 @@ type Agent struct {
 
 	// currIteration tracks the current iteration of the agentic loop.
 	currIteration int
+	// queryStart is the time the current query started, for MaxDuration.
+	queryStart time.Time
+
+	// approval is the approval of the pending tool calls, if the user confirmed them.
+	approval *api.Approval
+	// dontAskAgainApprover is the user who chose not to be asked again for
+	// confirmation in this session.
+	dontAskAgainApprover string
+	// approvalIDs maps the IDs of the queued approvals to the index of their
+	// call in pendingFunctionCalls.
+	approvalIDs map[string]int
+	// decisions are the decisions of the user on the pending calls, by index,
+	// nil for the declined ones. It is nil unless calls await a decision.
+	decisions map[int]*api.Approval
+	// approvals are the queued approvals still awaiting a decision, read by
+	// the web UI.
+	approvalsMu sync.Mutex
+	approvals   []api.PendingApproval
+	// queuedContent are the messages sent by the user while tool calls
+	// awaited approval, sent to the LLM with their results.
+	queuedContent []any
+
+	// watches run the watches registered with the watch_until tool, nil in
+	// RunOnce mode.
+	watches *tools.Watches
+	// watchEvents are the events of the watches since the last query, given
+	// to the model with the next one.
+	watchEvents []string
+	// metrics are the samples of kubectl top, compared with the current
+	// usage by the compare_metrics tool.
+	metrics *tools.MetricsHistory
+
+	// truncatedText accumulates the text of a response that was cut off by
+	// the output token limit, while the LLM is asked to continue it.
+	truncatedText string
+	// truncatedCitations accumulates the citations of the parts of a truncated response.
+	truncatedCitations []api.Citation
+	// continuations counts the continue turns issued for the current response.
+	continuations int
+	// meter measures the current response, including its continuations.
+	meter *streamMeter
+
+	// snippets are the runnable code blocks of the last answer containing some.
+	snippets []Snippet
 
 	LLM gollm.Client
 
 	// PromptTemplateFile allows specifying a custom template file
 	PromptTemplateFile string
 	// ExtraPromptPaths allows specifying additional prompt templates
-	// to be combined with PromptTemplateFile
+	// to be combined with PromptTemplateFile, including the prompt packs
+	// shipped with kubectl-ai (see PromptPackPath)
 	ExtraPromptPaths []string
 	Model            string
 	Provider         string
 
 	RemoveWorkDir bool
 
+	// WorkDir is a persistent working directory for tool invocations.
+	// If empty, a temporary directory is created for the session.
+	WorkDir string
+
+	// Env holds environment variables set for every tool invocation, e.g. AWS_PROFILE.
+	// Variables set with the "env set" meta command during a session take precedence.
+	Env map[string]string
+
 	MaxIterations int
 
+	// MaxDuration bounds the wall-clock time of each query, checked before
+	// each iteration. Zero means no limit.
+	MaxDuration time.Duration
+
+	// MaxCost is the budget in USD of the session: the queries are stopped
+	// when the estimated cost of its LLM calls reaches it. Zero means no limit.
+	MaxCost float64
+
+	// MaxContinuations is the maximum number of times the LLM is asked to
+	// continue a response that was cut off by the output token limit.
+	// Zero disables continuations.
+	MaxContinuations int
+
+	// KubectlOutputBudget is the number of tokens above which the listings of
+	// the kubectl tool are run again in a more compact format, e.g. -o name.
+	// Zero disables it.
+	KubectlOutputBudget int
+
 	// Kubeconfig is the path to the kubeconfig file.
 	Kubeconfig string
 
 	SkipPermissions bool
 
+	// ReadOnly refuses the tool calls that modify, or may modify, resources,
+	// instead of asking for approval, e.g. with --read-only or in the auditor
+	// mode. The LLM is told why, to plan around them.
+	ReadOnly bool
+
+	// ConfineKubeconfig denies the tool calls that could use other
+	// credentials than the kubeconfig of the session, e.g. with --kubeconfig,
+	// KUBECONFIG= or by reading files outside of the working directory, as
+	// in bootstrap sessions. See tools.CheckConfinedCommand.
+	ConfineKubeconfig bool
+
+	// StrictApprovals requires the approval of every change: approving all
+	// the changes of a turn doesn't approve the following ones, e.g. in the
+	// sre mode.
+	StrictApprovals bool
+
+	// RBACPreflight checks that the current identity is allowed to run the
+	// kubectl commands requiring approval, before asking for it. Calls that
+	// are not allowed are rejected, for the model to re-plan.
+	RBACPreflight bool
+
+	// CheckVersionSkew compares the versions of kubectl and of the API server
+	// at startup, and warns the user and the model if kubectl is outside of
+	// its supported version skew.
+	CheckVersionSkew bool
+
+	// ForceSessionTakeover takes over the lock of a resumed session that is
+	// in use by another process, instead of failing.
+	ForceSessionTakeover bool
+
 	Tools tools.Tools
 
+	// CustomToolsPath is the custom tools configuration file, or directory,
+	// the tools created by the "new-tool" meta command are saved to.
+	CustomToolsPath string
+
 	EnableToolUseShim bool
+	// ToolUseShimFallback switches the session to the tool-use shim when the
+	// model rejects or ignores the tools, instead of reporting an error.
+	ToolUseShimFallback bool
 
 	// MCPClientEnabled indicates whether MCP client mode is enabled
 	MCPClientEnabled bool
@@ -97,8 +213,108 @@ type Agent struct {
 	// Recorder captures events for diagnostics
 	Recorder journal.Recorder
 
+	// Hooks are external commands run on agent events, e.g. to log tool calls.
+	Hooks []Hook
+
+	// ContextEnvironments classify the kubeconfig context of the session as
+	// an environment, e.g. production, given to the hooks.
+	ContextEnvironments []ContextEnvironment
+
+	// FreezeWindows are the change freezes, during which the tool calls that
+	// may modify resources can only be approved with a reason.
+	FreezeWindows []FreezeWindow
+
+	// Aliases are the queries run by typing their name, e.g. "restarts" for
+	// "list the pods restarting in all namespaces". See ValidateAliases.
+	Aliases map[string]string
+
+	// Policy gives a verdict on each tool call: allowed without confirmation,
+	// denied, or asking for confirmation even with SkipPermissions.
+	Policy *tools.Policy
+
+	// ValidateAnswers enables the built-in validators of final answers, that
+	// check referenced resources, unexecuted commands and contradictions with
+	// tool outputs.
+	ValidateAnswers bool
+	// AnswerValidators are custom validators of final answers, run after the
+	// built-in ones.
+	AnswerValidators []AnswerValidator
+
+	// VerifyRemediation enables the verification of fixes: once a query that
+	// changed resources is answered, the read-only commands that observed the
+	// symptom are re-run and the model judges whether the symptom is gone.
+	VerifyRemediation bool
+
+	// InjectNotes gives the notes pinned to the session to the model with
+	// every query.
+	InjectNotes bool
+
+	// Language is the language of the answers: a language name, e.g.
+	// "French", LanguageAuto to answer in the language of each query, or
+	// LanguageOff to let the model choose.
+	Language string
+
+	// WatchNotifications configures the notifications of the watches
+	// registered with the watch_until tool.
+	WatchNotifications WatchNotificationOptions
+
+	// Tags are added to the tags of the session when it starts, e.g. the
+	// incident it investigates.
+	Tags []string
+
+	// JobRunner configures the remediation jobs created by the "job run" meta command.
+	JobRunner JobRunnerOptions
+
+	// FanOut configures the investigations run across namespaces or clusters
+	// by the "fanout" meta command.
+	FanOut FanOutOptions
+
+	// GitOps configures the pull requests opened for the changes of resources
+	// managed by GitOps, instead of changing them in the cluster.
+	GitOps GitOpsOptions
+
+	// RecordChanges records the changes made by the agent on the objects
+	// they modify, as Events or annotations. Nothing is recorded if empty.
+	RecordChanges ChangeRecord
+
+	// AnswerCache caches the answers of read-only queries. Answers are not
+	// cached if nil.
+	AnswerCache *AnswerCache
+
+	// PromptCache caches the system prompt and the function definitions of
+	// the tools across invocations. Nothing is cached if nil.
+	PromptCache *PromptCache
+
+	// HistoryFidelity controls how the saved messages of the session are given
+	// back to the model when the chat is re-initialized, e.g. when resuming a
+	// session. The saved messages are replayed as is if empty.
+	HistoryFidelity HistoryFidelity
+
+	// Router selects the model of each query, between a fast and a strong
+	// model. The chat always uses Model if nil.
+	Router *gollm.ModelRouter
+
+	// Offline disables the features that need the model: queries that are
+	// not meta commands or cached answers fail with ErrOffline. LLM should
+	// then be an offline client, see NewOfflineClient.
+	Offline bool
+
 	llmChat gollm.Chat
 
+	// systemPrompt is the system prompt of the chat.
+	systemPrompt string
+
+	// llmCalls are the last LLM calls, for the "stats" meta command.
+	llmCalls []llmCall
+
+	// tokens accounts the tokens used by the LLM calls of the session, and
+	// their cost. It is recorded into the session metadata.
+	tokens tokenAccount
+
+	// chatModel is the model of the chat, which differs from Model when
+	// the router selected the fast model.
+	chatModel string
+
 	workDir string
 
 	// session tracks the current session of the agent
@@ -116,6 +332,63 @@ type Agent struct {
 
 	// ChatMessageStore is the underlying session persistence layer.
 	ChatMessageStore api.ChatMessageStore
+
+	// Stream controls the batching of streamed text sent to the UI.
+	Stream StreamOptions
+
+	// TokenPrices are used to estimate the cost of the LLM calls, shown in
+	// the meter of the UI. Zero prices use the list prices of the model, see
+	// LookupTokenPrices, and hide the cost of the models not listed.
+	TokenPrices TokenPrices
+
+	// PromptLog is what is logged of the queries and of the requests to the
+	// LLM, in the logs and the journal. It is sampled once per query, so that
+	// all the requests of a sampled query are logged.
+	PromptLog gollm.PromptLogOptions
+
+	// env is the environment for tool invocations in the current session.
+	env map[string]string
+
+	// jobs are the names of the remediation jobs created in the current session.
+	jobs []string
+
+	// usage tracks the cluster activity of the agent, and is recorded
+	// into the session metadata when the agent is closed.
+	usage *sessions.Usage
+
+	// environment is the classification of the kubeconfig context of the
+	// session, see ContextEnvironments.
+	environment string
+
+	// namespace is the namespace of the kubeconfig context of the session,
+	// which the freeze windows and the policy apply to when commands don't
+	// set one.
+	namespace string
+
+	// remediation tracks the tool calls of the current query, to verify fixes.
+	remediation remediation
+
+	// cacheable tracks the tool calls of the current query, to cache its answer.
+	cacheable cacheableQuery
+
+	// notes are pinned to the session by the user.
+	notes []string
+
+	// owner is the user who owns the session, see ClaimSession.
+	owner string
+
+	// tags organize the session, see AddTag.
+	tags []string
+
+	// versionSkew is the unsupported skew between kubectl and the API server
+	// found at startup, if any.
+	versionSkew *tools.VersionSkew
+
+	// lastError is the last error reported to the user.
+	lastError error
+
+	// toolWizard is the interview of the "new-tool" meta command in progress, if any.
+	toolWizard *toolWizard
 }
 
 // Assert Session implements ChatMessageStore
@@ -138,15 +411,68 @@ func (s *Agent) Session() *api.Session {
 
 // addMessage creates a new message, adds it to the session, and sends it to the output channel
 func (c *Agent) addMessage(source api.MessageSource, messageType api.MessageType, payload any) *api.Message {
-	c.sessionMu.Lock()
-	defer c.sessionMu.Unlock()
-	message := &api.Message{
+	return c.postMessage(&api.Message{
 		ID:        uuid.New().String(),
 		Source:    source,
 		Type:      messageType,
 		Payload:   payload,
 		Timestamp: time.Now(),
+	})
+}
+
+// addUserMessage adds a query of the user to the session, attributed to its
+// author, and records it in the journal for the audit trail.
+func (c *Agent) addUserMessage(ctx context.Context, query string, author string) *api.Message {
+	payload := map[string]any{"author": author}
+	if gollm.PromptContentLogged(ctx) {
+		payload["query"] = query
+	} else {
+		payload["queryLength"] = len(query)
+	}
+	journal.RecorderFromContext(ctx).Write(ctx, &journal.Event{
+		Timestamp: time.Now(),
+		Action:    journal.ActionUserQuery,
+		Payload:   payload,
+	})
+	return c.postMessage(&api.Message{
+		ID:        uuid.New().String(),
+		Source:    api.MessageSourceUser,
+		Type:      api.MessageTypeText,
+		Payload:   query,
+		Timestamp: time.Now(),
+		Author:    author,
+	})
+}
+
+// promptLogContext samples whether the content of the requests of a query,
+// and the query itself, are logged, see gollm.PromptLogOptions.
+func (c *Agent) promptLogContext(ctx context.Context) context.Context {
+	return gollm.ContextWithPromptLog(ctx, c.PromptLog.Decide())
+}
+
+// loggedContent returns the query, or the response of the LLM, to log, unless
+// the content of the query must not be logged.
+func loggedContent(ctx context.Context, query any) any {
+	if gollm.PromptContentLogged(ctx) {
+		return query
 	}
+	return "<redacted>"
+}
+
+// withAuthor tells the model who sent a query, so that it can address the
+// operators by name in sessions shared by several of them. Only UIs that
+// identify their users set the author of queries.
+func withAuthor(query, author string) string {
+	if author == "" {
+		return query
+	}
+	return fmt.Sprintf("Message from operator %s:\n%s", author, query)
+}
+
+// postMessage adds a message to the session, and sends it to the output channel
+func (c *Agent) postMessage(message *api.Message) *api.Message {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	if c.session.ChatMessageStore != nil {
 		c.session.ChatMessageStore.AddChatMessage(message)
 	}
@@ -156,6 +482,19 @@ func (c *Agent) addMessage(source api.MessageSource, messageType api.MessageType
 	return message
 }
 
+// sendProgress tells the UI which phase the agent entered. Progress messages
+// are not added to the session.
+func (c *Agent) sendProgress(phase api.ProgressPhase, detail string) {
+	now := time.Now()
+	c.Output <- &api.Message{
+		ID:        uuid.New().String(),
+		Source:    api.MessageSourceAgent,
+		Type:      api.MessageTypeProgress,
+		Payload:   &api.Progress{Phase: phase, Detail: detail, Started: now},
+		Timestamp: now,
+	}
+}
+
 // setAgentState updates the agent state and ensures LastModified is updated
 func (c *Agent) setAgentState(newState api.AgentState) {
 	c.sessionMu.Lock()
@@ -193,6 +532,9 @@ func (s *Agent) Init(ctx context.Context) error {
 	if s.InitialQuery == "" && s.RunOnce {
 		return fmt.Errorf("RunOnce mode requires an initial query to be provided")
 	}
+	if _, ok := s.tokenPrices(s.Model); s.MaxCost > 0 && !ok {
+		return fmt.Errorf("the prices of the tokens of model %q are unknown, they are required by the maximum cost", s.Model)
+	}
 
 	s.session = &api.Session{
 		Messages:         s.ChatMessageStore.ChatMessages(),
@@ -200,45 +542,90 @@ func (s *Agent) Init(ctx context.Context) error {
 		ChatMessageStore: s.ChatMessageStore,
 	}
 
+	s.env = maps.Clone(s.Env)
 	if session, ok := s.ChatMessageStore.(*sessions.Session); ok {
-		s.loadSession(session.ID)
+		if err := s.loadSession(session.ID, s.ForceSessionTakeover); err != nil {
+			var locked *sessions.LockedError
+			if errors.As(err, &locked) {
+				return err
+			}
+			log.Error(err, "Failed to load session", "sessionID", session.ID)
+		}
 	} else {
 		s.session.ID = uuid.New().String()
 		s.session.CreatedAt = time.Now()
 		s.session.LastModified = time.Now()
 	}
+	for _, tag := range s.Tags {
+		if err := s.AddTag(tag); err != nil {
+			log.Error(err, "Failed to tag session", "tag", tag)
+		}
+	}
 
-	// Create a temporary working directory
-	workDir, err := os.MkdirTemp("", "agent-workdir-*")
-	if err != nil {
-		log.Error(err, "Failed to create temporary working directory")
-		return err
+	if !s.RunOnce {
+		s.watches = tools.NewWatches(s.notifyWatch)
+	}
+	if s.metrics == nil {
+		s.metrics = tools.NewMetricsHistory(nil, s.saveMetricSamples)
 	}
 
-	log.Info("Created temporary working directory", "workDir", workDir)
+	s.usage = &sessions.Usage{}
+	if kubeContext, err := tools.CurrentContext(s.Kubeconfig); err != nil {
+		log.V(2).Info("Unable to determine current kubeconfig context", "err", err)
+	} else {
+		s.usage.Context = kubeContext
+	}
+	s.environment = ClassifyContext(s.ContextEnvironments, s.usage.Context)
+	if len(s.FreezeWindows) > 0 || s.Policy != nil {
+		if namespace, err := tools.CurrentNamespace(s.Kubeconfig); err != nil {
+			// Commands without a namespace are then frozen by all windows,
+			// and have the "" namespace in policies.
+			log.V(2).Info("Unable to determine current namespace", "err", err)
+		} else {
+			s.namespace = namespace
+		}
+	}
 
-	systemPrompt, err := s.generatePrompt(ctx, defaultSystemPromptTemplate, PromptData{
-		Tools:             s.Tools,
-		EnableToolUseShim: s.EnableToolUseShim,
-	})
+	workDir := s.WorkDir
+	if workDir != "" {
+		if err := os.MkdirAll(workDir, 0o755); err != nil {
+			return fmt.Errorf("creating working directory %q: %w", workDir, err)
+		}
+		log.Info("Using working directory", "workDir", workDir)
+	} else {
+		// Create a temporary working directory
+		var err error
+		workDir, err = newTempWorkDir()
+		if err != nil {
+			log.Error(err, "Failed to create temporary working directory")
+			return err
+		}
+
+		log.Info("Created temporary working directory", "workDir", workDir)
+	}
+
+	systemPrompt, err := s.defaultSystemPrompt(ctx, workDir)
 	if err != nil {
-		return fmt.Errorf("generating system prompt: %w", err)
+		return err
+	}
+
+	if s.CheckVersionSkew {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		skew, err := tools.CheckVersionSkew(ctx, tools.InvokeToolOptions{Kubeconfig: s.Kubeconfig, WorkDir: workDir, Env: s.env})
+		cancel()
+		if err != nil {
+			log.V(2).Info("Unable to compare the versions of kubectl and of the cluster", "err", err)
+		} else if skew != nil {
+			log.Info("Unsupported version skew between kubectl and the cluster", "client", skew.Client, "server", skew.Server)
+			s.versionSkew = skew
+			systemPrompt += versionSkewPrompt(skew)
+		}
 	}
 
 	// Start a new chat session
-	s.llmChat = gollm.NewRetryChat(
-		s.LLM.StartChat(systemPrompt, s.Model),
-		gollm.RetryConfig{
-			MaxAttempts:    3,
-			InitialBackoff: 10 * time.Second,
-			MaxBackoff:     60 * time.Second,
-			BackoffFactor:  2,
-			Jitter:         true,
-		},
-	)
-	err = s.llmChat.Initialize(s.session.ChatMessageStore.ChatMessages())
-	if err != nil {
-		return fmt.Errorf("initializing chat session: %w", err)
+	s.systemPrompt = systemPrompt
+	if err := s.startChat(s.Model); err != nil {
+		return err
 	}
 
 	if s.MCPClientEnabled {
@@ -253,52 +640,141 @@ func (s *Agent) Init(ctx context.Context) error {
 		}
 	}
 
-	if !s.EnableToolUseShim {
-		var functionDefinitions []*gollm.FunctionDefinition
-		for _, tool := range s.Tools.AllTools() {
-			functionDefinitions = append(functionDefinitions, tool.FunctionDefinition())
-		}
-		// Sort function definitions to help KV cache reuse
-		sort.Slice(functionDefinitions, func(i, j int) bool {
-			return functionDefinitions[i].Name < functionDefinitions[j].Name
-		})
-		if err := s.llmChat.SetFunctionDefinitions(functionDefinitions); err != nil {
-			return fmt.Errorf("setting function definitions: %w", err)
-		}
+	if err := s.setFunctionDefinitions(); err != nil {
+		return err
 	}
 	s.workDir = workDir
 
 	return nil
 }
 
+// defaultSystemPrompt generates the system prompt from the default template,
+// for the tools and the tool-use shim mode of the agent.
+func (s *Agent) defaultSystemPrompt(ctx context.Context, workDir string) (string, error) {
+	systemPrompt, err := s.generatePrompt(ctx, defaultSystemPromptTemplate, PromptData{
+		Tools:             s.Tools,
+		EnableToolUseShim: s.EnableToolUseShim,
+		discoverCluster: func() *tools.ClusterInfo {
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			return tools.DiscoverClusterInfo(ctx, tools.InvokeToolOptions{
+				Kubeconfig: s.Kubeconfig,
+				WorkDir:    workDir,
+				Env:        s.env,
+			})
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("generating system prompt: %w", err)
+	}
+	if s.ReadOnly {
+		systemPrompt += readOnlyPrompt
+	}
+	return systemPrompt, nil
+}
+
+// startChat starts the chat with the model, replaying the messages of the session.
+func (c *Agent) startChat(model string) error {
+	c.llmChat = c.accountChat(gollm.NewRetryChat(
+		c.LLM.StartChat(c.systemPrompt, model),
+		gollm.RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     60 * time.Second,
+			BackoffFactor:  2,
+			Jitter:         true,
+		},
+	), model)
+	c.chatModel = model
+	if err := c.initializeChat(c.session.ChatMessageStore.ChatMessages()); err != nil {
+		return fmt.Errorf("initializing chat session: %w", err)
+	}
+	return nil
+}
+
+// setFunctionDefinitions declares the tools to the model, unless the tool-use shim is enabled.
+func (c *Agent) setFunctionDefinitions() error {
+	if c.EnableToolUseShim {
+		return nil
+	}
+	functionDefinitions := c.functionDefinitions()
+	if err := c.llmChat.SetFunctionDefinitions(functionDefinitions); err != nil {
+		return fmt.Errorf("setting function definitions: %w", err)
+	}
+	return nil
+}
+
+// routeQuery switches the chat to the model selected by the router for the query.
+// The chat is restarted with the messages of the session when the model changes.
+func (c *Agent) routeQuery(ctx context.Context, query string) error {
+	if c.Router == nil {
+		return nil
+	}
+	decision := c.Router.Route(ctx, query)
+	if decision.Model == "" || decision.Model == c.chatModel {
+		return nil
+	}
+	if err := c.startChat(decision.Model); err != nil {
+		return err
+	}
+	return c.setFunctionDefinitions()
+}
+
 func (c *Agent) Close() error {
-	if c.workDir != "" {
+	// A persistent working directory is never removed.
+	if c.workDir != "" && c.WorkDir == "" {
 		if c.RemoveWorkDir {
 			if err := os.RemoveAll(c.workDir); err != nil {
 				klog.Warningf("error cleaning up directory %q: %v", c.workDir, err)
 			}
 		}
 	}
+	if c.watches != nil {
+		c.watches.Close()
+	}
 	// Close MCP client connections
 	if err := c.CloseMCPClient(); err != nil {
 		klog.Warningf("error closing MCP client: %v", err)
 	}
+	if s, ok := c.ChatMessageStore.(*sessions.Session); ok && !c.usage.IsEmpty() {
+		if err := s.RecordUsage(c.usage); err != nil {
+			klog.Warningf("error recording session usage: %v", err)
+		}
+	}
+	c.recordTokenUsage()
+	event := &HookEvent{Event: HookEventSessionEnd, SessionID: c.sessionID(), Timestamp: time.Now(), Context: c.kubeContext(), Environment: c.environment}
+	if !c.usage.IsEmpty() {
+		event.Usage = c.usage
+	}
+	c.runHooks(context.Background(), event)
+	if s, ok := c.ChatMessageStore.(*sessions.Session); ok {
+		if err := s.Unlock(); err != nil {
+			klog.Warningf("error unlocking session: %v", err)
+		}
+	}
 	return nil
 }
 
 func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 	log := klog.FromContext(ctx)
 
-	log.Info("Starting agent loop", "initialQuery", initialQuery, "runOnce", c.RunOnce)
+	baseCtx := ctx
+	ctx = c.promptLogContext(baseCtx)
+	log.Info("Starting agent loop", "initialQuery", loggedContent(ctx, initialQuery), "runOnce", c.RunOnce)
+	// The output of --quiet runs is only the answer, the skew is logged.
+	if c.versionSkew != nil && !c.RunOnce {
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "Warning: "+c.versionSkew.String()+".")
+	}
 	go func() {
 		if initialQuery != "" {
-			c.addMessage(api.MessageSourceUser, api.MessageTypeText, initialQuery)
+			attachments := c.takeInitialAttachments()
+			c.addUserMessage(ctx, describeUserInput(&api.UserInputResponse{Query: initialQuery, Attachments: attachments}), localApprover())
 			answer, handled, err := c.handleMetaQuery(ctx, initialQuery)
 			if err != nil {
 				log.Error(err, "error handling meta query")
 				c.setAgentState(api.AgentStateDone)
 				c.pendingFunctionCalls = []ToolCallAnalysis{}
-				c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+				c.addError(ctx, err)
 			} else if handled {
 				// initialQuery is the 'exit' or 'quit' metaquery
 				if c.AgentState() == api.AgentStateExited {
@@ -310,11 +786,26 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 				c.setAgentState(api.AgentStateDone)
 				c.pendingFunctionCalls = []ToolCallAnalysis{}
 				c.addMessage(api.MessageSourceAgent, api.MessageTypeText, answer)
+			} else if len(attachments) == 0 && c.answerFromCache(ctx, initialQuery) {
+				c.setAgentState(api.AgentStateDone)
+				c.pendingFunctionCalls = []ToolCallAnalysis{}
+			} else if c.Offline {
+				c.setAgentState(api.AgentStateDone)
+				c.pendingFunctionCalls = []ToolCallAnalysis{}
+				c.addError(ctx, offlineError("answering queries"))
+			} else if err := c.routeQuery(ctx, initialQuery); err != nil {
+				log.Error(err, "error routing query")
+				c.setAgentState(api.AgentStateDone)
+				c.pendingFunctionCalls = []ToolCallAnalysis{}
+				c.addError(ctx, classifyProviderError(err))
 			} else {
 				// Start the agentic loop with the initial query
 				c.setAgentState(api.AgentStateRunning)
 				c.currIteration = 0
-				c.currChatContent = []any{initialQuery}
+				c.queryStart = time.Now()
+				c.remediation = remediation{query: initialQuery}
+				c.cacheable = cacheableQuery{query: initialQuery, readOnly: len(attachments) == 0}
+				c.currChatContent = []any{c.withNotes(c.withWatchEvents(withAttachments(c.withLanguage(initialQuery), attachments)))}
 				c.pendingFunctionCalls = []ToolCallAnalysis{}
 			}
 		} else {
@@ -346,7 +837,8 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 					log.Info("Agent loop done")
 					return
 				case userInput = <-c.Input:
-					log.Info("Received input from channel", "userInput", userInput)
+					ctx = c.promptLogContext(baseCtx)
+					log.Info("Received input from channel", "userInput", loggedContent(ctx, userInput))
 					if userInput == io.EOF {
 						log.Info("Agent loop done, EOF received")
 						c.setAgentState(api.AgentStateExited)
@@ -358,11 +850,33 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						log.Error(nil, "Received unexpected input from channel", "userInput", userInput)
 						return
 					}
-					if strings.TrimSpace(query.Query) == "" {
+					if strings.TrimSpace(query.Query) == "" && len(query.Images) == 0 {
 						log.Info("No query provided, skipping agentic loop")
 						continue
 					}
-					c.addMessage(api.MessageSourceUser, api.MessageTypeText, query.Query)
+					if len(c.InitialAttachments) > 0 {
+						query.Attachments = append(c.takeInitialAttachments(), query.Attachments...)
+					}
+					author := query.Author
+					if author == "" {
+						author = localApprover()
+					}
+					c.addUserMessage(ctx, describeUserInput(query), author)
+					if c.toolWizard != nil {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, c.continueToolWizard(query.Query))
+						continue
+					}
+					if expansion, ok := c.expandAlias(query.Query); ok {
+						query.Query = expansion
+					}
+					if index, ok := parseRunQuery(query.Query); ok && len(query.Images) == 0 {
+						c.runSnippet(ctx, index)
+						continue
+					}
+					if isUndoQuery(query.Query) {
+						c.undoLastChange(ctx)
+						continue
+					}
 					// we don't need the agentic loop for meta queries
 					// for ex. model, tools, etc.
 					answer, handled, err := c.handleMetaQuery(ctx, query.Query)
@@ -370,7 +884,7 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						log.Error(err, "error handling meta query")
 						c.setAgentState(api.AgentStateDone)
 						c.pendingFunctionCalls = []ToolCallAnalysis{}
-						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+						c.addError(ctx, err)
 						continue
 					}
 					if handled {
@@ -386,10 +900,37 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, answer)
 						continue
 					}
+					if len(query.Images) == 0 && len(query.Attachments) == 0 && c.answerFromCache(ctx, query.Query) {
+						c.setAgentState(api.AgentStateDone)
+						c.pendingFunctionCalls = []ToolCallAnalysis{}
+						continue
+					}
+					if c.Offline {
+						c.setAgentState(api.AgentStateDone)
+						c.pendingFunctionCalls = []ToolCallAnalysis{}
+						c.addError(ctx, offlineError("answering queries"))
+						continue
+					}
+					if err := c.routeQuery(ctx, query.Query); err != nil {
+						log.Error(err, "error routing query")
+						c.setAgentState(api.AgentStateDone)
+						c.pendingFunctionCalls = []ToolCallAnalysis{}
+						c.addError(ctx, classifyProviderError(err))
+						continue
+					}
 
 					c.setAgentState(api.AgentStateRunning)
 					c.currIteration = 0
-					c.currChatContent = []any{query.Query}
+					c.queryStart = time.Now()
+					c.truncatedText = ""
+					c.truncatedCitations = nil
+					c.continuations = 0
+					c.remediation = remediation{query: query.Query}
+					c.cacheable = cacheableQuery{query: query.Query, readOnly: len(query.Images) == 0 && len(query.Attachments) == 0}
+					c.currChatContent = []any{c.withNotes(c.withWatchEvents(withAuthor(withAttachments(c.withLanguage(query.Query), query.Attachments), query.Author)))}
+					for _, image := range query.Images {
+						c.currChatContent = append(c.currChatContent, gollm.ImagePart{MIMEType: image.MIMEType, Data: image.Data})
+					}
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
 					log.Info("Set agent state to running, will process agentic loop", "currIteration", c.currIteration, "currChatContent", len(c.currChatContent))
 				}
@@ -398,7 +939,7 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 				if c.RunOnce {
 					log.Error(nil, "RunOnce mode cannot handle user choice requests")
 					c.setAgentState(api.AgentStateExited)
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: RunOnce mode cannot handle user choice requests")
+					c.addError(ctx, errors.New("RunOnce mode cannot handle user choice requests"))
 					return
 				}
 				select {
@@ -412,19 +953,32 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "It has been a pleasure assisting you. Have a great day!")
 						return
 					}
+					// The chat remains usable while tool calls await approval.
+					if query, ok := userInput.(*api.UserInputResponse); ok {
+						c.queueInput(ctx, query)
+						continue
+					}
 					choiceResponse, ok := userInput.(*api.UserChoiceResponse)
 					if !ok {
 						log.Error(nil, "Received unexpected input from channel", "userInput", userInput)
 						return
 					}
+					// Nothing is left to do when the user declines running a snippet.
+					userInitiated := len(c.pendingFunctionCalls) > 0 && c.pendingFunctionCalls[0].UserInitiated
 					dispatchToolCalls := c.handleChoice(ctx, choiceResponse)
+					if c.awaitingDecisions() {
+						// Other tool calls are still awaiting a decision.
+						c.sendProgress(api.ProgressPhaseWaitingForApproval, "")
+						continue
+					}
 					if dispatchToolCalls {
 						if err := c.DispatchToolCalls(ctx); err != nil {
 							log.Error(err, "error dispatching tool calls")
 							c.setAgentState(api.AgentStateDone)
 							c.pendingFunctionCalls = []ToolCallAnalysis{}
+							c.queuedContent = nil
 							c.session.LastModified = time.Now()
-							c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+							c.addError(ctx, err)
 							// In RunOnce mode, exit on tool execution error
 							if c.RunOnce {
 								c.setAgentState(api.AgentStateExited)
@@ -440,9 +994,16 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						// if user has declined, we are done with this iteration
 						c.currIteration = c.currIteration + 1
 						c.pendingFunctionCalls = []ToolCallAnalysis{}
-						c.setAgentState(api.AgentStateRunning)
+						if userInitiated && len(c.queuedContent) == 0 {
+							c.setAgentState(api.AgentStateDone)
+						} else {
+							c.setAgentState(api.AgentStateRunning)
+						}
 						c.session.LastModified = time.Now()
 					}
+					// Messages sent while the tool calls awaited approval follow their results.
+					c.currChatContent = append(c.currChatContent, c.queuedContent...)
+					c.queuedContent = nil
 				}
 			case api.AgentStateRunning:
 				// Agent is running, don't wait for input, just continue to process the agentic loop
@@ -455,29 +1016,44 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 			if c.AgentState() == api.AgentStateRunning {
 				log.Info("Processing agentic loop", "currIteration", c.currIteration, "maxIterations", c.MaxIterations, "currChatContentLen", len(c.currChatContent))
 
-				if c.currIteration >= c.MaxIterations {
-					c.setAgentState(api.AgentStateDone)
-					c.pendingFunctionCalls = []ToolCallAnalysis{}
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "Maximum number of iterations reached.")
+				if limit := c.queryLimit(); limit != "" {
+					c.stopAtLimit(ctx, limit)
 					continue
 				}
 
 				// we run the agentic loop for one iteration
+				c.sendProgress(api.ProgressPhaseThinking, "")
+				timer := newCallTimer()
 				stream, err := c.llmChat.SendStreaming(ctx, c.currChatContent...)
 				if err != nil {
 					log.Error(err, "error sending streaming LLM response")
+					err = classifyProviderError(err)
+					if c.fallBackToShim(ctx, err, c.currChatContent) {
+						continue
+					}
 					c.setAgentState(api.AgentStateDone)
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
+					c.addError(ctx, err)
 					continue
 				}
 
-				// Clear our "response" now that we sent the last response
+				// Clear our "response" now that we sent the last response,
+				// keeping it in case it has to be sent again with the shim.
+				sent := c.currChatContent
 				c.currChatContent = nil
 
+				if c.meter == nil {
+					prices, _ := c.tokenPrices(c.chatModel)
+					c.meter = newStreamMeter(c.Output, prices, c.MaxIterations)
+				}
+				// The meter counts the tokens of the raw response, before the shim buffers it.
+				stream = timer.Observe(c.meter.Observe(stream))
+
 				if c.EnableToolUseShim {
 					// convert the candidate response into a gollm.ChatResponse
 					stream, err = candidateToShimCandidate(stream)
 					if err != nil {
+						c.meter = nil
 						c.setAgentState(api.AgentStateDone)
 						c.pendingFunctionCalls = []ToolCallAnalysis{}
 
@@ -495,8 +1071,13 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 
 				// accumulator for streamed text
 				var streamedText string
+				var truncated bool
 				var llmError error
+				coalescer := newTextCoalescer(c.Stream, c.Output)
 
+				c.meter.Begin(c.currIteration + 1)
+				// The citations of a continued response include those of its previous parts.
+				citations := c.truncatedCitations
 				for response, err := range stream {
 					if err != nil {
 						log.Error(err, "error reading streaming LLM response")
@@ -520,35 +1101,97 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 					}
 
 					candidate := response.Candidates()[0]
+					if gollm.CandidateFinishReason(candidate) == gollm.FinishReasonMaxTokens {
+						truncated = true
+					}
+					for _, citation := range gollm.CandidateCitations(candidate) {
+						citations = appendCitation(citations, api.Citation{Title: citation.Title, URI: citation.URI})
+					}
 
 					for _, part := range candidate.Parts() {
 						// Check if it's a text response
 						if text, ok := part.AsText(); ok {
-							log.Info("text response", "text", text)
+							log.Info("text response", "text", loggedContent(ctx, text))
 							streamedText += text
+							coalescer.Add(text)
 						}
 
 						// Check if it's a function call
 						if calls, ok := part.AsFunctionCalls(); ok && len(calls) > 0 {
-							log.Info("function calls", "calls", calls)
+							log.Info("function calls", "calls", loggedContent(ctx, calls))
 							functionCalls = append(functionCalls, calls...)
 						}
 					}
 				}
+				if llmError == nil && !truncated && len(functionCalls) == 0 && c.truncatedText == "" {
+					llmError = c.ignoredToolsError(streamedText)
+				}
+				stats := c.meter.End()
+				if llmError == nil {
+					c.recordLLMCall(ctx, timer.End(c.Provider, c.chatModel))
+				}
 				if llmError != nil {
+					c.meter = nil
 					log.Error(llmError, "error streaming LLM response")
+					if c.truncatedText != "" {
+						// Keep the part of the response received before the error.
+						c.addMessage(api.MessageSourceModel, api.MessageTypeText, c.truncatedText)
+						c.truncatedText = ""
+					}
+					c.truncatedCitations = nil
+					llmError = classifyProviderError(llmError)
+					if c.fallBackToShim(ctx, llmError, sent) {
+						continue
+					}
 					c.setAgentState(api.AgentStateDone)
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+llmError.Error())
+					c.addError(ctx, llmError)
 					continue
 				}
-				log.Info("streamedText", "streamedText", streamedText)
+				log.Info("streamedText", "streamedText", loggedContent(ctx, streamedText))
+
+				// If the response was cut off mid-answer, ask the LLM to continue it,
+				// and present the parts as a single message.
+				// Nothing is sent to the UI meanwhile, so that streamed text keeps accumulating.
+				if truncated && len(functionCalls) == 0 && !c.EnableToolUseShim && c.continuations < c.MaxContinuations {
+					c.continuations++
+					c.truncatedText += streamedText
+					c.truncatedCitations = citations
+					log.Info("Response truncated by the output token limit, asking the LLM to continue", "continuations", c.continuations)
+					c.currChatContent = []any{continuePrompt}
+					continue
+				}
+				streamedText = c.truncatedText + streamedText
+				c.truncatedText = ""
+				c.truncatedCitations = nil
+				c.continuations = 0
+				c.meter = nil
 
 				if streamedText != "" {
-					c.addMessage(api.MessageSourceModel, api.MessageTypeText, streamedText)
+					labeledText, snippets := LabelSnippets(streamedText)
+					if len(snippets) > 0 {
+						c.snippets = snippets
+					}
+					var warnings []string
+					if len(functionCalls) == 0 {
+						// Final answers are validated before they are shown.
+						warnings = c.validateAnswer(ctx, streamedText)
+					}
+					c.postMessage(&api.Message{
+						ID:        uuid.New().String(),
+						Source:    api.MessageSourceModel,
+						Type:      api.MessageTypeText,
+						Payload:   labeledText,
+						Timestamp: time.Now(),
+						Stats:     stats,
+						Warnings:  warnings,
+						Citations: citations,
+					})
 				}
 				// If no function calls to be made, we're done
 				if len(functionCalls) == 0 {
+					c.cacheAnswer(ctx, streamedText)
+					c.verifyRemediation(ctx)
 					log.Info("No function calls to be made, so most likely the task is completed, so we're done.")
 					c.setAgentState(api.AgentStateDone)
 					c.currChatContent = []any{}
@@ -564,50 +1207,77 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 					c.setAgentState(api.AgentStateDone)
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
 					c.session.LastModified = time.Now()
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+					c.addError(ctx, err)
 					continue
 				}
 
 				// mark the tools for dispatching
 				c.pendingFunctionCalls = toolCallAnalysisResults
+				for _, result := range toolCallAnalysisResults {
+					c.cacheable.record(result)
+				}
 
-				interactiveToolCallIndex := -1
+				interactive := false
 				modifiesResourceToolCallIndex := -1
 				for i, result := range toolCallAnalysisResults {
 					if result.ModifiesResourceStr != "no" {
 						modifiesResourceToolCallIndex = i
 					}
 					if result.IsInteractive {
-						interactiveToolCallIndex = i
+						interactive = true
 					}
 				}
 
-				if interactiveToolCallIndex >= 0 {
-					// Show error block for both shim enabled and disabled modes
-					errorMessage := fmt.Sprintf("  %s\n", toolCallAnalysisResults[interactiveToolCallIndex].IsInteractiveError.Error())
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, errorMessage)
-
-					if c.EnableToolUseShim {
-						// Add the error as an observation
-						observation := fmt.Sprintf("Result of running %q:\n%v",
-							toolCallAnalysisResults[interactiveToolCallIndex].FunctionCall.Name,
-							toolCallAnalysisResults[interactiveToolCallIndex].IsInteractiveError.Error())
-						c.currChatContent = append(c.currChatContent, observation)
-					} else {
-						// For models with tool-use support (shim disabled), use proper FunctionCallResult
-						// Note: This assumes the model supports sending FunctionCallResult
-						c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
-							ID:     toolCallAnalysisResults[interactiveToolCallIndex].FunctionCall.ID,
-							Name:   toolCallAnalysisResults[interactiveToolCallIndex].FunctionCall.Name,
-							Result: map[string]any{"error": toolCallAnalysisResults[interactiveToolCallIndex].IsInteractiveError.Error()},
-						})
+				if interactive {
+					// None of the calls is run, the LLM is told why for the
+					// interactive ones, and that the others were not run.
+					for _, result := range toolCallAnalysisResults {
+						if !result.IsInteractive {
+							continue
+						}
+						// Show error block for both shim enabled and disabled modes
+						errorMessage := fmt.Sprintf("  %s\n", result.IsInteractiveError.Error())
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, errorMessage)
+
+						if c.EnableToolUseShim {
+							// Add the error as an observation
+							observation := fmt.Sprintf("Result of running %q:\n%v",
+								result.FunctionCall.Name,
+								result.IsInteractiveError.Error())
+							c.currChatContent = append(c.currChatContent, observation)
+						} else {
+							// For models with tool-use support (shim disabled), use proper FunctionCallResult
+							c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
+								ID:     result.FunctionCall.ID,
+								Name:   result.FunctionCall.Name,
+								Result: map[string]any{"error": result.IsInteractiveError.Error()},
+							})
+						}
 					}
 					c.pendingFunctionCalls = []ToolCallAnalysis{} // reset pending function calls
 					c.currIteration = c.currIteration + 1
 					continue // Skip execution for interactive commands
 				}
 
-				if !c.SkipPermissions && modifiesResourceToolCallIndex >= 0 {
+				if c.ReadOnly && modifiesResourceToolCallIndex >= 0 {
+					c.rejectModifyingCalls(ctx)
+					c.currIteration = c.currIteration + 1
+					continue
+				}
+
+				if c.rejectDeniedCalls(ctx) {
+					c.pendingFunctionCalls = []ToolCallAnalysis{}
+					c.currIteration = c.currIteration + 1
+					continue
+				}
+
+				if c.needsApproval() {
+					if c.RBACPreflight && c.rejectForbiddenCalls(ctx) {
+						c.pendingFunctionCalls = []ToolCallAnalysis{}
+						c.currIteration = c.currIteration + 1
+						continue
+					}
+
 					// In RunOnce mode, exit with error if permission is required
 					if c.RunOnce {
 						var commandDescriptions []string
@@ -619,30 +1289,14 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 
 						log.Error(nil, "RunOnce mode cannot handle permission requests", "commands", commandDescriptions)
 						c.setAgentState(api.AgentStateExited)
-						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, errorMessage)
+						c.addError(ctx, errors.New(errorMessage))
 						return
 					}
 
-					var commandDescriptions []string
-					for _, call := range c.pendingFunctionCalls {
-						commandDescriptions = append(commandDescriptions, call.ParsedToolCall.Description())
-					}
-					confirmationPrompt := "The following commands require your approval to run:\n* " + strings.Join(commandDescriptions, "\n* ")
-					confirmationPrompt += "\n\nDo you want to proceed ?"
-
-					choiceRequest := &api.UserChoiceRequest{
-						Prompt: confirmationPrompt,
-						Options: []api.UserChoiceOption{
-							{Value: "yes", Label: "Yes"},
-							{Value: "yes_and_dont_ask_me_again", Label: "Yes, and don't ask me again"},
-							{Value: "no", Label: "No"},
-						},
-					}
-					c.setAgentState(api.AgentStateWaitingForInput)
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeUserChoiceRequest, choiceRequest)
 					// Request input from the user by sending a message on the output channel.
 					// Remaining part of the loop will be now resumed when we receive a choice input
 					// from the user.
+					c.askForApproval(ctx)
 					continue
 				}
 
@@ -652,7 +1306,7 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 					c.setAgentState(api.AgentStateDone)
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
 					c.session.LastModified = time.Now()
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+					c.addError(ctx, err)
 					continue
 				}
 				c.currIteration = c.currIteration + 1
@@ -665,6 +1319,125 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 	return nil
 }
 
+// askForApproval asks the user to confirm the pending tool calls.
+func (c *Agent) askForApproval(ctx context.Context) {
+	commandDescriptions := c.queueApprovals(ctx)
+	confirmationPrompt := "The following commands require your approval to run:\n* " + strings.Join(commandDescriptions, "\n* ")
+	var freezes []string
+	for _, approval := range c.PendingApprovals() {
+		if approval.Freeze != "" && !slices.Contains(freezes, approval.Freeze) {
+			freezes = append(freezes, approval.Freeze)
+		}
+	}
+	for _, call := range c.pendingFunctionCalls {
+		if call.Policy != nil && call.Policy.Verdict == tools.PolicyAsk {
+			confirmationPrompt += fmt.Sprintf("\n\nThe policy rule %q requires approval for %s", call.Policy.Rule, call.ParsedToolCall.Description())
+			if call.Policy.Message != "" {
+				confirmationPrompt += ": " + call.Policy.Message
+			}
+			confirmationPrompt += "."
+		}
+	}
+	if len(freezes) > 0 {
+		confirmationPrompt += fmt.Sprintf("\n\nChanges are frozen (%s). Approving them breaks the glass: a reason is required, and recorded in the audit log.", strings.Join(freezes, ", "))
+	}
+	confirmationPrompt += "\n\nDo you want to proceed ?"
+
+	choiceRequest := &api.UserChoiceRequest{
+		Prompt: confirmationPrompt,
+		Options: []api.UserChoiceOption{
+			{Value: "yes", Label: "Yes"},
+			{Value: "yes_and_dont_ask_me_again", Label: "Yes, and don't ask me again"},
+			{Value: "no", Label: "No"},
+		},
+		RequireReason: len(freezes) > 0,
+	}
+	c.setAgentState(api.AgentStateWaitingForInput)
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeUserChoiceRequest, choiceRequest)
+	c.sendProgress(api.ProgressPhaseWaitingForApproval, "")
+}
+
+// runSnippet runs a snippet of the last answer on behalf of the user, through
+// the same analysis and confirmation as the tool calls of the LLM.
+// The output is then sent to the LLM, for it to interpret.
+func (c *Agent) runSnippet(ctx context.Context, index int) {
+	log := klog.FromContext(ctx)
+
+	c.pendingFunctionCalls = []ToolCallAnalysis{}
+	if index < 1 || index > len(c.snippets) {
+		c.setAgentState(api.AgentStateDone)
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("There is no snippet #%d in the last answer.", index))
+		return
+	}
+	snippet := c.snippets[index-1]
+
+	call := gollm.FunctionCall{
+		ID:   uuid.New().String(),
+		Name: "bash",
+		Arguments: map[string]any{
+			"command":           snippet.Code,
+			"modifies_resource": "unknown",
+		},
+	}
+	analysis, err := c.analyzeToolCalls(ctx, []gollm.FunctionCall{call})
+	if err != nil {
+		log.Error(err, "error analyzing snippet", "snippet", snippet.Index)
+		c.setAgentState(api.AgentStateDone)
+		c.addError(ctx, err)
+		return
+	}
+	analysis[0].UserInitiated = true
+	if analysis[0].IsInteractive {
+		c.setAgentState(api.AgentStateDone)
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, analysis[0].IsInteractiveError.Error())
+		return
+	}
+
+	if reason := c.refusal(ctx, analysis[0]); reason != "" {
+		c.setAgentState(api.AgentStateDone)
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("Snippet #%d is not run: %s.", index, reason))
+		return
+	}
+
+	c.currIteration = 0
+	c.currChatContent = nil
+	c.pendingFunctionCalls = analysis
+	if c.needsApproval() {
+		c.askForApproval(ctx)
+		return
+	}
+
+	c.setAgentState(api.AgentStateRunning)
+	if err := c.DispatchToolCalls(ctx); err != nil {
+		log.Error(err, "error running snippet", "snippet", snippet.Index)
+		c.setAgentState(api.AgentStateDone)
+		c.addError(ctx, err)
+	}
+	c.pendingFunctionCalls = []ToolCallAnalysis{}
+}
+
+// continuePrompt asks the LLM to continue a response cut off by the output token limit.
+const continuePrompt = "Your previous response was cut off because it reached the output token limit. " +
+	"Continue exactly where it stopped, without repeating or summarizing what you already wrote."
+
+// describeUserInput returns the text of a user query as recorded in the
+// conversation, mentioning the attached images and reference material (which
+// are only sent to the LLM).
+func describeUserInput(query *api.UserInputResponse) string {
+	text := maskEnvQuery(query.Query)
+	for i, image := range query.Images {
+		name := image.Name
+		if name == "" {
+			name = fmt.Sprintf("image %d", i+1)
+		}
+		text += fmt.Sprintf("\n[attached %s (%s, %d KiB)]", name, image.MIMEType, (len(image.Data)+1023)/1024)
+	}
+	for _, attachment := range query.Attachments {
+		text += fmt.Sprintf("\n[attached %s (%d lines, %d KiB)]", attachmentLabel(attachment), strings.Count(strings.TrimRight(attachment.Content, "\n"), "\n")+1, (len(attachment.Content)+1023)/1024)
+	}
+	return strings.TrimSpace(text)
+}
+
 func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer string, handled bool, err error) {
 	switch query {
 	case "clear", "reset":
@@ -673,12 +1446,15 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 		if err := c.session.ChatMessageStore.ClearChatMessages(); err != nil {
 			return "Failed to clear the conversation", false, err
 		}
-		c.llmChat.Initialize(c.session.ChatMessageStore.ChatMessages())
+		c.initializeChat(c.session.ChatMessageStore.ChatMessages())
 		c.sessionMu.Unlock()
+		c.snippets = nil
 		return "Cleared the conversation.", true, nil
 	case "exit", "quit":
 		c.setAgentState(api.AgentStateExited)
 		return "It has been a pleasure assisting you. Have a great day!", true, nil
+	case "help":
+		return c.metaCommandsHelp(), true, nil
 	case "model":
 		return "Current model is `" + c.Model + "`", true, nil
 	case "models":
@@ -687,8 +1463,14 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 			return "", false, fmt.Errorf("listing models: %w", err)
 		}
 		return "Available models:\n\n  - " + strings.Join(models, "\n  - ") + "\n\n", true, nil
+	case "stats":
+		return c.handleStatsQuery(), true, nil
+	case "usage", "cost":
+		return c.handleUsageQuery(), true, nil
 	case "tools":
 		return "Available tools:\n\n  - " + strings.Join(c.Tools.Names(), "\n  - ") + "\n\n", true, nil
+	case "new-tool", "/new-tool":
+		return c.startToolWizard(), true, nil
 	case "session":
 		if s, ok := c.ChatMessageStore.(*sessions.Session); ok {
 			out, err := s.String()
@@ -706,6 +1488,13 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 		}
 		return "Saved session as " + savedSessionID, true, nil
 
+	case "new-session":
+		answer, err := c.newSession(ctx)
+		if err != nil {
+			return "", false, err
+		}
+		return answer, true, nil
+
 	case "sessions":
 		manager, err := sessions.NewSessionManager()
 		if err != nil {
@@ -723,8 +1512,8 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 		// Add ```text so markdown doesn't wreck the format
 		availableSessions := "```text"
 		availableSessions += "Available sessions:\n\n"
-		availableSessions += "ID\t\t\tCreated\t\t\tLast Accessed\t\tModel\t\tProvider\n"
-		availableSessions += "--\t\t\t-------\t\t\t-------------\t\t-----\t\t--------\n"
+		availableSessions += "ID\t\t\tCreated\t\t\tLast Accessed\t\tModel\t\tProvider\tContext\n"
+		availableSessions += "--\t\t\t-------\t\t\t-------------\t\t-----\t\t--------\t-------\n"
 
 		for _, session := range sessionList {
 			metadata, err := session.LoadMetadata()
@@ -733,25 +1522,62 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 				continue
 			}
 
-			availableSessions += fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n",
+			var kubeContext string
+			if metadata.Usage != nil {
+				kubeContext = metadata.Usage.Context
+			}
+			availableSessions += fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\n",
 				session.ID,
 				metadata.CreatedAt.Format("2006-01-02 15:04"),
 				metadata.LastAccessed.Format("2006-01-02 15:04"),
 				metadata.ModelID,
-				metadata.ProviderID)
+				metadata.ProviderID,
+				kubeContext)
 		}
 		// close the ```text box
 		availableSessions += "```"
 		return availableSessions, true, nil
 	}
 
+	if q := strings.TrimPrefix(query, "/"); q == "notes" || q == "note" || strings.HasPrefix(q, "note add ") || strings.HasPrefix(q, "note remove ") {
+		return c.handleNoteQuery(q)
+	}
+
+	if q := strings.TrimPrefix(query, "/"); q == "tags" || q == "tag" || strings.HasPrefix(q, "tag add ") || strings.HasPrefix(q, "tag remove ") {
+		return c.handleTagQuery(q)
+	}
+
+	if query == "env" || strings.HasPrefix(query, "env ") {
+		return c.handleEnvQuery(query)
+	}
+
+	if query == "job" || strings.HasPrefix(query, "job ") {
+		return c.handleJobQuery(ctx, query)
+	}
+
+	if query == "fanout" || strings.HasPrefix(query, "fanout ") {
+		return c.handleFanOutQuery(ctx, query)
+	}
+
+	if query == "watches" || strings.HasPrefix(query, "watches ") {
+		return c.handleWatchesQuery(query)
+	}
+
+	if model, ok := strings.CutPrefix(query, "model "); ok && strings.TrimSpace(model) != "" {
+		answer, err := c.switchModel(strings.TrimSpace(model))
+		if err != nil {
+			return "", false, fmt.Errorf("switching to model %s: %w", strings.TrimSpace(model), err)
+		}
+		return answer, true, nil
+	}
+
 	if strings.HasPrefix(query, "resume-session") {
 		parts := strings.Split(query, " ")
 		if len(parts) != 2 {
 			return "Invalid command. Usage: resume-session <session_id>", true, nil
 		}
 		sessionID := parts[1]
-		if err := c.loadSession(sessionID); err != nil {
+		if err := c.loadSession(sessionID, false); err != nil {
 			return "", false, err
 		}
 		return fmt.Sprintf("Resumed session %s.", sessionID), true, nil
@@ -777,26 +1603,37 @@ func (c *Agent) SaveSession() (string, error) {
 		LastAccessed: time.Now(),
 		ModelID:      c.Model,
 		ProviderID:   c.Provider,
+		Owner:        c.owner,
+		Tags:         c.tags,
 	}
 	newSession, err := manager.NewSession(metadata)
 	if err != nil {
 		return "", fmt.Errorf("failed to create new session: %w", err)
 	}
+	if err := newSession.Lock(false); err != nil {
+		return "", fmt.Errorf("failed to lock new session: %w", err)
+	}
 
 	messages := c.ChatMessageStore.ChatMessages()
 	if err := newSession.SetChatMessages(messages); err != nil {
 		return "", fmt.Errorf("failed to save chat messages to new session: %w", err)
 	}
+	if c.metrics != nil {
+		if err := newSession.AddMetricSamples(c.metrics.Samples()); err != nil {
+			return "", fmt.Errorf("failed to save metric samples to new session: %w", err)
+		}
+	}
 
 	c.ChatMessageStore = newSession
 	c.session.ChatMessageStore = newSession
-	c.llmChat.Initialize(c.ChatMessageStore.ChatMessages())
+	c.initializeChat(c.ChatMessageStore.ChatMessages())
 
 	return newSession.ID, nil
 }
 
 // loadSession loads a session by ID (or latest), updates the agent's state, and re-initializes the chat.
-func (c *Agent) loadSession(sessionID string) error {
+// The session is locked, taking over the lock of another process if force is set.
+func (c *Agent) loadSession(sessionID string, force bool) error {
 	manager, err := sessions.NewSessionManager()
 	if err != nil {
 		return fmt.Errorf("failed to create session manager: %w", err)
@@ -821,6 +1658,21 @@ func (c *Agent) loadSession(sessionID string) error {
 		session = s
 	}
 
+	prev, _ := c.ChatMessageStore.(*sessions.Session)
+	if prev != nil && prev.ID == session.ID {
+		// Keep the lock we may already hold on the session.
+		session = prev
+	}
+	if err := session.Lock(force); err != nil {
+		return err
+	}
+	if prev != nil && prev != session {
+		c.recordTokenUsage()
+		if err := prev.Unlock(); err != nil {
+			klog.Warningf("error unlocking session %s: %v", prev.ID, err)
+		}
+	}
+
 	c.sessionMu.Lock()
 	defer c.sessionMu.Unlock()
 
@@ -833,6 +1685,18 @@ func (c *Agent) loadSession(sessionID string) error {
 	}
 	c.session.ID = session.ID
 	c.session.CreatedAt = metadata.CreatedAt
+	c.env = maps.Clone(c.Env)
+	if len(metadata.Env) > 0 {
+		if c.env == nil {
+			c.env = make(map[string]string)
+		}
+		maps.Copy(c.env, metadata.Env)
+	}
+	c.notes = slices.Clone(metadata.Notes)
+	c.owner = metadata.Owner
+	c.tags = slices.Clone(metadata.Tags)
+	c.tokens.reset(metadata.TokenUsage)
+	c.metrics = tools.NewMetricsHistory(session.MetricSamples(), c.saveMetricSamples)
 	now := time.Now()
 	c.session.LastModified = now
 	metadata.LastAccessed = now
@@ -841,7 +1705,7 @@ func (c *Agent) loadSession(sessionID string) error {
 	}
 
 	if c.llmChat != nil {
-		if err := c.llmChat.Initialize(c.session.ChatMessageStore.ChatMessages()); err != nil {
+		if err := c.initializeChat(c.session.ChatMessageStore.ChatMessages()); err != nil {
 			return fmt.Errorf("failed to re-initialize chat with new session: %w", err)
 		}
 	}
@@ -862,22 +1726,81 @@ func (c *Agent) listModels(ctx context.Context) ([]string, error) {
 
 func (c *Agent) DispatchToolCalls(ctx context.Context) error {
 	log := klog.FromContext(ctx)
+	// An approval only covers the calls pending when it was given.
+	defer func() { c.approval = nil }()
 	// execute all pending function calls
 	for _, call := range c.pendingFunctionCalls {
 		// Only show "Running" message and proceed with execution for non-interactive commands
 		toolDescription := call.ParsedToolCall.Description()
 
-		c.addMessage(api.MessageSourceModel, api.MessageTypeToolCallRequest, toolDescription)
-
-		output, err := call.ParsedToolCall.InvokeTool(ctx, tools.InvokeToolOptions{
-			Kubeconfig: c.Kubeconfig,
-			WorkDir:    c.workDir,
+		c.postMessage(&api.Message{
+			ID:        uuid.New().String(),
+			Source:    api.MessageSourceModel,
+			Type:      api.MessageTypeToolCallRequest,
+			Payload:   toolDescription,
+			Timestamp: time.Now(),
+			Approval:  c.toolCallApproval(call),
 		})
 
-		if err != nil {
-			log.Error(err, "error executing action", "output", output)
-			c.addMessage(api.MessageSourceAgent, api.MessageTypeToolCallResponse, err.Error())
-			return err
+		var output any
+		if err := c.runHooks(ctx, c.toolHookEvent(HookEventPreToolExec, call)); err != nil {
+			// A failed blocking hook vetoes the tool call, the LLM is told why.
+			log.Info("tool call blocked by hook", "tool", call.FunctionCall.Name, "err", err)
+			c.postMessage(&api.Message{
+				ID:            uuid.New().String(),
+				Source:        api.MessageSourceAgent,
+				Type:          api.MessageTypeError,
+				Payload:       "Tool call blocked: " + err.Error(),
+				Timestamp:     time.Now(),
+				ErrorCategory: api.ErrorCategoryPermissionDenied,
+			})
+			output = map[string]any{"error": "the tool call was blocked by a pre-tool-exec hook: " + err.Error()}
+		} else if result, ok := c.proposeGitOpsChange(ctx, call); ok {
+			// Resources managed by GitOps are changed in a pull request, not in the cluster.
+			output = result
+		} else {
+			c.sendProgress(api.ProgressPhaseRunningTool, toolDescription)
+			revision := c.manifestRevision(ctx, call)
+			var err error
+			output, err = call.ParsedToolCall.InvokeTool(ctx, tools.InvokeToolOptions{
+				Kubeconfig:   c.Kubeconfig,
+				WorkDir:      c.workDir,
+				Env:          c.env,
+				OutputBudget: c.KubectlOutputBudget,
+				Watches:      c.watches,
+				Metrics:      c.metrics,
+			})
+
+			postEvent := c.toolHookEvent(HookEventPostToolExec, call)
+			postEvent.Output = output
+			if err != nil {
+				postEvent.Error = err.Error()
+			}
+			c.runHooks(ctx, postEvent)
+
+			if err != nil {
+				log.Error(err, "error executing action", "output", output)
+				err = api.WithCategory(tools.ErrorCategory(output, err), err)
+				c.postMessage(&api.Message{
+					ID:            uuid.New().String(),
+					Source:        api.MessageSourceAgent,
+					Type:          api.MessageTypeToolCallResponse,
+					Payload:       err.Error(),
+					Timestamp:     time.Now(),
+					ErrorCategory: api.ErrorCategoryOf(err),
+				})
+				return err
+			}
+
+			firstChange := 0
+			if c.usage != nil {
+				firstChange = len(c.usage.ResourcesModified)
+			}
+			c.recordUsage(call)
+			c.recordMetrics(call, output)
+			c.recordChange(call, output, revision)
+			c.recordChangeOnObjects(ctx, call, output, revision)
+			c.remediation.record(call, output, firstChange)
 		}
 
 		// Handle timeout message using UI blocks
@@ -886,11 +1809,14 @@ func (c *Agent) DispatchToolCalls(ctx context.Context) error {
 		}
 		// Add the tool call result to maintain conversation flow
 		var payload any
-		if c.EnableToolUseShim {
+		if c.EnableToolUseShim || call.UserInitiated {
 			// Add the error as an observation
 			observation := fmt.Sprintf("Result of running %q:\n%v",
 				call.FunctionCall.Name,
 				output)
+			if call.UserInitiated {
+				observation = fmt.Sprintf("I ran the command:\n%s\nResult:\n%v", call.FunctionCall.Arguments["command"], output)
+			}
 			c.currChatContent = append(c.currChatContent, observation)
 			payload = observation
 		} else {
@@ -900,6 +1826,13 @@ func (c *Agent) DispatchToolCalls(ctx context.Context) error {
 				log.Error(err, "error converting tool result to map", "output", output)
 				return err
 			}
+			// Results reporting an error don't have to match the schema.
+			if errMsg, _ := result["error"].(string); errMsg == "" {
+				if violations := tools.ValidateOutput(call.ParsedToolCall.OutputSchema(), result); len(violations) > 0 {
+					log.Info("tool result doesn't match its output schema", "tool", call.FunctionCall.Name, "violations", violations)
+					result["output_schema_violations"] = violations
+				}
+			}
 			payload = result
 			c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
 				ID:     call.FunctionCall.ID,
@@ -907,11 +1840,100 @@ func (c *Agent) DispatchToolCalls(ctx context.Context) error {
 				Result: result,
 			})
 		}
-		c.addMessage(api.MessageSourceAgent, api.MessageTypeToolCallResponse, payload)
+		c.postMessage(&api.Message{
+			ID:            uuid.New().String(),
+			Source:        api.MessageSourceAgent,
+			Type:          api.MessageTypeToolCallResponse,
+			Payload:       payload,
+			Timestamp:     time.Now(),
+			ErrorCategory: tools.ErrorCategory(output, nil),
+		})
+		if chart := tools.ChartFromResult(toolDescription, output); chart != nil {
+			c.addMessage(api.MessageSourceAgent, api.MessageTypeChart, chart)
+		}
+	}
+	return nil
+}
+
+// toolCallApproval returns who approved a tool call, or nil for calls that
+// don't require confirmation.
+func (c *Agent) toolCallApproval(call ToolCallAnalysis) *api.Approval {
+	if call.Approval != nil {
+		// Read-only calls are approved individually when a policy asks to.
+		return call.Approval
+	}
+	if call.ModifiesResourceStr == "no" {
+		return nil
+	}
+	switch {
+	case call.Policy != nil && call.Policy.Verdict == tools.PolicyAllow:
+		return &api.Approval{Approver: localApprover(), Method: api.ApprovalMethodPolicy, Timestamp: time.Now(), Reason: call.Policy.Rule}
+	case c.approval != nil:
+		return c.approval
+	case c.dontAskAgainApprover != "":
+		return &api.Approval{Approver: c.dontAskAgainApprover, Method: api.ApprovalMethodDontAskAgain, Timestamp: time.Now()}
+	case c.SkipPermissions:
+		return &api.Approval{Approver: localApprover(), Method: api.ApprovalMethodSkipPermissions, Timestamp: time.Now()}
 	}
 	return nil
 }
 
+// localApprover identifies the OS user running kubectl-ai, who approves
+// tool calls in terminal UIs.
+func localApprover() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// appendCitation appends a citation, unless a page with the same URI is already cited.
+func appendCitation(citations []api.Citation, citation api.Citation) []api.Citation {
+	for _, c := range citations {
+		if c.URI == citation.URI {
+			return citations
+		}
+	}
+	return append(citations, citation)
+}
+
+// recordUsage accounts an executed tool call in the usage snapshot of the session.
+func (c *Agent) recordUsage(call ToolCallAnalysis) {
+	if c.usage == nil {
+		return
+	}
+	command, _ := call.FunctionCall.Arguments["command"].(string)
+	kubectlCommands := tools.ParseKubectlCommands(command)
+	if len(kubectlCommands) == 0 {
+		c.usage.RecordCommand(call.FunctionCall.Name)
+		return
+	}
+	for _, kc := range kubectlCommands {
+		c.usage.RecordCommand(strings.TrimSpace("kubectl " + kc.Verb))
+		c.usage.RecordNamespace(kc.Namespace)
+		if !kc.Modifies {
+			continue
+		}
+		resource := kc.Resource
+		if resource == "" && kc.Filename != "" {
+			resource = "-f " + kc.Filename
+		}
+		verb := kc.Verb
+		if kc.SubVerb != "" {
+			verb += " " + kc.SubVerb
+		}
+		c.usage.RecordChange(sessions.ResourceChange{
+			Verb:      verb,
+			Resource:  resource,
+			Namespace: kc.Namespace,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
 // The key idea is to treat all tool calls to be executed atomically or not
 // If all tool calls are readonly call, it is straight forward
 // if some of the tool calls are not readonly, then the interesting question is should the permission
@@ -924,6 +1946,110 @@ type ToolCallAnalysis struct {
 	IsInteractive       bool
 	IsInteractiveError  error
 	ModifiesResourceStr string
+	// UserInitiated is set for the calls the user asked to run (e.g. a snippet),
+	// which the LLM didn't request. Their results are sent to the LLM as text.
+	UserInitiated bool
+	// Approval is the approval of the call, if it was decided on individually.
+	Approval *api.Approval
+	// Policy is the decision of the policy on the call, nil if no rule matched.
+	Policy *tools.PolicyDecision
+}
+
+// commandPreview explains a tool call awaiting approval, for approvers who
+// didn't write the query: what each kubectl command does, the resources it
+// affects according to a server-side dry-run, and the problems found in the
+// manifests it applies.
+func (c *Agent) commandPreview(ctx context.Context, call ToolCallAnalysis) string {
+	if call.FunctionCall.Name == "apply_manifest" {
+		return c.applyManifestPreview(ctx, call)
+	}
+	command, ok := call.FunctionCall.Arguments["command"].(string)
+	if !ok {
+		return ""
+	}
+
+	var preview string
+	for _, kc := range tools.ParseKubectlCommands(command) {
+		preview += "\n  - " + tools.ExplainKubectlCommand(kc)
+	}
+	if preview != "" && call.ModifiesResourceStr != "no" {
+		dryRunCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		names, ok, err := tools.DryRunKubectlCommand(dryRunCtx, command, tools.InvokeToolOptions{
+			Kubeconfig: c.Kubeconfig,
+			WorkDir:    c.workDir,
+			Env:        c.env,
+		})
+		cancel()
+		if err != nil {
+			preview += "\n  Dry-run: " + err.Error()
+		} else if ok {
+			preview += fmt.Sprintf("\n  Dry-run: affects %d resource(s)", len(names))
+			const maxNames = 10
+			if len(names) > maxNames {
+				names = append(names[:maxNames], fmt.Sprintf("... and %d more", len(names)-maxNames))
+			}
+			if len(names) > 0 {
+				preview += ": " + strings.Join(names, ", ")
+			}
+		}
+	}
+	return preview + c.manifestValidationSummary(ctx, command)
+}
+
+// applyManifestPreview describes the objects an apply_manifest call changes,
+// and the fields it conflicts on with other field managers, according to a
+// server-side dry-run.
+func (c *Agent) applyManifestPreview(ctx context.Context, call ToolCallAnalysis) string {
+	dryRunCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	result, err := tools.DryRunApplyManifest(dryRunCtx, call.FunctionCall.Arguments, tools.InvokeToolOptions{
+		Kubeconfig: c.Kubeconfig,
+		WorkDir:    c.workDir,
+		Env:        c.env,
+	})
+	if err != nil {
+		return "\n  Dry-run: " + err.Error()
+	}
+	var preview string
+	if len(result.Applied) > 0 {
+		preview += fmt.Sprintf("\n  Dry-run: applies %d object(s) as %q: %s", len(result.Applied), result.FieldManager, strings.Join(result.Applied, ", "))
+	}
+	if len(result.SkippedFields) > 0 {
+		preview += "\n  Skipped fields: " + strings.Join(result.SkippedFields, ", ")
+	}
+	if len(result.Conflicts) > 0 {
+		preview += "\n  Warning: conflicts with other field managers:"
+		for _, conflict := range result.Conflicts {
+			preview += "\n    - " + conflict.String()
+		}
+	} else if result.Error != "" {
+		preview += "\n  Dry-run: " + result.Error
+	}
+	if force, _ := call.FunctionCall.Arguments["force_conflicts"].(bool); force {
+		preview += "\n  Forces the conflicts: the fields owned by other field managers are overwritten"
+	}
+	if manifest, _ := call.FunctionCall.Arguments["manifest"].(string); manifest != "" {
+		preview += validationIssuesSummary(tools.ValidateManifests(ctx, []byte(manifest)))
+	}
+	return preview
+}
+
+// manifestValidationSummary validates the manifests the command would apply,
+// and describes the problems found so that they are visible when approving it.
+func (c *Agent) manifestValidationSummary(ctx context.Context, command string) string {
+	return validationIssuesSummary(tools.ValidateCommandManifests(ctx, command, c.workDir))
+}
+
+// validationIssuesSummary describes the problems found by a manifest validation.
+func validationIssuesSummary(result *tools.ManifestValidationResult) string {
+	if result == nil || result.Valid {
+		return ""
+	}
+	summary := fmt.Sprintf("\n  Warning: manifest validation found %d issue(s):", len(result.Issues))
+	for _, issue := range result.Issues {
+		summary += "\n    - " + issue.String()
+	}
+	return summary
 }
 
 func (c *Agent) analyzeToolCalls(ctx context.Context, toolCalls []gollm.FunctionCall) ([]ToolCallAnalysis, error) {
@@ -940,49 +2066,117 @@ func (c *Agent) analyzeToolCalls(ctx context.Context, toolCalls []gollm.Function
 		}
 		toolCallAnalysis[i].ModifiesResourceStr = toolCall.GetTool().CheckModifiesResource(call.Arguments)
 		toolCallAnalysis[i].ParsedToolCall = toolCall
+		toolCallAnalysis[i].Policy = c.evaluatePolicy(toolCallAnalysis[i])
 	}
 	return toolCallAnalysis, nil
 }
 
 func (c *Agent) handleChoice(ctx context.Context, choice *api.UserChoiceResponse) (dispatchToolCalls bool) {
 	log := klog.FromContext(ctx)
-	// if user input is a choice and use has declined the operation,
-	// we need to abort all pending function calls.
-	// update the currChatContent with the choice and keep the agent loop running.
+	// The choice applies to the calls of CallIDs, or to all the calls awaiting
+	// a decision. The calls are dispatched once all were decided, the LLM is
+	// told about the declined ones.
 
-	// Normalize the input
-	switch choice.Choice {
-	case 1:
-		dispatchToolCalls = true
-	case 2:
-		c.SkipPermissions = true
-		dispatchToolCalls = true
-	case 3:
-		c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
-			ID:   c.pendingFunctionCalls[0].FunctionCall.ID,
-			Name: c.pendingFunctionCalls[0].FunctionCall.Name,
-			Result: map[string]any{
-				"error":     "User declined to run this operation.",
-				"status":    "declined",
-				"retryable": false,
-			},
-		})
-		c.pendingFunctionCalls = []ToolCallAnalysis{}
-		dispatchToolCalls = false
-		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Operation was skipped. User declined to run this operation.")
-	default:
+	approver := choice.Approver
+	if approver == "" {
+		approver = localApprover()
+	}
+
+	if choice.Choice < 1 || choice.Choice > 3 {
 		// This case should technically not be reachable due to AskForConfirmation loop
 		err := fmt.Errorf("invalid confirmation choice: %q", choice.Choice)
 		log.Error(err, "Invalid choice received from AskForConfirmation")
 		c.pendingFunctionCalls = []ToolCallAnalysis{}
-		dispatchToolCalls = false
+		c.clearDecisions()
 		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Invalid choice received. Cancelling operation.")
+		return false
 	}
-	return dispatchToolCalls
+	indexes, err := c.choiceCalls(choice)
+	if err == nil && choice.Command != "" {
+		if len(indexes) != 1 || choice.Choice != 1 {
+			err = errors.New("only a single approved tool call can be edited")
+		} else {
+			err = c.editPendingCall(ctx, indexes[0], choice.Command, choice.Reason)
+		}
+	}
+	if err != nil {
+		log.Info("ignoring choice", "choice", choice, "err", err)
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("Choice ignored: %v.", err))
+		return false
+	}
+
+	if choice.Choice == 2 {
+		indexes = c.undecidedCalls()
+	}
+	if choice.Choice != 3 {
+		if err := c.checkBreakGlass(indexes, choice.Reason); err != nil {
+			log.Info("ignoring choice", "choice", choice, "err", err)
+			c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("Choice ignored: %v.", err))
+			return false
+		}
+	}
+
+	// Normalize the input
+	var undecided bool
+	switch choice.Choice {
+	case 1:
+		c.approval = confirmedApproval(approver)
+		undecided = c.approveCalls(ctx, indexes, c.approval, choice.Reason)
+	case 2:
+		c.approval = confirmedApproval(approver)
+		if c.StrictApprovals {
+			// Only the calls of this turn are approved.
+			c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "Approved. Every change requires an approval in this mode, the next ones will be asked for too.")
+		} else {
+			c.dontAskAgainApprover = approver
+			c.SkipPermissions = true
+		}
+		undecided = c.approveCalls(ctx, indexes, c.approval, choice.Reason)
+	case 3:
+		undecided = c.decide(indexes, nil)
+	}
+	if undecided {
+		return false
+	}
+	return c.settleDecisions()
 }
 
 // generateFromTemplate generates a prompt for LLM. It uses the prompt from the provides template file or default.
+// The prompt is reused from the PromptCache, if any, for the same template and tools.
 func (a *Agent) generatePrompt(_ context.Context, defaultPromptTemplate string, data PromptData) (string, error) {
+	promptTemplate, err := a.promptTemplate(defaultPromptTemplate)
+	if err != nil {
+		return "", err
+	}
+	var cacheName string
+	if a.PromptCache != nil {
+		cacheName = systemPromptCacheName(promptTemplate, &data)
+		var cached cachedPrompt
+		if a.PromptCache.load(cacheName, &cached) {
+			return cached.SystemPrompt, nil
+		}
+	}
+
+	tmpl, err := template.New("promptTemplate").Parse(promptTemplate)
+	if err != nil {
+		return "", fmt.Errorf("building template for prompt: %w", err)
+	}
+
+	var result strings.Builder
+	err = tmpl.Execute(&result, &data)
+	if err != nil {
+		return "", fmt.Errorf("evaluating template for prompt: %w", err)
+	}
+	// The prompts describing the cluster are specific to it.
+	if a.PromptCache != nil && data.cluster == nil {
+		a.PromptCache.store(cacheName, cachedPrompt{SystemPrompt: result.String()})
+	}
+	return result.String(), nil
+}
+
+// promptTemplate returns the template of the prompt, from the template file
+// or the default, followed by the extra prompts.
+func (a *Agent) promptTemplate(defaultPromptTemplate string) (string, error) {
 	promptTemplate := defaultPromptTemplate
 	if a.PromptTemplateFile != "" {
 		content, err := os.ReadFile(a.PromptTemplateFile)
@@ -993,24 +2187,22 @@ func (a *Agent) generatePrompt(_ context.Context, defaultPromptTemplate string,
 	}
 
 	for _, extraPromptPath := range a.ExtraPromptPaths {
-		content, err := os.ReadFile(extraPromptPath)
+		content, err := readPromptFile(extraPromptPath)
 		if err != nil {
 			return "", fmt.Errorf("error reading extra prompt path: %v", err)
 		}
 		promptTemplate += "\n" + string(content)
 	}
+	return promptTemplate, nil
+}
 
-	tmpl, err := template.New("promptTemplate").Parse(promptTemplate)
-	if err != nil {
-		return "", fmt.Errorf("building template for prompt: %w", err)
-	}
-
-	var result strings.Builder
-	err = tmpl.Execute(&result, &data)
-	if err != nil {
-		return "", fmt.Errorf("evaluating template for prompt: %w", err)
-	}
-	return result.String(), nil
+// versionSkewPrompt tells the model about an unsupported version skew
+// between kubectl and the API server, so that it avoids the flags and the
+// resources that kubectl doesn't support.
+func versionSkewPrompt(skew *tools.VersionSkew) string {
+	return fmt.Sprintf("\n\nNote: the kubectl binary (%s) is outside of the supported version skew of the cluster (%s). "+
+		"Only use kubectl flags and subcommands available in kubectl %s, and if a command fails with an unknown flag or resource, "+
+		"retry without it or with an equivalent that this kubectl supports, e.g. `kubectl get --raw`.\n", skew.Client, skew.Server, skew.Client)
 }
 
 // PromptData represents the structure of the data to be filled into the template.
@@ -1019,14 +2211,36 @@ type PromptData struct {
 	Tools tools.Tools
 
 	EnableToolUseShim bool
+
+	// discoverCluster queries the cluster for the Cluster template variable.
+	discoverCluster func() *tools.ClusterInfo
+	cluster         *tools.ClusterInfo
+}
+
+// Cluster describes the cluster, e.g. {{.Cluster.Version}} or {{.Cluster.Provider}}.
+// The cluster is only queried if the template uses it, so that templates that
+// don't need it don't slow down startup.
+func (a *PromptData) Cluster() *tools.ClusterInfo {
+	if a.cluster == nil {
+		if a.discoverCluster != nil {
+			a.cluster = a.discoverCluster()
+		} else {
+			a.cluster = &tools.ClusterInfo{NodeCount: -1}
+		}
+	}
+	return a.cluster
 }
 
 func (a *PromptData) ToolsAsJSON() string {
 	var toolDefinitions []*gollm.FunctionDefinition
 
 	for _, tool := range a.Tools.AllTools() {
-		toolDefinitions = append(toolDefinitions, tool.FunctionDefinition())
+		toolDefinitions = append(toolDefinitions, tools.FunctionDefinitionOf(tool))
 	}
+	// Sorted for the prompt to be the same across invocations.
+	sort.Slice(toolDefinitions, func(i, j int) bool {
+		return toolDefinitions[i].Name < toolDefinitions[j].Name
+	})
 
 	json, err := json.MarshalIndent(toolDefinitions, "", "  ")
 	if err != nil {
@@ -17,13 +17,17 @@ package agent
 import (
 	"context"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	"go.uber.org/mock/gomock"
 )
 
@@ -48,7 +52,7 @@ func TestHandleMetaQuery(t *testing.T) {
 				store := sessions.NewInMemoryChatStore()
 
 				chat := mocks.NewMockChat(ctrl)
-				chat.EXPECT().Initialize([]*api.Message{}).Times(1)
+				chat.EXPECT().Initialize([]*gollm.Message{}).Times(1)
 
 				mt := mocks.NewMockTool(ctrl)
 				mt.EXPECT().Name().Return("mock namespace tool").AnyTimes()
@@ -234,6 +238,179 @@ func TestHandleMetaQuery(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:   "env set",
+			query:  "env set AWS_PROFILE=dev HELM_NAMESPACE=apps",
+			expect: "  - AWS_PROFILE\n  - HELM_NAMESPACE\n  - KEEP\n",
+			expectations: func(t *testing.T) *Agent {
+				oldHome := os.Getenv("HOME")
+				t.Cleanup(func() { os.Setenv("HOME", oldHome) })
+				os.Setenv("HOME", t.TempDir())
+
+				manager, err := sessions.NewSessionManager()
+				if err != nil {
+					t.Fatalf("creating session manager: %v", err)
+				}
+				sess, err := manager.NewSession(sessions.Metadata{ProviderID: "p", ModelID: "m"})
+				if err != nil {
+					t.Fatalf("creating session: %v", err)
+				}
+				a := &Agent{ChatMessageStore: sess, env: map[string]string{"KEEP": "1", "AWS_PROFILE": "prod"}}
+				a.session = &api.Session{ChatMessageStore: sess}
+				return a
+			},
+			verify: func(t *testing.T, a *Agent, _ string) {
+				metadata, err := a.ChatMessageStore.(*sessions.Session).LoadMetadata()
+				if err != nil {
+					t.Fatalf("loading metadata: %v", err)
+				}
+				if metadata.Env["AWS_PROFILE"] != "dev" || metadata.Env["HELM_NAMESPACE"] != "apps" {
+					t.Fatalf("expected env to be saved in the session, got %v", metadata.Env)
+				}
+			},
+		},
+		{
+			name:   "env unset",
+			query:  "env unset AWS_PROFILE",
+			expect: "No environment variables are set for tools.",
+			expectations: func(t *testing.T) *Agent {
+				a := &Agent{env: map[string]string{"AWS_PROFILE": "dev"}}
+				a.session = &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()}
+				return a
+			},
+		},
+		{
+			name:   "note add",
+			query:  "/note add change ticket CHG-1234",
+			expect: "  1. suspected cause: bad config\n  2. change ticket CHG-1234\n",
+			expectations: func(t *testing.T) *Agent {
+				oldHome := os.Getenv("HOME")
+				t.Cleanup(func() { os.Setenv("HOME", oldHome) })
+				os.Setenv("HOME", t.TempDir())
+
+				manager, err := sessions.NewSessionManager()
+				if err != nil {
+					t.Fatalf("creating session manager: %v", err)
+				}
+				sess, err := manager.NewSession(sessions.Metadata{ProviderID: "p", ModelID: "m"})
+				if err != nil {
+					t.Fatalf("creating session: %v", err)
+				}
+				a := &Agent{ChatMessageStore: sess, notes: []string{"suspected cause: bad config"}}
+				a.session = &api.Session{ChatMessageStore: sess}
+				return a
+			},
+			verify: func(t *testing.T, a *Agent, _ string) {
+				metadata, err := a.ChatMessageStore.(*sessions.Session).LoadMetadata()
+				if err != nil {
+					t.Fatalf("loading metadata: %v", err)
+				}
+				if len(metadata.Notes) != 2 || metadata.Notes[1] != "change ticket CHG-1234" {
+					t.Fatalf("expected notes to be saved in the session, got %v", metadata.Notes)
+				}
+			},
+		},
+		{
+			name:   "note remove",
+			query:  "note remove 1",
+			expect: "  1. change ticket CHG-1234\n",
+			expectations: func(t *testing.T) *Agent {
+				a := &Agent{notes: []string{"suspected cause: bad config", "change ticket CHG-1234"}}
+				a.session = &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()}
+				return a
+			},
+		},
+		{
+			name:   "note remove invalid",
+			query:  "note remove 3",
+			expect: "there is no note #3",
+			expectations: func(t *testing.T) *Agent {
+				a := &Agent{notes: []string{"change ticket CHG-1234"}}
+				a.session = &api.Session{}
+				return a
+			},
+		},
+		{
+			name:   "notes empty",
+			query:  "notes",
+			expect: "No notes are pinned to the session.",
+			expectations: func(t *testing.T) *Agent {
+				a := &Agent{}
+				a.session = &api.Session{}
+				return a
+			},
+		},
+		{
+			name:   "tag add",
+			query:  "/tag add incident-1234",
+			expect: "Session tags: payments, incident-1234",
+			expectations: func(t *testing.T) *Agent {
+				oldHome := os.Getenv("HOME")
+				t.Cleanup(func() { os.Setenv("HOME", oldHome) })
+				os.Setenv("HOME", t.TempDir())
+
+				manager, err := sessions.NewSessionManager()
+				if err != nil {
+					t.Fatalf("creating session manager: %v", err)
+				}
+				sess, err := manager.NewSession(sessions.Metadata{ProviderID: "p", ModelID: "m"})
+				if err != nil {
+					t.Fatalf("creating session: %v", err)
+				}
+				a := &Agent{ChatMessageStore: sess, tags: []string{"payments"}}
+				a.session = &api.Session{ChatMessageStore: sess}
+				return a
+			},
+			verify: func(t *testing.T, a *Agent, _ string) {
+				metadata, err := a.ChatMessageStore.(*sessions.Session).LoadMetadata()
+				if err != nil {
+					t.Fatalf("loading metadata: %v", err)
+				}
+				if !metadata.HasTags([]string{"payments", "incident-1234"}) {
+					t.Fatalf("expected tags to be saved in the session, got %v", metadata.Tags)
+				}
+			},
+		},
+		{
+			name:   "tag add invalid",
+			query:  "tag add incident 1234",
+			expect: "invalid tag \"incident 1234\"",
+			expectations: func(t *testing.T) *Agent {
+				a := &Agent{}
+				a.session = &api.Session{}
+				return a
+			},
+		},
+		{
+			name:   "tag remove",
+			query:  "tag remove payments",
+			expect: "Session tags: incident-1234",
+			expectations: func(t *testing.T) *Agent {
+				a := &Agent{tags: []string{"payments", "incident-1234"}}
+				a.session = &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()}
+				return a
+			},
+		},
+		{
+			name:   "tag remove missing",
+			query:  "tag remove payments",
+			expect: "the session isn't tagged \"payments\"",
+			expectations: func(t *testing.T) *Agent {
+				a := &Agent{}
+				a.session = &api.Session{}
+				return a
+			},
+		},
+		{
+			name:   "env invalid",
+			query:  "env set 1FOO=bar",
+			expect: "Invalid environment variable \"1FOO\"",
+			expectations: func(t *testing.T) *Agent {
+				a := &Agent{}
+				a.session = &api.Session{}
+				return a
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -255,3 +432,465 @@ func TestHandleMetaQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestWithNotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		inject   bool
+		notes    []string
+		expected string
+	}{
+		{
+			name:     "injected",
+			inject:   true,
+			notes:    []string{"change ticket CHG-1234", "suspected cause: bad config"},
+			expected: "Notes pinned by the user to this session:\n- change ticket CHG-1234\n- suspected cause: bad config\n\nwhy is web crashing?",
+		},
+		{
+			name:     "not injected",
+			notes:    []string{"change ticket CHG-1234"},
+			expected: "why is web crashing?",
+		},
+		{
+			name:     "no notes",
+			inject:   true,
+			expected: "why is web crashing?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Agent{InjectNotes: tt.inject, notes: tt.notes}
+			if got := a.withNotes("why is web crashing?"); got != tt.expected {
+				t.Errorf("withNotes() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// eventRecorder is a journal recorder keeping the events in memory.
+type eventRecorder struct {
+	events []*journal.Event
+}
+
+func (r *eventRecorder) Write(ctx context.Context, event *journal.Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *eventRecorder) Close() error { return nil }
+
+func TestAddUserMessage(t *testing.T) {
+	recorder := &eventRecorder{}
+	ctx := journal.ContextWithRecorder(context.Background(), recorder)
+	a := &Agent{Output: make(chan any, 2)}
+	a.session = &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()}
+
+	a.addUserMessage(ctx, "scale web to 3", "alice@example.com")
+
+	messages := a.session.ChatMessageStore.ChatMessages()
+	if len(messages) != 1 || messages[0].Author != "alice@example.com" || messages[0].Source != api.MessageSourceUser {
+		t.Fatalf("session messages = %+v, want the query of alice", messages)
+	}
+	if len(recorder.events) != 1 || recorder.events[0].Action != journal.ActionUserQuery {
+		t.Fatalf("journal events = %+v, want the query", recorder.events)
+	}
+	if author, _ := recorder.events[0].GetString("author"); author != "alice@example.com" {
+		t.Errorf("journal event author = %q, want alice@example.com", author)
+	}
+	if query, _ := recorder.events[0].GetString("query"); query != "scale web to 3" {
+		t.Errorf("journal event query = %q, want the query", query)
+	}
+
+	// The query isn't journaled when the prompt log excludes its content.
+	a.PromptLog = gollm.PromptLogOptions{Mode: gollm.PromptLogMetadata}
+	a.addUserMessage(a.promptLogContext(ctx), "show the secrets", "alice@example.com")
+	if _, ok := recorder.events[1].GetString("query"); ok {
+		t.Errorf("journal event = %+v, want the query to be redacted", recorder.events[1])
+	}
+
+	if got, want := withAuthor("scale web to 3", "alice@example.com"), "Message from operator alice@example.com:\nscale web to 3"; got != want {
+		t.Errorf("withAuthor() = %q, want %q", got, want)
+	}
+	if got := withAuthor("scale web to 3", ""); got != "scale web to 3" {
+		t.Errorf("withAuthor() without author = %q, want the query", got)
+	}
+}
+
+func TestDescribeUserInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    *api.UserInputResponse
+		expected string
+	}{
+		{
+			name:     "text only",
+			query:    &api.UserInputResponse{Query: "list pods"},
+			expected: "list pods",
+		},
+		{
+			name: "with images",
+			query: &api.UserInputResponse{
+				Query: "what's wrong in this dashboard?",
+				Images: []api.Image{
+					{Name: "grafana.png", MIMEType: "image/png", Data: make([]byte, 2048)},
+					{MIMEType: "image/jpeg", Data: []byte("x")},
+				},
+			},
+			expected: "what's wrong in this dashboard?\n[attached grafana.png (image/png, 2 KiB)]\n[attached image 2 (image/jpeg, 1 KiB)]",
+		},
+		{
+			name:     "image without text",
+			query:    &api.UserInputResponse{Images: []api.Image{{Name: "a.png", MIMEType: "image/png"}}},
+			expected: "[attached a.png (image/png, 0 KiB)]",
+		},
+		{
+			name: "with attachments",
+			query: &api.UserInputResponse{
+				Query:       "why does this deployment not roll out?",
+				Attachments: []api.Attachment{{Label: "deployment.yaml", Content: "apiVersion: apps/v1\nkind: Deployment\n"}},
+			},
+			expected: "why does this deployment not roll out?\n[attached deployment.yaml (2 lines, 1 KiB)]",
+		},
+		{
+			name:     "env set",
+			query:    &api.UserInputResponse{Query: "env set AWS_PROFILE=prod GITHUB_TOKEN=ghp_s3cr3t"},
+			expected: "env set AWS_PROFILE=*** GITHUB_TOKEN=***",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := describeUserInput(tc.query); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+// fakeResponse is a streamed LLM response with a single text candidate.
+type fakeResponse struct {
+	text         string
+	finishReason gollm.FinishReason
+	usage        *gollm.Usage
+	citations    []gollm.Citation
+	calls        []gollm.FunctionCall
+}
+
+func (r *fakeResponse) UsageMetadata() any               { return nil }
+func (r *fakeResponse) TokenUsage() *gollm.Usage         { return r.usage }
+func (r *fakeResponse) Candidates() []gollm.Candidate    { return []gollm.Candidate{r} }
+func (r *fakeResponse) String() string                   { return r.text }
+func (r *fakeResponse) FinishReason() gollm.FinishReason { return r.finishReason }
+func (r *fakeResponse) AsText() (string, bool)           { return r.text, r.text != "" }
+func (r *fakeResponse) AsFunctionCalls() ([]gollm.FunctionCall, bool) {
+	return r.calls, len(r.calls) > 0
+}
+func (r *fakeResponse) Parts() []gollm.Part         { return []gollm.Part{r} }
+func (r *fakeResponse) Citations() []gollm.Citation { return r.citations }
+
+func streamOf(responses ...*fakeResponse) gollm.ChatResponseIterator {
+	return func(yield func(gollm.ChatResponse, error) bool) {
+		for _, response := range responses {
+			if !yield(response, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestContinueTruncatedResponse(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxContinuations int
+		streams          []gollm.ChatResponseIterator
+		expected         string
+		// expectedCitations are the URIs cited by the message.
+		expectedCitations []string
+	}{
+		{
+			name:             "continued",
+			maxContinuations: 3,
+			streams: []gollm.ChatResponseIterator{
+				streamOf(&fakeResponse{text: "Step 1. "}, &fakeResponse{text: "Step 2", finishReason: gollm.FinishReasonMaxTokens, citations: []gollm.Citation{{URI: "https://a"}}}),
+				streamOf(&fakeResponse{text: ". Step 3.", finishReason: gollm.FinishReasonStop, citations: []gollm.Citation{{URI: "https://a"}, {URI: "https://b"}}}),
+			},
+			expected:          "Step 1. Step 2. Step 3.",
+			expectedCitations: []string{"https://a", "https://b"},
+		},
+		{
+			name:             "bounded",
+			maxContinuations: 1,
+			streams: []gollm.ChatResponseIterator{
+				streamOf(&fakeResponse{text: "a", finishReason: gollm.FinishReasonMaxTokens}),
+				streamOf(&fakeResponse{text: "b", finishReason: gollm.FinishReasonMaxTokens}),
+			},
+			expected: "ab",
+		},
+		{
+			name:             "disabled",
+			maxContinuations: 0,
+			streams: []gollm.ChatResponseIterator{
+				streamOf(&fakeResponse{text: "a", finishReason: gollm.FinishReasonMaxTokens}),
+			},
+			expected: "a",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			chat := mocks.NewMockChat(ctrl)
+			var calls []any
+			for i, stream := range tc.streams {
+				contents := []any{gomock.Any()}
+				if i == 0 {
+					contents = []any{"query"}
+				}
+				calls = append(calls, chat.EXPECT().SendStreaming(gomock.Any(), contents...).Return(stream, nil))
+			}
+			gomock.InOrder(calls...)
+
+			store := sessions.NewInMemoryChatStore()
+			a := &Agent{
+				llmChat:          chat,
+				RunOnce:          true,
+				MaxIterations:    10,
+				MaxContinuations: tc.maxContinuations,
+				Input:            make(chan any, 10),
+				Output:           make(chan any, 10),
+				session:          &api.Session{ChatMessageStore: store},
+			}
+			if err := a.Run(context.Background(), "query"); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			for a.AgentState() != api.AgentStateExited {
+				select {
+				case <-a.Output:
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+
+			var got []string
+			for _, message := range store.ChatMessages() {
+				if message.Source == api.MessageSourceModel {
+					got = append(got, message.Payload.(string))
+					if message.Stats == nil || message.Stats.Iteration != 1 {
+						t.Errorf("expected the stats of the first iteration, got %+v", message.Stats)
+					}
+					var citations []string
+					for _, citation := range message.Citations {
+						citations = append(citations, citation.URI)
+					}
+					if !reflect.DeepEqual(citations, tc.expectedCitations) {
+						t.Errorf("expected citations %q, got %q", tc.expectedCitations, citations)
+					}
+				}
+			}
+			if len(got) != 1 || got[0] != tc.expected {
+				t.Errorf("expected a single model message %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestProgressMessages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	chat := mocks.NewMockChat(ctrl)
+	chat.EXPECT().SendStreaming(gomock.Any(), "query").Return(streamOf(&fakeResponse{text: "done", finishReason: gollm.FinishReasonStop}), nil)
+
+	store := sessions.NewInMemoryChatStore()
+	a := &Agent{
+		llmChat:       chat,
+		RunOnce:       true,
+		MaxIterations: 10,
+		Input:         make(chan any, 10),
+		Output:        make(chan any, 10),
+		session:       &api.Session{ChatMessageStore: store},
+	}
+	if err := a.Run(context.Background(), "query"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var types []api.MessageType
+	for a.AgentState() != api.AgentStateExited || len(a.Output) > 0 {
+		select {
+		case m := <-a.Output:
+			message := m.(*api.Message)
+			if message.Type == api.MessageTypeStreamStats {
+				continue
+			}
+			types = append(types, message.Type)
+			if progress, ok := message.Payload.(*api.Progress); ok && progress.Phase != api.ProgressPhaseThinking {
+				t.Errorf("progress phase = %q, want %q", progress.Phase, api.ProgressPhaseThinking)
+			}
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	want := []api.MessageType{api.MessageTypeText, api.MessageTypeProgress, api.MessageTypeText}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("messages = %q, want %q", types, want)
+	}
+	for _, message := range store.ChatMessages() {
+		if message.Type == api.MessageTypeProgress {
+			t.Errorf("progress message was added to the session")
+		}
+	}
+}
+
+func TestToolCallApproval(t *testing.T) {
+	modifying := ToolCallAnalysis{ModifiesResourceStr: "yes"}
+	readOnly := ToolCallAnalysis{ModifiesResourceStr: "no"}
+
+	tests := []struct {
+		name            string
+		skipPermissions bool
+		choices         []*api.UserChoiceResponse
+		call            ToolCallAnalysis
+		// expected is the expected approval after each choice, "" meaning none.
+		expected []string
+	}{
+		{
+			name:     "confirmed",
+			choices:  []*api.UserChoiceResponse{{Choice: 1, Approver: "alice"}, nil},
+			call:     modifying,
+			expected: []string{"alice/confirmed", ""},
+		},
+		{
+			name:     "don't ask again",
+			choices:  []*api.UserChoiceResponse{{Choice: 2, Approver: "bob"}, nil},
+			call:     modifying,
+			expected: []string{"bob/confirmed", "bob/dont-ask-again"},
+		},
+		{
+			name:            "skip permissions",
+			skipPermissions: true,
+			choices:         []*api.UserChoiceResponse{nil},
+			call:            modifying,
+			expected:        []string{"/skip-permissions"},
+		},
+		{
+			name:     "read-only",
+			choices:  []*api.UserChoiceResponse{{Choice: 1, Approver: "alice"}},
+			call:     readOnly,
+			expected: []string{""},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &Agent{
+				SkipPermissions: tc.skipPermissions,
+				Output:          make(chan any, 10),
+				session:         &api.Session{},
+			}
+			for i, choice := range tc.choices {
+				if choice != nil {
+					a.pendingFunctionCalls = []ToolCallAnalysis{tc.call}
+					a.handleChoice(context.Background(), choice)
+				}
+				var got string
+				if approval := a.toolCallApproval(tc.call); approval != nil {
+					approver := approval.Approver
+					if approval.Method == api.ApprovalMethodSkipPermissions {
+						// The OS user depends on the environment.
+						approver = ""
+					}
+					got = approver + "/" + string(approval.Method)
+				}
+				if got != tc.expected[i] {
+					t.Errorf("after choice %d: expected approval %q, got %q", i, tc.expected[i], got)
+				}
+				// Approvals only cover the calls pending when they were given.
+				a.approval = nil
+			}
+		})
+	}
+}
+
+func TestGeneratePromptCluster(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		want        string
+		wantQueries int
+	}{
+		{
+			name:     "cluster not used",
+			template: "Tools: {{.ToolNames}}",
+			want:     "Tools: ",
+		},
+		{
+			name:        "cluster variables",
+			template:    "{{.Cluster.Provider}} {{.Cluster.Version}}, {{.Cluster.NodeCount}} nodes, namespace {{.Cluster.Namespace}}",
+			want:        "GKE v1.30.2-gke.1587003, 3 nodes, namespace web",
+			wantQueries: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := 0
+			data := PromptData{
+				discoverCluster: func() *tools.ClusterInfo {
+					queries++
+					return &tools.ClusterInfo{Provider: "GKE", Version: "v1.30.2-gke.1587003", NodeCount: 3, Namespace: "web"}
+				},
+			}
+			got, err := (&Agent{}).generatePrompt(context.Background(), tt.template, data)
+			if err != nil {
+				t.Fatalf("generatePrompt() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("generatePrompt() = %q, want %q", got, tt.want)
+			}
+			if queries != tt.wantQueries {
+				t.Errorf("cluster queried %d times, want %d", queries, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestRouteQuery(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	store := sessions.NewInMemoryChatStore()
+	_ = store.AddChatMessage(&api.Message{ID: "u1", Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "get pods"})
+
+	llm := mocks.NewMockClient(ctrl)
+	newChat := func(model string) {
+		chat := mocks.NewMockChat(ctrl)
+		// The new chat is given the messages of the session, and the tools.
+		chat.EXPECT().Initialize([]*gollm.Message{{Role: gollm.RoleUser, Content: "get pods"}}).Return(nil)
+		chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil)
+		llm.EXPECT().StartChat("system prompt", model).Return(chat)
+	}
+
+	a := &Agent{
+		LLM:          llm,
+		Model:        "pro",
+		Router:       &gollm.ModelRouter{FastModel: "flash", StrongModel: "pro"},
+		systemPrompt: "system prompt",
+		chatModel:    "pro",
+		session:      &api.Session{ChatMessageStore: store},
+	}
+	steps := []struct {
+		query     string
+		wantModel string
+	}{
+		{query: "get pods", wantModel: "flash"},
+		{query: "list services", wantModel: "flash"},
+		{query: "why is the web pod crashing?", wantModel: "pro"},
+	}
+	for _, step := range steps {
+		if step.wantModel != a.chatModel {
+			newChat(step.wantModel)
+		}
+		if err := a.routeQuery(ctx, step.query); err != nil {
+			t.Fatalf("routeQuery(%q) error = %v", step.query, err)
+		}
+		if a.chatModel != step.wantModel {
+			t.Errorf("routeQuery(%q) chat model = %q, want %q", step.query, a.chatModel, step.wantModel)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"k8s.io/klog/v2"
+)
+
+// modelPrice is the price of the tokens of the models whose name starts with prefix.
+type modelPrice struct {
+	prefix string
+	prices TokenPrices
+}
+
+// tokenPriceTable are the list prices of the models of the providers, in USD
+// per million tokens (with the lowest tier of the models priced by prompt
+// size). They can be overridden with --input-token-price and --output-token-price.
+var tokenPriceTable = map[string][]modelPrice{
+	"gemini": {
+		{"gemini-2.5-pro", TokenPrices{Input: 1.25, Output: 10}},
+		{"gemini-2.5-flash", TokenPrices{Input: 0.30, Output: 2.50}},
+		{"gemini-2.5-flash-lite", TokenPrices{Input: 0.10, Output: 0.40}},
+		{"gemini-2.0-flash", TokenPrices{Input: 0.10, Output: 0.40}},
+		{"gemini-2.0-flash-lite", TokenPrices{Input: 0.075, Output: 0.30}},
+		{"gemini-1.5-pro", TokenPrices{Input: 1.25, Output: 5}},
+		{"gemini-1.5-flash", TokenPrices{Input: 0.075, Output: 0.30}},
+	},
+	"openai": {
+		{"gpt-5", TokenPrices{Input: 1.25, Output: 10}},
+		{"gpt-5-mini", TokenPrices{Input: 0.25, Output: 2}},
+		{"gpt-5-nano", TokenPrices{Input: 0.05, Output: 0.40}},
+		{"gpt-4.1", TokenPrices{Input: 2, Output: 8}},
+		{"gpt-4.1-mini", TokenPrices{Input: 0.40, Output: 1.60}},
+		{"gpt-4.1-nano", TokenPrices{Input: 0.10, Output: 0.40}},
+		{"gpt-4o", TokenPrices{Input: 2.50, Output: 10}},
+		{"gpt-4o-mini", TokenPrices{Input: 0.15, Output: 0.60}},
+		{"o3", TokenPrices{Input: 2, Output: 8}},
+		{"o3-mini", TokenPrices{Input: 1.10, Output: 4.40}},
+		{"o4-mini", TokenPrices{Input: 1.10, Output: 4.40}},
+	},
+	"grok": {
+		{"grok-4", TokenPrices{Input: 3, Output: 15}},
+		{"grok-3", TokenPrices{Input: 3, Output: 15}},
+		{"grok-3-mini", TokenPrices{Input: 0.30, Output: 0.50}},
+	},
+	"bedrock": {
+		{"anthropic.claude-opus-4", TokenPrices{Input: 15, Output: 75}},
+		{"anthropic.claude-sonnet-4", TokenPrices{Input: 3, Output: 15}},
+		{"anthropic.claude-3-7-sonnet", TokenPrices{Input: 3, Output: 15}},
+		{"anthropic.claude-3-5-haiku", TokenPrices{Input: 0.80, Output: 4}},
+		{"amazon.nova-pro", TokenPrices{Input: 0.80, Output: 3.20}},
+		{"amazon.nova-lite", TokenPrices{Input: 0.06, Output: 0.24}},
+		{"amazon.nova-micro", TokenPrices{Input: 0.035, Output: 0.14}},
+	},
+	"cohere": {
+		{"command-a", TokenPrices{Input: 2.50, Output: 10}},
+		{"command-r-plus", TokenPrices{Input: 2.50, Output: 10}},
+		{"command-r", TokenPrices{Input: 0.15, Output: 0.60}},
+	},
+}
+
+// providerPriceTables are the providers using the price table of another
+// provider, or none for the local providers, whose models are free.
+var providerPriceTables = map[string]string{
+	"vertexai": "gemini",
+	"azopenai": "openai",
+	"ollama":   "",
+	"llamacpp": "",
+}
+
+// LookupTokenPrices returns the list prices of the tokens of a model of a
+// provider. The models of the local providers (ollama, llamacpp) are free.
+// ok is false when the model is not in the price table.
+func LookupTokenPrices(provider, model string) (prices TokenPrices, ok bool) {
+	table, aliased := providerPriceTables[provider]
+	if !aliased {
+		table = provider
+	} else if table == "" {
+		return TokenPrices{}, true
+	}
+	// Model names can be qualified, e.g. models/gemini-2.5-pro, or
+	// us.anthropic.claude-sonnet-4-20250514-v1:0 on Bedrock.
+	model = strings.ToLower(model)
+	model = model[strings.LastIndex(model, "/")+1:]
+	var match string
+	for _, price := range tokenPriceTable[table] {
+		if len(price.prefix) <= len(match) {
+			continue
+		}
+		if strings.HasPrefix(model, price.prefix) || strings.Contains(model, "."+price.prefix) {
+			match, prices, ok = price.prefix, price.prices, true
+		}
+	}
+	return prices, ok
+}
+
+// tokenPrices returns the prices of the tokens of a model: TokenPrices if
+// they are set, or the list prices of the price table.
+func (c *Agent) tokenPrices(model string) (TokenPrices, bool) {
+	if c.TokenPrices != (TokenPrices{}) {
+		return c.TokenPrices, true
+	}
+	return LookupTokenPrices(c.Provider, model)
+}
+
+// tokenAccount aggregates the token usage and the estimated cost of the LLM
+// calls of the session, by provider and model. It is safe for concurrent use,
+// e.g. by the investigations of the "fanout" command.
+type tokenAccount struct {
+	mu     sync.Mutex
+	models []sessions.TokenUsage
+}
+
+// record adds the usage of an LLM call, priced with prices.
+func (a *tokenAccount) record(provider, model string, usage gollm.Usage, estimated bool, prices TokenPrices) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := slices.IndexFunc(a.models, func(u sessions.TokenUsage) bool {
+		return u.Provider == provider && u.Model == model
+	})
+	if i < 0 {
+		a.models = append(a.models, sessions.TokenUsage{Provider: provider, Model: model})
+		i = len(a.models) - 1
+	}
+	u := &a.models[i]
+	u.Calls++
+	u.InputTokens += usage.InputTokens
+	u.OutputTokens += usage.OutputTokens
+	u.Estimated = u.Estimated || estimated
+	u.Cost += (float64(usage.InputTokens)*prices.Input + float64(usage.OutputTokens)*prices.Output) / 1e6
+}
+
+// reset replaces the usage, e.g. with the usage recorded in a resumed session.
+func (a *tokenAccount) reset(models []sessions.TokenUsage) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.models = slices.Clone(models)
+}
+
+// snapshot returns the usage by model.
+func (a *tokenAccount) snapshot() []sessions.TokenUsage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.models)
+}
+
+// cost returns the estimated cost in USD of the session.
+func (a *tokenAccount) cost() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return sessions.TotalTokenCost(a.models)
+}
+
+// accountedChat records the token usage of the responses of a chat in the
+// token account of the agent.
+type accountedChat struct {
+	gollm.Chat
+	agent *Agent
+	model string
+}
+
+// startAccountedChat starts a chat with the model, whose token usage is
+// accounted for in the session.
+func (c *Agent) startAccountedChat(systemPrompt, model string) gollm.Chat {
+	return c.accountChat(c.LLM.StartChat(systemPrompt, model), model)
+}
+
+func (c *Agent) accountChat(chat gollm.Chat, model string) gollm.Chat {
+	return &accountedChat{Chat: chat, agent: c, model: model}
+}
+
+func (c *accountedChat) Send(ctx context.Context, contents ...any) (gollm.ChatResponse, error) {
+	response, err := c.Chat.Send(ctx, contents...)
+	if err == nil && response != nil {
+		var counter usageCounter
+		counter.observe(response)
+		c.record(counter)
+	}
+	return response, err
+}
+
+func (c *accountedChat) SendStreaming(ctx context.Context, contents ...any) (gollm.ChatResponseIterator, error) {
+	stream, err := c.Chat.SendStreaming(ctx, contents...)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(gollm.ChatResponse, error) bool) {
+		var counter usageCounter
+		// The usage is recorded even if the response is not read to the end.
+		defer func() { c.record(counter) }()
+		for response, err := range stream {
+			if err == nil && response != nil {
+				counter.observe(response)
+			}
+			if !yield(response, err) {
+				return
+			}
+		}
+	}, nil
+}
+
+func (c *accountedChat) record(counter usageCounter) {
+	if counter.responses == 0 {
+		return
+	}
+	usage, estimated := counter.usage()
+	prices, _ := c.agent.tokenPrices(c.model)
+	c.agent.tokens.record(c.agent.Provider, c.model, usage, estimated, prices)
+}
+
+// usageCounter counts the tokens of a response, from the usage reported by
+// the provider, or from the length of the text when it isn't.
+type usageCounter struct {
+	responses int
+	reported  *gollm.Usage
+	chars     int
+}
+
+func (u *usageCounter) observe(response gollm.ChatResponse) {
+	u.responses++
+	if usage := gollm.ResponseUsage(response); usage != nil {
+		u.reported = usage
+	}
+	for _, candidate := range response.Candidates() {
+		for _, part := range candidate.Parts() {
+			if text, ok := part.AsText(); ok {
+				u.chars += len(text)
+			}
+		}
+		// Only the first candidate is used.
+		break
+	}
+}
+
+// usage returns the tokens of the response, and whether they are estimated.
+func (u *usageCounter) usage() (gollm.Usage, bool) {
+	if u.reported != nil {
+		return *u.reported, false
+	}
+	return gollm.Usage{OutputTokens: u.chars / charsPerToken}, true
+}
+
+// recordTokenUsage records the token usage of the session into its metadata.
+func (c *Agent) recordTokenUsage() {
+	s, ok := c.ChatMessageStore.(*sessions.Session)
+	if !ok {
+		return
+	}
+	usage := c.tokens.snapshot()
+	if len(usage) == 0 {
+		return
+	}
+	if err := s.SetTokenUsage(usage); err != nil {
+		klog.Warningf("error recording session token usage: %v", err)
+	}
+}
+
+// maxCostReached returns true when the estimated cost of the session reached MaxCost.
+func (c *Agent) maxCostReached() bool {
+	return c.MaxCost > 0 && c.tokens.cost() >= c.MaxCost
+}
+
+// handleUsageQuery implements the "usage" and "cost" meta commands, which show
+// the tokens used by the session and their estimated cost, by provider and model.
+func (c *Agent) handleUsageQuery() string {
+	models := c.tokens.snapshot()
+	if len(models) == 0 {
+		return "No LLM calls were made yet."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("```text\n")
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tMODEL\tCALLS\tINPUT TOKENS\tOUTPUT TOKENS\tCOST")
+	var total sessions.TokenUsage
+	var estimated, unpriced bool
+	for _, u := range models {
+		output := fmt.Sprint(u.OutputTokens)
+		if u.Estimated {
+			output = "~" + output
+			estimated = true
+		}
+		cost := fmt.Sprintf("$%.4f", u.Cost)
+		if _, ok := c.tokenPrices(u.Model); !ok && u.Cost == 0 {
+			cost = "-"
+			unpriced = true
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\n", u.Provider, u.Model, u.Calls, u.InputTokens, output, cost)
+		total.Calls += u.Calls
+		total.InputTokens += u.InputTokens
+		total.OutputTokens += u.OutputTokens
+		total.Cost += u.Cost
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%d\t%d\t%d\t$%.4f\n", total.Calls, total.InputTokens, total.OutputTokens, total.Cost)
+	tw.Flush()
+	sb.WriteString("```\n")
+	if c.MaxCost > 0 {
+		fmt.Fprintf(&sb, "The session used $%.2f of its budget of $%.2f (--max-cost).\n", total.Cost, c.MaxCost)
+	}
+	if estimated {
+		sb.WriteString("~ marks the output tokens estimated from the length of the text, for the providers that don't report the usage.\n")
+	}
+	if unpriced {
+		sb.WriteString("The prices of the models without a cost are unknown, set them with --input-token-price and --output-token-price.\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLookupTokenPrices(t *testing.T) {
+	tests := []struct {
+		provider string
+		model    string
+		want     TokenPrices
+		wantOK   bool
+	}{
+		{"gemini", "gemini-2.5-pro", TokenPrices{Input: 1.25, Output: 10}, true},
+		{"vertexai", "models/gemini-2.5-flash-lite-preview-06-17", TokenPrices{Input: 0.10, Output: 0.40}, true},
+		{"azopenai", "gpt-4o-mini", TokenPrices{Input: 0.15, Output: 0.60}, true},
+		{"bedrock", "us.anthropic.claude-sonnet-4-20250514-v1:0", TokenPrices{Input: 3, Output: 15}, true},
+		{"ollama", "gemma3:12b-it-qat", TokenPrices{}, true},
+		{"openai", "my-fine-tuned-model", TokenPrices{}, false},
+		{"watsonx", "ibm/granite-3-8b-instruct", TokenPrices{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := LookupTokenPrices(tt.provider, tt.model)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("LookupTokenPrices(%q, %q) = %+v, %v, want %+v, %v", tt.provider, tt.model, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestAccountedChat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	chat := mocks.NewMockChat(ctrl)
+	chat.EXPECT().SendStreaming(gomock.Any(), gomock.Any()).Return(streamOf(
+		&fakeResponse{text: "The pod "},
+		&fakeResponse{text: "is crashing.", usage: &gollm.Usage{InputTokens: 100000, OutputTokens: 2000}},
+	), nil)
+	// The provider doesn't report the usage of this response.
+	chat.EXPECT().Send(gomock.Any(), gomock.Any()).Return(&fakeResponse{text: strings.Repeat("a", 400)}, nil)
+
+	a := &Agent{Provider: "gemini"}
+	accounted := a.accountChat(chat, "gemini-2.5-pro")
+	stream, err := accounted.SendStreaming(context.Background(), "why is the pod crashing?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range stream {
+	}
+	if _, err := accounted.Send(context.Background(), "summarize"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := a.tokens.snapshot()
+	want := sessions.TokenUsage{Provider: "gemini", Model: "gemini-2.5-pro", Calls: 2, InputTokens: 100000, OutputTokens: 2100, Estimated: true}
+	if len(got) != 1 || math.Abs(got[0].Cost-0.146) > 1e-9 {
+		t.Fatalf("unexpected usage %+v, want a cost of $0.146", got)
+	}
+	got[0].Cost = 0
+	if got[0] != want {
+		t.Errorf("unexpected usage %+v, want %+v", got[0], want)
+	}
+
+	a.MaxCost = 0.10
+	if !a.maxCostReached() {
+		t.Errorf("expected the maximum cost to be reached")
+	}
+	usage := a.handleUsageQuery()
+	for _, line := range []string{
+		"gemini    gemini-2.5-pro  2      100000        ~2100          $0.1460",
+		"The session used $0.15 of its budget of $0.10 (--max-cost).",
+	} {
+		if !strings.Contains(usage, line) {
+			t.Errorf("handleUsageQuery() = %q, want a line %q", usage, line)
+		}
+	}
+}
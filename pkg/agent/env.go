@@ -0,0 +1,116 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const envUsage = "Usage: env | env set NAME=VALUE [NAME=VALUE...] | env unset NAME [NAME...]"
+
+// handleEnvQuery implements the env meta commands, which show and change the
+// environment variables set for tool invocations in the current session.
+func (c *Agent) handleEnvQuery(query string) (answer string, handled bool, err error) {
+	fields := strings.Fields(query)
+	if len(fields) == 1 {
+		return c.describeEnv(), true, nil
+	}
+	if len(fields) < 3 {
+		return envUsage, true, nil
+	}
+
+	switch fields[1] {
+	case "set":
+		updates := make(map[string]string)
+		for _, arg := range fields[2:] {
+			name, value, ok := strings.Cut(arg, "=")
+			if !ok || !envNameRegexp.MatchString(name) {
+				// The value isn't echoed, as it is often a credential.
+				return fmt.Sprintf("Invalid environment variable %q. %s", name, envUsage), true, nil
+			}
+			updates[name] = value
+		}
+		if c.env == nil {
+			c.env = make(map[string]string)
+		}
+		maps.Copy(c.env, updates)
+	case "unset":
+		for _, name := range fields[2:] {
+			delete(c.env, name)
+		}
+	default:
+		return envUsage, true, nil
+	}
+
+	if s, ok := c.ChatMessageStore.(*sessions.Session); ok {
+		if err := s.SetEnv(c.env); err != nil {
+			return "", false, fmt.Errorf("saving session environment: %w", err)
+		}
+	}
+	return c.describeEnv(), true, nil
+}
+
+// describeEnv lists the names of the environment variables of the session.
+// Their values are often credentials, so they are never shown.
+func (c *Agent) describeEnv() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Working directory: %s\n\n", c.workDir)
+	if len(c.env) == 0 {
+		sb.WriteString("No environment variables are set for tools.")
+		return sb.String()
+	}
+	sb.WriteString("Environment variables set for tools (values hidden):\n\n")
+	for _, name := range slices.Sorted(maps.Keys(c.env)) {
+		fmt.Fprintf(&sb, "  - %s\n", name)
+	}
+	return sb.String()
+}
+
+// maskEnvQuery hides the values of an env set meta command, so that they
+// aren't recorded in the session and the journal with the user query.
+func maskEnvQuery(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) < 3 || fields[0] != "env" || fields[1] != "set" {
+		return query
+	}
+	for i, arg := range fields[2:] {
+		if name, _, ok := strings.Cut(arg, "="); ok {
+			fields[i+2] = name + "=***"
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// kubectlOutput runs kubectl with the kubeconfig, working directory and
+// environment of the session.
+func (c *Agent) kubectlOutput(ctx context.Context, args ...string) ([]byte, error) {
+	return c.kubectlOutputWithStdin(ctx, nil, args...)
+}
+
+// kubectlOutputWithStdin is like kubectlOutput, and feeds stdin to kubectl,
+// e.g. for "kubectl create -f -".
+func (c *Agent) kubectlOutputWithStdin(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	return tools.KubectlOutput(ctx, tools.InvokeToolOptions{Kubeconfig: c.Kubeconfig, WorkDir: c.workDir, Env: c.env}, stdin, args...)
+}
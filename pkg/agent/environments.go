@@ -0,0 +1,74 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// EnvironmentProduction is the environment of the contexts that must be
+// confirmed at the start of a session.
+const EnvironmentProduction = "production"
+
+// ContextEnvironment classifies the kubeconfig contexts whose name matches a
+// pattern as an environment, e.g. production or staging.
+type ContextEnvironment struct {
+	// Pattern is matched against the whole name of the context, where "*"
+	// matches any characters, e.g. "*prod*" or "gke_acme-prod_*".
+	Pattern     string `json:"pattern"`
+	Environment string `json:"environment"`
+}
+
+// Validate checks that the classification can be applied.
+func (e *ContextEnvironment) Validate() error {
+	if e.Pattern == "" {
+		return fmt.Errorf("context environment %q: pattern is required", e.Environment)
+	}
+	if e.Environment == "" {
+		return fmt.Errorf("context environment for pattern %q: environment is required", e.Pattern)
+	}
+	return nil
+}
+
+func (e *ContextEnvironment) matches(kubeContext string) bool {
+	return matchesPattern(e.Pattern, kubeContext)
+}
+
+// matchesPattern reports whether a pattern, where "*" matches any characters,
+// matches the whole of s.
+func matchesPattern(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(s)
+}
+
+// ClassifyContext returns the environment of a kubeconfig context, from the
+// first of the environments whose pattern matches its name, or "" if none
+// does.
+func ClassifyContext(environments []ContextEnvironment, kubeContext string) string {
+	if kubeContext == "" {
+		return ""
+	}
+	for i := range environments {
+		if environments[i].matches(kubeContext) {
+			return environments[i].Environment
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import "testing"
+
+func TestClassifyContext(t *testing.T) {
+	environments := []ContextEnvironment{
+		{Pattern: "kind-*", Environment: "development"},
+		{Pattern: "*prod*", Environment: EnvironmentProduction},
+		{Pattern: "arn:aws:eks:*:123456789012:cluster/*", Environment: "staging"},
+		{Pattern: "gke_acme_europe-west1_shop", Environment: EnvironmentProduction},
+	}
+
+	tests := []struct {
+		context string
+		want    string
+	}{
+		{context: "gke_acme-prod_us-central1_web", want: EnvironmentProduction},
+		// The first matching pattern wins.
+		{context: "kind-prod-test", want: "development"},
+		{context: "arn:aws:eks:eu-west-1:123456789012:cluster/shop", want: "staging"},
+		{context: "gke_acme_europe-west1_shop", want: EnvironmentProduction},
+		// Patterns match the whole name, and other characters literally.
+		{context: "gke_acme_europe-west1_shop-2", want: ""},
+		{context: "gke_acmeXeurope-west1_shop", want: ""},
+		{context: "minikube", want: ""},
+		{context: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyContext(environments, tt.context); got != tt.want {
+			t.Errorf("ClassifyContext(%q) = %q, want %q", tt.context, got, tt.want)
+		}
+	}
+}
+
+func TestContextEnvironmentValidate(t *testing.T) {
+	tests := []struct {
+		environment ContextEnvironment
+		wantErr     bool
+	}{
+		{environment: ContextEnvironment{Pattern: "*prod*", Environment: EnvironmentProduction}},
+		{environment: ContextEnvironment{Environment: EnvironmentProduction}, wantErr: true},
+		{environment: ContextEnvironment{Pattern: "*prod*"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.environment.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.environment, err, tt.wantErr)
+		}
+	}
+}
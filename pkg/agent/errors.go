@@ -0,0 +1,120 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/google/uuid"
+)
+
+// providerErrorPatterns recognize the errors of the LLM providers, whose
+// clients don't share an error type.
+var providerErrorPatterns = []struct {
+	category api.ErrorCategory
+	patterns []string
+}{
+	{
+		category: api.ErrorCategoryContextExceeded,
+		patterns: []string{"context_length_exceeded", "maximum context length", "context window", "input token count", "too many tokens", "prompt is too long"},
+	},
+	{
+		category: api.ErrorCategoryProviderRateLimit,
+		patterns: []string{"RESOURCE_EXHAUSTED", "rate limit", "Rate limit", "rate_limit", "Error 429", "ThrottlingException", "quota"},
+	},
+	{
+		category: api.ErrorCategoryProviderAuth,
+		patterns: []string{"API key not valid", "API_KEY_INVALID", "invalid_api_key", "Incorrect API key", "UNAUTHENTICATED", "Error 401", "Error 403", "could not find default credentials"},
+	},
+}
+
+// classifyProviderError wraps an error of the LLM provider with its category,
+// when it is recognized.
+func classifyProviderError(err error) error {
+	if err == nil || api.ErrorCategoryOf(err) != "" {
+		return err
+	}
+
+	if gollm.IsToolsNotSupported(err) {
+		if !errors.Is(err, gollm.ErrToolsNotSupported) {
+			err = fmt.Errorf("%w: %w", gollm.ErrToolsNotSupported, err)
+		}
+		return api.WithCategory(api.ErrorCategoryToolsNotSupported, err)
+	}
+
+	var apiErr *gollm.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return api.WithCategory(api.ErrorCategoryProviderAuth, err)
+		case http.StatusTooManyRequests:
+			return api.WithCategory(api.ErrorCategoryProviderRateLimit, err)
+		}
+	}
+
+	message := err.Error()
+	for _, p := range providerErrorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(message, pattern) {
+				return api.WithCategory(p.category, err)
+			}
+		}
+	}
+	return err
+}
+
+// addError reports an error to the user, with a hint at how to remediate it
+// when its category is known, and records it in the journal. The error is
+// kept as the last error of the agent, which sets the exit code in RunOnce mode.
+func (c *Agent) addError(ctx context.Context, err error) {
+	category := api.ErrorCategoryOf(err)
+
+	journal.RecorderFromContext(ctx).Write(ctx, &journal.Event{
+		Timestamp: time.Now(),
+		Action:    journal.ActionAgentError,
+		Payload:   map[string]any{"error": err.Error(), "category": string(category)},
+	})
+
+	text := "Error: " + err.Error()
+	if hint := category.Hint(); hint != "" {
+		text += "\n\nHint: " + hint
+	}
+	c.sessionMu.Lock()
+	c.lastError = err
+	c.sessionMu.Unlock()
+	c.postMessage(&api.Message{
+		ID:            uuid.New().String(),
+		Source:        api.MessageSourceAgent,
+		Type:          api.MessageTypeError,
+		Payload:       text,
+		Timestamp:     time.Now(),
+		ErrorCategory: category,
+	})
+}
+
+// LastError returns the last error reported to the user, or nil.
+func (c *Agent) LastError() error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.lastError
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestClassifyProviderError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want api.ErrorCategory
+	}{
+		{
+			name: "unauthorized",
+			err:  fmt.Errorf("sending message: %w", &gollm.APIError{StatusCode: 401, Message: "invalid token"}),
+			want: api.ErrorCategoryProviderAuth,
+		},
+		{
+			name: "too many requests",
+			err:  &gollm.APIError{StatusCode: 429, Message: "slow down"},
+			want: api.ErrorCategoryProviderRateLimit,
+		},
+		{
+			name: "gemini quota",
+			err:  errors.New("Error 429, Message: Resource has been exhausted (e.g. check quota)., Status: RESOURCE_EXHAUSTED"),
+			want: api.ErrorCategoryProviderRateLimit,
+		},
+		{
+			name: "gemini invalid key",
+			err:  errors.New("Error 400, Message: API key not valid. Please pass a valid API key., Status: INVALID_ARGUMENT"),
+			want: api.ErrorCategoryProviderAuth,
+		},
+		{
+			name: "openai context length",
+			err:  errors.New(`POST "https://api.openai.com/v1/chat/completions": 400 Bad Request {"code": "context_length_exceeded"}`),
+			want: api.ErrorCategoryContextExceeded,
+		},
+		{
+			name: "ollama model without tools",
+			err:  &gollm.APIError{StatusCode: 400, Message: `{"error":"registry.ollama.ai/library/gemma3:12b does not support tools"}`},
+			want: api.ErrorCategoryToolsNotSupported,
+		},
+		{
+			name: "unknown",
+			err:  errors.New("connection reset by peer"),
+		},
+		{
+			name: "already categorized",
+			err:  api.WithCategory(api.ErrorCategoryToolTimeout, errors.New("quota")),
+			want: api.ErrorCategoryToolTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyProviderError(tt.err)
+			if got := api.ErrorCategoryOf(err); got != tt.want {
+				t.Errorf("category = %q, want %q", got, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("classified error doesn't wrap the original error")
+			}
+			if got, want := errors.Is(err, gollm.ErrToolsNotSupported), tt.want == api.ErrorCategoryToolsNotSupported; got != want {
+				t.Errorf("errors.Is(err, ErrToolsNotSupported) = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAddError(t *testing.T) {
+	recorder := &eventRecorder{}
+	ctx := journal.ContextWithRecorder(context.Background(), recorder)
+	a := &Agent{Output: make(chan any, 1)}
+	a.session = &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()}
+
+	err := classifyProviderError(&gollm.APIError{StatusCode: 429, Message: "slow down"})
+	a.addError(ctx, err)
+
+	messages := a.session.ChatMessageStore.ChatMessages()
+	if len(messages) != 1 || messages[0].ErrorCategory != api.ErrorCategoryProviderRateLimit {
+		t.Fatalf("session messages = %+v, want the rate limit error", messages)
+	}
+	if text := messages[0].Payload.(string); !strings.Contains(text, "Hint: "+api.ErrorCategoryProviderRateLimit.Hint()) {
+		t.Errorf("error message %q doesn't contain the hint", text)
+	}
+	if category, _ := recorder.events[0].GetString("category"); category != string(api.ErrorCategoryProviderRateLimit) {
+		t.Errorf("journal event category = %q, want %q", category, api.ErrorCategoryProviderRateLimit)
+	}
+	if got := api.ErrorCategoryOf(a.LastError()).ExitCode(); got != 4 {
+		t.Errorf("exit code = %d, want 4", got)
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+const fanOutUsage = "Usage: fanout namespaces|contexts <name,name,...|all> <question>"
+
+const (
+	// maxFanOutTargets bounds the number of namespaces or contexts of a fan-out.
+	maxFanOutTargets = 50
+	// defaultFanOutConcurrency is the number of investigations run at the same time by default.
+	defaultFanOutConcurrency = 4
+	// defaultFanOutMaxIterations is the number of model turns of each investigation by default.
+	defaultFanOutMaxIterations = 10
+)
+
+// FanOutOptions configures the investigations fanned out across namespaces
+// or clusters with the "fanout" meta command.
+type FanOutOptions struct {
+	// MaxConcurrency bounds the investigations run at the same time.
+	MaxConcurrency int
+	// MaxIterations bounds the model turns of each investigation.
+	MaxIterations int
+}
+
+// fanOutScope is what the investigations of a fan-out are scoped to.
+type fanOutScope string
+
+const (
+	fanOutNamespaces fanOutScope = "namespace"
+	fanOutContexts   fanOutScope = "context"
+)
+
+// fanOutPrompt is the system prompt of each investigation.
+const fanOutPrompt = `You are a Kubernetes expert investigating a single %[1]s, %[2]q, as one of
+several identical investigations run in parallel across %[1]ss. kubectl is
+already configured to target it: don't pass --context, and only pass
+--namespace or --all-namespaces if the question is explicitly about other namespaces.
+
+Only run read-only commands: commands changing resources are refused.
+Investigate the question with the tools, then answer with a short report of
+your findings for %[2]q, starting with a one-line verdict. Be factual and
+include the names and values that support the verdict, e.g. images, versions,
+counts. Do not suggest fixes.`
+
+// fanOutMergePrompt is the system prompt merging the findings of the investigations.
+const fanOutMergePrompt = `You merge the findings of the same Kubernetes investigation run separately
+in several %[1]ss into a single comparative report.
+
+Start with a markdown table with one row per %[1]s and columns for the verdict
+and the key facts, then summarize what the %[1]ss have in common, how they
+differ and which ones need attention. Mention the %[1]ss whose investigation
+failed. Only use the findings you are given, do not call any tool.`
+
+// fanOutResult is the outcome of the investigation of a target.
+type fanOutResult struct {
+	target   string
+	findings string
+	err      error
+}
+
+// handleFanOutQuery implements the "fanout" meta command: the question is
+// investigated separately in each namespace or context, by sub-agents with
+// their own chat and kubeconfig running read-only tool calls, and their
+// findings are merged into a comparative report.
+func (c *Agent) handleFanOutQuery(ctx context.Context, query string) (answer string, handled bool, err error) {
+	if c.Offline {
+		return "", false, offlineError("fanout")
+	}
+	fields := strings.Fields(query)
+	if len(fields) < 4 {
+		return fanOutUsage, true, nil
+	}
+	var scope fanOutScope
+	switch fields[1] {
+	case "namespaces", "namespace", "ns":
+		scope = fanOutNamespaces
+	case "contexts", "context", "clusters":
+		scope = fanOutContexts
+	default:
+		return fanOutUsage, true, nil
+	}
+	question := strings.Join(fields[3:], " ")
+
+	targets, err := c.fanOutTargets(ctx, scope, fields[2])
+	if err != nil {
+		return "", false, err
+	}
+	if len(targets) == 0 {
+		return fmt.Sprintf("No %s to investigate.", scope), true, nil
+	}
+	if len(targets) > maxFanOutTargets {
+		return fmt.Sprintf("Found %d %ss, at most %d can be investigated at once. List the ones to investigate.", len(targets), scope, maxFanOutTargets), true, nil
+	}
+
+	results := c.fanOut(ctx, scope, targets, question)
+	c.sendProgress(api.ProgressPhaseThinking, "")
+	return c.mergeFindings(ctx, scope, question, results)
+}
+
+// fanOutTargets returns the namespaces or contexts listed by the user, or all
+// of them for "all".
+func (c *Agent) fanOutTargets(ctx context.Context, scope fanOutScope, list string) ([]string, error) {
+	if list != "all" {
+		var targets []string
+		for _, target := range strings.Split(list, ",") {
+			if target = strings.TrimSpace(target); target != "" && !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+		return targets, nil
+	}
+
+	args := []string{"get", "namespaces", "-o", "name"}
+	if scope == fanOutContexts {
+		args = []string{"config", "get-contexts", "-o", "name"}
+	}
+	out, err := c.kubectlOutput(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing %ss: %w", scope, err)
+	}
+	var targets []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimPrefix(strings.TrimSpace(line), "namespace/"); line != "" {
+			targets = append(targets, line)
+		}
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// fanOut investigates the question in each target, with at most
+// MaxConcurrency investigations at a time. The results are in the order of
+// the targets.
+func (c *Agent) fanOut(ctx context.Context, scope fanOutScope, targets []string, question string) []fanOutResult {
+	concurrency := c.FanOut.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultFanOutConcurrency
+	}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Investigating %d %ss, %d at a time...", len(targets), scope, min(concurrency, len(targets))))
+	c.sendProgress(api.ProgressPhaseRunningTool, fmt.Sprintf("fan-out across %d %ss", len(targets), scope))
+
+	results := make([]fanOutResult, len(targets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			findings, err := c.investigate(ctx, scope, target, question)
+			results[i] = fanOutResult{target: target, findings: findings, err: err}
+
+			mu.Lock()
+			done++
+			status := fmt.Sprintf("[%d/%d] %s %s investigated", done, len(targets), scope, target)
+			if err != nil {
+				status = fmt.Sprintf("[%d/%d] %s %s failed: %v", done, len(targets), scope, target, err)
+			}
+			mu.Unlock()
+			c.addMessage(api.MessageSourceAgent, api.MessageTypeText, status)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// investigate runs the investigation of a target: a sub-agent with its own
+// chat and a kubeconfig targeting the namespace or context runs the
+// read-only tool calls of the model until it answers.
+func (c *Agent) investigate(ctx context.Context, scope fanOutScope, target, question string) (string, error) {
+	log := klog.FromContext(ctx).WithValues("scope", scope, "target", target)
+
+	workDir, err := os.MkdirTemp(c.workDir, "fanout-*")
+	if err != nil {
+		return "", fmt.Errorf("creating working directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	selection := tools.KubeconfigSelection{Namespace: target}
+	if scope == fanOutContexts {
+		selection = tools.KubeconfigSelection{Context: target}
+	}
+	kubeconfig := filepath.Join(workDir, "kubeconfig")
+	if err := tools.WriteSelectedKubeconfig(ctx, tools.InvokeToolOptions{Kubeconfig: c.Kubeconfig, WorkDir: workDir, Env: c.env}, selection, kubeconfig); err != nil {
+		return "", err
+	}
+	opt := tools.InvokeToolOptions{Kubeconfig: kubeconfig, WorkDir: workDir, Env: c.env, OutputBudget: c.KubectlOutputBudget}
+
+	chat := c.startAccountedChat(fmt.Sprintf(fanOutPrompt, scope, target), c.Model)
+	var functionDefinitions []*gollm.FunctionDefinition
+	for _, tool := range c.Tools.AllTools() {
+		functionDefinitions = append(functionDefinitions, tools.FunctionDefinitionOf(tool))
+	}
+	sort.Slice(functionDefinitions, func(i, j int) bool {
+		return functionDefinitions[i].Name < functionDefinitions[j].Name
+	})
+	if err := chat.SetFunctionDefinitions(functionDefinitions); err != nil {
+		return "", fmt.Errorf("setting function definitions: %w", err)
+	}
+
+	maxIterations := c.FanOut.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultFanOutMaxIterations
+	}
+	return runInvestigation(ctx, chat, question, maxIterations, func(call gollm.FunctionCall) map[string]any {
+		result := c.runReadOnlyToolCall(ctx, call, opt)
+		log.V(2).Info("fan-out tool call", "tool", call.Name, "arguments", call.Arguments, "result", result)
+		return result
+	})
+}
+
+// runInvestigation asks the question in the chat and runs the tool calls of
+// the model with runCall until it answers, for at most maxIterations turns.
+func runInvestigation(ctx context.Context, chat gollm.Chat, question string, maxIterations int, runCall func(call gollm.FunctionCall) map[string]any) (string, error) {
+	contents := []any{question}
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		response, err := chat.Send(ctx, contents...)
+		if err != nil {
+			return "", classifyProviderError(err)
+		}
+		var text strings.Builder
+		var calls []gollm.FunctionCall
+		if candidates := response.Candidates(); len(candidates) > 0 {
+			for _, part := range candidates[0].Parts() {
+				if t, ok := part.AsText(); ok {
+					text.WriteString(t)
+				}
+				if fc, ok := part.AsFunctionCalls(); ok {
+					calls = append(calls, fc...)
+				}
+			}
+		}
+		if len(calls) == 0 {
+			return strings.TrimSpace(text.String()), nil
+		}
+
+		contents = nil
+		for _, call := range calls {
+			contents = append(contents, gollm.FunctionCallResult{ID: call.ID, Name: call.Name, Result: runCall(call)})
+		}
+	}
+	return "", fmt.Errorf("no findings after %d iterations", maxIterations)
+}
+
+// runReadOnlyToolCall runs a tool call of an investigation, unless it may
+// change resources or a pre-tool-exec hook blocks it. The model is told why
+// a call was refused.
+func (c *Agent) runReadOnlyToolCall(ctx context.Context, call gollm.FunctionCall, opt tools.InvokeToolOptions) map[string]any {
+	toolCall, err := c.Tools.ParseToolInvocation(ctx, call.Name, call.Arguments)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	analysis := ToolCallAnalysis{
+		FunctionCall:        call,
+		ParsedToolCall:      toolCall,
+		ModifiesResourceStr: toolCall.GetTool().CheckModifiesResource(call.Arguments),
+	}
+	if analysis.ModifiesResourceStr != "no" {
+		return map[string]any{"error": "refused: only read-only commands can run in fan-out investigations"}
+	}
+	analysis.Policy = c.evaluatePolicy(analysis)
+	switch analysis.policyVerdict() {
+	case tools.PolicyDeny:
+		return map[string]any{"error": "refused: " + policyDenial(analysis.Policy)}
+	case tools.PolicyAsk:
+		return map[string]any{"error": fmt.Sprintf("refused: the policy rule %q requires approval, which fan-out investigations can't ask for", analysis.Policy.Rule)}
+	}
+	if interactive, _ := toolCall.GetTool().IsInteractive(call.Arguments); interactive {
+		return map[string]any{"error": "refused: interactive commands can't run in fan-out investigations"}
+	}
+	if err := c.runHooks(ctx, c.toolHookEvent(HookEventPreToolExec, analysis)); err != nil {
+		return map[string]any{"error": "the tool call was blocked by a pre-tool-exec hook: " + err.Error()}
+	}
+
+	output, err := toolCall.InvokeTool(ctx, opt)
+	postEvent := c.toolHookEvent(HookEventPostToolExec, analysis)
+	postEvent.Output = output
+	if err != nil {
+		postEvent.Error = err.Error()
+	}
+	c.runHooks(ctx, postEvent)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	result, err := tools.ToolResultToMap(output)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return result
+}
+
+// mergeFindings asks the model for a comparative report of the findings of
+// the investigations. The findings are returned as is if the model fails.
+func (c *Agent) mergeFindings(ctx context.Context, scope fanOutScope, question string, results []fanOutResult) (string, bool, error) {
+	var findings strings.Builder
+	fmt.Fprintf(&findings, "Question investigated in each %s:\n%s\n", scope, question)
+	for _, result := range results {
+		fmt.Fprintf(&findings, "\n## %s %s\n", scope, result.target)
+		if result.err != nil {
+			fmt.Fprintf(&findings, "The investigation failed: %v\n", result.err)
+		} else {
+			fmt.Fprintf(&findings, "%s\n", result.findings)
+		}
+	}
+
+	chat := c.startAccountedChat(fmt.Sprintf(fanOutMergePrompt, scope), c.Model)
+	response, err := chat.Send(ctx, findings.String())
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "error merging the findings of the fan-out")
+		return "Could not merge the findings, here they are per " + string(scope) + ".\n\n" + findings.String(), true, nil
+	}
+	return responseText(response), true, nil
+}
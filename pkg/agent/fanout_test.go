@@ -0,0 +1,137 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func TestHandleFanOutQueryUsage(t *testing.T) {
+	for _, query := range []string{
+		"fanout",
+		"fanout namespaces shop,web",
+		"fanout pods shop why is it slow?",
+	} {
+		a := &Agent{}
+		answer, handled, err := a.handleFanOutQuery(context.Background(), query)
+		if err != nil || !handled || answer != fanOutUsage {
+			t.Errorf("handleFanOutQuery(%q) = %q, %v, %v, want the usage", query, answer, handled, err)
+		}
+	}
+}
+
+func TestFanOutTargets(t *testing.T) {
+	a := &Agent{}
+	got, err := a.fanOutTargets(context.Background(), fanOutNamespaces, "shop, web,,shop")
+	if err != nil {
+		t.Fatalf("fanOutTargets: %v", err)
+	}
+	if want := []string{"shop", "web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fanOutTargets() = %v, want %v", got, want)
+	}
+}
+
+func TestRunReadOnlyToolCall(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("kubectl").AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).DoAndReturn(func(args map[string]any) string {
+		if strings.HasPrefix(args["command"].(string), "kubectl delete") {
+			return "yes"
+		}
+		return "no"
+	}).AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().Run(gomock.Any(), gomock.Any()).Return("web-1   1/1   Running", nil).Times(1)
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+	a := &Agent{Tools: ts}
+
+	result := a.runReadOnlyToolCall(ctx, gollm.FunctionCall{Name: "kubectl", Arguments: map[string]any{"command": "kubectl delete pod web-1"}}, tools.InvokeToolOptions{})
+	if msg, _ := result["error"].(string); !strings.HasPrefix(msg, "refused") {
+		t.Errorf("modifying call returned %v, want it refused", result)
+	}
+
+	result = a.runReadOnlyToolCall(ctx, gollm.FunctionCall{Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}}, tools.InvokeToolOptions{})
+	if _, failed := result["error"]; failed {
+		t.Errorf("read-only call returned %v, want its output", result)
+	}
+}
+
+func TestMergeFindings(t *testing.T) {
+	ctx := context.Background()
+	results := []fanOutResult{
+		{target: "shop", findings: "All pods are running."},
+		{target: "web", err: errors.New("no findings after 10 iterations")},
+	}
+
+	tests := []struct {
+		name    string
+		sendErr error
+		want    string
+	}{
+		{
+			name: "merged by the model",
+			want: "| namespace | verdict |",
+		},
+		{
+			name:    "model failure",
+			sendErr: errors.New("quota exceeded"),
+			want:    "Could not merge the findings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			chat := mocks.NewMockChat(ctrl)
+			chat.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, contents ...any) (gollm.ChatResponse, error) {
+				prompt := contents[0].(string)
+				for _, want := range []string{"why is it slow?", "## namespace shop", "All pods are running.", "The investigation failed: no findings"} {
+					if !strings.Contains(prompt, want) {
+						t.Errorf("merge prompt doesn't contain %q:\n%s", want, prompt)
+					}
+				}
+				if tt.sendErr != nil {
+					return nil, tt.sendErr
+				}
+				return &fakeResponse{text: "| namespace | verdict |"}, nil
+			})
+			llm := mocks.NewMockClient(ctrl)
+			llm.EXPECT().StartChat(gomock.Any(), "test-model").Return(chat)
+			a := &Agent{LLM: llm, Model: "test-model"}
+
+			answer, handled, err := a.mergeFindings(ctx, fanOutNamespaces, "why is it slow?", results)
+			if err != nil || !handled {
+				t.Fatalf("mergeFindings() = %v, %v", handled, err)
+			}
+			if !strings.HasPrefix(answer, tt.want) {
+				t.Errorf("mergeFindings() = %q, want prefix %q", answer, tt.want)
+			}
+		})
+	}
+}
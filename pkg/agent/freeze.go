@@ -0,0 +1,253 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+// FreezeWindow is a recurring period during which changes are frozen, e.g.
+// from Friday 18:00 to Monday 08:00 in the production namespaces. Tool calls
+// that may modify resources then always require confirmation, and can only
+// be approved with a reason, even with --skip-permissions.
+type FreezeWindow struct {
+	Name string `json:"name"`
+	// Start and End delimit the window, as "Fri 18:00" for weekly windows, or
+	// "18:00" for daily ones. The window spans midnight, or the end of the
+	// week, when End is before Start.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Timezone is the IANA name of the time zone of Start and End, e.g.
+	// "Europe/Paris". The local time zone is used if empty.
+	Timezone string `json:"timezone,omitempty"`
+	// Namespaces are patterns of the namespaces frozen, where "*" matches any
+	// characters, e.g. "prod-*". All namespaces are frozen if empty. Changes
+	// whose namespace can't be determined, e.g. those of other commands than
+	// kubectl, are frozen by all windows.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Environments restrict the window to the kubeconfig contexts classified
+	// as one of them, see ContextEnvironment. All contexts are frozen if empty.
+	Environments []string `json:"environments,omitempty"`
+}
+
+// Validate checks that the window can be applied.
+func (w *FreezeWindow) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("freeze window: name is required")
+	}
+	_, err := w.bounds()
+	return err
+}
+
+// freezeBounds are the minutes of the day, or of the week, delimiting a window.
+type freezeBounds struct {
+	start, end int
+	weekly     bool
+	location   *time.Location
+}
+
+func (w *FreezeWindow) bounds() (*freezeBounds, error) {
+	b := &freezeBounds{location: time.Local}
+	if w.Timezone != "" {
+		location, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("freeze window %q: invalid timezone: %w", w.Name, err)
+		}
+		b.location = location
+	}
+	start, startWeekly, err := parseFreezeTime(w.Start)
+	if err != nil {
+		return nil, fmt.Errorf("freeze window %q: invalid start: %w", w.Name, err)
+	}
+	end, endWeekly, err := parseFreezeTime(w.End)
+	if err != nil {
+		return nil, fmt.Errorf("freeze window %q: invalid end: %w", w.Name, err)
+	}
+	if startWeekly != endWeekly {
+		return nil, fmt.Errorf("freeze window %q: start and end must both have a day of the week, or neither", w.Name)
+	}
+	b.start, b.end, b.weekly = start, end, startWeekly
+	return b, nil
+}
+
+var freezeWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseFreezeTime parses "Fri 18:00" into the minute of the week, or "18:00"
+// into the minute of the day.
+func parseFreezeTime(s string) (minute int, weekly bool, err error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, false, fmt.Errorf("expected \"Fri 18:00\" or \"18:00\", got %q", s)
+	}
+	clock, err := time.Parse("15:04", fields[len(fields)-1])
+	if err != nil {
+		return 0, false, fmt.Errorf("expected a time like 18:00, got %q", fields[len(fields)-1])
+	}
+	minute = clock.Hour()*60 + clock.Minute()
+	if len(fields) == 1 {
+		return minute, false, nil
+	}
+	day, ok := freezeWeekdays[strings.ToLower(fields[0])[:min(3, len(fields[0]))]]
+	if !ok {
+		return 0, false, fmt.Errorf("expected a day of the week like Fri, got %q", fields[0])
+	}
+	return int(day)*24*60 + minute, true, nil
+}
+
+// active reports whether the window is in effect at the given time.
+func (w *FreezeWindow) active(now time.Time) bool {
+	b, err := w.bounds()
+	if err != nil {
+		return false
+	}
+	t := now.In(b.location)
+	minute := t.Hour()*60 + t.Minute()
+	if b.weekly {
+		minute += int(t.Weekday()) * 24 * 60
+	}
+	if b.start <= b.end {
+		return b.start <= minute && minute < b.end
+	}
+	return minute >= b.start || minute < b.end
+}
+
+// freezes reports whether the window freezes changes in one of the given
+// namespaces, "" standing for an unknown one, of a context classified as
+// environment.
+func (w *FreezeWindow) freezes(namespaces []string, environment string) bool {
+	if len(w.Environments) > 0 && !slices.Contains(w.Environments, environment) {
+		return false
+	}
+	if len(w.Namespaces) == 0 {
+		return true
+	}
+	for _, namespace := range namespaces {
+		if namespace == "" {
+			return true
+		}
+		for _, pattern := range w.Namespaces {
+			if matchesPattern(pattern, namespace) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// activeFreeze returns the freeze window in effect for a tool call, or nil if
+// the call isn't frozen.
+func (c *Agent) activeFreeze(call ToolCallAnalysis, now time.Time) *FreezeWindow {
+	if len(c.FreezeWindows) == 0 || call.ModifiesResourceStr == "no" {
+		return nil
+	}
+	var namespaces []string
+	command, _ := call.FunctionCall.Arguments["command"].(string)
+	for _, kc := range tools.ParseKubectlCommands(command) {
+		switch {
+		case kc.AllNamespaces:
+			namespaces = append(namespaces, "")
+		case kc.Namespace != "":
+			namespaces = append(namespaces, kc.Namespace)
+		default:
+			namespaces = append(namespaces, c.namespace)
+		}
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for i := range c.FreezeWindows {
+		w := &c.FreezeWindows[i]
+		if w.active(now) && w.freezes(namespaces, c.environment) {
+			return w
+		}
+	}
+	return nil
+}
+
+// hasFrozenCalls reports whether some of the pending calls are frozen.
+func (c *Agent) hasFrozenCalls() bool {
+	now := time.Now()
+	for _, call := range c.pendingFunctionCalls {
+		if c.activeFreeze(call, now) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// breakGlassApproval returns the approval of a frozen call, recording the
+// reason given by the approver in the journal.
+func (c *Agent) breakGlassApproval(ctx context.Context, call ToolCallAnalysis, freeze *FreezeWindow, approver, reason string) *api.Approval {
+	approval := &api.Approval{
+		Approver:  approver,
+		Method:    api.ApprovalMethodBreakGlass,
+		Timestamp: time.Now(),
+		Reason:    reason,
+	}
+	journal.RecorderFromContext(ctx).Write(ctx, &journal.Event{
+		Timestamp: approval.Timestamp,
+		Action:    journal.ActionBreakGlass,
+		Payload: map[string]any{
+			"approver": approver,
+			"reason":   reason,
+			"freeze":   freeze.Name,
+			"call":     call.ParsedToolCall.Description(),
+		},
+	})
+	return approval
+}
+
+// checkBreakGlass checks that a reason is given to approve the pending calls
+// of indexes that are frozen.
+func (c *Agent) checkBreakGlass(indexes []int, reason string) error {
+	if strings.TrimSpace(reason) != "" {
+		return nil
+	}
+	now := time.Now()
+	for _, i := range indexes {
+		if freeze := c.activeFreeze(c.pendingFunctionCalls[i], now); freeze != nil {
+			return fmt.Errorf("changes are frozen by %q, a reason is required to approve %s", freeze.Name, c.pendingFunctionCalls[i].ParsedToolCall.Description())
+		}
+	}
+	return nil
+}
+
+// approveCalls records the approval of the pending calls of indexes, the
+// frozen ones being approved with the reason given to break the freeze, and
+// reports whether calls remain undecided.
+func (c *Agent) approveCalls(ctx context.Context, indexes []int, approval *api.Approval, reason string) (undecided bool) {
+	now := time.Now()
+	var approved []int
+	for _, i := range indexes {
+		call := c.pendingFunctionCalls[i]
+		if freeze := c.activeFreeze(call, now); freeze != nil {
+			c.decide([]int{i}, c.breakGlassApproval(ctx, call, freeze, approval.Approver, strings.TrimSpace(reason)))
+			continue
+		}
+		approved = append(approved, i)
+	}
+	return c.decide(approved, approval)
+}
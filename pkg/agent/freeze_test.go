@@ -0,0 +1,139 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFreezeWindowActive(t *testing.T) {
+	weekend := FreezeWindow{Name: "weekend", Start: "Fri 18:00", End: "Mon 08:00", Timezone: "Europe/Paris"}
+	nightly := FreezeWindow{Name: "nightly", Start: "22:00", End: "06:00", Timezone: "UTC"}
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	tests := []struct {
+		window   FreezeWindow
+		time     time.Time
+		expected bool
+	}{
+		{window: weekend, time: time.Date(2025, 7, 4, 17, 59, 0, 0, paris), expected: false}, // Friday
+		{window: weekend, time: time.Date(2025, 7, 4, 18, 0, 0, 0, paris), expected: true},
+		{window: weekend, time: time.Date(2025, 7, 6, 12, 0, 0, 0, paris), expected: true}, // Sunday
+		{window: weekend, time: time.Date(2025, 7, 7, 7, 59, 0, 0, paris), expected: true}, // Monday
+		{window: weekend, time: time.Date(2025, 7, 7, 8, 0, 0, 0, paris), expected: false},
+		{window: weekend, time: time.Date(2025, 7, 4, 16, 30, 0, 0, time.UTC), expected: true}, // 18:30 in Paris
+		{window: nightly, time: time.Date(2025, 7, 2, 23, 0, 0, 0, time.UTC), expected: true},
+		{window: nightly, time: time.Date(2025, 7, 3, 5, 59, 0, 0, time.UTC), expected: true},
+		{window: nightly, time: time.Date(2025, 7, 3, 12, 0, 0, 0, time.UTC), expected: false},
+	}
+	for _, tc := range tests {
+		if got := tc.window.active(tc.time); got != tc.expected {
+			t.Errorf("%s.active(%v) = %v, want %v", tc.window.Name, tc.time, got, tc.expected)
+		}
+	}
+
+	for _, invalid := range []FreezeWindow{
+		{Start: "Fri 18:00", End: "Mon 08:00"},
+		{Name: "mixed", Start: "Fri 18:00", End: "08:00"},
+		{Name: "day", Start: "Someday 18:00", End: "Mon 08:00"},
+		{Name: "time", Start: "6pm", End: "8am"},
+		{Name: "zone", Start: "18:00", End: "08:00", Timezone: "Mars/Olympus"},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate() accepted %+v", invalid)
+		}
+	}
+}
+
+func TestFreezeApproval(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("bash").AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).Return("yes").AnyTimes()
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	// The window is in effect for the two hours around now.
+	now := time.Now().UTC()
+	a := &Agent{
+		Tools:           ts,
+		SkipPermissions: true,
+		FreezeWindows: []FreezeWindow{{
+			Name:       "release",
+			Start:      now.Add(-time.Hour).Format("Mon 15:04"),
+			End:        now.Add(time.Hour).Format("Mon 15:04"),
+			Timezone:   "UTC",
+			Namespaces: []string{"prod-*"},
+		}},
+		session: &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:  make(chan any, 20),
+	}
+	var calls []gollm.FunctionCall
+	for i, command := range []string{"kubectl delete pod web-0 -n prod-eu", "kubectl delete pod web-0 -n dev"} {
+		calls = append(calls, gollm.FunctionCall{ID: string(rune('a' + i)), Name: "bash", Arguments: map[string]any{"command": command}})
+	}
+	var err error
+	if a.pendingFunctionCalls, err = a.analyzeToolCalls(ctx, calls); err != nil {
+		t.Fatalf("analyzeToolCalls: %v", err)
+	}
+
+	// Frozen calls require approval even with --skip-permissions.
+	if !a.hasFrozenCalls() {
+		t.Fatalf("hasFrozenCalls() = false, want true")
+	}
+	a.queueApprovals(ctx)
+	pending := a.PendingApprovals()
+	if len(pending) != 2 || pending[0].Freeze != "release" || pending[1].Freeze != "" {
+		t.Fatalf("PendingApprovals() = %+v, want the first call frozen", pending)
+	}
+
+	// Approving without a reason is ignored.
+	if a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 1, Approver: "alice"}) || len(a.undecidedCalls()) != 2 {
+		t.Fatalf("handleChoice() approved frozen calls without a reason")
+	}
+	// Editing a call into a frozen one requires a reason too.
+	if a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 1, CallIDs: []string{pending[1].ID}, Approver: "alice", Command: "kubectl delete pod web-0 -n prod-us"}) {
+		t.Fatalf("handleChoice() dispatched the calls after an edit")
+	}
+	if got := a.pendingFunctionCalls[1].FunctionCall.Arguments["command"]; got != "kubectl delete pod web-0 -n dev" || len(a.undecidedCalls()) != 2 {
+		t.Fatalf("handleChoice() applied the edit of a call into a frozen one without a reason: %v", got)
+	}
+	if !a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 1, Approver: "alice", Reason: "INC-1234 outage"}) {
+		t.Fatalf("handleChoice() did not dispatch the calls approved with a reason")
+	}
+	frozen, other := a.pendingFunctionCalls[0].Approval, a.pendingFunctionCalls[1].Approval
+	if frozen == nil || frozen.Method != api.ApprovalMethodBreakGlass || frozen.Reason != "INC-1234 outage" || frozen.Approver != "alice" {
+		t.Errorf("approval of the frozen call = %+v, want a break-glass approval with the reason", frozen)
+	}
+	if other == nil || other.Method != api.ApprovalMethodConfirmed {
+		t.Errorf("approval of the other call = %+v, want a confirmed approval", other)
+	}
+}
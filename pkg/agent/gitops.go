@@ -0,0 +1,335 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// GitOpsOptions configures the GitOps mode: the changes of resources managed
+// by a GitOps controller, e.g. Argo CD or Flux, are made to their manifests
+// in a pull request, instead of in the cluster where the controller would
+// revert them.
+type GitOpsOptions struct {
+	// Repository is the HTTPS URL of the Git repository of the manifests.
+	// The GitOps mode is disabled if empty.
+	Repository string
+	// BaseBranch is the branch the pull requests are opened against, main if empty.
+	BaseBranch string
+	// Path is the directory of the manifests of the cluster in the repository,
+	// e.g. the kustomize overlay of an environment. The whole repository if empty.
+	Path string
+	// Provider hosts the repository, github or gitlab. It is guessed from the
+	// host of the repository if empty.
+	Provider string
+	// APIURL is the URL of the API of the provider, for self-hosted instances.
+	APIURL string
+	// Token authenticates the pushes and the pull requests.
+	Token string
+}
+
+// Validate checks that pull requests can be opened.
+func (o *GitOpsOptions) Validate() error {
+	u, err := url.Parse(o.Repository)
+	if err != nil || u.Scheme != "https" || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("gitops: the repository must be an HTTPS URL, e.g. https://github.com/example/deploy")
+	}
+	switch o.provider() {
+	case "github", "gitlab":
+	default:
+		return fmt.Errorf("gitops: unknown provider %q, expected github or gitlab", o.Provider)
+	}
+	if filepath.IsAbs(o.Path) || strings.HasPrefix(filepath.Clean(o.Path), "..") {
+		return fmt.Errorf("gitops: the path %q must be relative to the root of the repository", o.Path)
+	}
+	return nil
+}
+
+func (o *GitOpsOptions) provider() string {
+	if o.Provider != "" {
+		return o.Provider
+	}
+	if u, err := url.Parse(o.Repository); err == nil && strings.Contains(u.Host, "gitlab") {
+		return "gitlab"
+	}
+	return "github"
+}
+
+func (o *GitOpsOptions) baseBranch() string {
+	if o.BaseBranch != "" {
+		return o.BaseBranch
+	}
+	return "main"
+}
+
+// repositoryPath returns the path of the repository on its host, e.g. example/deploy.
+func (o *GitOpsOptions) repositoryPath() string {
+	u, _ := url.Parse(o.Repository)
+	return strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+}
+
+func (o *GitOpsOptions) apiURL() string {
+	if o.APIURL != "" {
+		return strings.TrimSuffix(o.APIURL, "/")
+	}
+	u, _ := url.Parse(o.Repository)
+	switch {
+	case o.provider() == "gitlab":
+		return "https://" + u.Host + "/api/v4"
+	case u.Host == "github.com":
+		return "https://api.github.com"
+	default:
+		// GitHub Enterprise Server.
+		return "https://" + u.Host + "/api/v3"
+	}
+}
+
+// pullRequest is a change of the repository to propose.
+type pullRequest struct {
+	Branch string
+	Title  string
+	Body   string
+	// Edit changes the clone of the repository in dir, and returns the files
+	// it changed.
+	Edit func(dir string) ([]string, error)
+}
+
+// openPullRequest pushes the edits of the pull request to a new branch of
+// the repository, and opens the pull request (a merge request on GitLab)
+// against the base branch. It returns the URL of the pull request and the
+// files changed.
+func (o *GitOpsOptions) openPullRequest(ctx context.Context, pr pullRequest) (string, []string, error) {
+	dir, err := os.MkdirTemp("", "kubectl-ai-gitops-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := o.git(ctx, "", "clone", "--depth=1", "--branch", o.baseBranch(), o.Repository, dir); err != nil {
+		return "", nil, err
+	}
+	files, err := pr.Edit(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, args := range [][]string{
+		{"checkout", "-b", pr.Branch},
+		{"add", "-A"},
+		{"-c", "user.name=kubectl-ai", "-c", "user.email=kubectl-ai@localhost", "commit", "-m", pr.Title},
+		{"push", "origin", pr.Branch},
+	} {
+		if err := o.git(ctx, dir, args...); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var endpoint, urlField string
+	var request map[string]string
+	switch o.provider() {
+	case "gitlab":
+		endpoint = o.apiURL() + "/projects/" + url.PathEscape(o.repositoryPath()) + "/merge_requests"
+		request = map[string]string{"source_branch": pr.Branch, "target_branch": o.baseBranch(), "title": pr.Title, "description": pr.Body}
+		urlField = "web_url"
+	default:
+		endpoint = o.apiURL() + "/repos/" + o.repositoryPath() + "/pulls"
+		request = map[string]string{"head": pr.Branch, "base": o.baseBranch(), "title": pr.Title, "body": pr.Body}
+		urlField = "html_url"
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.provider() == "gitlab" {
+		req.Header.Set("PRIVATE-TOKEN", o.Token)
+	} else {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Authorization", "Bearer "+o.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("opening the pull request: %w", err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("opening the pull request: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	var created map[string]any
+	if err := json.Unmarshal(b, &created); err != nil {
+		return "", nil, fmt.Errorf("parsing the pull request: %w", err)
+	}
+	prURL, _ := created[urlField].(string)
+	return prURL, files, nil
+}
+
+// git runs a git command in dir, authenticated with the token.
+func (o *GitOpsOptions) git(ctx context.Context, dir string, args ...string) error {
+	// Errors only report the subcommand, the arguments hold the token.
+	subcommand := args[0]
+	for i := 0; i+2 < len(args) && args[i] == "-c"; i += 2 {
+		subcommand = args[i+2]
+	}
+	if o.Token != "" {
+		user := "x-access-token"
+		if o.provider() == "gitlab" {
+			user = "oauth2"
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(user + ":" + o.Token))
+		args = append([]string{"-c", "http.extraHeader=Authorization: Basic " + credentials}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", subcommand, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// gitOpsResult is the result of a tool call changing resources managed by
+// GitOps, whose change was proposed in a pull request.
+type gitOpsResult struct {
+	PullRequest string `json:"pull_request,omitempty"`
+	// ManagedBy lists the objects changed and their GitOps controller.
+	ManagedBy []string `json:"managed_by"`
+	Files     []string `json:"files,omitempty"`
+	// NotApplied are the objects not managed by GitOps that the call would
+	// also have changed.
+	NotApplied []string `json:"not_applied,omitempty"`
+	Note       string   `json:"note,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// proposeGitOpsChange opens a pull request with the change of a tool call,
+// if it changes resources managed by GitOps, and returns the result of the
+// call. The cluster is left unchanged. It returns false for the other calls,
+// to be run as usual.
+func (c *Agent) proposeGitOpsChange(ctx context.Context, call ToolCallAnalysis) (any, bool) {
+	if c.GitOps.Repository == "" || call.ModifiesResourceStr == "no" {
+		return nil, false
+	}
+	opt := tools.InvokeToolOptions{Kubeconfig: c.Kubeconfig, WorkDir: c.workDir, Env: c.env}
+	changes, unmanaged, ok, err := tools.GitOpsChanges(ctx, call.FunctionCall.Name, call.FunctionCall.Arguments, opt)
+	if !ok {
+		return nil, false
+	}
+	if err != nil {
+		// Whether the resources are managed by GitOps is unknown, so they are not changed.
+		return &gitOpsResult{Error: "previewing the change, to check whether the resources are managed by GitOps: " + err.Error()}, true
+	}
+	if len(changes) == 0 {
+		return nil, false
+	}
+
+	result := &gitOpsResult{NotApplied: unmanaged}
+	for _, change := range changes {
+		result.ManagedBy = append(result.ManagedBy, change.String()+": "+change.ManagedBy)
+	}
+	c.sendProgress(api.ProgressPhaseRunningTool, "Opening a pull request on "+c.GitOps.Repository)
+	url, files, err := c.GitOps.openPullRequest(ctx, pullRequest{
+		Branch: gitOpsBranch(changes[0]),
+		Title:  gitOpsTitle(changes),
+		Body:   c.gitOpsDescription(call, changes),
+		Edit: func(dir string) ([]string, error) {
+			var files []string
+			for _, change := range changes {
+				changed, err := tools.ApplyGitOpsChange(filepath.Join(dir, c.GitOps.Path), change)
+				if err != nil {
+					return nil, err
+				}
+				for _, file := range changed {
+					files = append(files, filepath.Join(c.GitOps.Path, file))
+				}
+			}
+			return files, nil
+		},
+	})
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "opening a GitOps pull request")
+		result.Error = "the resources are managed by GitOps, and the pull request changing their manifests could not be opened: " + err.Error()
+		return result, true
+	}
+	result.PullRequest, result.Files = url, files
+	result.Note = "The resources are managed by GitOps: the cluster was not changed, a pull request changing their manifests was opened instead. " +
+		"The change takes effect once the pull request is merged and synced by the GitOps controller."
+	if len(unmanaged) > 0 {
+		result.Note += " The objects of not_applied are not managed by GitOps and were not changed either, change them with a separate call if needed."
+	}
+	return result, true
+}
+
+// gitOpsBranch names the branch of the pull request of a change.
+func gitOpsBranch(change tools.GitOpsChange) string {
+	metadata, _ := change.Object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	kind, _ := change.Object["kind"].(string)
+	return fmt.Sprintf("kubectl-ai/%s-%s-%s", time.Now().Format("20060102-150405"), strings.ToLower(kind), name)
+}
+
+func gitOpsTitle(changes []tools.GitOpsChange) string {
+	verb := "Update"
+	if changes[0].Delete {
+		verb = "Delete"
+	}
+	title := verb + " " + changes[0].String()
+	if len(changes) > 1 {
+		title += fmt.Sprintf(" and %d more", len(changes)-1)
+	}
+	return title
+}
+
+// gitOpsDescription summarizes the session for the description of a pull request.
+func (c *Agent) gitOpsDescription(call ToolCallAnalysis, changes []tools.GitOpsChange) string {
+	var sb strings.Builder
+	sb.WriteString("kubectl-ai opened this pull request instead of changing the cluster, as the resources are managed by GitOps.\n\n")
+	request, plan := c.lastPlan()
+	if request != "" {
+		fmt.Fprintf(&sb, "### Request\n\n%s\n\n", request)
+	}
+	if plan != "" {
+		fmt.Fprintf(&sb, "### Summary\n\n%s\n\n", plan)
+	}
+	fmt.Fprintf(&sb, "### Change\n\n```\n%s\n```\n\n", call.ParsedToolCall.Description())
+	for _, change := range changes {
+		fmt.Fprintf(&sb, "- %s, managed by %s\n", change, change.ManagedBy)
+	}
+	approver := "unknown"
+	if approval := c.toolCallApproval(call); approval != nil {
+		approver = approval.Approver
+	}
+	fmt.Fprintf(&sb, "\nSession %s, approved by %s.\n", c.sessionID(), approver)
+	return sb.String()
+}
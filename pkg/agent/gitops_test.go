@@ -0,0 +1,123 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGitOpsOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options GitOpsOptions
+		wantErr bool
+	}{
+		{name: "github", options: GitOpsOptions{Repository: "https://github.com/example/deploy"}},
+		{name: "gitlab subgroup", options: GitOpsOptions{Repository: "https://gitlab.example.com/platform/clusters/deploy.git", Path: "overlays/prod"}},
+		{name: "ssh", options: GitOpsOptions{Repository: "git@github.com:example/deploy.git"}, wantErr: true},
+		{name: "unknown provider", options: GitOpsOptions{Repository: "https://git.example.com/deploy", Provider: "gitea"}, wantErr: true},
+		{name: "path outside", options: GitOpsOptions{Repository: "https://github.com/example/deploy", Path: "../other"}, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.options.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() = %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	gitlab := GitOpsOptions{Repository: "https://gitlab.example.com/platform/deploy.git"}
+	if got, want := gitlab.apiURL(), "https://gitlab.example.com/api/v4"; got != want {
+		t.Errorf("apiURL() = %q, want %q", got, want)
+	}
+	if got, want := gitlab.repositoryPath(), "platform/deploy"; got != want {
+		t.Errorf("repositoryPath() = %q, want %q", got, want)
+	}
+}
+
+func TestOpenPullRequest(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	// The repository of the manifests, with a main branch.
+	remote := filepath.Join(t.TempDir(), "deploy.git")
+	git("", "init", "--bare", "--initial-branch=main", remote)
+	work := t.TempDir()
+	git(work, "clone", remote, ".")
+	if err := os.WriteFile(filepath.Join(work, "web.yaml"), []byte("replicas: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git(work, "add", "-A")
+	git(work, "commit", "-m", "Add web")
+	git(work, "push", "origin", "HEAD:main")
+
+	var got map[string]string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/deploy/pulls") || r.Header.Get("Authorization") != "Bearer t0ken" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"html_url": "https://github.com/example/deploy/pull/1"})
+	}))
+	defer api.Close()
+
+	// The repository is cloned from its path, which the API is called with.
+	options := &GitOpsOptions{Repository: remote, APIURL: api.URL, Token: "t0ken", Provider: "github"}
+	url, files, err := options.openPullRequest(context.Background(), pullRequest{
+		Branch: "kubectl-ai/scale-web",
+		Title:  "Update Deployment web",
+		Body:   "Scale web to 3 replicas.",
+		Edit: func(dir string) ([]string, error) {
+			return []string{"web.yaml"}, os.WriteFile(filepath.Join(dir, "web.yaml"), []byte("replicas: 3\n"), 0o644)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://github.com/example/deploy/pull/1" || !reflect.DeepEqual(files, []string{"web.yaml"}) {
+		t.Errorf("openPullRequest() = %q, %v", url, files)
+	}
+	want := map[string]string{"head": "kubectl-ai/scale-web", "base": "main", "title": "Update Deployment web", "body": "Scale web to 3 replicas."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pull request = %v, want %v", got, want)
+	}
+
+	// The branch was pushed with the change.
+	git(work, "fetch", "origin", "kubectl-ai/scale-web")
+	git(work, "checkout", "FETCH_HEAD")
+	if b, _ := os.ReadFile(filepath.Join(work, "web.yaml")); string(b) != "replicas: 3\n" {
+		t.Errorf("pushed web.yaml = %q", b)
+	}
+}
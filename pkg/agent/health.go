@@ -0,0 +1,35 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/health"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/mcp"
+)
+
+// HealthChecks returns the checks of the readiness endpoint of the UIs
+// serving the agent: the provider (unless offline), the kubeconfig and, if
+// the MCP client is enabled, the MCP servers. It must be called after Init.
+func (c *Agent) HealthChecks() []health.Check {
+	var checks []health.Check
+	if !c.Offline {
+		checks = append(checks, health.Provider(c.LLM))
+	}
+	checks = append(checks, health.Kubeconfig(c.Kubeconfig))
+	if c.MCPClientEnabled {
+		checks = append(checks, health.MCPServers(func() *mcp.Manager { return c.mcpManager }))
+	}
+	return checks
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// HistoryFidelity controls how the saved messages of a session are given
+// back to the model when the chat is re-initialized, e.g. when resuming it.
+type HistoryFidelity string
+
+const (
+	// HistoryFidelityFull replays the messages as they were saved.
+	HistoryFidelityFull HistoryFidelity = "full"
+	// HistoryFidelityDigest replaces the output of old tool calls with a short
+	// digest, so that sessions with large kubectl outputs fit in the context.
+	HistoryFidelityDigest HistoryFidelity = "digest"
+)
+
+const (
+	// digestMaxBytes is the size of tool results kept as is in digest mode.
+	digestMaxBytes = 1024
+	// digestLines is the number of lines of output kept in a digest.
+	digestLines = 5
+)
+
+// ParseHistoryFidelity parses the value of --history-fidelity.
+func ParseHistoryFidelity(s string) (HistoryFidelity, error) {
+	switch f := HistoryFidelity(s); f {
+	case HistoryFidelityFull, HistoryFidelityDigest:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported history fidelity %q (supported values: %s, %s)", s, HistoryFidelityFull, HistoryFidelityDigest)
+}
+
+// initializeChat re-initializes the chat with the saved messages of the
+// session, at the fidelity selected for the agent. The saved messages are not
+// modified.
+func (c *Agent) initializeChat(messages []*api.Message) error {
+	// Charts are drawn from tool results that are already in the history.
+	messages = slices.DeleteFunc(slices.Clone(messages), func(m *api.Message) bool {
+		return m.Type == api.MessageTypeChart
+	})
+	if c.HistoryFidelity == HistoryFidelityDigest {
+		messages = digestHistory(messages)
+	}
+	return c.llmChat.Initialize(chatHistory(messages))
+}
+
+// chatHistory converts the messages of a session to the history of a chat.
+// The messages of the agent, e.g. errors, are given to the model as user
+// messages.
+func chatHistory(messages []*api.Message) []*gollm.Message {
+	history := make([]*gollm.Message, 0, len(messages))
+	for _, message := range messages {
+		var role gollm.Role
+		switch message.Source {
+		case api.MessageSourceUser, api.MessageSourceAgent:
+			role = gollm.RoleUser
+		case api.MessageSourceModel:
+			role = gollm.RoleModel
+		default:
+			continue
+		}
+		if message.Payload == nil {
+			continue
+		}
+		history = append(history, &gollm.Message{Role: role, Content: message.Payload})
+	}
+	return history
+}
+
+// digestHistory returns the messages with the large tool results replaced by
+// a digest. The command run is kept in the tool call request preceding them.
+func digestHistory(messages []*api.Message) []*api.Message {
+	digested := make([]*api.Message, 0, len(messages))
+	for _, message := range messages {
+		if message.Type == api.MessageTypeToolCallResponse {
+			if digest, ok := digestToolResult(message.Payload); ok {
+				m := *message
+				m.Payload = digest
+				message = &m
+			}
+		}
+		digested = append(digested, message)
+	}
+	return digested
+}
+
+// digestToolResult returns the first lines of a tool result and the size of
+// the elided output, or false if the result is small enough to be kept.
+func digestToolResult(payload any) (string, bool) {
+	var text string
+	switch p := payload.(type) {
+	case string:
+		text = p
+	default:
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return "", false
+		}
+		text = string(b)
+	}
+	if len(text) <= digestMaxBytes {
+		return "", false
+	}
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	kept := lines
+	if len(kept) > digestLines {
+		kept = kept[:digestLines]
+	}
+	head := strings.Join(kept, "\n")
+	if len(head) > digestMaxBytes {
+		head = strings.ToValidUTF8(head[:digestMaxBytes], "")
+	}
+	return fmt.Sprintf("%s\n[... output elided from the resumed history: %d lines, %d bytes in total. Run the command again if you need the full output.]",
+		head, len(lines), len(text)), true
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestDigestHistory(t *testing.T) {
+	var pods strings.Builder
+	pods.WriteString("NAME                     READY   STATUS    RESTARTS   AGE\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&pods, "nginx-7c5ddbdf54-%05d   1/1     Running   0          3d\n", i)
+	}
+	largeMap := map[string]any{"stdout": pods.String(), "exit_code": 0}
+
+	tests := []struct {
+		name        string
+		payload     any
+		wantDigest  bool
+		wantContain []string
+	}{
+		{
+			name:    "small output is kept",
+			payload: "Result of running \"kubectl\":\nNAME   READY\nnginx  1/1",
+		},
+		{
+			name:       "large output",
+			payload:    "Result of running \"kubectl\":\n" + pods.String(),
+			wantDigest: true,
+			wantContain: []string{
+				"Result of running \"kubectl\":\nNAME",
+				"nginx-7c5ddbdf54-00002",
+				"102 lines",
+			},
+		},
+		{
+			name:        "large tool result",
+			payload:     largeMap,
+			wantDigest:  true,
+			wantContain: []string{"\"stdout\": \"NAME", "output elided"},
+		},
+		{
+			name:       "single long line",
+			payload:    strings.Repeat("x", 5000),
+			wantDigest: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &api.Message{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get pods"}
+			response := &api.Message{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: tt.payload}
+			messages := []*api.Message{request, response}
+
+			got := digestHistory(messages)
+			if len(got) != 2 || got[0] != request {
+				t.Fatalf("digestHistory() = %v, want the tool call request unchanged", got)
+			}
+			if !tt.wantDigest {
+				if got[1] != response {
+					t.Errorf("digestHistory() changed a small tool result: %v", got[1].Payload)
+				}
+				return
+			}
+			digest, ok := got[1].Payload.(string)
+			if !ok {
+				t.Fatalf("digested payload is %T, want a string", got[1].Payload)
+			}
+			if len(digest) > digestMaxBytes+200 {
+				t.Errorf("digest is %d bytes, want at most about %d", len(digest), digestMaxBytes)
+			}
+			if strings.Contains(digest, "nginx-7c5ddbdf54-00099") {
+				t.Errorf("digest contains the end of the output:\n%s", digest)
+			}
+			for _, want := range tt.wantContain {
+				if !strings.Contains(digest, want) {
+					t.Errorf("digest doesn't contain %q:\n%s", want, digest)
+				}
+			}
+			if got[1] == response {
+				t.Errorf("digestHistory() modified the saved message instead of a copy")
+			}
+		})
+	}
+}
+
+func TestParseHistoryFidelity(t *testing.T) {
+	for _, s := range []string{"full", "digest"} {
+		if f, err := ParseHistoryFidelity(s); err != nil || string(f) != s {
+			t.Errorf("ParseHistoryFidelity(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := ParseHistoryFidelity("summary"); err == nil {
+		t.Errorf("ParseHistoryFidelity(summary): want error")
+	}
+}
+
+func TestChatHistory(t *testing.T) {
+	messages := []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "why is nginx down?"},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeText, Payload: "Let me check the pods."},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get pods"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeUserInputRequest},
+		{Source: "hook", Type: api.MessageTypeText, Payload: "unknown source"},
+	}
+	want := []*gollm.Message{
+		{Role: gollm.RoleUser, Content: "why is nginx down?"},
+		{Role: gollm.RoleModel, Content: "Let me check the pods."},
+		{Role: gollm.RoleUser, Content: "kubectl get pods"},
+	}
+	if got := chatHistory(messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("chatHistory() = %v, want %v", got, want)
+	}
+}
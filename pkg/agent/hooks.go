@@ -0,0 +1,189 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"k8s.io/klog/v2"
+)
+
+// HookEventType is the type of agent event a hook is run for.
+type HookEventType string
+
+const (
+	// HookEventPreToolExec is emitted before a tool call is executed.
+	HookEventPreToolExec HookEventType = "pre-tool-exec"
+	// HookEventPostToolExec is emitted after a tool call was executed.
+	HookEventPostToolExec HookEventType = "post-tool-exec"
+	// HookEventSessionEnd is emitted when the agent is closed.
+	HookEventSessionEnd HookEventType = "on-session-end"
+
+	// defaultHookTimeout bounds the run time of hooks without a configured timeout.
+	defaultHookTimeout = 10 * time.Second
+)
+
+// Hook is an external command run on agent events. The command is run with
+// bash and receives the event as JSON on stdin.
+type Hook struct {
+	// Event is the event the hook is run for.
+	Event HookEventType `json:"event"`
+	// Command is the shell command to run.
+	Command string `json:"command"`
+	// Blocking pre-tool-exec hooks prevent the tool call from running when
+	// they fail. Failures of non-blocking hooks are only logged.
+	Blocking bool `json:"blocking,omitempty"`
+	// TimeoutSeconds bounds the run time of the hook, 10 seconds by default.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// Validate checks that the hook can be run.
+func (h *Hook) Validate() error {
+	switch h.Event {
+	case HookEventPreToolExec:
+	case HookEventPostToolExec, HookEventSessionEnd:
+		if h.Blocking {
+			return fmt.Errorf("hook %q: only %s hooks can be blocking", h.Command, HookEventPreToolExec)
+		}
+	default:
+		return fmt.Errorf("hook %q: unknown event %q, expected one of %s, %s, %s",
+			h.Command, h.Event, HookEventPreToolExec, HookEventPostToolExec, HookEventSessionEnd)
+	}
+	if strings.TrimSpace(h.Command) == "" {
+		return fmt.Errorf("%s hook: command is required", h.Event)
+	}
+	if h.TimeoutSeconds < 0 {
+		return fmt.Errorf("hook %q: timeoutSeconds must not be negative", h.Command)
+	}
+	return nil
+}
+
+// HookEvent is the JSON document passed on stdin to hooks.
+type HookEvent struct {
+	Event     HookEventType `json:"event"`
+	SessionID string        `json:"sessionID,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	// Context is the kubeconfig context of the session, and Environment its
+	// classification, e.g. production, so that hooks can apply stricter
+	// policies to some environments.
+	Context     string `json:"context,omitempty"`
+	Environment string `json:"environment,omitempty"`
+
+	// Tool call events
+	Tool             string         `json:"tool,omitempty"`
+	Arguments        map[string]any `json:"arguments,omitempty"`
+	ModifiesResource string         `json:"modifiesResource,omitempty"`
+	Approval         *api.Approval  `json:"approval,omitempty"`
+	// Output is the result of the tool call, for post-tool-exec events.
+	Output any `json:"output,omitempty"`
+	// Error is the error of the tool call, for post-tool-exec events.
+	Error string `json:"error,omitempty"`
+
+	// Usage summarizes the cluster activity of the session, for on-session-end events.
+	Usage *sessions.Usage `json:"usage,omitempty"`
+}
+
+// toolHookEvent builds the event of a tool call.
+func (c *Agent) toolHookEvent(event HookEventType, call ToolCallAnalysis) *HookEvent {
+	return &HookEvent{
+		Event:            event,
+		SessionID:        c.sessionID(),
+		Timestamp:        time.Now(),
+		Context:          c.kubeContext(),
+		Environment:      c.environment,
+		Tool:             call.FunctionCall.Name,
+		Arguments:        call.FunctionCall.Arguments,
+		ModifiesResource: call.ModifiesResourceStr,
+		Approval:         c.toolCallApproval(call),
+	}
+}
+
+// kubeContext returns the kubeconfig context of the session, if known.
+func (c *Agent) kubeContext() string {
+	if c.usage == nil {
+		return ""
+	}
+	return c.usage.Context
+}
+
+func (c *Agent) sessionID() string {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.session == nil {
+		return ""
+	}
+	return c.session.ID
+}
+
+// runHooks runs the hooks configured for the event. It returns an error only
+// if a blocking hook failed, the failures of other hooks are logged.
+func (c *Agent) runHooks(ctx context.Context, event *HookEvent) error {
+	var input []byte
+	for _, hook := range c.Hooks {
+		if hook.Event != event.Event {
+			continue
+		}
+		if input == nil {
+			var err error
+			if input, err = json.Marshal(event); err != nil {
+				return fmt.Errorf("marshaling %s event: %w", event.Event, err)
+			}
+		}
+		if err := runHook(ctx, hook, input); err != nil {
+			if hook.Blocking {
+				return err
+			}
+			klog.Warningf("%v", err)
+		}
+	}
+	return nil
+}
+
+// runHook runs a single hook with the event on stdin.
+func runHook(ctx context.Context, hook Hook, input []byte) error {
+	timeout := defaultHookTimeout
+	if hook.TimeoutSeconds > 0 {
+		timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", hook.Command)
+	cmd.Env = append(os.Environ(), "KUBECTL_AI_HOOK_EVENT="+string(hook.Event))
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	klog.V(2).Infof("running %s hook %q", hook.Event, hook.Command)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s hook %q timed out after %v", hook.Event, hook.Command, timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s hook %q failed: %w: %s", hook.Event, hook.Command, err, msg)
+		}
+		return fmt.Errorf("%s hook %q failed: %w", hook.Event, hook.Command, err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   []Hook
+		wantErr bool
+		// wantRecorded is whether the event is expected in the file written by the hooks.
+		wantRecorded bool
+	}{
+		{
+			name:         "hook receives the event",
+			hooks:        []Hook{{Event: HookEventPreToolExec, Command: `cat > "$OUT"`}},
+			wantRecorded: true,
+		},
+		{
+			name:  "hooks of other events are not run",
+			hooks: []Hook{{Event: HookEventSessionEnd, Command: `cat > "$OUT"`}},
+		},
+		{
+			name: "non-blocking failure is ignored",
+			hooks: []Hook{
+				{Event: HookEventPreToolExec, Command: "exit 1"},
+				{Event: HookEventPreToolExec, Command: `cat > "$OUT"`},
+			},
+			wantRecorded: true,
+		},
+		{
+			name:    "blocking failure is returned",
+			hooks:   []Hook{{Event: HookEventPreToolExec, Command: "echo denied >&2; exit 1", Blocking: true}},
+			wantErr: true,
+		},
+		{
+			name:    "blocking hook times out",
+			hooks:   []Hook{{Event: HookEventPreToolExec, Command: "sleep 5", Blocking: true, TimeoutSeconds: 1}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "event.json")
+			t.Setenv("OUT", out)
+
+			a := &Agent{Hooks: tt.hooks}
+			event := &HookEvent{
+				Event:     HookEventPreToolExec,
+				Timestamp: time.Now(),
+				Tool:      "kubectl",
+				Arguments: map[string]any{"command": "kubectl delete pod web"},
+			}
+			err := a.runHooks(context.Background(), event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runHooks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			data, err := os.ReadFile(out)
+			if !tt.wantRecorded {
+				if err == nil {
+					t.Errorf("expected no event to be recorded, got %s", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reading recorded event: %v", err)
+			}
+			var got HookEvent
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshaling recorded event %s: %v", data, err)
+			}
+			if got.Event != event.Event || got.Tool != event.Tool || got.Arguments["command"] != event.Arguments["command"] {
+				t.Errorf("recorded event = %+v, want %+v", got, event)
+			}
+		})
+	}
+}
+
+func TestHookValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    Hook
+		wantErr bool
+	}{
+		{name: "valid", hook: Hook{Event: HookEventPostToolExec, Command: "logger"}},
+		{name: "blocking pre-tool-exec", hook: Hook{Event: HookEventPreToolExec, Command: "check", Blocking: true}},
+		{name: "unknown event", hook: Hook{Event: "on-start", Command: "logger"}, wantErr: true},
+		{name: "missing command", hook: Hook{Event: HookEventSessionEnd}, wantErr: true},
+		{name: "blocking post-tool-exec", hook: Hook{Event: HookEventPostToolExec, Command: "check", Blocking: true}, wantErr: true},
+		{name: "negative timeout", hook: Hook{Event: HookEventSessionEnd, Command: "logger", TimeoutSeconds: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hook.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
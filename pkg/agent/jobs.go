@@ -0,0 +1,139 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+const jobUsage = "Usage: job run | job status [NAME]"
+
+// JobRunnerOptions configures the Kubernetes Jobs that run approved
+// remediation plans in the cluster.
+type JobRunnerOptions struct {
+	// Image is the kubectl-ai image run by the jobs. Jobs are disabled if empty.
+	Image string
+	// Namespace of the jobs, the namespace of the current context if empty.
+	Namespace string
+	// ServiceAccount the jobs run as, it needs the permissions required by the plans.
+	ServiceAccount string
+	// Secret holds the credentials of the LLM provider, set as environment
+	// variables of the jobs.
+	Secret string
+}
+
+// remediationJobPrompt is the query of a remediation job.
+const remediationJobPrompt = `You are running unattended as a Kubernetes Job, to carry out the plan below, approved by %s.
+Run the steps of the plan, verify that they succeeded, and report what was done.
+Don't take actions that are not part of the plan; if a step fails, stop and report the failure.
+
+Request of the user:
+%s
+
+Plan:
+%s
+`
+
+// handleJobQuery implements the job meta commands. "job run" packages the
+// last answer of the model as the plan of a remediation job, running the
+// command is the approval of the plan. "job status" reports the status of a
+// job into the session.
+func (c *Agent) handleJobQuery(ctx context.Context, query string) (answer string, handled bool, err error) {
+	fields := strings.Fields(query)
+	if len(fields) < 2 {
+		return jobUsage, true, nil
+	}
+	if c.JobRunner.Image == "" {
+		return "Remediation jobs are not configured, set the kubectl-ai image of the jobs with --job-image.", true, nil
+	}
+	opt := tools.InvokeToolOptions{Kubeconfig: c.Kubeconfig, WorkDir: c.workDir, Env: c.env}
+
+	switch {
+	case fields[1] == "run" && len(fields) == 2:
+		if c.ReadOnly {
+			return "Remediation jobs can't be run: the session is read-only.", true, nil
+		}
+		request, plan := c.lastPlan()
+		if plan == "" {
+			return "There is no plan to run yet, ask for one first.", true, nil
+		}
+		approver := localApprover()
+		job := &tools.RemediationJob{
+			Name:           fmt.Sprintf("kubectl-ai-%s", time.Now().Format("20060102-150405")),
+			Namespace:      c.JobRunner.Namespace,
+			Image:          c.JobRunner.Image,
+			ServiceAccount: c.JobRunner.ServiceAccount,
+			Secret:         c.JobRunner.Secret,
+			Provider:       c.Provider,
+			Model:          c.Model,
+			Plan:           fmt.Sprintf(remediationJobPrompt, approver, request, plan),
+			Approver:       approver,
+			SessionID:      c.sessionID(),
+		}
+		if err := tools.SubmitRemediationJob(ctx, opt, job); err != nil {
+			return fmt.Sprintf("Failed to create the remediation job: %v", err), true, nil
+		}
+		c.jobs = append(c.jobs, job.Name)
+		return fmt.Sprintf("Created job `%s` to run the plan in the cluster. Use `job status` to follow it.", job.Name), true, nil
+	case fields[1] == "status" && len(fields) <= 3:
+		var name string
+		if len(fields) == 3 {
+			name = fields[2]
+		} else if len(c.jobs) > 0 {
+			name = c.jobs[len(c.jobs)-1]
+		} else {
+			return "No job was created in this session. " + jobUsage, true, nil
+		}
+		status, err := tools.RemediationJobStatus(ctx, opt, c.JobRunner.Namespace, name)
+		if err != nil {
+			return fmt.Sprintf("Failed to get the status of job %s: %v", name, err), true, nil
+		}
+		return status, true, nil
+	}
+	return jobUsage, true, nil
+}
+
+// lastPlan returns the last text answer of the model, and the query of the
+// user it answers.
+func (c *Agent) lastPlan() (request, plan string) {
+	c.sessionMu.Lock()
+	messages := c.session.ChatMessageStore.ChatMessages()
+	c.sessionMu.Unlock()
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		message := messages[i]
+		if message.Type != api.MessageTypeText {
+			continue
+		}
+		text, _ := message.Payload.(string)
+		switch message.Source {
+		case api.MessageSourceModel:
+			if plan == "" {
+				plan = text
+			}
+		case api.MessageSourceUser:
+			if plan != "" {
+				return text, plan
+			}
+		}
+	}
+	return "", plan
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestLastPlan(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	for _, m := range []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "the nodes of pool-1 must be replaced"},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get nodes"},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeText, Payload: "1. Cordon the nodes\n2. Drain them"},
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "job status"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeText, Payload: "No job was created in this session."},
+	} {
+		store.AddChatMessage(m)
+	}
+	a := &Agent{session: &api.Session{ChatMessageStore: store}}
+
+	request, plan := a.lastPlan()
+	if request != "the nodes of pool-1 must be replaced" || plan != "1. Cordon the nodes\n2. Drain them" {
+		t.Errorf("lastPlan() = %q, %q", request, plan)
+	}
+}
+
+func TestHandleJobQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		image    string
+		readOnly bool
+		want     string
+	}{
+		{name: "usage", query: "job", image: "kubectl-ai", want: jobUsage},
+		{name: "unknown subcommand", query: "job delete", image: "kubectl-ai", want: jobUsage},
+		{name: "not configured", query: "job run", want: "Remediation jobs are not configured, set the kubectl-ai image of the jobs with --job-image."},
+		{name: "no plan", query: "job run", image: "kubectl-ai", want: "There is no plan to run yet, ask for one first."},
+		{name: "read-only", query: "job run", image: "kubectl-ai", readOnly: true, want: "Remediation jobs can't be run: the session is read-only."},
+		{name: "no job", query: "job status", image: "kubectl-ai", want: "No job was created in this session. " + jobUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Agent{
+				JobRunner: JobRunnerOptions{Image: tt.image},
+				ReadOnly:  tt.readOnly,
+				session:   &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+			}
+			got, handled, err := a.handleJobQuery(context.Background(), tt.query)
+			if err != nil || !handled {
+				t.Fatalf("handleJobQuery(%q) = %v, %v", tt.query, handled, err)
+			}
+			if got != tt.want {
+				t.Errorf("handleJobQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
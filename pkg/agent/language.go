@@ -0,0 +1,145 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// LanguageAuto answers in the language of each query.
+	LanguageAuto = "auto"
+	// LanguageOff lets the model choose the language of the answers.
+	LanguageOff = "off"
+)
+
+// languageInstruction is appended to the queries, for the answers to be in
+// the language of the user without translating what the user runs or types.
+const languageInstruction = "(Answer in %s. Keep kubectl commands, flags, resource names, field names, code and the arguments of tool calls unchanged.)"
+
+// withLanguage appends the language of the answer to a query: the language
+// configured, or the language detected in the query in auto mode. Queries
+// detected in English are unchanged, as are the queries in undetected languages.
+func (c *Agent) withLanguage(query string) string {
+	language := c.Language
+	switch language {
+	case "", LanguageOff:
+		return query
+	case LanguageAuto:
+		language = detectLanguage(query)
+		if language == "" || language == "English" {
+			return query
+		}
+	}
+	return query + "\n\n" + fmt.Sprintf(languageInstruction, language)
+}
+
+// scriptLanguages are the languages detected by their script.
+var scriptLanguages = []struct {
+	language string
+	table    *unicode.RangeTable
+}{
+	// Japanese mixes kana with Han characters: a few kana make it Japanese.
+	{"Japanese", unicode.Hiragana},
+	{"Japanese", unicode.Katakana},
+	{"Chinese", unicode.Han},
+	{"Korean", unicode.Hangul},
+	{"Russian", unicode.Cyrillic},
+	{"Arabic", unicode.Arabic},
+	{"Hebrew", unicode.Hebrew},
+	{"Greek", unicode.Greek},
+	{"Thai", unicode.Thai},
+	{"Hindi", unicode.Devanagari},
+}
+
+// stopwords are frequent words of the languages written in the Latin script.
+var stopwords = map[string][]string{
+	"English":    {"the", "is", "are", "why", "what", "how", "my", "in", "of", "to", "and", "with", "for", "not", "all", "show", "me", "does", "can", "which"},
+	"French":     {"le", "la", "les", "des", "est", "sont", "pourquoi", "quels", "quel", "comment", "mon", "dans", "du", "et", "avec", "pour", "pas", "tous", "affiche", "moi"},
+	"Spanish":    {"el", "los", "las", "es", "son", "por", "qué", "cuáles", "cómo", "mi", "en", "del", "y", "con", "para", "no", "todos", "muestra", "muéstrame", "está"},
+	"German":     {"der", "die", "das", "ist", "sind", "warum", "welche", "wie", "mein", "im", "und", "mit", "für", "nicht", "alle", "zeige", "mir", "läuft", "den", "ein"},
+	"Portuguese": {"o", "os", "as", "é", "são", "por", "que", "quais", "como", "meu", "no", "do", "da", "e", "com", "para", "não", "todos", "mostre", "está"},
+	"Italian":    {"il", "gli", "le", "è", "sono", "perché", "quali", "come", "mio", "nel", "del", "della", "e", "con", "per", "non", "tutti", "mostra", "mostrami", "sta"},
+	"Dutch":      {"de", "het", "een", "is", "zijn", "waarom", "welke", "hoe", "mijn", "in", "van", "en", "met", "voor", "niet", "alle", "toon", "mij", "draait", "wat"},
+}
+
+// codePattern matches the parts of a query that are not prose: code blocks,
+// inline code and command lines.
+var codePattern = regexp.MustCompile("(?s:```.*?```)|`[^`]*`|(?m:^\\s*(kubectl|helm|\\$)\\s.*$)")
+
+// detectLanguage returns the English name of the language of a query, or ""
+// if it is unsure. Languages with their own script are detected by their
+// script, those written in the Latin script by their frequent words.
+func detectLanguage(query string) string {
+	prose := codePattern.ReplaceAllString(query, " ")
+
+	scripts := map[string]int{}
+	for _, r := range prose {
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				scripts[s.language]++
+				break
+			}
+		}
+	}
+	// Resource names and technical terms are in English in any language,
+	// a few characters of another script are enough.
+	if scripts["Japanese"] >= 2 {
+		return "Japanese"
+	}
+	best, bestCount := "", 0
+	for _, s := range scriptLanguages {
+		if count := scripts[s.language]; count >= 2 && count > bestCount {
+			best, bestCount = s.language, count
+		}
+	}
+	if best == "Russian" && strings.ContainsAny(prose, "іїєґІЇЄҐ") {
+		return "Ukrainian"
+	}
+	if best != "" {
+		return best
+	}
+
+	scores := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(prose), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for language, words := range stopwords {
+			for _, w := range words {
+				if word == w {
+					scores[language]++
+					break
+				}
+			}
+		}
+	}
+	best, bestCount = "", 0
+	tie := false
+	for language, score := range scores {
+		switch {
+		case score > bestCount:
+			best, bestCount, tie = language, score, false
+		case score == bestCount:
+			tie = true
+		}
+	}
+	if bestCount < 2 || tie {
+		return ""
+	}
+	return best
+}
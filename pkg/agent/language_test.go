@@ -0,0 +1,73 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := map[string]string{
+		"why is my pod crashing in the default namespace?":                  "English",
+		"pourquoi mon pod est en CrashLoopBackOff dans le namespace prod ?": "French",
+		"¿por qué los pods del deployment web no están listos?":             "Spanish",
+		"warum ist der Pod nicht bereit und wie behebe ich das?":            "German",
+		"por que o pod não está pronto no namespace default?":               "Portuguese",
+		"perché il pod non è pronto nel namespace default?":                 "Italian",
+		"waarom draait mijn pod niet?":                                      "Dutch",
+		"为什么我的 pod 一直重启？":                                                   "Chinese",
+		"nginx の pod が起動しない理由は？":                                            "Japanese",
+		"왜 nginx pod가 재시작되나요?":                                              "Korean",
+		"почему под nginx не запускается?":                                  "Russian",
+		"чому под nginx не запускається? він у стані CrashLoopBackOff":      "Ukrainian",
+		"kubectl get pods": "",
+		"pods":             "",
+		"Explique le résultat:\n```\nthe pod is not ready and the image is missing\n```": "",
+		"Explique-moi l'erreur de `kubectl describe pod web` pour le pod":                "French",
+	}
+	for query, want := range tests {
+		if got := detectLanguage(query); got != want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestWithLanguage(t *testing.T) {
+	french := "pourquoi mon pod est en CrashLoopBackOff ?"
+	english := "why is my pod crashing?"
+	tests := []struct {
+		language string
+		query    string
+		want     string
+	}{
+		{LanguageAuto, french, "Answer in French."},
+		{LanguageAuto, english, ""},
+		{LanguageOff, french, ""},
+		{"", french, ""},
+		{"German", english, "Answer in German."},
+	}
+	for _, tt := range tests {
+		a := &Agent{Language: tt.language}
+		got := a.withLanguage(tt.query)
+		if !strings.HasPrefix(got, tt.query) {
+			t.Errorf("withLanguage(%q) = %q, want the query first", tt.query, got)
+		}
+		instruction := strings.TrimSpace(strings.TrimPrefix(got, tt.query))
+		if tt.want == "" && instruction != "" || !strings.Contains(instruction, tt.want) {
+			t.Errorf("Language %q: withLanguage(%q) added %q, want %q", tt.language, tt.query, instruction, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+)
+
+// maxLLMCalls is the number of LLM calls kept for the "stats" meta command.
+const maxLLMCalls = 1000
+
+// llmCall is the latency and throughput of an LLM call.
+type llmCall struct {
+	Provider string
+	Model    string
+	// TimeToFirstToken is the time from the request to the first text or
+	// function call of the response.
+	TimeToFirstToken time.Duration
+	Duration         time.Duration
+	OutputTokens     int
+	// TokensEstimated is true when the provider didn't report the usage,
+	// OutputTokens is then estimated from the length of the text.
+	TokensEstimated bool
+	// TokensPerSecond is the output throughput after the first token, zero
+	// when the response came in a single chunk.
+	TokensPerSecond float64
+}
+
+// callTimer measures an LLM call, from the request to the end of its
+// streamed response.
+type callTimer struct {
+	now        func() time.Time
+	start      time.Time
+	firstToken time.Time
+	usage      *gollm.Usage
+	chars      int
+}
+
+// newCallTimer starts measuring an LLM call, before it is sent.
+func newCallTimer() *callTimer {
+	return &callTimer{now: time.Now, start: time.Now()}
+}
+
+// Observe wraps the response stream of the call to time its chunks.
+func (t *callTimer) Observe(stream gollm.ChatResponseIterator) gollm.ChatResponseIterator {
+	return func(yield func(gollm.ChatResponse, error) bool) {
+		for response, err := range stream {
+			if err == nil && response != nil {
+				t.observe(response)
+			}
+			if !yield(response, err) {
+				return
+			}
+		}
+	}
+}
+
+func (t *callTimer) observe(response gollm.ChatResponse) {
+	if usage := gollm.ResponseUsage(response); usage != nil {
+		t.usage = usage
+	}
+	for _, candidate := range response.Candidates() {
+		for _, part := range candidate.Parts() {
+			text, isText := part.AsText()
+			calls, _ := part.AsFunctionCalls()
+			if (isText && text != "" || len(calls) > 0) && t.firstToken.IsZero() {
+				t.firstToken = t.now()
+			}
+			t.chars += len(text)
+		}
+		// Only the first candidate is used.
+		break
+	}
+}
+
+// End returns the measures of the call, once its response was read.
+func (t *callTimer) End(provider, model string) llmCall {
+	end := t.now()
+	call := llmCall{Provider: provider, Model: model, Duration: end.Sub(t.start), TimeToFirstToken: end.Sub(t.start)}
+	if !t.firstToken.IsZero() {
+		call.TimeToFirstToken = t.firstToken.Sub(t.start)
+	}
+	if t.usage != nil {
+		call.OutputTokens = t.usage.OutputTokens
+	} else {
+		call.OutputTokens = t.chars / charsPerToken
+		call.TokensEstimated = true
+	}
+	if generation := call.Duration - call.TimeToFirstToken; generation > 10*time.Millisecond {
+		call.TokensPerSecond = float64(call.OutputTokens) / generation.Seconds()
+	}
+	return call
+}
+
+// recordLLMCall records an LLM call in the journal, and for the "stats" meta command.
+func (c *Agent) recordLLMCall(ctx context.Context, call llmCall) {
+	journal.RecorderFromContext(ctx).Write(ctx, &journal.Event{
+		Timestamp: time.Now(),
+		Action:    journal.ActionLLMCall,
+		Payload: map[string]any{
+			"provider":           call.Provider,
+			"model":              call.Model,
+			"timeToFirstTokenMs": call.TimeToFirstToken.Milliseconds(),
+			"durationMs":         call.Duration.Milliseconds(),
+			"outputTokens":       call.OutputTokens,
+			"tokensEstimated":    call.TokensEstimated,
+			"tokensPerSecond":    math.Round(call.TokensPerSecond*10) / 10,
+		},
+	})
+	c.llmCalls = append(c.llmCalls, call)
+	if len(c.llmCalls) > maxLLMCalls {
+		c.llmCalls = c.llmCalls[len(c.llmCalls)-maxLLMCalls:]
+	}
+}
+
+// handleStatsQuery implements the "stats" meta command, which shows the
+// percentiles of the latency and throughput of the LLM calls of the session,
+// by provider and model.
+func (c *Agent) handleStatsQuery() string {
+	if len(c.llmCalls) == 0 {
+		return "No LLM calls were made yet."
+	}
+	type key struct{ provider, model string }
+	var keys []key
+	calls := map[key][]llmCall{}
+	for _, call := range c.llmCalls {
+		k := key{call.Provider, call.Model}
+		if _, ok := calls[k]; !ok {
+			keys = append(keys, k)
+		}
+		calls[k] = append(calls[k], call)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("```text\n")
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tMODEL\tCALLS\tFIRST TOKEN p50/p90/p99\tTOKENS/S p50/p10")
+	for _, k := range keys {
+		var firstTokens []time.Duration
+		var throughputs []float64
+		for _, call := range calls[k] {
+			firstTokens = append(firstTokens, call.TimeToFirstToken)
+			if call.TokensPerSecond > 0 {
+				throughputs = append(throughputs, call.TokensPerSecond)
+			}
+		}
+		slices.Sort(firstTokens)
+		slices.Sort(throughputs)
+		throughput := "-"
+		if len(throughputs) > 0 {
+			throughput = fmt.Sprintf("%.0f/%.0f", percentile(throughputs, 50), percentile(throughputs, 10))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s/%s/%s\t%s\n", k.provider, k.model, len(calls[k]),
+			formatLatency(percentile(firstTokens, 50)), formatLatency(percentile(firstTokens, 90)), formatLatency(percentile(firstTokens, 99)), throughput)
+	}
+	tw.Flush()
+	sb.WriteString("```\n")
+	sb.WriteString("Throughputs are the output tokens per second after the first token, the 10th percentile being the slowest calls.")
+	return sb.String()
+}
+
+// percentile returns the p-th percentile of sorted values, with the nearest-rank method.
+func percentile[T time.Duration | float64](sorted []T, p int) T {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// formatLatency formats a latency, e.g. 850ms or 2.4s.
+func formatLatency(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+func TestCallTimer(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	timer := &callTimer{now: func() time.Time { return now }, start: now}
+
+	// The first chunk, without text, doesn't count as the first token.
+	responses := []*fakeResponse{{}, {text: "hello"}, {text: " world", usage: &gollm.Usage{OutputTokens: 40}}}
+	for range timer.Observe(streamOf(responses...)) {
+		now = now.Add(500 * time.Millisecond)
+	}
+	got := timer.End("gemini", "gemini-2.5-flash")
+	want := llmCall{Provider: "gemini", Model: "gemini-2.5-flash", TimeToFirstToken: 500 * time.Millisecond, Duration: 1500 * time.Millisecond, OutputTokens: 40, TokensPerSecond: 40}
+	if got != want {
+		t.Errorf("End() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHandleStatsQuery(t *testing.T) {
+	a := &Agent{}
+	if got := a.handleStatsQuery(); !strings.Contains(got, "No LLM calls") {
+		t.Errorf("handleStatsQuery() without calls = %q", got)
+	}
+	for i := 1; i <= 10; i++ {
+		a.recordLLMCall(context.Background(), llmCall{Provider: "openai", Model: "gpt-4.1", TimeToFirstToken: time.Duration(i) * 100 * time.Millisecond, TokensPerSecond: float64(10 * i)})
+	}
+	a.recordLLMCall(context.Background(), llmCall{Provider: "ollama", Model: "qwen3", TimeToFirstToken: 2500 * time.Millisecond})
+
+	got := a.handleStatsQuery()
+	for _, want := range []string{"openai    gpt-4.1  10     500ms/900ms/1.0s         50/10", "ollama    qwen3    1      2.5s/2.5s/2.5s           -"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("handleStatsQuery() = %q, want a line %q", got, want)
+		}
+	}
+}
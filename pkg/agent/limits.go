@@ -0,0 +1,83 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// progressSummaryTimeout bounds the request summarizing the progress of a
+// query stopped by a limit.
+const progressSummaryTimeout = time.Minute
+
+// progressSummaryPrompt asks the model to summarize the progress of a query
+// stopped by a limit. %s is the limit.
+const progressSummaryPrompt = `The investigation was stopped: %s
+Do not call any tool. Summarize for the user what you found so far, what you
+changed in the cluster if anything, and what remains to be done to complete
+the request, e.g. the next commands to run.`
+
+// queryLimit returns the limit the current query reached, if any: the
+// maximum number of iterations, the maximum duration, or the maximum cost.
+func (c *Agent) queryLimit() string {
+	if c.currIteration >= c.MaxIterations {
+		return fmt.Sprintf("the maximum number of iterations (%d) was reached.", c.MaxIterations)
+	}
+	if c.MaxDuration > 0 && time.Since(c.queryStart) >= c.MaxDuration {
+		return fmt.Sprintf("the maximum duration (%s) was reached.", c.MaxDuration)
+	}
+	if c.maxCostReached() {
+		return fmt.Sprintf("the maximum cost of the session ($%.2f) was reached, its LLM calls cost an estimated $%.2f.", c.MaxCost, c.tokens.cost())
+	}
+	return ""
+}
+
+// stopAtLimit ends the current query, which reached a limit, with a summary
+// of the progress made so far by the model, instead of leaving the user with
+// an unfinished investigation.
+func (c *Agent) stopAtLimit(ctx context.Context, limit string) {
+	c.setAgentState(api.AgentStateDone)
+	c.pendingFunctionCalls = []ToolCallAnalysis{}
+	message := "Stopped: " + limit
+
+	// Summarizing would exceed the maximum cost further.
+	if c.llmChat == nil || c.currIteration == 0 || c.maxCostReached() {
+		c.currChatContent = nil
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeText, message)
+		return
+	}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, message+" Summarizing the progress so far...")
+	c.sendProgress(api.ProgressPhaseThinking, "")
+
+	ctx, cancel := context.WithTimeout(ctx, progressSummaryTimeout)
+	defer cancel()
+	// The results of the last tool calls are sent with the request.
+	contents := append(c.currChatContent, fmt.Sprintf(progressSummaryPrompt, limit))
+	c.currChatContent = nil
+	response, err := c.llmChat.Send(ctx, contents...)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "error summarizing the progress of the query")
+		return
+	}
+	if summary := strings.TrimSpace(responseText(response)); summary != "" {
+		c.addMessage(api.MessageSourceModel, api.MessageTypeText, summary)
+	}
+}
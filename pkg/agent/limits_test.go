@@ -0,0 +1,126 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"go.uber.org/mock/gomock"
+)
+
+func TestQueryLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		iteration   int
+		maxDuration time.Duration
+		elapsed     time.Duration
+		maxCost     float64
+		cost        float64
+		want        string
+	}{
+		{
+			name:      "within limits",
+			iteration: 3,
+			elapsed:   time.Hour,
+		},
+		{
+			name:      "iterations",
+			iteration: 10,
+			want:      "the maximum number of iterations (10) was reached.",
+		},
+		{
+			name:        "duration",
+			iteration:   3,
+			maxDuration: 5 * time.Minute,
+			elapsed:     6 * time.Minute,
+			want:        "the maximum duration (5m0s) was reached.",
+		},
+		{
+			name:      "within budget",
+			iteration: 3,
+			maxCost:   1,
+			cost:      0.5,
+		},
+		{
+			name:      "cost",
+			iteration: 3,
+			maxCost:   1,
+			cost:      1.25,
+			want:      "the maximum cost of the session ($1.00) was reached, its LLM calls cost an estimated $1.25.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Agent{
+				MaxIterations: 10,
+				MaxDuration:   tt.maxDuration,
+				MaxCost:       tt.maxCost,
+				currIteration: tt.iteration,
+				queryStart:    time.Now().Add(-tt.elapsed),
+			}
+			a.tokens.reset([]sessions.TokenUsage{{Provider: "gemini", Model: "gemini-2.5-pro", Cost: tt.cost}})
+			if got := a.queryLimit(); got != tt.want {
+				t.Errorf("queryLimit() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopAtLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	result := gollm.FunctionCallResult{ID: "call-1", Name: "kubectl", Result: map[string]any{"stdout": "web-1   0/1   CrashLoopBackOff"}}
+	chat := mocks.NewMockChat(ctrl)
+	chat.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, contents ...any) (gollm.ChatResponse, error) {
+		if len(contents) != 2 || !reflect.DeepEqual(contents[0], result) {
+			t.Errorf("summary request doesn't start with the pending tool result: %v", contents)
+		}
+		if prompt, _ := contents[len(contents)-1].(string); !strings.Contains(prompt, "the maximum duration (5m0s) was reached.") {
+			t.Errorf("summary prompt doesn't mention the limit: %q", prompt)
+		}
+		return &fakeResponse{text: "web-1 is crashing because of a missing secret. Next: create the secret."}, nil
+	})
+
+	a := &Agent{
+		MaxIterations:   10,
+		llmChat:         chat,
+		session:         &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:          make(chan any, 10),
+		currIteration:   4,
+		currChatContent: []any{result},
+	}
+	a.setAgentState(api.AgentStateRunning)
+	a.stopAtLimit(context.Background(), "the maximum duration (5m0s) was reached.")
+
+	if got := a.AgentState(); got != api.AgentStateDone {
+		t.Errorf("agent state = %v, want %v", got, api.AgentStateDone)
+	}
+	if len(a.currChatContent) != 0 {
+		t.Errorf("pending chat content wasn't sent: %v", a.currChatContent)
+	}
+	messages := a.session.ChatMessageStore.ChatMessages()
+	last := messages[len(messages)-1]
+	if last.Source != api.MessageSourceModel || !strings.HasPrefix(last.Payload.(string), "web-1 is crashing") {
+		t.Errorf("last message = %v, want the summary of the model", last.Payload)
+	}
+}
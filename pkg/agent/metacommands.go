@@ -0,0 +1,204 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+// MetaCommand is a command handled by kubectl-ai itself instead of the model,
+// e.g. "models" or "tag add TAG". The catalog of the meta commands is listed
+// by "help", completed in the terminal, and offered by the command palette of
+// the web UI, so that the UIs stay in sync with the commands handled.
+type MetaCommand struct {
+	// Name is the command, e.g. "tag add".
+	Name string `json:"name"`
+	// Args describes the arguments following the name, e.g. "TAG", empty if
+	// the command takes none.
+	Args string `json:"args,omitempty"`
+	// Aliases are the other names of the command, e.g. "quit" for "exit".
+	Aliases     []string `json:"aliases,omitempty"`
+	Description string   `json:"description"`
+	// Query is the query an alias configured by the user stands for, empty
+	// for the built-in commands.
+	Query string `json:"query,omitempty"`
+	// TerminalOnly is set for the commands that only make sense in a
+	// terminal, e.g. "exit".
+	TerminalOnly bool `json:"terminalOnly,omitempty"`
+}
+
+// metaCommands are the built-in meta commands, as handled by handleMetaQuery,
+// runSnippet and undoLastChange.
+var metaCommands = []MetaCommand{
+	{Name: "help", Description: "List the meta commands"},
+	{Name: "model", Description: "Show the current model"},
+	{Name: "model", Args: "NAME", Description: "Switch to another model, keeping the conversation"},
+	{Name: "models", Description: "List the available models"},
+	{Name: "tools", Description: "List the available tools"},
+	{Name: "new-tool", Aliases: []string{"/new-tool"}, Description: "Create a custom tool wrapping a command"},
+	{Name: "usage", Aliases: []string{"cost"}, Description: "Show the tokens used by the session and their estimated cost"},
+	{Name: "stats", Description: "Show the latency and the throughput of the LLM calls"},
+	{Name: "env", Description: "Show the working directory and the environment variables of the tools"},
+	{Name: "env set", Args: "NAME=VALUE", Description: "Set an environment variable of the tools"},
+	{Name: "env unset", Args: "NAME", Description: "Unset an environment variable of the tools"},
+	{Name: "notes", Description: "Show the notes pinned to the session"},
+	{Name: "note add", Args: "TEXT", Description: "Pin a note to the session"},
+	{Name: "note remove", Args: "N", Description: "Remove the note #N"},
+	{Name: "tags", Description: "Show the tags of the session"},
+	{Name: "tag add", Args: "TAG", Description: "Tag the session"},
+	{Name: "tag remove", Args: "TAG", Description: "Remove a tag of the session"},
+	{Name: "run", Args: "N", Aliases: []string{"/run"}, Description: "Run the snippet #N of the last answer"},
+	{Name: "undo-last-change", Aliases: []string{"/undo-last-change"}, Description: "Undo the last change applied with apply_manifest"},
+	{Name: "job run", Description: "Run the last plan as a Kubernetes Job"},
+	{Name: "job status", Args: "[NAME]", Description: "Follow a remediation job"},
+	{Name: "fanout", Args: "namespaces|contexts NAMES|all QUESTION", Description: "Investigate a question in each namespace or cluster"},
+	{Name: "watches", Description: "List the running watches"},
+	{Name: "watches cancel", Args: "ID", Description: "Stop a watch"},
+	{Name: "session", Description: "Show the current session"},
+	{Name: "sessions", Description: "List the saved sessions"},
+	{Name: "save-session", Description: "Save the conversation as a new session"},
+	{Name: "new-session", Description: "Start a new session, saved if the current one is"},
+	{Name: "resume-session", Args: "ID", Description: "Resume a saved session"},
+	{Name: "reset", Aliases: []string{"clear"}, Description: "Clear the conversation"},
+	{Name: "exit", Aliases: []string{"quit"}, Description: "Exit kubectl-ai", TerminalOnly: true},
+}
+
+// MetaCommands returns the catalog of the meta commands, followed by the
+// aliases configured.
+func (c *Agent) MetaCommands() []MetaCommand {
+	commands := slices.Clone(metaCommands)
+	names := make([]string, 0, len(c.Aliases))
+	for name := range c.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		commands = append(commands, MetaCommand{Name: name, Description: "Alias of: " + c.Aliases[name], Query: c.Aliases[name]})
+	}
+	return commands
+}
+
+// Usage returns the command as typed, e.g. "tag add TAG".
+func (m MetaCommand) Usage() string {
+	return strings.TrimSpace(m.Name + " " + m.Args)
+}
+
+// ValidateAliases checks that the aliases are single words, and don't hide a
+// meta command.
+func ValidateAliases(aliases map[string]string) error {
+	for name, query := range aliases {
+		if name == "" || strings.ContainsAny(name, " \t\n") || strings.HasPrefix(name, "/") {
+			return fmt.Errorf("alias %q: names must be single words, without a leading /", name)
+		}
+		if isMetaCommand(name) {
+			return fmt.Errorf("alias %q: it is a meta command", name)
+		}
+		if strings.TrimSpace(query) == "" {
+			return fmt.Errorf("alias %q: the query is empty", name)
+		}
+	}
+	return nil
+}
+
+// isMetaCommand reports whether name is the first word of a meta command.
+func isMetaCommand(name string) bool {
+	for _, command := range metaCommands {
+		for _, n := range append([]string{command.Name}, command.Aliases...) {
+			if first, _, _ := strings.Cut(n, " "); strings.TrimPrefix(first, "/") == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandAlias returns the query an alias stands for, typed with or without a
+// leading /.
+func (c *Agent) expandAlias(query string) (string, bool) {
+	expansion, ok := c.Aliases[strings.TrimPrefix(strings.TrimSpace(query), "/")]
+	return expansion, ok
+}
+
+// metaCommandsHelp lists the meta commands, for the "help" meta command.
+func (c *Agent) metaCommandsHelp() string {
+	commands := c.MetaCommands()
+	width := 0
+	for _, command := range commands {
+		width = max(width, len(command.Usage()))
+	}
+	var sb strings.Builder
+	sb.WriteString("Meta commands:\n\n```text\n")
+	for _, command := range commands {
+		description := command.Description
+		if len(command.Aliases) > 0 {
+			description += " (or " + strings.Join(command.Aliases, ", ") + ")"
+		}
+		fmt.Fprintf(&sb, "%-*s  %s\n", width, command.Usage(), description)
+	}
+	sb.WriteString("```")
+	return sb.String()
+}
+
+// switchModel restarts the chat with another model, replaying the messages of
+// the session.
+func (c *Agent) switchModel(model string) (string, error) {
+	if err := c.startChat(model); err != nil {
+		return "", err
+	}
+	if err := c.setFunctionDefinitions(); err != nil {
+		return "", err
+	}
+	c.Model = model
+	answer := "Switched to model `" + model + "`."
+	if c.Router != nil {
+		answer += " The model router still selects the model of each query."
+	}
+	return answer, nil
+}
+
+// newSession starts a new session: a new saved session if the current one is
+// saved, the conversation is cleared otherwise.
+func (c *Agent) newSession(ctx context.Context) (string, error) {
+	if _, ok := c.ChatMessageStore.(*sessions.Session); !ok {
+		answer, _, err := c.handleMetaQuery(ctx, "reset")
+		return answer, err
+	}
+	manager, err := sessions.NewSessionManager()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session manager: %w", err)
+	}
+	now := time.Now()
+	session, err := manager.NewSession(sessions.Metadata{
+		CreatedAt:    now,
+		LastAccessed: now,
+		ModelID:      c.Model,
+		ProviderID:   c.Provider,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create new session: %w", err)
+	}
+	if err := c.loadSession(session.ID, false); err != nil {
+		return "", err
+	}
+	c.snippets = nil
+	return "Started session " + session.ID + ".", nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMetaCommandsAliases(t *testing.T) {
+	a := &Agent{Aliases: map[string]string{"restarts": "list the pods restarting", "errors": "show the recent warning events"}}
+	commands := a.MetaCommands()
+	if len(commands) != len(metaCommands)+2 {
+		t.Fatalf("MetaCommands() returned %d commands, want the %d built-in ones and 2 aliases", len(commands), len(metaCommands))
+	}
+	if aliases := commands[len(metaCommands):]; aliases[0].Name != "errors" || aliases[1].Name != "restarts" || aliases[1].Query != "list the pods restarting" {
+		t.Errorf("aliases = %+v, want them sorted by name with their query", aliases)
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  string
+		ok    bool
+	}{
+		{"restarts", "list the pods restarting", true},
+		{" /restarts ", "list the pods restarting", true},
+		{"restarts now", "", false},
+		{"models", "", false},
+	} {
+		if got, ok := a.expandAlias(tc.query); got != tc.want || ok != tc.ok {
+			t.Errorf("expandAlias(%q) = %q, %v, want %q, %v", tc.query, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	help := a.metaCommandsHelp()
+	for _, want := range []string{"tag add TAG", "usage", "(or cost)", "restarts", "Alias of: list the pods restarting"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help doesn't contain %q:\n%s", want, help)
+		}
+	}
+}
+
+func TestValidateAliases(t *testing.T) {
+	if err := ValidateAliases(map[string]string{"restarts": "list the pods restarting"}); err != nil {
+		t.Errorf("ValidateAliases() = %v", err)
+	}
+	for _, aliases := range []map[string]string{
+		{"two words": "get pods"},
+		{"/restarts": "get pods"},
+		{"models": "get pods"},
+		{"tag": "get pods"},
+		{"undo-last-change": "get pods"},
+		{"restarts": " "},
+	} {
+		if err := ValidateAliases(aliases); err == nil {
+			t.Errorf("ValidateAliases(%v) accepted invalid aliases", aliases)
+		}
+	}
+}
+
+func TestSwitchModel(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	store := sessions.NewInMemoryChatStore()
+	_ = store.AddChatMessage(&api.Message{ID: "u1", Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "get pods"})
+
+	// The chat of the new model is given the messages of the session, and the tools.
+	llm := mocks.NewMockClient(ctrl)
+	chat := mocks.NewMockChat(ctrl)
+	chat.EXPECT().Initialize([]*gollm.Message{{Role: gollm.RoleUser, Content: "get pods"}}).Return(nil)
+	chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil)
+	llm.EXPECT().StartChat("system prompt", "gemini-2.5-flash").Return(chat)
+
+	a := &Agent{
+		LLM:          llm,
+		Model:        "gemini-2.5-pro",
+		systemPrompt: "system prompt",
+		chatModel:    "gemini-2.5-pro",
+		session:      &api.Session{ChatMessageStore: store},
+	}
+	answer, handled, err := a.handleMetaQuery(ctx, "model gemini-2.5-flash")
+	if err != nil || !handled {
+		t.Fatalf("handleMetaQuery(model gemini-2.5-flash) = %q, %v, %v", answer, handled, err)
+	}
+	if a.Model != "gemini-2.5-flash" || a.chatModel != "gemini-2.5-flash" {
+		t.Errorf("model = %q, chat model = %q, want gemini-2.5-flash", a.Model, a.chatModel)
+	}
+	if answer, _, _ := a.handleMetaQuery(ctx, "model"); !strings.Contains(answer, "gemini-2.5-flash") {
+		t.Errorf("model = %q, want the new model", answer)
+	}
+}
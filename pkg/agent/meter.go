@@ -0,0 +1,198 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/google/uuid"
+)
+
+// meterInterval is how often the meter is sent to the UI while the model responds.
+const meterInterval = 500 * time.Millisecond
+
+// charsPerToken is used to estimate the output tokens until the provider
+// reports the usage, which some providers only do at the end of the stream.
+const charsPerToken = 4
+
+// TokenPrices are the prices of the tokens of the model, used to estimate
+// the cost of responses.
+type TokenPrices struct {
+	// Input is the price in USD of one million input tokens.
+	Input float64
+	// Output is the price in USD of one million output tokens.
+	Output float64
+}
+
+// streamMeter measures a model response while it is streamed, and sends
+// MessageTypeStreamStats snapshots to the UI.
+//
+// A response cut off by the output token limit and continued spans several
+// requests; their usage is added up.
+type streamMeter struct {
+	output chan any
+	prices TokenPrices
+	now    func() time.Time
+
+	// streamID is shared by all the snapshots of the same response.
+	streamID string
+	start    time.Time
+
+	mu sync.Mutex
+	// previous is the usage of the requests of the response before the current one.
+	previous gollm.Usage
+	// previousEstimated is true when the usage of a previous request wasn't reported.
+	previousEstimated bool
+	current           *gollm.Usage
+	currentChars      int
+	iteration         int
+	maxIterations     int
+
+	stopTicker chan struct{}
+	tickerDone chan struct{}
+}
+
+func newStreamMeter(output chan any, prices TokenPrices, maxIterations int) *streamMeter {
+	return &streamMeter{
+		output:        output,
+		prices:        prices,
+		now:           time.Now,
+		streamID:      uuid.New().String(),
+		maxIterations: maxIterations,
+	}
+}
+
+// Begin starts measuring a request of the response, and periodically sends
+// the meter to the UI until End is called.
+func (m *streamMeter) Begin(iteration int) {
+	m.mu.Lock()
+	if m.start.IsZero() {
+		m.start = m.now()
+	}
+	if m.current != nil {
+		m.previous.InputTokens += m.current.InputTokens
+		m.previous.OutputTokens += m.current.OutputTokens
+		m.previous.TotalTokens += m.current.TotalTokens
+	} else if m.currentChars > 0 {
+		m.previous.OutputTokens += m.currentChars / charsPerToken
+		m.previousEstimated = true
+	}
+	m.current = nil
+	m.currentChars = 0
+	m.iteration = iteration
+	m.mu.Unlock()
+
+	m.stopTicker = make(chan struct{})
+	m.tickerDone = make(chan struct{})
+	go func() {
+		defer close(m.tickerDone)
+		ticker := time.NewTicker(meterInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopTicker:
+				return
+			case <-ticker.C:
+				m.send()
+			}
+		}
+	}()
+}
+
+// Observe wraps a response stream to count the tokens of the responses.
+func (m *streamMeter) Observe(stream gollm.ChatResponseIterator) gollm.ChatResponseIterator {
+	return func(yield func(gollm.ChatResponse, error) bool) {
+		for response, err := range stream {
+			if err == nil && response != nil {
+				m.observe(response)
+			}
+			if !yield(response, err) {
+				return
+			}
+		}
+	}
+}
+
+func (m *streamMeter) observe(response gollm.ChatResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if usage := gollm.ResponseUsage(response); usage != nil {
+		m.current = usage
+	}
+	for _, candidate := range response.Candidates() {
+		for _, part := range candidate.Parts() {
+			if text, ok := part.AsText(); ok {
+				m.currentChars += len(text)
+			}
+		}
+		// Only the first candidate is used.
+		break
+	}
+}
+
+// End stops sending the meter to the UI, sends the final snapshot of the
+// request and returns it.
+func (m *streamMeter) End() *api.StreamStats {
+	if m.stopTicker != nil {
+		close(m.stopTicker)
+		<-m.tickerDone
+		m.stopTicker = nil
+	}
+	return m.send()
+}
+
+// send sends a snapshot to the UI without blocking, a slow UI skips snapshots.
+func (m *streamMeter) send() *api.StreamStats {
+	stats := m.Stats()
+	message := &api.Message{
+		ID:        m.streamID,
+		Source:    api.MessageSourceAgent,
+		Type:      api.MessageTypeStreamStats,
+		Payload:   stats,
+		Timestamp: m.now(),
+	}
+	select {
+	case m.output <- message:
+	default:
+	}
+	return stats
+}
+
+// Stats returns a snapshot of the stats of the response.
+func (m *streamMeter) Stats() *api.StreamStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := &api.StreamStats{
+		Elapsed:         m.now().Sub(m.start),
+		InputTokens:     m.previous.InputTokens,
+		OutputTokens:    m.previous.OutputTokens,
+		Iteration:       m.iteration,
+		MaxIterations:   m.maxIterations,
+		TokensEstimated: m.previousEstimated,
+	}
+	if m.current != nil {
+		stats.InputTokens += m.current.InputTokens
+		stats.OutputTokens += m.current.OutputTokens
+	} else {
+		stats.OutputTokens += m.currentChars / charsPerToken
+		stats.TokensEstimated = true
+	}
+	stats.Cost = (float64(stats.InputTokens)*m.prices.Input + float64(stats.OutputTokens)*m.prices.Output) / 1e6
+	return stats
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestStreamMeter(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams [][]*fakeResponse
+		want    *api.StreamStats
+	}{
+		{
+			name: "usage reported at the end",
+			streams: [][]*fakeResponse{
+				{{text: "0123456789"}, {usage: &gollm.Usage{InputTokens: 1000, OutputTokens: 20, TotalTokens: 1020}}},
+			},
+			want: &api.StreamStats{Elapsed: time.Second, InputTokens: 1000, OutputTokens: 20, Cost: 0.0014, Iteration: 2, MaxIterations: 20},
+		},
+		{
+			name: "usage not reported",
+			streams: [][]*fakeResponse{
+				{{text: "0123456789"}, {text: "0123456789"}},
+			},
+			want: &api.StreamStats{Elapsed: time.Second, OutputTokens: 5, TokensEstimated: true, Cost: 0.0001, Iteration: 2, MaxIterations: 20},
+		},
+		{
+			name: "continued response",
+			streams: [][]*fakeResponse{
+				{{text: "part 1", usage: &gollm.Usage{InputTokens: 1000, OutputTokens: 10, TotalTokens: 1010}}},
+				{{text: "part 2", usage: &gollm.Usage{InputTokens: 1010, OutputTokens: 10, TotalTokens: 1020}}},
+			},
+			want: &api.StreamStats{Elapsed: 2 * time.Second, InputTokens: 2010, OutputTokens: 20, Cost: 0.00241, Iteration: 2, MaxIterations: 20},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			output := make(chan any, 10)
+			m := newStreamMeter(output, TokenPrices{Input: 1, Output: 20}, 20)
+			now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			m.now = func() time.Time { return now }
+
+			var got *api.StreamStats
+			for _, responses := range tc.streams {
+				m.Begin(2)
+				for range m.Observe(streamOf(responses...)) {
+				}
+				now = now.Add(time.Second)
+				got = m.End()
+			}
+
+			// Costs are compared separately to allow for floating point rounding.
+			if diff := got.Cost - tc.want.Cost; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("expected cost %v, got %v", tc.want.Cost, got.Cost)
+			}
+			got.Cost, tc.want.Cost = 0, 0
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected stats %+v, got %+v", tc.want, got)
+			}
+
+			last := (<-output).(*api.Message)
+			for len(output) > 0 {
+				last = (<-output).(*api.Message)
+			}
+			if last.Type != api.MessageTypeStreamStats {
+				t.Errorf("expected a %q message, got %q", api.MessageTypeStreamStats, last.Type)
+			}
+		})
+	}
+}
+
+func TestStreamStatsString(t *testing.T) {
+	tests := []struct {
+		stats *api.StreamStats
+		want  string
+	}{
+		{
+			stats: &api.StreamStats{Elapsed: 3200 * time.Millisecond, InputTokens: 1500, OutputTokens: 20, Cost: 0.0042, Iteration: 2, MaxIterations: 20},
+			want:  "3.2s · 1520 tokens · $0.0042 · iteration 2/20",
+		},
+		{
+			stats: &api.StreamStats{Elapsed: 500 * time.Millisecond, OutputTokens: 12, TokensEstimated: true},
+			want:  "0.5s · ~12 tokens",
+		},
+	}
+
+	for _, tc := range tests {
+		if got := tc.stats.String(); got != tc.want {
+			t.Errorf("expected %q, got %q", tc.want, got)
+		}
+	}
+}
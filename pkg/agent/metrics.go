@@ -0,0 +1,50 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// recordMetrics samples the output of a kubectl top command, for the
+// compare_metrics tool to compare later usage with it.
+func (c *Agent) recordMetrics(call ToolCallAnalysis, output any) {
+	result, ok := output.(*tools.ExecResult)
+	if c.metrics == nil || !ok || result.ExitCode != 0 || result.Error != "" {
+		return
+	}
+	command, _ := call.FunctionCall.Arguments["command"].(string)
+	kubectlCommands := tools.ParseKubectlCommands(command)
+	if len(kubectlCommands) != 1 || kubectlCommands[0].Verb != "top" {
+		return
+	}
+	c.metrics.Record(tools.ParseTop(result.Stdout, kubectlCommands[0].Namespace, time.Now()))
+}
+
+// saveMetricSamples records new metric samples in the session, if it is persisted.
+func (c *Agent) saveMetricSamples(samples []api.MetricSample) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if s, ok := c.ChatMessageStore.(*sessions.Session); ok {
+		if err := s.AddMetricSamples(samples); err != nil {
+			klog.Warningf("error saving metric samples: %v", err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// modePrompts are the extra prompts of the operating modes.
+//
+//go:embed modes/*.txt
+var modePrompts embed.FS
+
+// modePromptScheme prefixes the extra prompt paths of the modes, like
+// promptPackScheme.
+const modePromptScheme = "mode://"
+
+// Mode is an operating mode of the agent, which adapts its tools, its
+// approvals and its prompt to the role of the user.
+type Mode struct {
+	Name        string
+	Description string
+	// DisableTools are the built-in tools the agent can't use in the mode.
+	DisableTools []string
+	// ReadOnly refuses the tool calls that modify, or may modify, resources.
+	ReadOnly bool
+	// StrictApprovals requires the approval of every change: permissions
+	// can't be skipped, and approving the changes of a turn doesn't approve
+	// the following ones.
+	StrictApprovals bool
+}
+
+// modes are the operating modes, selected with --mode or by context.
+var modes = []Mode{
+	{
+		Name:         "developer",
+		Description:  "works in the namespace of the context, and gives port-forward commands to reach the applications",
+		DisableTools: []string{"node_debug"},
+	},
+	{
+		Name:            "sre",
+		Description:     "all the tools, every change approved one by one",
+		StrictApprovals: true,
+	},
+	{
+		Name:         "auditor",
+		Description:  "read-only tools, answers as reports of findings",
+		DisableTools: []string{"apply_manifest", "bash", "netcheck", "node_debug"},
+		ReadOnly:     true,
+	},
+}
+
+// ModeNames returns the names of the operating modes.
+func ModeNames() []string {
+	var names []string
+	for _, mode := range modes {
+		names = append(names, mode.Name)
+	}
+	return names
+}
+
+// LookupMode returns the operating mode of a name.
+func LookupMode(name string) (*Mode, error) {
+	for i := range modes {
+		if modes[i].Name == name {
+			mode := modes[i]
+			return &mode, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown mode %q, expected one of %s", name, strings.Join(ModeNames(), ", "))
+}
+
+// PromptPath returns the extra prompt path of the prompt of the mode, to add
+// to ExtraPromptPaths.
+func (m *Mode) PromptPath() string {
+	return modePromptScheme + m.Name
+}
+
+// ContextMode selects the operating mode of the sessions on the kubeconfig
+// contexts whose name matches a pattern, e.g. auditor on *prod*.
+type ContextMode struct {
+	// Pattern is matched against the whole name of the context, where "*"
+	// matches any characters, like the pattern of ContextEnvironment.
+	Pattern string `json:"pattern"`
+	Mode    string `json:"mode"`
+}
+
+// Validate checks that the mode exists.
+func (m *ContextMode) Validate() error {
+	if m.Pattern == "" {
+		return fmt.Errorf("context mode %q: pattern is required", m.Mode)
+	}
+	if _, err := LookupMode(m.Mode); err != nil {
+		return fmt.Errorf("context mode for pattern %q: %w", m.Pattern, err)
+	}
+	return nil
+}
+
+// ModeForContext returns the mode of a kubeconfig context, from the first of
+// the context modes whose pattern matches its name, or "" if none does.
+func ModeForContext(contextModes []ContextMode, kubeContext string) string {
+	if kubeContext == "" {
+		return ""
+	}
+	for _, m := range contextModes {
+		if matchesPattern(m.Pattern, kubeContext) {
+			return m.Mode
+		}
+	}
+	return ""
+}
+
+// readOnlyPrompt tells the LLM of read-only sessions not to plan changes it
+// can't make.
+const readOnlyPrompt = "\n\nNote: this session is read-only. Tool calls that modify, or may modify, resources are refused without running. " +
+	"Only use read-only commands, e.g. `get`, `describe`, `logs` and `auth can-i`, and when a change is needed, " +
+	"describe it with the command or the manifest for the user to apply.\n"
+
+// rejectModifyingCalls refuses all the pending calls of a read-only session,
+// of which some modify resources, telling the LLM why so that it plans
+// around them.
+func (c *Agent) rejectModifyingCalls(ctx context.Context) {
+	klog.FromContext(ctx).Info("refusing tool calls modifying resources in a read-only session")
+	for _, call := range c.pendingFunctionCalls {
+		reason := "Not run, because other commands of the same turn modify resources."
+		status := "skipped"
+		if call.ModifiesResourceStr != "no" {
+			c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("  Refused in a read-only session: %s\n", call.ParsedToolCall.Description()))
+			reason = "Not run: the session is read-only, and the command modifies or may modify resources. " +
+				"Use read-only commands, or describe the change for the user to apply."
+			status = "refused"
+		}
+		if c.EnableToolUseShim {
+			c.currChatContent = append(c.currChatContent, fmt.Sprintf("Result of running %q:\n%s", call.FunctionCall.Name, reason))
+			continue
+		}
+		c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
+			ID:   call.FunctionCall.ID,
+			Name: call.FunctionCall.Name,
+			Result: map[string]any{
+				"error":             reason,
+				"status":            status,
+				"modifies_resource": call.ModifiesResourceStr,
+				"retryable":         false,
+			},
+		})
+	}
+	c.pendingFunctionCalls = []ToolCallAnalysis{}
+}
@@ -0,0 +1,174 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func TestModes(t *testing.T) {
+	if got, want := ModeNames(), []string{"developer", "sre", "auditor"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ModeNames() = %q, want %q", got, want)
+	}
+	if _, err := LookupMode("admin"); err == nil || !strings.Contains(err.Error(), "expected one of developer, sre, auditor") {
+		t.Errorf("LookupMode(\"admin\") error = %v, want the available modes", err)
+	}
+
+	// Every mode has a prompt, and its disabled tools are built-in tools.
+	for _, name := range ModeNames() {
+		mode, err := LookupMode(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := &Agent{ExtraPromptPaths: []string{mode.PromptPath()}}
+		got, err := a.generatePrompt(context.Background(), "You are kubectl-ai.", PromptData{})
+		if err != nil {
+			t.Fatalf("generatePrompt() in the %s mode error = %v", name, err)
+		}
+		if !strings.Contains(got, "mode\n") {
+			t.Errorf("generatePrompt() in the %s mode = %q, want the prompt of the mode", name, got)
+		}
+		for _, tool := range mode.DisableTools {
+			if tools.Lookup(tool) == nil {
+				t.Errorf("the %s mode disables the unknown tool %q", name, tool)
+			}
+		}
+	}
+}
+
+func TestModeForContext(t *testing.T) {
+	contextModes := []ContextMode{
+		{Pattern: "*prod*", Mode: "auditor"},
+		{Pattern: "kind-*", Mode: "developer"},
+	}
+	for _, m := range contextModes {
+		if err := m.Validate(); err != nil {
+			t.Errorf("Validate() = %v", err)
+		}
+	}
+	for kubeContext, want := range map[string]string{
+		"gke_acme-prod_europe-west1_main": "auditor",
+		"kind-dev":                        "developer",
+		"minikube":                        "",
+		"":                                "",
+	} {
+		if got := ModeForContext(contextModes, kubeContext); got != want {
+			t.Errorf("ModeForContext(%q) = %q, want %q", kubeContext, got, want)
+		}
+	}
+
+	invalid := ContextMode{Pattern: "*", Mode: "admin"}
+	if err := invalid.Validate(); err == nil || !strings.Contains(err.Error(), `unknown mode "admin"`) {
+		t.Errorf("Validate() of an unknown mode = %v", err)
+	}
+}
+
+func TestRejectModifyingCalls(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("kubectl").AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).DoAndReturn(func(args map[string]any) string {
+		switch command := args["command"].(string); {
+		case strings.Contains(command, " delete "):
+			return "yes"
+		case strings.Contains(command, " | "):
+			return "unknown"
+		}
+		return "no"
+	}).AnyTimes()
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	a := &Agent{
+		Tools:    ts,
+		ReadOnly: true,
+		session:  &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:   make(chan any, 20),
+	}
+	calls := []gollm.FunctionCall{
+		{ID: "a", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods -n shop"}},
+		{ID: "b", Name: "kubectl", Arguments: map[string]any{"command": "kubectl delete pod web-0 -n shop"}},
+		{ID: "c", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods -o name | xargs kubectl-foo"}},
+	}
+	var err error
+	if a.pendingFunctionCalls, err = a.analyzeToolCalls(context.Background(), calls); err != nil {
+		t.Fatalf("analyzeToolCalls: %v", err)
+	}
+	a.rejectModifyingCalls(context.Background())
+
+	var statuses, modifies []any
+	for _, content := range a.currChatContent {
+		result := content.(gollm.FunctionCallResult).Result
+		statuses = append(statuses, result["status"])
+		modifies = append(modifies, result["modifies_resource"])
+	}
+	if want := []any{"skipped", "refused", "refused"}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses of the results = %v, want %v", statuses, want)
+	}
+	if want := []any{"no", "yes", "unknown"}; !reflect.DeepEqual(modifies, want) {
+		t.Errorf("modifies_resource of the results = %v, want %v", modifies, want)
+	}
+	if len(a.pendingFunctionCalls) != 0 {
+		t.Errorf("the refused calls are still pending: %v", a.pendingFunctionCalls)
+	}
+}
+
+func TestStrictApprovals(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("kubectl").AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).Return("yes").AnyTimes()
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	a := &Agent{
+		Tools:           ts,
+		StrictApprovals: true,
+		session:         &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:          make(chan any, 20),
+	}
+	calls := []gollm.FunctionCall{{ID: "a", Name: "kubectl", Arguments: map[string]any{"command": "kubectl rollout undo deployment/web"}}}
+	var err error
+	if a.pendingFunctionCalls, err = a.analyzeToolCalls(ctx, calls); err != nil {
+		t.Fatalf("analyzeToolCalls: %v", err)
+	}
+	a.queueApprovals(ctx)
+
+	// "Yes, and don't ask me again" only approves the calls of the turn.
+	if !a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 2, Approver: "alice"}) {
+		t.Fatalf("handleChoice() didn't dispatch the approved call")
+	}
+	if a.SkipPermissions || a.dontAskAgainApprover != "" {
+		t.Errorf("the next changes don't require an approval: SkipPermissions = %v, dontAskAgainApprover = %q", a.SkipPermissions, a.dontAskAgainApprover)
+	}
+	if approval := a.pendingFunctionCalls[0].Approval; approval == nil || approval.Approver != "alice" {
+		t.Errorf("approval of the call = %+v, want approved by alice", approval)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+const notesUsage = "Usage: notes | note add TEXT | note remove N"
+
+// handleNoteQuery implements the note meta commands, which show and change
+// the notes pinned to the session, e.g. the change ticket or the suspected
+// cause of an incident.
+func (c *Agent) handleNoteQuery(query string) (answer string, handled bool, err error) {
+	command, rest, _ := strings.Cut(strings.TrimSpace(query), " ")
+	if command == "notes" && rest == "" {
+		return c.describeNotes(), true, nil
+	}
+	action, arg, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	arg = strings.TrimSpace(arg)
+	switch {
+	case command == "note" && action == "add" && arg != "":
+		if err := c.AddNote(arg); err != nil {
+			return "", false, err
+		}
+	case command == "note" && action == "remove":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return notesUsage, true, nil
+		}
+		if err := c.RemoveNote(n); err != nil {
+			return err.Error(), true, nil
+		}
+	default:
+		return notesUsage, true, nil
+	}
+	return c.describeNotes(), true, nil
+}
+
+// Notes returns the notes pinned to the session.
+func (c *Agent) Notes() []string {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return slices.Clone(c.notes)
+}
+
+// AddNote pins a note to the session.
+func (c *Agent) AddNote(note string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.notes = append(c.notes, note)
+	return c.saveNotes()
+}
+
+// RemoveNote removes the n-th note, starting at 1.
+func (c *Agent) RemoveNote(n int) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if n < 1 || n > len(c.notes) {
+		return fmt.Errorf("there is no note #%d", n)
+	}
+	c.notes = slices.Delete(c.notes, n-1, n)
+	return c.saveNotes()
+}
+
+// saveNotes records the notes in the session metadata, sessionMu must be held.
+func (c *Agent) saveNotes() error {
+	if s, ok := c.ChatMessageStore.(*sessions.Session); ok {
+		if err := s.SetNotes(c.notes); err != nil {
+			return fmt.Errorf("saving session notes: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Agent) describeNotes() string {
+	notes := c.Notes()
+	if len(notes) == 0 {
+		return "No notes are pinned to the session. " + notesUsage
+	}
+	var sb strings.Builder
+	sb.WriteString("Notes pinned to the session:\n\n")
+	for i, note := range notes {
+		fmt.Fprintf(&sb, "  %d. %s\n", i+1, note)
+	}
+	return sb.String()
+}
+
+// withNotes prepends the pinned notes to a query for the model, if enabled.
+// The notes are given with every query rather than once, so that they
+// survive the digest of the history when the chat is re-initialized.
+func (c *Agent) withNotes(query string) string {
+	notes := c.Notes()
+	if !c.InjectNotes || len(notes) == 0 {
+		return query
+	}
+	var sb strings.Builder
+	sb.WriteString("Notes pinned by the user to this session:\n")
+	for _, note := range notes {
+		sb.WriteString("- " + note + "\n")
+	}
+	sb.WriteString("\n" + query)
+	return sb.String()
+}
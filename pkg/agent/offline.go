@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+// ErrOffline is returned by the features that need the model in offline mode.
+var ErrOffline = errors.New("the model is not available in offline mode")
+
+// offlineError reports that a feature needs the model, which is disabled in offline mode.
+func offlineError(feature string) error {
+	return fmt.Errorf("%w: %s needs the model. Meta commands (e.g. `sessions`, `notes`, `env`, `tools`), `run N` and cached answers still work; run without --offline to ask questions", ErrOffline, feature)
+}
+
+// NewOfflineClient returns an LLM client that makes no network calls, for
+// offline mode: its chats fail with ErrOffline when a message is sent.
+func NewOfflineClient() gollm.Client {
+	return offlineClient{}
+}
+
+type offlineClient struct{}
+
+var _ gollm.Client = offlineClient{}
+
+func (offlineClient) Close() error {
+	return nil
+}
+
+func (offlineClient) StartChat(systemPrompt, model string) gollm.Chat {
+	return offlineChat{}
+}
+
+func (offlineClient) GenerateCompletion(ctx context.Context, req *gollm.CompletionRequest) (gollm.CompletionResponse, error) {
+	return nil, offlineError("generating completions")
+}
+
+func (offlineClient) SetResponseSchema(schema *gollm.Schema) error {
+	return nil
+}
+
+func (offlineClient) ListModels(ctx context.Context) ([]string, error) {
+	return nil, offlineError("listing models")
+}
+
+type offlineChat struct{}
+
+var _ gollm.Chat = offlineChat{}
+
+func (offlineChat) Send(ctx context.Context, contents ...any) (gollm.ChatResponse, error) {
+	return nil, offlineError("answering queries")
+}
+
+func (offlineChat) SendStreaming(ctx context.Context, contents ...any) (gollm.ChatResponseIterator, error) {
+	return nil, offlineError("answering queries")
+}
+
+func (offlineChat) SetFunctionDefinitions(functionDefinitions []*gollm.FunctionDefinition) error {
+	return nil
+}
+
+func (offlineChat) IsRetryableError(err error) bool {
+	return false
+}
+
+func (offlineChat) Initialize(messages []*gollm.Message) error {
+	return nil
+}
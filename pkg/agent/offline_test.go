@@ -0,0 +1,51 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOffline(t *testing.T) {
+	ctx := context.Background()
+	client := NewOfflineClient()
+
+	chat := client.StartChat("system prompt", "model")
+	if err := chat.Initialize(nil); err != nil {
+		t.Errorf("Initialize() error = %v", err)
+	}
+	if err := chat.SetFunctionDefinitions(nil); err != nil {
+		t.Errorf("SetFunctionDefinitions() error = %v", err)
+	}
+	if _, err := chat.Send(ctx, "why is my pod crashing?"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Send() error = %v, expected ErrOffline", err)
+	}
+	if _, err := chat.SendStreaming(ctx, "why is my pod crashing?"); !errors.Is(err, ErrOffline) {
+		t.Errorf("SendStreaming() error = %v, expected ErrOffline", err)
+	}
+	if _, err := client.ListModels(ctx); !errors.Is(err, ErrOffline) {
+		t.Errorf("ListModels() error = %v, expected ErrOffline", err)
+	}
+
+	a := &Agent{LLM: client, Offline: true}
+	if _, _, err := a.handleMetaQuery(ctx, "fanout namespaces all are pods restarting?"); !errors.Is(err, ErrOffline) {
+		t.Errorf("fanout error = %v, expected ErrOffline", err)
+	}
+	if answer, handled, err := a.handleMetaQuery(ctx, "model"); err != nil || !handled || answer == "" {
+		t.Errorf("model meta command = %q, %v, %v, expected an answer", answer, handled, err)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+// SessionOwner returns the user who owns the session, or "" if it has none.
+func (c *Agent) SessionOwner() string {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.owner
+}
+
+// ClaimSession makes an authenticated user the owner of the session, unless
+// it already has one, and returns the owner. Other users can still take part
+// in the session, the owner is recorded for the audit trail.
+func (c *Agent) ClaimSession(user string) (string, error) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.owner != "" {
+		return c.owner, nil
+	}
+	c.owner = user
+	if s, ok := c.ChatMessageStore.(*sessions.Session); ok {
+		if err := s.SetOwner(user); err != nil {
+			return user, fmt.Errorf("saving session owner: %w", err)
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// evaluatePolicy returns the decision of the policy on a tool call, nil if
+// there is no policy or no rule matches. With ConfineKubeconfig, the calls
+// that could use other credentials are denied first.
+func (c *Agent) evaluatePolicy(call ToolCallAnalysis) *tools.PolicyDecision {
+	if c.ConfineKubeconfig {
+		if err := tools.CheckConfinedArguments(call.FunctionCall.Arguments); err != nil {
+			return &tools.PolicyDecision{Verdict: tools.PolicyDeny, Rule: "confine-kubeconfig", Message: "the session can only use its own kubeconfig: " + err.Error()}
+		}
+	}
+	if c.Policy == nil {
+		return nil
+	}
+	decision := c.Policy.Evaluate(tools.PolicyInput{
+		Tool:             call.FunctionCall.Name,
+		Arguments:        call.FunctionCall.Arguments,
+		ModifiesResource: call.ModifiesResourceStr,
+		Namespace:        c.namespace,
+		Context:          c.kubeContext(),
+		Environment:      c.environment,
+	})
+	if decision != nil {
+		klog.V(2).Info("policy decision", "tool", call.FunctionCall.Name, "verdict", decision.Verdict, "rule", decision.Rule)
+	}
+	return decision
+}
+
+// policyVerdict returns the verdict of the policy on the call, "" if none.
+func (call ToolCallAnalysis) policyVerdict() tools.PolicyVerdict {
+	if call.Policy == nil {
+		return ""
+	}
+	return call.Policy.Verdict
+}
+
+// policyDenial explains why the policy denied a call.
+func policyDenial(decision *tools.PolicyDecision) string {
+	reason := fmt.Sprintf("denied by the policy rule %q", decision.Rule)
+	if decision.Message != "" {
+		reason += ": " + decision.Message
+	}
+	return reason
+}
+
+// requiresApproval reports whether a pending call is queued for approval:
+// the frozen calls and those the policy asks for always are, the calls the
+// policy allows are not, and the others are if they may modify resources.
+func (c *Agent) requiresApproval(call ToolCallAnalysis, now time.Time) bool {
+	if c.activeFreeze(call, now) != nil {
+		return true
+	}
+	switch call.policyVerdict() {
+	case tools.PolicyAsk:
+		return true
+	case tools.PolicyAllow:
+		return false
+	}
+	return call.ModifiesResourceStr != "no"
+}
+
+// needsApproval reports whether the user must be asked to approve some of the
+// pending calls. With SkipPermissions, only the frozen calls and those the
+// policy asks for are.
+func (c *Agent) needsApproval() bool {
+	if c.hasFrozenCalls() {
+		return true
+	}
+	now := time.Now()
+	for _, call := range c.pendingFunctionCalls {
+		if call.policyVerdict() == tools.PolicyAsk {
+			return true
+		}
+		if !c.SkipPermissions && c.requiresApproval(call, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectDeniedCalls rejects all the pending calls if the policy denies some,
+// telling the LLM why so that it re-plans, and reports whether the calls were
+// rejected.
+func (c *Agent) rejectDeniedCalls(ctx context.Context) bool {
+	denied := false
+	for _, call := range c.pendingFunctionCalls {
+		if call.policyVerdict() == tools.PolicyDeny {
+			denied = true
+		}
+	}
+	if !denied {
+		return false
+	}
+
+	for _, call := range c.pendingFunctionCalls {
+		result := map[string]any{"retryable": false}
+		var reason string
+		if call.policyVerdict() == tools.PolicyDeny {
+			c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("  Denied: %s: %s\n", call.ParsedToolCall.Description(), policyDenial(call.Policy)))
+			klog.FromContext(ctx).Info("tool call denied by policy", "tool", call.FunctionCall.Name, "rule", call.Policy.Rule)
+			reason = "Not run: " + policyDenial(call.Policy) + ". Find another way, or tell the user the policy doesn't allow it."
+			result["status"] = "denied"
+			result["policy_rule"] = call.Policy.Rule
+		} else {
+			reason = "Not run, because other commands of the same turn are denied by the policy."
+			result["status"] = "skipped"
+		}
+		result["error"] = reason
+		if c.EnableToolUseShim {
+			c.currChatContent = append(c.currChatContent, fmt.Sprintf("Result of running %q:\n%s", call.FunctionCall.Name, reason))
+			continue
+		}
+		c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
+			ID:     call.FunctionCall.ID,
+			Name:   call.FunctionCall.Name,
+			Result: result,
+		})
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func newPolicyTestAgent(t *testing.T) *Agent {
+	ctrl := gomock.NewController(t)
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("bash").AnyTimes()
+	mt.EXPECT().IsInteractive(gomock.Any()).Return(false, nil).AnyTimes()
+	mt.EXPECT().CheckModifiesResource(gomock.Any()).DoAndReturn(func(args map[string]any) string {
+		if strings.HasPrefix(args["command"].(string), "kubectl get") {
+			return "no"
+		}
+		return "yes"
+	}).AnyTimes()
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	policy, err := tools.NewPolicy([]tools.PolicyRule{
+		{Name: "no-namespace-deletion", Verdict: tools.PolicyDeny, Message: "ask the platform team",
+			When: `kubectl.exists(k, k.verb == "delete" && k.resource.matches("^(ns|namespaces?)(/|$)"))`},
+		{Name: "secrets", Verdict: tools.PolicyAsk, When: `kubectl.exists(k, k.resource.startsWith("secret"))`},
+		{Name: "dev-changes", Verdict: tools.PolicyAllow, When: `modifies != "no" && namespaces.all(n, n.startsWith("dev-"))`},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	return &Agent{
+		Tools:     ts,
+		Policy:    policy,
+		namespace: "default",
+		session:   &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:    make(chan any, 20),
+	}
+}
+
+// analyzeCommands sets the pending calls of the agent to bash calls running
+// the commands.
+func analyzeCommands(t *testing.T, c *Agent, commands ...string) {
+	t.Helper()
+	var calls []gollm.FunctionCall
+	for i, command := range commands {
+		calls = append(calls, gollm.FunctionCall{ID: fmt.Sprint(i), Name: "bash", Arguments: map[string]any{"command": command}})
+	}
+	var err error
+	if c.pendingFunctionCalls, err = c.analyzeToolCalls(context.Background(), calls); err != nil {
+		t.Fatalf("analyzeToolCalls: %v", err)
+	}
+}
+
+func TestPolicyDeny(t *testing.T) {
+	a := newPolicyTestAgent(t)
+	a.SkipPermissions = true
+	analyzeCommands(t, a, "kubectl get ns", "kubectl delete ns dev-web")
+
+	if !a.rejectDeniedCalls(context.Background()) {
+		t.Fatalf("rejectDeniedCalls() = false, want the calls rejected")
+	}
+	if len(a.currChatContent) != 2 {
+		t.Fatalf("got %d results, want 2", len(a.currChatContent))
+	}
+	skipped := a.currChatContent[0].(gollm.FunctionCallResult).Result
+	denied := a.currChatContent[1].(gollm.FunctionCallResult).Result
+	if skipped["status"] != "skipped" || denied["status"] != "denied" || denied["policy_rule"] != "no-namespace-deletion" {
+		t.Errorf("results = %v, %v, want the second call denied and the first skipped", skipped, denied)
+	}
+	if !strings.Contains(denied["error"].(string), "ask the platform team") {
+		t.Errorf("denied result %v doesn't give the message of the rule", denied)
+	}
+
+	a.currChatContent = nil
+	analyzeCommands(t, a, "kubectl get ns")
+	if a.rejectDeniedCalls(context.Background()) || len(a.currChatContent) != 0 {
+		t.Errorf("rejectDeniedCalls() rejected calls no rule denies")
+	}
+}
+
+func TestConfineKubeconfig(t *testing.T) {
+	a := newPolicyTestAgent(t)
+	a.Policy = nil
+	a.ConfineKubeconfig = true
+
+	analyzeCommands(t, a, "kubectl get pods -n shop | grep web", "kubectl get secrets -A --kubeconfig ~/.kube/config", "cat ~/.kube/config")
+	if got := a.pendingFunctionCalls[0].policyVerdict(); got != "" {
+		t.Errorf("verdict on a kubectl command of the session = %q, want none", got)
+	}
+	for _, call := range a.pendingFunctionCalls[1:] {
+		if call.policyVerdict() != tools.PolicyDeny || call.Policy.Rule != "confine-kubeconfig" {
+			t.Errorf("decision on %v = %+v, want it denied", call.FunctionCall.Arguments, call.Policy)
+		}
+	}
+}
+
+func TestPolicyAskAndAllow(t *testing.T) {
+	ctx := context.Background()
+	a := newPolicyTestAgent(t)
+
+	// Allowed changes run without approval, and are recorded as approved by
+	// the policy.
+	analyzeCommands(t, a, "kubectl get pods", "kubectl scale deployment web --replicas=3 -n dev-shop")
+	if a.needsApproval() {
+		t.Errorf("needsApproval() = true for calls the policy allows")
+	}
+	if approval := a.toolCallApproval(a.pendingFunctionCalls[1]); approval == nil || approval.Method != api.ApprovalMethodPolicy || approval.Reason != "dev-changes" {
+		t.Errorf("approval of an allowed call = %+v, want a policy approval", approval)
+	}
+
+	// Other changes ask for approval, unless permissions are skipped.
+	analyzeCommands(t, a, "kubectl scale deployment web --replicas=3")
+	if !a.needsApproval() {
+		t.Errorf("needsApproval() = false for a change no rule matches")
+	}
+	a.SkipPermissions = true
+	if a.needsApproval() {
+		t.Errorf("needsApproval() = true with --skip-permissions")
+	}
+
+	// Read-only calls the policy asks for require approval, even with
+	// --skip-permissions, and only them are queued.
+	analyzeCommands(t, a, "kubectl get secret db -n dev-shop -o yaml", "kubectl get pods")
+	if !a.needsApproval() {
+		t.Fatalf("needsApproval() = false for a call the policy asks for")
+	}
+	a.askForApproval(ctx)
+	if pending := a.PendingApprovals(); len(pending) != 1 || pending[0].Command != "kubectl get secret db -n dev-shop -o yaml" {
+		t.Fatalf("PendingApprovals() = %+v, want the call the policy asks for", pending)
+	}
+	if !a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 1, Approver: "alice"}) {
+		t.Fatalf("handleChoice() did not dispatch the approved calls")
+	}
+	if approval := a.toolCallApproval(a.pendingFunctionCalls[0]); approval == nil || approval.Approver != "alice" {
+		t.Errorf("approval of the read-only call = %+v, want alice's", approval)
+	}
+}
+
+func TestEditPendingCallReadOnly(t *testing.T) {
+	ctx := context.Background()
+	a := newPolicyTestAgent(t)
+	a.ReadOnly = true
+
+	// A read the policy asks for can't be edited into a change, even one the
+	// policy allows.
+	analyzeCommands(t, a, "kubectl get secret db -n dev-shop")
+	a.askForApproval(ctx)
+	pending := a.PendingApprovals()
+	if a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 1, CallIDs: []string{pending[0].ID}, Command: "kubectl scale deployment web --replicas=0 -n dev-shop"}) {
+		t.Errorf("handleChoice() dispatched a change in a read-only session")
+	}
+	if got := a.pendingFunctionCalls[0].FunctionCall.Arguments["command"]; got != "kubectl get secret db -n dev-shop" {
+		t.Errorf("command after a rejected edit = %v, want the original one", got)
+	}
+	if len(a.PendingApprovals()) != 1 {
+		t.Errorf("PendingApprovals() = %+v, want the call still pending", a.PendingApprovals())
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// PromptCache caches the rendered system prompt and the function definitions
+// of the tools on disk, so that invocations with the same prompt template and
+// the same tools, e.g. from scripts in quiet mode, skip rendering the template
+// and building the schemas of the tools.
+//
+// The system prompts are keyed by the hash of the template and the hash of
+// the toolset, the function definitions by the hash of the toolset. The
+// toolset hash covers the names and descriptions of the tools, the
+// definitions of the custom and MCP tools, and the kubectl-ai binary for the
+// definitions of the built-in tools. The prompts of the templates using
+// {{.Cluster}} are never cached, as they depend on the cluster.
+type PromptCache struct {
+	// Dir is the directory of the cached prompts.
+	Dir string
+}
+
+// NewPromptCache returns a cache of prompts in ~/.kubectl-ai/cache/prompts.
+func NewPromptCache() (*PromptCache, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return &PromptCache{Dir: filepath.Join(homeDir, ".kubectl-ai", "cache", "prompts")}, nil
+}
+
+// cachedPrompt is the file of a cached system prompt.
+type cachedPrompt struct {
+	SystemPrompt string `json:"systemPrompt"`
+}
+
+// cachedFunctionDefinitions is the file of the cached function definitions of a toolset.
+type cachedFunctionDefinitions struct {
+	FunctionDefinitions []*gollm.FunctionDefinition `json:"functionDefinitions"`
+}
+
+// load reads the cached file name into v, and reports whether it exists.
+func (p *PromptCache) load(name string, v any) bool {
+	b, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			klog.Warningf("error reading cached prompt %q: %v", name, err)
+		}
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+// store caches v in the file name.
+func (p *PromptCache) store(name string, v any) {
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = writeCacheFile(p.Dir, name, b)
+	}
+	if err != nil {
+		klog.Warningf("error caching prompt %q: %v", name, err)
+	}
+}
+
+func hashOf(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// toolsetHash returns the hash of the tools declared to the model. The
+// definitions of the built-in tools are only built for cache misses: they are
+// identified by the kubectl-ai binary defining them.
+func toolsetHash(ts tools.Tools) string {
+	parts := []string{binaryIdentity()}
+	all := ts.AllTools()
+	slices.SortFunc(all, func(a, b tools.Tool) int { return strings.Compare(a.Name(), b.Name()) })
+	for _, tool := range all {
+		parts = append(parts, tool.Name(), tool.Description())
+		switch tool.(type) {
+		case *tools.CustomTool, *tools.MCPTool:
+			// Their parameters come from their configuration, not from the binary.
+			b, _ := json.Marshal(tool.FunctionDefinition())
+			parts = append(parts, string(b))
+		}
+	}
+	return hashOf(parts...)
+}
+
+// binaryIdentity identifies the running kubectl-ai binary, including the
+// development builds sharing a version.
+func binaryIdentity() string {
+	path, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return path
+	}
+	return fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().UnixNano())
+}
+
+// functionDefinitions returns the function definitions of the tools, sorted
+// to help the providers reuse their cached prefix of the requests.
+func (c *Agent) functionDefinitions() []*gollm.FunctionDefinition {
+	var name string
+	if c.PromptCache != nil {
+		name = "tools-" + toolsetHash(c.Tools) + ".json"
+		var cached cachedFunctionDefinitions
+		if c.PromptCache.load(name, &cached) {
+			return cached.FunctionDefinitions
+		}
+	}
+	var functionDefinitions []*gollm.FunctionDefinition
+	for _, tool := range c.Tools.AllTools() {
+		functionDefinitions = append(functionDefinitions, tools.FunctionDefinitionOf(tool))
+	}
+	slices.SortFunc(functionDefinitions, func(a, b *gollm.FunctionDefinition) int { return strings.Compare(a.Name, b.Name) })
+	if c.PromptCache != nil {
+		c.PromptCache.store(name, cachedFunctionDefinitions{FunctionDefinitions: functionDefinitions})
+	}
+	return functionDefinitions
+}
+
+// systemPromptCacheName returns the name of the cached system prompt
+// rendered from a template for the tools of data.
+func systemPromptCacheName(promptTemplate string, data *PromptData) string {
+	return "prompt-" + hashOf(promptTemplate, toolsetHash(data.Tools), fmt.Sprint(data.EnableToolUseShim)) + ".json"
+}
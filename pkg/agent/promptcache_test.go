@@ -0,0 +1,98 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+// definitionCountingTool counts the builds of its function definition.
+type definitionCountingTool struct {
+	tools.Tool
+	name        string
+	description string
+	definitions int
+}
+
+func (t *definitionCountingTool) Name() string        { return t.name }
+func (t *definitionCountingTool) Description() string { return t.description }
+
+func (t *definitionCountingTool) FunctionDefinition() *gollm.FunctionDefinition {
+	t.definitions++
+	return &gollm.FunctionDefinition{Name: t.name, Description: t.description}
+}
+
+func TestPromptCache(t *testing.T) {
+	cache := &PromptCache{Dir: t.TempDir()}
+	kubectl := &definitionCountingTool{name: "kubectl", description: "Runs kubectl."}
+	bash := &definitionCountingTool{name: "bash", description: "Runs bash."}
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(kubectl)
+	ts.RegisterTool(bash)
+	a := &Agent{Tools: ts, PromptCache: cache}
+
+	for range 2 {
+		defs := a.functionDefinitions()
+		if len(defs) != 2 || defs[0].Name != "bash" || defs[1].Name != "kubectl" {
+			t.Fatalf("functionDefinitions() = %v, want bash and kubectl", defs)
+		}
+	}
+	if kubectl.definitions != 1 {
+		t.Errorf("function definition built %d times, want once", kubectl.definitions)
+	}
+
+	for range 2 {
+		got, err := a.generatePrompt(context.Background(), "Tools: {{.ToolsAsJSON}}", PromptData{Tools: ts})
+		if err != nil {
+			t.Fatalf("generatePrompt() error = %v", err)
+		}
+		if got == "Tools: " {
+			t.Errorf("generatePrompt() = %q, want the tools", got)
+		}
+	}
+	if kubectl.definitions != 2 {
+		t.Errorf("function definition built %d times, want once more for the prompt", kubectl.definitions)
+	}
+
+	// Changing a tool or the template misses the cache.
+	kubectl.description = "Runs kubectl commands."
+	a.functionDefinitions()
+	if _, err := a.generatePrompt(context.Background(), "Tools: {{.ToolNames}}", PromptData{Tools: ts}); err != nil {
+		t.Fatalf("generatePrompt() error = %v", err)
+	}
+	if kubectl.definitions != 3 {
+		t.Errorf("function definition built %d times, want once more for the new toolset", kubectl.definitions)
+	}
+
+	// The prompts describing the cluster are not cached.
+	queries := 0
+	for range 2 {
+		data := PromptData{Tools: ts, discoverCluster: func() *tools.ClusterInfo {
+			queries++
+			return &tools.ClusterInfo{Version: "v1.30.2"}
+		}}
+		if _, err := a.generatePrompt(context.Background(), "Version: {{.Cluster.Version}}", data); err != nil {
+			t.Fatalf("generatePrompt() error = %v", err)
+		}
+	}
+	if queries != 2 {
+		t.Errorf("cluster queried %d times, want 2", queries)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// promptPacks are the extra prompts shipped with kubectl-ai, tuning the agent
+// for a platform or a policy, e.g. "gke" or "security-strict".
+//
+//go:embed promptpacks/*.txt
+var promptPacks embed.FS
+
+// promptPackScheme prefixes the extra prompt paths of the prompt packs, so
+// that they can be given in ExtraPromptPaths like template files.
+const promptPackScheme = "prompt-pack://"
+
+// PromptPacks returns the names of the prompt packs, sorted.
+func PromptPacks() []string {
+	files, _ := fs.Glob(promptPacks, "promptpacks/*.txt")
+	var names []string
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(path.Base(file), ".txt"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PromptPackPath returns the extra prompt path of a prompt pack, to add to
+// ExtraPromptPaths.
+func PromptPackPath(name string) (string, error) {
+	for _, pack := range PromptPacks() {
+		if pack == name {
+			return promptPackScheme + name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown prompt pack %q, available packs are %s", name, strings.Join(PromptPacks(), ", "))
+}
+
+// readPromptFile reads a prompt template, from the prompt packs for the paths
+// returned by PromptPackPath, from the prompts of the modes for the paths
+// returned by Mode.PromptPath, or from a file.
+func readPromptFile(p string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(p, promptPackScheme); ok {
+		if _, err := PromptPackPath(name); err != nil {
+			return nil, err
+		}
+		return promptPacks.ReadFile("promptpacks/" + name + ".txt")
+	}
+	if name, ok := strings.CutPrefix(p, modePromptScheme); ok {
+		if _, err := LookupMode(name); err != nil {
+			return nil, err
+		}
+		return modePrompts.ReadFile("modes/" + name + ".txt")
+	}
+	return os.ReadFile(p)
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPromptPacks(t *testing.T) {
+	want := []string{"cost-conscious", "gke", "openshift", "security-strict"}
+	if got := PromptPacks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PromptPacks() = %q, want %q", got, want)
+	}
+
+	if _, err := PromptPackPath("aks"); err == nil || !strings.Contains(err.Error(), "available packs are cost-conscious, gke") {
+		t.Errorf("PromptPackPath(\"aks\") error = %v, want the available packs", err)
+	}
+	if _, err := readPromptFile(promptPackScheme + "../systemprompt_template_default"); err == nil {
+		t.Errorf("readPromptFile() read a file outside of the prompt packs")
+	}
+}
+
+func TestGeneratePromptPacks(t *testing.T) {
+	extra := filepath.Join(t.TempDir(), "extra.txt")
+	if err := os.WriteFile(extra, []byte("Answer in French."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every pack is a valid template, combined with the other extra prompts.
+	for _, name := range PromptPacks() {
+		t.Run(name, func(t *testing.T) {
+			packPath, err := PromptPackPath(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := &Agent{ExtraPromptPaths: []string{packPath, extra}}
+			got, err := a.generatePrompt(context.Background(), "You are kubectl-ai.", PromptData{})
+			if err != nil {
+				t.Fatalf("generatePrompt() error = %v", err)
+			}
+			pack, err := promptPacks.ReadFile("promptpacks/" + name + ".txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "You are kubectl-ai.\n" + string(pack) + "\nAnswer in French."; got != want {
+				t.Errorf("generatePrompt() = %q, want %q", got, want)
+			}
+		})
+	}
+}
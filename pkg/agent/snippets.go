@@ -0,0 +1,121 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Snippet is a shell code block of a model answer, that the user can run
+// with the "run <index>" meta query.
+type Snippet struct {
+	// Index is the 1-based number of the snippet in the answer.
+	Index    int
+	Language string
+	Code     string
+}
+
+// shellLanguages are the info strings of the code blocks considered runnable.
+var shellLanguages = map[string]bool{
+	"": true, "sh": true, "bash": true, "shell": true, "zsh": true, "console": true,
+}
+
+// LabelSnippets finds the runnable shell code blocks of a markdown answer,
+// and labels each of them with its index so that the user can refer to it.
+// It returns the labeled answer and the snippets.
+func LabelSnippets(text string) (string, []Snippet) {
+	lines := strings.SplitAfter(text, "\n")
+
+	// labels maps the line of the opening fence of each snippet to its index.
+	labels := make(map[int]int)
+	var snippets []Snippet
+
+	start := -1 // line of the opening fence of the current code block
+	var fence, language string
+	endBlock := func(end int) {
+		if !shellLanguages[language] {
+			return
+		}
+		var code []string
+		for _, line := range lines[start+1 : end] {
+			code = append(code, strings.TrimRight(line, "\r\n"))
+		}
+		if code := snippetCode(language, code); code != "" {
+			snippets = append(snippets, Snippet{Index: len(snippets) + 1, Language: language, Code: code})
+			labels[start] = len(snippets)
+		}
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			continue
+		}
+		if start < 0 {
+			start = i
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, "`"))]
+			language, _, _ = strings.Cut(strings.TrimSpace(strings.TrimPrefix(trimmed, fence)), " ")
+			language = strings.ToLower(language)
+		} else if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, "`") == "" {
+			endBlock(i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		// Unterminated code block, e.g. at the end of a truncated answer.
+		endBlock(len(lines))
+	}
+	if len(snippets) == 0 {
+		return text, nil
+	}
+
+	var labeled strings.Builder
+	for i, line := range lines {
+		if index, ok := labels[i]; ok {
+			fmt.Fprintf(&labeled, "Snippet #%d (type `run %d` to run it):\n", index, index)
+		}
+		labeled.WriteString(line)
+	}
+	return labeled.String(), snippets
+}
+
+// snippetCode returns the command of a code block.
+// Console blocks show prompts followed by output, only the commands are kept.
+func snippetCode(language string, lines []string) string {
+	if language == "console" {
+		var commands []string
+		for _, line := range lines {
+			if command, ok := strings.CutPrefix(line, "$ "); ok {
+				commands = append(commands, command)
+			}
+		}
+		lines = commands
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// parseRunQuery parses the "run <index>" meta query, also accepted as "/run <index>".
+func parseRunQuery(query string) (index int, ok bool) {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(query), "/"))
+	if len(fields) != 2 || fields[0] != "run" {
+		return 0, false
+	}
+	index, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, false
+	}
+	return index, true
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"testing"
+)
+
+func TestLabelSnippets(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+		snippets []Snippet
+	}{
+		{
+			name:     "no code blocks",
+			text:     "All pods are running.",
+			expected: "All pods are running.",
+		},
+		{
+			name: "shell and yaml blocks",
+			text: "Scale it:\n```bash\nkubectl scale deploy/web --replicas=3\n```\nWith:\n```yaml\nreplicas: 3\n```\nThen:\n```\nkubectl get pods\n```\n",
+			expected: "Scale it:\nSnippet #1 (type `run 1` to run it):\n```bash\nkubectl scale deploy/web --replicas=3\n```\n" +
+				"With:\n```yaml\nreplicas: 3\n```\nThen:\nSnippet #2 (type `run 2` to run it):\n```\nkubectl get pods\n```\n",
+			snippets: []Snippet{
+				{Index: 1, Language: "bash", Code: "kubectl scale deploy/web --replicas=3"},
+				{Index: 2, Language: "", Code: "kubectl get pods"},
+			},
+		},
+		{
+			name:     "console block keeps commands only",
+			text:     "```console\n$ kubectl get ns\nNAME STATUS\ndefault Active\n```",
+			expected: "Snippet #1 (type `run 1` to run it):\n```console\n$ kubectl get ns\nNAME STATUS\ndefault Active\n```",
+			snippets: []Snippet{{Index: 1, Language: "console", Code: "kubectl get ns"}},
+		},
+		{
+			name:     "empty and unterminated blocks",
+			text:     "```sh\n```\n````shell\nkubectl get pods\n```\nkubectl get svc",
+			expected: "```sh\n```\nSnippet #1 (type `run 1` to run it):\n````shell\nkubectl get pods\n```\nkubectl get svc",
+			snippets: []Snippet{{Index: 1, Language: "shell", Code: "kubectl get pods\n```\nkubectl get svc"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			labeled, snippets := LabelSnippets(tc.text)
+			if labeled != tc.expected {
+				t.Errorf("expected labeled text:\n%s\ngot:\n%s", tc.expected, labeled)
+			}
+			if len(snippets) != len(tc.snippets) {
+				t.Fatalf("expected snippets %+v, got %+v", tc.snippets, snippets)
+			}
+			for i := range snippets {
+				if snippets[i] != tc.snippets[i] {
+					t.Errorf("snippet %d: expected %+v, got %+v", i, tc.snippets[i], snippets[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRunQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		index int
+		ok    bool
+	}{
+		{query: "run 2", index: 2, ok: true},
+		{query: "/run 1", index: 1, ok: true},
+		{query: "run two", ok: false},
+		{query: "run 2 replicas of nginx", ok: false},
+		{query: "run", ok: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			index, ok := parseRunQuery(tc.query)
+			if index != tc.index || ok != tc.ok {
+				t.Errorf("expected (%d, %v), got (%d, %v)", tc.index, tc.ok, index, ok)
+			}
+		})
+	}
+}
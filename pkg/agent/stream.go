@@ -0,0 +1,112 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/google/uuid"
+)
+
+// StreamOptions controls how streamed model text is forwarded to the UI
+// while a response is still being generated.
+//
+// Text deltas are coalesced and flushed as a single MessageTypeTextDelta
+// message when either FlushInterval has elapsed or FlushBytes have been
+// buffered. The complete text is always sent as a MessageTypeText message
+// at the end of the response, so UIs that only render whole messages can
+// simply ignore deltas.
+type StreamOptions struct {
+	// FlushInterval is the minimum time between two delta messages.
+	// Zero disables delta messages.
+	FlushInterval time.Duration
+
+	// FlushBytes flushes buffered text as soon as it reaches this size,
+	// regardless of FlushInterval. Zero means only FlushInterval applies.
+	FlushBytes int
+}
+
+// Enabled returns true if partial text should be sent to the UI.
+func (o StreamOptions) Enabled() bool {
+	return o.FlushInterval > 0 || o.FlushBytes > 0
+}
+
+// textCoalescer batches streamed text deltas into fewer UI messages.
+//
+// Sends to the output channel never block: when the channel is full, the
+// pending text is kept and merged into the next flush, so a slow UI sees
+// fewer, larger deltas instead of stalling the agent loop.
+type textCoalescer struct {
+	opts   StreamOptions
+	output chan any
+
+	// streamID is shared by all delta messages of the same response, so
+	// that UIs can tell responses apart.
+	streamID  string
+	pending   strings.Builder
+	lastFlush time.Time
+	now       func() time.Time
+}
+
+func newTextCoalescer(opts StreamOptions, output chan any) *textCoalescer {
+	return &textCoalescer{
+		opts:     opts,
+		output:   output,
+		streamID: uuid.New().String(),
+		now:      time.Now,
+	}
+}
+
+// Add buffers a text delta and flushes it if a threshold has been reached.
+func (t *textCoalescer) Add(text string) {
+	if !t.opts.Enabled() || text == "" {
+		return
+	}
+	t.pending.WriteString(text)
+
+	now := t.now()
+	if t.lastFlush.IsZero() {
+		// Don't flush the very first delta on its own, start the interval from it.
+		t.lastFlush = now
+	}
+	intervalElapsed := t.opts.FlushInterval > 0 && now.Sub(t.lastFlush) >= t.opts.FlushInterval
+	bytesReached := t.opts.FlushBytes > 0 && t.pending.Len() >= t.opts.FlushBytes
+	if intervalElapsed || bytesReached {
+		t.flush(now)
+	}
+}
+
+// flush tries to send the pending text without blocking.
+func (t *textCoalescer) flush(now time.Time) {
+	if t.pending.Len() == 0 {
+		return
+	}
+	message := &api.Message{
+		ID:        t.streamID,
+		Source:    api.MessageSourceModel,
+		Type:      api.MessageTypeTextDelta,
+		Payload:   t.pending.String(),
+		Timestamp: now,
+	}
+	select {
+	case t.output <- message:
+		t.pending.Reset()
+		t.lastFlush = now
+	default:
+		// The UI is behind; keep the text and merge it into the next flush.
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestTextCoalescer(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       StreamOptions
+		bufferSize int
+		// deltas are added one per step, advancing the clock by step.
+		deltas []string
+		step   time.Duration
+		want   []string
+		// wantPending is the text still buffered at the end.
+		wantPending string
+	}{
+		{
+			name:       "disabled",
+			opts:       StreamOptions{},
+			bufferSize: 10,
+			deltas:     []string{"a", "b", "c"},
+			step:       time.Second,
+			want:       nil,
+		},
+		{
+			name:       "flush on interval",
+			opts:       StreamOptions{FlushInterval: 100 * time.Millisecond},
+			bufferSize: 10,
+			deltas:     []string{"a", "b", "c", "d", "e"},
+			step:       50 * time.Millisecond,
+			want:       []string{"abc", "de"},
+		},
+		{
+			name:        "flush on bytes",
+			opts:        StreamOptions{FlushInterval: time.Hour, FlushBytes: 4},
+			bufferSize:  10,
+			deltas:      []string{"ab", "cd", "e", "fgh", "i"},
+			step:        time.Millisecond,
+			want:        []string{"abcd", "efgh"},
+			wantPending: "i",
+		},
+		{
+			name:        "merge when output is full",
+			opts:        StreamOptions{FlushBytes: 1},
+			bufferSize:  1,
+			deltas:      []string{"a", "b", "c"},
+			step:        time.Millisecond,
+			want:        []string{"a"},
+			wantPending: "bc",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			output := make(chan any, tc.bufferSize)
+			c := newTextCoalescer(tc.opts, output)
+			now := time.Unix(0, 0)
+			c.now = func() time.Time { return now }
+
+			for _, delta := range tc.deltas {
+				now = now.Add(tc.step)
+				c.Add(delta)
+			}
+			close(output)
+
+			var got []string
+			for msg := range output {
+				m := msg.(*api.Message)
+				if m.Type != api.MessageTypeTextDelta {
+					t.Errorf("unexpected message type %q", m.Type)
+				}
+				if m.ID != c.streamID {
+					t.Errorf("message ID = %q, want stream ID %q", m.ID, c.streamID)
+				}
+				got = append(got, m.Payload.(string))
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got deltas %q, want %q", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("delta %d = %q, want %q", i, got[i], tc.want[i])
+				}
+			}
+			if got := c.pending.String(); got != tc.wantPending {
+				t.Errorf("pending = %q, want %q", got, tc.wantPending)
+			}
+		})
+	}
+}
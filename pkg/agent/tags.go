@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+const tagsUsage = "Usage: tags | tag add TAG | tag remove TAG"
+
+// ValidateTag checks that a tag is a single word, e.g. incident-1234, so that
+// tags can be given as a comma-separated list.
+func ValidateTag(tag string) error {
+	if tag == "" || strings.ContainsAny(tag, ", \t\n") {
+		return fmt.Errorf("invalid tag %q, tags are single words, e.g. incident-1234", tag)
+	}
+	return nil
+}
+
+// handleTagQuery implements the tag meta commands, which show and change the
+// tags organizing the session, e.g. by incident, team or cluster.
+func (c *Agent) handleTagQuery(query string) (answer string, handled bool, err error) {
+	command, rest, _ := strings.Cut(strings.TrimSpace(query), " ")
+	if command == "tags" && rest == "" {
+		return c.describeTags(), true, nil
+	}
+	action, arg, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	arg = strings.TrimSpace(arg)
+	switch {
+	case command == "tag" && action == "add" && arg != "":
+		if err := ValidateTag(arg); err != nil {
+			return err.Error(), true, nil
+		}
+		if err := c.AddTag(arg); err != nil {
+			return "", false, err
+		}
+	case command == "tag" && action == "remove" && arg != "":
+		if err := c.RemoveTag(arg); err != nil {
+			return err.Error(), true, nil
+		}
+	default:
+		return tagsUsage, true, nil
+	}
+	return c.describeTags(), true, nil
+}
+
+// SessionTags returns the tags of the session.
+func (c *Agent) SessionTags() []string {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return slices.Clone(c.tags)
+}
+
+// AddTag tags the session, if it isn't already.
+func (c *Agent) AddTag(tag string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if slices.Contains(c.tags, tag) {
+		return nil
+	}
+	c.tags = append(c.tags, tag)
+	return c.saveTags()
+}
+
+// RemoveTag removes a tag of the session.
+func (c *Agent) RemoveTag(tag string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	i := slices.Index(c.tags, tag)
+	if i < 0 {
+		return fmt.Errorf("the session isn't tagged %q", tag)
+	}
+	c.tags = slices.Delete(c.tags, i, i+1)
+	return c.saveTags()
+}
+
+// saveTags records the tags in the session metadata, sessionMu must be held.
+func (c *Agent) saveTags() error {
+	if s, ok := c.ChatMessageStore.(*sessions.Session); ok {
+		if err := s.SetTags(c.tags); err != nil {
+			return fmt.Errorf("saving session tags: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Agent) describeTags() string {
+	tags := c.SessionTags()
+	if len(tags) == 0 {
+		return "The session has no tags. " + tagsUsage
+	}
+	return "Session tags: " + strings.Join(tags, ", ")
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// shimFallbackMessage tells the user that the session switched to the tool-use shim.
+const shimFallbackMessage = "The model doesn't support tools, switching to the tool-use shim for the rest of the session."
+
+// ignoredToolsError returns an error wrapping gollm.ErrToolsNotSupported when
+// the final answer of the model shows that it ignores the tools declared to
+// it: an empty answer to a query, or a tool call written as text. Models
+// without tool support otherwise leave the user with empty answers.
+func (c *Agent) ignoredToolsError(answer string) error {
+	if c.EnableToolUseShim || len(c.Tools.AllTools()) == 0 {
+		return nil
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		// Models may have nothing to add after the results of their tools.
+		if c.currIteration > 0 {
+			return nil
+		}
+		return fmt.Errorf("%w: it returned an empty answer", gollm.ErrToolsNotSupported)
+	}
+	if name := textToolCall(answer); name != "" && c.Tools.Lookup(name) != nil {
+		return fmt.Errorf("%w: it wrote a call of the %s tool as text instead of calling it", gollm.ErrToolsNotSupported, name)
+	}
+	return nil
+}
+
+// textToolCall returns the name of the tool called by a tool call written as
+// JSON, e.g. {"name": "kubectl", "arguments": {...}}, optionally in a code
+// block, or "" if the text isn't one.
+func textToolCall(text string) string {
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimSuffix(text, "```")
+		if _, rest, ok := strings.Cut(text, "\n"); ok {
+			text = rest
+		}
+	}
+	var call struct {
+		Name       string          `json:"name"`
+		Arguments  json.RawMessage `json:"arguments"`
+		Parameters json.RawMessage `json:"parameters"`
+		Args       json.RawMessage `json:"args"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &call); err != nil {
+		return ""
+	}
+	if call.Arguments == nil && call.Parameters == nil && call.Args == nil {
+		return ""
+	}
+	return call.Name
+}
+
+// fallBackToShim switches the rest of the session to the tool-use shim when
+// the model doesn't support tools, if enabled, and sends content to the model
+// again. It reports whether it did.
+func (c *Agent) fallBackToShim(ctx context.Context, err error, content []any) bool {
+	if !c.ToolUseShimFallback || c.EnableToolUseShim || !errors.Is(err, gollm.ErrToolsNotSupported) {
+		return false
+	}
+	log := klog.FromContext(ctx)
+
+	prevPrompt := c.systemPrompt
+	c.EnableToolUseShim = true
+	systemPrompt, promptErr := c.defaultSystemPrompt(ctx, c.workDir)
+	if promptErr == nil {
+		if c.versionSkew != nil {
+			systemPrompt += versionSkewPrompt(c.versionSkew)
+		}
+		c.systemPrompt = systemPrompt
+		promptErr = c.startChat(c.chatModel)
+	}
+	if promptErr != nil {
+		log.Error(promptErr, "error switching to the tool-use shim")
+		c.EnableToolUseShim = false
+		c.systemPrompt = prevPrompt
+		return false
+	}
+	log.Info("The model doesn't support tools, switched to the tool-use shim", "err", err)
+
+	// The shim gives the results of the tools to the model as text.
+	for i, part := range content {
+		if result, ok := part.(gollm.FunctionCallResult); ok {
+			content[i] = fmt.Sprintf("Result of running %q:\n%v", result.Name, result.Result)
+		}
+	}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, shimFallbackMessage)
+	c.currChatContent = content
+	c.pendingFunctionCalls = []ToolCallAnalysis{}
+	c.setAgentState(api.AgentStateRunning)
+	return true
+}
@@ -0,0 +1,241 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"sigs.k8s.io/yaml"
+)
+
+// toolNamePattern matches the names accepted for functions by the providers.
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// toolWizard tracks the interview of the "new-tool" meta command, which
+// creates a custom tool wrapping a command.
+type toolWizard struct {
+	// step is the index of the current question in toolWizardSteps, or
+	// len(toolWizardSteps) when the user is asked to save the tool.
+	step   int
+	config tools.CustomToolConfig
+	// entry is the generated configuration of the tool, once validated.
+	entry []byte
+}
+
+// toolWizardStep is a question of the interview.
+type toolWizardStep struct {
+	question string
+	// answer records the answer into the configuration, or returns why it is invalid.
+	answer func(c *Agent, config *tools.CustomToolConfig, answer string) error
+}
+
+var toolWizardSteps = []toolWizardStep{
+	{
+		question: "Which command do you want to wrap, e.g. `gcloud`?",
+		answer: func(c *Agent, config *tools.CustomToolConfig, answer string) error {
+			if strings.ContainsAny(answer, " \t|;&") {
+				return fmt.Errorf("give the command only, without arguments")
+			}
+			config.Command = answer
+			return nil
+		},
+	},
+	{
+		question: "What should the tool be called? The model calls it by this name. Answer `default` to name it after the command.",
+		answer: func(c *Agent, config *tools.CustomToolConfig, answer string) error {
+			name := answer
+			if name == "default" {
+				name = config.Command
+			}
+			if !toolNamePattern.MatchString(name) {
+				return fmt.Errorf("the name can only contain letters, digits, `_` and `-`")
+			}
+			if c.Tools.Lookup(name) != nil {
+				return fmt.Errorf("a tool named %q already exists", name)
+			}
+			config.Name = name
+			return nil
+		},
+	},
+	{
+		question: "What is the tool for? The model reads this description to decide when to use it.",
+		answer: func(c *Agent, config *tools.CustomToolConfig, answer string) error {
+			config.Description = answer
+			return nil
+		},
+	},
+	{
+		question: "How should the model call it? Give usage notes or example commands, or answer `skip`.",
+		answer: func(c *Agent, config *tools.CustomToolConfig, answer string) error {
+			if answer != "skip" {
+				config.CommandDesc = answer
+			}
+			return nil
+		},
+	},
+	{
+		question: "Does the command modify resources? Answer `yes`, `no` or `unknown`. Commands that may modify resources are confirmed before they run.",
+		answer: func(c *Agent, config *tools.CustomToolConfig, answer string) error {
+			switch answer = strings.ToLower(answer); answer {
+			case "yes", "no", "unknown":
+				config.ModifiesResource = answer
+				return nil
+			}
+			return fmt.Errorf("answer `yes`, `no` or `unknown`")
+		},
+	},
+	{
+		question: "How long may a call run, e.g. `2m`? Answer `none` for no limit.",
+		answer: func(c *Agent, config *tools.CustomToolConfig, answer string) error {
+			if answer == "none" {
+				return nil
+			}
+			d, err := time.ParseDuration(answer)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("give a duration like `30s` or `5m`")
+			}
+			config.Timeout = answer
+			return nil
+		},
+	},
+	{
+		question: "Does the command print a JSON object? List its fields as `name:type`, separated by commas, e.g. `name:string, ready:boolean`, or answer `skip`.",
+		answer: func(c *Agent, config *tools.CustomToolConfig, answer string) error {
+			if answer == "skip" {
+				return nil
+			}
+			schema, err := parseOutputFields(answer)
+			if err != nil {
+				return err
+			}
+			config.OutputSchema = schema
+			return nil
+		},
+	},
+}
+
+// parseOutputFields parses a list of `name:type` fields into the schema of a JSON object.
+func parseOutputFields(fields string) (*gollm.Schema, error) {
+	schema := &gollm.Schema{Type: gollm.TypeObject, Properties: map[string]*gollm.Schema{}}
+	for _, field := range strings.Split(fields, ",") {
+		name, t, ok := strings.Cut(strings.TrimSpace(field), ":")
+		name, typ := strings.TrimSpace(name), gollm.SchemaType(strings.TrimSpace(t))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("give the fields as `name:type`, got %q", field)
+		}
+		switch typ {
+		case gollm.TypeString, gollm.TypeBoolean, gollm.TypeNumber, gollm.TypeInteger, gollm.TypeObject, gollm.TypeArray:
+		default:
+			return nil, fmt.Errorf("unknown type %q for field %q, use string, boolean, number, integer, object or array", typ, name)
+		}
+		schema.Properties[name] = &gollm.Schema{Type: typ}
+	}
+	return schema, nil
+}
+
+// startToolWizard starts the interview of the "new-tool" meta command.
+func (c *Agent) startToolWizard() string {
+	if c.RunOnce {
+		return "`new-tool` asks questions, and is not available when running a single query."
+	}
+	c.toolWizard = &toolWizard{}
+	return "Let's create a custom tool wrapping a command. Answer `cancel` at any time to stop.\n\n" + toolWizardSteps[0].question
+}
+
+// continueToolWizard handles an answer of the user during the interview of
+// the "new-tool" meta command, and returns the next question.
+func (c *Agent) continueToolWizard(answer string) string {
+	w := c.toolWizard
+	answer = strings.TrimSpace(answer)
+	if strings.EqualFold(answer, "cancel") {
+		c.toolWizard = nil
+		return "Cancelled the creation of the tool."
+	}
+	if answer == "" {
+		return "Please answer the question, or `cancel`."
+	}
+
+	if w.step == len(toolWizardSteps) {
+		return c.saveToolWizard(answer)
+	}
+	if err := toolWizardSteps[w.step].answer(c, &w.config, answer); err != nil {
+		return fmt.Sprintf("%s. %s", capitalize(err.Error()), toolWizardSteps[w.step].question)
+	}
+	w.step++
+	if w.step < len(toolWizardSteps) {
+		return toolWizardSteps[w.step].question
+	}
+
+	entry, err := yaml.Marshal([]tools.CustomToolConfig{w.config})
+	if err == nil {
+		_, err = tools.ParseCustomTools(entry)
+	}
+	if err != nil {
+		c.toolWizard = nil
+		return fmt.Sprintf("The generated tool is invalid: %v", err)
+	}
+	w.entry = entry
+
+	summary := "Here is the configuration of the tool:\n\n```yaml\n" + string(entry) + "```\n\n"
+	if c.CustomToolsPath == "" {
+		c.toolWizard = nil
+		return summary + "Add it to your custom tools configuration file, given with --custom-tools-config, to use it."
+	}
+	return summary + fmt.Sprintf("Save it to %s and enable it now? (yes/no)", c.CustomToolsPath)
+}
+
+// saveToolWizard persists the tool generated by the interview, and makes it
+// available to the model.
+func (c *Agent) saveToolWizard(answer string) string {
+	w := c.toolWizard
+	switch strings.ToLower(answer) {
+	case "yes", "y":
+	case "no", "n":
+		c.toolWizard = nil
+		return "The tool was not saved. You can add the configuration above to your custom tools configuration file later."
+	default:
+		return "Please answer `yes` or `no`."
+	}
+	c.toolWizard = nil
+
+	path, err := tools.SaveCustomToolConfig(c.CustomToolsPath, w.config)
+	if err != nil {
+		return fmt.Sprintf("Failed to save the tool: %v", err)
+	}
+	created, err := tools.ParseCustomTools(w.entry)
+	if err != nil {
+		return fmt.Sprintf("Saved the tool to %s, but failed to enable it: %v", path, err)
+	}
+	for _, tool := range created {
+		c.Tools.RegisterTool(tool)
+	}
+	if err := c.setFunctionDefinitions(); err != nil {
+		return fmt.Sprintf("Saved the tool to %s, but failed to enable it: %v", path, err)
+	}
+	return fmt.Sprintf("Saved the tool %q to %s. It is available from now on.", w.config.Name, path)
+}
+
+// capitalize upper-cases the first letter of s.
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
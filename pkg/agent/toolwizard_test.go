@@ -0,0 +1,87 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+func TestToolWizard(t *testing.T) {
+	var ts tools.Tools
+	ts.Init()
+	path := filepath.Join(t.TempDir(), "tools.yaml")
+	a := &Agent{Tools: ts, CustomToolsPath: path, EnableToolUseShim: true}
+
+	a.startToolWizard()
+	answers := []struct {
+		answer   string
+		expected string
+	}{
+		{answer: "gcloud compute", expected: "Give the command only"},
+		{answer: "gcloud", expected: "What should the tool be called?"},
+		{answer: "default", expected: "What is the tool for?"},
+		{answer: "Manages Google Cloud resources.", expected: "How should the model call it?"},
+		{answer: "skip", expected: "Does the command modify resources?"},
+		{answer: "maybe", expected: "Answer `yes`, `no` or `unknown`"},
+		{answer: "yes", expected: "How long may a call run"},
+		{answer: "2m", expected: "Does the command print a JSON object?"},
+		{answer: "name:text", expected: `Unknown type "text"`},
+		{answer: "name:string, ready:boolean", expected: "Save it to " + path},
+		{answer: "yes", expected: `Saved the tool "gcloud"`},
+	}
+	for _, tc := range answers {
+		if got := a.continueToolWizard(tc.answer); !strings.Contains(got, tc.expected) {
+			t.Fatalf("answering %q: expected %q in %q", tc.answer, tc.expected, got)
+		}
+	}
+	if a.toolWizard != nil {
+		t.Errorf("the wizard is still in progress after saving the tool")
+	}
+
+	tool := a.Tools.Lookup("gcloud")
+	if tool == nil {
+		t.Fatalf("the tool was not registered")
+	}
+	if got := tool.CheckModifiesResource(nil); got != "yes" {
+		t.Errorf("CheckModifiesResource() = %q, expected yes", got)
+	}
+	if schema := tools.OutputSchemaOf(tool); schema == nil || schema.Properties["result"] == nil || len(schema.Properties["result"].Properties) != 2 {
+		t.Errorf("unexpected output schema %+v", schema)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading saved tools: %v", err)
+	}
+	saved, err := tools.ParseCustomTools(data)
+	if err != nil || len(saved) != 1 || saved[0].Name() != "gcloud" {
+		t.Errorf("unexpected saved tools %v (error %v):\n%s", saved, err, data)
+	}
+
+	// Tools can't be defined twice.
+	a.startToolWizard()
+	a.continueToolWizard("gcloud")
+	if got := a.continueToolWizard("default"); !strings.Contains(got, "already exists") {
+		t.Errorf("expected the duplicate name to be rejected, got %q", got)
+	}
+	if got := a.continueToolWizard("cancel"); a.toolWizard != nil || !strings.Contains(got, "Cancelled") {
+		t.Errorf("expected the wizard to be cancelled, got %q", got)
+	}
+}
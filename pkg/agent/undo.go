@@ -0,0 +1,242 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// The last change applied with the apply_manifest tool is kept in the undo
+// directory of the working directory, for the "undo-last-change" meta command.
+const (
+	undoDir = "undo"
+	// lastChangeFile describes the change.
+	lastChangeFile = "last-change.json"
+	// previousObjectsFile lists the objects changed, as they were before.
+	previousObjectsFile = "previous.json"
+	// createdObjectsFile lists the objects created.
+	createdObjectsFile = "created.json"
+)
+
+// undoLimitations explains what undoing a change doesn't restore.
+const undoLimitations = `Limitations:
+- Only the last change applied with the apply_manifest tool is kept, changes made with kubectl commands or other tools can't be undone.
+- The objects changed are applied again as they were, with server-side apply, forcing the conflicts: changes made to them since, e.g. by controllers, are overwritten.
+- Fields added by the change are only removed if no other field manager owns them.
+- The objects created by the change are deleted. Objects deleted since, and the state of workloads (e.g. the pods replaced by a rollout), are not restored.`
+
+// manifestChange describes the last change applied with the apply_manifest tool.
+type manifestChange struct {
+	Time time.Time `json:"time"`
+	// Applied lists the objects applied, as printed by kubectl.
+	Applied []string `json:"applied"`
+	// Previous is the number of objects changed, stored in previousObjectsFile.
+	Previous int `json:"previous"`
+	// Created are the objects created, stored in createdObjectsFile.
+	Created []tools.ObjectRef `json:"created,omitempty"`
+	Undone  bool              `json:"undone,omitempty"`
+}
+
+// undoCommands returns the kubectl commands undoing the change, run in the
+// working directory.
+func (m *manifestChange) undoCommands() []string {
+	var commands []string
+	if m.Previous > 0 {
+		commands = append(commands, fmt.Sprintf("kubectl apply --server-side --force-conflicts --field-manager=%s -f %s", tools.FieldManager, filepath.Join(undoDir, previousObjectsFile)))
+	}
+	if len(m.Created) > 0 {
+		commands = append(commands, "kubectl delete --ignore-not-found -f "+filepath.Join(undoDir, createdObjectsFile))
+	}
+	return commands
+}
+
+// isUndoQuery reports whether query is the "undo-last-change" meta command,
+// also accepted as "/undo-last-change".
+func isUndoQuery(query string) bool {
+	return strings.TrimPrefix(strings.TrimSpace(query), "/") == "undo-last-change"
+}
+
+// manifestRevision gets the objects changed by an apply_manifest call before
+// it runs, or returns nil for the other calls.
+func (c *Agent) manifestRevision(ctx context.Context, call ToolCallAnalysis) *tools.ManifestRevision {
+	if call.FunctionCall.Name != "apply_manifest" || c.workDir == "" {
+		return nil
+	}
+	revision, err := tools.GetManifestRevision(ctx, call.FunctionCall.Arguments, tools.InvokeToolOptions{
+		Kubeconfig: c.Kubeconfig,
+		WorkDir:    c.workDir,
+		Env:        c.env,
+	})
+	if err != nil {
+		klog.FromContext(ctx).Info("the change can't be undone, error getting the objects of the manifest", "err", err)
+		return nil
+	}
+	return revision
+}
+
+// recordChange keeps the objects changed by a successful apply_manifest call
+// as they were before, and records when the last change is undone.
+func (c *Agent) recordChange(call ToolCallAnalysis, output any, revision *tools.ManifestRevision) {
+	if call.UserInitiated {
+		if result, ok := output.(*tools.ExecResult); ok && result.ExitCode == 0 && result.Error == "" {
+			c.recordUndo(call)
+		}
+		return
+	}
+	result, ok := output.(*tools.ApplyManifestResult)
+	if revision == nil || !ok || result.DryRun || result.Error != "" || len(result.Applied) == 0 {
+		return
+	}
+	change := &manifestChange{Time: time.Now(), Applied: result.Applied, Previous: len(revision.Objects), Created: revision.Missing}
+	if err := c.saveChange(change, revision); err != nil {
+		klog.Warningf("error saving the change for undo-last-change: %v", err)
+	}
+}
+
+// recordUndo marks the last change as undone once its undo commands ran.
+func (c *Agent) recordUndo(call ToolCallAnalysis) {
+	change, err := c.lastChange()
+	if err != nil || change == nil || change.Undone {
+		return
+	}
+	command, _ := call.FunctionCall.Arguments["command"].(string)
+	commands := change.undoCommands()
+	if len(commands) == 0 || command != commands[len(commands)-1] {
+		return
+	}
+	change.Undone = true
+	if err := writeJSONFile(filepath.Join(c.workDir, undoDir, lastChangeFile), change); err != nil {
+		klog.Warningf("error saving the undone change: %v", err)
+	}
+}
+
+func (c *Agent) saveChange(change *manifestChange, revision *tools.ManifestRevision) error {
+	dir := filepath.Join(c.workDir, undoDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var created []map[string]any
+	for _, ref := range revision.Missing {
+		created = append(created, ref.Object())
+	}
+	for name, items := range map[string][]map[string]any{previousObjectsFile: revision.Objects, createdObjectsFile: created} {
+		if err := writeJSONFile(filepath.Join(dir, name), map[string]any{"apiVersion": "v1", "kind": "List", "items": items}); err != nil {
+			return err
+		}
+	}
+	// Written last, for the change to only be undone once its objects are saved.
+	return writeJSONFile(filepath.Join(dir, lastChangeFile), change)
+}
+
+// lastChange returns the last change applied with the apply_manifest tool, or
+// nil if there is none.
+func (c *Agent) lastChange() (*manifestChange, error) {
+	if c.workDir == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(filepath.Join(c.workDir, undoDir, lastChangeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var change manifestChange
+	if err := json.Unmarshal(b, &change); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", lastChangeFile, err)
+	}
+	return &change, nil
+}
+
+// undoLastChange restores the objects changed by the last apply_manifest call
+// as they were, and deletes those it created, on behalf of the user. The
+// commands are always confirmed, even when permissions are skipped.
+func (c *Agent) undoLastChange(ctx context.Context) {
+	c.pendingFunctionCalls = []ToolCallAnalysis{}
+	change, err := c.lastChange()
+	if err != nil {
+		c.setAgentState(api.AgentStateDone)
+		c.addError(ctx, err)
+		return
+	}
+	if change == nil {
+		c.setAgentState(api.AgentStateDone)
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "No change applied with the apply_manifest tool can be undone in this session.")
+		return
+	}
+	if change.Undone {
+		c.setAgentState(api.AgentStateDone)
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("The last change, applied at %s, was already undone.", change.Time.Format(time.Kitchen)))
+		return
+	}
+
+	var calls []gollm.FunctionCall
+	for _, command := range change.undoCommands() {
+		calls = append(calls, gollm.FunctionCall{
+			ID:        uuid.New().String(),
+			Name:      "kubectl",
+			Arguments: map[string]any{"command": command, "modifies_resource": "yes"},
+		})
+	}
+	analysis, err := c.analyzeToolCalls(ctx, calls)
+	if err != nil {
+		c.setAgentState(api.AgentStateDone)
+		c.addError(ctx, err)
+		return
+	}
+	for i := range analysis {
+		analysis[i].UserInitiated = true
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Undoing the change applied at %s:\n", change.Time.Format(time.Kitchen))
+	for _, applied := range change.Applied {
+		sb.WriteString("  - " + applied + "\n")
+	}
+	if len(change.Created) > 0 {
+		sb.WriteString("\nThe objects it created are deleted:\n")
+		for _, ref := range change.Created {
+			sb.WriteString("  - " + ref.Resource() + "\n")
+		}
+	}
+	sb.WriteString("\n" + undoLimitations)
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, sb.String())
+
+	c.currIteration = 0
+	c.currChatContent = nil
+	c.pendingFunctionCalls = analysis
+	c.askForApproval(ctx)
+}
+
+func writeJSONFile(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
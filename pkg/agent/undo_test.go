@@ -0,0 +1,103 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+func TestRecordChange(t *testing.T) {
+	a := &Agent{
+		workDir: t.TempDir(),
+		session: &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:  make(chan any, 10),
+	}
+	apply := ToolCallAnalysis{FunctionCall: gollm.FunctionCall{Name: "apply_manifest"}}
+	revision := &tools.ManifestRevision{
+		Objects: []map[string]any{{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]any{"name": "web"}}},
+		Missing: []tools.ObjectRef{{APIVersion: "v1", Kind: "Service", Name: "web", Namespace: "shop"}},
+	}
+
+	// Failed applies are not recorded.
+	a.recordChange(apply, &tools.ApplyManifestResult{Error: "forbidden"}, revision)
+	a.undoLastChange(context.Background())
+	if msg := lastMessage(t, a); !strings.Contains(msg, "No change") {
+		t.Errorf("undo without a change = %q, want no change", msg)
+	}
+
+	a.recordChange(apply, &tools.ApplyManifestResult{Applied: []string{"deployment.apps/web serverside-applied", "service/web serverside-applied"}}, revision)
+	change, err := a.lastChange()
+	if err != nil || change == nil {
+		t.Fatalf("lastChange() = %v, %v", change, err)
+	}
+	commands := change.undoCommands()
+	want := []string{
+		"kubectl apply --server-side --force-conflicts --field-manager=kubectl-ai -f undo/previous.json",
+		"kubectl delete --ignore-not-found -f undo/created.json",
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("undoCommands() = %q, want %q", commands, want)
+	}
+	created, err := os.ReadFile(filepath.Join(a.workDir, undoDir, createdObjectsFile))
+	if err != nil || !strings.Contains(string(created), `"namespace": "shop"`) {
+		t.Errorf("created objects = %s, %v", created, err)
+	}
+
+	// The change is undone once its last command succeeded.
+	for i, command := range commands {
+		undo := ToolCallAnalysis{FunctionCall: gollm.FunctionCall{Name: "kubectl", Arguments: map[string]any{"command": command}}, UserInitiated: true}
+		a.recordChange(undo, &tools.ExecResult{}, nil)
+		if change, _ := a.lastChange(); change.Undone != (i == len(commands)-1) {
+			t.Errorf("after %q: undone = %v", command, change.Undone)
+		}
+	}
+	a.undoLastChange(context.Background())
+	if msg := lastMessage(t, a); !strings.Contains(msg, "already undone") {
+		t.Errorf("undo of an undone change = %q, want already undone", msg)
+	}
+}
+
+func lastMessage(t *testing.T, a *Agent) string {
+	t.Helper()
+	messages := a.session.ChatMessageStore.ChatMessages()
+	if len(messages) == 0 {
+		t.Fatal("no messages")
+	}
+	text, _ := messages[len(messages)-1].Payload.(string)
+	return text
+}
+
+func TestIsUndoQuery(t *testing.T) {
+	for query, want := range map[string]bool{
+		"undo-last-change":   true,
+		" /undo-last-change": true,
+		"undo":               false,
+		"undo-last-change x": false,
+	} {
+		if got := isUndoQuery(query); got != want {
+			t.Errorf("isUndoQuery(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// validationTimeout bounds the time spent validating an answer.
+const validationTimeout = 10 * time.Second
+
+// Answer is a final answer of the agent, along with the tool calls run to
+// produce it, i.e. since the last query of the user.
+type Answer struct {
+	Text      string           `json:"text"`
+	ToolCalls []AnswerToolCall `json:"toolCalls,omitempty"`
+}
+
+// AnswerToolCall is a tool call run to produce an answer.
+type AnswerToolCall struct {
+	// Command describes the call, e.g. the kubectl command.
+	Command string `json:"command"`
+	// Output is the result of the call, as JSON for structured results.
+	Output string `json:"output,omitempty"`
+}
+
+// AnswerValidator checks the final answers of the agent before they are shown.
+// The warnings returned are shown with the answer, they don't prevent it
+// from being shown.
+type AnswerValidator interface {
+	// Name identifies the validator in logs.
+	Name() string
+	Validate(ctx context.Context, answer *Answer) ([]string, error)
+}
+
+// validateAnswer runs the validators on an answer and returns their warnings.
+// Validators failing are logged and skipped.
+func (c *Agent) validateAnswer(ctx context.Context, text string) []string {
+	validators := c.answerValidators()
+	if len(validators) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, validationTimeout)
+	defer cancel()
+
+	answer := &Answer{Text: text, ToolCalls: c.turnToolCalls()}
+	var warnings []string
+	for _, v := range validators {
+		w, err := v.Validate(ctx, answer)
+		if err != nil {
+			klog.Warningf("answer validator %s failed: %v", v.Name(), err)
+			continue
+		}
+		warnings = append(warnings, w...)
+	}
+	return warnings
+}
+
+// answerValidators returns the built-in validators, if enabled, followed by the custom ones.
+func (c *Agent) answerValidators() []AnswerValidator {
+	var validators []AnswerValidator
+	if c.ValidateAnswers {
+		validators = append(validators,
+			&resourceRefValidator{missing: func(ctx context.Context, refs []string) []string {
+				return tools.MissingResources(ctx, tools.InvokeToolOptions{Kubeconfig: c.Kubeconfig, WorkDir: c.workDir, Env: c.env}, refs)
+			}},
+			unexecutedCommandValidator{},
+			contradictionValidator{},
+		)
+	}
+	return append(validators, c.AnswerValidators...)
+}
+
+// turnToolCalls returns the tool calls run since the last query of the user.
+func (c *Agent) turnToolCalls() []AnswerToolCall {
+	c.sessionMu.Lock()
+	messages := c.session.ChatMessageStore.ChatMessages()
+	c.sessionMu.Unlock()
+
+	start := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Source == api.MessageSourceUser && messages[i].Type == api.MessageTypeText {
+			start = i + 1
+			break
+		}
+	}
+
+	var calls []AnswerToolCall
+	for _, message := range messages[start:] {
+		switch message.Type {
+		case api.MessageTypeToolCallRequest:
+			command, _ := message.Payload.(string)
+			calls = append(calls, AnswerToolCall{Command: command})
+		case api.MessageTypeToolCallResponse:
+			if len(calls) == 0 {
+				continue
+			}
+			output, ok := message.Payload.(string)
+			if !ok {
+				b, err := json.Marshal(message.Payload)
+				if err != nil {
+					continue
+				}
+				output = string(b)
+			}
+			calls[len(calls)-1].Output = output
+		}
+	}
+	return calls
+}
+
+// maxResourceChecks bounds the number of resources looked up in the cluster for an answer.
+const maxResourceChecks = 5
+
+// resourceRefPattern matches kind/name references to resources, e.g. "deployment/web".
+var resourceRefPattern = regexp.MustCompile(`\b(pods?|po|deployments?|deploy|services?|svc|statefulsets?|sts|daemonsets?|ds|replicasets?|rs|jobs?|cronjobs?|cj|configmaps?|cm|secrets?|persistentvolumeclaims?|pvc|ingresses|ingress|ing|nodes?|namespaces?|ns)/([a-z0-9]([-a-z0-9.]*[a-z0-9])?)`)
+
+// resourceRefValidator checks that the resources referenced by the answer exist.
+// References to resources seen in the tool calls of the turn are trusted.
+type resourceRefValidator struct {
+	// missing returns the references that don't exist in the cluster.
+	missing func(ctx context.Context, refs []string) []string
+}
+
+func (v *resourceRefValidator) Name() string {
+	return "resource-refs"
+}
+
+func (v *resourceRefValidator) Validate(ctx context.Context, answer *Answer) ([]string, error) {
+	var seen strings.Builder
+	for _, call := range answer.ToolCalls {
+		seen.WriteString(call.Command)
+		seen.WriteString(call.Output)
+	}
+
+	var refs []string
+	checked := map[string]bool{}
+	for _, match := range resourceRefPattern.FindAllStringSubmatch(answer.Text, -1) {
+		ref, name := match[0], match[2]
+		if checked[ref] || strings.Contains(seen.String(), name) {
+			continue
+		}
+		checked[ref] = true
+		if len(refs) < maxResourceChecks {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	var warnings []string
+	for _, ref := range v.missing(ctx, refs) {
+		warnings = append(warnings, fmt.Sprintf("%s is referenced in the answer but wasn't found in the current namespace.", ref))
+	}
+	return warnings, nil
+}
+
+// inlineCodePattern matches inline code spans; fenced code blocks are removed before.
+var inlineCodePattern = regexp.MustCompile("`([^`\n]+)`")
+
+// unexecutedCommandValidator flags kubectl commands modifying resources that
+// are mentioned in the answer but weren't run, so that the user doesn't
+// mistake them for changes made by the agent. Commands in code blocks are
+// suggestions the user can run, and are not flagged.
+type unexecutedCommandValidator struct{}
+
+func (unexecutedCommandValidator) Name() string {
+	return "unexecuted-commands"
+}
+
+func (unexecutedCommandValidator) Validate(ctx context.Context, answer *Answer) ([]string, error) {
+	var executed []string
+	for _, call := range answer.ToolCalls {
+		executed = append(executed, strings.Join(strings.Fields(call.Command), " "))
+	}
+
+	kubectl := &tools.Kubectl{}
+	var warnings []string
+	for _, match := range inlineCodePattern.FindAllStringSubmatch(removeCodeBlocks(answer.Text), -1) {
+		command := strings.Join(strings.Fields(match[1]), " ")
+		if !strings.HasPrefix(command, "kubectl ") || kubectl.CheckModifiesResource(map[string]any{"command": command}) != "yes" {
+			continue
+		}
+		run := false
+		for _, e := range executed {
+			if strings.Contains(e, command) {
+				run = true
+				break
+			}
+		}
+		if !run {
+			warnings = append(warnings, fmt.Sprintf("`%s` is mentioned in the answer but wasn't run.", command))
+		}
+	}
+	return warnings, nil
+}
+
+// removeCodeBlocks removes the fenced code blocks of markdown text.
+func removeCodeBlocks(text string) string {
+	var b strings.Builder
+	inBlock := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inBlock = !inBlock
+			continue
+		}
+		if !inBlock {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+var (
+	// healthyClaimPattern matches answers claiming that there is no problem.
+	healthyClaimPattern = regexp.MustCompile(`(?i)\b(all (the )?pods are (running|healthy|ready)|no (errors|issues|problems) (were )?(found|detected)|everything (is|looks) (healthy|fine|good|ok)|(cluster|deployment|application) is healthy)\b`)
+	// problemStatusPattern matches statuses of failing workloads in tool outputs.
+	problemStatusPattern = regexp.MustCompile(`\b(CrashLoopBackOff|ImagePullBackOff|ErrImagePull|OOMKilled|CreateContainerConfigError|CreateContainerError|Evicted)\b`)
+)
+
+// contradictionValidator flags answers claiming that everything is fine
+// while the tool outputs show failing workloads.
+type contradictionValidator struct{}
+
+func (contradictionValidator) Name() string {
+	return "contradictions"
+}
+
+func (contradictionValidator) Validate(ctx context.Context, answer *Answer) ([]string, error) {
+	claim := healthyClaimPattern.FindString(answer.Text)
+	if claim == "" {
+		return nil, nil
+	}
+	for _, call := range answer.ToolCalls {
+		if status := problemStatusPattern.FindString(call.Output); status != "" {
+			return []string{fmt.Sprintf("The answer says %q, but the output of `%s` shows %s.", claim, call.Command, status)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// CommandValidatorConfig configures a custom answer validator run as an
+// external command, e.g. to check organization rules. The command is run with
+// bash and receives the Answer as JSON on stdin; each line it prints is a warning.
+type CommandValidatorConfig struct {
+	Name string `json:"name"`
+	// Command is the shell command to run.
+	Command string `json:"command"`
+	// TimeoutSeconds bounds the run time of the command, 10 seconds by default.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// NewCommandValidator returns the validator running the configured command.
+func NewCommandValidator(config CommandValidatorConfig) (AnswerValidator, error) {
+	if strings.TrimSpace(config.Command) == "" {
+		return nil, fmt.Errorf("answer validator %q: command is required", config.Name)
+	}
+	if config.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("answer validator %q: timeoutSeconds must not be negative", config.Name)
+	}
+	if config.Name == "" {
+		config.Name = config.Command
+	}
+	return &commandValidator{config: config}, nil
+}
+
+type commandValidator struct {
+	config CommandValidatorConfig
+}
+
+func (v *commandValidator) Name() string {
+	return v.config.Name
+}
+
+func (v *commandValidator) Validate(ctx context.Context, answer *Answer) ([]string, error) {
+	input, err := json.Marshal(answer)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling answer: %w", err)
+	}
+	if v.config.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(v.config.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", v.config.Command)
+	cmd.Env = os.Environ()
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running %q: %w: %s", v.config.Command, err, msg)
+		}
+		return nil, fmt.Errorf("running %q: %w", v.config.Command, err)
+	}
+
+	var warnings []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			warnings = append(warnings, line)
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestAnswerValidators(t *testing.T) {
+	getPods := AnswerToolCall{
+		Command: "kubectl get pods",
+		Output:  `{"stdout":"NAME    READY   STATUS             RESTARTS\nweb-0   0/1     CrashLoopBackOff   5\n"}`,
+	}
+	// Only pod/ghost is missing from the cluster.
+	missing := func(ctx context.Context, refs []string) []string {
+		var missing []string
+		for _, ref := range refs {
+			if ref == "pod/ghost" {
+				missing = append(missing, ref)
+			}
+		}
+		return missing
+	}
+
+	tests := []struct {
+		name      string
+		validator AnswerValidator
+		answer    *Answer
+		want      []string
+	}{
+		{
+			name:      "missing resource",
+			validator: &resourceRefValidator{missing: missing},
+			answer:    &Answer{Text: "The pod/ghost and deployment/api are fine.", ToolCalls: []AnswerToolCall{getPods}},
+			want:      []string{"pod/ghost is referenced in the answer but wasn't found in the current namespace."},
+		},
+		{
+			name:      "resource seen in tool output",
+			validator: &resourceRefValidator{missing: func(ctx context.Context, refs []string) []string { return refs }},
+			answer:    &Answer{Text: "pod/web-0 is crashing.", ToolCalls: []AnswerToolCall{getPods}},
+		},
+		{
+			name:      "unexecuted command",
+			validator: unexecutedCommandValidator{},
+			answer:    &Answer{Text: "I restarted it with `kubectl delete pod web-0` and checked `kubectl get pods`.", ToolCalls: []AnswerToolCall{getPods}},
+			want:      []string{"`kubectl delete pod web-0` is mentioned in the answer but wasn't run."},
+		},
+		{
+			name:      "executed command",
+			validator: unexecutedCommandValidator{},
+			answer: &Answer{Text: "I restarted it with `kubectl delete pod web-0`.", ToolCalls: []AnswerToolCall{
+				{Command: "kubectl  delete pod web-0 -n default"},
+			}},
+		},
+		{
+			name:      "suggested command",
+			validator: unexecutedCommandValidator{},
+			answer:    &Answer{Text: "To restart it, run:\n```bash\nkubectl delete pod web-0\n```\n"},
+		},
+		{
+			name:      "contradiction",
+			validator: contradictionValidator{},
+			answer:    &Answer{Text: "All pods are running.", ToolCalls: []AnswerToolCall{getPods}},
+			want:      []string{"The answer says \"All pods are running\", but the output of `kubectl get pods` shows CrashLoopBackOff."},
+		},
+		{
+			name:      "no contradiction",
+			validator: contradictionValidator{},
+			answer:    &Answer{Text: "web-0 is in CrashLoopBackOff.", ToolCalls: []AnswerToolCall{getPods}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.validator.Validate(context.Background(), tt.answer)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandValidator(t *testing.T) {
+	v, err := NewCommandValidator(CommandValidatorConfig{
+		Name:    "no-deletions",
+		Command: `grep -q 'kubectl delete' && echo "Deletions need a change ticket" || true`,
+	})
+	if err != nil {
+		t.Fatalf("NewCommandValidator() error = %v", err)
+	}
+	got, err := v.Validate(context.Background(), &Answer{Text: "Run `kubectl delete pod web-0`."})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if want := []string{"Deletions need a change ticket"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %q, want %q", got, want)
+	}
+
+	if _, err := NewCommandValidator(CommandValidatorConfig{Name: "empty"}); err == nil {
+		t.Errorf("NewCommandValidator() without a command succeeded")
+	}
+}
+
+func TestTurnToolCalls(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	for _, m := range []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "first query"},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get nodes"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: "node-1"},
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "second query"},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get pods"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: map[string]any{"stdout": "web-0"}},
+	} {
+		store.AddChatMessage(m)
+	}
+	a := &Agent{session: &api.Session{ChatMessageStore: store}}
+
+	want := []AnswerToolCall{{Command: "kubectl get pods", Output: `{"stdout":"web-0"}`}}
+	if got := a.turnToolCalls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("turnToolCalls() = %+v, want %+v", got, want)
+	}
+}
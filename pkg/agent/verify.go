@@ -0,0 +1,214 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxVerificationChecks bounds the number of commands re-run to verify a fix.
+	maxVerificationChecks = 5
+	// maxVerificationOutput bounds the output of each check given to the model.
+	maxVerificationOutput = 4096
+	// verificationTimeout bounds the whole verification of a fix.
+	verificationTimeout = 2 * time.Minute
+)
+
+// Verdicts of the verification of a fix, stored in the changelog of the session.
+const (
+	VerdictFixed        = "verified fixed"
+	VerdictPersists     = "symptom persists"
+	VerdictInconclusive = "inconclusive"
+)
+
+const verificationPrompt = `You verify whether a fix applied to a Kubernetes cluster worked.
+You are given the request of the user, and read-only commands that observed the
+problem before the fix, with their output before and after the fix.
+
+Answer with exactly one of these on the first line:
+VERIFIED FIXED
+SYMPTOM PERSISTS
+INCONCLUSIVE
+followed by one sentence explaining why. Do not call any tool.`
+
+// verificationCheck is a read-only command run before the first change of a
+// query, which observed the original symptom.
+type verificationCheck struct {
+	call   *tools.ToolCall
+	before string
+}
+
+// remediation tracks the tool calls of a query, to verify the fix once the
+// changes are applied.
+type remediation struct {
+	// query is the request of the user.
+	query  string
+	checks []verificationCheck
+	// mutated is true once a command changing resources has run.
+	mutated bool
+	// firstChange is the index of the first change of the query in the usage of the session.
+	firstChange int
+}
+
+// record accounts an executed tool call of the query.
+func (r *remediation) record(call ToolCallAnalysis, output any, firstChange int) {
+	if call.ModifiesResourceStr != "no" {
+		if !r.mutated {
+			r.mutated = true
+			r.firstChange = firstChange
+		}
+		return
+	}
+	// Checks are the read-only cluster queries run before the fix.
+	if r.mutated || len(r.checks) >= maxVerificationChecks || call.UserInitiated {
+		return
+	}
+	command, _ := call.FunctionCall.Arguments["command"].(string)
+	if len(tools.ParseKubectlCommands(command)) == 0 {
+		return
+	}
+	description := call.ParsedToolCall.Description()
+	for _, check := range r.checks {
+		if check.call.Description() == description {
+			return
+		}
+	}
+	r.checks = append(r.checks, verificationCheck{call: call.ParsedToolCall, before: verificationOutput(output)})
+}
+
+// verificationOutput renders the output of a tool call for the verification prompt.
+func verificationOutput(output any) string {
+	var s string
+	switch v := output.(type) {
+	case string:
+		s = v
+	case *tools.ExecResult:
+		if v == nil {
+			return ""
+		}
+		s = v.Stdout
+		if v.Stderr != "" {
+			s += "\n" + v.Stderr
+		}
+		if v.Error != "" {
+			s += "\n" + v.Error
+		}
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		s = string(b)
+	}
+	if len(s) > maxVerificationOutput {
+		s = s[:maxVerificationOutput] + "\n[output truncated]"
+	}
+	return s
+}
+
+// parseVerdict returns the verdict and its explanation from the response of the model.
+func parseVerdict(response string) (string, string) {
+	first, rest, _ := strings.Cut(strings.TrimSpace(response), "\n")
+	first = strings.TrimLeft(strings.TrimSpace(first), "*# ")
+	for keyword, verdict := range map[string]string{
+		"VERIFIED FIXED":   VerdictFixed,
+		"SYMPTOM PERSISTS": VerdictPersists,
+	} {
+		if len(first) >= len(keyword) && strings.EqualFold(first[:len(keyword)], keyword) {
+			reason := strings.TrimSpace(strings.TrimLeft(first[len(keyword):], "*:.- ") + "\n" + rest)
+			return verdict, reason
+		}
+	}
+	return VerdictInconclusive, strings.TrimSpace(rest)
+}
+
+// verifyRemediation re-runs the read-only commands that observed the symptom
+// once the changes of the query are applied, asks the model whether the
+// symptom is gone, reports the verdict to the user and records it in the
+// changelog of the session.
+func (c *Agent) verifyRemediation(ctx context.Context) {
+	r := c.remediation
+	c.remediation = remediation{}
+	if !c.VerifyRemediation || !r.mutated || len(r.checks) == 0 {
+		return
+	}
+	log := klog.FromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, verificationTimeout)
+	defer cancel()
+
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Verifying the fix by re-running %d read-only check(s)...", len(r.checks)))
+
+	c.sendProgress(api.ProgressPhaseVerifying, "")
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Request of the user:\n%s\n", r.query)
+	for i, check := range r.checks {
+		output, err := check.call.InvokeTool(ctx, tools.InvokeToolOptions{
+			Kubeconfig:   c.Kubeconfig,
+			WorkDir:      c.workDir,
+			Env:          c.env,
+			OutputBudget: c.KubectlOutputBudget,
+		})
+		after := verificationOutput(output)
+		if err != nil {
+			after = "error: " + err.Error()
+		}
+		fmt.Fprintf(&prompt, "\nCheck %d: %s\nBefore the fix:\n%s\nAfter the fix:\n%s\n", i+1, check.call.Description(), check.before, after)
+	}
+
+	verdict, reason := VerdictInconclusive, ""
+	chat := c.startAccountedChat(verificationPrompt, c.Model)
+	response, err := chat.Send(ctx, prompt.String())
+	if err != nil {
+		log.Error(err, "error asking the model to verify the fix")
+		reason = "the model could not be asked: " + err.Error()
+	} else if text := responseText(response); text != "" {
+		verdict, reason = parseVerdict(text)
+	}
+
+	message := "Verification: " + verdict
+	if reason != "" {
+		message += " - " + reason
+	}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, message)
+
+	if c.usage != nil {
+		for i := r.firstChange; i < len(c.usage.ResourcesModified); i++ {
+			c.usage.ResourcesModified[i].Verification = verdict
+		}
+	}
+}
+
+// responseText returns the text of the first candidate of a response.
+func responseText(response gollm.ChatResponse) string {
+	var text strings.Builder
+	if candidates := response.Candidates(); len(candidates) > 0 {
+		for _, part := range candidates[0].Parts() {
+			if t, ok := part.AsText(); ok {
+				text.WriteString(t)
+			}
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func TestParseVerdict(t *testing.T) {
+	tests := []struct {
+		response    string
+		wantVerdict string
+		wantReason  string
+	}{
+		{
+			response:    "VERIFIED FIXED\nThe pod is now Running.",
+			wantVerdict: VerdictFixed,
+			wantReason:  "The pod is now Running.",
+		},
+		{
+			response:    "**Symptom persists**: the pod is still in CrashLoopBackOff.",
+			wantVerdict: VerdictPersists,
+			wantReason:  "the pod is still in CrashLoopBackOff.",
+		},
+		{
+			response:    "INCONCLUSIVE\nThe check failed after the fix.",
+			wantVerdict: VerdictInconclusive,
+			wantReason:  "The check failed after the fix.",
+		},
+		{
+			response:    "I think it worked.",
+			wantVerdict: VerdictInconclusive,
+		},
+	}
+
+	for _, tt := range tests {
+		verdict, reason := parseVerdict(tt.response)
+		if verdict != tt.wantVerdict || reason != tt.wantReason {
+			t.Errorf("parseVerdict(%q) = %q, %q, want %q, %q", tt.response, verdict, reason, tt.wantVerdict, tt.wantReason)
+		}
+	}
+}
+
+func TestVerifyRemediation(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	// The check sees the pod crashing before the fix, and running after it.
+	mt := mocks.NewMockTool(ctrl)
+	mt.EXPECT().Name().Return("kubectl").AnyTimes()
+	mt.EXPECT().Run(gomock.Any(), gomock.Any()).Return("web-1   1/1   Running", nil).Times(1)
+	var ts tools.Tools
+	ts.Init()
+	ts.RegisterTool(mt)
+
+	parse := func(command string) *tools.ToolCall {
+		call, err := ts.ParseToolInvocation(ctx, "kubectl", map[string]any{"command": command})
+		if err != nil {
+			t.Fatalf("ParseToolInvocation: %v", err)
+		}
+		return call
+	}
+	calls := []struct {
+		command  string
+		modifies string
+		output   any
+	}{
+		{command: "kubectl get pods -n shop", modifies: "no", output: "web-1   0/1   CrashLoopBackOff"},
+		{command: "kubectl get pods -n shop", modifies: "no", output: "web-1   0/1   CrashLoopBackOff"},
+		{command: "kubectl set image deployment/web app=web:v2 -n shop", modifies: "yes", output: "deployment.apps/web image updated"},
+		{command: "kubectl get pods -n shop -w", modifies: "no", output: "web-1   1/1   Running"},
+	}
+
+	usage := &sessions.Usage{ResourcesModified: []sessions.ResourceChange{{Verb: "delete", Resource: "pod/old"}}}
+	a := &Agent{
+		VerifyRemediation: true,
+		Model:             "test-model",
+		session:           &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:            make(chan any, 10),
+		usage:             usage,
+		remediation:       remediation{query: "why is web crashing?"},
+	}
+	for _, call := range calls {
+		firstChange := len(usage.ResourcesModified)
+		if call.modifies != "no" {
+			usage.RecordChange(sessions.ResourceChange{Verb: "set image", Resource: "deployment/web", Namespace: "shop", Timestamp: time.Now()})
+		}
+		a.remediation.record(ToolCallAnalysis{
+			FunctionCall:        gollm.FunctionCall{Name: "kubectl", Arguments: map[string]any{"command": call.command}},
+			ParsedToolCall:      parse(call.command),
+			ModifiesResourceStr: call.modifies,
+		}, call.output, firstChange)
+	}
+	if len(a.remediation.checks) != 1 {
+		t.Fatalf("recorded %d checks, want only the read-only command run before the change", len(a.remediation.checks))
+	}
+
+	chat := mocks.NewMockChat(ctrl)
+	chat.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, contents ...any) (gollm.ChatResponse, error) {
+		prompt := contents[0].(string)
+		for _, want := range []string{"why is web crashing?", "CrashLoopBackOff", "Running"} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("verification prompt doesn't contain %q:\n%s", want, prompt)
+			}
+		}
+		return &fakeResponse{text: "VERIFIED FIXED\nThe pod is running."}, nil
+	})
+	llm := mocks.NewMockClient(ctrl)
+	llm.EXPECT().StartChat(gomock.Any(), "test-model").Return(chat)
+	a.LLM = llm
+
+	a.verifyRemediation(ctx)
+
+	if got := usage.ResourcesModified[0].Verification; got != "" {
+		t.Errorf("change of a previous query has verification %q, want none", got)
+	}
+	if got := usage.ResourcesModified[1].Verification; got != VerdictFixed {
+		t.Errorf("change has verification %q, want %q", got, VerdictFixed)
+	}
+	messages := a.session.ChatMessageStore.ChatMessages()
+	if len(messages) == 0 || messages[len(messages)-1].Payload != "Verification: verified fixed - The pod is running." {
+		t.Errorf("last message = %v, want the verdict", messages[len(messages)-1].Payload)
+	}
+	if a.remediation.mutated || len(a.remediation.checks) != 0 {
+		t.Errorf("remediation wasn't reset after the verification")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+const watchesUsage = "Usage: watches | watches cancel ID"
+
+// WatchNotificationOptions configures the notifications of the watches
+// registered with the watch_until tool, on top of the message in the session.
+type WatchNotificationOptions struct {
+	// Desktop shows a desktop notification, with notify-send on Linux and
+	// osascript on macOS.
+	Desktop bool
+	// WebhookURL receives the events of the watches as JSON, if set.
+	WebhookURL string
+}
+
+// webhookTimeout bounds the delivery of a watch event to the webhook.
+const webhookTimeout = 10 * time.Second
+
+// notifyWatch reports the end of a watch to the session, and to the model
+// with the next query. It is called from the goroutine of the watch.
+func (c *Agent) notifyWatch(event *tools.WatchEvent) {
+	message := event.String()
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "🔔 "+message)
+	c.sessionMu.Lock()
+	c.watchEvents = append(c.watchEvents, message)
+	c.sessionMu.Unlock()
+
+	if c.WatchNotifications.Desktop {
+		if err := desktopNotification("kubectl-ai", message); err != nil {
+			klog.Warningf("error showing desktop notification: %v", err)
+		}
+	}
+	if c.WatchNotifications.WebhookURL != "" {
+		if err := postWatchEvent(c.WatchNotifications.WebhookURL, event); err != nil {
+			klog.Warningf("error posting watch event to webhook: %v", err)
+		}
+	}
+}
+
+// withWatchEvents prepends the events of the watches since the last query to
+// a query, for the model to know about them.
+func (c *Agent) withWatchEvents(query string) string {
+	c.sessionMu.Lock()
+	events := c.watchEvents
+	c.watchEvents = nil
+	c.sessionMu.Unlock()
+	if len(events) == 0 {
+		return query
+	}
+	var sb strings.Builder
+	sb.WriteString("Notifications of the watches since the last message:\n")
+	for _, event := range events {
+		sb.WriteString("- " + event + "\n")
+	}
+	sb.WriteString("\n" + query)
+	return sb.String()
+}
+
+// handleWatchesQuery implements the watches meta commands: "watches" lists
+// the running watches, "watches cancel ID" stops one.
+func (c *Agent) handleWatchesQuery(query string) (answer string, handled bool, err error) {
+	if c.watches == nil {
+		return "Watches are only available in interactive sessions.", true, nil
+	}
+	fields := strings.Fields(query)
+	switch {
+	case len(fields) == 1:
+		watches := c.watches.List()
+		if len(watches) == 0 {
+			return "No watches are running.", true, nil
+		}
+		var sb strings.Builder
+		sb.WriteString("Running watches:\n\n")
+		for _, watch := range watches {
+			resource := watch.Resource
+			if watch.Namespace != "" {
+				resource += " in namespace " + watch.Namespace
+			}
+			fmt.Fprintf(&sb, "  - %s: %s until `%s` (until %s)\n", watch.ID, resource, watch.Condition, watch.Deadline.Format(time.Kitchen))
+		}
+		return sb.String(), true, nil
+	case len(fields) == 3 && fields[1] == "cancel":
+		if !c.watches.Cancel(fields[2]) {
+			return fmt.Sprintf("No watch %s is running.", fields[2]), true, nil
+		}
+		return fmt.Sprintf("Canceled watch %s.", fields[2]), true, nil
+	}
+	return watchesUsage, true, nil
+}
+
+// desktopNotification shows a desktop notification.
+func desktopNotification(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("notify-send", title, message)
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %s with title %s", strconv.Quote(message), strconv.Quote(title)))
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// postWatchEvent posts a watch event to a webhook as JSON.
+func postWatchEvent(url string, event *tools.WatchEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// workDirPrefix is the prefix of the temporary working directories, followed
+// by the PID of the process using them, e.g. agent-workdir-1234-567890.
+const workDirPrefix = "agent-workdir-"
+
+// orphanedWorkDirAge is how long the working directories of the processes
+// that exited are kept, to be looked at, unless removed with --remove-workdir.
+const orphanedWorkDirAge = 24 * time.Hour
+
+// newTempWorkDir creates a temporary working directory for this process.
+func newTempWorkDir() (string, error) {
+	return os.MkdirTemp("", fmt.Sprintf("%s%d-*", workDirPrefix, os.Getpid()))
+}
+
+// PrunedWorkDir is a temporary working directory removed by PruneWorkDirs.
+type PrunedWorkDir struct {
+	Path    string
+	ModTime time.Time
+}
+
+// PruneWorkDirs removes the temporary working directories of the processes
+// that exited, not modified for orphanedWorkDirAge, and returns them. With
+// dryRun, the directories are only returned.
+func PruneWorkDirs(now time.Time, dryRun bool) ([]PrunedWorkDir, error) {
+	entries, err := os.ReadDir(os.TempDir())
+	if err != nil {
+		return nil, err
+	}
+	var pruned []PrunedWorkDir
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), workDirPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < orphanedWorkDirAge {
+			continue
+		}
+		// The directories created before they were named after their process
+		// are only removed by age.
+		pid, _, hasPID := strings.Cut(strings.TrimPrefix(entry.Name(), workDirPrefix), "-")
+		if n, err := strconv.Atoi(pid); hasPID && err == nil && processRunning(n) {
+			continue
+		}
+		path := filepath.Join(os.TempDir(), entry.Name())
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		pruned = append(pruned, PrunedWorkDir{Path: path, ModTime: info.ModTime()})
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// processRunning reports whether the process pid is running. It can't be
+// told on Windows without opening the process, which is assumed to run.
+func processRunning(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPruneWorkDirs(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	old := time.Now().Add(-2 * orphanedWorkDirAge)
+
+	ours, err := newTempWorkDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dirs []string
+	for _, name := range []string{
+		// PIDs don't go that high, the process exited.
+		workDirPrefix + "999999999-1",
+		workDirPrefix + "123456",
+		"other-dir",
+	} {
+		dir := filepath.Join(os.TempDir(), name)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+	recent := filepath.Join(os.TempDir(), fmt.Sprintf("%s999999999-2", workDirPrefix))
+	if err := os.Mkdir(recent, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range append(dirs, ours) {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := PruneWorkDirs(time.Now(), false)
+	if err != nil {
+		t.Fatalf("PruneWorkDirs: %v", err)
+	}
+	var paths []string
+	for _, p := range pruned {
+		paths = append(paths, p.Path)
+	}
+	if want := []string{dirs[1], dirs[0]}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("PruneWorkDirs() = %q, want %q", paths, want)
+	}
+	for _, dir := range []string{ours, recent, dirs[2]} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("%s was removed: %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import "errors"
+
+// ErrorCategory classifies failures of the agent, the tools and the LLM
+// providers, for users to be told how to remediate them and for failures to
+// be analyzed automatically from sessions and journals.
+type ErrorCategory string
+
+const (
+	// ErrorCategoryProviderAuth is a rejected API key or credentials of the LLM provider.
+	ErrorCategoryProviderAuth ErrorCategory = "provider-auth"
+	// ErrorCategoryProviderRateLimit is a rate limit or quota of the LLM provider.
+	ErrorCategoryProviderRateLimit ErrorCategory = "provider-rate-limit"
+	// ErrorCategoryContextExceeded is a request larger than the context window of the model.
+	ErrorCategoryContextExceeded ErrorCategory = "context-exceeded"
+	// ErrorCategoryToolTimeout is a tool call that didn't complete in time.
+	ErrorCategoryToolTimeout ErrorCategory = "tool-timeout"
+	// ErrorCategoryToolNonZeroExit is a command that exited with a non-zero status.
+	ErrorCategoryToolNonZeroExit ErrorCategory = "tool-non-zero-exit"
+	// ErrorCategoryPermissionDenied is an operation denied by the cluster, a policy or a hook.
+	ErrorCategoryPermissionDenied ErrorCategory = "permission-denied"
+	// ErrorCategoryToolsNotSupported is a model rejecting or ignoring the tools.
+	ErrorCategoryToolsNotSupported ErrorCategory = "tools-not-supported"
+)
+
+// Hint returns what the user can do about errors of the category, or "" if
+// the category is unknown.
+func (c ErrorCategory) Hint() string {
+	switch c {
+	case ErrorCategoryProviderAuth:
+		return "Check the API key or credentials of the LLM provider, e.g. GEMINI_API_KEY, and that they have access to the model."
+	case ErrorCategoryProviderRateLimit:
+		return "The LLM provider is rate limiting requests. Wait a moment and retry, or check the quota of the project."
+	case ErrorCategoryContextExceeded:
+		return "The conversation is too long for the model. Use `clear` or `reset` to start over, or ask about fewer resources at once."
+	case ErrorCategoryToolTimeout:
+		return "The command didn't complete in time. Check that the cluster is reachable, or narrow the command down."
+	case ErrorCategoryToolNonZeroExit:
+		return "The command failed, see its output for details."
+	case ErrorCategoryPermissionDenied:
+		return "The operation was denied. Check the RBAC permissions of your kubeconfig, and the policies and hooks configured for kubectl-ai."
+	case ErrorCategoryToolsNotSupported:
+		return "The model doesn't support tools (function calling). Run with --enable-tool-use-shim, or --tool-use-shim-fallback to switch to it when this happens, or pick a model that supports tools."
+	}
+	return ""
+}
+
+// ExitCode returns the exit code of kubectl-ai when it fails with an error
+// of the category, 1 if the category is unknown.
+func (c ErrorCategory) ExitCode() int {
+	switch c {
+	case ErrorCategoryProviderAuth:
+		return 3
+	case ErrorCategoryProviderRateLimit:
+		return 4
+	case ErrorCategoryContextExceeded:
+		return 5
+	case ErrorCategoryToolTimeout:
+		return 6
+	case ErrorCategoryToolNonZeroExit:
+		return 7
+	case ErrorCategoryPermissionDenied:
+		return 8
+	case ErrorCategoryToolsNotSupported:
+		return 9
+	}
+	return 1
+}
+
+// CategorizedError is an error with its category.
+type CategorizedError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *CategorizedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CategorizedError) Unwrap() error {
+	return e.Err
+}
+
+// WithCategory wraps err with the category. Errors that already have a
+// category keep it, and a nil error or an empty category leave err unchanged.
+func WithCategory(category ErrorCategory, err error) error {
+	if err == nil || category == "" || ErrorCategoryOf(err) != "" {
+		return err
+	}
+	return &CategorizedError{Category: category, Err: err}
+}
+
+// ErrorCategoryOf returns the category of err, or "" if it has none.
+func ErrorCategoryOf(err error) ErrorCategory {
+	var categorized *CategorizedError
+	if errors.As(err, &categorized) {
+		return categorized.Category
+	}
+	return ""
+}
@@ -15,6 +15,8 @@
 package api
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -52,6 +54,22 @@ const (
 	MessageTypeUserInputResponse  MessageType = "user-input-response"
 	MessageTypeUserChoiceRequest  MessageType = "user-choice-request"
 	MessageTypeUserChoiceResponse MessageType = "user-choice-response"
+	// MessageTypeTextDelta carries a chunk of model text that is still being streamed.
+	// Deltas are only sent to the UI and are never persisted; the complete text
+	// follows as a MessageTypeText message.
+	MessageTypeTextDelta MessageType = "text-delta"
+	// MessageTypeStreamStats carries a *StreamStats snapshot of the model response
+	// being generated, for UIs to show a live meter. Like deltas, they are only
+	// sent to the UI and are never persisted; the final numbers are set as the
+	// Stats of the message with the complete text.
+	MessageTypeStreamStats MessageType = "stream-stats"
+	// MessageTypeProgress carries a *Progress, the phase the agent entered,
+	// for UIs to show what the agent is waiting on, e.g. the model or a tool.
+	// Like deltas, they are only sent to the UI and are never persisted.
+	MessageTypeProgress MessageType = "progress"
+	// MessageTypeChart carries a *Chart of time series returned by a tool, e.g.
+	// the resource usage of pods, for UIs to draw instead of the raw numbers.
+	MessageTypeChart MessageType = "chart"
 )
 
 type Message struct {
@@ -60,8 +78,171 @@ type Message struct {
 	Type      MessageType
 	Payload   any
 	Timestamp time.Time
+	// Author identifies the operator who sent a user message, e.g. the
+	// authenticated user of the web UI, when several operators share a session.
+	Author string `json:",omitempty"`
+	// Approval records who approved a tool call that required confirmation.
+	// It is only set on tool-call-request messages.
+	Approval *Approval `json:",omitempty"`
+	// Stats measures the model response, it is only set on model text messages.
+	Stats *StreamStats `json:",omitempty"`
+	// Warnings are soft warnings about a final answer, found by the answer
+	// validators. They are shown along with the answer.
+	Warnings []string `json:",omitempty"`
+	// Citations are the web pages the model used to ground the answer, when
+	// web search is enabled.
+	Citations []Citation `json:",omitempty"`
+	// ErrorCategory classifies the failure reported by error messages and
+	// tool-call-response messages, if known.
+	ErrorCategory ErrorCategory `json:",omitempty"`
 }
 
+// Citation is a web page cited by the model.
+type Citation struct {
+	Title string `json:",omitempty"`
+	URI   string
+}
+
+// StreamStats measures a model response: how long it took, how many tokens
+// it used and at which iteration of the agentic loop.
+type StreamStats struct {
+	Elapsed      time.Duration
+	InputTokens  int
+	OutputTokens int
+	// TokensEstimated is true when the provider hasn't reported the usage (yet),
+	// OutputTokens is then estimated from the length of the streamed text.
+	TokensEstimated bool `json:",omitempty"`
+	// Cost is the estimated cost of the tokens in USD, zero if token prices
+	// are not configured.
+	Cost          float64 `json:",omitempty"`
+	Iteration     int
+	MaxIterations int
+}
+
+// String formats the stats as a single line, e.g. "3.2s · 1520 tokens · $0.0042 · iteration 2/20".
+func (s *StreamStats) String() string {
+	parts := []string{fmt.Sprintf("%.1fs", s.Elapsed.Seconds())}
+	tokens := fmt.Sprintf("%d tokens", s.InputTokens+s.OutputTokens)
+	if s.TokensEstimated {
+		tokens = "~" + tokens
+	}
+	parts = append(parts, tokens)
+	if s.Cost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.4f", s.Cost))
+	}
+	if s.MaxIterations > 0 {
+		parts = append(parts, fmt.Sprintf("iteration %d/%d", s.Iteration, s.MaxIterations))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// ProgressPhase is what the agent is waiting on.
+type ProgressPhase string
+
+const (
+	ProgressPhaseThinking           ProgressPhase = "thinking"
+	ProgressPhaseRunningTool        ProgressPhase = "running-tool"
+	ProgressPhaseWaitingForApproval ProgressPhase = "waiting-for-approval"
+	ProgressPhaseVerifying          ProgressPhase = "verifying"
+)
+
+// Progress is the phase the agent entered, and when. It lasts until the
+// next message of the agent.
+type Progress struct {
+	Phase ProgressPhase
+	// Detail describes the phase, e.g. the tool call being run.
+	Detail  string `json:",omitempty"`
+	Started time.Time
+}
+
+// Status formats the progress with the time elapsed since it started,
+// e.g. "running kubectl get pods (2.1s)…".
+func (p *Progress) Status(now time.Time) string {
+	return fmt.Sprintf("%s (%.1fs)…", p.Label(), now.Sub(p.Started).Seconds())
+}
+
+// Label describes the phase, e.g. "running kubectl get pods".
+func (p *Progress) Label() string {
+	switch p.Phase {
+	case ProgressPhaseThinking:
+		return "thinking"
+	case ProgressPhaseRunningTool:
+		return "running " + p.Detail
+	case ProgressPhaseWaitingForApproval:
+		return "waiting for approval"
+	case ProgressPhaseVerifying:
+		return "verifying the fix"
+	default:
+		return string(p.Phase)
+	}
+}
+
+// Chart is a set of time series, e.g. the CPU usage of the pods of a deployment.
+type Chart struct {
+	Title  string
+	Series []ChartSeries
+}
+
+// ChartSeries is a time series of a chart, with its points in time order.
+type ChartSeries struct {
+	// Name identifies the series, e.g. the labels of a metric.
+	Name   string
+	Points []ChartPoint
+}
+
+// ChartPoint is a value of a time series.
+type ChartPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// MetricSample is the resource usage of a pod, a container or a node at a
+// point in time, as reported by kubectl top.
+type MetricSample struct {
+	// Time is when the sample was taken, the same for all the samples of a
+	// kubectl top command.
+	Time time.Time `json:"time"`
+	// Kind is "pod" or "node".
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Container is set for the samples of kubectl top pods --containers.
+	Container     string `json:"container,omitempty"`
+	CPUMillicores int64  `json:"cpuMillicores"`
+	MemoryBytes   int64  `json:"memoryBytes"`
+}
+
+// Approval records who approved a tool call, and how, for auditing.
+type Approval struct {
+	// Approver identifies who approved the call: the OS user for terminal UIs,
+	// or the authenticated user of the web UI.
+	Approver string
+	Method   ApprovalMethod
+	// Timestamp is the time the approver made the decision.
+	Timestamp time.Time
+	// Reason is given by the approver to break a change freeze, or is the
+	// policy rule that allowed the call.
+	Reason string `json:",omitempty"`
+}
+
+type ApprovalMethod string
+
+const (
+	// ApprovalMethodConfirmed means the approver confirmed the call when asked.
+	ApprovalMethodConfirmed ApprovalMethod = "confirmed"
+	// ApprovalMethodDontAskAgain means the call wasn't confirmed individually, because
+	// the approver previously asked not to be asked again in the session.
+	ApprovalMethodDontAskAgain ApprovalMethod = "dont-ask-again"
+	// ApprovalMethodSkipPermissions means confirmations were disabled with --skip-permissions.
+	ApprovalMethodSkipPermissions ApprovalMethod = "skip-permissions"
+	// ApprovalMethodBreakGlass means the approver confirmed the call during a
+	// change freeze, giving a reason.
+	ApprovalMethodBreakGlass ApprovalMethod = "break-glass"
+	// ApprovalMethodPolicy means a policy rule allowed the call without
+	// confirmation.
+	ApprovalMethodPolicy ApprovalMethod = "policy"
+)
+
 type MessageSource string
 
 const (
@@ -73,6 +254,9 @@ const (
 type UserChoiceRequest struct {
 	Prompt  string
 	Options []UserChoiceOption
+	// RequireReason is set when approving needs a reason, e.g. to break a
+	// change freeze. Declining doesn't.
+	RequireReason bool `json:",omitempty"`
 }
 
 type UserChoiceOption struct {
@@ -82,10 +266,62 @@ type UserChoiceOption struct {
 
 type UserChoiceResponse struct {
 	Choice int `json:"choice"`
+	// Approver identifies who made the choice, if known by the UI.
+	// The OS user running kubectl-ai is assumed otherwise.
+	Approver string `json:"approver,omitempty"`
+	// CallIDs are the IDs of the pending approvals the choice applies to,
+	// all the undecided ones if empty.
+	CallIDs []string `json:"callIDs,omitempty"`
+	// Command replaces the command of the single call of CallIDs, which is
+	// approved as edited.
+	Command string `json:"command,omitempty"`
+	// Reason is the reason of the approval, required to break a change freeze.
+	Reason string `json:"reason,omitempty"`
+}
+
+// PendingApproval is a tool call awaiting the decision of the user.
+type PendingApproval struct {
+	// ID identifies the call in UserChoiceResponse.CallIDs.
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	// Preview explains what the call does, e.g. the resources affected
+	// according to a dry-run.
+	Preview string `json:"preview,omitempty"`
+	// Command is the command run by the call, if it can be edited.
+	Command string `json:"command,omitempty"`
+	// Group is set for read-only calls, to the shape they share with the
+	// other calls of the group, e.g. "bash: kubectl get pods", so that they
+	// can be approved together.
+	Group string `json:"group,omitempty"`
+	// Freeze is the name of the change freeze in effect for the call, which
+	// can only be approved with a reason.
+	Freeze string `json:"freeze,omitempty"`
 }
 
 type UserInputResponse struct {
 	Query string `json:"query"`
+	// Author identifies who sent the query, if known by the UI.
+	// The OS user running kubectl-ai is assumed otherwise.
+	Author string `json:"author,omitempty"`
+	// Images are attached to the query, for models that accept images.
+	Images []Image `json:"images,omitempty"`
+	// Attachments are reference material attached to the query.
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Image is an image attached by the user, e.g. a screenshot of a dashboard.
+type Image struct {
+	Name     string `json:"name,omitempty"`
+	MIMEType string `json:"mimeType,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+// Attachment is reference material attached to a query, e.g. a manifest or a
+// log excerpt piped to kubectl-ai, given to the model apart from the question.
+type Attachment struct {
+	// Label names the material for the model, e.g. "deployment.yaml".
+	Label   string `json:"label,omitempty"`
+	Content string `json:"content,omitempty"`
 }
 
 // MCPStatus represents the overall status of MCP servers and tools
@@ -0,0 +1,198 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package health serves the liveness and readiness endpoints of the server
+// modes of kubectl-ai, so that deployments of kubectl-ai in Kubernetes can be
+// probed and load-balanced.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/mcp"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+const (
+	// LivenessPath reports that the process serves requests.
+	LivenessPath = "/healthz"
+	// ReadinessPath reports whether the dependencies of kubectl-ai are usable.
+	ReadinessPath = "/readyz"
+
+	// checkTimeout bounds each check. The timeoutSeconds of the readiness
+	// probe should be above it.
+	checkTimeout = 5 * time.Second
+	// cacheDuration is how long the results of the checks are reused, so
+	// that frequent probes of several replicas don't hit the provider.
+	cacheDuration = 10 * time.Second
+)
+
+// Check is a dependency checked by the readiness endpoint.
+type Check struct {
+	Name string
+	// Optional checks are reported, but don't make kubectl-ai unready, e.g.
+	// an external MCP server: the agent still works without its tools.
+	Optional bool
+	// Run returns an error if the dependency isn't usable.
+	Run func(ctx context.Context) error
+}
+
+// Result is the result of a check.
+type Result struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Optional bool   `json:"optional,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the response of the readiness endpoint.
+type Report struct {
+	Ready     bool      `json:"ready"`
+	Checks    []Result  `json:"checks"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Checker runs the checks of the readiness endpoint.
+type Checker struct {
+	checks []Check
+	// now is replaced by tests.
+	now func() time.Time
+
+	mu   sync.Mutex
+	last *Report
+}
+
+// NewChecker returns a Checker running the checks.
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{checks: checks, now: time.Now}
+}
+
+// Report runs the checks concurrently, or returns the results of the last
+// run if they are recent.
+func (c *Checker) Report(ctx context.Context) *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last != nil && c.now().Sub(c.last.CheckedAt) < cacheDuration {
+		return c.last
+	}
+
+	report := &Report{Ready: true, Checks: make([]Result, len(c.checks)), CheckedAt: c.now()}
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			result := Result{Name: check.Name, OK: true, Optional: check.Optional}
+			if err := check.Run(ctx); err != nil {
+				result.OK, result.Error = false, err.Error()
+			}
+			report.Checks[i] = result
+		}()
+	}
+	wg.Wait()
+	for _, result := range report.Checks {
+		if !result.OK && !result.Optional {
+			report.Ready = false
+		}
+	}
+	c.last = report
+	return report
+}
+
+// Register serves the liveness and readiness endpoints on mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+LivenessPath, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET "+ReadinessPath, func(w http.ResponseWriter, req *http.Request) {
+		report := c.Report(req.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Handler serves the liveness and readiness endpoints, and the other requests
+// with next. The endpoints aren't authenticated, as the kubelet probes them
+// without credentials, so they don't report anything secret.
+func (c *Checker) Handler(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	c.Register(mux)
+	mux.Handle("/", next)
+	return mux
+}
+
+// Provider checks that the LLM provider can be reached with the credentials
+// of the client, by listing its models.
+func Provider(client gollm.Client) Check {
+	return Check{
+		Name: "provider",
+		Run: func(ctx context.Context) error {
+			_, err := client.ListModels(ctx)
+			return err
+		},
+	}
+}
+
+// Kubeconfig checks that the kubeconfig can be used, see tools.CheckKubeconfig.
+func Kubeconfig(kubeconfigPath string) Check {
+	return Check{
+		Name: "kubeconfig",
+		Run: func(ctx context.Context) error {
+			return tools.CheckKubeconfig(kubeconfigPath)
+		},
+	}
+}
+
+// MCPServers checks that the configured MCP servers are connected. The manager is nil if the MCP client isn't enabled yet.
+func MCPServers(manager func() *mcp.Manager) Check {
+	return Check{
+		Name:     "mcp-servers",
+		Optional: true,
+		Run: func(ctx context.Context) error {
+			m := manager()
+			if m == nil {
+				return nil
+			}
+			status, err := m.GetStatus(ctx, true)
+			if err != nil {
+				return err
+			}
+			var failed []string
+			for _, server := range status.ServerInfoList {
+				if !server.IsConnected {
+					failed = append(failed, server.Name)
+				}
+			}
+			if len(failed) > 0 {
+				slices.Sort(failed)
+				return fmt.Errorf("%d of %d MCP servers are not connected: %s", len(failed), status.TotalServers, strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	providerErr := errors.New("401 Unauthorized")
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantStatus int
+		wantReady  bool
+	}{
+		{
+			name: "ready",
+			checks: []Check{
+				{Name: "provider", Run: func(ctx context.Context) error { return nil }},
+				{Name: "kubeconfig", Run: func(ctx context.Context) error { return nil }},
+			},
+			wantStatus: http.StatusOK,
+			wantReady:  true,
+		},
+		{
+			name: "provider unreachable",
+			checks: []Check{
+				{Name: "provider", Run: func(ctx context.Context) error { return providerErr }},
+				{Name: "kubeconfig", Run: func(ctx context.Context) error { return nil }},
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "optional check failing",
+			checks: []Check{
+				{Name: "kubeconfig", Run: func(ctx context.Context) error { return nil }},
+				{Name: "mcp-servers", Optional: true, Run: func(ctx context.Context) error { return errors.New("not connected") }},
+			},
+			wantStatus: http.StatusOK,
+			wantReady:  true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := NewChecker(tc.checks...).Handler(http.NotFoundHandler())
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", ReadinessPath, nil))
+			if w.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tc.wantStatus)
+			}
+			var report Report
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Ready != tc.wantReady || len(report.Checks) != len(tc.checks) {
+				t.Errorf("report = %+v, want ready: %v", report, tc.wantReady)
+			}
+			for i, result := range report.Checks {
+				if result.Name != tc.checks[i].Name {
+					t.Errorf("check #%d = %q, want %q", i, result.Name, tc.checks[i].Name)
+				}
+			}
+		})
+	}
+
+	// Only the liveness and readiness endpoints are served by the checker.
+	handler := NewChecker().Handler(http.NotFoundHandler())
+	for path, want := range map[string]int{LivenessPath: http.StatusOK, "/": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestReportCache(t *testing.T) {
+	runs := 0
+	c := NewChecker(Check{Name: "provider", Run: func(ctx context.Context) error { runs++; return nil }})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Report(context.Background())
+	c.Report(context.Background())
+	if runs != 1 {
+		t.Errorf("checks ran %d times, want the report to be reused", runs)
+	}
+	now = now.Add(cacheDuration)
+	c.Report(context.Background())
+	if runs != 2 {
+		t.Errorf("checks ran %d times, want them to run again once the report is stale", runs)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CastRecorder records what the terminal shows in the asciicast v2 format, so
+// that sessions can be replayed with `asciinema play`. It is also a Recorder:
+// the events of the journal it forwards to are added to the recording as
+// markers, at their timestamp, so that the replay can jump to each query and
+// tool call.
+type CastRecorder struct {
+	next Recorder
+
+	mu    sync.Mutex
+	w     io.WriteCloser
+	start time.Time
+	// last is the time of the last event, in seconds since the start.
+	last float64
+}
+
+var _ Recorder = &CastRecorder{}
+
+// castHeader is the first line of an asciicast v2 file.
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// NewCastRecorder creates a CastRecorder writing to the given file, for a
+// terminal of the given size, and forwarding the journal events to next.
+func NewCastRecorder(path string, width, height int, next Recorder) (*CastRecorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	r, err := newCastRecorder(file, width, height, time.Now(), next)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func newCastRecorder(w io.WriteCloser, width, height int, start time.Time, next Recorder) (*CastRecorder, error) {
+	header := castHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: start.Unix(),
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	}
+	b, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling cast header: %w", err)
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return nil, fmt.Errorf("writing cast header: %w", err)
+	}
+	return &CastRecorder{next: next, w: w, start: start}, nil
+}
+
+// Output returns a writer adding what is written to it to the recording, as
+// output of the terminal.
+func (r *CastRecorder) Output() io.Writer {
+	return castWriter{r}
+}
+
+type castWriter struct {
+	r *CastRecorder
+}
+
+// Write records the output like a terminal translating new lines to carriage
+// returns and line feeds shows it.
+func (w castWriter) Write(p []byte) (int, error) {
+	data := strings.ReplaceAll(strings.ReplaceAll(string(p), "\r\n", "\n"), "\n", "\r\n")
+	if err := w.r.writeEvent(time.Now(), "o", data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Write adds a marker for the user queries and tool calls to the recording,
+// and forwards the event to the journal.
+func (r *CastRecorder) Write(ctx context.Context, event *Event) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if label := castMarker(event); label != "" {
+		if err := r.writeEvent(event.Timestamp, "m", label); err != nil {
+			return err
+		}
+	}
+	if r.next == nil {
+		return nil
+	}
+	return r.next.Write(ctx, event)
+}
+
+// castMarker returns the label of the marker of a journal event, or "" if the
+// event is not worth a marker.
+func castMarker(event *Event) string {
+	switch event.Action {
+	case ActionUserQuery:
+		// The query isn't journaled when the prompt log excludes it.
+		query, ok := event.GetString("query")
+		if !ok {
+			return "query"
+		}
+		return "query: " + query
+	case "tool-request":
+		// The payload is a tools.ToolRequestEvent, or a map when the journal
+		// was loaded.
+		var request struct {
+			Name string `json:"name"`
+		}
+		if b, err := json.Marshal(event.Payload); err == nil {
+			json.Unmarshal(b, &request)
+		}
+		return "tool: " + request.Name
+	case ActionAgentError:
+		return "error"
+	}
+	return ""
+}
+
+// writeEvent writes an event of the recording, at its time since the start.
+// The times of the events never decrease, as players expect.
+func (r *CastRecorder) writeEvent(t time.Time, code, data string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	elapsed := math.Round(t.Sub(r.start).Seconds()*1e6) / 1e6
+	if elapsed < r.last {
+		elapsed = r.last
+	}
+	r.last = elapsed
+	b, err := json.Marshal([]any{elapsed, code, data})
+	if err != nil {
+		return fmt.Errorf("marshalling cast event: %w", err)
+	}
+	_, err = r.w.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the recording. The journal it forwards to is not closed.
+func (r *CastRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.w.Close()
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+type memoryRecorder struct {
+	events []*Event
+}
+
+func (r *memoryRecorder) Write(ctx context.Context, event *Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *memoryRecorder) Close() error { return nil }
+
+func TestCastRecorder(t *testing.T) {
+	var b bytes.Buffer
+	next := &memoryRecorder{}
+	start := time.Now().Add(-time.Second)
+	r, err := newCastRecorder(nopCloser{&b}, 100, 30, start, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	r.Write(ctx, &Event{Timestamp: start.Add(250 * time.Millisecond), Action: ActionUserQuery, Payload: map[string]any{"query": "why is nginx down?"}})
+	r.Write(ctx, &Event{Timestamp: start.Add(500 * time.Millisecond), Action: "tool-request", Payload: struct {
+		Name string `json:"name"`
+	}{Name: "kubectl"}})
+	r.Write(ctx, &Event{Timestamp: start.Add(750 * time.Millisecond), Action: ActionUIRender})
+	io.WriteString(r.Output(), "Running: kubectl get pods\r\n\n")
+	// An event recorded before the output doesn't go back in time.
+	r.Write(ctx, &Event{Timestamp: start, Action: ActionAgentError})
+
+	if len(next.events) != 4 {
+		t.Errorf("%d events were forwarded to the journal, want 4", len(next.events))
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	var header castHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatalf("parsing header: %v", err)
+	}
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 || header.Timestamp != start.Unix() {
+		t.Errorf("header = %+v", header)
+	}
+
+	var events [][]any
+	for _, line := range lines[1:] {
+		var event []any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("parsing event %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	want := [][]any{
+		{0.25, "m", "query: why is nginx down?"},
+		{0.5, "m", "tool: kubectl"},
+		{nil, "o", "Running: kubectl get pods\r\n\r\n"},
+		{nil, "m", "error"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		// The time of the output is the time it was written.
+		if want[i][0] == nil {
+			if events[i][0].(float64) < 1 {
+				t.Errorf("event %d at %v, want after the start of the output", i, events[i][0])
+			}
+			want[i][0] = events[i][0]
+		}
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if events[3][0] != events[2][0] {
+		t.Errorf("the error marker is at %v, want %v", events[3][0], events[2][0])
+	}
+}
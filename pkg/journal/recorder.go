@@ -79,6 +79,18 @@ type Event struct {
 // ActionUIRender is for an event that indicates we wrote output to the UI
 const ActionUIRender = "ui.render"
 
+// ActionUserQuery is for an event that records a query and its author
+const ActionUserQuery = "user.query"
+
+// ActionAgentError is for an event that records an error reported to the user, and its category
+const ActionAgentError = "agent.error"
+
+// ActionBreakGlass is for an event that records the approval of a tool call during a change freeze, and its reason
+const ActionBreakGlass = "approval.break-glass"
+
+// ActionLLMCall is for an event that records the latency and throughput of an LLM call
+const ActionLLMCall = "llm.call"
+
 // GetString is a helper to get a string value from the Payload
 func (e *Event) GetString(key string) (string, bool) {
 	if e.Payload == nil {
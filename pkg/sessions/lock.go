@@ -0,0 +1,260 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+const (
+	lockFileName = "lock.json"
+
+	// lockLease is how long a lock stays valid without being renewed, so that
+	// the sessions of processes that died without unlocking can be resumed.
+	lockLease = 90 * time.Second
+	// lockRenewInterval is how often the holder renews its lock.
+	lockRenewInterval = 30 * time.Second
+)
+
+// ErrLockLost is returned when writing to a session whose lock was taken over
+// by another process.
+var ErrLockLost = errors.New("session lock was taken over by another process")
+
+// LockInfo describes the holder of a session lock.
+type LockInfo struct {
+	// Owner identifies the lock holder, it is unique for every Lock call.
+	Owner     string    `json:"owner"`
+	PID       int       `json:"pid"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Acquired  time.Time `json:"acquired"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// LockedError is returned when locking a session in use by another process.
+type LockedError struct {
+	SessionID string
+	Holder    LockInfo
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder.PID == 0 {
+		return fmt.Sprintf("session %s is locked by another process (use --force-takeover to take it over)", e.SessionID)
+	}
+	return fmt.Sprintf("session %s is in use by process %d on %s since %s (use --force-takeover to take it over)",
+		e.SessionID, e.Holder.PID, e.Holder.Hostname, e.Holder.Acquired.Format("2006-01-02 15:04:05"))
+}
+
+// sessionLock is a lock held on a session by this process.
+type sessionLock struct {
+	info LockInfo
+	stop chan struct{}
+	done chan struct{}
+}
+
+// LockPath returns the path to the lock file of the session.
+func (s *Session) LockPath() string {
+	return filepath.Join(s.Path, lockFileName)
+}
+
+// Lock acquires an advisory lock on the session, so that a single process
+// writes to it at a time. The lock is a lease renewed in the background until
+// Unlock is called. If the session is locked by another process, a *LockedError
+// is returned, unless force is set: the lock is then taken over, and the
+// writes of the previous holder fail with ErrLockLost.
+func (s *Session) Lock(force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lock != nil {
+		return nil
+	}
+
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	info := LockInfo{
+		Owner:     uuid.New().String(),
+		PID:       os.Getpid(),
+		Hostname:  hostname,
+		Acquired:  now,
+		ExpiresAt: now.Add(lockLease),
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	// Creating the lock file with O_EXCL is atomic, only one process can win.
+	// An existing lock is removed at most once, if it expired or is forced.
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(s.LockPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.Write(b)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(s.LockPath())
+				return fmt.Errorf("writing session lock: %w", err)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrExist) || attempt > 0 {
+			return fmt.Errorf("creating session lock: %w", err)
+		}
+
+		holder, err := s.readLock()
+		if err != nil {
+			// The lock file may be read while being created, it is only
+			// considered stale once its lease would have expired.
+			if fi, statErr := os.Stat(s.LockPath()); statErr == nil && !force && now.Sub(fi.ModTime()) < lockLease {
+				return &LockedError{SessionID: s.ID}
+			}
+		} else if !force && now.Before(holder.ExpiresAt) {
+			return &LockedError{SessionID: s.ID, Holder: *holder}
+		} else {
+			klog.Warningf("taking over lock of session %s held by process %d on %s", s.ID, holder.PID, holder.Hostname)
+		}
+		if err := os.Remove(s.LockPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing session lock: %w", err)
+		}
+	}
+
+	s.lock = &sessionLock{info: info, stop: make(chan struct{}), done: make(chan struct{})}
+	go s.renewLock(s.lock)
+	return nil
+}
+
+// Unlock releases the lock acquired with Lock. It does nothing if the
+// session isn't locked by this process.
+func (s *Session) Unlock() error {
+	s.mu.Lock()
+	l := s.lock
+	s.lock = nil
+	s.mu.Unlock()
+
+	if l == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+
+	holder, err := s.readLock()
+	if err != nil || holder.Owner != l.info.Owner {
+		// The lock was taken over, it isn't ours to remove.
+		return nil
+	}
+	if err := os.Remove(s.LockPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing session lock: %w", err)
+	}
+	return nil
+}
+
+// renewLock extends the lease of the lock until it is released or lost.
+func (s *Session) renewLock(l *sessionLock) {
+	defer close(l.done)
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		err := s.checkLockOwner(l.info.Owner)
+		if err == nil {
+			l.info.ExpiresAt = time.Now().Add(lockLease)
+			err = s.renewLockFile(l.info)
+		}
+		s.mu.Unlock()
+		if errors.Is(err, ErrLockLost) {
+			klog.Warningf("session %s: %v", s.ID, err)
+			return
+		}
+		if err != nil {
+			klog.Warningf("renewing lock of session %s: %v", s.ID, err)
+		}
+	}
+}
+
+// renewLockFile replaces the lock file atomically, so that it is never read
+// partially written.
+func (s *Session) renewLockFile(info LockInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.Path, lockFileName+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.LockPath())
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// checkLock returns ErrLockLost if the session was locked by this process,
+// but the lock is now held by another one. It must be called with s.mu held.
+func (s *Session) checkLock() error {
+	if s.lock == nil {
+		return nil
+	}
+	return s.checkLockOwner(s.lock.info.Owner)
+}
+
+// checkLockOwner returns ErrLockLost if the lock isn't held by owner.
+func (s *Session) checkLockOwner(owner string) error {
+	holder, err := s.readLock()
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrLockLost
+	}
+	if err != nil {
+		return err
+	}
+	if holder.Owner != owner {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// readLock reads the lock file of the session.
+func (s *Session) readLock() (*LockInfo, error) {
+	b, err := os.ReadFile(s.LockPath())
+	if err != nil {
+		return nil, err
+	}
+	var info LockInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, fmt.Errorf("parsing session lock: %w", err)
+	}
+	return &info, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestSessionLock(t *testing.T) {
+	dir := t.TempDir()
+	// first and second stand for two processes using the same session.
+	first := &Session{ID: "20250101-0001", Path: dir}
+	second := &Session{ID: "20250101-0001", Path: dir}
+	msg := &api.Message{ID: "1", Type: api.MessageTypeText, Payload: "hello"}
+
+	if err := first.Lock(false); err != nil {
+		t.Fatalf("first.Lock() error = %v", err)
+	}
+	if err := first.AddChatMessage(msg); err != nil {
+		t.Fatalf("first.AddChatMessage() error = %v", err)
+	}
+
+	var locked *LockedError
+	if err := second.Lock(false); !errors.As(err, &locked) {
+		t.Fatalf("second.Lock() error = %v, want a LockedError", err)
+	}
+	if locked.Holder.PID != os.Getpid() {
+		t.Errorf("LockedError holder PID = %d, want %d", locked.Holder.PID, os.Getpid())
+	}
+
+	if err := second.Lock(true); err != nil {
+		t.Fatalf("second.Lock(force) error = %v", err)
+	}
+	if err := first.AddChatMessage(msg); !errors.Is(err, ErrLockLost) {
+		t.Errorf("first.AddChatMessage() after takeover error = %v, want ErrLockLost", err)
+	}
+	if err := second.AddChatMessage(msg); err != nil {
+		t.Errorf("second.AddChatMessage() error = %v", err)
+	}
+
+	// Unlocking a lost lock must not release the lock of the new holder.
+	if err := first.Unlock(); err != nil {
+		t.Fatalf("first.Unlock() error = %v", err)
+	}
+	if _, err := os.Stat(first.LockPath()); err != nil {
+		t.Errorf("lock file of the new holder removed: %v", err)
+	}
+	if err := second.Unlock(); err != nil {
+		t.Fatalf("second.Unlock() error = %v", err)
+	}
+	if _, err := os.Stat(second.LockPath()); !os.IsNotExist(err) {
+		t.Errorf("lock file not removed by Unlock: %v", err)
+	}
+
+	// Once unlocked, the session can be locked again.
+	if err := first.Lock(false); err != nil {
+		t.Fatalf("first.Lock() after unlock error = %v", err)
+	}
+	first.Unlock()
+}
+
+func TestSessionLockExpired(t *testing.T) {
+	s := &Session{ID: "20250101-0002", Path: t.TempDir()}
+
+	// A lock left behind by a process that died without unlocking.
+	stale := LockInfo{Owner: "dead", PID: 1, Hostname: "elsewhere", Acquired: time.Now().Add(-time.Hour), ExpiresAt: time.Now().Add(-time.Minute)}
+	b, err := json.Marshal(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.LockPath(), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Lock(false); err != nil {
+		t.Fatalf("Lock() of an expired lock error = %v", err)
+	}
+	defer s.Unlock()
+	holder, err := s.readLock()
+	if err != nil {
+		t.Fatalf("readLock() error = %v", err)
+	}
+	if holder.Owner == stale.Owner {
+		t.Errorf("expired lock not taken over")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy limits the saved sessions. Zero values don't limit them.
+type RetentionPolicy struct {
+	// MaxSessions is the number of sessions kept, the most recently accessed.
+	MaxSessions int
+	// MaxAge deletes the sessions not accessed for longer.
+	MaxAge time.Duration
+	// MaxTotalSize is the size in bytes of all the sessions, the least
+	// recently accessed are deleted until they fit.
+	MaxTotalSize int64
+}
+
+// PrunedSession is a session deleted by Prune.
+type PrunedSession struct {
+	ID           string
+	LastAccessed time.Time
+	Size         int64
+	// Reason is the limit of the retention policy the session exceeded.
+	Reason string
+}
+
+// Prune deletes the sessions exceeding the retention policy, except keep and
+// the sessions in use by a process, and returns them. With dryRun, the
+// sessions are only returned.
+func (sm *SessionManager) Prune(policy RetentionPolicy, keep string, now time.Time, dryRun bool) ([]PrunedSession, error) {
+	sessions, err := sm.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		session      *Session
+		lastAccessed time.Time
+		size         int64
+	}
+	var candidates []candidate
+	var totalSize int64
+	for _, s := range sessions {
+		c := candidate{session: s, size: dirSize(s.Path)}
+		totalSize += c.size
+		if s.ID == keep || s.inUse(now) {
+			continue
+		}
+		if m, err := s.LoadMetadata(); err == nil {
+			c.lastAccessed = m.LastAccessed
+		} else if fi, err := os.Stat(s.Path); err == nil {
+			// Sessions without metadata were never used.
+			c.lastAccessed = fi.ModTime()
+		}
+		candidates = append(candidates, c)
+	}
+	// The kept sessions and those in use count towards the limits first.
+	kept := len(sessions) - len(candidates)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastAccessed.After(candidates[j].lastAccessed)
+	})
+
+	var reasons []string
+	for i, c := range candidates {
+		var reason string
+		switch {
+		case policy.MaxAge > 0 && now.Sub(c.lastAccessed) > policy.MaxAge:
+			reason = "max age"
+		case policy.MaxSessions > 0 && kept+i >= policy.MaxSessions:
+			reason = "max sessions"
+		}
+		reasons = append(reasons, reason)
+		if reason != "" {
+			totalSize -= c.size
+		}
+	}
+	// The least recently accessed sessions are deleted first to fit the size.
+	for i := len(candidates) - 1; i >= 0 && policy.MaxTotalSize > 0 && totalSize > policy.MaxTotalSize; i-- {
+		if reasons[i] == "" {
+			reasons[i] = "max total size"
+			totalSize -= candidates[i].size
+		}
+	}
+
+	var pruned []PrunedSession
+	for i := len(candidates) - 1; i >= 0; i-- {
+		c := candidates[i]
+		if reasons[i] == "" {
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(c.session.Path); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, PrunedSession{ID: c.session.ID, LastAccessed: c.lastAccessed, Size: c.size, Reason: reasons[i]})
+	}
+	return pruned, nil
+}
+
+// inUse reports whether a process holds the lock of the session.
+func (s *Session) inUse(now time.Time) bool {
+	holder, err := s.readLock()
+	if err != nil {
+		// The lock file may be read while being created.
+		fi, statErr := os.Stat(s.LockPath())
+		return statErr == nil && now.Sub(fi.ModTime()) < lockLease
+	}
+	return now.Before(holder.ExpiresAt)
+}
+
+// dirSize returns the size of the files of a directory.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	newManager := func(t *testing.T) *SessionManager {
+		sm := &SessionManager{BasePath: t.TempDir()}
+		// Sessions of 1 KiB of history, accessed 1 to 5 days ago.
+		for i := 1; i <= 5; i++ {
+			s := &Session{ID: "2025052" + string(rune('0'+i)) + "-0001"}
+			s.Path = filepath.Join(sm.BasePath, s.ID)
+			if err := os.MkdirAll(s.Path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SaveMetadata(&Metadata{LastAccessed: now.Add(-time.Duration(i) * day)}); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(s.HistoryPath(), []byte(strings.Repeat("x", 1024)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return sm
+	}
+	prunedIDs := func(pruned []PrunedSession) []string {
+		var ids []string
+		for _, p := range pruned {
+			ids = append(ids, p.ID+" "+p.Reason)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		keep   string
+		want   []string
+	}{
+		{name: "no limits"},
+		{
+			name:   "max age",
+			policy: RetentionPolicy{MaxAge: 4*day + time.Hour},
+			want:   []string{"20250525-0001 max age"},
+		},
+		{
+			name:   "max sessions",
+			policy: RetentionPolicy{MaxSessions: 3},
+			want:   []string{"20250525-0001 max sessions", "20250524-0001 max sessions"},
+		},
+		{
+			name:   "kept session",
+			policy: RetentionPolicy{MaxSessions: 3},
+			keep:   "20250525-0001",
+			want:   []string{"20250524-0001 max sessions", "20250523-0001 max sessions"},
+		},
+		{
+			name:   "max total size",
+			policy: RetentionPolicy{MaxTotalSize: 4000},
+			want:   []string{"20250525-0001 max total size", "20250524-0001 max total size"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sm := newManager(t)
+			pruned, err := sm.Prune(tc.policy, tc.keep, now, true)
+			if err != nil {
+				t.Fatalf("Prune(dryRun): %v", err)
+			}
+			if got := prunedIDs(pruned); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Prune(dryRun) = %q, want %q", got, tc.want)
+			}
+			if sessions, _ := sm.ListSessions(); len(sessions) != 5 {
+				t.Errorf("Prune(dryRun) deleted sessions, %d left", len(sessions))
+			}
+
+			if _, err := sm.Prune(tc.policy, tc.keep, now, false); err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			if sessions, _ := sm.ListSessions(); len(sessions) != 5-len(tc.want) {
+				t.Errorf("%d sessions left, want %d", len(sessions), 5-len(tc.want))
+			}
+		})
+	}
+}
+
+func TestPruneSkipsSessionsInUse(t *testing.T) {
+	sm := &SessionManager{BasePath: t.TempDir()}
+	s, err := sm.NewSession(Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(false); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Unlock()
+
+	pruned, err := sm.Prune(RetentionPolicy{MaxAge: time.Millisecond}, "", time.Now().Add(time.Second), false)
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("Prune() = %+v, %v, want the session in use to be kept", pruned, err)
+	}
+}
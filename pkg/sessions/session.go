@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -29,6 +30,7 @@ import (
 const (
 	metadataFileName = "metadata.yaml"
 	historyFileName  = "history.json"
+	metricsFileName  = "metrics.json"
 )
 
 // Metadata contains metadata about a session
@@ -37,6 +39,29 @@ type Metadata struct {
 	ModelID      string    `json:"modelID"`
 	CreatedAt    time.Time `json:"createdAt"`
 	LastAccessed time.Time `json:"lastAccessed"`
+	// Usage is the snapshot of cluster activity recorded when the session was closed.
+	Usage *Usage `json:"usage,omitempty"`
+	// TokenUsage is the LLM token usage and cost of the session, by model.
+	TokenUsage []TokenUsage `json:"tokenUsage,omitempty"`
+	// Env holds the environment variables set for tools during the session.
+	Env map[string]string `json:"env,omitempty"`
+	// Notes are pinned to the session by the user, e.g. the change ticket.
+	Notes []string `json:"notes,omitempty"`
+	// Owner is the user who started the session, when the UI authenticates
+	// its users, e.g. the web UI with OIDC.
+	Owner string `json:"owner,omitempty"`
+	// Tags organize the sessions, e.g. by incident, team or cluster.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// HasTags reports whether the session is tagged with all the tags.
+func (m *Metadata) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(m.Tags, tag) {
+			return false
+		}
+	}
+	return true
 }
 
 // Session represents a single chat session.
@@ -44,6 +69,8 @@ type Session struct {
 	ID   string
 	Path string
 	mu   sync.Mutex
+	// lock is the lock held on the session by this process, if any.
+	lock *sessionLock
 }
 
 // HistoryPath returns the path to the history file for the session.
@@ -56,6 +83,11 @@ func (s *Session) MetadataPath() string {
 	return filepath.Join(s.Path, metadataFileName)
 }
 
+// MetricsPath returns the path to the file of the metric samples of the session.
+func (s *Session) MetricsPath() string {
+	return filepath.Join(s.Path, metricsFileName)
+}
+
 // LoadMetadata loads the metadata for the session.
 func (s *Session) LoadMetadata() (*Metadata, error) {
 	b, err := os.ReadFile(s.MetadataPath())
@@ -88,11 +120,131 @@ func (s *Session) UpdateLastAccessed() error {
 	return s.SaveMetadata(m)
 }
 
+// RecordUsage merges the given usage snapshot into the session metadata.
+func (s *Session) RecordUsage(u *Usage) error {
+	m, err := s.LoadMetadata()
+	if err != nil {
+		return err
+	}
+	if m.Usage == nil {
+		m.Usage = &Usage{}
+	}
+	m.Usage.Merge(u)
+	m.LastAccessed = time.Now()
+	return s.SaveMetadata(m)
+}
+
+// SetTokenUsage replaces the LLM token usage recorded for the session.
+func (s *Session) SetTokenUsage(usage []TokenUsage) error {
+	m, err := s.LoadMetadata()
+	if err != nil {
+		return err
+	}
+	m.TokenUsage = usage
+	m.LastAccessed = time.Now()
+	return s.SaveMetadata(m)
+}
+
+// SetEnv replaces the environment variables recorded for the session.
+func (s *Session) SetEnv(env map[string]string) error {
+	m, err := s.LoadMetadata()
+	if err != nil {
+		return err
+	}
+	m.Env = env
+	return s.SaveMetadata(m)
+}
+
+// SetNotes replaces the notes pinned to the session.
+func (s *Session) SetNotes(notes []string) error {
+	m, err := s.LoadMetadata()
+	if err != nil {
+		return err
+	}
+	m.Notes = notes
+	return s.SaveMetadata(m)
+}
+
+// SetOwner records the user who owns the session.
+func (s *Session) SetOwner(owner string) error {
+	m, err := s.LoadMetadata()
+	if err != nil {
+		return err
+	}
+	m.Owner = owner
+	return s.SaveMetadata(m)
+}
+
+// SetTags replaces the tags of the session.
+func (s *Session) SetTags(tags []string) error {
+	m, err := s.LoadMetadata()
+	if err != nil {
+		return err
+	}
+	m.Tags = tags
+	return s.SaveMetadata(m)
+}
+
+// AddMetricSamples appends metric samples, e.g. of kubectl top, to the
+// session's metrics file.
+func (s *Session) AddMetricSamples(samples []api.MetricSample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkLock(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.MetricsPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var b []byte
+	for _, sample := range samples {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		b = append(append(b, line...), '\n')
+	}
+	_, err = f.Write(b)
+	return err
+}
+
+// MetricSamples returns the metric samples recorded in the session, oldest first.
+func (s *Session) MetricSamples() []api.MetricSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.MetricsPath())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var samples []api.MetricSample
+	decoder := json.NewDecoder(f)
+	for decoder.More() {
+		var sample api.MetricSample
+		if err := decoder.Decode(&sample); err != nil {
+			break // a sample cut off by a crash ends the file
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
 // AddChatMessage appends a new message to the history and persists it to the sessions's history file.
 func (s *Session) AddChatMessage(msg *api.Message) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.checkLock(); err != nil {
+		return err
+	}
+
 	f, err := os.OpenFile(s.HistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
@@ -115,6 +267,10 @@ func (s *Session) SetChatMessages(newMessages []*api.Message) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.checkLock(); err != nil {
+		return err
+	}
+
 	f, err := os.OpenFile(s.HistoryPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
@@ -163,6 +319,10 @@ func (s *Session) ClearChatMessages() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.checkLock(); err != nil {
+		return err
+	}
+
 	// Truncate the file by opening it with O_TRUNC
 	f, err := os.OpenFile(s.HistoryPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
@@ -176,10 +336,16 @@ func (s *Session) String() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Current session:\n\nID: %s\nCreated: %s\nLast Accessed: %s\nModel: %s\nProvider: %s\n\n",
+	var cost string
+	if len(metadata.TokenUsage) > 0 {
+		cost = fmt.Sprintf("Estimated LLM cost: $%.2f\n", TotalTokenCost(metadata.TokenUsage))
+	}
+	return fmt.Sprintf("Current session:\n\nID: %s\nCreated: %s\nLast Accessed: %s\nModel: %s\nProvider: %s\n%s%s\n",
 		s.ID,
 		metadata.CreatedAt.Format("2006-01-02 15:04:05"),
 		metadata.LastAccessed.Format("2006-01-02 15:04:05"),
 		metadata.ModelID,
-		metadata.ProviderID), nil
+		metadata.ProviderID,
+		cost,
+		metadata.Usage.String()), nil
 }
@@ -0,0 +1,102 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// benchmarkMessage is a typical tool call result, the most frequent and
+// largest messages of a session.
+var benchmarkMessage = &api.Message{
+	ID:        "1",
+	Source:    api.MessageSourceAgent,
+	Type:      api.MessageTypeToolCallResponse,
+	Payload:   map[string]any{"stdout": strings.Repeat("nginx-7c5ddbdf54-x2vgp   1/1     Running   0          3d\n", 50)},
+	Timestamp: time.Now(),
+}
+
+func BenchmarkAddChatMessage(b *testing.B) {
+	stores := []struct {
+		name  string
+		store api.ChatMessageStore
+	}{
+		{"in-memory", NewInMemoryChatStore()},
+		{"file", &Session{ID: "20250101-0001", Path: b.TempDir()}},
+	}
+	for _, s := range stores {
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := s.store.AddChatMessage(benchmarkMessage); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricSamples(t *testing.T) {
+	s := &Session{ID: "20250101-0001", Path: t.TempDir()}
+	if got := s.MetricSamples(); got != nil {
+		t.Errorf("MetricSamples() = %+v, want none", got)
+	}
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	samples := []api.MetricSample{
+		{Time: at, Kind: "pod", Namespace: "shop", Name: "web-0", CPUMillicores: 250, MemoryBytes: 512 << 20},
+		{Time: at, Kind: "node", Name: "node-1", CPUMillicores: 1200, MemoryBytes: 4 << 30},
+	}
+	for _, sample := range samples {
+		if err := s.AddMetricSamples([]api.MetricSample{sample}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := s.MetricSamples()
+	if len(got) != 2 || got[0] != samples[0] || got[1] != samples[1] {
+		t.Errorf("MetricSamples() = %+v, want %+v", got, samples)
+	}
+}
+
+func TestSetTokenUsage(t *testing.T) {
+	s := &Session{ID: "20250101-0001", Path: t.TempDir()}
+	if err := s.SaveMetadata(&Metadata{ProviderID: "gemini", ModelID: "gemini-2.5-pro"}); err != nil {
+		t.Fatal(err)
+	}
+	usage := []TokenUsage{
+		{Provider: "gemini", Model: "gemini-2.5-pro", Calls: 3, InputTokens: 40000, OutputTokens: 1200, Cost: 0.062},
+		{Provider: "gemini", Model: "gemini-2.5-flash-lite", Calls: 1, InputTokens: 900, OutputTokens: 50, Estimated: true, Cost: 0.0001},
+	}
+	if err := s.SetTokenUsage(usage); err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.LoadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.TokenUsage) != 2 || m.TokenUsage[0] != usage[0] || m.TokenUsage[1] != usage[1] {
+		t.Errorf("TokenUsage = %+v, want %+v", m.TokenUsage, usage)
+	}
+	out, err := s.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Estimated LLM cost: $0.06\n") {
+		t.Errorf("String() = %q, want the estimated cost", out)
+	}
+}
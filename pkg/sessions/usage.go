@@ -0,0 +1,186 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Usage is a snapshot of the cluster activity performed during a session.
+// It is recorded into the session metadata when the session is closed, so
+// that sessions can later be searched by what they touched.
+type Usage struct {
+	// Context is the kubeconfig context the session was running against.
+	Context string `json:"context,omitempty"`
+	// Namespaces lists the namespaces referenced by executed commands.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Commands counts executed commands, keyed by tool and verb (e.g. "kubectl get").
+	Commands map[string]int `json:"commands,omitempty"`
+	// ResourcesModified lists the resources changed by executed commands.
+	ResourcesModified []ResourceChange `json:"resourcesModified,omitempty"`
+}
+
+// ResourceChange records a single mutation of a cluster resource.
+type ResourceChange struct {
+	Verb      string    `json:"verb"`
+	Resource  string    `json:"resource,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Verification is the outcome of the verification of the fix the change
+	// belongs to, e.g. "verified fixed" or "symptom persists".
+	Verification string `json:"verification,omitempty"`
+}
+
+func (c ResourceChange) String() string {
+	resource := c.Resource
+	if resource == "" {
+		resource = "<unknown>"
+	}
+	if c.Namespace != "" {
+		resource = c.Namespace + "/" + resource
+	}
+	s := fmt.Sprintf("%s %s (%s)", c.Verb, resource, c.Timestamp.Format("2006-01-02 15:04"))
+	if c.Verification != "" {
+		s += " [" + c.Verification + "]"
+	}
+	return s
+}
+
+// IsEmpty returns true if no activity has been recorded.
+func (u *Usage) IsEmpty() bool {
+	return u == nil || (len(u.Namespaces) == 0 && len(u.Commands) == 0 && len(u.ResourcesModified) == 0)
+}
+
+// RecordCommand increments the counter for the given command key.
+func (u *Usage) RecordCommand(key string) {
+	if u.Commands == nil {
+		u.Commands = make(map[string]int)
+	}
+	u.Commands[key]++
+}
+
+// RecordNamespace adds the namespace to the set of touched namespaces.
+func (u *Usage) RecordNamespace(namespace string) {
+	if namespace == "" {
+		return
+	}
+	for _, ns := range u.Namespaces {
+		if ns == namespace {
+			return
+		}
+	}
+	u.Namespaces = append(u.Namespaces, namespace)
+	sort.Strings(u.Namespaces)
+}
+
+// RecordChange appends a resource mutation.
+func (u *Usage) RecordChange(change ResourceChange) {
+	u.RecordNamespace(change.Namespace)
+	u.ResourcesModified = append(u.ResourcesModified, change)
+}
+
+// Merge folds the activity recorded in other into u.
+// The context of other takes precedence if set.
+func (u *Usage) Merge(other *Usage) {
+	if other == nil {
+		return
+	}
+	if other.Context != "" {
+		u.Context = other.Context
+	}
+	for _, ns := range other.Namespaces {
+		u.RecordNamespace(ns)
+	}
+	for key, n := range other.Commands {
+		if u.Commands == nil {
+			u.Commands = make(map[string]int)
+		}
+		u.Commands[key] += n
+	}
+	u.ResourcesModified = append(u.ResourcesModified, other.ResourcesModified...)
+}
+
+// TotalCommands returns the number of commands executed.
+func (u *Usage) TotalCommands() int {
+	if u == nil {
+		return 0
+	}
+	total := 0
+	for _, n := range u.Commands {
+		total += n
+	}
+	return total
+}
+
+// String returns a human-readable summary of the usage.
+func (u *Usage) String() string {
+	if u.IsEmpty() && (u == nil || u.Context == "") {
+		return ""
+	}
+	var sb strings.Builder
+	if u.Context != "" {
+		fmt.Fprintf(&sb, "Context: %s\n", u.Context)
+	}
+	if len(u.Namespaces) > 0 {
+		fmt.Fprintf(&sb, "Namespaces: %s\n", strings.Join(u.Namespaces, ", "))
+	}
+	if len(u.Commands) > 0 {
+		keys := make([]string, 0, len(u.Commands))
+		for key := range u.Commands {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&sb, "Commands executed: %d\n", u.TotalCommands())
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "  - %s: %d\n", key, u.Commands[key])
+		}
+	}
+	if len(u.ResourcesModified) > 0 {
+		fmt.Fprintf(&sb, "Resources modified:\n")
+		for _, change := range u.ResourcesModified {
+			fmt.Fprintf(&sb, "  - %s\n", change)
+		}
+	}
+	return sb.String()
+}
+
+// TokenUsage is the LLM token usage of a model during a session, and its
+// estimated cost. It is recorded into the session metadata when the session is
+// closed.
+type TokenUsage struct {
+	Provider     string `json:"provider,omitempty"`
+	Model        string `json:"model"`
+	Calls        int    `json:"calls"`
+	InputTokens  int    `json:"inputTokens"`
+	OutputTokens int    `json:"outputTokens"`
+	// Estimated is true when the provider didn't report the usage of some calls,
+	// their output tokens are then estimated from the length of the text.
+	Estimated bool `json:"estimated,omitempty"`
+	// Cost is the estimated cost in USD, with the prices of the tokens at the
+	// time of the calls.
+	Cost float64 `json:"cost,omitempty"`
+}
+
+// TotalTokenCost returns the estimated cost in USD of the token usage.
+func TotalTokenCost(usage []TokenUsage) float64 {
+	var cost float64
+	for _, u := range usage {
+		cost += u.Cost
+	}
+	return cost
+}
@@ -0,0 +1,382 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	RegisterTool(&ApplyManifest{})
+}
+
+// FieldManager is the field manager of the changes applied by kubectl-ai with
+// server-side apply, which the API server records as the owner of the fields
+// of the manifests.
+const FieldManager = "kubectl-ai"
+
+// ApplyManifest applies Kubernetes manifests with server-side apply. When
+// fields of the manifests are owned by other field managers, e.g. a
+// controller or another tool, the conflicts are reported with the ways to
+// resolve them instead of failing with the error of kubectl.
+type ApplyManifest struct{}
+
+func (t *ApplyManifest) Name() string {
+	return "apply_manifest"
+}
+
+func (t *ApplyManifest) Description() string {
+	return fmt.Sprintf(`Applies Kubernetes manifests (YAML or JSON, multiple documents allowed) with server-side apply, as the field manager %q.
+Prefer this tool over "kubectl apply" to create or update resources from a manifest.
+When fields of the manifest are owned by other field managers (e.g. a controller, an autoscaler or another deployment tool), the objects with conflicts are not applied, and the conflicts are returned, with the fields and their managers.
+Then present the conflicts to the user and ask how to proceed, among:
+- force: apply again with force_conflicts, taking the ownership of the fields and overwriting their values;
+- narrow: apply again with skip_fields listing the conflicting fields, leaving them to their current managers;
+- abandon: don't apply the manifest.`, FieldManager)
+}
+
+func (t *ApplyManifest) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"manifest": {
+					Type:        gollm.TypeString,
+					Description: `The manifest to apply, as YAML or JSON. Multiple YAML documents can be separated with "---".`,
+				},
+				"filename": {
+					Type:        gollm.TypeString,
+					Description: `Path of a manifest file to apply, relative to the working directory. Ignored if manifest is set.`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace of the resources that don't set one. Defaults to the current namespace.`,
+				},
+				"force_conflicts": {
+					Type:        gollm.TypeBoolean,
+					Description: `Take the ownership of the fields managed by other field managers, overwriting their values. Only set it when the user chose to force the conflicts.`,
+				},
+				"skip_fields": {
+					Type:        gollm.TypeArray,
+					Items:       &gollm.Schema{Type: gollm.TypeString},
+					Description: `Fields removed from every document of the manifest before applying it, as reported in the conflicts, e.g. ".spec.replicas" or ".spec.template.spec.containers[name=\"web\"].image".`,
+				},
+			},
+		},
+	}
+}
+
+func (t *ApplyManifest) Run(ctx context.Context, args map[string]any) (any, error) {
+	return applyManifest(ctx, args, false)
+}
+
+// DryRunApplyManifest runs the server-side apply of an apply_manifest call
+// with --dry-run=server, to preview the objects it changes and its conflicts.
+func DryRunApplyManifest(ctx context.Context, args map[string]any, opt InvokeToolOptions) (*ApplyManifestResult, error) {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+	return applyManifest(ctx, args, true)
+}
+
+func applyManifest(ctx context.Context, args map[string]any, dryRun bool) (*ApplyManifestResult, error) {
+	result := &ApplyManifestResult{FieldManager: FieldManager, DryRun: dryRun}
+
+	manifest, err := readManifestArg(ctx, args)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	if skipFields, _ := args["skip_fields"].([]any); len(skipFields) > 0 {
+		var paths []string
+		for _, f := range skipFields {
+			if s, ok := f.(string); ok && s != "" {
+				paths = append(paths, s)
+			}
+		}
+		if manifest, result.SkippedFields, err = removeManifestFields(manifest, paths); err != nil {
+			result.Error = err.Error()
+			return result, nil
+		}
+	}
+
+	kubectlArgs := []string{"apply", "--server-side", "--field-manager=" + FieldManager, "-f", "-"}
+	if namespace, _ := args["namespace"].(string); namespace != "" {
+		kubectlArgs = append(kubectlArgs, "-n", namespace)
+	}
+	if force, _ := args["force_conflicts"].(bool); force {
+		kubectlArgs = append(kubectlArgs, "--force-conflicts")
+	}
+	if dryRun {
+		kubectlArgs = append(kubectlArgs, "--dry-run=server")
+	}
+
+	kubeconfig, _ := ctx.Value(KubeconfigKey).(string)
+	workDir, _ := ctx.Value(WorkDirKey).(string)
+	cmd := exec.CommandContext(ctx, "kubectl", kubectlArgs...)
+	cmd.Env = commandEnv(ctx)
+	cmd.Dir = workDir
+	if kubeconfig != "" {
+		if kubeconfig, err = expandShellVar(kubeconfig); err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, "KUBECONFIG="+kubeconfig)
+	}
+	cmd.Stdin = bytes.NewReader(manifest)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+
+	// kubectl applies the objects it can, and reports the others.
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result.Applied = append(result.Applied, line)
+		}
+	}
+	if runErr == nil {
+		return result, nil
+	}
+	if _, ok := runErr.(*exec.ExitError); !ok {
+		return nil, fmt.Errorf("running kubectl apply: %w", runErr)
+	}
+	result.Conflicts = parseApplyConflicts(stderr.String())
+	if len(result.Conflicts) == 0 {
+		result.Error = strings.TrimSpace(stderr.String())
+		return result, nil
+	}
+	result.Error = "some fields of the manifest are owned by other field managers"
+	result.Options = []string{
+		"force: apply again with force_conflicts set, taking the ownership of the conflicting fields and overwriting their values",
+		"narrow: apply again with skip_fields set to the conflicting fields, leaving them to their current managers",
+		"abandon: don't apply the manifest",
+	}
+	return result, nil
+}
+
+// readManifestArg returns the manifest given with the manifest or the
+// filename argument of a tool call.
+func readManifestArg(ctx context.Context, args map[string]any) ([]byte, error) {
+	if manifest, _ := args["manifest"].(string); manifest != "" {
+		return []byte(manifest), nil
+	}
+	filename, _ := args["filename"].(string)
+	if filename == "" {
+		return nil, fmt.Errorf("one of manifest or filename must be provided")
+	}
+	if !filepath.IsAbs(filename) {
+		workDir, _ := ctx.Value(WorkDirKey).(string)
+		filename = filepath.Join(workDir, filename)
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	return b, nil
+}
+
+func (t *ApplyManifest) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+// CheckModifiesResource reports that the tool modifies resources, so that its
+// calls are confirmed by the user.
+func (t *ApplyManifest) CheckModifiesResource(args map[string]any) string {
+	return "yes"
+}
+
+// ApplyManifestResult is the result of applying manifests with server-side apply.
+type ApplyManifestResult struct {
+	FieldManager string `json:"fieldManager"`
+	DryRun       bool   `json:"dryRun,omitempty"`
+	// Applied lists the objects applied, as printed by kubectl,
+	// e.g. "deployment.apps/web serverside-applied".
+	Applied []string `json:"applied,omitempty"`
+	// SkippedFields lists the fields removed from the manifest before applying it.
+	SkippedFields []string        `json:"skippedFields,omitempty"`
+	Conflicts     []FieldConflict `json:"conflicts,omitempty"`
+	// Options are the ways to resolve the conflicts.
+	Options []string `json:"options,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// FieldConflict lists the fields of the manifest owned by another field manager.
+type FieldConflict struct {
+	Manager string `json:"manager"`
+	// APIVersion is the version of the API the manager used to set the fields.
+	APIVersion string   `json:"apiVersion,omitempty"`
+	Fields     []string `json:"fields"`
+}
+
+func (c FieldConflict) String() string {
+	manager := fmt.Sprintf("%q", c.Manager)
+	if c.APIVersion != "" {
+		manager += " (" + c.APIVersion + ")"
+	}
+	return fmt.Sprintf("%s owns %s", manager, strings.Join(c.Fields, ", "))
+}
+
+// applyConflictPattern matches the managers in the conflicts reported by the
+// API server, e.g.
+//
+//	Apply failed with 1 conflict: conflict with "kubectl-client-side-apply" using apps/v1: .spec.replicas
+//	Apply failed with 2 conflicts: conflicts with "hpa-controller" using apps/v1:
+//	- .spec.replicas
+var applyConflictPattern = regexp.MustCompile(`conflicts? with "([^"]+)"(?: using ([^\s:]+))?:?(?:\s+(\S.*))?$`)
+
+// parseApplyConflicts parses the conflicts of a server-side apply from the
+// errors printed by kubectl.
+func parseApplyConflicts(stderr string) []FieldConflict {
+	var conflicts []FieldConflict
+	var current *FieldConflict
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if m := applyConflictPattern.FindStringSubmatch(line); m != nil {
+			current = nil
+			for i := range conflicts {
+				if conflicts[i].Manager == m[1] && conflicts[i].APIVersion == m[2] {
+					current = &conflicts[i]
+				}
+			}
+			if current == nil {
+				conflicts = append(conflicts, FieldConflict{Manager: m[1], APIVersion: m[2]})
+				current = &conflicts[len(conflicts)-1]
+			}
+			if m[3] != "" {
+				current.Fields = append(current.Fields, m[3])
+			}
+			continue
+		}
+		if current != nil && strings.HasPrefix(line, "- ") {
+			current.Fields = append(current.Fields, strings.TrimPrefix(line, "- "))
+			continue
+		}
+		current = nil
+	}
+	return conflicts
+}
+
+// fieldPathSegment matches a segment of a field path, as reported in the
+// conflicts: a field (".replicas") or an element of a list, selected by a key
+// ("[name=\"web\"]") or by its value ("[=\"--verbose\"]").
+var fieldPathSegment = regexp.MustCompile(`^(?:\.([^.\[]+)|\[([^=\]]*)=([^\]]*)\])`)
+
+// removeManifestFields removes the fields of paths from every document of the
+// manifest, and returns the fields that were found.
+func removeManifestFields(manifest []byte, paths []string) ([]byte, []string, error) {
+	for i, path := range paths {
+		if !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "[") {
+			paths[i] = "." + path
+		}
+	}
+	var docs [][]byte
+	removed := map[string]bool{}
+	for _, doc := range yamlDocumentSeparator.Split(string(manifest), -1) {
+		if strings.TrimSpace(stripYAMLComments(doc)) == "" {
+			continue
+		}
+		var obj any
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		for _, path := range paths {
+			ok, err := removeField(obj, path)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				removed[path] = true
+			}
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		docs = append(docs, b)
+	}
+
+	var found []string
+	for _, path := range paths {
+		if removed[path] {
+			found = append(found, path)
+		}
+	}
+	return bytes.Join(docs, []byte("\n---\n")), found, nil
+}
+
+// removeField removes the field at path from obj, and reports whether it was found.
+func removeField(obj any, path string) (bool, error) {
+	m := fieldPathSegment.FindStringSubmatch(path)
+	if m == nil {
+		return false, fmt.Errorf("invalid field path %q", path)
+	}
+	rest := path[len(m[0]):]
+
+	if m[1] != "" {
+		fields, ok := obj.(map[string]any)
+		if !ok {
+			return false, nil
+		}
+		value, ok := fields[m[1]]
+		if !ok {
+			return false, nil
+		}
+		if rest == "" {
+			delete(fields, m[1])
+			return true, nil
+		}
+		return removeField(value, rest)
+	}
+
+	items, ok := obj.([]any)
+	if !ok {
+		return false, nil
+	}
+	var want any
+	if err := json.Unmarshal([]byte(m[3]), &want); err != nil {
+		return false, fmt.Errorf("invalid field path %q: %w", path, err)
+	}
+	for i, item := range items {
+		var value any = item
+		if m[2] != "" {
+			fields, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			value = fields[m[2]]
+		}
+		if fmt.Sprint(value) != fmt.Sprint(want) {
+			continue
+		}
+		if rest == "" {
+			// Elements of lists can't be removed in place.
+			return false, fmt.Errorf("invalid field path %q: skipping a whole element of a list is not supported, skip its fields instead", path)
+		}
+		return removeField(items[i], rest)
+	}
+	return false, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseApplyConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   string
+		expected []FieldConflict
+	}{
+		{
+			name: "single conflict",
+			stderr: `error: Apply failed with 1 conflict: conflict with "kubectl-client-side-apply" using apps/v1: .spec.replicas
+Please review the fields above--they currently have other managers. Here
+are the ways you can resolve this warning:
+* If you intend to manage all of these fields, please re-run the apply
+  command with the ` + "`--force-conflicts`" + ` flag.`,
+			expected: []FieldConflict{{Manager: "kubectl-client-side-apply", APIVersion: "apps/v1", Fields: []string{".spec.replicas"}}},
+		},
+		{
+			name: "conflicts with several managers",
+			stderr: `error: Apply failed with 3 conflicts: conflicts with "argocd-controller" using apps/v1:
+- .spec.template.spec.containers[name="web"].image
+- .metadata.labels.version
+conflicts with "hpa-controller":
+- .spec.replicas
+Please review the fields above--they currently have other managers.`,
+			expected: []FieldConflict{
+				{Manager: "argocd-controller", APIVersion: "apps/v1", Fields: []string{`.spec.template.spec.containers[name="web"].image`, ".metadata.labels.version"}},
+				{Manager: "hpa-controller", Fields: []string{".spec.replicas"}},
+			},
+		},
+		{
+			name:   "other error",
+			stderr: `Error from server (NotFound): namespaces "demo" not found`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseApplyConflicts(tc.stderr); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRemoveManifestFields(t *testing.T) {
+	manifest, found, err := removeManifestFields([]byte(validDeployment+"---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"), []string{
+		"spec.replicas",
+		`.spec.template.spec.containers[name="web"].image`,
+		`.spec.template.spec.containers[name="web"].ports[containerPort=80].containerPort`,
+		".spec.paused",
+	})
+	if err != nil {
+		t.Fatalf("removeManifestFields: %v", err)
+	}
+	expected := []string{".spec.replicas", `.spec.template.spec.containers[name="web"].image`, `.spec.template.spec.containers[name="web"].ports[containerPort=80].containerPort`}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected the fields %q to be found, got %q", expected, found)
+	}
+	for _, removed := range []string{`"replicas"`, `"image"`, `"containerPort"`} {
+		if strings.Contains(string(manifest), removed) {
+			t.Errorf("expected %s to be removed, got %s", removed, manifest)
+		}
+	}
+	if !strings.Contains(string(manifest), `"kind":"Service"`) || !strings.Contains(string(manifest), `"memory":"128Mi"`) {
+		t.Errorf("expected the other fields to be kept, got %s", manifest)
+	}
+
+	if _, _, err := removeManifestFields([]byte(validDeployment), []string{`.spec.template.spec.containers[name="web"]`}); err == nil {
+		t.Errorf("expected an error when skipping an element of a list")
+	}
+}
+
+func TestApplyManifest(t *testing.T) {
+	dir := t.TempDir()
+	// The fake kubectl reports a conflict unless it is forced, and keeps the manifest it was given.
+	script := `cat > "` + filepath.Join(dir, "applied.json") + `"
+case "$*" in
+*--force-conflicts*) echo "deployment.apps/web serverside-applied" ;;
+*) echo 'error: Apply failed with 1 conflict: conflict with "hpa-controller" using apps/v1: .spec.replicas' >&2; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	ctx := context.Background()
+	tool := &ApplyManifest{}
+
+	out, err := tool.Run(ctx, map[string]any{"manifest": validDeployment})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	result := out.(*ApplyManifestResult)
+	expected := []FieldConflict{{Manager: "hpa-controller", APIVersion: "apps/v1", Fields: []string{".spec.replicas"}}}
+	if !reflect.DeepEqual(result.Conflicts, expected) || len(result.Options) != 3 || len(result.Applied) != 0 {
+		t.Errorf("expected the conflicts %+v with the ways to resolve them, got %+v", expected, result)
+	}
+
+	out, err = tool.Run(ctx, map[string]any{"manifest": validDeployment, "force_conflicts": true, "skip_fields": []any{".metadata.labels"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	result = out.(*ApplyManifestResult)
+	if result.Error != "" || !reflect.DeepEqual(result.Applied, []string{"deployment.apps/web serverside-applied"}) || !reflect.DeepEqual(result.SkippedFields, []string{".metadata.labels"}) {
+		t.Errorf("expected the deployment to be applied without its labels, got %+v", result)
+	}
+	applied, err := os.ReadFile(filepath.Join(dir, "applied.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(applied), `"labels":{"app":"web"},"name":"web"`) {
+		t.Errorf("expected the labels of the deployment to be skipped, got %s", applied)
+	}
+}
@@ -18,11 +18,13 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -63,6 +65,22 @@ func expandShellVar(value string) (string, error) {
 	return os.ExpandEnv(value), nil
 }
 
+// commandEnv returns the environment for commands run by tools: the environment
+// of the process, overridden by the variables set in the context.
+func commandEnv(ctx context.Context) []string {
+	env := os.Environ()
+	extra, _ := ctx.Value(EnvKey).(map[string]string)
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+extra[k])
+	}
+	return env
+}
+
 type BashTool struct{}
 
 func (t *BashTool) Name() string {
@@ -117,7 +135,7 @@ func (t *BashTool) Run(ctx context.Context, args map[string]any) (any, error) {
 		cmd = exec.CommandContext(ctx, lookupBashBin(), "-c", command)
 	}
 	cmd.Dir = workDir
-	cmd.Env = os.Environ()
+	cmd.Env = commandEnv(ctx)
 	if kubeconfig != "" {
 		kubeconfig, err := expandShellVar(kubeconfig)
 		if err != nil {
@@ -136,10 +154,31 @@ type ExecResult struct {
 	Stderr     string `json:"stderr,omitempty"`
 	ExitCode   int    `json:"exit_code,omitempty"`
 	StreamType string `json:"stream_type,omitempty"`
+	// Attempts is the number of times the command was run, set when it was
+	// retried after transient cluster errors.
+	Attempts int `json:"attempts,omitempty"`
+	// Result is the stdout parsed as JSON, for custom tools declaring an output schema.
+	Result any `json:"result,omitempty"`
+	// OriginalCommand is the command requested, when a more compact variant
+	// was run instead because its output was over budget, and Note explains it.
+	OriginalCommand string `json:"original_command,omitempty"`
+	Note            string `json:"note,omitempty"`
 }
 
 func (e *ExecResult) String() string {
-	return fmt.Sprintf("Command: %q\nError: %q\nStdout: %q\nStderr: %q\nExitCode: %d\nStreamType: %q}", e.Command, e.Error, e.Stdout, e.Stderr, e.ExitCode, e.StreamType)
+	s := fmt.Sprintf("Command: %q\nError: %q\nStdout: %q\nStderr: %q\nExitCode: %d\nStreamType: %q}", e.Command, e.Error, e.Stdout, e.Stderr, e.ExitCode, e.StreamType)
+	if e.Attempts > 0 {
+		s += fmt.Sprintf("\nAttempts: %d", e.Attempts)
+	}
+	if e.OriginalCommand != "" {
+		s += fmt.Sprintf("\nOriginalCommand: %q\nNote: %q", e.OriginalCommand, e.Note)
+	}
+	if e.Result != nil {
+		if b, err := json.Marshal(e.Result); err == nil {
+			s += "\nResult: " + string(b)
+		}
+	}
+	return s
 }
 
 func IsInteractiveCommand(command string) (bool, error) {
@@ -292,6 +331,11 @@ func executeCommand(ctx context.Context, cmd *exec.Cmd) (*ExecResult, error) {
 	return results, nil
 }
 
+// OutputSchema returns the schema of ExecResult.
+func (t *BashTool) OutputSchema() *gollm.Schema {
+	return execResultSchema()
+}
+
 func (t *BashTool) IsInteractive(args map[string]any) (bool, error) {
 	commandVal, ok := args["command"]
 	if !ok || commandVal == nil {
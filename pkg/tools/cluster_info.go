@@ -0,0 +1,152 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// ClusterInfo describes the cluster the agent is running against.
+// Fields that couldn't be determined are left empty.
+type ClusterInfo struct {
+	// Context is the current kubeconfig context.
+	Context string
+	// Version is the version of the API server, e.g. "v1.30.2-gke.1587003".
+	Version string
+	// Provider is the detected Kubernetes distribution: GKE, EKS, AKS, kind, ...
+	Provider string
+	// NodeCount is the number of nodes, or -1 if they can't be listed.
+	NodeCount int
+	// FeatureGates lists the alpha and beta feature gates enabled in the API server.
+	FeatureGates []string
+	// Namespace is the namespace of the current context.
+	Namespace string
+}
+
+// DiscoverClusterInfo queries the cluster with kubectl for the information
+// exposed to prompt templates. Discovery is best effort: failing queries
+// (e.g. for lack of permissions) only leave the matching fields empty.
+func DiscoverClusterInfo(ctx context.Context, opt InvokeToolOptions) *ClusterInfo {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+	log := klog.FromContext(ctx)
+
+	info := &ClusterInfo{NodeCount: -1, Namespace: "default"}
+	if out, err := kubectlOutput(ctx, "config", "current-context"); err != nil {
+		log.V(2).Info("Unable to determine current context", "err", err)
+	} else {
+		info.Context = strings.TrimSpace(string(out))
+	}
+	if out, err := kubectlOutput(ctx, "config", "view", "--minify", "-o", "jsonpath={..namespace}"); err != nil {
+		log.V(2).Info("Unable to determine current namespace", "err", err)
+	} else if ns := strings.TrimSpace(string(out)); ns != "" {
+		info.Namespace = ns
+	}
+	if out, err := kubectlOutput(ctx, "version", "-o", "json"); err != nil {
+		log.V(2).Info("Unable to determine server version", "err", err)
+	} else {
+		info.Version = parseServerVersion(out)
+	}
+	var providerIDs []string
+	if out, err := kubectlOutput(ctx, "get", "nodes", "-o", `jsonpath={range .items[*]}{.spec.providerID}{"\n"}{end}`); err != nil {
+		log.V(2).Info("Unable to list nodes", "err", err)
+	} else {
+		providerIDs = strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		if len(out) == 0 {
+			providerIDs = nil
+		}
+		info.NodeCount = len(providerIDs)
+	}
+	info.Provider = detectProvider(info.Version, providerIDs)
+	if out, err := kubectlOutput(ctx, "get", "--raw", "/metrics"); err != nil {
+		log.V(2).Info("Unable to read API server metrics for feature gates", "err", err)
+	} else {
+		info.FeatureGates = parseEnabledFeatureGates(string(out))
+	}
+	return info
+}
+
+// parseServerVersion extracts the server gitVersion from `kubectl version -o json`.
+func parseServerVersion(out []byte) string {
+	_, server := parseVersions(out)
+	return server
+}
+
+// detectProvider infers the Kubernetes distribution from the server version
+// and the provider IDs of the nodes.
+func detectProvider(version string, providerIDs []string) string {
+	switch {
+	case strings.Contains(version, "-gke."):
+		return "GKE"
+	case strings.Contains(version, "-eks-"):
+		return "EKS"
+	case strings.Contains(version, "+k3s"):
+		return "k3s"
+	}
+	for _, id := range providerIDs {
+		scheme, _, ok := strings.Cut(id, "://")
+		if !ok {
+			continue
+		}
+		switch scheme {
+		case "gce":
+			return "GCE"
+		case "aws":
+			return "AWS"
+		case "azure":
+			return "AKS"
+		case "kind":
+			return "kind"
+		case "digitalocean":
+			return "DOKS"
+		}
+	}
+	return ""
+}
+
+// featureEnabledMetric matches the samples of the kubernetes_feature_enabled
+// metric of the API server, capturing the labels and the value.
+var featureEnabledMetric = regexp.MustCompile(`^kubernetes_feature_enabled\{([^}]*)\}\s+(\S+)`)
+
+// metricLabel matches a label of a metric sample.
+var metricLabel = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// parseEnabledFeatureGates returns the sorted names of the alpha and beta
+// feature gates enabled according to the API server metrics. GA features are
+// always enabled, so they are left out.
+func parseEnabledFeatureGates(metrics string) []string {
+	var gates []string
+	for _, line := range strings.Split(metrics, "\n") {
+		m := featureEnabledMetric.FindStringSubmatch(line)
+		if m == nil || m[2] != "1" {
+			continue
+		}
+		labels := make(map[string]string)
+		for _, l := range metricLabel.FindAllStringSubmatch(m[1], -1) {
+			labels[l[1]] = l[2]
+		}
+		if labels["name"] != "" && (labels["stage"] == "ALPHA" || labels["stage"] == "BETA") {
+			gates = append(gates, labels["name"])
+		}
+	}
+	sort.Strings(gates)
+	return gates
+}
@@ -0,0 +1,82 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{
+			name: "server version",
+			out:  `{"clientVersion":{"gitVersion":"v1.31.0"},"serverVersion":{"major":"1","minor":"30","gitVersion":"v1.30.2-gke.1587003"}}`,
+			want: "v1.30.2-gke.1587003",
+		},
+		{name: "client only", out: `{"clientVersion":{"gitVersion":"v1.31.0"}}`, want: ""},
+		{name: "invalid", out: "error: unknown", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseServerVersion([]byte(tt.out)); got != tt.want {
+				t.Errorf("parseServerVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		providerIDs []string
+		want        string
+	}{
+		{name: "gke version", version: "v1.30.2-gke.1587003", providerIDs: []string{"gce://project/us-central1-a/node-1"}, want: "GKE"},
+		{name: "eks version", version: "v1.29.4-eks-036c24b", want: "EKS"},
+		{name: "k3s version", version: "v1.29.4+k3s1", want: "k3s"},
+		{name: "aks provider id", version: "v1.29.4", providerIDs: []string{"azure:///subscriptions/x/vm-0"}, want: "AKS"},
+		{name: "kind provider id", version: "v1.30.0", providerIDs: []string{"", "kind://docker/kind/kind-control-plane"}, want: "kind"},
+		{name: "unknown", version: "v1.30.0", providerIDs: []string{""}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectProvider(tt.version, tt.providerIDs); got != tt.want {
+				t.Errorf("detectProvider() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEnabledFeatureGates(t *testing.T) {
+	metrics := `# HELP kubernetes_feature_enabled [BETA] This metric records the data about the stage and enablement of a k8s feature.
+# TYPE kubernetes_feature_enabled gauge
+kubernetes_feature_enabled{name="APIListChunking",stage=""} 1
+kubernetes_feature_enabled{name="SidecarContainers",stage="BETA"} 1
+kubernetes_feature_enabled{name="InPlacePodVerticalScaling",stage="ALPHA"} 0
+kubernetes_feature_enabled{name="DynamicResourceAllocation",stage="ALPHA"} 1
+kubernetes_feature_enabled{name="LegacyFeature",stage="DEPRECATED"} 1
+apiserver_request_total{code="200"} 42
+`
+	want := []string{"DynamicResourceAllocation", "SidecarContainers"}
+	if got := parseEnabledFeatureGates(metrics); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEnabledFeatureGates() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,639 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+func init() {
+	RegisterTool(&ListCRDs{})
+	RegisterTool(&CustomResourceStatusTool{})
+	RegisterTool(&ControllerLogsTool{})
+}
+
+const (
+	// maxCRDControllers bounds the candidate controllers reported per CRD.
+	maxCRDControllers = 5
+	// maxControllerLogTail bounds the lines read from each controller, before
+	// they are filtered.
+	maxControllerLogTail = 20000
+
+	defaultControllerLogLines = 100
+	maxControllerLogLines     = 500
+	defaultControllerLogSince = time.Hour
+	maxControllerLogSince     = 24 * time.Hour
+)
+
+// crdGroupStopWords are the parts of API groups that don't identify a project.
+var crdGroupStopWords = []string{"io", "com", "org", "net", "dev", "sh", "k8s", "x-k8s", "sigs", "kubernetes", "api", "apis", "internal"}
+
+// Controller matches, from the strongest to the weakest.
+const (
+	crdMatchHelmRelease = iota
+	crdMatchLabel
+	crdMatchName
+)
+
+// ListCRDs lists the custom resource definitions of the cluster, with the
+// workloads that likely run their controllers.
+type ListCRDs struct{}
+
+func (t *ListCRDs) Name() string {
+	return "list_crds"
+}
+
+func (t *ListCRDs) Description() string {
+	return `Lists the CustomResourceDefinitions of the cluster with their group, kind, scope and versions, and the deployments that likely run their controller (operator), found from their Helm release, their app.kubernetes.io labels or their name.
+Use this tool when troubleshooting custom resources, e.g. "why is my certificate stuck", to find the operator responsible for a kind before reading its status and logs.`
+}
+
+func (t *ListCRDs) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"filter": {
+					Type:        gollm.TypeString,
+					Description: `Only list the CRDs whose name, group or kind contains this text, case insensitively (e.g. "cert-manager" or "Certificate"). Lists all CRDs if empty.`,
+				},
+			},
+		},
+	}
+}
+
+// CRDList is the result of the list_crds tool.
+type CRDList struct {
+	CRDs   []CRDInfo `json:"crds"`
+	Errors []string  `json:"errors,omitempty"`
+}
+
+// CRDInfo is a CustomResourceDefinition and its candidate controllers.
+type CRDInfo struct {
+	Name  string `json:"name"`
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	Scope string `json:"scope"`
+	// Versions are the served versions, the storage version first.
+	Versions    []string        `json:"versions"`
+	Controllers []CRDController `json:"controllers,omitempty"`
+}
+
+// CRDController is a workload that likely runs the controller of a CRD.
+type CRDController struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Match is why the workload is believed to run the controller.
+	Match string `json:"match"`
+	rank  int
+}
+
+func (t *ListCRDs) Run(ctx context.Context, args map[string]any) (any, error) {
+	filter, _ := args["filter"].(string)
+	crds, err := listCRDs(ctx)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+	if filter != "" {
+		filter = strings.ToLower(filter)
+		var filtered []crdObject
+		for _, crd := range crds {
+			if strings.Contains(strings.ToLower(crd.Metadata.Name+" "+crd.Spec.Names.Kind), filter) {
+				filtered = append(filtered, crd)
+			}
+		}
+		crds = filtered
+	}
+
+	list := &CRDList{CRDs: []CRDInfo{}}
+	var workloads []controllerWorkload
+	if len(crds) > 0 {
+		if workloads, err = listControllerWorkloads(ctx); err != nil {
+			list.Errors = append(list.Errors, fmt.Sprintf("listing deployments: %v", err))
+		}
+	}
+	for _, crd := range crds {
+		list.CRDs = append(list.CRDs, crd.info(workloads))
+	}
+	return list, nil
+}
+
+func (t *ListCRDs) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *ListCRDs) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// CustomResourceStatusTool returns the status of a custom resource in a
+// structured form.
+type CustomResourceStatusTool struct{}
+
+func (t *CustomResourceStatusTool) Name() string {
+	return "custom_resource_status"
+}
+
+func (t *CustomResourceStatusTool) Description() string {
+	return `Returns the status of a custom resource (or any other object) in a structured form: its status conditions with their reason and message, its phase, whether the controller observed its latest generation, its finalizers and deletion timestamp, the rest of its status and its recent events.
+Use this tool to understand why a custom resource is not ready or is stuck, instead of reading the full YAML.`
+}
+
+func (t *CustomResourceStatusTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"resource": {
+					Type:        gollm.TypeString,
+					Description: `The object as kind/name, e.g. "certificate/web-tls" or "certificates.cert-manager.io/web-tls".`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace of the object. Defaults to the namespace of the context.`,
+				},
+			},
+			Required: []string{"resource"},
+		},
+	}
+}
+
+// CustomResourceStatus is the result of the custom_resource_status tool.
+type CustomResourceStatus struct {
+	Resource   string `json:"resource"`
+	APIVersion string `json:"apiVersion"`
+	Namespace  string `json:"namespace,omitempty"`
+	Generation int64  `json:"generation,omitempty"`
+	// ObservedGeneration is the generation last processed by the controller,
+	// from the status or from the conditions.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Stale is true when the controller didn't process the latest spec yet.
+	Stale             bool                `json:"stale,omitempty"`
+	DeletionTimestamp string              `json:"deletionTimestamp,omitempty"`
+	Finalizers        []string            `json:"finalizers,omitempty"`
+	Phase             string              `json:"phase,omitempty"`
+	Conditions        []ResourceCondition `json:"conditions,omitempty"`
+	// Status is the rest of the status, without the conditions.
+	Status map[string]any `json:"status,omitempty"`
+	Events []string       `json:"events,omitempty"`
+	Errors []string       `json:"errors,omitempty"`
+}
+
+// ResourceCondition is a status condition of an object.
+type ResourceCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+}
+
+func (t *CustomResourceStatusTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	resource, _ := args["resource"].(string)
+	namespace, _ := args["namespace"].(string)
+	if resource == "" {
+		return &ExecResult{Error: "resource must be provided"}, nil
+	}
+
+	obj, err := getObject(ctx, resource, namespace)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+	status, err := customResourceStatus(obj)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+
+	eventArgs := []string{"get", "events", "-o", "json", "--field-selector", "involvedObject.name=" + obj.Metadata.Name + ",involvedObject.kind=" + obj.Kind}
+	if obj.Metadata.Namespace != "" {
+		eventArgs = append(eventArgs, "--namespace", obj.Metadata.Namespace)
+	}
+	if out, err := kubectlOutput(ctx, eventArgs...); err != nil {
+		status.Errors = append(status.Errors, fmt.Sprintf("listing events: %v", err))
+	} else if entries, err := eventTimelineEntries(out); err != nil {
+		status.Errors = append(status.Errors, fmt.Sprintf("parsing events: %v", err))
+	} else {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+		for _, e := range entries {
+			status.Events = append(status.Events, fmt.Sprintf("%s %s %s: %s", e.Time.Format(time.RFC3339), e.Severity, e.Reason, e.Message))
+		}
+	}
+	return status, nil
+}
+
+func (t *CustomResourceStatusTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *CustomResourceStatusTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// ControllerLogsTool returns the log lines of the controller of a custom
+// resource that mention the resource.
+type ControllerLogsTool struct{}
+
+func (t *ControllerLogsTool) Name() string {
+	return "controller_logs"
+}
+
+func (t *ControllerLogsTool) Description() string {
+	return `Returns the recent log lines of the controller (operator) of a custom resource that mention the resource by name.
+The controller is found like with list_crds, unless it is given. The logs of one pod of each candidate controller are read.
+Use this tool after custom_resource_status, to find why the controller doesn't make progress on a resource.`
+}
+
+func (t *ControllerLogsTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"resource": {
+					Type:        gollm.TypeString,
+					Description: `The custom resource as kind/name, e.g. "certificate/web-tls".`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace of the custom resource. Defaults to the namespace of the context.`,
+				},
+				"controller": {
+					Type:        gollm.TypeString,
+					Description: `The workload of the controller as namespace/kind/name (e.g. "cert-manager/deployment/cert-manager"), if it is known or was not found automatically.`,
+				},
+				"since": {
+					Type:        gollm.TypeString,
+					Description: `How far back to read the logs, as a duration (e.g. "30m", "2h"). Defaults to "1h", at most "24h".`,
+				},
+				"lines": {
+					Type:        gollm.TypeInteger,
+					Description: `Maximum number of matching lines to return per controller, keeping the most recent ones. Defaults to 100, at most 500.`,
+				},
+			},
+			Required: []string{"resource"},
+		},
+	}
+}
+
+// ControllerLogs is the result of the controller_logs tool.
+type ControllerLogs struct {
+	Resource    string          `json:"resource"`
+	Namespace   string          `json:"namespace,omitempty"`
+	Controllers []ControllerLog `json:"controllers"`
+}
+
+// ControllerLog are the log lines of a controller mentioning the resource.
+type ControllerLog struct {
+	Controller string `json:"controller"`
+	Namespace  string `json:"namespace"`
+	Match      string `json:"match,omitempty"`
+	Logs       string `json:"logs,omitempty"`
+	// Truncated is the number of older matching lines dropped to respect lines.
+	Truncated int    `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (t *ControllerLogsTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	resource, _ := args["resource"].(string)
+	namespace, _ := args["namespace"].(string)
+	controller, _ := args["controller"].(string)
+	if resource == "" {
+		return &ExecResult{Error: "resource must be provided"}, nil
+	}
+	since := defaultControllerLogSince
+	if s, ok := args["since"].(string); ok && s != "" {
+		parsed, err := time.ParseDuration(s)
+		if err != nil || parsed <= 0 {
+			return &ExecResult{Error: fmt.Sprintf("invalid since %q, expected a positive duration like 30m or 2h", s)}, nil
+		}
+		since = min(parsed, maxControllerLogSince)
+	}
+	lines := defaultControllerLogLines
+	if n, ok := args["lines"].(float64); ok && n > 0 {
+		lines = min(int(n), maxControllerLogLines)
+	}
+
+	obj, err := getObject(ctx, resource, namespace)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+
+	var controllers []CRDController
+	if controller != "" {
+		parts := strings.Split(controller, "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return &ExecResult{Error: fmt.Sprintf("invalid controller %q, expected namespace/kind/name", controller)}, nil
+		}
+		controllers = []CRDController{{Namespace: parts[0], Kind: parts[1], Name: parts[2]}}
+	} else {
+		group, _, found := strings.Cut(obj.APIVersion, "/")
+		if !found {
+			return &ExecResult{Error: fmt.Sprintf("%s is not a custom resource", resource)}, nil
+		}
+		crds, err := listCRDs(ctx)
+		if err != nil {
+			return &ExecResult{Error: err.Error()}, nil
+		}
+		i := findCRD(crds, group, obj.Kind)
+		if i < 0 {
+			return &ExecResult{Error: fmt.Sprintf("no CustomResourceDefinition found for kind %s of group %s", obj.Kind, group)}, nil
+		}
+		workloads, err := listControllerWorkloads(ctx)
+		if err != nil {
+			return &ExecResult{Error: err.Error()}, nil
+		}
+		controllers = crds[i].info(workloads).Controllers
+		if len(controllers) == 0 {
+			return &ExecResult{Error: fmt.Sprintf("no controller found for %s, find it and pass it as controller", crds[i].Metadata.Name)}, nil
+		}
+	}
+
+	result := &ControllerLogs{
+		Resource:    strings.ToLower(obj.Kind) + "/" + obj.Metadata.Name,
+		Namespace:   obj.Metadata.Namespace,
+		Controllers: []ControllerLog{},
+	}
+	for _, c := range controllers {
+		log := ControllerLog{Controller: strings.ToLower(c.Kind) + "/" + c.Name, Namespace: c.Namespace, Match: c.Match}
+		out, err := kubectlOutput(ctx, "logs", log.Controller, "--namespace", c.Namespace, "--all-containers",
+			fmt.Sprintf("--since=%ds", int(since.Seconds())), fmt.Sprintf("--tail=%d", maxControllerLogTail))
+		if err != nil {
+			log.Error = err.Error()
+		} else {
+			matched := mentioningLines(string(out), obj.Metadata.Name)
+			if len(matched) > lines {
+				log.Truncated = len(matched) - lines
+				matched = matched[log.Truncated:]
+			}
+			log.Logs = strings.Join(matched, "\n")
+		}
+		result.Controllers = append(result.Controllers, log)
+	}
+	return result, nil
+}
+
+func (t *ControllerLogsTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *ControllerLogsTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// Minimal views of the objects we read, to avoid depending on client-go.
+
+type crdObject struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Scope    string `json:"scope"`
+		Versions []struct {
+			Name    string `json:"name"`
+			Served  bool   `json:"served"`
+			Storage bool   `json:"storage"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+type controllerWorkload struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		Labels          map[string]string `json:"labels"`
+		Annotations     map[string]string `json:"annotations"`
+		OwnerReferences []struct {
+			Kind string `json:"kind"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+}
+
+type statusObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name              string   `json:"name"`
+		Namespace         string   `json:"namespace"`
+		Generation        int64    `json:"generation"`
+		DeletionTimestamp string   `json:"deletionTimestamp"`
+		Finalizers        []string `json:"finalizers"`
+	} `json:"metadata"`
+	Status map[string]any `json:"status"`
+}
+
+func listCRDs(ctx context.Context) ([]crdObject, error) {
+	out, err := kubectlOutput(ctx, "get", "customresourcedefinitions", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var list objectList[crdObject]
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("parsing CustomResourceDefinitions: %w", err)
+	}
+	return list.Items, nil
+}
+
+// listControllerWorkloads lists the deployments that may run controllers.
+// Operators run as deployments; statefulsets and daemonsets are more often
+// their operands.
+func listControllerWorkloads(ctx context.Context) ([]controllerWorkload, error) {
+	out, err := kubectlOutput(ctx, "get", "deployments", "--all-namespaces", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var list objectList[controllerWorkload]
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("parsing deployments: %w", err)
+	}
+	for i := range list.Items {
+		if list.Items[i].Kind == "" {
+			list.Items[i].Kind = "Deployment"
+		}
+	}
+	return list.Items, nil
+}
+
+func getObject(ctx context.Context, resource, namespace string) (*statusObject, error) {
+	args := []string{"get", resource, "-o", "json"}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	out, err := kubectlOutput(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var obj statusObject
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", resource, err)
+	}
+	if obj.Kind == "List" {
+		return nil, fmt.Errorf("%s is not a single object, expected kind/name", resource)
+	}
+	return &obj, nil
+}
+
+func findCRD(crds []crdObject, group, kind string) int {
+	for i, crd := range crds {
+		if crd.Spec.Group == group && crd.Spec.Names.Kind == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// info returns the description of the CRD, with the workloads likely running
+// its controller.
+func (crd *crdObject) info(workloads []controllerWorkload) CRDInfo {
+	info := CRDInfo{
+		Name:     crd.Metadata.Name,
+		Group:    crd.Spec.Group,
+		Kind:     crd.Spec.Names.Kind,
+		Scope:    crd.Spec.Scope,
+		Versions: []string{},
+	}
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			info.Versions = append([]string{v.Name}, info.Versions...)
+		} else if v.Served {
+			info.Versions = append(info.Versions, v.Name)
+		}
+	}
+
+	var tokens []string
+	for _, part := range strings.Split(crd.Spec.Group, ".") {
+		if len(part) >= 3 && !slices.Contains(crdGroupStopWords, part) {
+			tokens = append(tokens, part)
+		}
+	}
+	for _, w := range workloads {
+		// Workloads owned by another object, e.g. by a custom resource, are
+		// operands. Operators installed by OLM are owned by their ClusterServiceVersion.
+		if len(w.Metadata.OwnerReferences) > 0 && w.Metadata.OwnerReferences[0].Kind != "ClusterServiceVersion" {
+			continue
+		}
+		c := CRDController{Kind: w.Kind, Name: w.Metadata.Name, Namespace: w.Metadata.Namespace, rank: -1}
+		release := crd.Metadata.Annotations["meta.helm.sh/release-name"]
+		if release != "" && release == w.Metadata.Annotations["meta.helm.sh/release-name"] &&
+			crd.Metadata.Annotations["meta.helm.sh/release-namespace"] == w.Metadata.Annotations["meta.helm.sh/release-namespace"] {
+			c.rank, c.Match = crdMatchHelmRelease, "same Helm release "+release
+		}
+		for _, label := range []string{"app.kubernetes.io/part-of", "app.kubernetes.io/instance"} {
+			if c.rank >= 0 {
+				break
+			}
+			if value := crd.Metadata.Labels[label]; value != "" && value == w.Metadata.Labels[label] {
+				c.rank, c.Match = crdMatchLabel, fmt.Sprintf("same label %s=%s", label, value)
+			}
+		}
+		for _, token := range tokens {
+			if c.rank >= 0 {
+				break
+			}
+			if strings.Contains(w.Metadata.Name, token) {
+				c.rank, c.Match = crdMatchName, fmt.Sprintf("name contains %q of the group", token)
+			}
+		}
+		if c.rank >= 0 {
+			info.Controllers = append(info.Controllers, c)
+		}
+	}
+	sort.SliceStable(info.Controllers, func(i, j int) bool {
+		return info.Controllers[i].rank < info.Controllers[j].rank
+	})
+	if len(info.Controllers) > maxCRDControllers {
+		info.Controllers = info.Controllers[:maxCRDControllers]
+	}
+	return info
+}
+
+// customResourceStatus extracts the status of an object.
+func customResourceStatus(obj *statusObject) (*CustomResourceStatus, error) {
+	status := &CustomResourceStatus{
+		Resource:          strings.ToLower(obj.Kind) + "/" + obj.Metadata.Name,
+		APIVersion:        obj.APIVersion,
+		Namespace:         obj.Metadata.Namespace,
+		Generation:        obj.Metadata.Generation,
+		DeletionTimestamp: obj.Metadata.DeletionTimestamp,
+		Finalizers:        obj.Metadata.Finalizers,
+	}
+	rest := map[string]any{}
+	for key, value := range obj.Status {
+		rest[key] = value
+	}
+	if conditions, ok := rest["conditions"]; ok {
+		b, err := json.Marshal(conditions)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &status.Conditions); err != nil {
+			return nil, fmt.Errorf("parsing the conditions of %s: %w", status.Resource, err)
+		}
+		delete(rest, "conditions")
+	}
+	for _, key := range []string{"phase", "state"} {
+		if phase, ok := rest[key].(string); ok && status.Phase == "" {
+			status.Phase = phase
+			delete(rest, key)
+		}
+	}
+	if observed, ok := rest["observedGeneration"].(float64); ok {
+		status.ObservedGeneration = int64(observed)
+		delete(rest, "observedGeneration")
+	} else {
+		for _, c := range status.Conditions {
+			status.ObservedGeneration = max(status.ObservedGeneration, c.ObservedGeneration)
+		}
+	}
+	status.Stale = status.ObservedGeneration > 0 && status.ObservedGeneration < status.Generation
+	if len(rest) > 0 {
+		status.Status = rest
+	}
+	return status, nil
+}
+
+// mentioningLines returns the lines of logs mentioning name as a word.
+func mentioningLines(logs, name string) []string {
+	pattern := regexp.MustCompile(`(^|[^a-zA-Z0-9-])` + regexp.QuoteMeta(name) + `($|[^a-zA-Z0-9-])`)
+	var lines []string
+	for _, line := range strings.Split(logs, "\n") {
+		if pattern.MatchString(line) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
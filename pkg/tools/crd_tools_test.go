@@ -0,0 +1,117 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCRDInfo(t *testing.T) {
+	var crds objectList[crdObject]
+	if err := json.Unmarshal([]byte(`{"items":[
+		{"metadata":{"name":"certificates.cert-manager.io","annotations":{"meta.helm.sh/release-name":"cm","meta.helm.sh/release-namespace":"cert-manager"}},
+		 "spec":{"group":"cert-manager.io","names":{"kind":"Certificate"},"scope":"Namespaced","versions":[{"name":"v1alpha2","served":true},{"name":"v1","served":true,"storage":true},{"name":"v1alpha1"}]}},
+		{"metadata":{"name":"clusters.postgresql.cnpg.io","labels":{"app.kubernetes.io/part-of":"cloudnative-pg"}},
+		 "spec":{"group":"postgresql.cnpg.io","names":{"kind":"Cluster"},"scope":"Namespaced","versions":[{"name":"v1","served":true,"storage":true}]}},
+		{"metadata":{"name":"widgets.example.com"},
+		 "spec":{"group":"example.com","names":{"kind":"Widget"},"scope":"Cluster","versions":[{"name":"v1","served":true,"storage":true}]}}]}`), &crds); err != nil {
+		t.Fatal(err)
+	}
+	var workloads objectList[controllerWorkload]
+	if err := json.Unmarshal([]byte(`{"items":[
+		{"kind":"Deployment","metadata":{"name":"cert-manager-webhook","namespace":"cert-manager"}},
+		{"kind":"Deployment","metadata":{"name":"cert-manager","namespace":"cert-manager","annotations":{"meta.helm.sh/release-name":"cm","meta.helm.sh/release-namespace":"cert-manager"}}},
+		{"kind":"Deployment","metadata":{"name":"cnpg-controller-manager","namespace":"cnpg-system","labels":{"app.kubernetes.io/part-of":"cloudnative-pg"}}},
+		{"kind":"Deployment","metadata":{"name":"postgresql-pooler","namespace":"db","ownerReferences":[{"kind":"Pooler"}]}},
+		{"kind":"Deployment","metadata":{"name":"web","namespace":"shop"}}]}`), &workloads); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []CRDInfo{
+		{
+			Name: "certificates.cert-manager.io", Group: "cert-manager.io", Kind: "Certificate", Scope: "Namespaced",
+			Versions: []string{"v1", "v1alpha2"},
+			Controllers: []CRDController{
+				{Kind: "Deployment", Name: "cert-manager", Namespace: "cert-manager", Match: "same Helm release cm", rank: crdMatchHelmRelease},
+				{Kind: "Deployment", Name: "cert-manager-webhook", Namespace: "cert-manager", Match: `name contains "cert-manager" of the group`, rank: crdMatchName},
+			},
+		},
+		{
+			Name: "clusters.postgresql.cnpg.io", Group: "postgresql.cnpg.io", Kind: "Cluster", Scope: "Namespaced",
+			Versions: []string{"v1"},
+			Controllers: []CRDController{
+				{Kind: "Deployment", Name: "cnpg-controller-manager", Namespace: "cnpg-system", Match: "same label app.kubernetes.io/part-of=cloudnative-pg", rank: crdMatchLabel},
+			},
+		},
+		{
+			Name: "widgets.example.com", Group: "example.com", Kind: "Widget", Scope: "Cluster",
+			Versions: []string{"v1"},
+		},
+	}
+	for i, crd := range crds.Items {
+		if got := crd.info(workloads.Items); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("info() = %+v, want %+v", got, want[i])
+		}
+	}
+}
+
+func TestCustomResourceStatus(t *testing.T) {
+	var obj statusObject
+	if err := json.Unmarshal([]byte(`{"apiVersion":"cert-manager.io/v1","kind":"Certificate",
+		"metadata":{"name":"web-tls","namespace":"shop","generation":3,"finalizers":["finalizer.cert-manager.io"]},
+		"status":{"conditions":[{"type":"Ready","status":"False","reason":"Issuing","message":"Issuing certificate as Secret does not exist","lastTransitionTime":"2025-06-01T10:00:00Z","observedGeneration":2}],
+		"phase":"Pending","revision":1}}`), &obj); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := customResourceStatus(&obj)
+	if err != nil {
+		t.Fatalf("customResourceStatus() error = %v", err)
+	}
+	want := &CustomResourceStatus{
+		Resource:           "certificate/web-tls",
+		APIVersion:         "cert-manager.io/v1",
+		Namespace:          "shop",
+		Generation:         3,
+		ObservedGeneration: 2,
+		Stale:              true,
+		Finalizers:         []string{"finalizer.cert-manager.io"},
+		Phase:              "Pending",
+		Conditions: []ResourceCondition{{
+			Type: "Ready", Status: "False", Reason: "Issuing", Message: "Issuing certificate as Secret does not exist",
+			LastTransitionTime: "2025-06-01T10:00:00Z", ObservedGeneration: 2,
+		}},
+		Status: map[string]any{"revision": float64(1)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("customResourceStatus() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMentioningLines(t *testing.T) {
+	logs := `I0601 reconciling certificate shop/web-tls
+I0601 reconciling certificate shop/web-tls-2
+E0601 "msg"="issuer not ready" "name"="web-tls"
+I0601 nothing to do for web`
+	want := []string{
+		"I0601 reconciling certificate shop/web-tls",
+		`E0601 "msg"="issuer not ready" "name"="web-tls"`,
+	}
+	if got := mentioningLines(logs, "web-tls"); !reflect.DeepEqual(got, want) {
+		t.Errorf("mentioningLines() = %q, want %q", got, want)
+	}
+}
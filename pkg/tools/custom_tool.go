@@ -16,10 +16,11 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"mvdan.cc/sh/v3/syntax"
@@ -27,16 +28,51 @@ import (
 
 // CustomToolConfig defines the structure for configuring a custom tool.
 type CustomToolConfig struct {
-	Name          string `yaml:"name"`
-	Description   string `yaml:"description"`
-	Command       string `yaml:"command"`
-	CommandDesc   string `yaml:"command_desc"`
-	IsInteractive bool   `yaml:"is_interactive"`
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	// Type is the kind of custom tool: "command" (the default) or "http".
+	Type          string `yaml:"type" json:"type,omitempty"`
+	Command       string `yaml:"command" json:"command,omitempty"`
+	CommandDesc   string `yaml:"command_desc" json:"command_desc,omitempty"`
+	IsInteractive bool   `yaml:"is_interactive" json:"is_interactive,omitempty"`
+	// ModifiesResource tells whether the command modifies resources: "yes",
+	// "no" or "unknown" (the default). Commands that may modify resources
+	// require confirmation.
+	ModifiesResource string `yaml:"modifies_resource" json:"modifies_resource,omitempty"`
+	// Timeout bounds the run time of the command, e.g. "5m". No limit if empty.
+	Timeout string `yaml:"timeout" json:"timeout,omitempty"`
+
+	// HTTP configures the request made by tools of type "http".
+	HTTP *HTTPToolConfig `yaml:"http" json:"http,omitempty"`
+	// Parameters are the arguments the LLM passes to tools of type "http".
+	Parameters []CustomToolParameter `yaml:"parameters" json:"parameters,omitempty"`
+
+	// OutputSchema is the schema of the JSON printed by the command, or of
+	// the response body for tools of type "http".
+	OutputSchema *gollm.Schema `yaml:"output_schema" json:"output_schema,omitempty"`
+}
+
+const (
+	customToolTypeCommand = "command"
+	customToolTypeHTTP    = "http"
+)
+
+// newToolFromConfig creates the custom tool matching the configured type.
+func newToolFromConfig(config CustomToolConfig) (Tool, error) {
+	switch config.Type {
+	case "", customToolTypeCommand:
+		return NewCustomTool(config)
+	case customToolTypeHTTP:
+		return NewHTTPTool(config)
+	default:
+		return nil, fmt.Errorf("unknown custom tool type %q for tool %q", config.Type, config.Name)
+	}
 }
 
 // CustomTool implements the Tool interface for external commands.
 type CustomTool struct {
-	config CustomToolConfig
+	config  CustomToolConfig
+	timeout time.Duration
 }
 
 // NewCustomTool creates a new CustomTool instance.
@@ -47,8 +83,21 @@ func NewCustomTool(config CustomToolConfig) (*CustomTool, error) {
 	if len(config.Command) == 0 {
 		return nil, fmt.Errorf("custom tool command cannot be empty for tool %q", config.Name)
 	}
+	switch config.ModifiesResource {
+	case "", "yes", "no", "unknown":
+	default:
+		return nil, fmt.Errorf("modifies_resource must be yes, no or unknown for tool %q, got %q", config.Name, config.ModifiesResource)
+	}
 
-	return &CustomTool{config: config}, nil
+	t := &CustomTool{config: config}
+	if config.Timeout != "" {
+		var err error
+		t.timeout, err = time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("parsing timeout for tool %q: %w", config.Name, err)
+		}
+	}
+	return t, nil
 }
 
 // Name returns the tool's name.
@@ -138,18 +187,50 @@ func (t *CustomTool) Run(ctx context.Context, args map[string]any) (any, error)
 
 	workDir := ctx.Value(WorkDirKey).(string)
 
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
 	cmd := exec.CommandContext(ctx, lookupBashBin(), "-c", command)
 	cmd.Dir = workDir
-	cmd.Env = os.Environ()
+	cmd.Env = commandEnv(ctx)
 
-	return executeCommand(ctx, cmd)
+	result, err := executeCommand(ctx, cmd)
+	if err != nil || t.config.OutputSchema == nil || result.ExitCode != 0 || result.StreamType != "" {
+		return result, err
+	}
+	// The command prints a structured result, pass it to the LLM as JSON
+	// rather than as text.
+	if err := json.Unmarshal([]byte(result.Stdout), &result.Result); err != nil {
+		result.Error = fmt.Sprintf("expected the command to print JSON: %v", err)
+		return result, nil
+	}
+	result.Stdout = ""
+	return result, nil
+}
+
+// OutputSchema returns the schema of ExecResult, including the configured
+// schema of the command output.
+func (t *CustomTool) OutputSchema() *gollm.Schema {
+	schema := execResultSchema()
+	if t.config.OutputSchema != nil {
+		result := *t.config.OutputSchema
+		if result.Description == "" {
+			result.Description = "The output of the command, parsed as JSON. stdout is omitted when set."
+		}
+		schema.Properties["result"] = &result
+	}
+	return schema
 }
 
 // CheckModifiesResource determines if the command modifies resources
 // For custom tools, we'll conservatively assume they might modify resources
-// unless we have specific knowledge otherwise
+// unless the configuration tells otherwise
 // Returns "yes", "no", or "unknown"
 func (t *CustomTool) CheckModifiesResource(args map[string]any) string {
-	// For custom tools, we'll conservatively use "unknown" since we can't
+	if t.config.ModifiesResource != "" {
+		return t.config.ModifiesResource
+	}
 	return "unknown"
 }
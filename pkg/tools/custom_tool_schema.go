@@ -0,0 +1,466 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/itchyny/gojq"
+	"sigs.k8s.io/yaml"
+	yamlv3 "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+// CustomToolsSchema is the JSON schema of custom tools configuration files,
+// e.g. for editors to complete and check them.
+//
+//go:embed custom_tools.schema.json
+var CustomToolsSchema []byte
+
+// customToolsSchema is the subset of JSON schema CustomToolsSchema is written with.
+type customToolsSchema struct {
+	Ref         string                        `json:"$ref,omitempty"`
+	Type        string                        `json:"type,omitempty"`
+	Properties  map[string]*customToolsSchema `json:"properties,omitempty"`
+	Items       *customToolsSchema            `json:"items,omitempty"`
+	Required    []string                      `json:"required,omitempty"`
+	Enum        []string                      `json:"enum,omitempty"`
+	Pattern     string                        `json:"pattern,omitempty"`
+	Definitions map[string]*customToolsSchema `json:"definitions,omitempty"`
+	// AdditionalProperties is false, or the schema of the other properties.
+	AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+}
+
+var loadCustomToolsSchema = sync.OnceValues(func() (*customToolsSchema, error) {
+	var schema customToolsSchema
+	if err := json.Unmarshal(CustomToolsSchema, &schema); err != nil {
+		return nil, fmt.Errorf("parsing custom tools schema: %w", err)
+	}
+	return &schema, nil
+})
+
+// yaml11Booleans are the unquoted values read as booleans by the YAML parser
+// the configurations are decoded with, e.g. modifies_resource: no.
+var yaml11Booleans = []string{"y", "yes", "n", "no", "true", "false", "on", "off"}
+
+// CustomToolError is a problem found in a custom tools configuration file.
+type CustomToolError struct {
+	Line int
+	// Tool is the name of the tool, or its position in the file if it has none.
+	Tool string
+	// Field is the path of the field in the tool, e.g. http.url.
+	Field   string
+	Message string
+}
+
+func (e CustomToolError) String() string {
+	var parts []string
+	if e.Tool != "" {
+		parts = append(parts, e.Tool)
+	}
+	if e.Field != "" {
+		parts = append(parts, e.Field)
+	}
+	return strings.Join(append(parts, e.Message), ": ")
+}
+
+// InvalidCustomToolsError lists the problems of a custom tools configuration
+// file. None of the tools of an invalid file are registered.
+type InvalidCustomToolsError struct {
+	// Path is the path of the file, empty for configurations not read from a file.
+	Path   string
+	Errors []CustomToolError
+}
+
+func (e *InvalidCustomToolsError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("invalid custom tools configuration")
+	if e.Path != "" {
+		sb.WriteString(" " + e.Path)
+	}
+	sb.WriteString(":")
+	for _, err := range e.Errors {
+		// file:line, for editors and terminals to link to the line.
+		if e.Path != "" {
+			fmt.Fprintf(&sb, "\n - %s:%d: %s", e.Path, err.Line, err)
+		} else {
+			fmt.Fprintf(&sb, "\n - line %d: %s", err.Line, err)
+		}
+	}
+	return sb.String()
+}
+
+// validatedTool is a custom tool configuration found valid, and its line.
+type validatedTool struct {
+	config CustomToolConfig
+	line   int
+}
+
+// ValidateCustomTools checks a custom tools configuration file against
+// CustomToolsSchema, and for the problems the schema can't describe: names
+// already used by other tools, template variables that are not declared
+// parameters, and invalid timeouts, templates or jq filters. It returns the
+// configurations if the file is valid, or an *InvalidCustomToolsError listing
+// every problem with its line. path is only used in the errors.
+func ValidateCustomTools(path string, data []byte) ([]CustomToolConfig, error) {
+	validated, err := validateCustomTools(path, data)
+	if err != nil {
+		return nil, err
+	}
+	var configs []CustomToolConfig
+	for _, t := range validated {
+		configs = append(configs, t.config)
+	}
+	return configs, nil
+}
+
+func validateCustomTools(path string, data []byte) ([]validatedTool, error) {
+	schema, err := loadCustomToolsSchema()
+	if err != nil {
+		return nil, err
+	}
+	invalid := &InvalidCustomToolsError{Path: path}
+
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		invalid.Errors = append(invalid.Errors, CustomToolError{Line: yamlErrorLine(err), Message: fmt.Sprintf("invalid YAML: %v", err)})
+		return nil, invalid
+	}
+	if len(doc.Content) == 0 {
+		// Empty file, or only comments.
+		return nil, nil
+	}
+	root := resolveAlias(doc.Content[0])
+	if root.Tag == "!!null" {
+		return nil, nil
+	}
+	if root.Kind != yamlv3.SequenceNode {
+		invalid.Errors = append(invalid.Errors, CustomToolError{Line: root.Line, Message: fmt.Sprintf("expected a list of tools, got %s", describeNode(root))})
+		return nil, invalid
+	}
+
+	var validated []validatedTool
+	names := map[string]int{}
+	for i, item := range root.Content {
+		item = resolveAlias(item)
+		tool := fmt.Sprintf("tool #%d", i+1)
+		if _, name := mappingField(item, "name"); name != nil && name.Value != "" {
+			tool = fmt.Sprintf("tool %q", name.Value)
+		}
+		var errs []CustomToolError
+		addError := func(node *yamlv3.Node, field, message string) {
+			errs = append(errs, CustomToolError{Line: node.Line, Tool: tool, Field: field, Message: message})
+		}
+		validateNode(schema, schema.Items, item, "", addError)
+		if len(errs) > 0 {
+			invalid.Errors = append(invalid.Errors, errs...)
+			continue
+		}
+		// Decoded as the configurations always were, e.g. with the same
+		// conversion of unquoted scalars.
+		var config CustomToolConfig
+		b, err := yamlv3.Marshal(item)
+		if err == nil {
+			err = yaml.Unmarshal(b, &config)
+		}
+		if err != nil {
+			addError(item, "", fmt.Sprintf("decoding the tool: %v", err))
+		} else {
+			checkCustomTool(config, item, names, addError)
+		}
+		invalid.Errors = append(invalid.Errors, errs...)
+		if len(errs) == 0 {
+			validated = append(validated, validatedTool{config: config, line: item.Line})
+		}
+	}
+	if len(invalid.Errors) > 0 {
+		return nil, invalid
+	}
+	return validated, nil
+}
+
+// checkCustomTool checks what the schema can't describe in a tool. names are
+// the lines of the tools of the file checked so far, by name.
+func checkCustomTool(config CustomToolConfig, item *yamlv3.Node, names map[string]int, addError func(node *yamlv3.Node, field, message string)) {
+	nameKey, _ := mappingField(item, "name")
+	if config.Name == "" {
+		addError(item, "name", "required field is empty")
+		return
+	}
+	if line, ok := names[config.Name]; ok {
+		addError(nameKey, "name", fmt.Sprintf("the name is already used by the tool at line %d", line))
+	} else if source, ok := customToolSources[config.Name]; ok {
+		addError(nameKey, "name", "the name is already used by the custom tool at "+source)
+	} else if _, ok := allTools.tools[config.Name]; ok {
+		addError(nameKey, "name", "the name is already used by a built-in tool, disable it with --disable-tools to replace it")
+	}
+	names[config.Name] = item.Line
+
+	checkDuration := func(node *yamlv3.Node, field, value string) {
+		if value == "" {
+			return
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			addError(node, field, fmt.Sprintf("invalid duration %q, e.g. 30s or 5m", value))
+		}
+	}
+	timeoutKey, _ := mappingField(item, "timeout")
+	httpKey, httpNode := mappingField(item, "http")
+	parametersKey, _ := mappingField(item, "parameters")
+
+	if config.Type != customToolTypeHTTP {
+		if config.Command == "" {
+			addError(item, "command", "required field is missing for command tools")
+		}
+		checkDuration(timeoutKey, "timeout", config.Timeout)
+		if httpKey != nil {
+			addError(httpKey, "http", `only used by tools of type "http"`)
+		}
+		if parametersKey != nil {
+			addError(parametersKey, "parameters", `only used by tools of type "http", command tools are passed a command`)
+		}
+		return
+	}
+
+	if config.HTTP == nil {
+		addError(item, "http", `required field is missing for tools of type "http"`)
+		return
+	}
+	if commandKey, _ := mappingField(item, "command"); commandKey != nil {
+		addError(commandKey, "command", `not used by tools of type "http"`)
+	}
+	if timeoutKey != nil {
+		addError(timeoutKey, "timeout", "not used by tools of type \"http\", set http.timeout")
+	}
+	var declared []string
+	for _, p := range config.Parameters {
+		declared = append(declared, p.Name)
+	}
+	for _, field := range []struct{ name, value string }{{"url", config.HTTP.URL}, {"body", config.HTTP.Body}} {
+		key, _ := mappingField(httpNode, field.name)
+		if key == nil {
+			continue
+		}
+		tmpl, err := template.New(field.name).Funcs(httpToolTemplateFuncs).Parse(field.value)
+		if err != nil {
+			addError(key, "http."+field.name, fmt.Sprintf("invalid template: %v", err))
+			continue
+		}
+		for _, variable := range templateVariables(tmpl.Tree.Root) {
+			if !slices.Contains(declared, variable) {
+				addError(key, "http."+field.name, fmt.Sprintf("{{ .%s }} is not a declared parameter, add it to parameters", variable))
+			}
+		}
+	}
+	if key, _ := mappingField(httpNode, "response_filter"); key != nil {
+		if _, err := gojq.Parse(config.HTTP.ResponseFilter); err != nil {
+			addError(key, "http.response_filter", fmt.Sprintf("invalid jq expression: %v", err))
+		}
+	}
+	key, _ := mappingField(httpNode, "timeout")
+	checkDuration(key, "http.timeout", config.HTTP.Timeout)
+}
+
+// templateVariables returns the top-level variables a template uses, e.g.
+// host for {{ .host | urlquery }}. The variables used in range and with
+// blocks, where the dot is not the parameters, are ignored.
+func templateVariables(node parse.Node) []string {
+	var variables []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if !slices.Contains(variables, n.Ident[0]) {
+				variables = append(variables, n.Ident[0])
+			}
+		}
+	}
+	walk(node)
+	return variables
+}
+
+// validateNode checks node against schema, calling addError for every problem found.
+func validateNode(root, schema *customToolsSchema, node *yamlv3.Node, path string, addError func(node *yamlv3.Node, field, message string)) {
+	if schema == nil {
+		return
+	}
+	if schema.Ref != "" {
+		validateNode(root, root.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")], node, path, addError)
+		return
+	}
+	node = resolveAlias(node)
+	if node.Tag == "!!null" {
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		if node.Kind != yamlv3.MappingNode {
+			addError(node, path, fmt.Sprintf("expected an object, got %s", describeNode(node)))
+			return
+		}
+		for _, field := range schema.Required {
+			if key, _ := mappingField(node, field); key == nil {
+				addError(node, joinFieldPath(path, field), "required field is missing")
+			}
+		}
+		additional := schema.additionalProperties()
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field := joinFieldPath(path, key.Value)
+			if fieldSchema, ok := schema.Properties[key.Value]; ok {
+				validateNode(root, fieldSchema, value, field, addError)
+			} else if additional != nil {
+				validateNode(root, additional, value, field, addError)
+			} else if len(schema.Properties) > 0 {
+				addError(key, field, fmt.Sprintf("unknown field, expected one of %s", strings.Join(schema.propertyNames(), ", ")))
+			}
+		}
+	case "array":
+		if node.Kind != yamlv3.SequenceNode {
+			addError(node, path, fmt.Sprintf("expected a list, got %s", describeNode(node)))
+			return
+		}
+		for i, item := range node.Content {
+			validateNode(root, schema.Items, item, fmt.Sprintf("%s[%d]", path, i), addError)
+		}
+	case "string":
+		if node.Kind != yamlv3.ScalarNode {
+			addError(node, path, fmt.Sprintf("expected a string, got %s", describeNode(node)))
+			return
+		}
+		if len(schema.Enum) == 0 {
+			if schema.Pattern != "" && !regexp.MustCompile(schema.Pattern).MatchString(node.Value) {
+				addError(node, path, fmt.Sprintf("invalid value %q, must match %s", node.Value, schema.Pattern))
+			}
+			return
+		}
+		if isUnquotedBoolean(node) {
+			addError(node, path, fmt.Sprintf("unquoted %s is read as a boolean, quote it: %q", node.Value, node.Value))
+		} else if !slices.Contains(schema.Enum, node.Value) {
+			addError(node, path, fmt.Sprintf("unsupported value %q, must be one of %s", node.Value, strings.Join(schema.Enum, ", ")))
+		}
+	case "boolean":
+		if node.Kind != yamlv3.ScalarNode || node.Tag != "!!bool" && !isUnquotedBoolean(node) {
+			addError(node, path, fmt.Sprintf("expected a boolean, got %s", describeNode(node)))
+		}
+	}
+}
+
+// additionalProperties returns the schema of the properties not listed, or nil
+// if they are not allowed.
+func (s *customToolsSchema) additionalProperties() *customToolsSchema {
+	var additional customToolsSchema
+	if len(s.AdditionalProperties) == 0 || json.Unmarshal(s.AdditionalProperties, &additional) != nil {
+		// false, or not set: the schemas list all the properties.
+		return nil
+	}
+	return &additional
+}
+
+func (s *customToolsSchema) propertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mappingField returns the key and value nodes of a field of a mapping, or nil
+// if the field is not set.
+func mappingField(node *yamlv3.Node, name string) (key, value *yamlv3.Node) {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i], resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil, nil
+}
+
+func resolveAlias(node *yamlv3.Node) *yamlv3.Node {
+	for node.Kind == yamlv3.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func isUnquotedBoolean(node *yamlv3.Node) bool {
+	return node.Kind == yamlv3.ScalarNode && node.Style&(yamlv3.SingleQuotedStyle|yamlv3.DoubleQuotedStyle) == 0 &&
+		slices.Contains(yaml11Booleans, strings.ToLower(node.Value))
+}
+
+func describeNode(node *yamlv3.Node) string {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		return "an object"
+	case yamlv3.SequenceNode:
+		return "a list"
+	}
+	return fmt.Sprintf("%q", node.Value)
+}
+
+var yamlErrorLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorLine returns the line of a YAML syntax error, or 1 if it has none.
+func yamlErrorLine(err error) int {
+	var line int
+	if m := yamlErrorLinePattern.FindStringSubmatch(err.Error()); m != nil {
+		fmt.Sscan(m[1], &line)
+	}
+	return max(line, 1)
+}
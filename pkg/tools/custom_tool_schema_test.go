@@ -0,0 +1,153 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCustomTools(t *testing.T) {
+	config := `- name: gcloud
+  description: Manages Google Cloud resources.
+  command: gcloud
+  modifies_resource: no
+- name: bash
+  command: bash
+- name: gcloud
+  command: gcloud
+  comand_desc: typo
+- name: cmdb
+  type: http
+  http:
+    url: "https://cmdb.example.com/hosts/{{ .host | urlquery }}?env={{ .env }}"
+    timeout: soon
+  parameters:
+  - name: host
+    type: text
+- name: tickets
+  type: http
+  modifies_resource: maybe
+  is_interactive: sometimes
+`
+	_, err := ValidateCustomTools("tools.yaml", []byte(config))
+	var invalid *InvalidCustomToolsError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected an InvalidCustomToolsError, got %v", err)
+	}
+	expected := []string{
+		`tools.yaml:4: tool "gcloud": modifies_resource: unquoted no is read as a boolean, quote it: "no"`,
+		`tools.yaml:5: tool "bash": name: the name is already used by a built-in tool, disable it with --disable-tools to replace it`,
+		`tools.yaml:9: tool "gcloud": comand_desc: unknown field, expected one of command, command_desc, description, http, is_interactive, modifies_resource, name, output_schema, parameters, timeout, type`,
+		`tools.yaml:17: tool "cmdb": parameters[0].type: unsupported value "text", must be one of string, integer, number, boolean`,
+		`tools.yaml:20: tool "tickets": modifies_resource: unsupported value "maybe", must be one of yes, no, unknown`,
+		`tools.yaml:21: tool "tickets": is_interactive: expected a boolean, got "sometimes"`,
+	}
+	if got := strings.Split(err.Error(), "\n - ")[1:]; strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected errors:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// The problems the schema can't describe are found once it is followed.
+	config = `- name: gcloud
+  command: gcloud
+  modifies_resource: "no"
+- name: gcloud
+  command: gcloud
+  timeout: soon
+- name: cmdb
+  type: http
+  http:
+    url: "https://cmdb.example.com/hosts/{{ .host | urlquery }}?env={{ .env }}"
+    body: '{{ range .tags }}{{ .name }}{{ end }}'
+    response_filter: ".items[] |"
+  parameters:
+  - name: host
+  - name: tags
+`
+	_, err = ValidateCustomTools("tools.yaml", []byte(config))
+	expected = []string{
+		`tools.yaml:4: tool "gcloud": name: the name is already used by the tool at line 1`,
+		`tools.yaml:6: tool "gcloud": timeout: invalid duration "soon", e.g. 30s or 5m`,
+		`tools.yaml:10: tool "cmdb": http.url: {{ .env }} is not a declared parameter, add it to parameters`,
+		`tools.yaml:12: tool "cmdb": http.response_filter: invalid jq expression: unexpected EOF`,
+	}
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if got := strings.Split(err.Error(), "\n - ")[1:]; strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected errors:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	configs, err := ValidateCustomTools("tools.yaml", []byte("# No tools yet.\n"))
+	if err != nil || len(configs) != 0 {
+		t.Errorf("ValidateCustomTools() of an empty file = %v, %v", configs, err)
+	}
+	if _, err := ValidateCustomTools("tools.yaml", []byte("- name: [gcloud\n")); err == nil || !strings.Contains(err.Error(), "tools.yaml:1: invalid YAML") {
+		t.Errorf("expected a YAML syntax error, got %v", err)
+	}
+}
+
+func TestLoadAndRegisterCustomTools(t *testing.T) {
+	dir := t.TempDir()
+	valid := `- name: test_cmdb
+  type: http
+  http:
+    url: "https://cmdb.example.com/hosts/{{ .host | urlquery }}"
+  parameters:
+  - name: host
+`
+	invalid := `- name: test_kustomize
+  command: kustomize
+- name: test_kubectl_neat
+  command: kubectl neat
+  modifies_resource: maybe
+`
+	for name, content := range map[string]string{"cmdb.yaml": valid, "kustomize.yaml": invalid} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, name := range []string{"test_cmdb", "test_kustomize", "test_kubectl_neat"} {
+			allTools.UnregisterTool(name)
+			delete(customToolSources, name)
+		}
+	})
+
+	err := LoadAndRegisterCustomTools(dir)
+	var invalidErr *InvalidCustomToolsError
+	if !errors.As(err, &invalidErr) || invalidErr.Path != filepath.Join(dir, "kustomize.yaml") {
+		t.Fatalf("expected the invalid file to be reported, got %v", err)
+	}
+	if allTools.Lookup("test_cmdb") == nil {
+		t.Errorf("the tools of the valid file were not registered")
+	}
+	if allTools.Lookup("test_kustomize") != nil || allTools.Lookup("test_kubectl_neat") != nil {
+		t.Errorf("tools of the invalid file were registered")
+	}
+
+	// Tools can't be defined again, e.g. in another file.
+	other := filepath.Join(t.TempDir(), "tools.yaml")
+	if err := os.WriteFile(other, []byte(valid), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = LoadAndRegisterCustomTools(other)
+	if err == nil || !strings.Contains(err.Error(), "the name is already used by the custom tool at "+filepath.Join(dir, "cmdb.yaml")+":1") {
+		t.Errorf("expected the name to be reported as used, got %v", err)
+	}
+}
@@ -15,7 +15,12 @@
 package tools
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCustomTool_AddCommandPrefix(t *testing.T) {
@@ -111,3 +116,58 @@ func TestCustomTool_AddCommandPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveCustomToolConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tools.yaml")
+	existing := "# Tools of the platform team.\n- name: kustomize\n  description: Renders kustomizations.\n  command: kustomize"
+	if err := os.WriteFile(path, []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := CustomToolConfig{Name: "gcloud", Description: "Manages Google Cloud resources.", Command: "gcloud", ModifiesResource: "no", Timeout: "1m"}
+	written, err := SaveCustomToolConfig(path, config)
+	if err != nil || written != path {
+		t.Fatalf("SaveCustomToolConfig() = %q, %v", written, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), existing+"\n") {
+		t.Errorf("the existing content was not kept:\n%s", data)
+	}
+	parsed, err := ParseCustomTools(data)
+	if err != nil || len(parsed) != 2 {
+		t.Fatalf("ParseCustomTools() = %v, %v", parsed, err)
+	}
+	if got := parsed[1].CheckModifiesResource(nil); got != "no" {
+		t.Errorf("CheckModifiesResource() = %q, expected no", got)
+	}
+
+	if _, err := SaveCustomToolConfig(path, config); err == nil {
+		t.Errorf("expected an error saving a tool twice")
+	}
+	if written, err := SaveCustomToolConfig(dir, config); err != nil || written != filepath.Join(dir, "gcloud.yaml") {
+		t.Errorf("SaveCustomToolConfig() to a directory = %q, %v", written, err)
+	}
+	config.Name, config.Timeout = "invalid", "soon"
+	if _, err := SaveCustomToolConfig(filepath.Join(dir, "other.yaml"), config); err == nil {
+		t.Errorf("expected an error saving a tool with an invalid timeout")
+	}
+}
+
+func TestCustomToolTimeout(t *testing.T) {
+	tool, err := NewCustomTool(CustomToolConfig{Name: "sleep", Command: "sleep", Timeout: "100ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), WorkDirKey, t.TempDir())
+	start := time.Now()
+	if _, err := tool.Run(ctx, map[string]any{"command": "sleep 10"}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the command was not stopped after its timeout, ran for %v", elapsed)
+	}
+}
@@ -0,0 +1,461 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	RegisterTool(&DiffResources{})
+}
+
+const (
+	diffResourcesFetchTimeout = 30 * time.Second
+	// maxDiffManifestBytes caps the size of the manifests read from files and URLs.
+	maxDiffManifestBytes = 1024 * 1024
+)
+
+// diffIgnoredMetadata are the metadata fields set by the API server, that
+// differ between any two objects.
+var diffIgnoredMetadata = []string{"managedFields", "resourceVersion", "uid", "creationTimestamp", "generation", "selfLink", "namespace"}
+
+// diffIgnoredAnnotations are annotations maintained by controllers and kubectl.
+var diffIgnoredAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+}
+
+// DiffResources compares two Kubernetes objects, from the cluster or from
+// manifests, ignoring the fields that are noise for the comparison.
+type DiffResources struct{}
+
+func (t *DiffResources) Name() string {
+	return "diff_resources"
+}
+
+func (t *DiffResources) Description() string {
+	return `Compares two Kubernetes objects and returns the fields that differ, ignoring status, managedFields, resourceVersion and other fields set by the API server.
+Each side is an object in the cluster, possibly in another namespace or kubeconfig context, or a manifest file or URL.
+Use this tool to answer questions like "why does staging differ from prod for this deployment", or to compare a live object with its manifest.`
+}
+
+func (t *DiffResources) FunctionDefinition() *gollm.FunctionDefinition {
+	sideProperties := func(side string) map[string]*gollm.Schema {
+		return map[string]*gollm.Schema{
+			side: {
+				Type:        gollm.TypeString,
+				Description: fmt.Sprintf(`The %s object: a reference as kind/name (e.g. "deployment/web"), a manifest file path relative to the working directory, or an http(s) URL of a manifest.`, side),
+			},
+			side + "_namespace": {
+				Type:        gollm.TypeString,
+				Description: fmt.Sprintf(`The namespace of the %s object, if it is a reference. Defaults to the namespace of the context.`, side),
+			},
+			side + "_context": {
+				Type:        gollm.TypeString,
+				Description: fmt.Sprintf(`The kubeconfig context of the %s object, if it is a reference. Defaults to the current context.`, side),
+			},
+		}
+	}
+	properties := sideProperties("left")
+	for name, schema := range sideProperties("right") {
+		properties[name] = schema
+	}
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type:       gollm.TypeObject,
+			Properties: properties,
+			Required:   []string{"left", "right"},
+		},
+	}
+}
+
+// ResourceDiff is the result of the diff_resources tool.
+type ResourceDiff struct {
+	Left        string               `json:"left"`
+	Right       string               `json:"right"`
+	Identical   bool                 `json:"identical"`
+	Differences []ResourceDifference `json:"differences,omitempty"`
+}
+
+// ResourceDifference is a field that differs between the two objects.
+type ResourceDifference struct {
+	// Path of the field, e.g. spec.template.spec.containers[name=app].image.
+	// Elements of lists of named objects are identified by their name.
+	Path string `json:"path"`
+	// Change is "added" (only in right), "removed" (only in left) or "changed".
+	Change string `json:"change"`
+	Left   any    `json:"left,omitempty"`
+	Right  any    `json:"right,omitempty"`
+}
+
+func (t *DiffResources) Run(ctx context.Context, args map[string]any) (any, error) {
+	left := diffSide{}
+	left.ref, _ = args["left"].(string)
+	left.namespace, _ = args["left_namespace"].(string)
+	left.context, _ = args["left_context"].(string)
+	right := diffSide{}
+	right.ref, _ = args["right"].(string)
+	right.namespace, _ = args["right_namespace"].(string)
+	right.context, _ = args["right_context"].(string)
+	if left.ref == "" || right.ref == "" {
+		return &ExecResult{Error: "both left and right must be provided"}, nil
+	}
+
+	// Objects are loaded first, manifests with several documents are then
+	// narrowed down to the object of the other side.
+	leftObjs, err := left.load(ctx)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+	rightObjs, err := right.load(ctx)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+	leftObj, rightObj, err := pairObjects(leftObjs, rightObjs)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+
+	return DiffObjects(left.String(), right.String(), leftObj, rightObj), nil
+}
+
+func (t *DiffResources) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *DiffResources) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// DiffObjects returns the differences between two objects, after removing the
+// fields that are noise for the comparison.
+func DiffObjects(leftName, rightName string, left, right map[string]any) *ResourceDiff {
+	diff := &ResourceDiff{Left: leftName, Right: rightName}
+	diffValues("", normalizeObject(left), normalizeObject(right), &diff.Differences)
+	diff.Identical = len(diff.Differences) == 0
+	return diff
+}
+
+type diffSide struct {
+	ref       string
+	namespace string
+	context   string
+}
+
+func (s diffSide) String() string {
+	name := s.ref
+	if s.namespace != "" {
+		name += " -n " + s.namespace
+	}
+	if s.context != "" {
+		name += " --context " + s.context
+	}
+	return name
+}
+
+func (s diffSide) isURL() bool {
+	return strings.HasPrefix(s.ref, "http://") || strings.HasPrefix(s.ref, "https://")
+}
+
+func (s diffSide) isFile() bool {
+	switch strings.ToLower(filepath.Ext(s.ref)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// load returns the objects of the side: the object in the cluster, or the
+// documents of the manifest.
+func (s diffSide) load(ctx context.Context) ([]map[string]any, error) {
+	switch {
+	case s.isURL():
+		b, err := fetchManifest(ctx, s.ref)
+		if err != nil {
+			return nil, err
+		}
+		return parseManifestObjects(s.ref, b)
+	case s.isFile():
+		filename := s.ref
+		if !filepath.IsAbs(filename) {
+			workDir, _ := ctx.Value(WorkDirKey).(string)
+			filename = filepath.Join(workDir, filename)
+		}
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", s.ref, err)
+		}
+		return parseManifestObjects(s.ref, b)
+	}
+
+	args := append([]string{"get"}, strings.Fields(s.ref)...)
+	args = append(args, "-o", "json")
+	if s.namespace != "" {
+		args = append(args, "--namespace", s.namespace)
+	}
+	if s.context != "" {
+		args = append(args, "--context", s.context)
+	}
+	out, err := kubectlOutput(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %w", s, err)
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", s, err)
+	}
+	if obj["kind"] == "List" {
+		return nil, fmt.Errorf("%s is not a single object, use a kind/name reference", s)
+	}
+	return []map[string]any{obj}, nil
+}
+
+func fetchManifest(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, diffResourcesFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxDiffManifestBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	if len(b) > maxDiffManifestBytes {
+		return nil, fmt.Errorf("manifest %s is larger than %d bytes", url, maxDiffManifestBytes)
+	}
+	return b, nil
+}
+
+func parseManifestObjects(name string, manifest []byte) ([]map[string]any, error) {
+	var objs []map[string]any
+	for _, doc := range yamlDocumentSeparator.Split(string(manifest), -1) {
+		if strings.TrimSpace(stripYAMLComments(doc)) == "" {
+			continue
+		}
+		var obj map[string]any
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		objs = append(objs, obj)
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("manifest %s is empty", name)
+	}
+	return objs, nil
+}
+
+// pairObjects picks the objects to compare. When a side has several objects,
+// the one with the kind and name of the object of the other side is used.
+func pairObjects(left, right []map[string]any) (map[string]any, map[string]any, error) {
+	if len(left) > 1 && len(right) > 1 {
+		return nil, nil, fmt.Errorf("both manifests have several objects, compare them one at a time")
+	}
+	if len(left) > 1 {
+		l, err := findObject(left, right[0])
+		return l, right[0], err
+	}
+	if len(right) > 1 {
+		r, err := findObject(right, left[0])
+		return left[0], r, err
+	}
+	return left[0], right[0], nil
+}
+
+func findObject(objs []map[string]any, like map[string]any) (map[string]any, error) {
+	kind, name := objectKindName(like)
+	for _, obj := range objs {
+		if k, n := objectKindName(obj); k == kind && n == name {
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("the manifest has no %s named %q", kind, name)
+}
+
+func objectKindName(obj map[string]any) (string, string) {
+	kind, _ := obj["kind"].(string)
+	metadata, _ := obj["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	return kind, name
+}
+
+// normalizeObject returns a copy of obj without status and the fields set by
+// the API server and controllers.
+func normalizeObject(obj map[string]any) map[string]any {
+	out := make(map[string]any, len(obj))
+	for k, v := range obj {
+		out[k] = v
+	}
+	delete(out, "status")
+
+	metadata, ok := out["metadata"].(map[string]any)
+	if !ok {
+		return out
+	}
+	m := make(map[string]any, len(metadata))
+	for k, v := range metadata {
+		m[k] = v
+	}
+	for _, field := range diffIgnoredMetadata {
+		delete(m, field)
+	}
+	if annotations, ok := m["annotations"].(map[string]any); ok {
+		a := make(map[string]any, len(annotations))
+		for k, v := range annotations {
+			a[k] = v
+		}
+		for _, annotation := range diffIgnoredAnnotations {
+			delete(a, annotation)
+		}
+		if len(a) == 0 {
+			delete(m, "annotations")
+		} else {
+			m["annotations"] = a
+		}
+	}
+	out["metadata"] = m
+	return out
+}
+
+// diffValues appends the differences between left and right, found at path, to diffs.
+func diffValues(path string, left, right any, diffs *[]ResourceDifference) {
+	switch l := left.(type) {
+	case map[string]any:
+		if r, ok := right.(map[string]any); ok {
+			keys := map[string]bool{}
+			for k := range l {
+				keys[k] = true
+			}
+			for k := range r {
+				keys[k] = true
+			}
+			sorted := make([]string, 0, len(keys))
+			for k := range keys {
+				sorted = append(sorted, k)
+			}
+			sort.Strings(sorted)
+			for _, k := range sorted {
+				lv, lok := l[k]
+				rv, rok := r[k]
+				childPath := joinDiffPath(path, k)
+				switch {
+				case !lok:
+					*diffs = append(*diffs, ResourceDifference{Path: childPath, Change: "added", Right: rv})
+				case !rok:
+					*diffs = append(*diffs, ResourceDifference{Path: childPath, Change: "removed", Left: lv})
+				default:
+					diffValues(childPath, lv, rv, diffs)
+				}
+			}
+			return
+		}
+	case []any:
+		if r, ok := right.([]any); ok {
+			diffLists(path, l, r, diffs)
+			return
+		}
+	}
+	if !reflect.DeepEqual(left, right) {
+		*diffs = append(*diffs, ResourceDifference{Path: path, Change: "changed", Left: left, Right: right})
+	}
+}
+
+// diffLists compares lists of named objects, e.g. containers or env
+// variables, by name, and other lists by index.
+func diffLists(path string, left, right []any, diffs *[]ResourceDifference) {
+	leftNames, lok := listNames(left)
+	rightNames, rok := listNames(right)
+	if lok && rok {
+		for i, name := range leftNames {
+			childPath := fmt.Sprintf("%s[name=%s]", path, name)
+			j := slices.Index(rightNames, name)
+			if j < 0 {
+				*diffs = append(*diffs, ResourceDifference{Path: childPath, Change: "removed", Left: left[i]})
+				continue
+			}
+			diffValues(childPath, left[i], right[j], diffs)
+		}
+		for j, name := range rightNames {
+			if slices.Index(leftNames, name) < 0 {
+				*diffs = append(*diffs, ResourceDifference{Path: fmt.Sprintf("%s[name=%s]", path, name), Change: "added", Right: right[j]})
+			}
+		}
+		return
+	}
+
+	for i := 0; i < len(left) || i < len(right); i++ {
+		childPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(right):
+			*diffs = append(*diffs, ResourceDifference{Path: childPath, Change: "removed", Left: left[i]})
+		case i >= len(left):
+			*diffs = append(*diffs, ResourceDifference{Path: childPath, Change: "added", Right: right[i]})
+		default:
+			diffValues(childPath, left[i], right[i], diffs)
+		}
+	}
+}
+
+// listNames returns the names of the elements of a list of named objects.
+func listNames(list []any) ([]string, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	names := make([]string, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok || slices.Index(names, name) >= 0 {
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
+
+func joinDiffPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+const diffStagingDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: staging
+  uid: 1b4c
+  resourceVersion: "1234"
+  generation: 7
+  creationTimestamp: "2025-06-01T10:00:00Z"
+  annotations:
+    deployment.kubernetes.io/revision: "7"
+  managedFields:
+  - manager: kubectl
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: web:v2
+        env:
+        - name: LOG_LEVEL
+          value: debug
+      - name: proxy
+        image: envoy:1.30
+status:
+  readyReplicas: 1
+`
+
+const diffProdDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  uid: 9f3a
+  resourceVersion: "98765"
+  generation: 42
+  creationTimestamp: "2025-01-01T10:00:00Z"
+  annotations:
+    deployment.kubernetes.io/revision: "42"
+    team: shop
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: proxy
+        image: envoy:1.30
+      - name: app
+        image: web:v1
+        env:
+        - name: LOG_LEVEL
+          value: info
+        - name: CACHE
+          value: "on"
+status:
+  readyReplicas: 3
+`
+
+func diffTestObject(t *testing.T, manifest string) map[string]any {
+	t.Helper()
+	var obj map[string]any
+	if err := yaml.Unmarshal([]byte(manifest), &obj); err != nil {
+		t.Fatalf("parsing manifest: %v", err)
+	}
+	return obj
+}
+
+func TestDiffObjects(t *testing.T) {
+	staging := diffTestObject(t, diffStagingDeployment)
+	prod := diffTestObject(t, diffProdDeployment)
+
+	got := DiffObjects("staging", "prod", staging, prod)
+	want := []ResourceDifference{
+		{Path: "metadata.annotations", Change: "added", Right: map[string]any{"team": "shop"}},
+		{Path: "spec.replicas", Change: "changed", Left: float64(1), Right: float64(3)},
+		{Path: "spec.template.spec.containers[name=app].env[name=LOG_LEVEL].value", Change: "changed", Left: "debug", Right: "info"},
+		{Path: "spec.template.spec.containers[name=app].env[name=CACHE]", Change: "added", Right: map[string]any{"name": "CACHE", "value": "on"}},
+		{Path: "spec.template.spec.containers[name=app].image", Change: "changed", Left: "web:v2", Right: "web:v1"},
+	}
+	if got.Identical {
+		t.Errorf("DiffObjects() identical, want differences")
+	}
+	if !reflect.DeepEqual(got.Differences, want) {
+		t.Errorf("DiffObjects() differences =\n%+v\nwant\n%+v", got.Differences, want)
+	}
+
+	if got := DiffObjects("staging", "staging", staging, diffTestObject(t, diffStagingDeployment)); !got.Identical {
+		t.Errorf("DiffObjects() of the same object = %+v, want identical", got.Differences)
+	}
+	// Status and server-set fields are ignored.
+	if got := DiffObjects("a", "b", staging, map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web", "namespace": "other"},
+		"spec":       staging["spec"],
+	}); !got.Identical {
+		t.Errorf("DiffObjects() = %+v, want identical when only server-set fields differ", got.Differences)
+	}
+}
+
+func TestDiffResourcesManifests(t *testing.T) {
+	workDir := t.TempDir()
+	// A manifest with several objects is narrowed down to the object of the other side.
+	manifest := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\n" + diffStagingDeployment
+	if err := os.WriteFile(filepath.Join(workDir, "staging.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(diffProdDeployment))
+	}))
+	defer server.Close()
+
+	ctx := context.WithValue(context.Background(), WorkDirKey, workDir)
+	result, err := (&DiffResources{}).Run(ctx, map[string]any{
+		"left":  "staging.yaml",
+		"right": server.URL + "/prod.yaml",
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	diff, ok := result.(*ResourceDiff)
+	if !ok {
+		t.Fatalf("Run() = %+v, want a ResourceDiff", result)
+	}
+	if len(diff.Differences) != 5 {
+		t.Errorf("Run() found %d differences, want 5: %+v", len(diff.Differences), diff.Differences)
+	}
+
+	result, err = (&DiffResources{}).Run(ctx, map[string]any{"left": "staging.yaml", "right": "missing.yaml"})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if r, ok := result.(*ExecResult); !ok || r.Error == "" {
+		t.Errorf("Run() with a missing file = %+v, want an error result", result)
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The envtest tests run the tools against a local API server, started from
+// the etcd, kube-apiserver and kubectl binaries found in $KUBEBUILDER_ASSETS,
+// the layout installed by setup-envtest (see dev/ci/presubmits/go-test-envtest.sh).
+// They are skipped when KUBEBUILDER_ASSETS is not set.
+
+const (
+	envtestAdminToken = "envtest-admin-token"
+	// envtestViewerToken authenticates a user without any RBAC permission.
+	envtestViewerToken = "envtest-viewer-token"
+)
+
+// envtestCluster is a local control plane.
+type envtestCluster struct {
+	// adminKubeconfig authenticates as a member of system:masters.
+	adminKubeconfig string
+	// viewerKubeconfig authenticates as a user without permissions.
+	viewerKubeconfig string
+}
+
+// startEnvtestCluster starts etcd and kube-apiserver, which are stopped at the end of the test.
+func startEnvtestCluster(t *testing.T) *envtestCluster {
+	t.Helper()
+	assets := os.Getenv("KUBEBUILDER_ASSETS")
+	if assets == "" {
+		t.Skip("KUBEBUILDER_ASSETS not set, skipping envtest tests")
+	}
+	// Tools run kubectl from PATH.
+	t.Setenv("PATH", assets+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	etcdPort, etcdPeerPort, apiPort := freePort(t), freePort(t), freePort(t)
+	etcdURL := fmt.Sprintf("http://127.0.0.1:%d", etcdPort)
+	startProcess(t, filepath.Join(assets, "etcd"),
+		"--data-dir="+filepath.Join(dir, "etcd"),
+		"--listen-client-urls="+etcdURL,
+		"--advertise-client-urls="+etcdURL,
+		fmt.Sprintf("--listen-peer-urls=http://127.0.0.1:%d", etcdPeerPort),
+		"--unsafe-no-fsync=true",
+	)
+
+	saKey := filepath.Join(dir, "sa.key")
+	writeRSAKey(t, saKey)
+	tokens := filepath.Join(dir, "tokens.csv")
+	tokensContent := fmt.Sprintf("%s,admin,admin,system:masters\n%s,viewer,viewer\n", envtestAdminToken, envtestViewerToken)
+	if err := os.WriteFile(tokens, []byte(tokensContent), 0o600); err != nil {
+		t.Fatalf("writing token file: %v", err)
+	}
+	startProcess(t, filepath.Join(assets, "kube-apiserver"),
+		"--etcd-servers="+etcdURL,
+		"--bind-address=127.0.0.1",
+		fmt.Sprintf("--secure-port=%d", apiPort),
+		"--cert-dir="+filepath.Join(dir, "certs"),
+		"--token-auth-file="+tokens,
+		"--authorization-mode=RBAC",
+		"--service-cluster-ip-range=10.0.0.0/24",
+		"--service-account-issuer=https://envtest.local",
+		"--service-account-key-file="+saKey,
+		"--service-account-signing-key-file="+saKey,
+		"--disable-admission-plugins=ServiceAccount",
+	)
+
+	server := fmt.Sprintf("https://127.0.0.1:%d", apiPort)
+	waitForAPIServer(t, server)
+	return &envtestCluster{
+		adminKubeconfig:  writeKubeconfig(t, dir, "admin", server, envtestAdminToken),
+		viewerKubeconfig: writeKubeconfig(t, dir, "viewer", server, envtestViewerToken),
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func startProcess(t *testing.T, path string, args ...string) {
+	t.Helper()
+	cmd := exec.Command(path, args...)
+	log, err := os.Create(filepath.Join(t.TempDir(), filepath.Base(path)+".log"))
+	if err != nil {
+		t.Fatalf("creating log file: %v", err)
+	}
+	cmd.Stdout, cmd.Stderr = log, log
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting %s: %v", path, err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		log.Close()
+		if t.Failed() {
+			out, _ := os.ReadFile(log.Name())
+			t.Logf("%s output:\n%s", filepath.Base(path), out)
+		}
+	})
+}
+
+func writeRSAKey(t *testing.T, path string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating service account key: %v", err)
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("writing service account key: %v", err)
+	}
+}
+
+func waitForAPIServer(t *testing.T, server string) {
+	t.Helper()
+	// The serving certificate is self-signed by the API server.
+	client := &http.Client{
+		Timeout:   time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		req, _ := http.NewRequest(http.MethodGet, server+"/readyz", nil)
+		req.Header.Set("Authorization", "Bearer "+envtestAdminToken)
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	t.Fatalf("API server %s not ready after a minute", server)
+}
+
+func writeKubeconfig(t *testing.T, dir, user, server, token string) string {
+	t.Helper()
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: envtest
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+users:
+- name: %s
+  user:
+    token: %s
+contexts:
+- name: %s
+  context:
+    cluster: envtest
+    user: %s
+current-context: %s
+`, server, user, token, user, user, user)
+	path := filepath.Join(dir, user+".kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatalf("writing kubeconfig: %v", err)
+	}
+	return path
+}
+
+// runKubectlTool runs a command with the kubectl tool, as the agent does.
+func runKubectlTool(t *testing.T, kubeconfig, command string) *ExecResult {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, KubeconfigKey, kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, t.TempDir())
+
+	result, err := (&Kubectl{}).Run(ctx, map[string]any{"command": command})
+	if err != nil {
+		t.Fatalf("running %q: %v", command, err)
+	}
+	return result.(*ExecResult)
+}
+
+func TestEnvtestKubectl(t *testing.T) {
+	cluster := startEnvtestCluster(t)
+
+	for _, command := range []string{
+		"kubectl create namespace team-a",
+		"kubectl create configmap settings -n team-a --from-literal=mode=test",
+	} {
+		if result := runKubectlTool(t, cluster.adminKubeconfig, command); result.ExitCode != 0 {
+			t.Fatalf("setup command %q failed: %s", command, result)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		kubeconfig string
+		command    string
+		wantExit   bool
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "lists namespaces",
+			kubeconfig: cluster.adminKubeconfig,
+			command:    "kubectl get namespaces -o name",
+			wantStdout: "namespace/team-a",
+		},
+		{
+			name:       "namespace scoped get",
+			kubeconfig: cluster.adminKubeconfig,
+			command:    "kubectl get configmaps -n team-a -o name",
+			wantStdout: "configmap/settings",
+		},
+		{
+			name:       "other namespace is not visible",
+			kubeconfig: cluster.adminKubeconfig,
+			command:    "kubectl get configmap settings -n default",
+			wantExit:   true,
+			wantStderr: "NotFound",
+		},
+		{
+			name:       "RBAC denial is reported",
+			kubeconfig: cluster.viewerKubeconfig,
+			command:    "kubectl get configmaps -n team-a",
+			wantExit:   true,
+			wantStderr: "forbidden",
+		},
+		{
+			name:       "modifying command applies",
+			kubeconfig: cluster.adminKubeconfig,
+			command:    "kubectl label configmap settings -n team-a owner=agent && kubectl get configmap settings -n team-a -o jsonpath={.metadata.labels.owner}",
+			wantStdout: "agent",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runKubectlTool(t, tt.kubeconfig, tt.command)
+			if (result.ExitCode != 0) != tt.wantExit {
+				t.Fatalf("exit code = %d, want failure %v: %s", result.ExitCode, tt.wantExit, result)
+			}
+			if !strings.Contains(result.Stdout, tt.wantStdout) {
+				t.Errorf("stdout = %q, want it to contain %q", result.Stdout, tt.wantStdout)
+			}
+			if !strings.Contains(result.Stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", result.Stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestEnvtestDryRunKubectlCommand(t *testing.T) {
+	cluster := startEnvtestCluster(t)
+
+	opt := InvokeToolOptions{Kubeconfig: cluster.adminKubeconfig, WorkDir: t.TempDir()}
+	names, ok, err := DryRunKubectlCommand(context.Background(), "kubectl create namespace dry-run", opt)
+	if err != nil || !ok {
+		t.Fatalf("DryRunKubectlCommand() = %v, %v, %v", names, ok, err)
+	}
+	if len(names) != 1 || names[0] != "namespace/dry-run" {
+		t.Errorf("DryRunKubectlCommand() names = %v, want [namespace/dry-run]", names)
+	}
+	// A dry-run must not create anything.
+	if result := runKubectlTool(t, cluster.adminKubeconfig, "kubectl get namespace dry-run"); result.ExitCode == 0 {
+		t.Errorf("namespace created by dry-run: %s", result)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// permissionDeniedPatterns are in the output of commands denied by the cluster.
+var permissionDeniedPatterns = []string{
+	"(Forbidden)",
+	"is forbidden:",
+	"(Unauthorized)",
+	"You must be logged in to the server",
+	"permission denied",
+}
+
+// ErrorCategory returns the category of the failure of a tool call, from
+// its result and error, or "" if the call succeeded.
+func ErrorCategory(result any, err error) api.ErrorCategory {
+	if err != nil {
+		if category := api.ErrorCategoryOf(err); category != "" {
+			return category
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return api.ErrorCategoryToolTimeout
+		}
+	}
+
+	execResult, ok := result.(*ExecResult)
+	if !ok || execResult == nil {
+		return ""
+	}
+	if execResult.StreamType == "timeout" {
+		return api.ErrorCategoryToolTimeout
+	}
+	if execResult.ExitCode == 0 && execResult.Error == "" {
+		return ""
+	}
+	for _, pattern := range permissionDeniedPatterns {
+		if strings.Contains(execResult.Stderr, pattern) || strings.Contains(execResult.Error, pattern) {
+			return api.ErrorCategoryPermissionDenied
+		}
+	}
+	if execResult.ExitCode != 0 {
+		return api.ErrorCategoryToolNonZeroExit
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestErrorCategory(t *testing.T) {
+	tests := []struct {
+		name   string
+		result any
+		err    error
+		want   api.ErrorCategory
+	}{
+		{
+			name:   "success",
+			result: &ExecResult{Stdout: "pod/web-1"},
+		},
+		{
+			name:   "non-zero exit",
+			result: &ExecResult{ExitCode: 1, Stderr: `Error from server (NotFound): pods "web-2" not found`},
+			want:   api.ErrorCategoryToolNonZeroExit,
+		},
+		{
+			name:   "forbidden",
+			result: &ExecResult{ExitCode: 1, Stderr: `Error from server (Forbidden): pods is forbidden: User "dev" cannot list resource "pods"`},
+			want:   api.ErrorCategoryPermissionDenied,
+		},
+		{
+			name:   "bash timeout",
+			result: &ExecResult{Error: "Timeout reached after 7 seconds", StreamType: "timeout"},
+			want:   api.ErrorCategoryToolTimeout,
+		},
+		{
+			name: "deadline exceeded",
+			err:  fmt.Errorf("fetching: %w", context.DeadlineExceeded),
+			want: api.ErrorCategoryToolTimeout,
+		},
+		{
+			name:   "other result",
+			result: map[string]any{"error": "not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCategory(tt.result, tt.err); got != tt.want {
+				t.Errorf("ErrorCategory() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
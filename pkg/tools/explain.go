@@ -0,0 +1,296 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// kubectlVerbDescriptions describe what a kubectl verb does to its target.
+var kubectlVerbDescriptions = map[string]string{
+	"apply":       "Create or update",
+	"create":      "Create",
+	"replace":     "Replace",
+	"delete":      "Delete",
+	"patch":       "Patch",
+	"edit":        "Edit",
+	"scale":       "Scale",
+	"autoscale":   "Create an autoscaler for",
+	"expose":      "Create a service exposing",
+	"run":         "Start a new pod",
+	"exec":        "Run a command in",
+	"attach":      "Attach to",
+	"cp":          "Copy files to or from a container:",
+	"debug":       "Start a debugging container for",
+	"label":       "Change the labels of",
+	"annotate":    "Change the annotations of",
+	"taint":       "Change the taints of",
+	"drain":       "Evict all pods from",
+	"cordon":      "Mark as unschedulable:",
+	"uncordon":    "Mark as schedulable:",
+	"certificate": "Approve or deny the certificate signing request",
+}
+
+// kubectlSubVerbDescriptions describe verbs that take a sub-command.
+var kubectlSubVerbDescriptions = map[string]string{
+	"rollout restart": "Restart the pods of",
+	"rollout undo":    "Roll back",
+	"rollout pause":   "Pause the rollout of",
+	"rollout resume":  "Resume the rollout of",
+	"set image":       "Change the container images of",
+	"set env":         "Change the environment variables of",
+	"set resources":   "Change the resource requests and limits of",
+}
+
+// ExplainKubectlCommand returns a short plain-English description of what a
+// kubectl command does, e.g. "Delete pods matching app=web in namespace prod".
+func ExplainKubectlCommand(kc KubectlCommand) string {
+	action := kubectlSubVerbDescriptions[kc.Verb+" "+kc.SubVerb]
+	if action == "" {
+		action = kubectlVerbDescriptions[kc.Verb]
+	}
+	if action == "" {
+		action = "Run kubectl " + strings.TrimSpace(kc.Verb+" "+kc.SubVerb) + " on"
+	}
+
+	var target string
+	switch {
+	case kc.Filename == "-":
+		target = "the resources of the inline manifest"
+	case kc.Filename != "":
+		target = "the resources defined in " + kc.Filename
+	case kc.Resource != "":
+		target = kc.Resource
+		if kc.All {
+			target = "all " + target
+		}
+		if kc.Selector != "" {
+			target += " matching " + kc.Selector
+		}
+	default:
+		target = "resources"
+	}
+
+	var scope string
+	switch {
+	case kc.AllNamespaces:
+		scope = "in all namespaces"
+	case kc.Namespace != "":
+		scope = "in namespace " + kc.Namespace
+	default:
+		scope = "in the current namespace"
+	}
+
+	explanation := action + " " + target + " " + scope
+	if !kc.Modifies {
+		explanation += " (read-only)"
+	}
+	return explanation
+}
+
+// dryRunVerbs are the kubectl verbs that support --dry-run=server.
+var dryRunVerbs = map[string]bool{
+	"apply": true, "create": true, "replace": true, "delete": true,
+	"patch": true, "scale": true, "label": true, "annotate": true,
+	"set": true, "expose": true, "autoscale": true, "taint": true,
+}
+
+// DryRunKubectlCommand runs a modifying kubectl command with --dry-run=server
+// and returns the names of the resources it would affect.
+//
+// Only commands made of a single kubectl invocation (optionally fed by a heredoc
+// or by cat) are supported, so that nothing else gets executed; ok is false
+// for other commands.
+func DryRunKubectlCommand(ctx context.Context, command string, opt InvokeToolOptions) (names []string, ok bool, err error) {
+	out, ok, err := dryRunKubectl(ctx, command, "name", opt)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, true, nil
+}
+
+// DryRunKubectlObjects is like DryRunKubectlCommand, and returns the objects
+// as they would be after the command, or before it for deletions, with the
+// verb of the command.
+func DryRunKubectlObjects(ctx context.Context, command string, opt InvokeToolOptions) (objects []map[string]any, verb string, ok bool, err error) {
+	out, ok, err := dryRunKubectl(ctx, command, "json", opt)
+	if !ok || err != nil {
+		return nil, "", ok, err
+	}
+	objects, err = parseKubectlObjects(out)
+	if err != nil {
+		return nil, "", true, fmt.Errorf("parsing dry-run output: %w", err)
+	}
+	for _, kc := range ParseKubectlCommands(command) {
+		verb = kc.Verb
+	}
+	return objects, verb, true, nil
+}
+
+// parseKubectlObjects parses the JSON output of kubectl, a single object or
+// a list of objects.
+func parseKubectlObjects(out []byte) ([]map[string]any, error) {
+	var objects []map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		var obj map[string]any
+		if err := decoder.Decode(&obj); err != nil {
+			return nil, err
+		}
+		if items, ok := obj["items"].([]any); ok && obj["kind"] == "List" {
+			for _, item := range items {
+				if m, ok := item.(map[string]any); ok {
+					objects = append(objects, m)
+				}
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// dryRunKubectl runs a modifying kubectl command with --dry-run=server and
+// the output format, and returns its output.
+func dryRunKubectl(ctx context.Context, command, output string, opt InvokeToolOptions) ([]byte, bool, error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil || len(file.Stmts) != 1 {
+		return nil, false, nil
+	}
+
+	// The command is executed, so make sure it can't run anything but kubectl.
+	if !isLiteralCommand(file) {
+		return nil, false, nil
+	}
+	call := dryRunnableCall(file.Stmts[0])
+	if call == nil {
+		return nil, false, nil
+	}
+	args := callArgs(call)
+	kc := parseKubectlCommand(args[1:])
+	if !kc.Modifies || !dryRunVerbs[kc.Verb] {
+		return nil, false, nil
+	}
+	for _, arg := range args[1:] {
+		// Output flags conflict with -o, and arguments after -- are not for kubectl.
+		if arg == "--" || arg == "-o" || strings.HasPrefix(arg, "-o=") || strings.HasPrefix(arg, "--output") {
+			return nil, false, nil
+		}
+	}
+
+	for _, arg := range []string{"--dry-run=server", "-o", output} {
+		call.Args = append(call.Args, &syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{Value: arg}}})
+	}
+	var script bytes.Buffer
+	if err := syntax.NewPrinter().Print(&script, file); err != nil {
+		return nil, false, fmt.Errorf("printing dry-run command: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, lookupBashBin(), "-c", script.String())
+	cmd.Dir = opt.WorkDir
+	if cmd.Env, err = kubectlEnv(ctx, opt); err != nil {
+		return nil, false, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, true, fmt.Errorf("dry-run failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return out, true, nil
+}
+
+// kubectlEnv returns the environment of the kubectl commands run on behalf of
+// a tool call, with the kubeconfig of the session.
+func kubectlEnv(ctx context.Context, opt InvokeToolOptions) ([]string, error) {
+	env := commandEnv(context.WithValue(ctx, EnvKey, opt.Env))
+	if opt.Kubeconfig != "" {
+		kubeconfig, err := expandShellVar(opt.Kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, "KUBECONFIG="+kubeconfig)
+	}
+	return env, nil
+}
+
+// dryRunnableCall returns the kubectl call of a statement, if the statement is
+// a kubectl call, or cat piped into a kubectl call.
+func dryRunnableCall(stmt *syntax.Stmt) *syntax.CallExpr {
+	switch cmd := stmt.Cmd.(type) {
+	case *syntax.CallExpr:
+		if isKubectlCall(cmd) {
+			return cmd
+		}
+	case *syntax.BinaryCmd:
+		if cmd.Op != syntax.Pipe {
+			return nil
+		}
+		cat, ok := cmd.X.Cmd.(*syntax.CallExpr)
+		if !ok {
+			return nil
+		}
+		if args := callArgs(cat); len(args) == 0 || args[0] != "cat" || len(args) > 2 || (len(args) == 2 && args[1] != "-") {
+			return nil
+		}
+		if call, ok := cmd.Y.Cmd.(*syntax.CallExpr); ok && len(cmd.Y.Redirs) == 0 && isKubectlCall(call) {
+			return call
+		}
+	}
+	return nil
+}
+
+func isKubectlCall(call *syntax.CallExpr) bool {
+	if len(call.Assigns) > 0 {
+		return false
+	}
+	args := callArgs(call)
+	if len(args) == 0 {
+		return false
+	}
+	// Don't run binaries named kubectl from arbitrary paths.
+	return args[0] == "kubectl"
+}
+
+// isLiteralCommand checks that a command doesn't contain expansions that could
+// run other commands (e.g. command substitutions), nor redirections other than
+// heredocs.
+func isLiteralCommand(file *syntax.File) bool {
+	literal := true
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.CmdSubst, *syntax.ProcSubst, *syntax.ArithmExp, *syntax.ParamExp, *syntax.ExtGlob:
+			literal = false
+		case *syntax.Redirect:
+			if n.Op != syntax.Hdoc && n.Op != syntax.DashHdoc {
+				literal = false
+			}
+		}
+		return literal
+	})
+	return literal
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExplainKubectlCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{
+			command:  "kubectl get pods -n prod",
+			expected: "Run kubectl get on pods in namespace prod (read-only)",
+		},
+		{
+			command:  "kubectl delete pods -l app=web -n prod",
+			expected: "Delete pods matching app=web in namespace prod",
+		},
+		{
+			command:  "kubectl delete deployments --all -A",
+			expected: "Delete all deployments in all namespaces",
+		},
+		{
+			command:  "kubectl apply -f deploy.yaml",
+			expected: "Create or update the resources defined in deploy.yaml in the current namespace",
+		},
+		{
+			command:  "kubectl rollout restart deployment/web",
+			expected: "Restart the pods of deployment/web in the current namespace",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.command, func(t *testing.T) {
+			commands := ParseKubectlCommands(tc.command)
+			if len(commands) != 1 {
+				t.Fatalf("expected 1 kubectl command, got %d", len(commands))
+			}
+			if got := ExplainKubectlCommand(commands[0]); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDryRunKubectlCommandUnsupported(t *testing.T) {
+	// None of these commands must be executed.
+	t.Setenv("PATH", "")
+
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{name: "read-only", command: "kubectl get pods"},
+		{name: "multiple statements", command: "kubectl delete pod a; kubectl delete pod b"},
+		{name: "command substitution", command: "kubectl delete pod $(whoami)"},
+		{name: "variable", command: "kubectl delete pod $POD"},
+		{name: "redirection", command: "kubectl delete pod a > out.txt"},
+		{name: "other binary", command: "./kubectl delete pod a"},
+		{name: "pipe from other command", command: "curl https://example.com | kubectl apply -f -"},
+		{name: "output flag", command: "kubectl scale deployment web --replicas=2 -o yaml"},
+		{name: "unsupported verb", command: "kubectl drain node-1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			names, ok, err := DryRunKubectlCommand(context.Background(), tc.command, InvokeToolOptions{WorkDir: t.TempDir()})
+			if ok || err != nil || names != nil {
+				t.Errorf("expected command to be unsupported, got names=%v ok=%v err=%v", names, ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,490 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// GitOpsManager returns the GitOps controller managing an object, according
+// to the labels and annotations they set, e.g. "Argo CD application web".
+// It returns "" for objects that are not managed by GitOps.
+func GitOpsManager(obj map[string]any) string {
+	metadata, _ := obj["metadata"].(map[string]any)
+	labels, _ := metadata["labels"].(map[string]any)
+	annotations, _ := metadata["annotations"].(map[string]any)
+	label := func(key string) string {
+		s, _ := labels[key].(string)
+		return s
+	}
+
+	// Argo CD tracks its objects with an annotation, "app:group/kind:namespace/name",
+	// or with a label in older versions.
+	if id, _ := annotations["argocd.argoproj.io/tracking-id"].(string); id != "" {
+		app, _, _ := strings.Cut(id, ":")
+		return "Argo CD application " + app
+	}
+	if app := label("argocd.argoproj.io/instance"); app != "" {
+		return "Argo CD application " + app
+	}
+	if name := label("kustomize.toolkit.fluxcd.io/name"); name != "" {
+		return "Flux Kustomization " + joinNamespace(label("kustomize.toolkit.fluxcd.io/namespace"), name)
+	}
+	if name := label("helm.toolkit.fluxcd.io/name"); name != "" {
+		return "Flux HelmRelease " + joinNamespace(label("helm.toolkit.fluxcd.io/namespace"), name)
+	}
+	return ""
+}
+
+func joinNamespace(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// GitOpsChange is the change of an object managed by GitOps, to be made to
+// its manifest rather than to the cluster.
+type GitOpsChange struct {
+	// Object is the object in the cluster.
+	Object map[string]any
+	// ManagedBy is the GitOps controller managing the object, see GitOpsManager.
+	ManagedBy string
+	// Delete is set if the object is deleted.
+	Delete bool
+	// Patch is the strategic merge patch of the fields of the manifest of the
+	// object: the labels, annotations, and the fields besides metadata and status.
+	Patch map[string]any
+}
+
+// String identifies the object, e.g. "Deployment web in namespace prod".
+func (c GitOpsChange) String() string {
+	return objectRef(c.Object)
+}
+
+func objectRef(obj map[string]any) string {
+	kind, name := objectKindName(obj)
+	if namespace := objectNamespace(obj); namespace != "" {
+		return fmt.Sprintf("%s %s in namespace %s", kind, name, namespace)
+	}
+	return kind + " " + name
+}
+
+func objectNamespace(obj map[string]any) string {
+	metadata, _ := obj["metadata"].(map[string]any)
+	namespace, _ := metadata["namespace"].(string)
+	return namespace
+}
+
+// GitOpsChanges previews a call of the kubectl or apply_manifest tools with a
+// server-side dry-run, and returns its changes to the objects managed by
+// GitOps, and the other objects it creates or changes. ok is false for the
+// calls that can't be previewed, see DryRunKubectlCommand.
+func GitOpsChanges(ctx context.Context, toolName string, args map[string]any, opt InvokeToolOptions) (changes []GitOpsChange, unmanaged []string, ok bool, err error) {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+
+	var objects []map[string]any
+	var verb string
+	switch toolName {
+	case "kubectl":
+		command, _ := args["command"].(string)
+		objects, verb, ok, err = DryRunKubectlObjects(ctx, command, opt)
+	case "apply_manifest":
+		objects, err = dryRunApplyManifestObjects(ctx, args)
+		ok = true
+	}
+	if !ok || err != nil {
+		return nil, nil, ok, err
+	}
+
+	for _, obj := range objects {
+		live, err := getClusterObject(ctx, obj)
+		if err != nil {
+			return nil, nil, true, err
+		}
+		manager := GitOpsManager(live)
+		if live == nil || manager == "" {
+			unmanaged = append(unmanaged, objectRef(obj))
+			continue
+		}
+		change := GitOpsChange{Object: live, ManagedBy: manager, Delete: verb == "delete"}
+		if !change.Delete {
+			change.Patch = manifestPatch(manifestFields(live), manifestFields(obj))
+			if len(change.Patch) == 0 {
+				continue
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, unmanaged, true, nil
+}
+
+// dryRunApplyManifestObjects returns the objects as they would be after an
+// apply_manifest call.
+func dryRunApplyManifestObjects(ctx context.Context, args map[string]any) ([]map[string]any, error) {
+	manifest, err := readManifestArg(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	kubectlArgs := []string{"apply", "--server-side", "--field-manager=" + FieldManager, "--dry-run=server", "-o", "json", "-f", "-"}
+	if namespace, _ := args["namespace"].(string); namespace != "" {
+		kubectlArgs = append(kubectlArgs, "-n", namespace)
+	}
+	if force, _ := args["force_conflicts"].(bool); force {
+		kubectlArgs = append(kubectlArgs, "--force-conflicts")
+	}
+	out, err := kubectlOutputWithStdin(ctx, manifest, kubectlArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return parseKubectlObjects(out)
+}
+
+// getClusterObject returns the object of the cluster with the kind, name and
+// namespace of obj, or nil if it doesn't exist.
+func getClusterObject(ctx context.Context, obj map[string]any) (map[string]any, error) {
+	kind, name := objectKindName(obj)
+	resource := strings.ToLower(kind)
+	// Kinds are qualified with the version and group, e.g. deployment.v1.apps.
+	if apiVersion, _ := obj["apiVersion"].(string); strings.Contains(apiVersion, "/") {
+		group, version, _ := strings.Cut(apiVersion, "/")
+		resource += "." + version + "." + group
+	}
+	args := []string{"get", resource, name, "-o", "json", "--ignore-not-found"}
+	if namespace := objectNamespace(obj); namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	out, err := kubectlOutput(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := parseKubectlObjects(out)
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	return objects[0], nil
+}
+
+// manifestFields returns the fields of an object that belong to its manifest.
+func manifestFields(obj map[string]any) map[string]any {
+	fields := normalizeObject(obj)
+	delete(fields, "apiVersion")
+	delete(fields, "kind")
+	metadata, _ := fields["metadata"].(map[string]any)
+	kept := map[string]any{}
+	for _, key := range []string{"labels", "annotations"} {
+		if v, ok := metadata[key]; ok {
+			kept[key] = v
+		}
+	}
+	fields["metadata"] = kept
+	return fields
+}
+
+// manifestPatch returns the strategic merge patch turning from into to.
+// Lists of named objects, e.g. containers, are patched by name; other lists
+// are replaced.
+func manifestPatch(from, to map[string]any) map[string]any {
+	patch := map[string]any{}
+	for key, value := range to {
+		old, ok := from[key]
+		if !ok {
+			patch[key] = value
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			if o, ok := old.(map[string]any); ok {
+				if p := manifestPatch(o, v); len(p) > 0 {
+					patch[key] = p
+				}
+				continue
+			}
+		case []any:
+			if o, ok := old.([]any); ok && namedList(o) && namedList(v) {
+				if p := namedListPatch(o, v); len(p) > 0 {
+					patch[key] = p
+				}
+				continue
+			}
+		}
+		if !reflect.DeepEqual(old, value) {
+			patch[key] = value
+		}
+	}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	return patch
+}
+
+// namedList reports whether the list is made of objects with names.
+func namedList(list []any) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		if _, ok := m["name"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func namedListPatch(from, to []any) []any {
+	old := map[string]map[string]any{}
+	for _, item := range from {
+		m := item.(map[string]any)
+		old[m["name"].(string)] = m
+	}
+	var patch []any
+	for _, item := range to {
+		m := item.(map[string]any)
+		name := m["name"].(string)
+		o, ok := old[name]
+		delete(old, name)
+		if !ok {
+			patch = append(patch, m)
+			continue
+		}
+		if p := manifestPatch(o, m); len(p) > 0 {
+			p["name"] = name
+			patch = append(patch, p)
+		}
+	}
+	for _, item := range from {
+		name := item.(map[string]any)["name"].(string)
+		if _, ok := old[name]; ok {
+			patch = append(patch, map[string]any{"name": name, "$patch": "delete"})
+		}
+	}
+	return patch
+}
+
+// applyManifestPatch applies a patch returned by manifestPatch to obj.
+func applyManifestPatch(obj, patch map[string]any) map[string]any {
+	if obj == nil {
+		obj = map[string]any{}
+	}
+	for key, value := range patch {
+		switch v := value.(type) {
+		case nil:
+			delete(obj, key)
+		case map[string]any:
+			o, _ := obj[key].(map[string]any)
+			obj[key] = applyManifestPatch(o, v)
+		case []any:
+			if o, ok := obj[key].([]any); ok && namedList(o) && namedList(v) {
+				obj[key] = applyNamedListPatch(o, v)
+			} else {
+				obj[key] = v
+			}
+		default:
+			obj[key] = v
+		}
+	}
+	return obj
+}
+
+func applyNamedListPatch(list, patch []any) []any {
+	for _, item := range patch {
+		p := item.(map[string]any)
+		name := p["name"].(string)
+		i := 0
+		for i < len(list) && list[i].(map[string]any)["name"] != name {
+			i++
+		}
+		switch {
+		case p["$patch"] == "delete":
+			if i < len(list) {
+				list = append(list[:i], list[i+1:]...)
+			}
+		case i < len(list):
+			list[i] = applyManifestPatch(list[i].(map[string]any), p)
+		default:
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// kustomizationFiles are the names of the kustomization file of a directory.
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// ApplyGitOpsChange makes a change to the manifests of the directory dir of a
+// Git repository, and returns the files it changed, relative to dir. The
+// document of the manifest defining the object is changed if there is one,
+// and else a patch is added to the kustomize overlay of dir.
+func ApplyGitOpsChange(dir string, change GitOpsChange) ([]string, error) {
+	kind, name := objectKindName(change.Object)
+	namespace := objectNamespace(change.Object)
+
+	type match struct {
+		file  string
+		docs  []string
+		index int
+		obj   map[string]any
+	}
+	var matches []match
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		docs := yamlDocumentSeparator.Split(string(b), -1)
+		for i, doc := range docs {
+			var obj map[string]any
+			if yaml.Unmarshal([]byte(doc), &obj) != nil {
+				continue
+			}
+			k, n := objectKindName(obj)
+			if ns := objectNamespace(obj); k == kind && n == name && (ns == "" || ns == namespace) {
+				matches = append(matches, match{file: path, docs: docs, index: i, obj: obj})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading the manifests: %w", err)
+	}
+
+	switch {
+	case len(matches) > 1:
+		var files []string
+		for _, m := range matches {
+			rel, _ := filepath.Rel(dir, m.file)
+			files = append(files, rel)
+		}
+		return nil, fmt.Errorf("%s is defined by several manifests (%s), set the path of the manifests of the cluster", change, strings.Join(files, ", "))
+	case len(matches) == 1:
+		m := matches[0]
+		docs := m.docs
+		if change.Delete {
+			docs = append(docs[:m.index], docs[m.index+1:]...)
+		} else {
+			b, err := yaml.Marshal(applyManifestPatch(m.obj, change.Patch))
+			if err != nil {
+				return nil, err
+			}
+			docs[m.index] = string(b)
+		}
+		var parts []string
+		for _, doc := range docs {
+			if doc = strings.Trim(doc, "\n"); doc != "" {
+				parts = append(parts, doc+"\n")
+			}
+		}
+		if err := os.WriteFile(m.file, []byte(strings.Join(parts, "---\n")), 0o644); err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(dir, m.file)
+		return []string{rel}, nil
+	case change.Delete:
+		return nil, fmt.Errorf("no manifest of %s was found, to delete it", change)
+	}
+	return addKustomizePatch(dir, change)
+}
+
+// addKustomizePatch adds the patch of a change to the kustomize overlay of dir.
+func addKustomizePatch(dir string, change GitOpsChange) ([]string, error) {
+	var kustomizationFile string
+	for _, name := range kustomizationFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			kustomizationFile = name
+			break
+		}
+	}
+	if kustomizationFile == "" {
+		return nil, fmt.Errorf("no manifest of %s was found, and there is no kustomize overlay to patch it", change)
+	}
+
+	kind, name := objectKindName(change.Object)
+	metadata := map[string]any{"name": name}
+	if namespace := objectNamespace(change.Object); namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	patchFile := strings.ToLower(fmt.Sprintf("kubectl-ai-%s-%s.yaml", kind, name))
+	patch := map[string]any{"apiVersion": change.Object["apiVersion"], "kind": kind, "metadata": metadata}
+	// Changes of the same object are gathered in a single patch.
+	if b, err := os.ReadFile(filepath.Join(dir, patchFile)); err == nil {
+		if err := yaml.Unmarshal(b, &patch); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", patchFile, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	b, err := yaml.Marshal(applyManifestPatch(patch, change.Patch))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, patchFile), b, 0o644); err != nil {
+		return nil, err
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, kustomizationFile))
+	if err != nil {
+		return nil, err
+	}
+	var kustomization map[string]any
+	if err := yaml.Unmarshal(b, &kustomization); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", kustomizationFile, err)
+	}
+	if kustomization == nil {
+		kustomization = map[string]any{}
+	}
+	patches, _ := kustomization["patches"].([]any)
+	for _, p := range patches {
+		if m, ok := p.(map[string]any); ok && m["path"] == patchFile {
+			return []string{patchFile}, nil
+		}
+	}
+	kustomization["patches"] = append(patches, map[string]any{"path": patchFile})
+	if b, err = yaml.Marshal(kustomization); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, kustomizationFile), b, 0o644); err != nil {
+		return nil, err
+	}
+	return []string{kustomizationFile, patchFile}, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+const gitOpsLiveDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  resourceVersion: "98765"
+  labels:
+    app: web
+  annotations:
+    argocd.argoproj.io/tracking-id: "web:apps/Deployment:prod/web"
+    deployment.kubernetes.io/revision: "42"
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: app
+        image: web:v1
+        imagePullPolicy: IfNotPresent
+      - name: proxy
+        image: envoy:1.30
+status:
+  readyReplicas: 2
+`
+
+func parseTestObject(t *testing.T, manifest string) map[string]any {
+	t.Helper()
+	var obj map[string]any
+	if err := yaml.Unmarshal([]byte(manifest), &obj); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestGitOpsManager(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		want     string
+	}{
+		{name: "argo cd tracking id", metadata: `annotations: {argocd.argoproj.io/tracking-id: "web:apps/Deployment:prod/web"}`, want: "Argo CD application web"},
+		{name: "argo cd label", metadata: `labels: {argocd.argoproj.io/instance: web}`, want: "Argo CD application web"},
+		{name: "flux kustomization", metadata: `labels: {kustomize.toolkit.fluxcd.io/name: apps, kustomize.toolkit.fluxcd.io/namespace: flux-system}`, want: "Flux Kustomization flux-system/apps"},
+		{name: "flux helm release", metadata: `labels: {helm.toolkit.fluxcd.io/name: web}`, want: "Flux HelmRelease web"},
+		{name: "helm only", metadata: `labels: {app.kubernetes.io/managed-by: Helm, app.kubernetes.io/instance: web}`, want: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := map[string]any{"metadata": parseTestObject(t, tc.metadata)}
+			if got := GitOpsManager(obj); got != tc.want {
+				t.Errorf("GitOpsManager() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestManifestPatch(t *testing.T) {
+	live := parseTestObject(t, gitOpsLiveDeployment)
+	desired := parseTestObject(t, gitOpsLiveDeployment)
+	spec := desired["spec"].(map[string]any)
+	spec["replicas"] = float64(3)
+	containers := spec["template"].(map[string]any)["spec"].(map[string]any)["containers"].([]any)
+	containers[0].(map[string]any)["image"] = "web:v2"
+	spec["template"].(map[string]any)["spec"].(map[string]any)["containers"] = containers[:1]
+
+	patch := manifestPatch(manifestFields(live), manifestFields(desired))
+	want := parseTestObject(t, `
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: web:v2
+      - name: proxy
+        $patch: delete
+`)
+	if !reflect.DeepEqual(patch, want) {
+		t.Errorf("manifestPatch() = %v, want %v", patch, want)
+	}
+
+	// The patch applies to manifests without the defaults of the cluster.
+	manifest := parseTestObject(t, `
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: app
+        image: web:v1
+      - name: proxy
+        image: envoy:1.30
+`)
+	got := applyManifestPatch(manifest, patch)
+	wantManifest := parseTestObject(t, `
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: web:v2
+`)
+	if !reflect.DeepEqual(got, wantManifest) {
+		t.Errorf("applyManifestPatch() = %v, want %v", got, wantManifest)
+	}
+}
+
+func TestApplyGitOpsChange(t *testing.T) {
+	change := GitOpsChange{
+		Object:    parseTestObject(t, gitOpsLiveDeployment),
+		ManagedBy: "Argo CD application web",
+		Patch:     map[string]any{"spec": map[string]any{"replicas": float64(3)}},
+	}
+
+	t.Run("manifest", func(t *testing.T) {
+		dir := t.TempDir()
+		manifest := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 2\n"
+		if err := os.WriteFile(filepath.Join(dir, "web.yaml"), []byte(manifest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files, err := ApplyGitOpsChange(dir, change)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(files, []string{"web.yaml"}) {
+			t.Errorf("ApplyGitOpsChange() changed %v, want web.yaml", files)
+		}
+		b, _ := os.ReadFile(filepath.Join(dir, "web.yaml"))
+		want := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 3\n"
+		if string(b) != want {
+			t.Errorf("manifest = %q, want %q", b, want)
+		}
+	})
+
+	t.Run("kustomize overlay", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n- ../../base\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files, err := ApplyGitOpsChange(dir, change)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(files, []string{"kustomization.yaml", "kubectl-ai-deployment-web.yaml"}) {
+			t.Errorf("ApplyGitOpsChange() changed %v", files)
+		}
+		b, _ := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+		if want := "patches:\n- path: kubectl-ai-deployment-web.yaml\nresources:\n- ../../base\n"; string(b) != want {
+			t.Errorf("kustomization = %q, want %q", b, want)
+		}
+		b, _ = os.ReadFile(filepath.Join(dir, "kubectl-ai-deployment-web.yaml"))
+		if want := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: prod\nspec:\n  replicas: 3\n"; string(b) != want {
+			t.Errorf("patch = %q, want %q", b, want)
+		}
+	})
+
+	t.Run("several manifests", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, env := range []string{"staging", "prod"} {
+			os.MkdirAll(filepath.Join(dir, env), 0o755)
+			if err := os.WriteFile(filepath.Join(dir, env, "web.yaml"), []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := ApplyGitOpsChange(dir, change); err == nil || !strings.Contains(err.Error(), "several manifests") {
+			t.Errorf("ApplyGitOpsChange() error = %v, want the manifests to be ambiguous", err)
+		}
+	})
+}
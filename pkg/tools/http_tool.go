@@ -0,0 +1,388 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/itchyny/gojq"
+)
+
+const (
+	defaultHTTPToolTimeout = 30 * time.Second
+	// maxHTTPToolResponseBytes caps the response body we read and return to the LLM.
+	maxHTTPToolResponseBytes = 64 * 1024
+)
+
+// httpToolTransport is the transport of the requests of the http tools.
+var httpToolTransport http.RoundTripper = http.DefaultTransport
+
+// SetHTTPTransport sets the transport of the requests of the http tools, e.g.
+// to trust the CA bundle trusted by the CLI.
+func SetHTTPTransport(transport http.RoundTripper) {
+	httpToolTransport = transport
+}
+
+// HTTPToolConfig describes the request made by a custom tool of type "http".
+//
+// URL and Body are Go templates rendered with the tool call arguments,
+// e.g. "https://cmdb.example.com/api/hosts/{{ .host | urlquery }}". The
+// arguments are inserted as is: escape them with urlquery in the URL and with
+// json in JSON bodies. Omitted parameters are rendered as their zero value.
+// Header values are expanded with environment variables (e.g. "Bearer ${CMDB_TOKEN}"),
+// so that secrets never have to be written in the config or seen by the LLM.
+// Requests are only sent to the scheme and host of the URL rendered without
+// arguments, so that the arguments can't send the headers to another host.
+type HTTPToolConfig struct {
+	Method  string            `yaml:"method" json:"method,omitempty"`
+	URL     string            `yaml:"url" json:"url"`
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+	Body    string            `yaml:"body" json:"body,omitempty"`
+	// ResponseFilter is a jq expression applied to JSON responses, e.g. ".items[] | {id, status}".
+	ResponseFilter string `yaml:"response_filter" json:"response_filter,omitempty"`
+	// Timeout is the request timeout, e.g. "10s". Defaults to 30s.
+	Timeout string `yaml:"timeout" json:"timeout,omitempty"`
+}
+
+// CustomToolParameter describes an argument of a custom tool.
+type CustomToolParameter struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	// Type is the JSON schema type of the parameter: string (default), integer, number or boolean.
+	Type     string `yaml:"type" json:"type,omitempty"`
+	Required bool   `yaml:"required" json:"required,omitempty"`
+}
+
+// HTTPTool implements the Tool interface for custom tools calling REST APIs.
+type HTTPTool struct {
+	config         CustomToolConfig
+	urlTemplate    *template.Template
+	bodyTemplate   *template.Template
+	responseFilter *gojq.Code
+	timeout        time.Duration
+	// scheme and host are the ones of the URL rendered without arguments.
+	scheme, host string
+}
+
+var httpToolTemplateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// NewHTTPTool creates a new HTTPTool instance.
+func NewHTTPTool(config CustomToolConfig) (*HTTPTool, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("custom tool name cannot be empty")
+	}
+	if config.HTTP == nil || config.HTTP.URL == "" {
+		return nil, fmt.Errorf("http.url cannot be empty for http tool %q", config.Name)
+	}
+	if config.HTTP.Method == "" {
+		config.HTTP.Method = http.MethodGet
+	}
+	config.HTTP.Method = strings.ToUpper(config.HTTP.Method)
+
+	for _, p := range config.Parameters {
+		if p.Name == "" {
+			return nil, fmt.Errorf("parameter name cannot be empty for http tool %q", config.Name)
+		}
+		switch p.Type {
+		case "", "string", "integer", "number", "boolean":
+		default:
+			return nil, fmt.Errorf("unsupported type %q for parameter %q of http tool %q", p.Type, p.Name, config.Name)
+		}
+	}
+
+	t := &HTTPTool{config: config, timeout: defaultHTTPToolTimeout}
+
+	var err error
+	t.urlTemplate, err = template.New("url").Funcs(httpToolTemplateFuncs).Option("missingkey=error").Parse(config.HTTP.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url template for http tool %q: %w", config.Name, err)
+	}
+	u, err := t.renderURL(nil)
+	if err != nil {
+		return nil, fmt.Errorf("rendering url of http tool %q: %w", config.Name, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url of http tool %q must start with http:// or https:// and a host that doesn't depend on the parameters", config.Name)
+	}
+	t.scheme, t.host = u.Scheme, u.Host
+	if config.HTTP.Body != "" {
+		t.bodyTemplate, err = template.New("body").Funcs(httpToolTemplateFuncs).Option("missingkey=error").Parse(config.HTTP.Body)
+		if err != nil {
+			return nil, fmt.Errorf("parsing body template for http tool %q: %w", config.Name, err)
+		}
+	}
+	if config.HTTP.ResponseFilter != "" {
+		query, err := gojq.Parse(config.HTTP.ResponseFilter)
+		if err != nil {
+			return nil, fmt.Errorf("parsing response_filter for http tool %q: %w", config.Name, err)
+		}
+		t.responseFilter, err = gojq.Compile(query)
+		if err != nil {
+			return nil, fmt.Errorf("compiling response_filter for http tool %q: %w", config.Name, err)
+		}
+	}
+	if config.HTTP.Timeout != "" {
+		t.timeout, err = time.ParseDuration(config.HTTP.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("parsing timeout for http tool %q: %w", config.Name, err)
+		}
+	}
+	return t, nil
+}
+
+// Name returns the tool's name.
+func (t *HTTPTool) Name() string {
+	return t.config.Name
+}
+
+// Description returns the tool's description.
+func (t *HTTPTool) Description() string {
+	return t.config.Description
+}
+
+// FunctionDefinition returns the tool's function definition, built from the configured parameters.
+func (t *HTTPTool) FunctionDefinition() *gollm.FunctionDefinition {
+	properties := make(map[string]*gollm.Schema)
+	var required []string
+	for _, p := range t.config.Parameters {
+		schemaType := gollm.TypeString
+		switch p.Type {
+		case "integer":
+			schemaType = gollm.TypeInteger
+		case "number":
+			schemaType = gollm.TypeNumber
+		case "boolean":
+			schemaType = gollm.TypeBoolean
+		}
+		properties[p.Name] = &gollm.Schema{
+			Type:        schemaType,
+			Description: p.Description,
+		}
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type:       gollm.TypeObject,
+			Properties: properties,
+			Required:   required,
+		},
+	}
+}
+
+// HTTPToolResult is the result of an http tool call.
+type HTTPToolResult struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Body       any    `json:"body,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OutputSchema returns the schema of HTTPToolResult, including the configured
+// schema of the response body.
+func (t *HTTPTool) OutputSchema() *gollm.Schema {
+	body := &gollm.Schema{Description: "The response body, after the response_filter if any."}
+	if t.config.OutputSchema != nil {
+		body = t.config.OutputSchema
+	}
+	return &gollm.Schema{
+		Type: gollm.TypeObject,
+		Properties: map[string]*gollm.Schema{
+			"method":      {Type: gollm.TypeString},
+			"url":         {Type: gollm.TypeString},
+			"status_code": {Type: gollm.TypeInteger},
+			"body":        body,
+			"truncated":   {Type: gollm.TypeBoolean, Description: "Whether the response body was truncated."},
+			"error":       {Type: gollm.TypeString, Description: "Why the request failed, omitted on success."},
+		},
+	}
+}
+
+// Run renders the request from the arguments and calls the configured endpoint.
+func (t *HTTPTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	for _, p := range t.config.Parameters {
+		if _, ok := args[p.Name]; p.Required && !ok {
+			return &HTTPToolResult{Method: t.config.HTTP.Method, Error: fmt.Sprintf("missing required parameter %q", p.Name)}, nil
+		}
+	}
+
+	u, err := t.renderURL(args)
+	if err != nil {
+		return &HTTPToolResult{Method: t.config.HTTP.Method, Error: err.Error()}, nil
+	}
+	result := &HTTPToolResult{Method: t.config.HTTP.Method, URL: u.String()}
+	if u.Scheme != t.scheme || u.Host != t.host {
+		result.Error = fmt.Sprintf("the arguments changed the url to another host than %s://%s", t.scheme, t.host)
+		return result, nil
+	}
+
+	var body io.Reader
+	if t.bodyTemplate != nil {
+		var b bytes.Buffer
+		if err := t.bodyTemplate.Execute(&b, t.templateData(args)); err != nil {
+			return nil, fmt.Errorf("rendering body: %w", err)
+		}
+		body = &b
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, t.config.HTTP.Method, result.URL, body)
+	if err != nil {
+		result.Error = fmt.Sprintf("building request: %v", err)
+		return result, nil
+	}
+	for k, v := range t.config.HTTP.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// The timeout of the client also bounds the reading of the body.
+	client := &http.Client{Timeout: t.timeout, Transport: httpToolTransport}
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPToolResponseBytes+1))
+	if err != nil {
+		result.Error = fmt.Sprintf("reading response: %v", err)
+		return result, nil
+	}
+	if len(b) > maxHTTPToolResponseBytes {
+		b = b[:maxHTTPToolResponseBytes]
+		result.Truncated = true
+	}
+	if resp.StatusCode >= 400 {
+		result.Error = resp.Status
+	}
+
+	result.Body = string(b)
+	if t.responseFilter != nil && !result.Truncated {
+		filtered, err := t.filterResponse(ctx, b)
+		if err != nil {
+			result.Error = fmt.Sprintf("applying response_filter: %v", err)
+			return result, nil
+		}
+		result.Body = filtered
+	} else if t.config.OutputSchema != nil && !result.Truncated && result.Error == "" {
+		var body any
+		if err := json.Unmarshal(b, &body); err != nil {
+			result.Error = fmt.Sprintf("expected a JSON response: %v", err)
+			return result, nil
+		}
+		result.Body = body
+	}
+	return result, nil
+}
+
+// templateData returns the arguments of a tool call for the templates, with the
+// zero value of their type for the omitted parameters.
+func (t *HTTPTool) templateData(args map[string]any) map[string]any {
+	data := make(map[string]any, len(t.config.Parameters))
+	for _, p := range t.config.Parameters {
+		switch p.Type {
+		case "integer", "number":
+			data[p.Name] = 0
+		case "boolean":
+			data[p.Name] = false
+		default:
+			data[p.Name] = ""
+		}
+	}
+	for k, v := range args {
+		data[k] = v
+	}
+	return data
+}
+
+// renderURL renders the URL template with the arguments of a tool call.
+func (t *HTTPTool) renderURL(args map[string]any) (*url.URL, error) {
+	var b bytes.Buffer
+	if err := t.urlTemplate.Execute(&b, t.templateData(args)); err != nil {
+		return nil, fmt.Errorf("rendering url: %w", err)
+	}
+	u, err := url.Parse(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %w", err)
+	}
+	return u, nil
+}
+
+// filterResponse applies the jq response filter to a JSON response body.
+func (t *HTTPTool) filterResponse(ctx context.Context, b []byte) (any, error) {
+	var input any
+	if err := json.Unmarshal(b, &input); err != nil {
+		return nil, fmt.Errorf("response is not JSON: %w", err)
+	}
+	var results []any
+	iter := t.responseFilter.RunWithContext(ctx, input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		results = append(results, v)
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+// IsInteractive returns false, http tools are never interactive.
+func (t *HTTPTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+// CheckModifiesResource treats safe HTTP methods as read-only.
+// Any other method may change the state of the remote system.
+func (t *HTTPTool) CheckModifiesResource(args map[string]any) string {
+	switch t.config.HTTP.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "no"
+	}
+	return "unknown"
+}
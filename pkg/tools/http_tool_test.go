@@ -0,0 +1,178 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestHTTPTool(t *testing.T) {
+	t.Setenv("TICKETS_TOKEN", "s3cr3t")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer s3cr3t" {
+			t.Errorf("expected authorization header from env, got %q", got)
+		}
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if got := r.URL.Query().Get("q"); got != "pod crash" {
+			t.Errorf("expected query %q, got %q", "pod crash", got)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"priority":"high"}` {
+			t.Errorf("unexpected body %q", body)
+		}
+		w.Write([]byte(`{"issues":[{"key":"OPS-1","status":"open"},{"key":"OPS-2","status":"closed"}]}`))
+	}))
+	defer server.Close()
+
+	configYAML := `
+- name: tickets
+  type: http
+  description: Search tickets
+  http:
+    method: post
+    url: "` + server.URL + `/search?q={{ .query | urlquery }}"
+    headers:
+      Authorization: "Bearer ${TICKETS_TOKEN}"
+    body: '{"priority":{{ json .priority }}}'
+    response_filter: "[.issues[].key]"
+  parameters:
+  - name: query
+    required: true
+  - name: priority
+`
+	var configs []CustomToolConfig
+	if err := yaml.Unmarshal([]byte(configYAML), &configs); err != nil {
+		t.Fatalf("parsing config: %v", err)
+	}
+	tool, err := newToolFromConfig(configs[0])
+	if err != nil {
+		t.Fatalf("creating tool: %v", err)
+	}
+
+	if got := tool.CheckModifiesResource(nil); got != "unknown" {
+		t.Errorf("expected POST tool to be unknown, got %q", got)
+	}
+	if got := tool.FunctionDefinition().Parameters.Required; !reflect.DeepEqual(got, []string{"query"}) {
+		t.Errorf("unexpected required parameters %v", got)
+	}
+
+	result, err := tool.Run(context.Background(), map[string]any{"query": "pod crash", "priority": "high"})
+	if err != nil {
+		t.Fatalf("running tool: %v", err)
+	}
+	httpResult := result.(*HTTPToolResult)
+	if httpResult.Error != "" {
+		t.Fatalf("unexpected error: %s", httpResult.Error)
+	}
+	if !reflect.DeepEqual(httpResult.Body, []any{"OPS-1", "OPS-2"}) {
+		t.Errorf("unexpected filtered body %#v", httpResult.Body)
+	}
+
+	result, err = tool.Run(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("running tool: %v", err)
+	}
+	if result.(*HTTPToolResult).Error == "" {
+		t.Errorf("expected error for missing required parameter")
+	}
+}
+
+func TestHTTPToolArguments(t *testing.T) {
+	t.Setenv("CMDB_TOKEN", "s3cr3t")
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	tool, err := newToolFromConfig(CustomToolConfig{
+		Name: "hosts",
+		Type: "http",
+		HTTP: &HTTPToolConfig{
+			URL:     server.URL + "{{ .path }}?q={{ .query | urlquery }}&limit={{ .limit }}",
+			Headers: map[string]string{"Authorization": "Bearer ${CMDB_TOKEN}"},
+		},
+		Parameters: []CustomToolParameter{{Name: "path"}, {Name: "query"}, {Name: "limit", Type: "integer"}},
+	})
+	if err != nil {
+		t.Fatalf("newToolFromConfig: %v", err)
+	}
+
+	// Omitted parameters are rendered as their zero value.
+	result, err := tool.Run(context.Background(), map[string]any{"path": "/hosts"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := result.(*HTTPToolResult); got.Error != "" || !reflect.DeepEqual(queries, []string{"q=&limit=0"}) {
+		t.Errorf("Run() = %+v with queries %v, want q=&limit=0", got, queries)
+	}
+
+	// The arguments can't send the headers to another host.
+	for _, path := range []string{".evil.com/", "@evil.com/"} {
+		result, err := tool.Run(context.Background(), map[string]any{"path": path})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if got := result.(*HTTPToolResult); got.Error == "" || got.StatusCode != 0 {
+			t.Errorf("Run() with path %q = %+v, want the request rejected", path, got)
+		}
+	}
+
+	if _, err := newToolFromConfig(CustomToolConfig{Name: "hosts", Type: "http", HTTP: &HTTPToolConfig{URL: "https://{{ .host }}/api"}, Parameters: []CustomToolParameter{{Name: "host"}}}); err == nil {
+		t.Errorf("newToolFromConfig() accepted a url whose host is a parameter")
+	}
+}
+
+func TestHTTPToolTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	tool, err := newToolFromConfig(CustomToolConfig{Name: "status", Type: "http", HTTP: &HTTPToolConfig{Method: "GET", URL: server.URL}})
+	if err != nil {
+		t.Fatalf("newToolFromConfig: %v", err)
+	}
+	run := func() *HTTPToolResult {
+		t.Helper()
+		result, err := tool.Run(context.Background(), map[string]any{})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return result.(*HTTPToolResult)
+	}
+
+	if result := run(); result.Error == "" {
+		t.Errorf("Run() trusted the certificate of the server without its CA")
+	}
+	// The transport of the test server trusts its certificate, like the
+	// transport trusting the CA bundle of the CLI.
+	defer SetHTTPTransport(httpToolTransport)
+	SetHTTPTransport(server.Client().Transport)
+	if result := run(); result.Error != "" || result.StatusCode != http.StatusOK {
+		t.Errorf("Run() = %+v, want the response of the server", result)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// serviceAccountDir is where the credentials of the service account are mounted in pods.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// InCluster returns true when running in a pod with the credentials of its
+// service account mounted, the conditions of rest.InClusterConfig.
+func InCluster() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" || os.Getenv("KUBERNETES_SERVICE_PORT") == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(serviceAccountDir, "token"))
+	return err == nil
+}
+
+// WriteInClusterKubeconfig writes to path a kubeconfig using the service
+// account of the pod, for kubectl and the other tools to run without a
+// mounted kubeconfig. The kubeconfig references the token file rather than
+// copying it, so that rotated tokens are picked up.
+func WriteInClusterKubeconfig(path string) error {
+	kubeconfig, err := inClusterKubeconfig(serviceAccountDir, os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, kubeconfig, 0o600); err != nil {
+		return fmt.Errorf("writing in-cluster kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// inClusterKubeconfig returns the kubeconfig for the service account mounted
+// in dir, to reach the API server at host and port.
+func inClusterKubeconfig(dir, host, port string) ([]byte, error) {
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	tokenFile := filepath.Join(dir, "token")
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, fmt.Errorf("reading the service account token: %w", err)
+	}
+
+	cluster := map[string]any{"server": "https://" + net.JoinHostPort(host, port)}
+	caFile := filepath.Join(dir, "ca.crt")
+	if _, err := os.Stat(caFile); err == nil {
+		cluster["certificate-authority"] = caFile
+	}
+	kubeContext := map[string]any{"cluster": "in-cluster", "user": "in-cluster"}
+	if namespace, err := os.ReadFile(filepath.Join(dir, "namespace")); err == nil {
+		if ns := strings.TrimSpace(string(namespace)); ns != "" {
+			kubeContext["namespace"] = ns
+		}
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Config",
+		"clusters": []map[string]any{
+			{"name": "in-cluster", "cluster": cluster},
+		},
+		"users": []map[string]any{
+			{"name": "in-cluster", "user": map[string]any{"tokenFile": tokenFile}},
+		},
+		"contexts": []map[string]any{
+			{"name": "in-cluster", "context": kubeContext},
+		},
+		"current-context": "in-cluster",
+	}, "", "  ")
+}
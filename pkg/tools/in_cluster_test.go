@@ -0,0 +1,84 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestInClusterKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"token":     "sa-token",
+		"ca.crt":    "-----BEGIN CERTIFICATE-----",
+		"namespace": "kubectl-ai\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := inClusterKubeconfig(dir, "fd00::1", "443")
+	if err != nil {
+		t.Fatalf("inClusterKubeconfig() error = %v", err)
+	}
+	var got struct {
+		Clusters []struct {
+			Cluster struct {
+				Server               string `json:"server"`
+				CertificateAuthority string `json:"certificate-authority"`
+			} `json:"cluster"`
+		} `json:"clusters"`
+		Users []struct {
+			User struct {
+				TokenFile string `json:"tokenFile"`
+			} `json:"user"`
+		} `json:"users"`
+		Contexts []struct {
+			Context struct {
+				Namespace string `json:"namespace"`
+			} `json:"context"`
+		} `json:"contexts"`
+	}
+	if err := yaml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("parsing kubeconfig: %v", err)
+	}
+	if len(got.Clusters) != 1 || len(got.Users) != 1 || len(got.Contexts) != 1 {
+		t.Fatalf("kubeconfig = %s, want a single cluster, user and context", b)
+	}
+	if want := "https://[fd00::1]:443"; got.Clusters[0].Cluster.Server != want {
+		t.Errorf("server = %q, want %q", got.Clusters[0].Cluster.Server, want)
+	}
+	if want := filepath.Join(dir, "ca.crt"); got.Clusters[0].Cluster.CertificateAuthority != want {
+		t.Errorf("certificate authority = %q, want %q", got.Clusters[0].Cluster.CertificateAuthority, want)
+	}
+	if want := filepath.Join(dir, "token"); got.Users[0].User.TokenFile != want {
+		t.Errorf("token file = %q, want %q", got.Users[0].User.TokenFile, want)
+	}
+	if got.Contexts[0].Context.Namespace != "kubectl-ai" {
+		t.Errorf("namespace = %q, want kubectl-ai", got.Contexts[0].Context.Namespace)
+	}
+
+	if _, err := inClusterKubeconfig(dir, "", ""); err == nil {
+		t.Errorf("inClusterKubeconfig() without the service host: want error")
+	}
+	if _, err := inClusterKubeconfig(t.TempDir(), "10.0.0.1", "443"); err == nil {
+		t.Errorf("inClusterKubeconfig() without a token: want error")
+	}
+}
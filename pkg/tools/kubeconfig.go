@@ -0,0 +1,95 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// kubeconfigFile is the subset of the kubeconfig format we need to inspect.
+type kubeconfigFile struct {
+	CurrentContext string `json:"current-context"`
+	Contexts       []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Namespace string `json:"namespace"`
+		} `json:"context"`
+	} `json:"contexts"`
+}
+
+// CurrentContext returns the current-context set in the given kubeconfig file.
+func CurrentContext(kubeconfigPath string) (string, error) {
+	cfg, err := readKubeconfig(kubeconfigPath)
+	if err != nil {
+		return "", err
+	}
+	return cfg.CurrentContext, nil
+}
+
+// CurrentNamespace returns the namespace of the current context of the given
+// kubeconfig file, "default" if the context sets none.
+func CurrentNamespace(kubeconfigPath string) (string, error) {
+	cfg, err := readKubeconfig(kubeconfigPath)
+	if err != nil {
+		return "", err
+	}
+	for _, context := range cfg.Contexts {
+		if context.Name == cfg.CurrentContext && context.Context.Namespace != "" {
+			return context.Context.Namespace, nil
+		}
+	}
+	return "default", nil
+}
+
+func readKubeconfig(kubeconfigPath string) (*kubeconfigFile, error) {
+	if kubeconfigPath == "" {
+		return nil, fmt.Errorf("kubeconfig path is empty")
+	}
+	b, err := os.ReadFile(kubeconfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig %q: %w", kubeconfigPath, err)
+	}
+	var cfg kubeconfigFile
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig %q: %w", kubeconfigPath, err)
+	}
+	return &cfg, nil
+}
+
+// CheckKubeconfig checks that the given kubeconfig file can be used: it must
+// set a current context defined in the file. Inside a pod, a missing file is
+// fine, kubectl then uses the service account of the pod.
+func CheckKubeconfig(kubeconfigPath string) error {
+	if _, err := os.Stat(kubeconfigPath); errors.Is(err, os.ErrNotExist) && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return nil
+	}
+	cfg, err := readKubeconfig(kubeconfigPath)
+	if err != nil {
+		return err
+	}
+	if cfg.CurrentContext == "" {
+		return fmt.Errorf("kubeconfig %q has no current context", kubeconfigPath)
+	}
+	for _, context := range cfg.Contexts {
+		if context.Name == cfg.CurrentContext {
+			return nil
+		}
+	}
+	return fmt.Errorf("the current context %q is not defined in kubeconfig %q", cfg.CurrentContext, kubeconfigPath)
+}
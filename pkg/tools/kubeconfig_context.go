@@ -0,0 +1,145 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// KubeconfigSelection selects the context, cluster, user and namespace of a
+// kubeconfig, like the --context, --cluster, --user and --namespace flags of kubectl.
+type KubeconfigSelection struct {
+	// Context is the context to use, the current context if empty.
+	Context string
+	// Cluster overrides the cluster of the context.
+	Cluster string
+	// User overrides the user of the context.
+	User string
+	// Namespace overrides the namespace of the context.
+	Namespace string
+}
+
+// IsZero returns true if nothing is selected, i.e. the kubeconfig is used as is.
+func (s KubeconfigSelection) IsZero() bool {
+	return s == KubeconfigSelection{}
+}
+
+// WriteSelectedKubeconfig writes to path a kubeconfig holding only the
+// selected context of the kubeconfig of opt, with the cluster, user and
+// namespace overrides applied, as its current context. Tools then run with it as
+// their KUBECONFIG, so that every kubectl invocation and kubeconfig-aware
+// tool (e.g. helm) targets the selected cluster. It fails if the context,
+// cluster or user is not defined in the kubeconfig.
+func WriteSelectedKubeconfig(ctx context.Context, opt InvokeToolOptions, selection KubeconfigSelection, path string) error {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+
+	current, err := kubectlOutput(ctx, "config", "view", "--flatten", "-o", "json")
+	if err != nil {
+		return err
+	}
+	kubeconfig, err := selectKubeconfig(current, selection)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, kubeconfig, 0o600); err != nil {
+		return fmt.Errorf("writing kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// selectKubeconfig returns the kubeconfig holding the selected context of
+// config, the output of "kubectl config view --flatten -o json".
+func selectKubeconfig(config []byte, selection KubeconfigSelection) ([]byte, error) {
+	var kubeconfig struct {
+		Clusters       []map[string]any `json:"clusters"`
+		Users          []map[string]any `json:"users"`
+		Contexts       []map[string]any `json:"contexts"`
+		CurrentContext string           `json:"current-context"`
+		Preferences    map[string]any   `json:"preferences,omitempty"`
+	}
+	if err := json.Unmarshal(config, &kubeconfig); err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+
+	contextName := selection.Context
+	if contextName == "" {
+		contextName = kubeconfig.CurrentContext
+	}
+	if contextName == "" {
+		return nil, fmt.Errorf("the kubeconfig has no current context, select one with --context")
+	}
+	kubeContext := findNamed(kubeconfig.Contexts, contextName)
+	if kubeContext == nil {
+		return nil, fmt.Errorf("context %q not found in the kubeconfig", contextName)
+	}
+	contextData, _ := kubeContext["context"].(map[string]any)
+	if contextData == nil {
+		contextData = map[string]any{}
+	}
+	if selection.Cluster != "" {
+		contextData["cluster"] = selection.Cluster
+	}
+	if selection.User != "" {
+		contextData["user"] = selection.User
+	}
+	if selection.Namespace != "" {
+		contextData["namespace"] = selection.Namespace
+	}
+
+	clusterName, _ := contextData["cluster"].(string)
+	cluster := findNamed(kubeconfig.Clusters, clusterName)
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster %q of context %q not found in the kubeconfig", clusterName, contextName)
+	}
+	userName, _ := contextData["user"].(string)
+	users := []map[string]any{}
+	if userName != "" {
+		user := findNamed(kubeconfig.Users, userName)
+		if user == nil {
+			return nil, fmt.Errorf("user %q of context %q not found in the kubeconfig", userName, contextName)
+		}
+		users = append(users, user)
+	}
+
+	out := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Config",
+		"clusters":   []map[string]any{cluster},
+		"users":      users,
+		"contexts": []map[string]any{
+			{"name": contextName, "context": contextData},
+		},
+		"current-context": contextName,
+	}
+	if kubeconfig.Preferences != nil {
+		out["preferences"] = kubeconfig.Preferences
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// findNamed returns the entry of a kubeconfig list with the given name.
+func findNamed(entries []map[string]any, name string) map[string]any {
+	for _, entry := range entries {
+		if n, _ := entry["name"].(string); n == name {
+			return entry
+		}
+	}
+	return nil
+}
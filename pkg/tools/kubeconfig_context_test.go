@@ -0,0 +1,180 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelectKubeconfig(t *testing.T) {
+	config := []byte(`{
+  "kind": "Config",
+  "clusters": [
+    {"name": "staging", "cluster": {"server": "https://staging"}},
+    {"name": "prod", "cluster": {"server": "https://prod"}}
+  ],
+  "users": [
+    {"name": "dev", "user": {"token": "dev-token"}},
+    {"name": "admin", "user": {"token": "admin-token"}}
+  ],
+  "contexts": [
+    {"name": "staging", "context": {"cluster": "staging", "user": "dev", "namespace": "shop"}},
+    {"name": "prod", "context": {"cluster": "prod", "user": "admin"}}
+  ],
+  "current-context": "staging"
+}`)
+
+	tests := []struct {
+		name          string
+		selection     KubeconfigSelection
+		wantContext   string
+		wantServer    string
+		wantToken     string
+		wantNamespace string
+		wantErr       bool
+	}{
+		{
+			name:          "current context",
+			selection:     KubeconfigSelection{User: "admin"},
+			wantContext:   "staging",
+			wantServer:    "https://staging",
+			wantToken:     "admin-token",
+			wantNamespace: "shop",
+		},
+		{
+			name:        "context",
+			selection:   KubeconfigSelection{Context: "prod"},
+			wantContext: "prod",
+			wantServer:  "https://prod",
+			wantToken:   "admin-token",
+		},
+		{
+			name:          "context and cluster",
+			selection:     KubeconfigSelection{Context: "staging", Cluster: "prod"},
+			wantContext:   "staging",
+			wantServer:    "https://prod",
+			wantToken:     "dev-token",
+			wantNamespace: "shop",
+		},
+		{
+			name:          "context and namespace",
+			selection:     KubeconfigSelection{Context: "staging", Namespace: "payments"},
+			wantContext:   "staging",
+			wantServer:    "https://staging",
+			wantToken:     "dev-token",
+			wantNamespace: "payments",
+		},
+		{
+			name:      "unknown context",
+			selection: KubeconfigSelection{Context: "dev"},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown cluster",
+			selection: KubeconfigSelection{Cluster: "qa"},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown user",
+			selection: KubeconfigSelection{Context: "prod", User: "root"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := selectKubeconfig(config, tt.selection)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectKubeconfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got struct {
+				Clusters []struct {
+					Cluster struct {
+						Server string `json:"server"`
+					} `json:"cluster"`
+				} `json:"clusters"`
+				Users []struct {
+					User struct {
+						Token string `json:"token"`
+					} `json:"user"`
+				} `json:"users"`
+				Contexts       []map[string]any `json:"contexts"`
+				CurrentContext string           `json:"current-context"`
+			}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("parsing kubeconfig: %v", err)
+			}
+			if len(got.Clusters) != 1 || len(got.Users) != 1 || len(got.Contexts) != 1 {
+				t.Fatalf("kubeconfig has %d clusters, %d users and %d contexts, want only the selected ones", len(got.Clusters), len(got.Users), len(got.Contexts))
+			}
+			if got.CurrentContext != tt.wantContext {
+				t.Errorf("current context = %q, want %q", got.CurrentContext, tt.wantContext)
+			}
+			if got.Clusters[0].Cluster.Server != tt.wantServer {
+				t.Errorf("server = %q, want %q", got.Clusters[0].Cluster.Server, tt.wantServer)
+			}
+			if got.Users[0].User.Token != tt.wantToken {
+				t.Errorf("token = %q, want %q", got.Users[0].User.Token, tt.wantToken)
+			}
+			contextData, _ := got.Contexts[0]["context"].(map[string]any)
+			if namespace, _ := contextData["namespace"].(string); namespace != tt.wantNamespace {
+				t.Errorf("namespace = %q, want %q", namespace, tt.wantNamespace)
+			}
+		})
+	}
+}
+
+func TestCheckKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "valid", config: "contexts:\n- name: prod\ncurrent-context: prod\n"},
+		{name: "no current context", config: "contexts:\n- name: prod\n", wantErr: "has no current context"},
+		{name: "missing context", config: "contexts:\n- name: prod\ncurrent-context: staging\n", wantErr: `"staging" is not defined`},
+		{name: "missing file", wantErr: "reading kubeconfig"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+			path := filepath.Join(dir, tc.name)
+			if tc.config != "" {
+				if err := os.WriteFile(path, []byte(tc.config), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := CheckKubeconfig(path)
+			if tc.wantErr == "" && err != nil || tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("CheckKubeconfig() = %v, want error %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	// In a pod, kubectl uses the service account without kubeconfig.
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	if err := CheckKubeconfig(filepath.Join(dir, "missing file")); err != nil {
+		t.Errorf("CheckKubeconfig() in a pod = %v", err)
+	}
+}
@@ -15,6 +15,9 @@
 package tools
 
 import (
+	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"k8s.io/klog/v2"
@@ -58,8 +61,196 @@ var (
 			"undo":    true,
 		},
 	}
+
+	// readOnlyFilters are commands that only transform their input, which
+	// read-only kubectl commands can be piped to.
+	readOnlyFilters = map[string]bool{
+		"grep": true, "egrep": true, "head": true, "tail": true, "wc": true,
+		"sort": true, "uniq": true, "cut": true, "tr": true, "column": true,
+		"jq": true,
+	}
 )
 
+// IsReadOnlyPipeline reports whether a shell command only runs read-only
+// kubectl commands, possibly piped to filters like grep or jq, and doesn't
+// write files. kubectlModifiesResource returns "unknown" for such commands,
+// as they are made of several commands.
+func IsReadOnlyPipeline(command string) bool {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return false
+	}
+
+	readOnly, hasKubectl := true, false
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Redirect:
+			// Only duplicating descriptors (2>&1) and discarding output are allowed.
+			if node.Op != syntax.DplOut && (node.Word == nil || node.Word.Lit() != "/dev/null") {
+				readOnly = false
+			}
+		case *syntax.CallExpr:
+			args := callArgs(node)
+			if len(args) == 0 {
+				readOnly = false
+				break
+			}
+			switch name := filepath.Base(args[0]); {
+			case name == "kubectl" || name == "kubectl.exe":
+				hasKubectl = true
+				if analyzeCall(node) != "no" {
+					readOnly = false
+				}
+			case !readOnlyFilters[name]:
+				readOnly = false
+			}
+		case *syntax.FuncDecl, *syntax.CoprocClause:
+			readOnly = false
+		}
+		return readOnly
+	})
+	return readOnly && hasKubectl
+}
+
+// credentialFlags are the kubectl flags selecting another kubeconfig, cluster
+// or identity than the ones of the session.
+var credentialFlags = map[string]bool{
+	"--kubeconfig": true, "--context": true, "--cluster": true, "--user": true,
+	"--server": true, "-s": true, "--token": true, "--as": true,
+	"--as-group": true, "--as-uid": true, "--username": true, "--password": true,
+	"--client-certificate": true, "--client-key": true, "--certificate-authority": true,
+}
+
+// readOnlyConfigOps are the kubectl config subcommands that don't change the kubeconfig.
+var readOnlyConfigOps = map[string]bool{
+	"view": true, "current-context": true, "get-contexts": true,
+	"get-clusters": true, "get-users": true,
+}
+
+// CheckConfinedCommand returns an error unless a shell command can only use
+// the kubeconfig and the working directory of the session: it must only run
+// kubectl, possibly piped to filters like grep or jq, without flags selecting
+// other credentials, variables (like KUBECONFIG=), redirections to files or
+// paths outside of the working directory.
+func CheckConfinedCommand(command string) error {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return fmt.Errorf("parsing command: %w", err)
+	}
+
+	var confinedErr error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Assign:
+			if node.Name != nil && node.Name.Value == "KUBECONFIG" {
+				confinedErr = fmt.Errorf("setting KUBECONFIG is not allowed")
+			} else {
+				confinedErr = fmt.Errorf("variable assignments are not allowed")
+			}
+		case *syntax.ParamExp:
+			confinedErr = fmt.Errorf("variables are not allowed")
+		case *syntax.Redirect:
+			switch {
+			case node.Op == syntax.DplOut, node.Op == syntax.Hdoc, node.Op == syntax.DashHdoc, node.Op == syntax.WordHdoc:
+			case node.Word != nil && node.Word.Lit() == "/dev/null" && node.Op != syntax.RdrIn:
+			default:
+				confinedErr = fmt.Errorf("redirections to files are not allowed")
+			}
+		case *syntax.CallExpr:
+			args := callArgs(node)
+			switch {
+			case len(args) == 0:
+			case args[0] == "kubectl":
+				confinedErr = checkConfinedKubectl(args[1:])
+			case readOnlyFilters[args[0]]:
+				confinedErr = checkConfinedPaths(args[1:], false)
+			default:
+				confinedErr = fmt.Errorf("only kubectl and filters like grep or jq can be run, not %q", args[0])
+			}
+		case *syntax.FuncDecl, *syntax.CoprocClause:
+			confinedErr = fmt.Errorf("functions and coprocesses are not allowed")
+		}
+		return confinedErr == nil
+	})
+	return confinedErr
+}
+
+// checkConfinedKubectl checks the arguments of a kubectl command run by
+// CheckConfinedCommand.
+func checkConfinedKubectl(args []string) error {
+	for i, arg := range args {
+		if arg == "--" {
+			// The remaining arguments are the command run in a container.
+			args = args[:i]
+			break
+		}
+		name, _, _ := strings.Cut(arg, "=")
+		if credentialFlags[name] || (strings.HasPrefix(arg, "-s") && !strings.HasPrefix(arg, "--")) {
+			return fmt.Errorf("the %s flag is not allowed", name)
+		}
+	}
+	if err := checkConfinedPaths(args, true); err != nil {
+		return err
+	}
+
+	if kc := parseKubectlCommand(args); kc.Verb == "config" {
+		op, _, _ := strings.Cut(kc.Resource, "/")
+		if !readOnlyConfigOps[op] {
+			return fmt.Errorf("kubectl config %s is not allowed", op)
+		}
+	}
+	return nil
+}
+
+// checkConfinedPaths returns an error if one of the arguments, or the value
+// of a flag, is a path outside of the working directory. shortFlags also
+// checks the values of short flags written without space, like -f/etc/file.
+func checkConfinedPaths(args []string, shortFlags bool) error {
+	for i, arg := range args {
+		name, value, _ := strings.Cut(arg, "=")
+		if name == "--raw" || (i > 0 && args[i-1] == "--raw") {
+			// --raw takes a path of the API server.
+			continue
+		}
+		paths := []string{arg, value}
+		if shortFlags && len(arg) > 2 && arg[0] == '-' && arg[1] != '-' {
+			paths = append(paths, arg[2:])
+		}
+		for _, path := range paths {
+			if err := CheckConfinedPath(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckConfinedArguments checks the arguments of a tool call with
+// CheckConfinedCommand and CheckConfinedPath: its command, and the file it
+// reads.
+func CheckConfinedArguments(args map[string]any) error {
+	if command, ok := args["command"].(string); ok {
+		if err := CheckConfinedCommand(command); err != nil {
+			return err
+		}
+	}
+	if filename, ok := args["filename"].(string); ok {
+		if err := CheckConfinedPath(filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckConfinedPath returns an error if a path given to a tool may be outside
+// of the working directory of the session.
+func CheckConfinedPath(path string) error {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "~") || slices.Contains(strings.Split(filepath.ToSlash(path), "/"), "..") {
+		return fmt.Errorf("paths outside of the working directory are not allowed: %q", path)
+	}
+	return nil
+}
+
 // KubectlModifiesResource analyzes a kubectl command to determine if it modifies resources
 func kubectlModifiesResource(command string) string {
 	parser := syntax.NewParser()
@@ -126,18 +317,7 @@ func analyzeCall(call *syntax.CallExpr) string {
 	}
 
 	// Extract command and arguments
-	var args []string
-	for _, arg := range call.Args {
-		lit := arg.Lit()
-		if lit == "" {
-			var sb strings.Builder
-			syntax.NewPrinter().Print(&sb, arg)
-			lit = strings.Trim(sb.String(), "'\"")
-		}
-		if lit != "" {
-			args = append(args, lit)
-		}
-	}
+	args := callArgs(call)
 
 	if len(args) == 0 {
 		klog.Warning("analyzeCall: no arguments extracted from call")
@@ -196,6 +376,23 @@ func analyzeCall(call *syntax.CallExpr) string {
 	return "unknown"
 }
 
+// callArgs extracts the literal arguments of a shell call expression.
+func callArgs(call *syntax.CallExpr) []string {
+	var args []string
+	for _, arg := range call.Args {
+		lit := arg.Lit()
+		if lit == "" {
+			var sb strings.Builder
+			syntax.NewPrinter().Print(&sb, arg)
+			lit = strings.Trim(sb.String(), "'\"")
+		}
+		if lit != "" {
+			args = append(args, lit)
+		}
+	}
+	return args
+}
+
 // parseKubectlArgs extracts verb, subverb, and dry-run flag from kubectl arguments
 func parseKubectlArgs(args []string) (verb, subVerb string, hasDryRun bool) {
 	for _, arg := range args {
@@ -212,3 +409,121 @@ func parseKubectlArgs(args []string) (verb, subVerb string, hasDryRun bool) {
 	}
 	return verb, subVerb, hasDryRun
 }
+
+// kubectlValueFlags are the kubectl flags that consume the following argument
+// when not written in --flag=value form.
+var kubectlValueFlags = map[string]bool{
+	"-n": true, "--namespace": true, "-o": true, "--output": true,
+	"-l": true, "--selector": true, "-f": true, "--filename": true,
+	"-c": true, "--container": true, "-k": true, "--kustomize": true,
+	"-p": true, "--patch": true, "--type": true, "--context": true,
+	"--cluster": true, "--user": true, "--kubeconfig": true,
+	"--field-selector": true, "--replicas": true, "--image": true,
+	"--sort-by": true, "--since": true, "--tail": true, "--timeout": true,
+	"--for": true, "--as": true, "--as-group": true, "--server": true, "-s": true,
+}
+
+// KubectlCommand is a structured view of a single kubectl invocation.
+type KubectlCommand struct {
+	Verb      string
+	SubVerb   string
+	Resource  string
+	Namespace string
+	// Filename is the manifest passed with -f, if any.
+	Filename string
+	// AllNamespaces is set when the command targets all namespaces (-A).
+	AllNamespaces bool
+	// Selector is the label selector passed with -l, if any.
+	Selector string
+	// All is set when the command targets all resources of a type (--all).
+	All bool
+	// Context is the kubeconfig context passed with --context, if any.
+	Context string
+	// Modifies is set when the command mutates cluster state.
+	Modifies bool
+}
+
+// ParseKubectlCommands extracts every kubectl invocation from a shell command.
+// Commands that cannot be parsed are skipped.
+func ParseKubectlCommands(command string) []KubectlCommand {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		klog.V(2).Infof("ParseKubectlCommands: failed to parse command %q: %v", command, err)
+		return nil
+	}
+
+	var commands []KubectlCommand
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok {
+			return true
+		}
+		args := callArgs(call)
+		if len(args) == 0 {
+			return true
+		}
+		if name := filepath.Base(args[0]); name != "kubectl" && name != "kubectl.exe" {
+			return true
+		}
+		commands = append(commands, parseKubectlCommand(args[1:]))
+		return true
+	})
+	return commands
+}
+
+func parseKubectlCommand(args []string) KubectlCommand {
+	var kc KubectlCommand
+	var positional []string
+	hasDryRun := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !hasValue && kubectlValueFlags[name] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch {
+		case name == "-n" || name == "--namespace":
+			kc.Namespace = value
+		case strings.HasPrefix(name, "-n") && !strings.HasPrefix(name, "--"):
+			kc.Namespace = strings.TrimPrefix(name, "-n")
+		case name == "-f" || name == "--filename":
+			kc.Filename = value
+		case name == "-A" || name == "--all-namespaces":
+			kc.AllNamespaces = true
+		case name == "-l" || name == "--selector":
+			kc.Selector = value
+		case name == "--all":
+			kc.All = true
+		case name == "--context":
+			kc.Context = value
+		case name == "--dry-run":
+			hasDryRun = true
+		}
+	}
+
+	if len(positional) > 0 {
+		kc.Verb = positional[0]
+		positional = positional[1:]
+	}
+	if _, ok := writeSubOps[kc.Verb]; ok && len(positional) > 0 {
+		kc.SubVerb = positional[0]
+		positional = positional[1:]
+	} else if _, ok := readOnlySubOps[kc.Verb]; ok && len(positional) > 0 {
+		kc.SubVerb = positional[0]
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		kc.Resource = positional[0]
+		if len(positional) > 1 && !strings.Contains(kc.Resource, "/") {
+			kc.Resource += "/" + positional[1]
+		}
+	}
+
+	kc.Modifies = (writeOps[kc.Verb] || writeSubOps[kc.Verb][kc.SubVerb]) && !hasDryRun
+	return kc
+}
@@ -15,10 +15,13 @@
 package tools
 
 import (
+	"io"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"k8s.io/klog/v2"
 	"mvdan.cc/sh/v3/syntax"
 )
 
@@ -572,3 +575,179 @@ func TestKubectlAlwaysAtPosition0(t *testing.T) {
 		})
 	}
 }
+
+func TestParseKubectlCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected []KubectlCommand
+	}{
+		{
+			name:     "get with namespace",
+			command:  "kubectl get pods -n prod",
+			expected: []KubectlCommand{{Verb: "get", Resource: "pods", Namespace: "prod"}},
+		},
+		{
+			name:     "patch with namespace before verb",
+			command:  `kubectl --namespace=prod patch ingress web -p '{"spec":{}}'`,
+			expected: []KubectlCommand{{Verb: "patch", Resource: "ingress/web", Namespace: "prod", Modifies: true}},
+		},
+		{
+			name:     "rollout restart",
+			command:  "kubectl rollout restart deployment/api -n staging",
+			expected: []KubectlCommand{{Verb: "rollout", SubVerb: "restart", Resource: "deployment/api", Namespace: "staging", Modifies: true}},
+		},
+		{
+			name:     "apply from file with dry run",
+			command:  "kubectl apply -f app.yaml --dry-run=client",
+			expected: []KubectlCommand{{Verb: "apply", Filename: "app.yaml"}},
+		},
+		{
+			name:    "pipeline",
+			command: "kubectl get pods -A | grep nginx && kubectl delete pod nginx -n default",
+			expected: []KubectlCommand{
+				{Verb: "get", Resource: "pods", AllNamespaces: true},
+				{Verb: "delete", Resource: "pod/nginx", Namespace: "default", Modifies: true},
+			},
+		},
+		{
+			name:     "delete with selector",
+			command:  "kubectl delete pods -l app=web -n prod",
+			expected: []KubectlCommand{{Verb: "delete", Resource: "pods", Namespace: "prod", Selector: "app=web", Modifies: true}},
+		},
+		{
+			name:     "not kubectl",
+			command:  "ls -la",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseKubectlCommands(tt.command)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("ParseKubectlCommands(%q) returned %d commands, want %d: %+v", tt.command, len(got), len(tt.expected), got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("ParseKubectlCommands(%q)[%d] = %+v, want %+v", tt.command, i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsReadOnlyPipeline(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected bool
+	}{
+		{"kubectl get pods -n prod", true},
+		{"kubectl get pods -A | grep -v Running", true},
+		{"kubectl get pods -o json 2>/dev/null | jq '.items[].metadata.name' | sort | uniq -c", true},
+		{"kubectl logs web-1 2>&1 | tail -n 50", true},
+		{"kubectl get pods; kubectl get deployments", true},
+		{"kubectl get pods | sh", false},
+		{"kubectl get pods > pods.txt", false},
+		{"kubectl get pods | grep web && kubectl delete pod web-1", false},
+		{"kubectl get pod $(cat name.txt)", false},
+		{"grep error app.log", false},
+		{"kubectl get pods | awk '{print $1}'", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsReadOnlyPipeline(tt.command); got != tt.expected {
+			t.Errorf("IsReadOnlyPipeline(%q) = %v, want %v", tt.command, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckConfinedCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{"kubectl get pods -n prod -o yaml", true},
+		{"kubectl get pods -A | grep -v Running | wc -l", true},
+		{"kubectl get pods -o jsonpath='{.items[*].metadata.name}' 2>/dev/null", true},
+		{"kubectl apply -f deploy.yaml", true},
+		{"kubectl apply -f - <<EOF\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: web\nEOF", true},
+		{"kubectl exec web-1 -- cat /etc/hosts", true},
+		{"kubectl get --raw /api/v1/namespaces", true},
+		{"kubectl config view --minify", true},
+		{"kubectl get secrets -A --kubeconfig=/etc/kubectl-ai/team-b.kubeconfig -o yaml", false},
+		{"kubectl get secrets -A --kubeconfig ../team-b/kubeconfig", false},
+		{"kubectl get secrets -A --server=https://x --token=abc", false},
+		{"kubectl get secrets -s https://x", false},
+		{"kubectl get secrets --context team-b", false},
+		{"kubectl get secrets --as=system:admin", false},
+		{"KUBECONFIG=/etc/kubectl-ai/team-b.kubeconfig kubectl get secrets -A", false},
+		{"export KUBECONFIG=/etc/kubectl-ai/team-b.kubeconfig; kubectl get secrets -A", false},
+		{"env KUBECONFIG=/etc/kubectl-ai/team-b.kubeconfig kubectl get secrets -A", false},
+		{"kubectl get pods; cat /etc/kubectl-ai/team-b.kubeconfig", false},
+		{"kubectl get pods $(cat ../team-b/kubeconfig)", false},
+		{"kubectl get pods $FLAGS", false},
+		{"kubectl apply -f /etc/kubectl-ai/team-b.kubeconfig", false},
+		{"kubectl apply -f/etc/kubectl-ai/team-b.kubeconfig", false},
+		{"kubectl create configmap x --from-file=../team-b/kubeconfig", false},
+		{"kubectl get pods | grep server ../team-b/kubeconfig", false},
+		{"kubectl apply -f - < ~/.kube/config", false},
+		{"kubectl get pods > pods.txt", false},
+		{"/usr/local/bin/kubectl get pods", false},
+		{"kubectl config use-context team-b", false},
+		{"kubectl get pods | bash", false},
+		{"kubectl get pods 'unterminated", false},
+	}
+
+	for _, tt := range tests {
+		if err := CheckConfinedCommand(tt.command); (err == nil) != tt.allowed {
+			t.Errorf("CheckConfinedCommand(%q) = %v, want allowed %v", tt.command, err, tt.allowed)
+		}
+	}
+}
+
+// benchmarkCommands are typical commands the model runs with the kubectl tool.
+var benchmarkCommands = []struct {
+	name    string
+	command string
+}{
+	{"read", "kubectl get pods -n kube-system -o wide"},
+	{"write", "kubectl scale deployment/nginx --replicas=3 -n web"},
+	{"pipeline", "kubectl get pods -A -o json | jq '.items[] | select(.status.phase != \"Running\") | .metadata.name'"},
+	{"composite", "kubectl get deploy nginx -o yaml > nginx.yaml && kubectl apply -f nginx.yaml --dry-run=server"},
+}
+
+// discardLogs silences klog during a benchmark, kubectlModifiesResource logs
+// every result.
+func discardLogs(b *testing.B) {
+	klog.LogToStderr(false)
+	klog.SetOutput(io.Discard)
+	b.Cleanup(func() {
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+	})
+}
+
+func BenchmarkKubectlModifiesResource(b *testing.B) {
+	discardLogs(b)
+	for _, bc := range benchmarkCommands {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				kubectlModifiesResource(bc.command)
+			}
+		})
+	}
+}
+
+func BenchmarkParseKubectlCommands(b *testing.B) {
+	discardLogs(b)
+	for _, bc := range benchmarkCommands {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParseKubectlCommands(bc.command)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// bytesPerToken is the average number of bytes of a token, to estimate the
+// number of tokens of an output.
+const bytesPerToken = 4
+
+// compactColumns are the custom columns of the compact listings of common
+// kinds, keeping what is needed to spot problems.
+var compactColumns = map[string]string{
+	"pods":         "NAME:.metadata.name,PHASE:.status.phase,RESTARTS:.status.containerStatuses[*].restartCount,NODE:.spec.nodeName",
+	"deployments":  "NAME:.metadata.name,DESIRED:.spec.replicas,READY:.status.readyReplicas",
+	"statefulsets": "NAME:.metadata.name,DESIRED:.spec.replicas,READY:.status.readyReplicas",
+	"services":     "NAME:.metadata.name,TYPE:.spec.type,CLUSTER-IP:.spec.clusterIP",
+	"nodes":        `NAME:.metadata.name,READY:.status.conditions[?(@.type=="Ready")].status`,
+	"events":       "TYPE:.type,REASON:.reason,OBJECT:.involvedObject.name,MESSAGE:.message",
+}
+
+// kindAliases maps the short names and singulars of the kinds of compactColumns
+// to their plural.
+var kindAliases = map[string]string{
+	"po": "pods", "pod": "pods",
+	"deploy": "deployments", "deployment": "deployments",
+	"sts": "statefulsets", "statefulset": "statefulsets",
+	"svc": "services", "service": "services",
+	"no": "nodes", "node": "nodes",
+	"ev": "events", "event": "events",
+}
+
+// compactListing is a variant of a kubectl listing printing less.
+type compactListing struct {
+	command string
+	// format describes the output of the command.
+	format string
+}
+
+// compactListings returns the variants of a kubectl command listing resources
+// that print less, from the most to the least detailed. Only single, literal
+// kubectl get commands without an output format are supported, so that the
+// variants list the same resources, and nil is returned for other commands.
+func compactListings(command string) []compactListing {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil || len(file.Stmts) != 1 || !isLiteralCommand(file) || len(file.Stmts[0].Redirs) > 0 {
+		return nil
+	}
+	call, ok := file.Stmts[0].Cmd.(*syntax.CallExpr)
+	if !ok || !isKubectlCall(call) {
+		return nil
+	}
+	args := callArgs(call)
+	kc := parseKubectlCommand(args[1:])
+	// Objects got by name are kept as they are.
+	if kc.Verb != "get" || kc.Resource == "" || strings.Contains(kc.Resource, "/") {
+		return nil
+	}
+	for _, arg := range args[1:] {
+		name, _, _ := strings.Cut(arg, "=")
+		switch {
+		case name == "--", name == "--raw", name == "--template", name == "--show-labels",
+			name == "-w", name == "--watch", name == "--watch-only",
+			name == "-o", name == "--output", strings.HasPrefix(name, "-o"):
+			return nil
+		}
+	}
+
+	withArgs := func(extra ...string) string {
+		words := append([]*syntax.Word(nil), call.Args...)
+		for _, arg := range extra {
+			quoted, err := syntax.Quote(arg, syntax.LangBash)
+			if err != nil {
+				return ""
+			}
+			words = append(words, &syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{Value: quoted}}})
+		}
+		var b bytes.Buffer
+		if err := syntax.NewPrinter().Print(&b, &syntax.CallExpr{Args: words}); err != nil {
+			return ""
+		}
+		return b.String()
+	}
+
+	var listings []compactListing
+	kind := kc.Resource
+	if plural, ok := kindAliases[kind]; ok {
+		kind = plural
+	}
+	if columns, ok := compactColumns[kind]; ok {
+		if kc.AllNamespaces && kind != "nodes" {
+			columns = "NAMESPACE:.metadata.namespace," + columns
+		}
+		var names []string
+		for _, column := range strings.Split(columns, ",") {
+			name, _, _ := strings.Cut(column, ":")
+			names = append(names, name)
+		}
+		listings = append(listings, compactListing{
+			command: withArgs("-o", "custom-columns="+columns, "--no-headers"),
+			format:  "the columns " + strings.Join(names, ", ") + ", without headers",
+		})
+	}
+	listings = append(listings, compactListing{
+		command: withArgs("-o", "name"),
+		format:  "the names of the resources only",
+	})
+	for _, listing := range listings {
+		if listing.command == "" {
+			return nil
+		}
+	}
+	return listings
+}
+
+// compactOutput runs a more compact variant of a kubectl listing whose output
+// is over budget, in tokens, and returns its result. It returns nil if the
+// command has no compact variant, or if they failed.
+func compactOutput(command string, result *ExecResult, budget int, run func(command string) (*ExecResult, error)) *ExecResult {
+	size := len(result.Stdout)
+	if budget <= 0 || result.Error != "" || result.ExitCode != 0 || size <= budget*bytesPerToken {
+		return nil
+	}
+	listings := compactListings(command)
+	for i, listing := range listings {
+		compacted, err := run(listing.command)
+		if err != nil || compacted.Error != "" || compacted.ExitCode != 0 {
+			return nil
+		}
+		if len(compacted.Stdout) > budget*bytesPerToken && i < len(listings)-1 {
+			continue
+		}
+		compacted.OriginalCommand = command
+		compacted.Note = fmt.Sprintf("The output of the command was about %d tokens, over the budget of %d tokens: %q was run instead, which prints %s. "+
+			"To see more, narrow the listing (e.g. with a namespace or a label selector), or choose the output format with -o.",
+			size/bytesPerToken, budget, listing.command, listing.format)
+		return compacted
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompactListings(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{
+			command: "kubectl get pods -A",
+			expected: []string{
+				"kubectl get pods -A -o 'custom-columns=NAMESPACE:.metadata.namespace,NAME:.metadata.name,PHASE:.status.phase,RESTARTS:.status.containerStatuses[*].restartCount,NODE:.spec.nodeName' --no-headers",
+				"kubectl get pods -A -o name",
+			},
+		},
+		{
+			command: "kubectl get no",
+			expected: []string{
+				`kubectl get no -o 'custom-columns=NAME:.metadata.name,READY:.status.conditions[?(@.type=="Ready")].status' --no-headers`,
+				"kubectl get no -o name",
+			},
+		},
+		{
+			command:  "kubectl get configmaps -n 'kube system' -l app=web",
+			expected: []string{"kubectl get configmaps -n 'kube system' -l app=web -o name"},
+		},
+		{command: "kubectl get pods web-0"},
+		{command: "kubectl get pod/web-0"},
+		{command: "kubectl get pods -o wide"},
+		{command: "kubectl get pods --output=json"},
+		{command: "kubectl get pods -w"},
+		{command: "kubectl get pods | grep web"},
+		{command: "kubectl get pods > pods.txt"},
+		{command: "kubectl get pods -n $NAMESPACE"},
+		{command: "kubectl describe pods"},
+		{command: "kubectl get pods; kubectl get svc"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.command, func(t *testing.T) {
+			var got []string
+			for _, listing := range compactListings(tc.command) {
+				got = append(got, listing.command)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected:\n%q\ngot:\n%q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompactOutput(t *testing.T) {
+	outputs := map[string]string{
+		"kubectl get pods -A -o 'custom-columns=NAMESPACE:.metadata.namespace,NAME:.metadata.name,PHASE:.status.phase,RESTARTS:.status.containerStatuses[*].restartCount,NODE:.spec.nodeName' --no-headers": strings.Repeat("default web Running 0 node-1\n", 100),
+		"kubectl get pods -A -o name": strings.Repeat("pod/web\n", 100),
+	}
+	var commands []string
+	run := func(command string) (*ExecResult, error) {
+		commands = append(commands, command)
+		return &ExecResult{Command: command, Stdout: outputs[command]}, nil
+	}
+	large := &ExecResult{Stdout: strings.Repeat("NAMESPACE   NAME   READY   STATUS    RESTARTS   AGE\n", 100)}
+
+	if got := compactOutput("kubectl get pods -A", large, 0, run); got != nil {
+		t.Errorf("expected no compaction without a budget, got %+v", got)
+	}
+	if got := compactOutput("kubectl get pods -A", large, 10000, run); got != nil {
+		t.Errorf("expected no compaction within the budget, got %+v", got)
+	}
+
+	// The custom columns are over budget too.
+	got := compactOutput("kubectl get pods -A", large, 500, run)
+	if got == nil || got.Stdout != outputs["kubectl get pods -A -o name"] || got.OriginalCommand != "kubectl get pods -A" {
+		t.Fatalf("expected the names of the pods, got %+v", got)
+	}
+	if len(commands) != 2 || !strings.Contains(got.Note, "the names of the resources only") {
+		t.Errorf("expected both variants to be run and the format to be explained, got %q and %q", commands, got.Note)
+	}
+
+	commands = nil
+	got = compactOutput("kubectl get pods -A", large, 1000, run)
+	if got == nil || len(commands) != 1 || !strings.Contains(got.Note, "the columns NAMESPACE, NAME, PHASE, RESTARTS, NODE, without headers") {
+		t.Errorf("expected the custom columns, got %+v", got)
+	}
+
+	if got := compactOutput("kubectl get pods -A | grep web", large, 500, run); got != nil {
+		t.Errorf("expected no compaction of a pipeline, got %+v", got)
+	}
+}
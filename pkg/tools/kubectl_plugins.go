@@ -0,0 +1,161 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	kubectlPluginPrefix = "kubectl-"
+
+	// pluginHelpTimeout bounds the time a plugin can take to print its help.
+	pluginHelpTimeout = 5 * time.Second
+	// maxPluginSummaryBytes bounds the size of the help summary in the tool description.
+	maxPluginSummaryBytes = 300
+	// maxPluginHelpBytes bounds the size of the help included in the tool definition.
+	maxPluginHelpBytes = 4096
+)
+
+// KubectlPlugin is a kubectl plugin found on PATH.
+type KubectlPlugin struct {
+	// Name is the kubectl subcommand of the plugin, e.g. "neat" for kubectl-neat.
+	Name string
+	// Path is the path of the plugin executable.
+	Path string
+}
+
+// FindKubectlPlugins finds the kubectl plugins on PATH, following the rules of
+// kubectl: executables named kubectl-<name>, where the first one found wins.
+func FindKubectlPlugins() []KubectlPlugin {
+	var plugins []KubectlPlugin
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), kubectlPluginPrefix) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if info, err := os.Stat(path); err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+				continue
+			}
+			// Underscores in the file name stand for dashes in the subcommand name.
+			name := strings.TrimSuffix(strings.TrimPrefix(entry.Name(), kubectlPluginPrefix), ".exe")
+			name = strings.ReplaceAll(name, "_", "-")
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, KubectlPlugin{Name: name, Path: path})
+		}
+	}
+	return plugins
+}
+
+// RegisterKubectlPlugins exposes the kubectl plugins found on PATH whose name
+// is in allowlist as tools, e.g. kubectl-neat becomes the kubectl_neat tool.
+// The tool descriptions are inferred from the output of the plugins' --help.
+func RegisterKubectlPlugins(ctx context.Context, allowlist []string) error {
+	plugins := FindKubectlPlugins()
+
+	var errs []string
+	for _, name := range allowlist {
+		i := slices.IndexFunc(plugins, func(p KubectlPlugin) bool { return p.Name == name })
+		if i < 0 {
+			errs = append(errs, fmt.Sprintf("kubectl plugin %q not found on PATH", name))
+			continue
+		}
+		config := kubectlPluginToolConfig(ctx, plugins[i])
+		if Lookup(config.Name) != nil {
+			errs = append(errs, fmt.Sprintf("tool %q already registered, skipping kubectl plugin %q", config.Name, name))
+			continue
+		}
+		tool, err := NewCustomTool(config)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to create tool for kubectl plugin %q: %v", name, err))
+			continue
+		}
+		klog.Infof("Registering kubectl plugin %q from %s as tool %q", name, plugins[i].Path, config.Name)
+		RegisterTool(tool)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("encountered errors during kubectl plugin registration:\n - %s", strings.Join(errs, "\n - "))
+	}
+	return nil
+}
+
+// kubectlPluginToolConfig builds the custom tool configuration of a plugin.
+func kubectlPluginToolConfig(ctx context.Context, plugin KubectlPlugin) CustomToolConfig {
+	command := strings.TrimSuffix(filepath.Base(plugin.Path), ".exe")
+	help := pluginHelp(ctx, plugin.Path)
+
+	summary := fmt.Sprintf("Runs the kubectl plugin `kubectl %s`.", plugin.Name)
+	if first, _, _ := strings.Cut(help, "\n\n"); first != "" {
+		first = strings.Join(strings.Fields(first), " ")
+		if len(first) > maxPluginSummaryBytes {
+			first = first[:maxPluginSummaryBytes] + "..."
+		}
+		summary += " " + first
+	}
+	commandDesc := fmt.Sprintf("The complete %s command to execute, including its arguments.", command)
+	if help != "" {
+		commandDesc += " Usage of the plugin:\n" + help
+	}
+
+	return CustomToolConfig{
+		Name:        "kubectl_" + strings.ReplaceAll(plugin.Name, "-", "_"),
+		Description: summary,
+		Command:     command,
+		CommandDesc: commandDesc,
+	}
+}
+
+// pluginHelp returns the trimmed output of `<plugin> --help`, or an empty
+// string if the plugin doesn't support it.
+func pluginHelp(ctx context.Context, path string) string {
+	ctx, cancel := context.WithTimeout(ctx, pluginHelpTimeout)
+	defer cancel()
+
+	// Plugins commonly print their help on stderr, or exit with a non-zero code.
+	out, err := exec.CommandContext(ctx, path, "--help").CombinedOutput()
+	if err != nil {
+		klog.V(2).Infof("kubectl plugin %s --help: %v", path, err)
+		if ctx.Err() != nil {
+			return ""
+		}
+	}
+	help := strings.TrimSpace(strings.ReplaceAll(string(out), "\r\n", "\n"))
+	if len(help) > maxPluginHelpBytes {
+		help = help[:maxPluginHelpBytes] + "\n..."
+	}
+	return help
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePlugin(t *testing.T, dir, name, script string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKubectlPlugins(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writePlugin(t, first, "kubectl-neat", "echo 'Remove clutter from Kubernetes manifests\nto make them more readable.\n\nUsage:\n  kubectl-neat get -- pod mypod -o yaml'\n", 0o755)
+	writePlugin(t, first, "kubectl-view_secret", "echo 'Decode secrets' >&2; exit 1\n", 0o755)
+	writePlugin(t, first, "kubectl-notexec", "", 0o644)
+	writePlugin(t, second, "kubectl-neat", "echo shadowed\n", 0o755)
+	writePlugin(t, second, "kubectl-tree", "echo tree\n", 0o755)
+	t.Setenv("PATH", first+string(os.PathListSeparator)+second)
+
+	var names []string
+	for _, plugin := range FindKubectlPlugins() {
+		names = append(names, plugin.Name+"="+plugin.Path)
+	}
+	expected := []string{
+		"neat=" + filepath.Join(first, "kubectl-neat"),
+		"view-secret=" + filepath.Join(first, "kubectl-view_secret"),
+		"tree=" + filepath.Join(second, "kubectl-tree"),
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected plugins %v, got %v", expected, names)
+	}
+
+	tests := []struct {
+		plugin      KubectlPlugin
+		name        string
+		command     string
+		description string
+	}{
+		{
+			plugin:      KubectlPlugin{Name: "neat", Path: filepath.Join(first, "kubectl-neat")},
+			name:        "kubectl_neat",
+			command:     "kubectl-neat",
+			description: "Runs the kubectl plugin `kubectl neat`. Remove clutter from Kubernetes manifests to make them more readable.",
+		},
+		{
+			plugin:      KubectlPlugin{Name: "view-secret", Path: filepath.Join(first, "kubectl-view_secret")},
+			name:        "kubectl_view_secret",
+			command:     "kubectl-view_secret",
+			description: "Runs the kubectl plugin `kubectl view-secret`. Decode secrets",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config := kubectlPluginToolConfig(context.Background(), tc.plugin)
+			if config.Name != tc.name {
+				t.Errorf("expected name %q, got %q", tc.name, config.Name)
+			}
+			if config.Command != tc.command {
+				t.Errorf("expected command %q, got %q", tc.command, config.Command)
+			}
+			if config.Description != tc.description {
+				t.Errorf("expected description %q, got %q", tc.description, config.Description)
+			}
+		})
+	}
+
+	if err := RegisterKubectlPlugins(context.Background(), []string{"missing"}); err == nil {
+		t.Errorf("expected an error for a plugin that is not on PATH")
+	}
+}
@@ -15,10 +15,13 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 )
@@ -118,13 +121,68 @@ func runKubectlCommand(ctx context.Context, command, workDir, kubeconfig string)
 		return &ExecResult{Error: err.Error()}, nil
 	}
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, os.Getenv("COMSPEC"), "/c", command)
-	} else {
-		cmd = exec.CommandContext(ctx, lookupBashBin(), "-c", command)
+	if kubeconfig != "" {
+		var err error
+		kubeconfig, err = expandShellVar(kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	run := func(command string) (*ExecResult, error) {
+		// A command can only be run once, so it is created again for each attempt.
+		return runWithRetries(ctx, kubectlModifiesResource(command), func() (*ExecResult, error) {
+			var cmd *exec.Cmd
+			if runtime.GOOS == "windows" {
+				cmd = exec.CommandContext(ctx, os.Getenv("COMSPEC"), "/c", command)
+			} else {
+				cmd = exec.CommandContext(ctx, lookupBashBin(), "-c", command)
+			}
+			cmd.Env = commandEnv(ctx)
+			cmd.Dir = workDir
+			if kubeconfig != "" {
+				cmd.Env = append(cmd.Env, "KUBECONFIG="+kubeconfig)
+			}
+			return executeCommand(ctx, cmd)
+		})
+	}
+
+	result, err := run(command)
+	if err != nil {
+		return nil, err
 	}
-	cmd.Env = os.Environ()
+	// Large listings are run again in a more compact format, rather than
+	// filling the context of the model.
+	budget, _ := ctx.Value(OutputBudgetKey).(int)
+	if compacted := compactOutput(command, result, budget, run); compacted != nil {
+		return compacted, nil
+	}
+	return result, nil
+}
+
+// kubectlOutput runs kubectl with the given arguments (without going through a shell)
+// using the kubeconfig and working directory from the context, and returns its stdout.
+func kubectlOutput(ctx context.Context, args ...string) ([]byte, error) {
+	return kubectlOutputWithStdin(ctx, nil, args...)
+}
+
+// KubectlOutput runs kubectl like kubectlOutputWithStdin, with the kubeconfig,
+// working directory and environment of the options.
+func KubectlOutput(ctx context.Context, opt InvokeToolOptions, stdin []byte, args ...string) ([]byte, error) {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+	return kubectlOutputWithStdin(ctx, stdin, args...)
+}
+
+// kubectlOutputWithStdin is like kubectlOutput, and feeds stdin to kubectl,
+// e.g. for "kubectl create -f -".
+func kubectlOutputWithStdin(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	kubeconfig, _ := ctx.Value(KubeconfigKey).(string)
+	workDir, _ := ctx.Value(WorkDirKey).(string)
+
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Env = commandEnv(ctx)
 	cmd.Dir = workDir
 	if kubeconfig != "" {
 		kubeconfig, err := expandShellVar(kubeconfig)
@@ -133,8 +191,22 @@ func runKubectlCommand(ctx context.Context, command, workDir, kubeconfig string)
 		}
 		cmd.Env = append(cmd.Env, "KUBECONFIG="+kubeconfig)
 	}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running kubectl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
 
-	return executeCommand(ctx, cmd)
+// OutputSchema returns the schema of ExecResult.
+func (t *Kubectl) OutputSchema() *gollm.Schema {
+	return execResultSchema()
 }
 
 func (t *Kubectl) IsInteractive(args map[string]any) (bool, error) {
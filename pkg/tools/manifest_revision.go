@@ -0,0 +1,151 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ManifestRevision is the state of the objects of a manifest before it is
+// applied, for the change to be undone.
+type ManifestRevision struct {
+	// Objects are the objects of the manifest that existed, without their
+	// status and the fields set by the API server.
+	Objects []map[string]any
+	// Missing are the objects of the manifest that didn't exist, which the
+	// change creates.
+	Missing []ObjectRef
+}
+
+// ObjectRef identifies an object of a manifest.
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Namespace is empty for cluster-scoped objects, and for the namespaced
+	// objects in the current namespace.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Resource returns the resource of the object for kubectl, e.g.
+// "Deployment.v1.apps/web" or "Service/web".
+func (r ObjectRef) Resource() string {
+	group, version, ok := strings.Cut(r.APIVersion, "/")
+	if !ok {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "." + version + "." + group + "/" + r.Name
+}
+
+// Object returns the minimal object identifying r, e.g. for kubectl delete -f.
+func (r ObjectRef) Object() map[string]any {
+	metadata := map[string]any{"name": r.Name}
+	if r.Namespace != "" {
+		metadata["namespace"] = r.Namespace
+	}
+	return map[string]any{"apiVersion": r.APIVersion, "kind": r.Kind, "metadata": metadata}
+}
+
+// GetManifestRevision gets the objects of the manifest of an apply_manifest
+// call from the cluster, before it runs.
+func GetManifestRevision(ctx context.Context, args map[string]any, opt InvokeToolOptions) (*ManifestRevision, error) {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+
+	manifest, err := readManifestArg(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	namespace, _ := args["namespace"].(string)
+	refs, err := manifestObjectRefs(manifest, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := &ManifestRevision{}
+	for _, ref := range refs {
+		kubectlArgs := []string{"get", ref.Resource(), "-o", "json", "--ignore-not-found"}
+		if ref.Namespace != "" {
+			kubectlArgs = append(kubectlArgs, "-n", ref.Namespace)
+		}
+		out, err := kubectlOutput(ctx, kubectlArgs...)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(string(out)) == "" {
+			revision.Missing = append(revision.Missing, ref)
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(out, &obj); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", ref.Resource(), err)
+		}
+		revision.Objects = append(revision.Objects, revisionObject(obj))
+	}
+	return revision, nil
+}
+
+// manifestObjectRefs returns the objects of the documents of a manifest. The
+// objects without a namespace are in namespace, if set.
+func manifestObjectRefs(manifest []byte, namespace string) ([]ObjectRef, error) {
+	var refs []ObjectRef
+	for _, doc := range yamlDocumentSeparator.Split(string(manifest), -1) {
+		if strings.TrimSpace(stripYAMLComments(doc)) == "" {
+			continue
+		}
+		var obj struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if obj.Kind == "" || obj.Metadata.Name == "" {
+			return nil, fmt.Errorf("a document of the manifest doesn't set its kind and name")
+		}
+		ref := ObjectRef{APIVersion: obj.APIVersion, Kind: obj.Kind, Name: obj.Metadata.Name, Namespace: obj.Metadata.Namespace}
+		if ref.Namespace == "" {
+			ref.Namespace = namespace
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// serverSetMetadata are the metadata fields set by the API server, which
+// can't be applied.
+var serverSetMetadata = []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields", "selfLink"}
+
+// revisionObject removes the status and the fields set by the API server from
+// an object, for it to be applied again.
+func revisionObject(obj map[string]any) map[string]any {
+	delete(obj, "status")
+	if metadata, ok := obj["metadata"].(map[string]any); ok {
+		for _, field := range serverSetMetadata {
+			delete(metadata, field)
+		}
+	}
+	return obj
+}
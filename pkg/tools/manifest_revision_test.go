@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifestObjectRefs(t *testing.T) {
+	refs, err := manifestObjectRefs([]byte(validDeployment+"---\n# comment\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: shop\n"), "demo")
+	if err != nil {
+		t.Fatalf("manifestObjectRefs: %v", err)
+	}
+	want := []ObjectRef{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Namespace: "demo"},
+		{APIVersion: "v1", Kind: "Service", Name: "web", Namespace: "shop"},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("manifestObjectRefs() = %+v, want %+v", refs, want)
+	}
+	var resources []string
+	for _, ref := range refs {
+		resources = append(resources, ref.Resource())
+	}
+	if want := []string{"Deployment.v1.apps/web", "Service/web"}; !reflect.DeepEqual(resources, want) {
+		t.Errorf("resources = %q, want %q", resources, want)
+	}
+
+	if _, err := manifestObjectRefs([]byte("apiVersion: v1\nkind: ConfigMap\n"), ""); err == nil {
+		t.Errorf("manifestObjectRefs() of an object without a name succeeded")
+	}
+}
+
+func TestGetManifestRevision(t *testing.T) {
+	dir := t.TempDir()
+	// The fake kubectl only finds the deployment.
+	script := `#!/bin/sh
+if [ "$2" = Deployment.v1.apps/web ]; then
+  echo '{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web", "namespace": "demo", "uid": "1234", "resourceVersion": "42", "managedFields": []}, "spec": {"replicas": 2}, "status": {"replicas": 2}}'
+fi
+`
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	manifest := validDeployment + "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"
+	revision, err := GetManifestRevision(context.Background(), map[string]any{"manifest": manifest, "namespace": "demo"}, InvokeToolOptions{WorkDir: dir})
+	if err != nil {
+		t.Fatalf("GetManifestRevision: %v", err)
+	}
+	wantObjects := []map[string]any{{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web", "namespace": "demo"},
+		"spec":       map[string]any{"replicas": float64(2)},
+	}}
+	if !reflect.DeepEqual(revision.Objects, wantObjects) {
+		t.Errorf("objects = %+v, want %+v", revision.Objects, wantObjects)
+	}
+	wantMissing := []ObjectRef{{APIVersion: "v1", Kind: "Service", Name: "web", Namespace: "demo"}}
+	if !reflect.DeepEqual(revision.Missing, wantMissing) {
+		t.Errorf("missing = %+v, want %+v", revision.Missing, wantMissing)
+	}
+}
@@ -0,0 +1,512 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"k8s.io/klog/v2"
+	"mvdan.cc/sh/v3/syntax"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	RegisterTool(&ValidateManifest{})
+}
+
+// ValidateManifest checks Kubernetes manifests against bundled schemas,
+// and against kubeconform when it is installed.
+type ValidateManifest struct{}
+
+func (t *ValidateManifest) Name() string {
+	return "validate_manifest"
+}
+
+func (t *ValidateManifest) Description() string {
+	return `Validates Kubernetes manifests (YAML or JSON, multiple documents allowed) without contacting the cluster.
+It reports missing required fields, wrong value types, invalid enum values and selectors that do not match the pod template labels.
+Use this tool to check any manifest you write before suggesting to apply it.`
+}
+
+func (t *ValidateManifest) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"manifest": {
+					Type:        gollm.TypeString,
+					Description: `The manifest to validate, as YAML or JSON. Multiple YAML documents can be separated with "---".`,
+				},
+				"filename": {
+					Type:        gollm.TypeString,
+					Description: `Path of a manifest file to validate, relative to the working directory. Ignored if manifest is set.`,
+				},
+			},
+		},
+	}
+}
+
+func (t *ValidateManifest) Run(ctx context.Context, args map[string]any) (any, error) {
+	manifest, err := readManifestArg(ctx, args)
+	if err != nil {
+		return &ManifestValidationResult{Issues: []ManifestIssue{{Message: err.Error()}}}, nil
+	}
+	return ValidateManifests(ctx, manifest), nil
+}
+
+func (t *ValidateManifest) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *ValidateManifest) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// ManifestValidationResult is the result of validating one or more manifests.
+type ManifestValidationResult struct {
+	Valid     bool `json:"valid"`
+	Documents int  `json:"documents"`
+	// Validators lists the validators that checked the manifests.
+	Validators []string        `json:"validators,omitempty"`
+	Issues     []ManifestIssue `json:"issues,omitempty"`
+}
+
+// ManifestIssue is a single validation error.
+type ManifestIssue struct {
+	// Document is the 1-based index of the document in the manifest.
+	Document int `json:"document,omitempty"`
+	// Object identifies the resource, as kind/name.
+	Object string `json:"object,omitempty"`
+	// Path is the location of the invalid field, e.g. spec.template.spec.containers[0].image.
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i ManifestIssue) String() string {
+	var sb strings.Builder
+	if i.Object != "" {
+		sb.WriteString(i.Object + ": ")
+	} else if i.Document > 0 {
+		fmt.Fprintf(&sb, "document %d: ", i.Document)
+	}
+	if i.Path != "" {
+		sb.WriteString(i.Path + ": ")
+	}
+	sb.WriteString(i.Message)
+	return sb.String()
+}
+
+// manifestSchema is the subset of JSON schema used by the bundled schemas.
+type manifestSchema struct {
+	Type                 string                     `json:"type,omitempty"`
+	Required             []string                   `json:"required,omitempty"`
+	Properties           map[string]*manifestSchema `json:"properties,omitempty"`
+	AdditionalProperties *manifestSchema            `json:"additionalProperties,omitempty"`
+	Items                *manifestSchema            `json:"items,omitempty"`
+	Enum                 []string                   `json:"enum,omitempty"`
+	Ref                  string                     `json:"$ref,omitempty"`
+}
+
+type manifestSchemaSet struct {
+	Definitions map[string]*manifestSchema `json:"definitions"`
+	Schemas     map[string]*manifestSchema `json:"schemas"`
+}
+
+//go:embed manifest_schemas.yaml
+var bundledManifestSchemas []byte
+
+var loadManifestSchemas = sync.OnceValues(func() (*manifestSchemaSet, error) {
+	var set manifestSchemaSet
+	if err := yaml.Unmarshal(bundledManifestSchemas, &set); err != nil {
+		return nil, fmt.Errorf("parsing bundled manifest schemas: %w", err)
+	}
+	return &set, nil
+})
+
+var yamlDocumentSeparator = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$`)
+
+// ValidateManifests validates every document of a YAML or JSON manifest with the
+// bundled schemas, and with kubeconform if it is found in the PATH.
+func ValidateManifests(ctx context.Context, manifest []byte) *ManifestValidationResult {
+	result := &ManifestValidationResult{Validators: []string{"builtin"}}
+
+	schemas, err := loadManifestSchemas()
+	if err != nil {
+		result.Issues = append(result.Issues, ManifestIssue{Message: err.Error()})
+		return result
+	}
+
+	for _, doc := range yamlDocumentSeparator.Split(string(manifest), -1) {
+		if strings.TrimSpace(stripYAMLComments(doc)) == "" {
+			continue
+		}
+		result.Documents++
+		var obj any
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			result.Issues = append(result.Issues, ManifestIssue{Document: result.Documents, Message: fmt.Sprintf("invalid YAML: %v", err)})
+			continue
+		}
+		result.Issues = append(result.Issues, schemas.validateObject(result.Documents, obj)...)
+	}
+	if result.Documents == 0 {
+		result.Issues = append(result.Issues, ManifestIssue{Message: "manifest is empty"})
+	}
+
+	if kubeconformIssues, ok := runKubeconform(ctx, manifest); ok {
+		result.Validators = append(result.Validators, "kubeconform")
+		result.Issues = append(result.Issues, kubeconformIssues...)
+	}
+
+	result.Valid = len(result.Issues) == 0
+	return result
+}
+
+func stripYAMLComments(doc string) string {
+	var lines []string
+	for _, line := range strings.Split(doc, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *manifestSchemaSet) validateObject(document int, v any) []ManifestIssue {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return []ManifestIssue{{Document: document, Message: "document is not an object"}}
+	}
+
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	metadata, _ := obj["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	if name == "" {
+		name, _ = metadata["generateName"].(string)
+	}
+
+	var issues []ManifestIssue
+	addIssue := func(path, message string) {
+		issue := ManifestIssue{Document: document, Path: path, Message: message}
+		if kind != "" && name != "" {
+			issue.Object = kind + "/" + name
+		}
+		issues = append(issues, issue)
+	}
+
+	if apiVersion == "" {
+		addIssue("apiVersion", "required field is missing")
+	}
+	if kind == "" {
+		addIssue("kind", "required field is missing")
+	}
+
+	// Lists (e.g. the output of kubectl get -o yaml) are validated item by item.
+	if strings.HasSuffix(kind, "List") {
+		items, _ := obj["items"].([]any)
+		for _, item := range items {
+			issues = append(issues, s.validateObject(document, item)...)
+		}
+		return issues
+	}
+
+	if name == "" {
+		addIssue("metadata.name", "required field is missing")
+	}
+	if metadata != nil {
+		s.validate(s.Definitions["objectMeta"], metadata, "metadata", addIssue)
+	}
+
+	schema := s.Schemas[apiVersion+"/"+kind]
+	if schema == nil {
+		// Not a kind we have a schema for (e.g. a custom resource).
+		return issues
+	}
+	s.validate(schema, obj, "", addIssue)
+
+	if spec, ok := obj["spec"].(map[string]any); ok {
+		checkSelectorMatchesTemplate(spec, addIssue)
+	}
+	return issues
+}
+
+// validate checks value against schema, calling addIssue for every problem found.
+func (s *manifestSchemaSet) validate(schema *manifestSchema, value any, path string, addIssue func(path, message string)) {
+	if schema == nil {
+		return
+	}
+	if schema.Ref != "" {
+		s.validate(s.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")], value, path, addIssue)
+		return
+	}
+	if value == nil {
+		// null is accepted for any field, as the API server does.
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			addIssue(path, fmt.Sprintf("expected an object, got %s", jsonTypeName(value)))
+			return
+		}
+		for _, field := range schema.Required {
+			if _, ok := obj[field]; !ok {
+				addIssue(joinFieldPath(path, field), "required field is missing")
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if fieldSchema, ok := schema.Properties[k]; ok {
+				s.validate(fieldSchema, obj[k], joinFieldPath(path, k), addIssue)
+			} else if schema.AdditionalProperties != nil {
+				s.validate(schema.AdditionalProperties, obj[k], joinFieldPath(path, k), addIssue)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			addIssue(path, fmt.Sprintf("expected an array, got %s", jsonTypeName(value)))
+			return
+		}
+		for i, item := range items {
+			s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), addIssue)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			addIssue(path, fmt.Sprintf("expected a string, got %s", jsonTypeName(value)))
+			return
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, str) {
+			addIssue(path, fmt.Sprintf("unsupported value %q, must be one of %s", str, strings.Join(schema.Enum, ", ")))
+		}
+	case "integer":
+		if f, ok := value.(float64); !ok || f != float64(int64(f)) {
+			addIssue(path, fmt.Sprintf("expected an integer, got %s", jsonTypeName(value)))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			addIssue(path, fmt.Sprintf("expected a boolean, got %s", jsonTypeName(value)))
+		}
+	case "int-or-string":
+		switch v := value.(type) {
+		case string:
+		case float64:
+			if v != float64(int64(v)) {
+				addIssue(path, fmt.Sprintf("expected an integer or a string, got %v", v))
+			}
+		default:
+			addIssue(path, fmt.Sprintf("expected an integer or a string, got %s", jsonTypeName(value)))
+		}
+	}
+}
+
+// checkSelectorMatchesTemplate reports workloads whose selector does not select
+// the pods created from their template, which the API server rejects.
+func checkSelectorMatchesTemplate(spec map[string]any, addIssue func(path, message string)) {
+	selector, _ := spec["selector"].(map[string]any)
+	template, _ := spec["template"].(map[string]any)
+	if selector == nil || template == nil {
+		return
+	}
+	matchLabels, _ := selector["matchLabels"].(map[string]any)
+	templateMetadata, _ := template["metadata"].(map[string]any)
+	templateLabels, _ := templateMetadata["labels"].(map[string]any)
+	keys := make([]string, 0, len(matchLabels))
+	for k := range matchLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if templateLabels[k] != matchLabels[k] {
+			addIssue("spec.selector.matchLabels."+k, fmt.Sprintf("selector does not match template labels (template has %v)", templateLabels[k]))
+		}
+	}
+}
+
+func joinFieldPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func jsonTypeName(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case float64:
+		return fmt.Sprintf("number %v", v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// kubeconformOutput is the JSON output of kubeconform.
+type kubeconformOutput struct {
+	Resources []struct {
+		Kind   string `json:"kind"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+		Msg    string `json:"msg"`
+	} `json:"resources"`
+}
+
+// runKubeconform validates the manifest with kubeconform, if it is installed.
+// It returns false if kubeconform could not be run.
+func runKubeconform(ctx context.Context, manifest []byte) ([]ManifestIssue, bool) {
+	path, err := exec.LookPath("kubeconform")
+	if err != nil {
+		return nil, false
+	}
+	cmd := exec.CommandContext(ctx, path, "-strict", "-ignore-missing-schemas", "-output", "json", "-")
+	cmd.Stdin = bytes.NewReader(manifest)
+	// kubeconform exits with a non-zero status when resources are invalid,
+	// so we rely on its output rather than its exit code.
+	out, _ := cmd.Output()
+
+	var output kubeconformOutput
+	if err := json.Unmarshal(out, &output); err != nil {
+		klog.Warningf("failed to parse kubeconform output: %v", err)
+		return nil, false
+	}
+	var issues []ManifestIssue
+	for _, r := range output.Resources {
+		switch r.Status {
+		case "statusInvalid":
+			issues = append(issues, ManifestIssue{Object: r.Kind + "/" + r.Name, Message: r.Msg})
+		case "statusError":
+			// Typically schemas that could not be downloaded, which we don't treat as invalid.
+			klog.V(2).Infof("kubeconform error for %s/%s: %s", r.Kind, r.Name, r.Msg)
+		}
+	}
+	return issues, true
+}
+
+// manifestApplyVerbs are the kubectl verbs that send manifests to the cluster.
+var manifestApplyVerbs = map[string]bool{
+	"apply": true, "create": true, "replace": true,
+}
+
+// ValidateCommandManifests validates the manifests a shell command would send to
+// the cluster: heredocs fed to kubectl apply/create/replace -f -, and local files
+// passed with -f (relative to workDir). It returns nil if the command does not
+// contain any manifest.
+func ValidateCommandManifests(ctx context.Context, command string, workDir string) *ManifestValidationResult {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil
+	}
+
+	// Manifests are also commonly piped to kubectl, e.g. cat <<EOF | kubectl apply -f -
+	pipedHeredocs := make(map[*syntax.Stmt][][]byte)
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if pipe, ok := node.(*syntax.BinaryCmd); ok && pipe.Op == syntax.Pipe {
+			pipedHeredocs[pipe.Y] = heredocs(pipe.X)
+		}
+		return true
+	})
+
+	var manifests [][]byte
+	syntax.Walk(file, func(node syntax.Node) bool {
+		stmt, ok := node.(*syntax.Stmt)
+		if !ok {
+			return true
+		}
+		call, ok := stmt.Cmd.(*syntax.CallExpr)
+		if !ok {
+			return true
+		}
+		args := callArgs(call)
+		if len(args) == 0 {
+			return true
+		}
+		if name := filepath.Base(args[0]); name != "kubectl" && name != "kubectl.exe" {
+			return true
+		}
+		kc := parseKubectlCommand(args[1:])
+		if !manifestApplyVerbs[kc.Verb] || kc.Filename == "" {
+			return true
+		}
+
+		if kc.Filename == "-" {
+			manifests = append(manifests, heredocs(stmt)...)
+			manifests = append(manifests, pipedHeredocs[stmt]...)
+			return true
+		}
+		if strings.Contains(kc.Filename, "://") {
+			// Remote manifests are not fetched for validation.
+			return true
+		}
+		p := kc.Filename
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(workDir, p)
+		}
+		if info, err := os.Stat(p); err != nil || info.IsDir() {
+			return true
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			klog.V(2).Infof("failed to read manifest %q: %v", p, err)
+			return true
+		}
+		manifests = append(manifests, b)
+		return true
+	})
+
+	if len(manifests) == 0 {
+		return nil
+	}
+	return ValidateManifests(ctx, bytes.Join(manifests, []byte("\n---\n")))
+}
+
+// heredocs returns the content of the heredocs redirected to a statement.
+func heredocs(stmt *syntax.Stmt) [][]byte {
+	var docs [][]byte
+	for _, redir := range stmt.Redirs {
+		if (redir.Op == syntax.Hdoc || redir.Op == syntax.DashHdoc) && redir.Hdoc != nil {
+			var sb strings.Builder
+			syntax.NewPrinter().Print(&sb, redir.Hdoc)
+			docs = append(docs, []byte(sb.String()))
+		}
+	}
+	return docs
+}
@@ -0,0 +1,259 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const validDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.27
+        ports:
+        - containerPort: 80
+        resources:
+          limits:
+            cpu: 500m
+            memory: 128Mi
+`
+
+func TestValidateManifests(t *testing.T) {
+	// Only test the builtin validator.
+	t.Setenv("PATH", "")
+
+	tests := []struct {
+		name      string
+		manifest  string
+		documents int
+		expected  []string
+	}{
+		{
+			name:      "valid deployment",
+			manifest:  validDeployment,
+			documents: 1,
+		},
+		{
+			name: "multiple documents with a custom resource",
+			manifest: validDeployment + `---
+# a comment
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+    targetPort: http
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  anything: [1, 2]
+`,
+			documents: 3,
+		},
+		{
+			name: "json",
+			manifest: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "cfg"},
+				"data": {"replicas": 3}}`,
+			documents: 1,
+			expected:  []string{"ConfigMap/cfg: data.replicas: expected a string, got number 3"},
+		},
+		{
+			name: "invalid deployment",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    version: 2
+spec:
+  replicas: "2"
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: api
+    spec:
+      containers:
+      - name: web
+        imagePullPolicy: Sometimes
+        ports:
+        - containerPort: 80.5
+`,
+			documents: 1,
+			expected: []string{
+				"Deployment/web: metadata.labels.version: expected a string, got number 2",
+				"Deployment/web: spec.replicas: expected an integer, got string \"2\"",
+				"Deployment/web: spec.template.spec.containers[0].image: required field is missing",
+				"Deployment/web: spec.template.spec.containers[0].imagePullPolicy: unsupported value \"Sometimes\", must be one of Always, IfNotPresent, Never",
+				"Deployment/web: spec.template.spec.containers[0].ports[0].containerPort: expected an integer, got number 80.5",
+				"Deployment/web: spec.selector.matchLabels.app: selector does not match template labels (template has api)",
+			},
+		},
+		{
+			name:      "missing type meta",
+			manifest:  "metadata:\n  name: x\n",
+			documents: 1,
+			expected: []string{
+				"document 1: apiVersion: required field is missing",
+				"document 1: kind: required field is missing",
+			},
+		},
+		{
+			name:      "invalid yaml",
+			manifest:  "apiVersion: v1\nkind: [\n",
+			documents: 1,
+			expected:  []string{"document 1: invalid YAML: error converting YAML to JSON: yaml: line 2: did not find expected node content"},
+		},
+		{
+			name:     "empty",
+			manifest: "---\n# nothing\n",
+			expected: []string{"manifest is empty"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ValidateManifests(context.Background(), []byte(tc.manifest))
+			if result.Documents != tc.documents {
+				t.Errorf("expected %d documents, got %d", tc.documents, result.Documents)
+			}
+			if result.Valid != (len(tc.expected) == 0) {
+				t.Errorf("expected valid=%v, got %v", len(tc.expected) == 0, result.Valid)
+			}
+			var got []string
+			for _, issue := range result.Issues {
+				got = append(got, issue.String())
+			}
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected issues:\n%q\ngot:\n%q", tc.expected, got)
+			}
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Errorf("issue %d: expected %q, got %q", i, tc.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCommandManifests(t *testing.T) {
+	t.Setenv("PATH", "")
+
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "deploy.yaml"), []byte(validDeployment), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		// expected is the number of issues, or -1 if no manifest should be found.
+		expected int
+	}{
+		{
+			name:     "no manifest",
+			command:  "kubectl get pods",
+			expected: -1,
+		},
+		{
+			name:     "delete with file",
+			command:  "kubectl delete -f deploy.yaml",
+			expected: -1,
+		},
+		{
+			name:     "remote file",
+			command:  "kubectl apply -f https://example.com/deploy.yaml",
+			expected: -1,
+		},
+		{
+			name:     "local file",
+			command:  "kubectl apply -f deploy.yaml",
+			expected: 0,
+		},
+		{
+			name: "heredoc",
+			command: `kubectl apply -f - <<'EOF'
+apiVersion: v1
+kind: Pod
+metadata:
+  name: p
+spec:
+  containers:
+  - name: c
+EOF`,
+			expected: 1,
+		},
+		{
+			name: "heredoc in a compound command",
+			command: `kubectl create namespace demo && cat <<EOF | kubectl apply -f -
+apiVersion: v1
+kind: Pod
+EOF
+kubectl -n demo create -f - <<EOF
+apiVersion: v1
+kind: Service
+metadata:
+  name: s
+spec:
+  ports:
+  - targetPort: 80
+EOF`,
+			expected: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ValidateCommandManifests(context.Background(), tc.command, workDir)
+			if tc.expected < 0 {
+				if result != nil {
+					t.Fatalf("expected no manifest, got %+v", result)
+				}
+				return
+			}
+			if result == nil {
+				t.Fatalf("expected a manifest to be validated")
+			}
+			if len(result.Issues) != tc.expected {
+				t.Errorf("expected %d issues, got %v", tc.expected, result.Issues)
+			}
+		})
+	}
+}
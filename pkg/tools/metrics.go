@@ -0,0 +1,381 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func init() {
+	RegisterTool(&CompareMetrics{})
+}
+
+const (
+	defaultCompareMinutes = 10
+	// maxMetricSamples bounds the samples kept by a MetricsHistory, the
+	// oldest are dropped.
+	maxMetricSamples = 20000
+)
+
+// ParseTop parses the output of kubectl top pods or kubectl top nodes into
+// samples taken at the given time. The samples of pods without a namespace
+// column are in the given namespace, "" for the namespace of the context.
+// It returns nil if the output isn't a kubectl top table with its header.
+func ParseTop(output, namespace string, at time.Time) []api.MetricSample {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	header := strings.Fields(lines[0])
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	cpuColumn, hasCPU := columns["CPU(cores)"]
+	memoryColumn, hasMemory := columns["MEMORY(bytes)"]
+	if !hasCPU || !hasMemory {
+		return nil
+	}
+	kind := "pod"
+	if _, ok := columns["CPU%"]; ok {
+		kind = "node"
+	} else if _, ok := columns["CPU(%)"]; ok {
+		kind = "node"
+	}
+
+	var samples []api.MetricSample
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != len(header) {
+			continue
+		}
+		cpu, err := resource.ParseQuantity(fields[cpuColumn])
+		if err != nil {
+			// Nodes without metrics are reported as <unknown>.
+			continue
+		}
+		memory, err := resource.ParseQuantity(fields[memoryColumn])
+		if err != nil {
+			continue
+		}
+		sample := api.MetricSample{
+			Time:          at,
+			Kind:          kind,
+			Name:          fields[columns["NAME"]],
+			CPUMillicores: cpu.MilliValue(),
+			MemoryBytes:   memory.Value(),
+		}
+		if kind == "pod" {
+			sample.Namespace = namespace
+			if i, ok := columns["NAMESPACE"]; ok {
+				sample.Namespace = fields[i]
+			}
+			// kubectl top pods --containers prints the pod and the container.
+			if i, ok := columns["POD"]; ok {
+				sample.Container = sample.Name
+				sample.Name = fields[i]
+			}
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// MetricsHistory holds the metric samples of a session, for the usage of the
+// cluster to be compared with earlier samples.
+type MetricsHistory struct {
+	mu      sync.Mutex
+	samples []api.MetricSample
+	// record persists the new samples, if set.
+	record func([]api.MetricSample)
+}
+
+// NewMetricsHistory returns a history holding the given samples, e.g. those
+// recorded in the session, which calls record with the new samples.
+func NewMetricsHistory(samples []api.MetricSample, record func([]api.MetricSample)) *MetricsHistory {
+	return &MetricsHistory{samples: samples, record: record}
+}
+
+// Record adds samples to the history.
+func (h *MetricsHistory) Record(samples []api.MetricSample) {
+	if len(samples) == 0 {
+		return
+	}
+	h.mu.Lock()
+	h.samples = append(h.samples, samples...)
+	if extra := len(h.samples) - maxMetricSamples; extra > 0 {
+		h.samples = slices.Delete(h.samples, 0, extra)
+	}
+	h.mu.Unlock()
+	if h.record != nil {
+		h.record(samples)
+	}
+}
+
+// Samples returns the samples of the history, oldest first.
+func (h *MetricsHistory) Samples() []api.MetricSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.samples)
+}
+
+// Baseline returns the samples of the kind, in the namespace unless
+// allNamespaces is set, of the sample taken before the given time which is
+// the closest to target. Container samples are ignored.
+func (h *MetricsHistory) Baseline(kind, namespace string, allNamespaces bool, target, before time.Time) []api.MetricSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	matches := func(s api.MetricSample) bool {
+		return s.Kind == kind && s.Container == "" && (kind == "node" || allNamespaces || s.Namespace == namespace)
+	}
+	var best time.Time
+	for _, s := range h.samples {
+		if !matches(s) || !s.Time.Before(before) {
+			continue
+		}
+		if best.IsZero() || s.Time.Sub(target).Abs() < best.Sub(target).Abs() {
+			best = s.Time
+		}
+	}
+	var samples []api.MetricSample
+	for _, s := range h.samples {
+		if matches(s) && s.Time.Equal(best) {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// MetricDelta is the change of the usage of a pod or a node between two samples.
+type MetricDelta struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// CPU and Memory are formatted as "before → after (change, percent)".
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+
+	CPUDeltaMillicores int64 `json:"cpu_delta_millicores"`
+	MemoryDeltaBytes   int64 `json:"memory_delta_bytes"`
+}
+
+// CompareMetricSamples returns the changes between two sets of samples, the
+// largest CPU changes first, and the names of the pods or nodes found in
+// only one of them.
+func CompareMetricSamples(baseline, current []api.MetricSample) (deltas []MetricDelta, added, removed []string) {
+	key := func(s api.MetricSample) string {
+		if s.Namespace == "" {
+			return s.Name
+		}
+		return s.Namespace + "/" + s.Name
+	}
+	before := map[string]api.MetricSample{}
+	for _, s := range baseline {
+		before[key(s)] = s
+	}
+	for _, s := range current {
+		b, ok := before[key(s)]
+		if !ok {
+			added = append(added, key(s))
+			continue
+		}
+		delete(before, key(s))
+		deltas = append(deltas, MetricDelta{
+			Namespace:          s.Namespace,
+			Name:               s.Name,
+			CPU:                formatChange(b.CPUMillicores, s.CPUMillicores, formatMillicores),
+			Memory:             formatChange(b.MemoryBytes, s.MemoryBytes, formatBytes),
+			CPUDeltaMillicores: s.CPUMillicores - b.CPUMillicores,
+			MemoryDeltaBytes:   s.MemoryBytes - b.MemoryBytes,
+		})
+	}
+	for k := range before {
+		removed = append(removed, k)
+	}
+	sort.SliceStable(deltas, func(i, j int) bool {
+		di, dj := abs(deltas[i].CPUDeltaMillicores), abs(deltas[j].CPUDeltaMillicores)
+		if di != dj {
+			return di > dj
+		}
+		return abs(deltas[i].MemoryDeltaBytes) > abs(deltas[j].MemoryDeltaBytes)
+	})
+	sort.Strings(added)
+	sort.Strings(removed)
+	return deltas, added, removed
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// formatChange formats the change of a value, e.g. "250m → 400m (+150m, +60%)".
+func formatChange(before, after int64, format func(int64) string) string {
+	s := fmt.Sprintf("%s → %s (", format(before), format(after))
+	if after >= before {
+		s += "+"
+	} else {
+		s += "-"
+	}
+	s += format(abs(after - before))
+	if before != 0 {
+		s += fmt.Sprintf(", %+.0f%%", float64(after-before)*100/float64(before))
+	}
+	return s + ")"
+}
+
+func formatMillicores(m int64) string {
+	return fmt.Sprintf("%dm", m)
+}
+
+func formatBytes(b int64) string {
+	const mi = 1 << 20
+	if b >= 10*1024*mi {
+		return fmt.Sprintf("%.1fGi", float64(b)/(1024*mi))
+	}
+	return fmt.Sprintf("%dMi", int64(math.Round(float64(b)/mi)))
+}
+
+// CompareMetrics compares the current usage of the pods or nodes, as
+// reported by kubectl top, with a sample recorded earlier in the session.
+type CompareMetrics struct{}
+
+func (t *CompareMetrics) Name() string {
+	return "compare_metrics"
+}
+
+func (t *CompareMetrics) Description() string {
+	return `Compares the current CPU and memory usage of the pods or nodes (kubectl top) with the usage sampled earlier in the session, e.g. "compare with 10 minutes ago". Returns the change of each pod or node, the largest CPU changes first.
+The results of kubectl top commands run in the session are sampled, as are those of this tool. Use this tool instead of comparing numbers from earlier messages. If there is no earlier sample, the current one is recorded for a later comparison.`
+}
+
+func (t *CompareMetrics) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"kind": {
+					Type:        gollm.TypeString,
+					Description: `"pods" or "nodes". Defaults to "pods".`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace of the pods. Defaults to the namespace of the context.`,
+				},
+				"all_namespaces": {
+					Type:        gollm.TypeBoolean,
+					Description: `Compare the pods of all namespaces.`,
+				},
+				"minutes_ago": {
+					Type:        gollm.TypeInteger,
+					Description: fmt.Sprintf("How long ago the sample to compare with was taken, in minutes. The closest earlier sample is used. Defaults to %d.", defaultCompareMinutes),
+				},
+			},
+		},
+	}
+}
+
+// CompareMetricsResult is the result of the compare_metrics tool.
+type CompareMetricsResult struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Current   time.Time `json:"current"`
+	// Baseline is when the sample compared with was taken, zero if there is none.
+	Baseline time.Time     `json:"baseline,omitempty"`
+	Deltas   []MetricDelta `json:"deltas,omitempty"`
+	// Added and Removed are the pods or nodes in only one of the samples.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+func (t *CompareMetrics) Run(ctx context.Context, args map[string]any) (any, error) {
+	history, _ := ctx.Value(MetricsKey).(*MetricsHistory)
+	if history == nil {
+		return &ExecResult{Error: "metrics can only be compared in a session"}, nil
+	}
+	kind, _ := args["kind"].(string)
+	namespace, _ := args["namespace"].(string)
+	allNamespaces, _ := args["all_namespaces"].(bool)
+	minutes := float64(defaultCompareMinutes)
+	if n, ok := args["minutes_ago"].(float64); ok && n > 0 {
+		minutes = n
+	}
+
+	kubectlArgs := []string{"top"}
+	switch strings.TrimSpace(strings.ToLower(kind)) {
+	case "", "pod", "pods", "po":
+		kind = "pod"
+		kubectlArgs = append(kubectlArgs, "pods")
+		if allNamespaces {
+			kubectlArgs = append(kubectlArgs, "--all-namespaces")
+		} else if namespace != "" {
+			kubectlArgs = append(kubectlArgs, "--namespace", namespace)
+		}
+	case "node", "nodes", "no":
+		kind = "node"
+		kubectlArgs = append(kubectlArgs, "nodes")
+		namespace, allNamespaces = "", false
+	default:
+		return &ExecResult{Error: fmt.Sprintf("kind %q must be pods or nodes", kind)}, nil
+	}
+
+	out, err := kubectlOutput(ctx, kubectlArgs...)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+	now := time.Now()
+	current := ParseTop(string(out), namespace, now)
+	result := &CompareMetricsResult{Kind: kind, Namespace: namespace, Current: now}
+	if current == nil {
+		result.Message = "kubectl top reported no metrics."
+		return result, nil
+	}
+	target := now.Add(-time.Duration(minutes * float64(time.Minute)))
+	baseline := history.Baseline(kind, namespace, allNamespaces, target, now)
+	history.Record(current)
+	if len(baseline) == 0 {
+		result.Message = "There is no earlier sample to compare with. The current usage was sampled, compare again later."
+		return result, nil
+	}
+
+	result.Baseline = baseline[0].Time
+	result.Deltas, result.Added, result.Removed = CompareMetricSamples(baseline, current)
+	result.Message = fmt.Sprintf("Compared with the sample taken %s ago.", now.Sub(result.Baseline).Round(time.Second))
+	if off := result.Baseline.Sub(target).Abs(); off > time.Minute && off > time.Duration(minutes*float64(time.Minute))/4 {
+		result.Message += fmt.Sprintf(" There is no sample from %s ago, this is the closest.", time.Duration(minutes*float64(time.Minute)))
+	}
+	return result, nil
+}
+
+func (t *CompareMetrics) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *CompareMetrics) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestParseTop(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		output string
+		want   []api.MetricSample
+	}{
+		{
+			name:   "pods",
+			output: "NAME          CPU(cores)   MEMORY(bytes)\nweb-0         250m         512Mi\nweb-1         1            1Gi\n",
+			want: []api.MetricSample{
+				{Time: at, Kind: "pod", Namespace: "shop", Name: "web-0", CPUMillicores: 250, MemoryBytes: 512 << 20},
+				{Time: at, Kind: "pod", Namespace: "shop", Name: "web-1", CPUMillicores: 1000, MemoryBytes: 1 << 30},
+			},
+		},
+		{
+			name:   "all namespaces and containers",
+			output: "NAMESPACE   POD     NAME    CPU(cores)   MEMORY(bytes)\nkube-system coredns coredns 3m           20Mi\n",
+			want: []api.MetricSample{
+				{Time: at, Kind: "pod", Namespace: "kube-system", Name: "coredns", Container: "coredns", CPUMillicores: 3, MemoryBytes: 20 << 20},
+			},
+		},
+		{
+			name:   "nodes",
+			output: "NAME     CPU(cores)   CPU(%)   MEMORY(bytes)   MEMORY(%)\nnode-1   1200m        30%      4000Mi          50%\nnode-2   <unknown>    <unknown>   <unknown>    <unknown>\n",
+			want: []api.MetricSample{
+				{Time: at, Kind: "node", Name: "node-1", CPUMillicores: 1200, MemoryBytes: 4000 << 20},
+			},
+		},
+		{name: "no header", output: "web-0   250m   512Mi\n"},
+		{name: "error", output: "error: Metrics API not available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseTop(tt.output, "shop", at); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTop() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsHistoryBaseline(t *testing.T) {
+	now := time.Now()
+	sample := func(minutesAgo int, namespace, name string) api.MetricSample {
+		return api.MetricSample{Time: now.Add(-time.Duration(minutesAgo) * time.Minute), Kind: "pod", Namespace: namespace, Name: name}
+	}
+	var recorded []api.MetricSample
+	h := NewMetricsHistory([]api.MetricSample{sample(30, "shop", "web-0")}, func(s []api.MetricSample) { recorded = append(recorded, s...) })
+	h.Record([]api.MetricSample{sample(12, "shop", "web-0"), sample(12, "shop", "web-1")})
+	h.Record([]api.MetricSample{sample(9, "other", "db-0")})
+	h.Record([]api.MetricSample{sample(2, "shop", "web-0")})
+	if len(recorded) != 4 {
+		t.Errorf("recorded %d samples, want 4", len(recorded))
+	}
+
+	got := h.Baseline("pod", "shop", false, now.Add(-10*time.Minute), now)
+	if len(got) != 2 || !got[0].Time.Equal(now.Add(-12*time.Minute)) {
+		t.Errorf("Baseline(shop, 10 minutes ago) = %+v, want the 2 samples of 12 minutes ago", got)
+	}
+	got = h.Baseline("pod", "", true, now.Add(-10*time.Minute), now)
+	if len(got) != 1 || got[0].Name != "db-0" {
+		t.Errorf("Baseline(all namespaces, 10 minutes ago) = %+v, want db-0", got)
+	}
+	if got := h.Baseline("node", "", false, now, now); len(got) != 0 {
+		t.Errorf("Baseline(nodes) = %+v, want none", got)
+	}
+}
+
+func TestCompareMetricSamples(t *testing.T) {
+	baseline := []api.MetricSample{
+		{Kind: "pod", Namespace: "shop", Name: "web-0", CPUMillicores: 200, MemoryBytes: 500 << 20},
+		{Kind: "pod", Namespace: "shop", Name: "web-1", CPUMillicores: 100, MemoryBytes: 500 << 20},
+		{Kind: "pod", Namespace: "shop", Name: "old", CPUMillicores: 100, MemoryBytes: 500 << 20},
+	}
+	current := []api.MetricSample{
+		{Kind: "pod", Namespace: "shop", Name: "web-0", CPUMillicores: 150, MemoryBytes: 400 << 20},
+		{Kind: "pod", Namespace: "shop", Name: "web-1", CPUMillicores: 400, MemoryBytes: 600 << 20},
+		{Kind: "pod", Namespace: "shop", Name: "new", CPUMillicores: 100, MemoryBytes: 500 << 20},
+	}
+	deltas, added, removed := CompareMetricSamples(baseline, current)
+	want := []MetricDelta{
+		{Namespace: "shop", Name: "web-1", CPU: "100m → 400m (+300m, +300%)", Memory: "500Mi → 600Mi (+100Mi, +20%)", CPUDeltaMillicores: 300, MemoryDeltaBytes: 100 << 20},
+		{Namespace: "shop", Name: "web-0", CPU: "200m → 150m (-50m, -25%)", Memory: "500Mi → 400Mi (-100Mi, -20%)", CPUDeltaMillicores: -50, MemoryDeltaBytes: -100 << 20},
+	}
+	if !reflect.DeepEqual(deltas, want) {
+		t.Errorf("deltas = %+v, want %+v", deltas, want)
+	}
+	if !reflect.DeepEqual(added, []string{"shop/new"}) || !reflect.DeepEqual(removed, []string{"shop/old"}) {
+		t.Errorf("added, removed = %v, %v, want [shop/new], [shop/old]", added, removed)
+	}
+}
+
+func TestCompareMetrics(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\nprintf 'NAME CPU(cores) MEMORY(bytes)\\nweb-0 300m 600Mi\\n'\n"
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	history := NewMetricsHistory(nil, nil)
+	ctx := context.WithValue(context.Background(), MetricsKey, history)
+	tool := &CompareMetrics{}
+
+	out, err := tool.Run(ctx, map[string]any{"namespace": "shop"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	result := out.(*CompareMetricsResult)
+	if !result.Baseline.IsZero() || len(history.Samples()) != 1 {
+		t.Errorf("Run() without samples = %+v, want the current usage sampled", result)
+	}
+	if args, _ := os.ReadFile(filepath.Join(dir, "args")); string(args) != "top pods --namespace shop\n" {
+		t.Errorf("kubectl args = %q", args)
+	}
+
+	history.Record([]api.MetricSample{{Time: time.Now().Add(-10 * time.Minute), Kind: "pod", Namespace: "shop", Name: "web-0", CPUMillicores: 100, MemoryBytes: 600 << 20}})
+	out, err = tool.Run(ctx, map[string]any{"namespace": "shop", "minutes_ago": float64(10)})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	result = out.(*CompareMetricsResult)
+	if len(result.Deltas) != 1 || result.Deltas[0].CPUDeltaMillicores != 200 {
+		t.Errorf("Run() = %+v, want web-0 up by 200m", result)
+	}
+
+	out, _ = tool.Run(ctx, map[string]any{"kind": "services"})
+	if result, ok := out.(*ExecResult); !ok || result.Error == "" {
+		t.Errorf("Run(services) = %+v, want an error result", out)
+	}
+	out, _ = tool.Run(context.Background(), map[string]any{})
+	if result, ok := out.(*ExecResult); !ok || result.Error == "" {
+		t.Errorf("Run() without history = %+v, want an error result", out)
+	}
+}
@@ -0,0 +1,556 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	RegisterTool(&NetCheckTool{})
+}
+
+const (
+	// netcheckImage is the image running the probes, with nslookup, nc and wget.
+	netcheckImage   = "busybox"
+	netcheckTimeout = 2 * time.Minute
+	// netcheckProbeTimeout bounds each probe, in seconds.
+	netcheckProbeTimeout = 5
+
+	maxNetcheckChecks      = 10
+	maxNetcheckOutputLines = 20
+
+	netcheckBeginMarker = "@@netcheck-begin"
+	netcheckEndMarker   = "@@netcheck-end"
+)
+
+var (
+	netcheckHostPattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9.]{0,251}[A-Za-z0-9])?$`)
+	netcheckPathPattern = regexp.MustCompile(`^/[A-Za-z0-9._~/%?=&+-]*$`)
+	labelKeyPattern     = regexp.MustCompile(`^([a-z0-9.-]+/)?[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	labelValuePattern   = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?$`)
+	httpStatusPattern   = regexp.MustCompile(`HTTP/[0-9.]+ ([0-9]{3})`)
+)
+
+// NetCheckTool diagnoses network connectivity from inside the cluster: it
+// runs DNS lookups and TCP and HTTP probes from an ephemeral container in an
+// existing pod, or from a short-lived pod, and simulates the NetworkPolicies
+// for the traffic of each probe.
+type NetCheckTool struct{}
+
+func (t *NetCheckTool) Name() string {
+	return "netcheck"
+}
+
+func (t *NetCheckTool) Description() string {
+	return `Checks the network connectivity from a pod of the cluster, returning a pass/fail result per check, with the output of the probe and a simulation of the NetworkPolicies for its traffic.
+Use this tool to investigate network issues, e.g. a service that can't be reached, DNS resolution failures or traffic blocked by NetworkPolicies.
+The checks run from an ephemeral container added to an existing pod (with the network and the NetworkPolicies of the pod), or from a short-lived pod deleted afterwards, in a namespace and with labels of your choice.
+Checks are:
+- "dns": resolves a host name, e.g. "web.shop.svc.cluster.local" or "example.com"
+- "tcp": opens a TCP connection to a host and port, e.g. a service "web.shop" on port 80
+- "http": sends a GET request to http://host:port/path and reports the HTTP status`
+}
+
+func (t *NetCheckTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"from": {
+					Type:        gollm.TypeString,
+					Description: `Where the checks run: "namespace/pod" to run them from an ephemeral container in an existing pod, or "namespace" to run them from a short-lived pod in the namespace.`,
+				},
+				"labels": {
+					Type:        gollm.TypeString,
+					Description: `When "from" is a namespace, the labels of the short-lived pod, e.g. "app=web,tier=frontend", to check the traffic of the pods with these labels.`,
+				},
+				"checks": {
+					Type:        gollm.TypeArray,
+					Description: fmt.Sprintf(`The checks to run, at most %d.`, maxNetcheckChecks),
+					Items: &gollm.Schema{
+						Type: gollm.TypeObject,
+						Properties: map[string]*gollm.Schema{
+							"type": {
+								Type:        gollm.TypeString,
+								Description: `"dns", "tcp" or "http".`,
+							},
+							"host": {
+								Type:        gollm.TypeString,
+								Description: `The host name or IP address, e.g. a service "name.namespace".`,
+							},
+							"port": {
+								Type:        gollm.TypeInteger,
+								Description: `The port, required for tcp. Defaults to 80 for http.`,
+							},
+							"path": {
+								Type:        gollm.TypeString,
+								Description: `For http, the path of the request, e.g. "/healthz". Defaults to "/".`,
+							},
+						},
+						Required: []string{"type", "host"},
+					},
+				},
+			},
+			Required: []string{"from", "checks"},
+		},
+	}
+}
+
+// NetCheckResult is the result of the netcheck tool.
+type NetCheckResult struct {
+	// Source describes where the checks ran.
+	Source string     `json:"source"`
+	Checks []NetCheck `json:"checks"`
+	Passed int        `json:"passed"`
+	Failed int        `json:"failed"`
+	// PolicyError is set when the NetworkPolicies could not be simulated.
+	PolicyError string `json:"policyError,omitempty"`
+	Note        string `json:"note,omitempty"`
+}
+
+// NetCheck is the result of a check.
+type NetCheck struct {
+	Type   string `json:"type"`
+	Target string `json:"target"`
+	// Status is "pass", "fail", or "error" when the probe didn't run.
+	Status     string                `json:"status"`
+	HTTPStatus int                   `json:"httpStatus,omitempty"`
+	Output     string                `json:"output,omitempty"`
+	Policy     *NetworkPolicyVerdict `json:"networkPolicy,omitempty"`
+}
+
+// netcheckProbe is a check requested by the model.
+type netcheckProbe struct {
+	Type string
+	Host string
+	Port int
+	Path string
+}
+
+func (p netcheckProbe) target() string {
+	switch p.Type {
+	case "tcp":
+		return fmt.Sprintf("%s:%d", p.Host, p.Port)
+	case "http":
+		return fmt.Sprintf("http://%s:%d%s", p.Host, p.Port, p.Path)
+	default:
+		return p.Host
+	}
+}
+
+// command returns the shell command of the probe. Hosts and paths are
+// validated, so that quoting them is enough.
+func (p netcheckProbe) command() string {
+	switch p.Type {
+	case "tcp":
+		return fmt.Sprintf("nc -z -w %d '%s' %d", netcheckProbeTimeout, p.Host, p.Port)
+	case "http":
+		return fmt.Sprintf("wget -S -q -T %d -O /dev/null '%s'", netcheckProbeTimeout, p.target())
+	default:
+		return fmt.Sprintf("nslookup '%s'", p.Host)
+	}
+}
+
+func (t *NetCheckTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	from, _ := args["from"].(string)
+	namespace, pod, _ := strings.Cut(from, "/")
+	if !dnsSubdomainPattern.MatchString(namespace) || (pod != "" && !dnsSubdomainPattern.MatchString(pod)) {
+		return &ExecResult{Error: fmt.Sprintf("invalid from %q, expected namespace/pod or namespace", from)}, nil
+	}
+	labelsArg, _ := args["labels"].(string)
+	if pod != "" && labelsArg != "" {
+		return &ExecResult{Error: "labels can only be set when checks run from a namespace"}, nil
+	}
+	labels, err := parseLabels(labelsArg)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+	probes, err := netcheckProbes(args["checks"])
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, netcheckTimeout)
+	defer cancel()
+	name := "netcheck-" + uuid.New().String()[:8]
+	script := netcheckScript(probes)
+	result := &NetCheckResult{}
+	var kubectlArgs []string
+	if pod != "" {
+		result.Source = fmt.Sprintf("ephemeral container %s of pod %s/%s", name, namespace, pod)
+		result.Note = "Ephemeral containers can't be removed, the container stays in the pod spec in the terminated state."
+		kubectlArgs = []string{"debug", "pod/" + pod, "--namespace", namespace, "--image=" + netcheckImage, "--container=" + name, "--attach=true", "--quiet", "--", "sh", "-c", script}
+	} else {
+		result.Source = fmt.Sprintf("pod %s/%s", namespace, name)
+		kubectlArgs = []string{"run", name, "--namespace", namespace, "--image=" + netcheckImage, "--restart=Never", "--rm", "--attach=true", "--quiet"}
+		if labelsArg != "" {
+			result.Source += " with labels " + labelsArg
+			kubectlArgs = append(kubectlArgs, "--labels="+labelsArg)
+		}
+		kubectlArgs = append(kubectlArgs, "--command", "--", "sh", "-c", script)
+		// The pod is left behind when kubectl is interrupted.
+		defer func() {
+			if _, err := kubectlOutput(context.WithoutCancel(ctx), "delete", "pod", name, "--namespace", namespace, "--wait=false", "--ignore-not-found"); err != nil {
+				klog.Warningf("deleting netcheck pod %s/%s: %v", namespace, name, err)
+			}
+		}()
+	}
+	out, err := kubectlOutput(runCtx, kubectlArgs...)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+	result.Checks = parseNetcheckOutput(string(out), probes)
+	for _, check := range result.Checks {
+		if check.Status == "pass" {
+			result.Passed++
+		} else {
+			result.Failed++
+		}
+	}
+
+	if err := simulateNetcheckPolicies(ctx, namespace, pod, labels, probes, result.Checks); err != nil {
+		result.PolicyError = err.Error()
+	}
+	return result, nil
+}
+
+// parseLabels parses labels like "app=web,tier=frontend".
+func parseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	if s == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || !labelKeyPattern.MatchString(k) || !labelValuePattern.MatchString(v) {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+// netcheckProbes validates the checks requested by the model.
+func netcheckProbes(arg any) ([]netcheckProbe, error) {
+	checks, _ := arg.([]any)
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("checks must be provided")
+	}
+	if len(checks) > maxNetcheckChecks {
+		return nil, fmt.Errorf("too many checks (%d), at most %d can run at once", len(checks), maxNetcheckChecks)
+	}
+	var probes []netcheckProbe
+	for i, c := range checks {
+		check, _ := c.(map[string]any)
+		probe := netcheckProbe{}
+		probe.Type, _ = check["type"].(string)
+		probe.Host, _ = check["host"].(string)
+		probe.Path, _ = check["path"].(string)
+		if port, ok := check["port"].(float64); ok {
+			probe.Port = int(port)
+		}
+		if !netcheckHostPattern.MatchString(probe.Host) {
+			return nil, fmt.Errorf("check %d: invalid host %q", i+1, probe.Host)
+		}
+		switch probe.Type {
+		case "dns":
+		case "tcp":
+			if probe.Port <= 0 || probe.Port > 65535 {
+				return nil, fmt.Errorf("check %d: a port between 1 and 65535 is required for tcp", i+1)
+			}
+		case "http":
+			if probe.Port == 0 {
+				probe.Port = 80
+			}
+			if probe.Port < 0 || probe.Port > 65535 {
+				return nil, fmt.Errorf("check %d: invalid port %d", i+1, probe.Port)
+			}
+			if probe.Path == "" {
+				probe.Path = "/"
+			}
+			if !netcheckPathPattern.MatchString(probe.Path) {
+				return nil, fmt.Errorf("check %d: invalid path %q", i+1, probe.Path)
+			}
+		default:
+			return nil, fmt.Errorf("check %d: invalid type %q, expected dns, tcp or http", i+1, probe.Type)
+		}
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// netcheckScript returns the shell script running the probes, delimiting the
+// output of each one with markers.
+func netcheckScript(probes []netcheckProbe) string {
+	var b strings.Builder
+	for i, probe := range probes {
+		fmt.Fprintf(&b, "echo '%s %d'; %s 2>&1; echo \"%s $?\"\n", netcheckBeginMarker, i, probe.command(), netcheckEndMarker)
+	}
+	return b.String()
+}
+
+// parseNetcheckOutput returns the results of the probes from the output of
+// their script.
+func parseNetcheckOutput(out string, probes []netcheckProbe) []NetCheck {
+	checks := make([]NetCheck, len(probes))
+	for i, probe := range probes {
+		checks[i] = NetCheck{Type: probe.Type, Target: probe.target(), Status: "error", Output: "the probe did not run"}
+	}
+
+	current := -1
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if rest, ok := strings.CutPrefix(line, netcheckBeginMarker+" "); ok {
+			current, lines = -1, nil
+			if i, err := strconv.Atoi(strings.TrimSpace(rest)); err == nil && i >= 0 && i < len(checks) {
+				current = i
+			}
+			continue
+		}
+		if current < 0 {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, netcheckEndMarker+" "); ok {
+			check := &checks[current]
+			check.Status = "fail"
+			if strings.TrimSpace(rest) == "0" {
+				check.Status = "pass"
+			}
+			if len(lines) > maxNetcheckOutputLines {
+				lines = lines[len(lines)-maxNetcheckOutputLines:]
+			}
+			check.Output = strings.TrimSpace(strings.Join(lines, "\n"))
+			if check.Type == "http" {
+				if m := httpStatusPattern.FindAllStringSubmatch(check.Output, -1); m != nil {
+					check.HTTPStatus, _ = strconv.Atoi(m[len(m)-1][1])
+				}
+			}
+			current = -1
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return checks
+}
+
+type podObject struct {
+	Metadata struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Ports []struct {
+				Name          string `json:"name"`
+				ContainerPort int    `json:"containerPort"`
+			} `json:"ports"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+type serviceObject struct {
+	Spec struct {
+		Selector map[string]string `json:"selector"`
+		Ports    []struct {
+			Port       int    `json:"port"`
+			Protocol   string `json:"protocol"`
+			TargetPort any    `json:"targetPort"`
+		} `json:"ports"`
+	} `json:"spec"`
+}
+
+// policyEndpoint returns the pod as an endpoint of the traffic.
+func (p *podObject) policyEndpoint(namespaceLabels map[string]map[string]string) policyEndpoint {
+	endpoint := policyEndpoint{
+		Namespace:       p.Metadata.Namespace,
+		NamespaceLabels: namespaceLabels[p.Metadata.Namespace],
+		Labels:          p.Metadata.Labels,
+		IP:              p.Status.PodIP,
+		ContainerPorts:  map[string]int{},
+		Name:            "pod " + p.Metadata.Namespace + "/" + p.Metadata.Name,
+	}
+	for _, c := range p.Spec.Containers {
+		for _, port := range c.Ports {
+			if port.Name != "" {
+				endpoint.ContainerPorts[port.Name] = port.ContainerPort
+			}
+		}
+	}
+	return endpoint
+}
+
+// simulateNetcheckPolicies sets the NetworkPolicy verdict of the checks, for
+// the traffic of the source pod, or of a pod with the labels in the namespace.
+func simulateNetcheckPolicies(ctx context.Context, namespace, pod string, labels map[string]string, probes []netcheckProbe, checks []NetCheck) error {
+	var policies objectList[networkPolicy]
+	if err := getJSON(ctx, &policies, "get", "networkpolicies", "--all-namespaces"); err != nil {
+		return err
+	}
+	var namespaces objectList[struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}]
+	if err := getJSON(ctx, &namespaces, "get", "namespaces"); err != nil {
+		return err
+	}
+	namespaceLabels := map[string]map[string]string{}
+	for _, ns := range namespaces.Items {
+		namespaceLabels[ns.Metadata.Name] = ns.Metadata.Labels
+	}
+
+	source := policyEndpoint{Namespace: namespace, NamespaceLabels: namespaceLabels[namespace], Labels: labels, Name: "pod in " + namespace}
+	if pod != "" {
+		var p podObject
+		if err := getJSON(ctx, &p, "get", "pod", pod, "--namespace", namespace); err != nil {
+			return err
+		}
+		source = p.policyEndpoint(namespaceLabels)
+	}
+
+	for i, probe := range probes {
+		destinations, port, err := netcheckDestinations(ctx, probe, namespace, namespaceLabels)
+		if err != nil {
+			return err
+		}
+		checks[i].Policy = simulateNetworkPolicies(policies.Items, source, destinations, port)
+	}
+	return nil
+}
+
+// netcheckDestinations returns the destinations of the traffic of a probe: the
+// DNS pods for lookups, the pods of the service for the other probes, or an
+// external endpoint when the host is not a service.
+func netcheckDestinations(ctx context.Context, probe netcheckProbe, namespace string, namespaceLabels map[string]map[string]string) ([]policyEndpoint, policyPort, error) {
+	if probe.Type == "dns" {
+		pods, err := listPods(ctx, "kube-system", "k8s-app=kube-dns", namespaceLabels)
+		return pods, policyPort{Protocol: "UDP", Number: 53}, err
+	}
+
+	port := policyPort{Protocol: "TCP", Number: probe.Port}
+	external := []policyEndpoint{{Name: probe.Host}}
+	if _, err := netip.ParseAddr(probe.Host); err == nil {
+		external[0].IP = probe.Host
+	}
+	name, serviceNamespace, ok := serviceRef(probe.Host, namespace)
+	if !ok {
+		return external, port, nil
+	}
+	var service serviceObject
+	if err := getJSON(ctx, &service, "get", "service", name, "--namespace", serviceNamespace); err != nil {
+		// The host is not a service of the cluster.
+		return external, port, nil
+	}
+	for _, p := range service.Spec.Ports {
+		if p.Port != probe.Port {
+			continue
+		}
+		if p.Protocol != "" {
+			port.Protocol = p.Protocol
+		}
+		switch target := p.TargetPort.(type) {
+		case float64:
+			port.Number = int(target)
+		case string:
+			if n, err := strconv.Atoi(target); err == nil {
+				port.Number = n
+			} else {
+				port = policyPort{Protocol: port.Protocol, Name: target}
+			}
+		}
+	}
+	if len(service.Spec.Selector) == 0 {
+		return nil, port, nil
+	}
+	var selector []string
+	for k, v := range service.Spec.Selector {
+		selector = append(selector, k+"="+v)
+	}
+	sort.Strings(selector)
+	pods, err := listPods(ctx, serviceNamespace, strings.Join(selector, ","), namespaceLabels)
+	return pods, port, err
+}
+
+// serviceRef returns the service a host name refers to, e.g. "web",
+// "web.shop" or "web.shop.svc.cluster.local".
+func serviceRef(host, namespace string) (name, serviceNamespace string, ok bool) {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return "", "", false
+	}
+	parts := strings.Split(host, ".")
+	switch {
+	case len(parts) == 1:
+		return parts[0], namespace, true
+	case len(parts) == 2, len(parts) >= 3 && parts[2] == "svc":
+		return parts[0], parts[1], true
+	default:
+		return "", "", false
+	}
+}
+
+func listPods(ctx context.Context, namespace, selector string, namespaceLabels map[string]map[string]string) ([]policyEndpoint, error) {
+	var pods objectList[podObject]
+	if err := getJSON(ctx, &pods, "get", "pods", "--namespace", namespace, "--selector", selector); err != nil {
+		return nil, err
+	}
+	var endpoints []policyEndpoint
+	for i := range pods.Items {
+		endpoints = append(endpoints, pods.Items[i].policyEndpoint(namespaceLabels))
+	}
+	return endpoints, nil
+}
+
+// getJSON runs a kubectl get command and parses its JSON output into v.
+func getJSON(ctx context.Context, v any, args ...string) error {
+	out, err := kubectlOutput(ctx, append(args, "-o", "json")...)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out, v); err != nil {
+		return fmt.Errorf("parsing the output of kubectl %s: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+func (t *NetCheckTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+// CheckModifiesResource reports that the tool modifies resources: it adds an
+// ephemeral container to a pod or creates a pod, so that its calls are
+// confirmed by the user.
+func (t *NetCheckTool) CheckModifiesResource(args map[string]any) string {
+	return "yes"
+}
@@ -0,0 +1,156 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNetcheckProbes(t *testing.T) {
+	tests := []struct {
+		name    string
+		checks  any
+		want    []netcheckProbe
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			checks: []any{
+				map[string]any{"type": "dns", "host": "web.shop.svc.cluster.local"},
+				map[string]any{"type": "tcp", "host": "db.shop", "port": float64(5432)},
+				map[string]any{"type": "http", "host": "web.shop"},
+			},
+			want: []netcheckProbe{
+				{Type: "dns", Host: "web.shop.svc.cluster.local"},
+				{Type: "tcp", Host: "db.shop", Port: 5432},
+				{Type: "http", Host: "web.shop", Port: 80, Path: "/"},
+			},
+		},
+		{
+			name:    "tcp without port",
+			checks:  []any{map[string]any{"type": "tcp", "host": "db.shop"}},
+			wantErr: "a port between 1 and 65535 is required",
+		},
+		{
+			name:    "host with shell characters",
+			checks:  []any{map[string]any{"type": "dns", "host": "web'; rm -rf /"}},
+			wantErr: "invalid host",
+		},
+		{
+			name:    "path with shell characters",
+			checks:  []any{map[string]any{"type": "http", "host": "web", "path": "/$(id)"}},
+			wantErr: "invalid path",
+		},
+		{
+			name:    "unknown type",
+			checks:  []any{map[string]any{"type": "icmp", "host": "web"}},
+			wantErr: "invalid type",
+		},
+		{
+			name:    "no check",
+			wantErr: "checks must be provided",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := netcheckProbes(tt.checks)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("netcheckProbes() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("netcheckProbes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("netcheckProbes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNetcheckOutput(t *testing.T) {
+	probes := []netcheckProbe{
+		{Type: "dns", Host: "web.shop"},
+		{Type: "http", Host: "web.shop", Port: 8080, Path: "/healthz"},
+		{Type: "tcp", Host: "db.shop", Port: 5432},
+	}
+	script := netcheckScript(probes)
+	if want := "echo '@@netcheck-begin 1'; wget -S -q -T 5 -O /dev/null 'http://web.shop:8080/healthz' 2>&1; echo \"@@netcheck-end $?\"\n"; !strings.Contains(script, want) {
+		t.Errorf("netcheckScript() = %q, want it to contain %q", script, want)
+	}
+
+	// The tcp probe didn't run, e.g. because the pod was killed.
+	out := `@@netcheck-begin 0
+Server:		10.96.0.10
+Address:	10.96.0.10:53
+
+Name:	web.shop.svc.cluster.local
+Address: 10.96.12.7
+@@netcheck-end 0
+@@netcheck-begin 1
+  HTTP/1.1 503 Service Unavailable
+wget: server returned error: HTTP/1.1 503 Service Unavailable
+@@netcheck-end 1
+`
+	want := []NetCheck{
+		{Type: "dns", Target: "web.shop", Status: "pass", Output: "Server:\t\t10.96.0.10\nAddress:\t10.96.0.10:53\n\nName:\tweb.shop.svc.cluster.local\nAddress: 10.96.12.7"},
+		{Type: "http", Target: "http://web.shop:8080/healthz", Status: "fail", HTTPStatus: 503, Output: "HTTP/1.1 503 Service Unavailable\nwget: server returned error: HTTP/1.1 503 Service Unavailable"},
+		{Type: "tcp", Target: "db.shop:5432", Status: "error", Output: "the probe did not run"},
+	}
+	if got := parseNetcheckOutput(out, probes); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNetcheckOutput() = %+v, want %+v", got, want)
+	}
+}
+
+func TestServiceRef(t *testing.T) {
+	tests := []struct {
+		host          string
+		wantName      string
+		wantNamespace string
+		wantOK        bool
+	}{
+		{host: "web", wantName: "web", wantNamespace: "default", wantOK: true},
+		{host: "web.shop", wantName: "web", wantNamespace: "shop", wantOK: true},
+		{host: "web.shop.svc.cluster.local", wantName: "web", wantNamespace: "shop", wantOK: true},
+		{host: "api.example.com"},
+		{host: "10.96.12.7"},
+	}
+
+	for _, tt := range tests {
+		name, namespace, ok := serviceRef(tt.host, "default")
+		if name != tt.wantName || namespace != tt.wantNamespace || ok != tt.wantOK {
+			t.Errorf("serviceRef(%q) = %q, %q, %v, want %q, %q, %v", tt.host, name, namespace, ok, tt.wantName, tt.wantNamespace, tt.wantOK)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	got, err := parseLabels("app=web,app.kubernetes.io/part-of=shop,canary=")
+	if err != nil {
+		t.Fatalf("parseLabels() error = %v", err)
+	}
+	want := map[string]string{"app": "web", "app.kubernetes.io/part-of": "shop", "canary": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLabels() = %v, want %v", got, want)
+	}
+	if _, err := parseLabels("app=web;id"); err == nil {
+		t.Errorf("parseLabels() accepted an invalid label")
+	}
+}
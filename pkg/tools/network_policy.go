@@ -0,0 +1,396 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// The NetworkPolicies of the cluster are evaluated offline, as the API server
+// would describe them: a pod selected by policies of a type (ingress or
+// egress) only accepts the traffic allowed by a rule of one of them, other
+// pods accept all the traffic. CNIs may implement more (e.g. their own policy
+// resources) or less (no enforcement at all), so the verdict explains the
+// result of the probes rather than replacing them.
+
+// NetworkPolicyVerdict is the result of the simulation of the NetworkPolicies
+// for the traffic of a check.
+type NetworkPolicyVerdict struct {
+	// Verdict is "allowed", "denied", "partial" when only some destination
+	// pods accept the traffic, or "unknown".
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason"`
+	// EgressPolicies are the policies restricting the egress of the source.
+	EgressPolicies []string `json:"egressPolicies,omitempty"`
+	// IngressPolicies are the policies restricting the ingress of the
+	// destination pods.
+	IngressPolicies []string `json:"ingressPolicies,omitempty"`
+}
+
+type networkPolicy struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		PodSelector labelSelector       `json:"podSelector"`
+		PolicyTypes []string            `json:"policyTypes"`
+		Ingress     []networkPolicyRule `json:"ingress"`
+		Egress      []networkPolicyRule `json:"egress"`
+	} `json:"spec"`
+}
+
+type networkPolicyRule struct {
+	From  []networkPolicyPeer `json:"from"`
+	To    []networkPolicyPeer `json:"to"`
+	Ports []networkPolicyPort `json:"ports"`
+}
+
+type networkPolicyPeer struct {
+	PodSelector       *labelSelector `json:"podSelector"`
+	NamespaceSelector *labelSelector `json:"namespaceSelector"`
+	IPBlock           *struct {
+		CIDR   string   `json:"cidr"`
+		Except []string `json:"except"`
+	} `json:"ipBlock"`
+}
+
+type networkPolicyPort struct {
+	Protocol string `json:"protocol"`
+	// Port is a number, or the name of a port of the destination pod.
+	Port    any `json:"port"`
+	EndPort int `json:"endPort"`
+}
+
+type labelSelector struct {
+	MatchLabels      map[string]string `json:"matchLabels"`
+	MatchExpressions []struct {
+		Key      string   `json:"key"`
+		Operator string   `json:"operator"`
+		Values   []string `json:"values"`
+	} `json:"matchExpressions"`
+}
+
+// matches reports whether the selector selects an object with the labels. An
+// empty selector selects all objects.
+func (s *labelSelector) matches(labels map[string]string) bool {
+	for k, v := range s.MatchLabels {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	for _, e := range s.MatchExpressions {
+		value, ok := labels[e.Key]
+		switch e.Operator {
+		case "In":
+			if !ok || !slices.Contains(e.Values, value) {
+				return false
+			}
+		case "NotIn":
+			if ok && slices.Contains(e.Values, value) {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// policyEndpoint is the source or a destination of the traffic.
+type policyEndpoint struct {
+	// Namespace is empty for endpoints outside of the cluster.
+	Namespace       string
+	NamespaceLabels map[string]string
+	Labels          map[string]string
+	// IP is the address of the endpoint, if known.
+	IP string
+	// ContainerPorts maps the names of the ports of a destination pod to
+	// their number.
+	ContainerPorts map[string]int
+	// Name describes the endpoint, e.g. "pod shop/web-1".
+	Name string
+}
+
+// policyPort is the destination port of the traffic.
+type policyPort struct {
+	Protocol string
+	Number   int
+	// Name is the name of the port on the destination pod, if known.
+	Name string
+	// resolved is set when the named ports of the destination are known, so
+	// that a port without a name matches no named port.
+	resolved bool
+}
+
+// on resolves the port on a destination pod, from its name or number.
+func (p policyPort) on(destination policyEndpoint) policyPort {
+	p.resolved = destination.ContainerPorts != nil
+	for name, number := range destination.ContainerPorts {
+		if p.Number == 0 && name == p.Name {
+			p.Number = number
+		} else if p.Name == "" && number == p.Number {
+			p.Name = name
+		}
+	}
+	return p
+}
+
+func (p policyPort) String() string {
+	if p.Number == 0 {
+		return p.Protocol + "/" + p.Name
+	}
+	return fmt.Sprintf("%s/%d", p.Protocol, p.Number)
+}
+
+// policyMatch is the result of the evaluation of a part of a policy, unknown
+// when it depends on information missing, e.g. the IP of an external host.
+type policyMatch int
+
+const (
+	policyNoMatch policyMatch = iota
+	policyMatches
+	policyMatchUnknown
+)
+
+// anyMatch combines the results of alternatives.
+func anyMatch(matches ...policyMatch) policyMatch {
+	result := policyNoMatch
+	for _, m := range matches {
+		if m == policyMatches {
+			return policyMatches
+		}
+		if m == policyMatchUnknown {
+			result = policyMatchUnknown
+		}
+	}
+	return result
+}
+
+// appliesTo reports whether the policy restricts the traffic of the given type
+// ("Ingress" or "Egress") of the endpoint.
+func (p *networkPolicy) appliesTo(policyType string, endpoint policyEndpoint) bool {
+	if endpoint.Namespace != p.Metadata.Namespace || !p.Spec.PodSelector.matches(endpoint.Labels) {
+		return false
+	}
+	types := p.Spec.PolicyTypes
+	if len(types) == 0 {
+		// Policies without types restrict the ingress, and the egress if they
+		// have egress rules.
+		types = []string{"Ingress"}
+		if len(p.Spec.Egress) > 0 {
+			types = append(types, "Egress")
+		}
+	}
+	return slices.Contains(types, policyType)
+}
+
+func (p *networkPolicy) name() string {
+	return p.Metadata.Namespace + "/" + p.Metadata.Name
+}
+
+// allows evaluates whether a rule allows the traffic with the peer, to the port.
+func (r *networkPolicyRule) allows(peers []networkPolicyPeer, policyNamespace string, peer policyEndpoint, port policyPort) policyMatch {
+	portMatch := policyMatches
+	if len(r.Ports) > 0 {
+		var matches []policyMatch
+		for _, p := range r.Ports {
+			matches = append(matches, p.matches(port))
+		}
+		portMatch = anyMatch(matches...)
+	}
+	if portMatch == policyNoMatch {
+		return policyNoMatch
+	}
+
+	peerMatch := policyMatches
+	if len(peers) > 0 {
+		var matches []policyMatch
+		for _, p := range peers {
+			matches = append(matches, p.matches(policyNamespace, peer))
+		}
+		peerMatch = anyMatch(matches...)
+	}
+	if peerMatch == policyMatches && portMatch == policyMatches {
+		return policyMatches
+	}
+	if peerMatch == policyNoMatch {
+		return policyNoMatch
+	}
+	return policyMatchUnknown
+}
+
+func (p *networkPolicyPeer) matches(policyNamespace string, peer policyEndpoint) policyMatch {
+	if p.IPBlock != nil {
+		addr, err := netip.ParseAddr(peer.IP)
+		if err != nil {
+			return policyMatchUnknown
+		}
+		if !prefixContains(p.IPBlock.CIDR, addr) {
+			return policyNoMatch
+		}
+		for _, except := range p.IPBlock.Except {
+			if prefixContains(except, addr) {
+				return policyNoMatch
+			}
+		}
+		return policyMatches
+	}
+	// Pod and namespace selectors only select pods.
+	if peer.Namespace == "" {
+		return policyNoMatch
+	}
+	if p.NamespaceSelector == nil && peer.Namespace != policyNamespace {
+		return policyNoMatch
+	}
+	if p.NamespaceSelector != nil && !p.NamespaceSelector.matches(peer.NamespaceLabels) {
+		return policyNoMatch
+	}
+	if p.PodSelector != nil && !p.PodSelector.matches(peer.Labels) {
+		return policyNoMatch
+	}
+	return policyMatches
+}
+
+func prefixContains(cidr string, addr netip.Addr) bool {
+	prefix, err := netip.ParsePrefix(cidr)
+	return err == nil && prefix.Contains(addr)
+}
+
+func (p *networkPolicyPort) matches(port policyPort) policyMatch {
+	protocol := p.Protocol
+	if protocol == "" {
+		protocol = "TCP"
+	}
+	if protocol != port.Protocol {
+		return policyNoMatch
+	}
+	switch v := p.Port.(type) {
+	case nil:
+		return policyMatches
+	case float64:
+		if port.Number == 0 {
+			return policyMatchUnknown
+		}
+		end := p.EndPort
+		if end == 0 {
+			end = int(v)
+		}
+		if port.Number >= int(v) && port.Number <= end {
+			return policyMatches
+		}
+		return policyNoMatch
+	case string:
+		if port.Name == "" && !port.resolved {
+			return policyMatchUnknown
+		}
+		if v == port.Name {
+			return policyMatches
+		}
+		return policyNoMatch
+	default:
+		return policyMatchUnknown
+	}
+}
+
+// allowedBy evaluates whether the policies of a type restricting the endpoint
+// allow the traffic with the peer, and returns their names.
+func allowedBy(policies []networkPolicy, policyType string, endpoint, peer policyEndpoint, port policyPort) (policyMatch, []string) {
+	var names []string
+	var matches []policyMatch
+	for i := range policies {
+		policy := &policies[i]
+		if !policy.appliesTo(policyType, endpoint) {
+			continue
+		}
+		names = append(names, policy.name())
+		rules, peers := policy.Spec.Ingress, func(r *networkPolicyRule) []networkPolicyPeer { return r.From }
+		if policyType == "Egress" {
+			rules, peers = policy.Spec.Egress, func(r *networkPolicyRule) []networkPolicyPeer { return r.To }
+		}
+		for j := range rules {
+			matches = append(matches, rules[j].allows(peers(&rules[j]), policy.Metadata.Namespace, peer, port))
+		}
+	}
+	if len(names) == 0 {
+		return policyMatches, nil
+	}
+	return anyMatch(matches...), names
+}
+
+// simulateNetworkPolicies evaluates whether the policies allow the traffic of
+// the source to the destinations on the port.
+func simulateNetworkPolicies(policies []networkPolicy, source policyEndpoint, destinations []policyEndpoint, port policyPort) *NetworkPolicyVerdict {
+	verdict := &NetworkPolicyVerdict{}
+	if len(destinations) == 0 {
+		verdict.Verdict = "unknown"
+		verdict.Reason = "no destination pod was found"
+		return verdict
+	}
+
+	var allowed, denied, unknown []string
+	for _, destination := range destinations {
+		port := port.on(destination)
+		egress, egressPolicies := allowedBy(policies, "Egress", source, destination, port)
+		ingress, ingressPolicies := allowedBy(policies, "Ingress", destination, source, port)
+		verdict.EgressPolicies = egressPolicies
+		for _, name := range ingressPolicies {
+			if !slices.Contains(verdict.IngressPolicies, name) {
+				verdict.IngressPolicies = append(verdict.IngressPolicies, name)
+			}
+		}
+		switch {
+		case egress == policyNoMatch:
+			denied = append(denied, fmt.Sprintf("the egress policies of %s (%s) don't allow %s to %s", source.Name, strings.Join(egressPolicies, ", "), port, destination.Name))
+		case ingress == policyNoMatch:
+			denied = append(denied, fmt.Sprintf("the ingress policies of %s (%s) don't allow %s from %s", destination.Name, strings.Join(ingressPolicies, ", "), port, source.Name))
+		case egress == policyMatchUnknown || ingress == policyMatchUnknown:
+			unknown = append(unknown, fmt.Sprintf("whether the policies allow %s to %s depends on its address or named ports", port, destination.Name))
+		default:
+			allowed = append(allowed, destination.Name)
+		}
+	}
+
+	switch {
+	case len(allowed) == len(destinations):
+		verdict.Verdict = "allowed"
+		if len(verdict.EgressPolicies) == 0 && len(verdict.IngressPolicies) == 0 {
+			verdict.Reason = "no NetworkPolicy restricts this traffic"
+		} else {
+			verdict.Reason = fmt.Sprintf("the NetworkPolicies allow %s to %s", port, strings.Join(allowed, ", "))
+		}
+	case len(denied) == len(destinations):
+		verdict.Verdict = "denied"
+		verdict.Reason = strings.Join(denied, "; ")
+	case len(unknown) > 0 && len(allowed) == 0:
+		verdict.Verdict = "unknown"
+		verdict.Reason = strings.Join(append(unknown, denied...), "; ")
+	default:
+		verdict.Verdict = "partial"
+		verdict.Reason = fmt.Sprintf("allowed to %s; %s", strings.Join(allowed, ", "), strings.Join(append(denied, unknown...), "; "))
+	}
+	return verdict
+}
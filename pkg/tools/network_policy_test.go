@@ -0,0 +1,72 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSimulateNetworkPolicies(t *testing.T) {
+	// The db only accepts traffic from the api on its named port, the web
+	// pods can only reach the api and DNS.
+	var policies objectList[networkPolicy]
+	if err := json.Unmarshal([]byte(`{"items":[
+		{"metadata":{"name":"db-from-api","namespace":"shop"},
+		 "spec":{"podSelector":{"matchLabels":{"app":"db"}},
+		         "ingress":[{"from":[{"podSelector":{"matchLabels":{"app":"api"}}}],"ports":[{"port":"postgres"}]}]}},
+		{"metadata":{"name":"web-egress","namespace":"shop"},
+		 "spec":{"podSelector":{"matchExpressions":[{"key":"app","operator":"In","values":["web"]}]},"policyTypes":["Egress"],
+		         "egress":[{"to":[{"podSelector":{"matchLabels":{"app":"api"}}}]},
+		                   {"to":[{"namespaceSelector":{"matchLabels":{"kubernetes.io/metadata.name":"kube-system"}}}],"ports":[{"protocol":"UDP","port":53}]},
+		                   {"to":[{"ipBlock":{"cidr":"0.0.0.0/0","except":["10.0.0.0/8"]}}],"ports":[{"port":443}]}]}}]}`), &policies); err != nil {
+		t.Fatal(err)
+	}
+	shop := map[string]string{"kubernetes.io/metadata.name": "shop"}
+	web := policyEndpoint{Namespace: "shop", NamespaceLabels: shop, Labels: map[string]string{"app": "web"}, Name: "pod shop/web-1"}
+	api := policyEndpoint{Namespace: "shop", NamespaceLabels: shop, Labels: map[string]string{"app": "api"}, Name: "pod shop/api-1"}
+	db := policyEndpoint{Namespace: "shop", NamespaceLabels: shop, Labels: map[string]string{"app": "db"}, ContainerPorts: map[string]int{"postgres": 5432}, Name: "pod shop/db-0"}
+	dns := policyEndpoint{Namespace: "kube-system", NamespaceLabels: map[string]string{"kubernetes.io/metadata.name": "kube-system"}, Labels: map[string]string{"k8s-app": "kube-dns"}, Name: "pod kube-system/coredns-1"}
+	tcp := func(port int) policyPort { return policyPort{Protocol: "TCP", Number: port} }
+
+	tests := []struct {
+		name         string
+		source       policyEndpoint
+		destinations []policyEndpoint
+		port         policyPort
+		want         string
+	}{
+		{name: "egress allowed to the api", source: web, destinations: []policyEndpoint{api}, port: tcp(8080), want: "allowed"},
+		{name: "egress denied to the db", source: web, destinations: []policyEndpoint{db}, port: tcp(5432), want: "denied"},
+		{name: "dns", source: web, destinations: []policyEndpoint{dns}, port: policyPort{Protocol: "UDP", Number: 53}, want: "allowed"},
+		{name: "ingress allowed on the named port", source: api, destinations: []policyEndpoint{db}, port: tcp(5432), want: "allowed"},
+		{name: "ingress denied on another port", source: api, destinations: []policyEndpoint{db}, port: tcp(22), want: "denied"},
+		{name: "external address", source: web, destinations: []policyEndpoint{{IP: "203.0.113.7", Name: "203.0.113.7"}}, port: tcp(443), want: "allowed"},
+		{name: "excepted address", source: web, destinations: []policyEndpoint{{IP: "10.1.2.3", Name: "10.1.2.3"}}, port: tcp(443), want: "denied"},
+		{name: "external host without address", source: web, destinations: []policyEndpoint{{Name: "api.example.com"}}, port: tcp(443), want: "unknown"},
+		{name: "some pods", source: web, destinations: []policyEndpoint{api, db}, port: tcp(5432), want: "partial"},
+		{name: "no policy", source: api, destinations: []policyEndpoint{web}, port: tcp(80), want: "allowed"},
+		{name: "no destination", source: web, port: tcp(80), want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := simulateNetworkPolicies(policies.Items, tt.source, tt.destinations, tt.port)
+			if got.Verdict != tt.want {
+				t.Errorf("simulateNetworkPolicies() = %+v, want verdict %q", got, tt.want)
+			}
+		})
+	}
+}
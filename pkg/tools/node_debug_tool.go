@@ -0,0 +1,223 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	RegisterTool(&NodeDebug{})
+}
+
+const (
+	// nodeDebugImage is the image of the debugger pod, the diagnostics run
+	// the binaries of the node with chroot.
+	nodeDebugImage   = "busybox"
+	nodeDebugTimeout = 2 * time.Minute
+
+	defaultNodeDebugLines = 100
+	maxNodeDebugLines     = 500
+	defaultNodeDebugSince = time.Hour
+	maxNodeDebugSince     = 24 * time.Hour
+)
+
+// nodeDebugDiagnostics are the commands that can be run on nodes.
+var nodeDebugDiagnostics = []string{"dmesg", "df", "journalctl"}
+
+// nodeDebugUnits are the systemd units whose journal can be read.
+var nodeDebugUnits = []string{"kubelet", "containerd", "crio", "docker", "kube-proxy", "systemd-journald", "google-guest-agent"}
+
+// dnsSubdomainPattern matches the names of most resources, e.g. nodes and pods.
+var dnsSubdomainPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]{0,251}[a-z0-9])?$`)
+
+// debugPodPattern extracts the name of the debugger pod from the output of kubectl debug.
+var debugPodPattern = regexp.MustCompile(`Creating debugging pod (\S+) with container`)
+
+// NodeDebug runs diagnostic commands on a node, in a privileged debugger pod
+// created with "kubectl debug node". Only the diagnostics of an allowlist can
+// run, with arguments built by the tool, and the pod is deleted afterwards.
+type NodeDebug struct{}
+
+func (t *NodeDebug) Name() string {
+	return "node_debug"
+}
+
+func (t *NodeDebug) Description() string {
+	return `Runs a read-only diagnostic command on a Kubernetes node, in a privileged debugger pod created with "kubectl debug node" and deleted afterwards.
+Use this tool to investigate node-level problems that the Kubernetes API doesn't show, e.g. disk pressure, kernel errors (OOM kills, hardware or filesystem errors) or kubelet and container runtime logs.
+Only these diagnostics are available:
+- "dmesg": the last lines of the kernel ring buffer
+- "df": the disk usage of the filesystems of the node (or their inode usage)
+- "journalctl": the last lines of the journal of a systemd unit, e.g. kubelet or containerd`
+}
+
+func (t *NodeDebug) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"node": {
+					Type:        gollm.TypeString,
+					Description: `The name of the node, as listed by "kubectl get nodes".`,
+				},
+				"diagnostic": {
+					Type:        gollm.TypeString,
+					Description: `The diagnostic to run: "dmesg", "df" or "journalctl".`,
+				},
+				"unit": {
+					Type:        gollm.TypeString,
+					Description: fmt.Sprintf(`For journalctl, the systemd unit whose journal to read, one of %s. Defaults to "kubelet".`, strings.Join(nodeDebugUnits, ", ")),
+				},
+				"since": {
+					Type:        gollm.TypeString,
+					Description: `For journalctl, how far back to read the journal, as a duration (e.g. "30m", "2h"). Defaults to "1h", at most "24h".`,
+				},
+				"lines": {
+					Type:        gollm.TypeInteger,
+					Description: fmt.Sprintf(`For dmesg and journalctl, the number of most recent lines to return. Defaults to %d, at most %d.`, defaultNodeDebugLines, maxNodeDebugLines),
+				},
+				"inodes": {
+					Type:        gollm.TypeBoolean,
+					Description: `For df, report the inode usage instead of the disk usage.`,
+				},
+			},
+			Required: []string{"node", "diagnostic"},
+		},
+	}
+}
+
+func (t *NodeDebug) Run(ctx context.Context, args map[string]any) (any, error) {
+	kubeconfig, _ := ctx.Value(KubeconfigKey).(string)
+	workDir, _ := ctx.Value(WorkDirKey).(string)
+
+	node, _ := args["node"].(string)
+	if !dnsSubdomainPattern.MatchString(node) {
+		return &ExecResult{Error: fmt.Sprintf("invalid node name %q", node)}, nil
+	}
+	command, lines, err := nodeDebugCommand(args)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+
+	if kubeconfig != "" {
+		if kubeconfig, err = expandShellVar(kubeconfig); err != nil {
+			return nil, err
+		}
+	}
+	kubectlCmd := func(ctx context.Context, args ...string) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, "kubectl", args...)
+		cmd.Env = commandEnv(ctx)
+		cmd.Dir = workDir
+		if kubeconfig != "" {
+			cmd.Env = append(cmd.Env, "KUBECONFIG="+kubeconfig)
+		}
+		return cmd
+	}
+
+	debugCtx, cancel := context.WithTimeout(ctx, nodeDebugTimeout)
+	defer cancel()
+	debugArgs := append([]string{"debug", "node/" + node, "--image=" + nodeDebugImage, "--profile=sysadmin", "--attach=true", "--", "chroot", "/host"}, command...)
+	result, err := executeCommand(debugCtx, kubectlCmd(debugCtx, debugArgs...))
+	if err != nil {
+		return nil, fmt.Errorf("running kubectl debug: %w", err)
+	}
+
+	// The debugger pod stays after the command completed.
+	if m := debugPodPattern.FindStringSubmatch(result.Stderr); m != nil {
+		if out, err := kubectlCmd(ctx, "delete", "pod", m[1], "--wait=false").CombinedOutput(); err != nil {
+			klog.Warningf("deleting debugger pod %s: %v: %s", m[1], err, out)
+			result.Stderr += fmt.Sprintf("\nThe debugger pod %s could not be deleted: %v", m[1], err)
+		}
+	}
+	if lines > 0 {
+		result.Stdout = lastLines(result.Stdout, lines)
+	}
+	return result, nil
+}
+
+// nodeDebugCommand returns the command line of the diagnostic requested by
+// args, and the number of lines of its output to keep (0 for all of them).
+func nodeDebugCommand(args map[string]any) ([]string, int, error) {
+	lines := defaultNodeDebugLines
+	if n, ok := args["lines"].(float64); ok && n > 0 {
+		lines = min(int(n), maxNodeDebugLines)
+	}
+
+	diagnostic, _ := args["diagnostic"].(string)
+	switch diagnostic {
+	case "dmesg":
+		return []string{"dmesg", "-T"}, lines, nil
+	case "df":
+		if inodes, _ := args["inodes"].(bool); inodes {
+			return []string{"df", "-i"}, 0, nil
+		}
+		return []string{"df", "-h"}, 0, nil
+	case "journalctl":
+		unit, _ := args["unit"].(string)
+		if unit == "" {
+			unit = "kubelet"
+		}
+		if !slices.Contains(nodeDebugUnits, unit) {
+			return nil, 0, fmt.Errorf("unit %q is not allowed, expected one of %s", unit, strings.Join(nodeDebugUnits, ", "))
+		}
+		since := defaultNodeDebugSince
+		if s, ok := args["since"].(string); ok && s != "" {
+			parsed, err := time.ParseDuration(s)
+			if err != nil || parsed <= 0 {
+				return nil, 0, fmt.Errorf("invalid since %q, expected a positive duration like 30m or 2h", s)
+			}
+			since = min(parsed, maxNodeDebugSince)
+		}
+		return []string{"journalctl", "--no-pager", "--unit=" + unit, fmt.Sprintf("--since=-%ds", int(since.Seconds())), fmt.Sprintf("--lines=%d", lines)}, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("diagnostic %q is not allowed, expected one of %s", diagnostic, strings.Join(nodeDebugDiagnostics, ", "))
+	}
+}
+
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return strings.Join(lines[len(lines)-n:], "") + "\n"
+}
+
+// OutputSchema returns the schema of ExecResult.
+func (t *NodeDebug) OutputSchema() *gollm.Schema {
+	return execResultSchema()
+}
+
+func (t *NodeDebug) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+// CheckModifiesResource reports that the tool modifies resources: it creates
+// a privileged pod on the node, so that its calls are confirmed by the user.
+func (t *NodeDebug) CheckModifiesResource(args map[string]any) string {
+	return "yes"
+}
@@ -0,0 +1,121 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNodeDebugCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]any
+		want      []string
+		wantLines int
+		wantErr   string
+	}{
+		{
+			name:      "dmesg",
+			args:      map[string]any{"diagnostic": "dmesg", "lines": float64(50)},
+			want:      []string{"dmesg", "-T"},
+			wantLines: 50,
+		},
+		{
+			name:      "dmesg lines are capped",
+			args:      map[string]any{"diagnostic": "dmesg", "lines": float64(100000)},
+			want:      []string{"dmesg", "-T"},
+			wantLines: maxNodeDebugLines,
+		},
+		{
+			name: "df inodes",
+			args: map[string]any{"diagnostic": "df", "inodes": true},
+			want: []string{"df", "-i"},
+		},
+		{
+			name: "journalctl defaults to kubelet",
+			args: map[string]any{"diagnostic": "journalctl"},
+			want: []string{"journalctl", "--no-pager", "--unit=kubelet", "--since=-3600s", "--lines=100"},
+		},
+		{
+			name: "journalctl since is capped",
+			args: map[string]any{"diagnostic": "journalctl", "unit": "containerd", "since": "72h", "lines": float64(20)},
+			want: []string{"journalctl", "--no-pager", "--unit=containerd", "--since=-86400s", "--lines=20"},
+		},
+		{
+			name:    "unit not allowed",
+			args:    map[string]any{"diagnostic": "journalctl", "unit": "kubelet; rm -rf /"},
+			wantErr: "is not allowed",
+		},
+		{
+			name:    "invalid since",
+			args:    map[string]any{"diagnostic": "journalctl", "since": "yesterday"},
+			wantErr: "invalid since",
+		},
+		{
+			name:    "diagnostic not allowed",
+			args:    map[string]any{"diagnostic": "cat /etc/shadow"},
+			wantErr: "is not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, lines, err := nodeDebugCommand(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("nodeDebugCommand() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nodeDebugCommand() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || lines != tt.wantLines {
+				t.Errorf("nodeDebugCommand() = %q, %d, want %q, %d", got, lines, tt.want, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestNodeDebugInvalidNode(t *testing.T) {
+	ctx := context.WithValue(context.Background(), KubeconfigKey, "")
+	result, err := (&NodeDebug{}).Run(ctx, map[string]any{"node": "node-1 --image=evil", "diagnostic": "df"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := result.(*ExecResult).Error; !strings.Contains(got, "invalid node name") {
+		t.Errorf("Run() error = %q, want the node name refused", got)
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{s: "a\nb\nc\n", n: 2, want: "b\nc\n"},
+		{s: "a\nb\nc", n: 2, want: "b\nc\n"},
+		{s: "a\nb\n", n: 5, want: "a\nb\n"},
+	}
+	for _, tt := range tests {
+		if got := lastLines(tt.s, tt.n); got != tt.want {
+			t.Errorf("lastLines(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
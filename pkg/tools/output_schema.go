@@ -0,0 +1,161 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+// OutputSchemaTool is implemented by tools declaring the schema of their
+// structured result. The schema is described to the LLM along with the tool,
+// and results are validated against it before being sent to the LLM.
+type OutputSchemaTool interface {
+	Tool
+
+	// OutputSchema returns the schema of the result of Run, once converted
+	// with ToolResultToMap.
+	OutputSchema() *gollm.Schema
+}
+
+// OutputSchemaOf returns the output schema of a tool, or nil if it doesn't declare one.
+func OutputSchemaOf(tool Tool) *gollm.Schema {
+	if t, ok := tool.(OutputSchemaTool); ok {
+		return t.OutputSchema()
+	}
+	return nil
+}
+
+// FunctionDefinitionOf returns the function definition of a tool, with the
+// schema of its result appended to the description if it declares one.
+func FunctionDefinitionOf(tool Tool) *gollm.FunctionDefinition {
+	def := tool.FunctionDefinition()
+	schema := OutputSchemaOf(tool)
+	if schema == nil {
+		return def
+	}
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return def
+	}
+	withOutput := *def
+	withOutput.Description += "\n\nThe result of the tool is a JSON object with the following schema:\n" + string(b)
+	return &withOutput
+}
+
+// execResultSchema is the schema of ExecResult, the result of command tools.
+func execResultSchema() *gollm.Schema {
+	return &gollm.Schema{
+		Type: gollm.TypeObject,
+		Properties: map[string]*gollm.Schema{
+			"command":     {Type: gollm.TypeString, Description: "The command that was run."},
+			"stdout":      {Type: gollm.TypeString, Description: "The standard output of the command."},
+			"stderr":      {Type: gollm.TypeString, Description: "The standard error of the command."},
+			"exit_code":   {Type: gollm.TypeInteger, Description: "The exit code of the command, omitted when 0."},
+			"error":       {Type: gollm.TypeString, Description: "Why the command failed or wasn't run, omitted on success."},
+			"stream_type": {Type: gollm.TypeString, Description: `Set for streaming commands stopped after a timeout: "watch", "logs", "attach" or "timeout".`},
+			"attempts":    {Type: gollm.TypeInteger, Description: "The number of times the command was run, set when it was retried automatically after transient cluster errors."},
+		},
+	}
+}
+
+// ValidateOutput checks a tool result converted with ToolResultToMap against
+// the output schema of the tool, and returns the violations found.
+func ValidateOutput(schema *gollm.Schema, result map[string]any) []string {
+	if schema == nil {
+		return nil
+	}
+	return validateValue(schema, result, "result")
+}
+
+func validateValue(schema *gollm.Schema, value any, path string) []string {
+	if schema == nil || value == nil {
+		return nil
+	}
+
+	var violations []string
+	switch schema.Type {
+	case gollm.TypeObject:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %s", path, jsonType(value))}
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				violations = append(violations, fmt.Sprintf("%s: missing required field %q", path, name))
+			}
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, ok := obj[name]; ok {
+				violations = append(violations, validateValue(schema.Properties[name], v, path+"."+name)...)
+			}
+		}
+	case gollm.TypeArray:
+		items, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %s", path, jsonType(value))}
+		}
+		for i, item := range items {
+			violations = append(violations, validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case gollm.TypeString:
+		if _, ok := value.(string); !ok {
+			violations = append(violations, fmt.Sprintf("%s: expected a string, got %s", path, jsonType(value)))
+		}
+	case gollm.TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			violations = append(violations, fmt.Sprintf("%s: expected a boolean, got %s", path, jsonType(value)))
+		}
+	case gollm.TypeNumber:
+		if _, ok := value.(float64); !ok {
+			violations = append(violations, fmt.Sprintf("%s: expected a number, got %s", path, jsonType(value)))
+		}
+	case gollm.TypeInteger:
+		if f, ok := value.(float64); !ok || f != math.Trunc(f) {
+			violations = append(violations, fmt.Sprintf("%s: expected an integer, got %s", path, jsonType(value)))
+		}
+	}
+	return violations
+}
+
+// jsonType returns the JSON type of a value decoded from JSON.
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "an integer"
+		}
+		return "a number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
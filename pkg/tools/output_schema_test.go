@@ -0,0 +1,140 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+func TestValidateOutput(t *testing.T) {
+	schema := &gollm.Schema{
+		Type:     gollm.TypeObject,
+		Required: []string{"name"},
+		Properties: map[string]*gollm.Schema{
+			"name":     {Type: gollm.TypeString},
+			"replicas": {Type: gollm.TypeInteger},
+			"ready":    {Type: gollm.TypeBoolean},
+			"pods": {
+				Type:  gollm.TypeArray,
+				Items: &gollm.Schema{Type: gollm.TypeObject, Properties: map[string]*gollm.Schema{"cpu": {Type: gollm.TypeNumber}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		result map[string]any
+		want   []string
+	}{
+		{
+			name:   "valid",
+			result: map[string]any{"name": "web", "replicas": 3.0, "ready": true, "pods": []any{map[string]any{"cpu": 0.5}}},
+		},
+		{
+			name:   "missing required field",
+			result: map[string]any{"replicas": 3.0},
+			want:   []string{`result: missing required field "name"`},
+		},
+		{
+			name:   "wrong types",
+			result: map[string]any{"name": "web", "replicas": 1.5, "ready": "yes", "pods": []any{map[string]any{"cpu": "500m"}}},
+			want: []string{
+				"result.pods[0].cpu: expected a number, got a string",
+				"result.ready: expected a boolean, got a string",
+				"result.replicas: expected an integer, got a number",
+			},
+		},
+		{
+			name:   "unknown fields are allowed",
+			result: map[string]any{"name": "web", "extra": []any{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateOutput(schema, tt.result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateOutput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunctionDefinitionOf(t *testing.T) {
+	def := FunctionDefinitionOf(&Kubectl{})
+	if !strings.Contains(def.Description, `"exit_code":{"type":"integer"`) {
+		t.Errorf("kubectl description doesn't include the output schema: %s", def.Description)
+	}
+	if (&Kubectl{}).FunctionDefinition().Description == def.Description {
+		t.Errorf("FunctionDefinitionOf() modified the definition of the tool")
+	}
+}
+
+func TestCustomToolOutputSchema(t *testing.T) {
+	schema := &gollm.Schema{
+		Type:       gollm.TypeObject,
+		Properties: map[string]*gollm.Schema{"healthy": {Type: gollm.TypeBoolean}},
+	}
+	ctx := context.WithValue(context.Background(), WorkDirKey, t.TempDir())
+
+	tests := []struct {
+		name       string
+		command    string
+		wantResult any
+		wantStdout string
+		wantError  bool
+	}{
+		{
+			name:       "JSON output is parsed",
+			command:    `echo '{"healthy": true}'`,
+			wantResult: map[string]any{"healthy": true},
+		},
+		{
+			name:       "non-JSON output is reported",
+			command:    "echo healthy",
+			wantStdout: "healthy\n",
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, err := NewCustomTool(CustomToolConfig{Name: "health", Command: "echo", OutputSchema: schema})
+			if err != nil {
+				t.Fatalf("NewCustomTool() error = %v", err)
+			}
+			out, err := tool.Run(ctx, map[string]any{"command": tt.command})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			result := out.(*ExecResult)
+			if !reflect.DeepEqual(result.Result, tt.wantResult) || result.Stdout != tt.wantStdout || (result.Error != "") != tt.wantError {
+				t.Errorf("Run() = %+v, want result %v, stdout %q, error %v", result, tt.wantResult, tt.wantStdout, tt.wantError)
+			}
+
+			m, err := ToolResultToMap(result)
+			if err != nil {
+				t.Fatalf("ToolResultToMap() error = %v", err)
+			}
+			if violations := ValidateOutput(tool.OutputSchema(), m); len(violations) > 0 {
+				t.Errorf("result doesn't match the output schema: %v", violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	celtypes "github.com/google/cel-go/common/types"
+)
+
+// PolicyVerdict is the decision of a policy on a tool call.
+type PolicyVerdict string
+
+const (
+	// PolicyAllow runs the call without asking for confirmation.
+	PolicyAllow PolicyVerdict = "allow"
+	// PolicyDeny refuses the call, telling the LLM why.
+	PolicyDeny PolicyVerdict = "deny"
+	// PolicyAsk asks for confirmation, even for read-only calls and with
+	// --skip-permissions.
+	PolicyAsk PolicyVerdict = "ask"
+)
+
+// PolicyRule gives a verdict on the tool calls matching a CEL expression,
+// e.g. deny the deletion of namespaces with:
+//
+//	kubectl.exists(k, k.verb == "delete" && k.resource.matches("^(ns|namespaces?)(/|$)"))
+//
+// The expression can use the following variables:
+//   - tool: the name of the tool, e.g. "kubectl" or "bash"
+//   - args: the arguments of the call, e.g. args.command
+//   - command: the command run by the call, "" if it has none
+//   - modifies: whether the call may modify resources, "yes", "no" or "unknown"
+//   - kubectl: the kubectl commands of the call, with the fields verb, subverb,
+//     resource, namespace, allNamespaces, selector, all, context, filename and
+//     modifies
+//   - namespaces: the namespaces of the kubectl commands, the current one if
+//     not given, and "" when it's unknown or they target all namespaces
+//   - context: the current kubeconfig context
+//   - environment: the environment the current context is classified as, e.g.
+//     "production", see the contextEnvironments setting
+type PolicyRule struct {
+	Name string `json:"name"`
+	// When is the CEL expression selecting the calls the rule applies to.
+	When    string        `json:"when"`
+	Verdict PolicyVerdict `json:"verdict"`
+	// Message explains the verdict, to the LLM for denied calls and to the
+	// approver for the others.
+	Message string `json:"message,omitempty"`
+}
+
+// PolicyInput describes a tool call evaluated by a policy.
+type PolicyInput struct {
+	Tool      string
+	Arguments map[string]any
+	// ModifiesResource is "yes", "no" or "unknown", see Tool.CheckModifiesResource.
+	ModifiesResource string
+	// Namespace is the current namespace, used for the kubectl commands that
+	// don't give one.
+	Namespace   string
+	Context     string
+	Environment string
+}
+
+// PolicyDecision is the verdict of a policy on a tool call, and the rule that
+// gave it.
+type PolicyDecision struct {
+	Verdict PolicyVerdict
+	Rule    string
+	Message string
+}
+
+// Policy evaluates tool calls against rules, the first matching rule giving
+// the verdict.
+type Policy struct {
+	rules    []PolicyRule
+	programs []cel.Program
+}
+
+// NewPolicy compiles the rules of a policy.
+func NewPolicy(rules []PolicyRule) (*Policy, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("tool", cel.StringType),
+		cel.Variable("args", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("command", cel.StringType),
+		cel.Variable("modifies", cel.StringType),
+		cel.Variable("kubectl", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+		cel.Variable("namespaces", cel.ListType(cel.StringType)),
+		cel.Variable("context", cel.StringType),
+		cel.Variable("environment", cel.StringType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating the policy environment: %w", err)
+	}
+
+	p := &Policy{rules: rules}
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("policy rule: name is required")
+		}
+		switch rule.Verdict {
+		case PolicyAllow, PolicyDeny, PolicyAsk:
+		default:
+			return nil, fmt.Errorf("policy rule %q: unsupported verdict %q (supported values: %s, %s, %s)", rule.Name, rule.Verdict, PolicyAllow, PolicyDeny, PolicyAsk)
+		}
+		ast, issues := env.Compile(rule.When)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("policy rule %q: invalid expression: %w", rule.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return nil, fmt.Errorf("policy rule %q: the expression returns %s, not bool", rule.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %q: invalid expression: %w", rule.Name, err)
+		}
+		p.programs = append(p.programs, program)
+	}
+	return p, nil
+}
+
+// Evaluate returns the decision of the first rule matching the call, or nil
+// if none does. A rule that fails to evaluate, e.g. because it reads an
+// argument the call doesn't have, asks for confirmation.
+func (p *Policy) Evaluate(input PolicyInput) *PolicyDecision {
+	if p == nil || len(p.rules) == 0 {
+		return nil
+	}
+	vars := policyVariables(input)
+	for i, program := range p.programs {
+		rule := p.rules[i]
+		out, _, err := program.Eval(vars)
+		if err != nil {
+			return &PolicyDecision{Verdict: PolicyAsk, Rule: rule.Name, Message: fmt.Sprintf("the rule failed to evaluate: %v", err)}
+		}
+		if out == celtypes.True {
+			return &PolicyDecision{Verdict: rule.Verdict, Rule: rule.Name, Message: rule.Message}
+		}
+	}
+	return nil
+}
+
+// policyVariables returns the variables of the CEL expressions of the rules.
+func policyVariables(input PolicyInput) map[string]any {
+	args := input.Arguments
+	if args == nil {
+		args = map[string]any{}
+	}
+	command, _ := args["command"].(string)
+
+	kubectl := []any{}
+	var namespaces []string
+	for _, kc := range ParseKubectlCommands(command) {
+		kubectl = append(kubectl, map[string]any{
+			"verb":          kc.Verb,
+			"subverb":       kc.SubVerb,
+			"resource":      kc.Resource,
+			"namespace":     kc.Namespace,
+			"allNamespaces": kc.AllNamespaces,
+			"selector":      kc.Selector,
+			"all":           kc.All,
+			"context":       kc.Context,
+			"filename":      kc.Filename,
+			"modifies":      kc.Modifies,
+		})
+		switch {
+		case kc.AllNamespaces:
+			namespaces = append(namespaces, "")
+		case kc.Namespace != "":
+			namespaces = append(namespaces, kc.Namespace)
+		default:
+			namespaces = append(namespaces, input.Namespace)
+		}
+	}
+	if len(namespaces) == 0 {
+		// e.g. the calls of other tools, or commands not running kubectl.
+		namespaces = []string{""}
+	}
+
+	return map[string]any{
+		"tool":        input.Tool,
+		"args":        args,
+		"command":     command,
+		"modifies":    input.ModifiesResource,
+		"kubectl":     kubectl,
+		"namespaces":  namespaces,
+		"context":     input.Context,
+		"environment": input.Environment,
+	}
+}
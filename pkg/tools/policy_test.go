@@ -0,0 +1,93 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := NewPolicy([]PolicyRule{
+		{Name: "no-namespace-deletion", Verdict: PolicyDeny, Message: "namespaces are deleted by the platform team",
+			When: `kubectl.exists(k, k.verb == "delete" && k.resource.matches("^(ns|namespaces?)(/|$)"))`},
+		{Name: "production", Verdict: PolicyAsk, When: `environment == "production"`},
+		{Name: "dev-only", Verdict: PolicyDeny, When: `modifies != "no" && !namespaces.all(n, n.startsWith("dev-"))`},
+		{Name: "dev-changes", Verdict: PolicyAllow, When: `modifies != "no"`},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	for _, tc := range []struct {
+		command     string
+		modifies    string
+		environment string
+		want        *PolicyDecision
+	}{
+		{"kubectl delete ns dev-web", "yes", "", &PolicyDecision{Verdict: PolicyDeny, Rule: "no-namespace-deletion", Message: "namespaces are deleted by the platform team"}},
+		{"kubectl get pods && kubectl delete namespace/dev-web", "yes", "", &PolicyDecision{Verdict: PolicyDeny, Rule: "no-namespace-deletion", Message: "namespaces are deleted by the platform team"}},
+		{"kubectl get pods -n prod", "no", "production", &PolicyDecision{Verdict: PolicyAsk, Rule: "production"}},
+		{"kubectl get pods -n prod", "no", "", nil},
+		{"kubectl scale deployment web --replicas=3 -n prod", "yes", "", &PolicyDecision{Verdict: PolicyDeny, Rule: "dev-only"}},
+		{"kubectl delete pods --all -A", "yes", "", &PolicyDecision{Verdict: PolicyDeny, Rule: "dev-only"}},
+		{"helm upgrade web ./chart", "unknown", "", &PolicyDecision{Verdict: PolicyDeny, Rule: "dev-only"}},
+		{"kubectl scale deployment web --replicas=3 -n dev-web", "yes", "", &PolicyDecision{Verdict: PolicyAllow, Rule: "dev-changes"}},
+		// The current namespace is used when the command doesn't give one.
+		{"kubectl rollout restart deployment/web", "yes", "", &PolicyDecision{Verdict: PolicyAllow, Rule: "dev-changes"}},
+	} {
+		got := policy.Evaluate(PolicyInput{
+			Tool:             "bash",
+			Arguments:        map[string]any{"command": tc.command},
+			ModifiesResource: tc.modifies,
+			Namespace:        "dev-shop",
+			Environment:      tc.environment,
+		})
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Errorf("Evaluate(%q) = %+v, want %+v", tc.command, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyEvaluateError(t *testing.T) {
+	policy, err := NewPolicy([]PolicyRule{{Name: "manifest-size", Verdict: PolicyDeny, When: `size(args.manifest) > 10000`}})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	got := policy.Evaluate(PolicyInput{Tool: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}, ModifiesResource: "no"})
+	if got == nil || got.Verdict != PolicyAsk || got.Rule != "manifest-size" || !strings.Contains(got.Message, "failed to evaluate") {
+		t.Errorf("Evaluate() of a failing rule = %+v, want it to ask", got)
+	}
+	if got := (*Policy)(nil).Evaluate(PolicyInput{Tool: "kubectl"}); got != nil {
+		t.Errorf("Evaluate() of no policy = %+v, want nil", got)
+	}
+}
+
+func TestNewPolicyErrors(t *testing.T) {
+	for _, tc := range []struct {
+		rule PolicyRule
+		want string
+	}{
+		{PolicyRule{Verdict: PolicyDeny, When: "true"}, "name is required"},
+		{PolicyRule{Name: "r", Verdict: "block", When: "true"}, `unsupported verdict "block"`},
+		{PolicyRule{Name: "r", Verdict: PolicyDeny, When: "tool =="}, "invalid expression"},
+		{PolicyRule{Name: "r", Verdict: PolicyDeny, When: "unknown_variable"}, "invalid expression"},
+		{PolicyRule{Name: "r", Verdict: PolicyDeny, When: "tool"}, "not bool"},
+	} {
+		if _, err := NewPolicy([]PolicyRule{tc.rule}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("NewPolicy(%+v) = %v, want an error containing %q", tc.rule, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// AccessCheck is a permission a kubectl command needs, checked with
+// "kubectl auth can-i", i.e. a SelfSubjectAccessReview of the current identity.
+type AccessCheck struct {
+	// Verb is the RBAC verb, e.g. patch.
+	Verb string
+	// Resource is the resource type, optionally followed by the name of the
+	// resource, e.g. deployments or deployment/web.
+	Resource    string
+	Subresource string
+	Namespace   string
+	// AllNamespaces is set when the command targets all namespaces.
+	AllNamespaces bool
+	// Context is the kubeconfig context the command runs against, if not the current one.
+	Context string
+}
+
+func (a AccessCheck) String() string {
+	s := a.Verb + " " + a.Resource
+	if a.Subresource != "" {
+		s += " (" + a.Subresource + ")"
+	}
+	switch {
+	case a.AllNamespaces:
+		s += " in all namespaces"
+	case a.Namespace != "":
+		s += " in namespace " + a.Namespace
+	}
+	return s
+}
+
+// kubectlAccessRule is the permission a kubectl verb needs on its target.
+type kubectlAccessRule struct {
+	verb        string
+	subresource string
+	// resource is the type of the target of verbs whose target is implied,
+	// e.g. nodes for cordon. The name of the target is kept if byName is set.
+	resource string
+	byName   bool
+}
+
+// kubectlAccessRules are the permissions needed by the modifying kubectl verbs.
+// Verbs running several requests, e.g. drain, are checked on their main one.
+var kubectlAccessRules = map[string]kubectlAccessRule{
+	"apply":    {verb: "patch"},
+	"create":   {verb: "create"},
+	"replace":  {verb: "update"},
+	"delete":   {verb: "delete"},
+	"patch":    {verb: "patch"},
+	"edit":     {verb: "patch"},
+	"label":    {verb: "patch"},
+	"annotate": {verb: "patch"},
+	"taint":    {verb: "patch"},
+	"set":      {verb: "patch"},
+	"rollout":  {verb: "patch"},
+	"scale":    {verb: "patch", subresource: "scale"},
+	"exec":     {verb: "create", subresource: "exec", resource: "pods"},
+	"attach":   {verb: "create", subresource: "attach", resource: "pods"},
+	"cp":       {verb: "create", subresource: "exec", resource: "pods"},
+	"run":      {verb: "create", resource: "pods"},
+	"cordon":   {verb: "patch", resource: "nodes", byName: true},
+	"uncordon": {verb: "patch", resource: "nodes", byName: true},
+	"drain":    {verb: "create", subresource: "eviction", resource: "pods"},
+	"certificate": {
+		verb: "update", subresource: "approval", resource: "certificatesigningrequests", byName: true,
+	},
+}
+
+// RequiredAccess returns the permissions needed by the modifying kubectl
+// commands of a shell command. Commands whose target isn't known from their
+// arguments, e.g. those applying manifests, are skipped.
+func RequiredAccess(command string) []AccessCheck {
+	var checks []AccessCheck
+	for _, kc := range ParseKubectlCommands(command) {
+		if !kc.Modifies || kc.Filename != "" || kc.Resource == "" {
+			continue
+		}
+		rule, ok := kubectlAccessRules[kc.Verb]
+		if !ok {
+			continue
+		}
+		check := AccessCheck{
+			Verb:          rule.verb,
+			Resource:      kc.Resource,
+			Subresource:   rule.subresource,
+			Namespace:     kc.Namespace,
+			AllNamespaces: kc.AllNamespaces,
+			Context:       kc.Context,
+		}
+		switch {
+		case rule.resource != "" && rule.byName:
+			// The target is a name, possibly prefixed by its type.
+			name := kc.Resource[strings.LastIndex(kc.Resource, "/")+1:]
+			check.Resource = rule.resource + "/" + name
+		case rule.resource != "":
+			check.Resource = rule.resource
+		case kc.Verb == "create":
+			// Names are not authorized on creation, and the positional
+			// arguments of "kubectl create" may not be a name, e.g. in
+			// "kubectl create secret generic".
+			check.Resource, _, _ = strings.Cut(kc.Resource, "/")
+		}
+		if strings.Contains(check.Resource, ",") {
+			// Several resource types can't be checked at once.
+			continue
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// CheckAccess checks whether the current identity has the given permissions,
+// and returns the reasons of the denied ones, by index. Permissions on
+// resource types unknown to the server are not reported as denied.
+func CheckAccess(ctx context.Context, checks []AccessCheck, opt InvokeToolOptions) (map[int]string, error) {
+	env, err := kubectlEnv(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	denied := map[int]string{}
+	for i, check := range checks {
+		args := []string{"auth", "can-i", check.Verb, check.Resource}
+		if check.Subresource != "" {
+			args = append(args, "--subresource="+check.Subresource)
+		}
+		switch {
+		case check.AllNamespaces:
+			args = append(args, "--all-namespaces")
+		case check.Namespace != "":
+			args = append(args, "--namespace="+check.Namespace)
+		}
+		if check.Context != "" {
+			args = append(args, "--context="+check.Context)
+		}
+
+		cmd := exec.CommandContext(ctx, "kubectl", args...)
+		cmd.Dir = opt.WorkDir
+		cmd.Env = env
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+
+		answer := strings.TrimSpace(stdout.String())
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil && answer == "yes":
+		case errors.As(err, &exitErr) && strings.HasPrefix(answer, "no"):
+			if strings.Contains(stderr.String(), "doesn't have a resource type") {
+				continue
+			}
+			reason := fmt.Sprintf("the current identity cannot %s", check)
+			if _, detail, ok := strings.Cut(answer, " - "); ok {
+				reason += ": " + detail
+			}
+			denied[i] = reason
+		default:
+			if err == nil {
+				err = fmt.Errorf("unexpected answer %q", answer)
+			}
+			return nil, fmt.Errorf("checking access to %s: %w: %s", check, err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return denied, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRequiredAccess(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected []AccessCheck
+	}{
+		{
+			command:  "kubectl get pods -n prod",
+			expected: nil,
+		},
+		{
+			command:  "kubectl delete pod nginx -n prod --context=prod-cluster",
+			expected: []AccessCheck{{Verb: "delete", Resource: "pod/nginx", Namespace: "prod", Context: "prod-cluster"}},
+		},
+		{
+			command:  "kubectl scale deployment web --replicas=3",
+			expected: []AccessCheck{{Verb: "patch", Resource: "deployment/web", Subresource: "scale"}},
+		},
+		{
+			command:  "kubectl create secret generic db --from-literal=password=x -n app",
+			expected: []AccessCheck{{Verb: "create", Resource: "secret", Namespace: "app"}},
+		},
+		{
+			command:  "kubectl exec -it web-0 -n app -- sh",
+			expected: []AccessCheck{{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "app"}},
+		},
+		{
+			command:  "kubectl cordon node-1",
+			expected: []AccessCheck{{Verb: "patch", Resource: "nodes/node-1"}},
+		},
+		{
+			command:  "kubectl apply -f deploy.yaml",
+			expected: nil,
+		},
+		{
+			command:  "kubectl delete pods,services -l app=web",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.command, func(t *testing.T) {
+			if got := RequiredAccess(tc.command); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	dir := t.TempDir()
+	// The fake kubectl only allows deleting pods, and doesn't know widgets.
+	writePlugin(t, dir, "kubectl", `case "$3 $4" in
+"delete pod/nginx") echo yes ;;
+"create widgets") echo no; echo "Warning: the server doesn't have a resource type 'widgets'" >&2; exit 1 ;;
+*) echo "no - RBAC: $3 $4 is not allowed"; exit 1 ;;
+esac
+`, 0o755)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	checks := []AccessCheck{
+		{Verb: "delete", Resource: "pod/nginx", Namespace: "prod"},
+		{Verb: "patch", Resource: "deployment/web", Subresource: "scale", Namespace: "prod"},
+		{Verb: "create", Resource: "widgets"},
+	}
+	denied, err := CheckAccess(context.Background(), checks, InvokeToolOptions{WorkDir: dir})
+	if err != nil {
+		t.Fatalf("CheckAccess() error = %v", err)
+	}
+	expected := map[int]string{
+		1: "the current identity cannot patch deployment/web (scale) in namespace prod: RBAC: patch deployment/web is not allowed",
+	}
+	if !reflect.DeepEqual(denied, expected) {
+		t.Errorf("expected %v, got %v", expected, denied)
+	}
+}
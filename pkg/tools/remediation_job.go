@@ -0,0 +1,200 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// remediationPlanDir is where the plan is mounted in the job container.
+	remediationPlanDir = "/etc/kubectl-ai/plan"
+	// remediationJobTTL is how long finished jobs are kept, in seconds.
+	remediationJobTTL = 7 * 24 * 60 * 60
+)
+
+// RemediationJob is a Kubernetes Job running kubectl-ai non-interactively in
+// the cluster to carry out an approved plan, so that long operations don't
+// depend on the machine of the operator staying connected.
+type RemediationJob struct {
+	Name      string
+	Namespace string
+	// Image is a kubectl-ai container image.
+	Image string
+	// ServiceAccount is the service account the job runs as. It needs the
+	// permissions required by the plan.
+	ServiceAccount string
+	// Secret is the name of a secret holding the credentials of the LLM
+	// provider, e.g. GEMINI_API_KEY, set as environment variables.
+	Secret   string
+	Provider string
+	Model    string
+	// Plan is the query given to kubectl-ai.
+	Plan string
+	// Approver and SessionID are recorded as annotations, for auditing.
+	Approver  string
+	SessionID string
+}
+
+// Manifest returns the ConfigMap holding the plan and the Job running it,
+// as a JSON list that can be applied with kubectl.
+func (j *RemediationJob) Manifest() ([]byte, error) {
+	metadata := map[string]any{
+		"name": j.Name,
+		"labels": map[string]string{
+			"app.kubernetes.io/name":      "kubectl-ai",
+			"app.kubernetes.io/component": "remediation",
+		},
+		"annotations": map[string]string{
+			"kubectl-ai/approver": j.Approver,
+			"kubectl-ai/session":  j.SessionID,
+		},
+	}
+	if j.Namespace != "" {
+		metadata["namespace"] = j.Namespace
+	}
+
+	args := []string{"--quiet", "--skip-permissions"}
+	if j.Provider != "" {
+		args = append(args, "--llm-provider", j.Provider)
+	}
+	if j.Model != "" {
+		args = append(args, "--model", j.Model)
+	}
+	// The plan is passed as the query, read from the mounted ConfigMap.
+	script := `exec kubectl-ai "$@" "$(cat ` + remediationPlanDir + `/plan.md)"`
+
+	container := map[string]any{
+		"name":    "kubectl-ai",
+		"image":   j.Image,
+		"command": append([]string{"/bin/sh", "-c", script, "kubectl-ai"}, args...),
+		"volumeMounts": []map[string]any{
+			{"name": "plan", "mountPath": remediationPlanDir, "readOnly": true},
+		},
+	}
+	if j.Secret != "" {
+		container["envFrom"] = []map[string]any{
+			{"secretRef": map[string]string{"name": j.Secret}},
+		}
+	}
+	podSpec := map[string]any{
+		"restartPolicy": "Never",
+		"containers":    []any{container},
+		"volumes": []map[string]any{
+			{"name": "plan", "configMap": map[string]string{"name": j.Name}},
+		},
+	}
+	if j.ServiceAccount != "" {
+		podSpec["serviceAccountName"] = j.ServiceAccount
+	}
+
+	list := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items": []any{
+			map[string]any{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   metadata,
+				"data":       map[string]string{"plan.md": j.Plan},
+			},
+			map[string]any{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   metadata,
+				"spec": map[string]any{
+					// A failed remediation must not be run again without the operator.
+					"backoffLimit":            0,
+					"ttlSecondsAfterFinished": remediationJobTTL,
+					"template": map[string]any{
+						"metadata": map[string]any{"labels": metadata["labels"]},
+						"spec":     podSpec,
+					},
+				},
+			},
+		},
+	}
+	return json.MarshalIndent(list, "", "  ")
+}
+
+// SubmitRemediationJob creates the ConfigMap and the Job of a remediation job.
+func SubmitRemediationJob(ctx context.Context, opt InvokeToolOptions, job *RemediationJob) error {
+	manifest, err := job.Manifest()
+	if err != nil {
+		return fmt.Errorf("generating the manifest of job %s: %w", job.Name, err)
+	}
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+
+	if _, err := kubectlOutputWithStdin(ctx, manifest, "create", "-f", "-"); err != nil {
+		return fmt.Errorf("creating job %s: %w", job.Name, err)
+	}
+	return nil
+}
+
+// RemediationJobStatus describes the status of a remediation job, with the
+// last lines of its logs.
+func RemediationJobStatus(ctx context.Context, opt InvokeToolOptions, namespace, name string) (string, error) {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+
+	nsArgs := []string{}
+	if namespace != "" {
+		nsArgs = []string{"-n", namespace}
+	}
+	out, err := kubectlOutput(ctx, append([]string{"get", "job", name, "-o", "json"}, nsArgs...)...)
+	if err != nil {
+		return "", err
+	}
+	var job struct {
+		Status struct {
+			Active         int    `json:"active"`
+			Succeeded      int    `json:"succeeded"`
+			Failed         int    `json:"failed"`
+			StartTime      string `json:"startTime"`
+			CompletionTime string `json:"completionTime"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(out, &job); err != nil {
+		return "", fmt.Errorf("parsing job %s: %w", name, err)
+	}
+
+	var sb strings.Builder
+	switch {
+	case job.Status.Succeeded > 0:
+		fmt.Fprintf(&sb, "Job %s succeeded (completed at %s).\n", name, job.Status.CompletionTime)
+	case job.Status.Failed > 0:
+		fmt.Fprintf(&sb, "Job %s failed.\n", name)
+	case job.Status.Active > 0:
+		fmt.Fprintf(&sb, "Job %s is running (started at %s).\n", name, job.Status.StartTime)
+	default:
+		fmt.Fprintf(&sb, "Job %s is pending.\n", name)
+	}
+
+	logs, err := kubectlOutput(ctx, append([]string{"logs", "job/" + name, "--tail=20"}, nsArgs...)...)
+	if err != nil {
+		// The pod may not have started yet.
+		return sb.String(), nil
+	}
+	if l := strings.TrimSpace(string(logs)); l != "" {
+		sb.WriteString("\nLast lines of the logs:\n\n```text\n" + l + "\n```\n")
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRemediationJobManifest(t *testing.T) {
+	job := &RemediationJob{
+		Name:           "kubectl-ai-20250101-120000",
+		Namespace:      "ops",
+		Image:          "kubectl-ai:latest",
+		ServiceAccount: "remediation",
+		Secret:         "llm-credentials",
+		Provider:       "gemini",
+		Model:          "gemini-2.5-pro",
+		Plan:           "Drain the nodes of pool-1.",
+		Approver:       "alice",
+		SessionID:      "20250101-123456",
+	}
+	b, err := job.Manifest()
+	if err != nil {
+		t.Fatalf("Manifest() error: %v", err)
+	}
+
+	var list struct {
+		Items []struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name        string            `json:"name"`
+				Namespace   string            `json:"namespace"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+			Data map[string]string `json:"data"`
+			Spec struct {
+				BackoffLimit *int `json:"backoffLimit"`
+				Template     struct {
+					Spec struct {
+						ServiceAccountName string `json:"serviceAccountName"`
+						Containers         []struct {
+							Image   string   `json:"image"`
+							Command []string `json:"command"`
+							EnvFrom []struct {
+								SecretRef struct {
+									Name string `json:"name"`
+								} `json:"secretRef"`
+							} `json:"envFrom"`
+						} `json:"containers"`
+						Volumes []struct {
+							ConfigMap struct {
+								Name string `json:"name"`
+							} `json:"configMap"`
+						} `json:"volumes"`
+					} `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(b, &list); err != nil {
+		t.Fatalf("parsing manifest: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].Kind != "ConfigMap" || list.Items[1].Kind != "Job" {
+		t.Fatalf("manifest items = %+v, want a ConfigMap and a Job", list.Items)
+	}
+
+	cm, j := list.Items[0], list.Items[1]
+	if cm.Data["plan.md"] != job.Plan {
+		t.Errorf("plan.md = %q, want %q", cm.Data["plan.md"], job.Plan)
+	}
+	if j.Metadata.Namespace != "ops" || j.Metadata.Annotations["kubectl-ai/approver"] != "alice" || j.Metadata.Annotations["kubectl-ai/session"] != "20250101-123456" {
+		t.Errorf("job metadata = %+v", j.Metadata)
+	}
+	if j.Spec.BackoffLimit == nil || *j.Spec.BackoffLimit != 0 {
+		t.Errorf("backoffLimit = %v, want 0", j.Spec.BackoffLimit)
+	}
+	pod := j.Spec.Template.Spec
+	if pod.ServiceAccountName != "remediation" {
+		t.Errorf("serviceAccountName = %q, want remediation", pod.ServiceAccountName)
+	}
+	if len(pod.Volumes) != 1 || pod.Volumes[0].ConfigMap.Name != job.Name {
+		t.Errorf("volumes = %+v, want the ConfigMap %s", pod.Volumes, job.Name)
+	}
+	container := pod.Containers[0]
+	if len(container.EnvFrom) != 1 || container.EnvFrom[0].SecretRef.Name != "llm-credentials" {
+		t.Errorf("envFrom = %+v, want the secret llm-credentials", container.EnvFrom)
+	}
+	wantArgs := []string{"kubectl-ai", "--quiet", "--skip-permissions", "--llm-provider", "gemini", "--model", "gemini-2.5-pro"}
+	if got := container.Command[3:]; !reflect.DeepEqual(got, wantArgs) {
+		t.Errorf("command arguments = %q, want %q", got, wantArgs)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// MissingResources returns the resources, given as kind/name references
+// (e.g. "pod/web-0"), that don't exist in the current namespace.
+// References that can't be checked, e.g. of an unknown kind, are not returned.
+func MissingResources(ctx context.Context, opt InvokeToolOptions, refs []string) []string {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+
+	var missing []string
+	for _, ref := range refs {
+		out, err := kubectlOutput(ctx, "get", ref, "--ignore-not-found", "-o", "name")
+		if err != nil {
+			klog.V(2).Infof("unable to check if %s exists: %v", ref, err)
+			continue
+		}
+		if strings.TrimSpace(string(out)) == "" {
+			missing = append(missing, ref)
+		}
+	}
+	return missing
+}
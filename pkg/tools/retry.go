@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// maxKubectlAttempts bounds the number of times a kubectl command failing
+// with a transient cluster error is run.
+const maxKubectlAttempts = 3
+
+// kubectlRetryBackoff is the delay before the first retry, doubled for each retry.
+var kubectlRetryBackoff = time.Second
+
+// transientError is an error of kubectl that is likely to go away when retried.
+type transientError struct {
+	pattern string
+	// unsent is true when the request can't have been processed by the API server,
+	// so that commands modifying resources can be retried too.
+	unsent bool
+}
+
+var transientErrors = []transientError{
+	{pattern: "etcdserver: request timed out"},
+	{pattern: "etcdserver: leader changed"},
+	{pattern: "connect: connection refused", unsent: true},
+	{pattern: "net/http: TLS handshake timeout", unsent: true},
+	// 429 from the API server, e.g. with API priority and fairness.
+	{pattern: "(TooManyRequests)", unsent: true},
+}
+
+// isTransientFailure returns true if the command failed with a transient
+// error, that can be retried depending on whether it modifies resources.
+func isTransientFailure(result *ExecResult, modifiesResource string) bool {
+	if result == nil || result.ExitCode == 0 || result.StreamType != "" {
+		return false
+	}
+	for _, e := range transientErrors {
+		if strings.Contains(result.Stderr, e.pattern) && (e.unsent || modifiesResource == "no") {
+			return true
+		}
+	}
+	return false
+}
+
+// runWithRetries runs a kubectl command, and runs it again with exponential
+// backoff while it fails with a transient error. The number of attempts is
+// recorded in the result when the command was retried, so that the LLM
+// doesn't retry it again.
+func runWithRetries(ctx context.Context, modifiesResource string, run func() (*ExecResult, error)) (*ExecResult, error) {
+	backoff := kubectlRetryBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := run()
+		if err != nil {
+			return nil, err
+		}
+		if attempt > 1 {
+			result.Attempts = attempt
+		}
+		if attempt >= maxKubectlAttempts || !isTransientFailure(result, modifiesResource) {
+			return result, nil
+		}
+
+		klog.Infof("kubectl command failed with a transient error, retrying in %v (attempt %d/%d): %s", backoff, attempt, maxKubectlAttempts, strings.TrimSpace(result.Stderr))
+		select {
+		case <-ctx.Done():
+			return result, nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
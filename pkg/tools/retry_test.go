@@ -0,0 +1,121 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunWithRetries(t *testing.T) {
+	kubectlRetryBackoff = time.Millisecond
+	defer func() { kubectlRetryBackoff = time.Second }()
+
+	const (
+		etcdTimeout = "Error from server: etcdserver: request timed out\n"
+		refused     = "The connection to the server 127.0.0.1:6443 was refused - did you specify the right host or port?\nerror: dial tcp 127.0.0.1:6443: connect: connection refused\n"
+		throttled   = "Error from server (TooManyRequests): the server has received too many requests and has asked us to try again later\n"
+		notFound    = "Error from server (NotFound): pods \"web\" not found\n"
+	)
+	failure := func(stderr string) *ExecResult {
+		return &ExecResult{ExitCode: 1, Stderr: stderr}
+	}
+	success := &ExecResult{Stdout: "ok"}
+
+	tests := []struct {
+		name             string
+		modifiesResource string
+		results          []*ExecResult
+		wantRuns         int
+		wantAttempts     int
+		wantExitCode     int
+	}{
+		{
+			name:             "success",
+			modifiesResource: "no",
+			results:          []*ExecResult{success},
+			wantRuns:         1,
+		},
+		{
+			name:             "transient error then success",
+			modifiesResource: "no",
+			results:          []*ExecResult{failure(etcdTimeout), failure(refused), success},
+			wantRuns:         3,
+			wantAttempts:     3,
+		},
+		{
+			name:             "attempts are bounded",
+			modifiesResource: "no",
+			results:          []*ExecResult{failure(throttled), failure(throttled), failure(throttled), success},
+			wantRuns:         3,
+			wantAttempts:     3,
+			wantExitCode:     1,
+		},
+		{
+			name:             "permanent error",
+			modifiesResource: "no",
+			results:          []*ExecResult{failure(notFound), success},
+			wantRuns:         1,
+			wantExitCode:     1,
+		},
+		{
+			name:             "modifying command not sent",
+			modifiesResource: "yes",
+			results:          []*ExecResult{failure(refused), success},
+			wantRuns:         2,
+			wantAttempts:     2,
+		},
+		{
+			name:             "modifying command maybe processed",
+			modifiesResource: "yes",
+			results:          []*ExecResult{failure(etcdTimeout), success},
+			wantRuns:         1,
+			wantExitCode:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			result, err := runWithRetries(context.Background(), tt.modifiesResource, func() (*ExecResult, error) {
+				r := *tt.results[runs]
+				runs++
+				return &r, nil
+			})
+			if err != nil {
+				t.Fatalf("runWithRetries() error = %v", err)
+			}
+			if runs != tt.wantRuns || result.Attempts != tt.wantAttempts || result.ExitCode != tt.wantExitCode {
+				t.Errorf("runWithRetries() ran %d times and returned attempts %d, exit code %d, want %d, %d, %d", runs, result.Attempts, result.ExitCode, tt.wantRuns, tt.wantAttempts, tt.wantExitCode)
+			}
+		})
+	}
+}
+
+func TestRunKubectlCommandRetries(t *testing.T) {
+	kubectlRetryBackoff = time.Millisecond
+	defer func() { kubectlRetryBackoff = time.Second }()
+
+	// The command fails to connect the first time only.
+	command := `n=$(cat count 2>/dev/null || echo 0); echo $((n+1)) > count; if [ "$n" -ge 1 ]; then echo ok; exit 0; fi; echo "dial tcp 127.0.0.1:6443: connect: connection refused" >&2; exit 1`
+	result, err := runKubectlCommand(context.Background(), command, t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("runKubectlCommand() error = %v", err)
+	}
+	if result.ExitCode != 0 || result.Stdout != "ok\n" || result.Attempts != 2 {
+		t.Errorf("runKubectlCommand() = %+v, want a success after 2 attempts", result)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ScopedAccess is a least-privilege identity for the agent: a ServiceAccount
+// bound to Roles that only grant the selected verbs on the selected resources
+// of the selected namespaces, with a time-bound token.
+type ScopedAccess struct {
+	// Name of the ServiceAccount, Roles and RoleBindings.
+	Name string
+	// Namespaces the agent can access. The ServiceAccount is created in the first one.
+	Namespaces []string
+	// Verbs granted, e.g. get, list, watch.
+	Verbs []string
+	// Resources granted, e.g. pods, deployments.apps. All resources if empty.
+	Resources []string
+	// Duration is the validity of the token.
+	Duration time.Duration
+}
+
+// Validate checks that the access can be created.
+func (a *ScopedAccess) Validate() error {
+	if len(a.Namespaces) == 0 {
+		return fmt.Errorf("at least one namespace is required")
+	}
+	if len(a.Verbs) == 0 {
+		return fmt.Errorf("at least one verb is required")
+	}
+	if a.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	return nil
+}
+
+// Manifest returns the ServiceAccount, Roles and RoleBindings of the access,
+// as a JSON list that can be applied with kubectl.
+func (a *ScopedAccess) Manifest() ([]byte, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	saNamespace := a.Namespaces[0]
+	metadata := func(namespace string) map[string]any {
+		return map[string]any{
+			"name":      a.Name,
+			"namespace": namespace,
+			"labels": map[string]string{
+				"app.kubernetes.io/name":      "kubectl-ai",
+				"app.kubernetes.io/component": "scoped-access",
+			},
+			"annotations": map[string]string{
+				// The token expires by itself, the objects are deleted at the
+				// end of the session and can be garbage collected after this time.
+				"kubectl-ai/expires": time.Now().Add(a.Duration).UTC().Format(time.RFC3339),
+			},
+		}
+	}
+
+	// Resources are given as resource.group, e.g. deployments.apps.
+	rules := []map[string]any{{"apiGroups": []string{"*"}, "resources": []string{"*"}, "verbs": a.Verbs}}
+	if len(a.Resources) > 0 {
+		rules = nil
+		for _, resource := range a.Resources {
+			name, group, _ := strings.Cut(resource, ".")
+			rules = append(rules, map[string]any{"apiGroups": []string{group}, "resources": []string{name}, "verbs": a.Verbs})
+		}
+	}
+
+	items := []any{
+		map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ServiceAccount",
+			"metadata":   metadata(saNamespace),
+		},
+	}
+	for _, namespace := range a.Namespaces {
+		items = append(items,
+			map[string]any{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "Role",
+				"metadata":   metadata(namespace),
+				"rules":      rules,
+			},
+			map[string]any{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "RoleBinding",
+				"metadata":   metadata(namespace),
+				"roleRef": map[string]string{
+					"apiGroup": "rbac.authorization.k8s.io",
+					"kind":     "Role",
+					"name":     a.Name,
+				},
+				"subjects": []map[string]string{
+					{"kind": "ServiceAccount", "name": a.Name, "namespace": saNamespace},
+				},
+			},
+		)
+	}
+	return json.MarshalIndent(map[string]any{"apiVersion": "v1", "kind": "List", "items": items}, "", "  ")
+}
+
+// CreateScopedKubeconfig creates the access in the cluster of the kubeconfig
+// of opt, which needs the permissions to grant it, and writes a kubeconfig
+// using the token of the access to path.
+func CreateScopedKubeconfig(ctx context.Context, opt InvokeToolOptions, access *ScopedAccess, path string) error {
+	manifest, err := access.Manifest()
+	if err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+
+	// The cluster is read first, to fail before creating anything if the kubeconfig is unusable.
+	current, err := kubectlOutput(ctx, "config", "view", "--minify", "--flatten", "-o", "json")
+	if err != nil {
+		return err
+	}
+	if _, err := kubectlOutputWithStdin(ctx, manifest, "create", "-f", "-"); err != nil {
+		return fmt.Errorf("creating service account %s: %w", access.Name, err)
+	}
+	token, err := kubectlOutput(ctx, "create", "token", access.Name, "-n", access.Namespaces[0], "--duration", access.Duration.String())
+	if err != nil {
+		return fmt.Errorf("creating token of service account %s: %w", access.Name, err)
+	}
+
+	kubeconfig, err := scopedKubeconfig(current, access, strings.TrimSpace(string(token)))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, kubeconfig, 0o600); err != nil {
+		return fmt.Errorf("writing kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// DeleteScopedAccess deletes the objects of the access.
+func DeleteScopedAccess(ctx context.Context, opt InvokeToolOptions, access *ScopedAccess) error {
+	manifest, err := access.Manifest()
+	if err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+
+	if _, err := kubectlOutputWithStdin(ctx, manifest, "delete", "--ignore-not-found", "-f", "-"); err != nil {
+		return fmt.Errorf("deleting service account %s: %w", access.Name, err)
+	}
+	return nil
+}
+
+// scopedKubeconfig returns a kubeconfig for the cluster of current, the
+// output of "kubectl config view --minify --flatten -o json", authenticating
+// with the token of the access.
+func scopedKubeconfig(current []byte, access *ScopedAccess, token string) ([]byte, error) {
+	var config struct {
+		Clusters []struct {
+			Name    string         `json:"name"`
+			Cluster map[string]any `json:"cluster"`
+		} `json:"clusters"`
+	}
+	if err := json.Unmarshal(current, &config); err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	if len(config.Clusters) != 1 {
+		return nil, fmt.Errorf("expected the current cluster in the kubeconfig, found %d clusters", len(config.Clusters))
+	}
+	cluster := config.Clusters[0]
+
+	return json.MarshalIndent(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Config",
+		"clusters":   []any{map[string]any{"name": cluster.Name, "cluster": cluster.Cluster}},
+		"users":      []any{map[string]any{"name": access.Name, "user": map[string]string{"token": token}}},
+		"contexts": []any{map[string]any{
+			"name": access.Name,
+			"context": map[string]string{
+				"cluster":   cluster.Name,
+				"user":      access.Name,
+				"namespace": access.Namespaces[0],
+			},
+		}},
+		"current-context": access.Name,
+	}, "", "  ")
+}
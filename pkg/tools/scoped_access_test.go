@@ -0,0 +1,165 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScopedAccessManifest(t *testing.T) {
+	type rule struct {
+		APIGroups []string `json:"apiGroups"`
+		Resources []string `json:"resources"`
+		Verbs     []string `json:"verbs"`
+	}
+	tests := []struct {
+		name      string
+		access    ScopedAccess
+		wantKinds []string
+		wantRules []rule
+		wantErr   bool
+	}{
+		{
+			name:      "all resources",
+			access:    ScopedAccess{Name: "kubectl-ai-1", Namespaces: []string{"shop"}, Verbs: []string{"get", "list"}, Duration: time.Hour},
+			wantKinds: []string{"ServiceAccount", "Role", "RoleBinding"},
+			wantRules: []rule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"get", "list"}}},
+		},
+		{
+			name: "resources and namespaces",
+			access: ScopedAccess{
+				Name:       "kubectl-ai-1",
+				Namespaces: []string{"shop", "payments"},
+				Verbs:      []string{"get", "patch"},
+				Resources:  []string{"pods", "deployments.apps"},
+				Duration:   time.Hour,
+			},
+			wantKinds: []string{"ServiceAccount", "Role", "RoleBinding", "Role", "RoleBinding"},
+			wantRules: []rule{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "patch"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "patch"}},
+			},
+		},
+		{
+			name:    "no namespace",
+			access:  ScopedAccess{Name: "kubectl-ai-1", Verbs: []string{"get"}, Duration: time.Hour},
+			wantErr: true,
+		},
+		{
+			name:    "no duration",
+			access:  ScopedAccess{Name: "kubectl-ai-1", Namespaces: []string{"shop"}, Verbs: []string{"get"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.access.Manifest()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Manifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var list struct {
+				Items []struct {
+					Kind     string `json:"kind"`
+					Metadata struct {
+						Name      string `json:"name"`
+						Namespace string `json:"namespace"`
+					} `json:"metadata"`
+					Rules    []rule `json:"rules"`
+					Subjects []struct {
+						Name      string `json:"name"`
+						Namespace string `json:"namespace"`
+					} `json:"subjects"`
+				} `json:"items"`
+			}
+			if err := json.Unmarshal(b, &list); err != nil {
+				t.Fatalf("parsing manifest: %v", err)
+			}
+			var kinds []string
+			for i, item := range list.Items {
+				kinds = append(kinds, item.Kind)
+				if item.Metadata.Name != tt.access.Name {
+					t.Errorf("item %d name = %q, want %q", i, item.Metadata.Name, tt.access.Name)
+				}
+				switch item.Kind {
+				case "Role":
+					if !reflect.DeepEqual(item.Rules, tt.wantRules) {
+						t.Errorf("rules = %+v, want %+v", item.Rules, tt.wantRules)
+					}
+				case "RoleBinding":
+					// The service account is bound in every namespace, from the first one.
+					if len(item.Subjects) != 1 || item.Subjects[0].Namespace != tt.access.Namespaces[0] {
+						t.Errorf("subjects = %+v, want the service account in %s", item.Subjects, tt.access.Namespaces[0])
+					}
+				}
+			}
+			if !reflect.DeepEqual(kinds, tt.wantKinds) {
+				t.Errorf("kinds = %v, want %v", kinds, tt.wantKinds)
+			}
+			if got := list.Items[len(list.Items)-1].Metadata.Namespace; got != tt.access.Namespaces[len(tt.access.Namespaces)-1] {
+				t.Errorf("last role binding namespace = %q, want %q", got, tt.access.Namespaces[len(tt.access.Namespaces)-1])
+			}
+		})
+	}
+}
+
+func TestScopedKubeconfig(t *testing.T) {
+	current := []byte(`{
+  "kind": "Config",
+  "clusters": [{"name": "prod", "cluster": {"server": "https://10.0.0.1", "certificate-authority-data": "Q0E="}}],
+  "users": [{"name": "admin", "user": {"client-key-data": "S0VZ"}}],
+  "contexts": [{"name": "prod", "context": {"cluster": "prod", "user": "admin"}}],
+  "current-context": "prod"
+}`)
+	access := &ScopedAccess{Name: "kubectl-ai-1", Namespaces: []string{"shop"}}
+
+	b, err := scopedKubeconfig(current, access, "secret-token")
+	if err != nil {
+		t.Fatalf("scopedKubeconfig() error: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("parsing kubeconfig: %v", err)
+	}
+	want := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Config",
+		"clusters": []any{map[string]any{"name": "prod", "cluster": map[string]any{
+			"server":                     "https://10.0.0.1",
+			"certificate-authority-data": "Q0E=",
+		}}},
+		"users": []any{map[string]any{"name": "kubectl-ai-1", "user": map[string]any{"token": "secret-token"}}},
+		"contexts": []any{map[string]any{"name": "kubectl-ai-1", "context": map[string]any{
+			"cluster":   "prod",
+			"user":      "kubectl-ai-1",
+			"namespace": "shop",
+		}}},
+		"current-context": "kubectl-ai-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("kubeconfig = %v, want %v", got, want)
+	}
+
+	if _, err := scopedKubeconfig([]byte(`{"clusters": []}`), access, "secret-token"); err == nil {
+		t.Errorf("scopedKubeconfig() without cluster: want error")
+	}
+}
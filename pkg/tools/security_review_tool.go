@@ -0,0 +1,614 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+func init() {
+	RegisterTool(&SecurityReview{})
+}
+
+// Severities of the findings of the security review, from the most to the
+// least severe.
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+var severityOrder = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow}
+
+// securityPolicy is a check of the bundled policies of the security review.
+type securityPolicy struct {
+	ID          string
+	Description string
+}
+
+// securityPolicies are the bundled policies, in the order they are listed to
+// the LLM.
+var securityPolicies = []securityPolicy{
+	{ID: "privileged-container", Description: "containers running privileged, or allowed to escalate their privileges"},
+	{ID: "host-namespaces", Description: "pods sharing the network, PID or IPC namespace of the node"},
+	{ID: "host-path-volume", Description: "pods mounting directories of the node with hostPath volumes"},
+	{ID: "missing-resource-limits", Description: "containers without CPU or memory limits"},
+	{ID: "latest-image-tag", Description: "images with the latest tag, or without tag nor digest"},
+	{ID: "rbac-wildcard", Description: `roles granting "*" verbs, resources or API groups, including those bound to the service account of a workload`},
+}
+
+// securityWorkloadKinds are the kinds reviewed in a namespace.
+const securityWorkloadKinds = "deployments,statefulsets,daemonsets,jobs,cronjobs,pods"
+
+// SecurityReview checks workloads and roles against the bundled security
+// policies, and returns the findings with their severities.
+type SecurityReview struct{}
+
+func (t *SecurityReview) Name() string {
+	return "security_review"
+}
+
+func (t *SecurityReview) Description() string {
+	var policies strings.Builder
+	for _, policy := range securityPolicies {
+		fmt.Fprintf(&policies, "\n- %s: %s", policy.ID, policy.Description)
+	}
+	return `Reviews the security posture of a workload, or of all the workloads and roles of a namespace, against a bundled set of policies, and returns the findings with their severity (critical, high, medium or low), the resource and container concerned, and how to fix them.
+Use this tool to answer questions like "is this deployment safe" or "what are the security risks in this namespace" from checks rather than by reading manifests. The policies are:` + policies.String()
+}
+
+func (t *SecurityReview) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"resource": {
+					Type:        gollm.TypeString,
+					Description: `The workload or role to review as kind/name, e.g. "deployment/web", "cronjob/backup" or "role/deployer". All the workloads and roles of the namespace are reviewed if empty.`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace to review. Defaults to the namespace of the context.`,
+				},
+			},
+		},
+	}
+}
+
+// SecurityReviewResult is the result of the security_review tool.
+type SecurityReviewResult struct {
+	// Scope is the resource reviewed, or "namespace" for a whole namespace.
+	Scope     string `json:"scope"`
+	Namespace string `json:"namespace,omitempty"`
+	// Reviewed are the resources checked, as kind/name.
+	Reviewed []string `json:"reviewed"`
+	// Findings are sorted by decreasing severity.
+	Findings []SecurityFinding `json:"findings"`
+	// Summary is the number of findings by severity.
+	Summary map[string]int `json:"summary"`
+	// Skipped are the checks that could not run, e.g. for lack of permissions.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// SecurityFinding is a violation of a policy.
+type SecurityFinding struct {
+	Policy    string `json:"policy"`
+	Severity  string `json:"severity"`
+	Resource  string `json:"resource"`
+	Container string `json:"container,omitempty"`
+	Message   string `json:"message"`
+	Fix       string `json:"fix"`
+}
+
+func (t *SecurityReview) Run(ctx context.Context, args map[string]any) (any, error) {
+	resource, _ := args["resource"].(string)
+	namespace, _ := args["namespace"].(string)
+	resource = strings.TrimSpace(resource)
+	if resource != "" && !strings.Contains(resource, "/") {
+		return &ExecResult{Error: fmt.Sprintf("resource %q must be kind/name, e.g. deployment/web", resource)}, nil
+	}
+
+	review := &securityReviewer{ctx: ctx, namespace: namespace, roles: map[string]*securityObject{}}
+	result := &SecurityReviewResult{Scope: "namespace", Namespace: namespace}
+	var objects []*securityObject
+	if resource == "" {
+		list, err := review.getList(securityWorkloadKinds)
+		if err != nil {
+			return &ExecResult{Error: err.Error()}, nil
+		}
+		for _, obj := range list {
+			// Pods and jobs created by a workload are reviewed with it.
+			if len(obj.Metadata.OwnerReferences) == 0 {
+				objects = append(objects, obj)
+			}
+		}
+		roles, err := review.getList("roles")
+		if err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("roles: %v", err))
+		}
+		objects = append(objects, roles...)
+	} else {
+		result.Scope = resource
+		obj, err := review.get(resource)
+		if err != nil {
+			return &ExecResult{Error: err.Error()}, nil
+		}
+		objects = append(objects, obj)
+	}
+
+	for _, obj := range objects {
+		result.Reviewed = append(result.Reviewed, obj.ref())
+		findings, skipped := review.review(obj)
+		result.Findings = append(result.Findings, findings...)
+		result.Skipped = append(result.Skipped, skipped...)
+	}
+	if resource == "" {
+		findings, skipped := review.reviewRoleBindings()
+		result.Findings = append(result.Findings, findings...)
+		result.Skipped = append(result.Skipped, skipped...)
+	}
+	sortFindings(result.Findings)
+	result.Summary = summarizeFindings(result.Findings)
+	if result.Reviewed == nil {
+		result.Reviewed = []string{}
+	}
+	if result.Findings == nil {
+		result.Findings = []SecurityFinding{}
+	}
+	return result, nil
+}
+
+func (t *SecurityReview) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *SecurityReview) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// OutputSchema returns the schema of SecurityReviewResult.
+func (t *SecurityReview) OutputSchema() *gollm.Schema {
+	finding := &gollm.Schema{
+		Type: gollm.TypeObject,
+		Properties: map[string]*gollm.Schema{
+			"policy":    {Type: gollm.TypeString, Description: "The policy violated."},
+			"severity":  {Type: gollm.TypeString, Description: "critical, high, medium or low."},
+			"resource":  {Type: gollm.TypeString, Description: "The resource violating the policy, as kind/name."},
+			"container": {Type: gollm.TypeString, Description: "The container violating the policy, if any."},
+			"message":   {Type: gollm.TypeString, Description: "What violates the policy."},
+			"fix":       {Type: gollm.TypeString, Description: "How to fix the violation."},
+		},
+		Required: []string{"policy", "severity", "resource", "message", "fix"},
+	}
+	return &gollm.Schema{
+		Type: gollm.TypeObject,
+		Properties: map[string]*gollm.Schema{
+			"scope":     {Type: gollm.TypeString, Description: `The resource reviewed, or "namespace".`},
+			"namespace": {Type: gollm.TypeString, Description: "The namespace reviewed, if not the one of the context."},
+			"reviewed":  {Type: gollm.TypeArray, Items: &gollm.Schema{Type: gollm.TypeString}, Description: "The resources checked."},
+			"findings":  {Type: gollm.TypeArray, Items: finding, Description: "The findings, by decreasing severity."},
+			"summary":   {Type: gollm.TypeObject, Description: "The number of findings by severity."},
+			"skipped":   {Type: gollm.TypeArray, Items: &gollm.Schema{Type: gollm.TypeString}, Description: "The checks that could not run, e.g. for lack of permissions."},
+		},
+		Required: []string{"scope", "reviewed", "findings", "summary"},
+	}
+}
+
+// securityObject holds the fields of the workloads, roles and role bindings
+// checked by the security review.
+type securityObject struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		OwnerReferences []any  `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec json.RawMessage `json:"spec"`
+	// Rules are the rules of roles.
+	Rules []securityPolicyRule `json:"rules"`
+	// RoleRef and Subjects are the fields of role bindings.
+	RoleRef struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	} `json:"roleRef"`
+	Subjects []securitySubject `json:"subjects"`
+}
+
+type securitySubject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+func (o *securityObject) ref() string {
+	return strings.ToLower(o.Kind) + "/" + o.Metadata.Name
+}
+
+type securityPolicyRule struct {
+	APIGroups []string `json:"apiGroups"`
+	Resources []string `json:"resources"`
+	Verbs     []string `json:"verbs"`
+}
+
+type securityPodSpec struct {
+	HostNetwork        bool   `json:"hostNetwork"`
+	HostPID            bool   `json:"hostPID"`
+	HostIPC            bool   `json:"hostIPC"`
+	ServiceAccountName string `json:"serviceAccountName"`
+	Volumes            []struct {
+		Name     string `json:"name"`
+		HostPath *struct {
+			Path string `json:"path"`
+		} `json:"hostPath"`
+	} `json:"volumes"`
+	InitContainers []securityContainer `json:"initContainers"`
+	Containers     []securityContainer `json:"containers"`
+}
+
+type securityContainer struct {
+	Name            string `json:"name"`
+	Image           string `json:"image"`
+	SecurityContext *struct {
+		Privileged               *bool `json:"privileged"`
+		AllowPrivilegeEscalation *bool `json:"allowPrivilegeEscalation"`
+	} `json:"securityContext"`
+	Resources struct {
+		Limits map[string]any `json:"limits"`
+	} `json:"resources"`
+}
+
+// podSpec returns the pod spec of a workload, or nil for other kinds.
+func (o *securityObject) podSpec() (*securityPodSpec, error) {
+	var spec struct {
+		securityPodSpec
+		Template struct {
+			Spec securityPodSpec `json:"spec"`
+		} `json:"template"`
+		JobTemplate struct {
+			Spec struct {
+				Template struct {
+					Spec securityPodSpec `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+		} `json:"jobTemplate"`
+	}
+	switch o.Kind {
+	case "Pod", "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob":
+	default:
+		return nil, nil
+	}
+	if err := json.Unmarshal(o.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", o.ref(), err)
+	}
+	switch o.Kind {
+	case "Pod":
+		return &spec.securityPodSpec, nil
+	case "CronJob":
+		return &spec.JobTemplate.Spec.Template.Spec, nil
+	default:
+		return &spec.Template.Spec, nil
+	}
+}
+
+// securityReviewer fetches the objects reviewed, and caches the roles bound
+// to service accounts.
+type securityReviewer struct {
+	ctx       context.Context
+	namespace string
+	roles     map[string]*securityObject
+}
+
+func (r *securityReviewer) kubectl(args ...string) ([]byte, error) {
+	if r.namespace != "" {
+		args = append(args, "--namespace", r.namespace)
+	}
+	return kubectlOutput(r.ctx, append(args, "-o", "json")...)
+}
+
+func (r *securityReviewer) get(resource string) (*securityObject, error) {
+	out, err := r.kubectl("get", resource)
+	if err != nil {
+		return nil, err
+	}
+	var obj securityObject
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", resource, err)
+	}
+	return &obj, nil
+}
+
+func (r *securityReviewer) getList(kinds string, extraArgs ...string) ([]*securityObject, error) {
+	out, err := r.kubectl(append([]string{"get", kinds}, extraArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []*securityObject `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", kinds, err)
+	}
+	return list.Items, nil
+}
+
+// role returns a role or cluster role referenced by a binding.
+func (r *securityReviewer) role(kind, name string) (*securityObject, error) {
+	ref := strings.ToLower(kind) + "/" + name
+	if role, ok := r.roles[ref]; ok {
+		return role, nil
+	}
+	role, err := r.get(ref)
+	if err != nil {
+		return nil, err
+	}
+	r.roles[ref] = role
+	return role, nil
+}
+
+// review returns the findings of a workload or a role, and the checks skipped.
+func (r *securityReviewer) review(obj *securityObject) ([]SecurityFinding, []string) {
+	if obj.Kind == "Role" || obj.Kind == "ClusterRole" {
+		return reviewRules(obj.ref(), obj.Rules), nil
+	}
+	spec, err := obj.podSpec()
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	if spec == nil {
+		return nil, []string{fmt.Sprintf("%s: only workloads and roles are reviewed", obj.ref())}
+	}
+	findings := reviewPodSpec(obj.ref(), spec)
+
+	serviceAccount := spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	roleFindings, skipped := r.reviewServiceAccount(obj, serviceAccount)
+	return append(findings, roleFindings...), skipped
+}
+
+// reviewServiceAccount returns the findings of the roles bound to the
+// service account of a workload.
+func (r *securityReviewer) reviewServiceAccount(obj *securityObject, serviceAccount string) ([]SecurityFinding, []string) {
+	var findings []SecurityFinding
+	var skipped []string
+	var bindings []*securityObject
+	for _, kind := range []string{"rolebindings", "clusterrolebindings"} {
+		list, err := r.getList(kind)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s of the service account %s: %v", kind, serviceAccount, err))
+			continue
+		}
+		bindings = append(bindings, list...)
+	}
+	for _, binding := range bindings {
+		bound := slices.ContainsFunc(binding.Subjects, func(s securitySubject) bool {
+			return s.Kind == "ServiceAccount" && s.Name == serviceAccount && (s.Namespace == "" || s.Namespace == obj.Metadata.Namespace)
+		})
+		if !bound {
+			continue
+		}
+		role, err := r.role(binding.RoleRef.Kind, binding.RoleRef.Name)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", binding.ref(), err))
+			continue
+		}
+		for _, finding := range reviewRules(role.ref(), role.Rules) {
+			finding.Message = fmt.Sprintf("%s, and is bound to the service account %s of %s by %s", finding.Message, serviceAccount, obj.ref(), binding.ref())
+			findings = append(findings, finding)
+		}
+	}
+	return findings, skipped
+}
+
+// reviewRoleBindings returns the findings of the cluster roles bound in the
+// namespace, the roles of the namespace being reviewed on their own.
+func (r *securityReviewer) reviewRoleBindings() ([]SecurityFinding, []string) {
+	bindings, err := r.getList("rolebindings")
+	if err != nil {
+		return nil, []string{fmt.Sprintf("rolebindings: %v", err)}
+	}
+	var findings []SecurityFinding
+	var skipped []string
+	for _, binding := range bindings {
+		if binding.RoleRef.Kind != "ClusterRole" {
+			continue
+		}
+		role, err := r.role(binding.RoleRef.Kind, binding.RoleRef.Name)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", binding.ref(), err))
+			continue
+		}
+		for _, finding := range reviewRules(role.ref(), role.Rules) {
+			finding.Message = fmt.Sprintf("%s, and is bound in the namespace by %s", finding.Message, binding.ref())
+			findings = append(findings, finding)
+		}
+	}
+	return findings, skipped
+}
+
+// reviewPodSpec returns the findings of the pod spec of a workload.
+func reviewPodSpec(resource string, spec *securityPodSpec) []SecurityFinding {
+	var findings []SecurityFinding
+	var hostNamespaces []string
+	if spec.HostNetwork {
+		hostNamespaces = append(hostNamespaces, "network")
+	}
+	if spec.HostPID {
+		hostNamespaces = append(hostNamespaces, "PID")
+	}
+	if spec.HostIPC {
+		hostNamespaces = append(hostNamespaces, "IPC")
+	}
+	if len(hostNamespaces) > 0 {
+		findings = append(findings, SecurityFinding{
+			Policy:   "host-namespaces",
+			Severity: SeverityHigh,
+			Resource: resource,
+			Message:  fmt.Sprintf("the pods share the %s namespaces of the node", strings.Join(hostNamespaces, ", ")),
+			Fix:      "remove hostNetwork, hostPID and hostIPC from the pod spec, unless the workload is a node agent that needs them",
+		})
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath == nil {
+			continue
+		}
+		severity := SeverityHigh
+		if volume.HostPath.Path == "/" || strings.HasPrefix(volume.HostPath.Path, "/var/run/docker.sock") || strings.HasPrefix(volume.HostPath.Path, "/run/containerd") {
+			severity = SeverityCritical
+		}
+		findings = append(findings, SecurityFinding{
+			Policy:   "host-path-volume",
+			Severity: severity,
+			Resource: resource,
+			Message:  fmt.Sprintf("the volume %s mounts the directory %s of the node", volume.Name, volume.HostPath.Path),
+			Fix:      "use a persistentVolumeClaim, configMap, secret or emptyDir volume instead",
+		})
+	}
+	for _, container := range slices.Concat(spec.InitContainers, spec.Containers) {
+		findings = append(findings, reviewContainer(resource, container)...)
+	}
+	return findings
+}
+
+func reviewContainer(resource string, container securityContainer) []SecurityFinding {
+	var findings []SecurityFinding
+	if sc := container.SecurityContext; sc != nil {
+		switch {
+		case sc.Privileged != nil && *sc.Privileged:
+			findings = append(findings, SecurityFinding{
+				Policy:    "privileged-container",
+				Severity:  SeverityCritical,
+				Resource:  resource,
+				Container: container.Name,
+				Message:   "the container runs privileged, with all the capabilities of the node",
+				Fix:       "set securityContext.privileged to false, and add only the capabilities the container needs",
+			})
+		case sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation:
+			findings = append(findings, SecurityFinding{
+				Policy:    "privileged-container",
+				Severity:  SeverityMedium,
+				Resource:  resource,
+				Container: container.Name,
+				Message:   "the processes of the container can gain more privileges than their parent",
+				Fix:       "set securityContext.allowPrivilegeEscalation to false",
+			})
+		}
+	}
+
+	var missing []string
+	for _, limit := range []string{"cpu", "memory"} {
+		if _, ok := container.Resources.Limits[limit]; !ok {
+			missing = append(missing, limit)
+		}
+	}
+	if len(missing) > 0 {
+		findings = append(findings, SecurityFinding{
+			Policy:    "missing-resource-limits",
+			Severity:  SeverityMedium,
+			Resource:  resource,
+			Container: container.Name,
+			Message:   fmt.Sprintf("the container has no %s limit, and can starve the other pods of the node", strings.Join(missing, " nor ")),
+			Fix:       fmt.Sprintf("set resources.limits.%s", strings.Join(missing, " and resources.limits.")),
+		})
+	}
+
+	if isLatestImage(container.Image) {
+		findings = append(findings, SecurityFinding{
+			Policy:    "latest-image-tag",
+			Severity:  SeverityMedium,
+			Resource:  resource,
+			Container: container.Name,
+			Message:   fmt.Sprintf("the image %s is not pinned: it changes whenever the image is pushed", container.Image),
+			Fix:       "use a version tag, or a digest, of the image",
+		})
+	}
+	return findings
+}
+
+// isLatestImage returns true if the image has the latest tag, or no tag nor digest.
+func isLatestImage(image string) bool {
+	if image == "" || strings.Contains(image, "@") {
+		return false
+	}
+	// The registry of the image may have a port, e.g. localhost:5000/app.
+	name := image[strings.LastIndex(image, "/")+1:]
+	_, tag, ok := strings.Cut(name, ":")
+	return !ok || tag == "latest"
+}
+
+// reviewRules returns the findings of the rules of a role.
+func reviewRules(resource string, rules []securityPolicyRule) []SecurityFinding {
+	var findings []SecurityFinding
+	for _, rule := range rules {
+		var wildcards []string
+		if slices.Contains(rule.Verbs, "*") {
+			wildcards = append(wildcards, "verbs")
+		}
+		if slices.Contains(rule.Resources, "*") {
+			wildcards = append(wildcards, "resources")
+		}
+		if slices.Contains(rule.APIGroups, "*") {
+			wildcards = append(wildcards, "API groups")
+		}
+		if len(wildcards) == 0 {
+			continue
+		}
+		severity := SeverityHigh
+		if slices.Contains(rule.Verbs, "*") && slices.Contains(rule.Resources, "*") {
+			severity = SeverityCritical
+		}
+		findings = append(findings, SecurityFinding{
+			Policy:   "rbac-wildcard",
+			Severity: severity,
+			Resource: resource,
+			Message:  fmt.Sprintf("the role grants all %s (verbs: %s, resources: %s, API groups: %s)", strings.Join(wildcards, " and "), strings.Join(rule.Verbs, ","), strings.Join(rule.Resources, ","), strings.Join(rule.APIGroups, ",")),
+			Fix:      "list the verbs, resources and API groups the subjects of the role need",
+		})
+	}
+	return findings
+}
+
+// sortFindings sorts findings by decreasing severity, then by resource.
+func sortFindings(findings []SecurityFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		si, sj := slices.Index(severityOrder, findings[i].Severity), slices.Index(severityOrder, findings[j].Severity)
+		if si != sj {
+			return si < sj
+		}
+		return findings[i].Resource < findings[j].Resource
+	})
+}
+
+func summarizeFindings(findings []SecurityFinding) map[string]int {
+	summary := map[string]int{}
+	for _, severity := range severityOrder {
+		summary[severity] = 0
+	}
+	for _, finding := range findings {
+		summary[finding.Severity]++
+	}
+	return summary
+}
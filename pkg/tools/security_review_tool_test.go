@@ -0,0 +1,123 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+const securityCronJob = `
+kind: CronJob
+metadata:
+  name: backup
+  namespace: prod
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          hostPID: true
+          volumes:
+          - name: data
+            hostPath:
+              path: /var/lib/data
+          - name: tmp
+            emptyDir: {}
+          initContainers:
+          - name: init
+            image: registry.local:5000/tools
+            resources:
+              limits:
+                cpu: 100m
+                memory: 64Mi
+          containers:
+          - name: backup
+            image: backup:1.4
+            securityContext:
+              privileged: true
+            resources:
+              limits:
+                memory: 1Gi
+`
+
+func TestReviewPodSpec(t *testing.T) {
+	var obj securityObject
+	if err := yaml.Unmarshal([]byte(securityCronJob), &obj); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := obj.podSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][3]string
+	for _, finding := range reviewPodSpec(obj.ref(), spec) {
+		got = append(got, [3]string{finding.Policy, finding.Severity, finding.Container})
+	}
+	want := [][3]string{
+		{"host-namespaces", SeverityHigh, ""},
+		{"host-path-volume", SeverityHigh, ""},
+		{"latest-image-tag", SeverityMedium, "init"},
+		{"privileged-container", SeverityCritical, "backup"},
+		{"missing-resource-limits", SeverityMedium, "backup"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reviewPodSpec(%s) = %v, want %v", obj.ref(), got, want)
+	}
+}
+
+func TestIsLatestImage(t *testing.T) {
+	tests := map[string]bool{
+		"nginx":                        true,
+		"nginx:latest":                 true,
+		"nginx:1.27":                   false,
+		"localhost:5000/app":           true,
+		"localhost:5000/app:v1":        false,
+		"nginx@sha256:0123456789abcde": false,
+	}
+	for image, want := range tests {
+		if got := isLatestImage(image); got != want {
+			t.Errorf("isLatestImage(%q) = %v, want %v", image, got, want)
+		}
+	}
+}
+
+func TestReviewRules(t *testing.T) {
+	rules := []securityPolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"*"}},
+		{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+	}
+	findings := reviewRules("role/deployer", rules)
+	if len(findings) != 2 {
+		t.Fatalf("reviewRules() = %+v, want 2 findings", findings)
+	}
+	if findings[0].Severity != SeverityHigh || findings[1].Severity != SeverityCritical {
+		t.Errorf("severities = %s, %s, want %s, %s", findings[0].Severity, findings[1].Severity, SeverityHigh, SeverityCritical)
+	}
+
+	sortFindings(findings)
+	if findings[0].Severity != SeverityCritical {
+		t.Errorf("sortFindings() put %s first, want %s", findings[0].Severity, SeverityCritical)
+	}
+	summary := summarizeFindings(findings)
+	if want := map[string]int{SeverityCritical: 1, SeverityHigh: 1, SeverityMedium: 0, SeverityLow: 0}; !reflect.DeepEqual(summary, want) {
+		t.Errorf("summarizeFindings() = %v, want %v", summary, want)
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+func init() {
+	RegisterTool(&IncidentTimeline{})
+}
+
+const (
+	defaultTimelineWindow     = time.Hour
+	defaultTimelineMaxEntries = 200
+)
+
+// IncidentTimeline merges events, container restarts, deployment rollouts and
+// node condition transitions into a single chronological timeline.
+type IncidentTimeline struct{}
+
+func (t *IncidentTimeline) Name() string {
+	return "incident_timeline"
+}
+
+func (t *IncidentTimeline) Description() string {
+	return `Builds a chronological timeline of what happened in the cluster during a time window, merging Kubernetes events, container restarts, deployment rollouts and node condition changes.
+Use this tool when investigating an incident or outage to understand the sequence of events, before digging into individual resources.`
+}
+
+func (t *IncidentTimeline) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace to build the timeline for. Leave empty for all namespaces. Node conditions are always included.`,
+				},
+				"since": {
+					Type:        gollm.TypeString,
+					Description: `How far back the timeline starts, as a duration relative to the end of the window (e.g. "30m", "2h"). Defaults to "1h".`,
+				},
+				"until": {
+					Type:        gollm.TypeString,
+					Description: `The end of the window as an RFC3339 timestamp (e.g. "2025-06-01T10:00:00Z"). Defaults to now.`,
+				},
+				"max_entries": {
+					Type:        gollm.TypeInteger,
+					Description: `Maximum number of entries to return, keeping the most recent ones. Defaults to 200.`,
+				},
+			},
+		},
+	}
+}
+
+// Timeline is the result of the incident_timeline tool.
+type Timeline struct {
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Entries []TimelineEntry `json:"timeline"`
+	// Truncated is the number of older entries dropped to respect max_entries.
+	Truncated int      `json:"truncated,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// TimelineEntry is a single point in the timeline.
+type TimelineEntry struct {
+	Time time.Time `json:"time"`
+	// Source is one of Event, ContainerRestart, Rollout or NodeCondition.
+	Source    string `json:"source"`
+	Severity  string `json:"severity"`
+	Object    string `json:"object"`
+	Namespace string `json:"namespace,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func (t *IncidentTimeline) Run(ctx context.Context, args map[string]any) (any, error) {
+	namespace, _ := args["namespace"].(string)
+
+	end := time.Now()
+	if until, ok := args["until"].(string); ok && until != "" {
+		parsed, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return &ExecResult{Error: fmt.Sprintf("invalid until %q, expected RFC3339 timestamp: %v", until, err)}, nil
+		}
+		end = parsed
+	}
+	window := defaultTimelineWindow
+	if since, ok := args["since"].(string); ok && since != "" {
+		parsed, err := time.ParseDuration(since)
+		if err != nil || parsed <= 0 {
+			return &ExecResult{Error: fmt.Sprintf("invalid since %q, expected a positive duration like 30m or 2h", since)}, nil
+		}
+		window = parsed
+	}
+	maxEntries := defaultTimelineMaxEntries
+	if n, ok := args["max_entries"].(float64); ok && n > 0 {
+		maxEntries = int(n)
+	}
+
+	timeline := &Timeline{
+		Start: end.Add(-window),
+		End:   end,
+	}
+
+	nsArgs := []string{"--all-namespaces"}
+	if namespace != "" {
+		nsArgs = []string{"--namespace", namespace}
+	}
+
+	collectors := []struct {
+		name    string
+		args    []string
+		collect func(b []byte) ([]TimelineEntry, error)
+	}{
+		{"events", append([]string{"get", "events", "-o", "json"}, nsArgs...), eventTimelineEntries},
+		{"pods", append([]string{"get", "pods", "-o", "json"}, nsArgs...), podRestartTimelineEntries},
+		{"replicasets", append([]string{"get", "replicasets", "-o", "json"}, nsArgs...), rolloutTimelineEntries},
+		{"nodes", []string{"get", "nodes", "-o", "json"}, nodeConditionTimelineEntries},
+	}
+	for _, c := range collectors {
+		out, err := kubectlOutput(ctx, c.args...)
+		if err != nil {
+			timeline.Errors = append(timeline.Errors, fmt.Sprintf("listing %s: %v", c.name, err))
+			continue
+		}
+		entries, err := c.collect(out)
+		if err != nil {
+			timeline.Errors = append(timeline.Errors, fmt.Sprintf("parsing %s: %v", c.name, err))
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Time.Before(timeline.Start) || entry.Time.After(timeline.End) {
+				continue
+			}
+			timeline.Entries = append(timeline.Entries, entry)
+		}
+	}
+
+	sort.SliceStable(timeline.Entries, func(i, j int) bool {
+		return timeline.Entries[i].Time.Before(timeline.Entries[j].Time)
+	})
+	if len(timeline.Entries) > maxEntries {
+		timeline.Truncated = len(timeline.Entries) - maxEntries
+		timeline.Entries = timeline.Entries[timeline.Truncated:]
+	}
+	return timeline, nil
+}
+
+func (t *IncidentTimeline) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *IncidentTimeline) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// Minimal views of the Kubernetes objects we read, to avoid depending on client-go.
+
+type objectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+	Annotations       map[string]string `json:"annotations"`
+	OwnerReferences   []struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	} `json:"ownerReferences"`
+}
+
+type objectList[T any] struct {
+	Items []T `json:"items"`
+}
+
+func eventTimelineEntries(b []byte) ([]TimelineEntry, error) {
+	var list objectList[struct {
+		Metadata       objectMeta `json:"metadata"`
+		InvolvedObject struct {
+			Kind      string `json:"kind"`
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"involvedObject"`
+		Reason         string    `json:"reason"`
+		Message        string    `json:"message"`
+		Type           string    `json:"type"`
+		Count          int       `json:"count"`
+		FirstTimestamp time.Time `json:"firstTimestamp"`
+		LastTimestamp  time.Time `json:"lastTimestamp"`
+		EventTime      time.Time `json:"eventTime"`
+	}]
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+
+	var entries []TimelineEntry
+	for _, ev := range list.Items {
+		ts := ev.LastTimestamp
+		if ts.IsZero() {
+			ts = ev.EventTime
+		}
+		if ts.IsZero() {
+			ts = ev.FirstTimestamp
+		}
+		if ts.IsZero() {
+			ts = ev.Metadata.CreationTimestamp
+		}
+		message := ev.Message
+		if ev.Count > 1 {
+			message = fmt.Sprintf("%s (x%d)", message, ev.Count)
+		}
+		severity := "info"
+		if ev.Type == "Warning" {
+			severity = "warning"
+		}
+		entries = append(entries, TimelineEntry{
+			Time:      ts,
+			Source:    "Event",
+			Severity:  severity,
+			Object:    strings.ToLower(ev.InvolvedObject.Kind) + "/" + ev.InvolvedObject.Name,
+			Namespace: ev.InvolvedObject.Namespace,
+			Reason:    ev.Reason,
+			Message:   message,
+		})
+	}
+	return entries, nil
+}
+
+func podRestartTimelineEntries(b []byte) ([]TimelineEntry, error) {
+	type containerStatus struct {
+		Name         string `json:"name"`
+		RestartCount int    `json:"restartCount"`
+		LastState    struct {
+			Terminated *struct {
+				ExitCode   int       `json:"exitCode"`
+				Reason     string    `json:"reason"`
+				FinishedAt time.Time `json:"finishedAt"`
+			} `json:"terminated"`
+		} `json:"lastState"`
+	}
+	var list objectList[struct {
+		Metadata objectMeta `json:"metadata"`
+		Status   struct {
+			InitContainerStatuses []containerStatus `json:"initContainerStatuses"`
+			ContainerStatuses     []containerStatus `json:"containerStatuses"`
+		} `json:"status"`
+	}]
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+
+	var entries []TimelineEntry
+	for _, pod := range list.Items {
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			terminated := cs.LastState.Terminated
+			if cs.RestartCount == 0 || terminated == nil {
+				continue
+			}
+			entries = append(entries, TimelineEntry{
+				Time:      terminated.FinishedAt,
+				Source:    "ContainerRestart",
+				Severity:  "warning",
+				Object:    "pod/" + pod.Metadata.Name,
+				Namespace: pod.Metadata.Namespace,
+				Reason:    terminated.Reason,
+				Message:   fmt.Sprintf("container %q terminated with exit code %d (restarts: %d)", cs.Name, terminated.ExitCode, cs.RestartCount),
+			})
+		}
+	}
+	return entries, nil
+}
+
+func rolloutTimelineEntries(b []byte) ([]TimelineEntry, error) {
+	var list objectList[struct {
+		Metadata objectMeta `json:"metadata"`
+		Spec     struct {
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Image string `json:"image"`
+					} `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}]
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+
+	var entries []TimelineEntry
+	for _, rs := range list.Items {
+		revision := rs.Metadata.Annotations["deployment.kubernetes.io/revision"]
+		deployment := ""
+		for _, owner := range rs.Metadata.OwnerReferences {
+			if owner.Kind == "Deployment" {
+				deployment = owner.Name
+			}
+		}
+		if deployment == "" || revision == "" {
+			continue
+		}
+		var images []string
+		for _, c := range rs.Spec.Template.Spec.Containers {
+			images = append(images, c.Image)
+		}
+		entries = append(entries, TimelineEntry{
+			Time:      rs.Metadata.CreationTimestamp,
+			Source:    "Rollout",
+			Severity:  "info",
+			Object:    "deployment/" + deployment,
+			Namespace: rs.Metadata.Namespace,
+			Reason:    "Revision" + revision,
+			Message:   fmt.Sprintf("rolled out revision %s (replicaset %s) with images %s", revision, rs.Metadata.Name, strings.Join(images, ", ")),
+		})
+	}
+	return entries, nil
+}
+
+func nodeConditionTimelineEntries(b []byte) ([]TimelineEntry, error) {
+	var list objectList[struct {
+		Metadata objectMeta `json:"metadata"`
+		Status   struct {
+			Conditions []struct {
+				Type               string    `json:"type"`
+				Status             string    `json:"status"`
+				Reason             string    `json:"reason"`
+				Message            string    `json:"message"`
+				LastTransitionTime time.Time `json:"lastTransitionTime"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}]
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+
+	var entries []TimelineEntry
+	for _, node := range list.Items {
+		for _, cond := range node.Status.Conditions {
+			// Ready should be True, all other (pressure/unavailable) conditions should be False
+			healthy := (cond.Type == "Ready") == (cond.Status == "True")
+			severity := "info"
+			if !healthy {
+				severity = "warning"
+			}
+			entries = append(entries, TimelineEntry{
+				Time:     cond.LastTransitionTime,
+				Source:   "NodeCondition",
+				Severity: severity,
+				Object:   "node/" + node.Metadata.Name,
+				Reason:   cond.Reason,
+				Message:  fmt.Sprintf("%s=%s: %s", cond.Type, cond.Status, cond.Message),
+			})
+		}
+	}
+	return entries, nil
+}
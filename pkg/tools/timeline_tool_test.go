@@ -0,0 +1,94 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"testing"
+)
+
+func TestTimelineEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		collect  func([]byte) ([]TimelineEntry, error)
+		input    string
+		expected []TimelineEntry
+	}{
+		{
+			name:    "warning event",
+			collect: eventTimelineEntries,
+			input: `{"items":[{"metadata":{"name":"web.1"},"involvedObject":{"kind":"Pod","name":"web","namespace":"prod"},
+				"reason":"BackOff","message":"Back-off restarting failed container","type":"Warning","count":3,
+				"lastTimestamp":"2025-06-01T10:00:00Z"}]}`,
+			expected: []TimelineEntry{{
+				Source: "Event", Severity: "warning", Object: "pod/web", Namespace: "prod",
+				Reason: "BackOff", Message: "Back-off restarting failed container (x3)",
+			}},
+		},
+		{
+			name:    "container restart",
+			collect: podRestartTimelineEntries,
+			input: `{"items":[{"metadata":{"name":"web","namespace":"prod"},"status":{"containerStatuses":[
+				{"name":"app","restartCount":2,"lastState":{"terminated":{"exitCode":137,"reason":"OOMKilled","finishedAt":"2025-06-01T10:00:00Z"}}},
+				{"name":"sidecar","restartCount":0,"lastState":{}}]}}]}`,
+			expected: []TimelineEntry{{
+				Source: "ContainerRestart", Severity: "warning", Object: "pod/web", Namespace: "prod",
+				Reason: "OOMKilled", Message: `container "app" terminated with exit code 137 (restarts: 2)`,
+			}},
+		},
+		{
+			name:    "deployment rollout",
+			collect: rolloutTimelineEntries,
+			input: `{"items":[{"metadata":{"name":"web-abc","namespace":"prod","creationTimestamp":"2025-06-01T10:00:00Z",
+				"annotations":{"deployment.kubernetes.io/revision":"4"},"ownerReferences":[{"kind":"Deployment","name":"web"}]},
+				"spec":{"template":{"spec":{"containers":[{"image":"web:v2"}]}}}}]}`,
+			expected: []TimelineEntry{{
+				Source: "Rollout", Severity: "info", Object: "deployment/web", Namespace: "prod",
+				Reason: "Revision4", Message: "rolled out revision 4 (replicaset web-abc) with images web:v2",
+			}},
+		},
+		{
+			name:    "node conditions",
+			collect: nodeConditionTimelineEntries,
+			input: `{"items":[{"metadata":{"name":"n1"},"status":{"conditions":[
+				{"type":"MemoryPressure","status":"True","reason":"KubeletHasInsufficientMemory","message":"low memory","lastTransitionTime":"2025-06-01T10:00:00Z"},
+				{"type":"Ready","status":"True","reason":"KubeletReady","message":"ok","lastTransitionTime":"2025-06-01T10:00:00Z"}]}}]}`,
+			expected: []TimelineEntry{
+				{Source: "NodeCondition", Severity: "warning", Object: "node/n1", Reason: "KubeletHasInsufficientMemory", Message: "MemoryPressure=True: low memory"},
+				{Source: "NodeCondition", Severity: "info", Object: "node/n1", Reason: "KubeletReady", Message: "Ready=True: ok"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := tt.collect([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.expected), len(entries), entries)
+			}
+			for i, entry := range entries {
+				if entry.Time.IsZero() {
+					t.Errorf("entry %d has no timestamp", i)
+				}
+				entry.Time = tt.expected[i].Time
+				if entry != tt.expected[i] {
+					t.Errorf("entry %d: expected %+v, got %+v", i, tt.expected[i], entry)
+				}
+			}
+		})
+	}
+}
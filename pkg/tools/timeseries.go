@@ -0,0 +1,149 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// maxChartSeries bounds the number of series of a chart, the others are dropped.
+const maxChartSeries = 10
+
+// ChartFromResult returns the chart of the time series in a tool result, or
+// nil if it has none. Time series are recognized in the format of range
+// queries of the Prometheus HTTP API (resultType "matrix"), as returned by
+// http tools, or printed by commands such as curl or promtool.
+func ChartFromResult(title string, result any) *api.Chart {
+	var body any
+	switch r := result.(type) {
+	case *HTTPToolResult:
+		if r.Error != "" || r.Truncated {
+			return nil
+		}
+		body = r.Body
+	case *ExecResult:
+		if r.ExitCode != 0 || r.Error != "" {
+			return nil
+		}
+		body = r.Stdout
+		if r.Result != nil {
+			body = r.Result
+		}
+	default:
+		return nil
+	}
+
+	if s, ok := body.(string); ok {
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "{") || json.Unmarshal([]byte(s), &body) != nil {
+			return nil
+		}
+	}
+	m, ok := body.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if data, ok := m["data"].(map[string]any); ok {
+		m = data
+	}
+	if m["resultType"] != "matrix" {
+		return nil
+	}
+
+	results, _ := m["result"].([]any)
+	chart := &api.Chart{Title: title}
+	for i, r := range results {
+		if len(chart.Series) == maxChartSeries {
+			break
+		}
+		series, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		metric, _ := series["metric"].(map[string]any)
+		s := api.ChartSeries{Name: seriesName(metric)}
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("series %d", i+1)
+		}
+		values, _ := series["values"].([]any)
+		for _, v := range values {
+			if point, ok := parseSample(v); ok {
+				s.Points = append(s.Points, point)
+			}
+		}
+		if len(s.Points) > 0 {
+			chart.Series = append(chart.Series, s)
+		}
+	}
+	if len(chart.Series) == 0 {
+		return nil
+	}
+	return chart
+}
+
+// seriesName formats the labels of a metric like Prometheus,
+// e.g. container_memory_working_set_bytes{pod="web-1"}.
+func seriesName(metric map[string]any) string {
+	name, _ := metric["__name__"].(string)
+	var labels []string
+	for k, v := range metric {
+		if k != "__name__" {
+			labels = append(labels, fmt.Sprintf("%s=%q", k, fmt.Sprint(v)))
+		}
+	}
+	if len(labels) == 0 {
+		return name
+	}
+	sort.Strings(labels)
+	return name + "{" + strings.Join(labels, ",") + "}"
+}
+
+// parseSample parses a sample of a Prometheus range query, a
+// [unix timestamp, "value"] pair.
+func parseSample(v any) (api.ChartPoint, bool) {
+	sample, ok := v.([]any)
+	if !ok || len(sample) != 2 {
+		return api.ChartPoint{}, false
+	}
+	ts, ok := sample[0].(float64)
+	if !ok {
+		return api.ChartPoint{}, false
+	}
+	var value float64
+	switch x := sample[1].(type) {
+	case string:
+		var err error
+		if value, err = strconv.ParseFloat(x, 64); err != nil {
+			return api.ChartPoint{}, false
+		}
+	case float64:
+		value = x
+	default:
+		return api.ChartPoint{}, false
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return api.ChartPoint{}, false
+	}
+	sec, frac := math.Modf(ts)
+	return api.ChartPoint{Time: time.Unix(int64(sec), int64(frac*1e9)), Value: value}, true
+}
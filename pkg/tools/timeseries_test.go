@@ -0,0 +1,122 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+const rangeQueryResponse = `{
+  "status": "success",
+  "data": {
+    "resultType": "matrix",
+    "result": [
+      {
+        "metric": {"__name__": "container_memory_working_set_bytes", "pod": "web-1", "namespace": "default"},
+        "values": [[1700000000, "100"], [1700000060.5, "150"], [1700000120, "NaN"]]
+      },
+      {
+        "metric": {},
+        "values": [[1700000000, "1"]]
+      }
+    ]
+  }
+}`
+
+func TestChartFromResult(t *testing.T) {
+	var body any
+	if err := json.Unmarshal([]byte(rangeQueryResponse), &body); err != nil {
+		t.Fatalf("parsing response: %v", err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(rangeQueryResponse), &struct {
+		Data *map[string]any `json:"data"`
+	}{&data}); err != nil {
+		t.Fatalf("parsing response: %v", err)
+	}
+
+	want := &api.Chart{
+		Title: "memory",
+		Series: []api.ChartSeries{
+			{
+				Name: `container_memory_working_set_bytes{namespace="default",pod="web-1"}`,
+				Points: []api.ChartPoint{
+					{Time: time.Unix(1700000000, 0), Value: 100},
+					{Time: time.Unix(1700000060, 5e8), Value: 150},
+				},
+			},
+			{
+				Name:   "series 2",
+				Points: []api.ChartPoint{{Time: time.Unix(1700000000, 0), Value: 1}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		result any
+		want   *api.Chart
+	}{
+		{
+			name:   "http result",
+			result: &HTTPToolResult{StatusCode: 200, Body: body},
+			want:   want,
+		},
+		{
+			name:   "command output",
+			result: &ExecResult{Stdout: rangeQueryResponse},
+			want:   want,
+		},
+		{
+			name:   "command output without the envelope",
+			result: &ExecResult{Result: data},
+			want:   want,
+		},
+		{
+			name:   "instant query",
+			result: &ExecResult{Stdout: `{"status":"success","data":{"resultType":"vector","result":[]}}`},
+		},
+		{
+			name:   "not json",
+			result: &ExecResult{Stdout: "NAME    READY   STATUS\nweb-1   1/1     Running"},
+		},
+		{
+			name:   "failed command",
+			result: &ExecResult{Stdout: rangeQueryResponse, ExitCode: 1},
+		},
+		{
+			name:   "truncated body",
+			result: &HTTPToolResult{Body: body, Truncated: true},
+		},
+		{
+			name:   "no series",
+			result: &ExecResult{Stdout: `{"resultType":"matrix","result":[]}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ChartFromResult("memory", tt.result)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChartFromResult() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
 	"os"
@@ -26,6 +27,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
 	"github.com/google/uuid"
 	"sigs.k8s.io/yaml"
@@ -36,6 +39,18 @@ type ContextKey string
 const (
 	KubeconfigKey ContextKey = "kubeconfig"
 	WorkDirKey    ContextKey = "work_dir"
+	// EnvKey holds additional environment variables (map[string]string)
+	// set for every command run by the tools.
+	EnvKey ContextKey = "env"
+	// OutputBudgetKey is the context key of the number of tokens above which
+	// the kubectl listings are run again in a more compact format.
+	OutputBudgetKey ContextKey = "output_budget"
+	// WatchesKey holds the *Watches of the session, which run the watches
+	// registered by the watch_until tool.
+	WatchesKey ContextKey = "watches"
+	// MetricsKey holds the *MetricsHistory of the session, the samples of
+	// kubectl top compared by the compare_metrics tool.
+	MetricsKey ContextKey = "metrics"
 )
 
 func Lookup(name string) Tool {
@@ -55,6 +70,23 @@ func RegisterTool(tool Tool) {
 	allTools.RegisterTool(tool)
 }
 
+// DisableTools removes built-in tools from the default tools, e.g. for the
+// agent to only use a curated set of tools. It fails on unknown names, which
+// would leave enabled tools that were meant to be disabled.
+func DisableTools(names []string) error {
+	var unknown []string
+	for i, name := range names {
+		// The same tool may be disabled by several config files.
+		if !allTools.UnregisterTool(name) && !slices.Contains(names[:i], name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown tools %s, expected some of %s", strings.Join(unknown, ", "), strings.Join(allTools.Names(), ", "))
+	}
+	return nil
+}
+
 type Tools struct {
 	tools map[string]Tool
 }
@@ -87,6 +119,15 @@ func (t *Tools) RegisterTool(tool Tool) {
 	t.tools[tool.Name()] = tool
 }
 
+// UnregisterTool removes a tool, and reports whether it was registered.
+func (t *Tools) UnregisterTool(name string) bool {
+	if _, exists := t.tools[name]; !exists {
+		return false
+	}
+	delete(t.tools, name)
+	return true
+}
+
 type ToolCall struct {
 	tool      Tool
 	name      string
@@ -143,6 +184,22 @@ type InvokeToolOptions struct {
 
 	// Kubeconfig is the path to the kubeconfig file.
 	Kubeconfig string
+
+	// Env holds environment variables set for the tool, on top of
+	// the environment of the process.
+	Env map[string]string
+
+	// OutputBudget is the number of tokens above which the listings of the
+	// kubectl tool are run again in a more compact format, 0 to disable it.
+	OutputBudget int
+
+	// Watches run the watches of the session, nil if the tools can't
+	// register watches, e.g. in RunOnce mode.
+	Watches *Watches
+
+	// Metrics holds the metric samples of the session, nil if the usage
+	// can't be compared with earlier samples.
+	Metrics *MetricsHistory
 }
 
 type ToolRequestEvent struct {
@@ -155,6 +212,13 @@ type ToolResponseEvent struct {
 	CallID   string `json:"id,omitempty"`
 	Response any    `json:"response,omitempty"`
 	Error    string `json:"error,omitempty"`
+	// ErrorCategory classifies the failure of the call, if it failed.
+	ErrorCategory api.ErrorCategory `json:"errorCategory,omitempty"`
+}
+
+// OutputSchema returns the output schema of the invoked tool, or nil if it doesn't declare one.
+func (t *ToolCall) OutputSchema() *gollm.Schema {
+	return OutputSchemaOf(t.tool)
 }
 
 // InvokeTool handles the execution of a single action
@@ -174,13 +238,18 @@ func (t *ToolCall) InvokeTool(ctx context.Context, opt InvokeToolOptions) (any,
 
 	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
 	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+	ctx = context.WithValue(ctx, OutputBudgetKey, opt.OutputBudget)
+	ctx = context.WithValue(ctx, WatchesKey, opt.Watches)
+	ctx = context.WithValue(ctx, MetricsKey, opt.Metrics)
 
 	response, err := t.tool.Run(ctx, t.arguments)
 
 	{
 		ev := ToolResponseEvent{
-			CallID:   callID,
-			Response: response,
+			CallID:        callID,
+			Response:      response,
+			ErrorCategory: ErrorCategory(response, err),
 		}
 		if err != nil {
 			ev.Error = err.Error()
@@ -221,8 +290,13 @@ func ToolResultToMap(result any) (map[string]any, error) {
 	return m, nil
 }
 
+// customToolSources are the file and line the custom tools registered with
+// LoadAndRegisterCustomTools are defined at, by name.
+var customToolSources = map[string]string{}
+
 // LoadAndRegisterCustomTools loads tool configurations from a YAML file
-// and registers them.
+// and registers them. Invalid files are reported with an
+// *InvalidCustomToolsError, and none of their tools are registered.
 func LoadAndRegisterCustomTools(configPath string) error {
 	pathInfo, err := os.Stat(configPath)
 	if err != nil {
@@ -235,13 +309,15 @@ func LoadAndRegisterCustomTools(configPath string) error {
 			return fmt.Errorf("failed to read config dir %s: %w", configPath, err)
 		}
 
+		// The valid files are loaded, and the problems of all the others reported.
+		var errs []error
 		for _, entry := range configPaths {
 			if err := LoadAndRegisterCustomTools(filepath.Join(configPath, entry.Name())); err != nil {
-				return err
+				errs = append(errs, err)
 			}
 		}
 
-		return nil
+		return errors.Join(errs...)
 	}
 
 	yamlFile, err := os.ReadFile(configPath)
@@ -251,33 +327,82 @@ func LoadAndRegisterCustomTools(configPath string) error {
 		return fmt.Errorf("failed to read config file %s: %w", configPath, err)
 	}
 
-	var configs []CustomToolConfig
-	err = yaml.Unmarshal(yamlFile, &configs)
+	// The whole file is validated first, for none of its tools to be
+	// registered if any of them is invalid.
+	validated, err := validateCustomTools(configPath, yamlFile)
 	if err != nil {
-		return fmt.Errorf("failed to parse YAML config file %s: %w", configPath, err)
+		return err
+	}
+	for _, t := range validated {
+		tool, err := newToolFromConfig(t.config)
+		if err != nil {
+			return fmt.Errorf("%s:%d: failed to create tool %q: %w", configPath, t.line, t.config.Name, err)
+		}
+		RegisterTool(tool)
+		customToolSources[tool.Name()] = fmt.Sprintf("%s:%d", configPath, t.line)
+	}
+	return nil
+}
+
+// ParseCustomTools parses a YAML list of custom tool configurations and
+// creates the tools, without registering them.
+func ParseCustomTools(data []byte) ([]Tool, error) {
+	configs, err := ValidateCustomTools("", data)
+	if err != nil {
+		return nil, err
 	}
 
-	// Register each custom tool
-	var registrationErrors []string
+	var tools []Tool
 	for _, config := range configs {
-		tool, err := NewCustomTool(config)
+		tool, err := newToolFromConfig(config)
 		if err != nil {
-			registrationErrors = append(registrationErrors, fmt.Sprintf("failed to create tool %q: %v", config.Name, err))
-			continue // Skip registration if creation failed
-		}
-		// Check for duplicate registration attempt
-		if _, exists := allTools.tools[tool.Name()]; exists {
-			registrationErrors = append(registrationErrors, fmt.Sprintf("tool %q already registered (possibly built-in), skipping custom definition", tool.Name()))
-			continue
+			return nil, fmt.Errorf("failed to create tool %q: %w", config.Name, err)
 		}
-		RegisterTool(tool)
+		tools = append(tools, tool)
 	}
+	return tools, nil
+}
 
-	if len(registrationErrors) > 0 {
-		return fmt.Errorf("encountered errors during custom tool registration:\n - %s", strings.Join(registrationErrors, "\n - "))
+// SaveCustomToolConfig adds a custom tool configuration to the configuration
+// file at path, which is created if needed. If path is a directory, the
+// configuration is written to its own file in it. The existing content of the
+// file is kept as is. It returns the path of the file written.
+func SaveCustomToolConfig(path string, config CustomToolConfig) (string, error) {
+	entry, err := yaml.Marshal([]CustomToolConfig{config})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tool %q: %w", config.Name, err)
+	}
+	if _, err := ParseCustomTools(entry); err != nil {
+		return "", err
 	}
 
-	return nil
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, config.Name+".yaml")
+	}
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	var configs []CustomToolConfig
+	if err := yaml.Unmarshal(existing, &configs); err != nil {
+		return "", fmt.Errorf("failed to parse YAML config file %s: %w", path, err)
+	}
+	for _, c := range configs {
+		if c.Name == config.Name {
+			return "", fmt.Errorf("tool %q is already defined in %s", config.Name, path)
+		}
+	}
+
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		existing = append(existing, '\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, append(existing, entry...), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return path, nil
 }
 
 // For CustomTool
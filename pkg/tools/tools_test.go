@@ -0,0 +1,49 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnregisterTool(t *testing.T) {
+	var ts Tools
+	ts.Init()
+	ts.RegisterTool(&BashTool{})
+	ts.RegisterTool(&Kubectl{})
+
+	if !ts.UnregisterTool("bash") {
+		t.Errorf("UnregisterTool(bash) = false, want true")
+	}
+	if ts.UnregisterTool("bash") {
+		t.Errorf("UnregisterTool(bash) = true for a removed tool, want false")
+	}
+	if got, want := ts.Names(), []string{"kubectl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	// A custom tool can take the name of a removed tool.
+	ts.RegisterTool(&BashTool{})
+}
+
+func TestDisableToolsUnknown(t *testing.T) {
+	if err := DisableTools([]string{"kubectl_exec"}); err == nil {
+		t.Errorf("DisableTools(kubectl_exec) = nil, want an error for an unknown tool")
+	}
+	if Lookup("kubectl") == nil {
+		t.Errorf("DisableTools() removed kubectl")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// VersionSkew is the versions of the kubectl binary and of the API server,
+// when kubectl is outside of its supported version skew: kubectl supports
+// API servers one minor version older or newer than itself.
+type VersionSkew struct {
+	// Client is the version of kubectl, e.g. "v1.27.3".
+	Client string
+	// Server is the version of the API server, e.g. "v1.31.1-gke.1146000".
+	Server string
+	// Minors is the number of minor versions kubectl is newer than the API
+	// server, negative if it is older.
+	Minors int
+}
+
+// String describes the skew for the users.
+func (s *VersionSkew) String() string {
+	age := "older"
+	if s.Minors > 0 {
+		age = "newer"
+	}
+	minors := s.Minors
+	if minors < 0 {
+		minors = -minors
+	}
+	return fmt.Sprintf("kubectl %s is %d minor versions %s than the cluster (%s), outside the supported skew of one minor version: some commands may fail or behave differently", s.Client, minors, age, s.Server)
+}
+
+// CheckVersionSkew compares the versions of the kubectl binary and of the
+// API server, and returns the skew if it is not supported, or nil.
+func CheckVersionSkew(ctx context.Context, opt InvokeToolOptions) (*VersionSkew, error) {
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	ctx = context.WithValue(ctx, EnvKey, opt.Env)
+	out, err := kubectlOutput(ctx, "version", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	client, server := parseVersions(out)
+	return versionSkew(client, server)
+}
+
+// parseVersions extracts the client and server gitVersions from `kubectl version -o json`.
+func parseVersions(out []byte) (client, server string) {
+	var version struct {
+		ClientVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"clientVersion"`
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal(out, &version); err != nil {
+		return "", ""
+	}
+	return version.ClientVersion.GitVersion, version.ServerVersion.GitVersion
+}
+
+var versionRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+
+// minorVersion returns the major and minor versions of a gitVersion.
+func minorVersion(version string) (major, minor int, err error) {
+	m := versionRegexp.FindStringSubmatch(version)
+	if m == nil {
+		return 0, 0, fmt.Errorf("unexpected version %q", version)
+	}
+	major, _ = strconv.Atoi(m[1])
+	minor, _ = strconv.Atoi(m[2])
+	return major, minor, nil
+}
+
+func versionSkew(client, server string) (*VersionSkew, error) {
+	clientMajor, clientMinor, err := minorVersion(client)
+	if err != nil {
+		return nil, fmt.Errorf("kubectl version: %w", err)
+	}
+	serverMajor, serverMinor, err := minorVersion(server)
+	if err != nil {
+		return nil, fmt.Errorf("server version: %w", err)
+	}
+	if clientMajor != serverMajor {
+		return nil, fmt.Errorf("kubectl %s and the cluster (%s) have different major versions", client, server)
+	}
+	skew := &VersionSkew{Client: client, Server: server, Minors: clientMinor - serverMinor}
+	if skew.Minors >= -1 && skew.Minors <= 1 {
+		return nil, nil
+	}
+	return skew, nil
+}
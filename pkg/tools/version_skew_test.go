@@ -0,0 +1,61 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersionSkew(t *testing.T) {
+	out := []byte(`{
+  "clientVersion": {"major": "1", "minor": "27", "gitVersion": "v1.27.3"},
+  "kustomizeVersion": "v5.0.1",
+  "serverVersion": {"major": "1", "minor": "31", "gitVersion": "v1.31.1-gke.1146000"}
+}`)
+	client, server := parseVersions(out)
+	if client != "v1.27.3" || server != "v1.31.1-gke.1146000" {
+		t.Fatalf("parseVersions() = %q, %q", client, server)
+	}
+
+	tests := []struct {
+		client, server string
+		wantMinors     int
+		wantSkew       bool
+		wantErr        bool
+	}{
+		{client: "v1.31.0", server: "v1.31.1-gke.1146000"},
+		{client: "v1.32.0", server: "v1.31.1"},
+		{client: "v1.30.2", server: "v1.31.1"},
+		{client: "v1.27.3", server: "v1.31.1-gke.1146000", wantSkew: true, wantMinors: -4},
+		{client: "v1.33.1", server: "v1.31.1-eks-ae9a62a", wantSkew: true, wantMinors: 2},
+		{client: "v1.31.0", server: "", wantErr: true},
+	}
+	for _, tc := range tests {
+		skew, err := versionSkew(tc.client, tc.server)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("versionSkew(%q, %q) error = %v, want error: %v", tc.client, tc.server, err, tc.wantErr)
+			continue
+		}
+		if (skew != nil) != tc.wantSkew || skew != nil && skew.Minors != tc.wantMinors {
+			t.Errorf("versionSkew(%q, %q) = %+v, want minors %d", tc.client, tc.server, skew, tc.wantMinors)
+		}
+	}
+
+	skew := &VersionSkew{Client: "v1.27.3", Server: "v1.31.1", Minors: -4}
+	if got := skew.String(); !strings.HasPrefix(got, "kubectl v1.27.3 is 4 minor versions older than the cluster (v1.31.1)") {
+		t.Errorf("String() = %q", got)
+	}
+}
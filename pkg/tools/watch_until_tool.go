@@ -0,0 +1,430 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kel"
+	celtypes "github.com/google/cel-go/common/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	RegisterTool(&WatchUntil{})
+}
+
+const (
+	defaultWatchTimeout = 30 * time.Minute
+	maxWatchTimeout     = 24 * time.Hour
+	// watchRestartDelay is the delay before watching again when the API
+	// server closes a watch.
+	watchRestartDelay = time.Second
+)
+
+// Outcomes of a watch.
+const (
+	WatchMet     = "met"
+	WatchDeleted = "deleted"
+	WatchTimeout = "timeout"
+	WatchFailed  = "failed"
+)
+
+// WatchUntil registers a background watch of a resource, which notifies the
+// session when a CEL condition on the resource becomes true.
+type WatchUntil struct{}
+
+func (t *WatchUntil) Name() string {
+	return "watch_until"
+}
+
+func (t *WatchUntil) Description() string {
+	return `Watches a resource in the background until a condition on it is true, and notifies the user when it is, e.g. when a pod is ready or a rollout is complete. The user keeps chatting meanwhile, and is also notified if the resource is deleted or the watch times out.
+Use this tool when the user asks to be told when something happens ("tell me when this pod is Ready"). Don't wait or poll for the condition after registering the watch: answer right away.
+The condition is a CEL expression on the resource, named self, e.g.:
+- self.status.phase == "Running"
+- self.status.conditions.exists(c, c.type == "Ready" && c.status == "True")
+- has(self.status.readyReplicas) && self.status.readyReplicas == self.spec.replicas
+- self.status.succeeded >= 1`
+}
+
+func (t *WatchUntil) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"resource": {
+					Type:        gollm.TypeString,
+					Description: `The resource to watch as kind/name, e.g. "pod/web-0" or "deployment/web".`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace of the resource. Defaults to the namespace of the context.`,
+				},
+				"condition": {
+					Type:        gollm.TypeString,
+					Description: `The CEL expression on the resource (self) that ends the watch when true.`,
+				},
+				"timeout_minutes": {
+					Type:        gollm.TypeInteger,
+					Description: fmt.Sprintf("How long to watch, in minutes. Defaults to %d, at most %d.", int(defaultWatchTimeout.Minutes()), int(maxWatchTimeout.Minutes())),
+				},
+			},
+			Required: []string{"resource", "condition"},
+		},
+	}
+}
+
+// WatchUntilResult is the result of the watch_until tool.
+type WatchUntilResult struct {
+	ID        string    `json:"id"`
+	Resource  string    `json:"resource"`
+	Namespace string    `json:"namespace,omitempty"`
+	Condition string    `json:"condition"`
+	Deadline  time.Time `json:"deadline"`
+	Message   string    `json:"message"`
+}
+
+func (t *WatchUntil) Run(ctx context.Context, args map[string]any) (any, error) {
+	watches, _ := ctx.Value(WatchesKey).(*Watches)
+	if watches == nil {
+		return &ExecResult{Error: "watches are only available in interactive sessions"}, nil
+	}
+	resource, _ := args["resource"].(string)
+	namespace, _ := args["namespace"].(string)
+	condition, _ := args["condition"].(string)
+	resource, condition = strings.TrimSpace(resource), strings.TrimSpace(condition)
+	if !strings.Contains(resource, "/") {
+		return &ExecResult{Error: fmt.Sprintf("resource %q must be kind/name, e.g. pod/web-0", resource)}, nil
+	}
+	if condition == "" {
+		return &ExecResult{Error: "condition is required"}, nil
+	}
+	timeout := defaultWatchTimeout
+	if n, ok := args["timeout_minutes"].(float64); ok && n > 0 {
+		timeout = min(time.Duration(n)*time.Minute, maxWatchTimeout)
+	}
+
+	env, err := kel.NewEnv()
+	if err != nil {
+		return nil, fmt.Errorf("initializing CEL: %w", err)
+	}
+	expr, err := kel.NewExpression(env, condition)
+	if err != nil {
+		return &ExecResult{Error: err.Error()}, nil
+	}
+
+	watch := watches.Start(ctx, resource, namespace, expr, timeout)
+	return &WatchUntilResult{
+		ID:        watch.ID,
+		Resource:  watch.Resource,
+		Namespace: watch.Namespace,
+		Condition: watch.Condition,
+		Deadline:  watch.Deadline,
+		Message:   "The watch runs in the background, the user will be notified when the condition is true. Don't wait for it.",
+	}, nil
+}
+
+func (t *WatchUntil) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *WatchUntil) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// Watch is a watch registered with the watch_until tool.
+type Watch struct {
+	ID        string
+	Resource  string
+	Namespace string
+	Condition string
+	Deadline  time.Time
+
+	cancel context.CancelFunc
+}
+
+// WatchEvent reports the end of a watch.
+type WatchEvent struct {
+	ID        string `json:"id"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Condition string `json:"condition"`
+	// Outcome is WatchMet, WatchDeleted, WatchTimeout or WatchFailed.
+	Outcome string `json:"outcome"`
+	// Detail is the error of a failed watch, or the last values of the
+	// condition of a watch that timed out.
+	Detail string    `json:"detail,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+func (e *WatchEvent) String() string {
+	resource := e.Resource
+	if e.Namespace != "" {
+		resource += " in namespace " + e.Namespace
+	}
+	var s string
+	switch e.Outcome {
+	case WatchMet:
+		s = fmt.Sprintf("%s: `%s` is now true.", resource, e.Condition)
+	case WatchDeleted:
+		s = fmt.Sprintf("%s was deleted before `%s` was true.", resource, e.Condition)
+	case WatchTimeout:
+		s = fmt.Sprintf("%s: `%s` is still false, the watch timed out.", resource, e.Condition)
+	default:
+		s = fmt.Sprintf("%s: the watch of `%s` failed.", resource, e.Condition)
+	}
+	if e.Detail != "" {
+		s += " (" + e.Detail + ")"
+	}
+	return fmt.Sprintf("Watch %s: %s", e.ID, s)
+}
+
+// Watches runs the watches of a session in the background, and reports their
+// events to notify.
+type Watches struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	notify func(*WatchEvent)
+
+	mu      sync.Mutex
+	next    int
+	watches map[string]*Watch
+}
+
+// NewWatches returns the watches of a session, notify is called from the
+// goroutines of the watches.
+func NewWatches(notify func(*WatchEvent)) *Watches {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Watches{ctx: ctx, cancel: cancel, notify: notify, watches: map[string]*Watch{}}
+}
+
+// Start starts watching a resource until expr is true, with the kubeconfig,
+// working directory and environment of ctx.
+func (w *Watches) Start(ctx context.Context, resource, namespace string, expr *kel.Expression, timeout time.Duration) *Watch {
+	watchCtx, cancel := context.WithTimeout(w.ctx, timeout)
+	for _, key := range []ContextKey{KubeconfigKey, WorkDirKey, EnvKey} {
+		watchCtx = context.WithValue(watchCtx, key, ctx.Value(key))
+	}
+
+	w.mu.Lock()
+	w.next++
+	watch := &Watch{
+		ID:        "w" + strconv.Itoa(w.next),
+		Resource:  resource,
+		Namespace: namespace,
+		Condition: expr.CELText,
+		Deadline:  time.Now().Add(timeout),
+		cancel:    cancel,
+	}
+	w.watches[watch.ID] = watch
+	w.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		event := runWatch(watchCtx, watch, expr)
+		w.mu.Lock()
+		delete(w.watches, watch.ID)
+		w.mu.Unlock()
+		if event != nil {
+			w.notify(event)
+		}
+	}()
+	return watch
+}
+
+// List returns the running watches, by ID.
+func (w *Watches) List() []Watch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var watches []Watch
+	for _, watch := range w.watches {
+		watches = append(watches, *watch)
+	}
+	sort.Slice(watches, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(watches[i].ID, "w"))
+		b, _ := strconv.Atoi(strings.TrimPrefix(watches[j].ID, "w"))
+		return a < b
+	})
+	return watches
+}
+
+// Cancel stops a watch without notifying it, and reports whether it was running.
+func (w *Watches) Cancel(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	watch, ok := w.watches[id]
+	if ok {
+		watch.cancel()
+		delete(w.watches, id)
+	}
+	return ok
+}
+
+// Close stops all the watches.
+func (w *Watches) Close() {
+	w.cancel()
+}
+
+// runWatch watches a resource with kubectl until the watch ends, and returns
+// its event, or nil if it was canceled.
+func runWatch(ctx context.Context, watch *Watch, expr *kel.Expression) *WatchEvent {
+	log := klog.FromContext(ctx).WithValues("watch", watch.ID, "resource", watch.Resource)
+	event := &WatchEvent{ID: watch.ID, Resource: watch.Resource, Namespace: watch.Namespace, Condition: watch.Condition}
+	done := func(outcome, detail string) *WatchEvent {
+		event.Outcome, event.Detail, event.Time = outcome, detail, time.Now()
+		return event
+	}
+
+	args := []string{"get", watch.Resource, "-o", "json", "--watch", "--output-watch-events"}
+	if watch.Namespace != "" {
+		args = append(args, "--namespace", watch.Namespace)
+	}
+	var last *unstructured.Unstructured
+	for {
+		outcome, obj, err := watchKubectl(ctx, args, expr)
+		if obj != nil {
+			last = obj
+		}
+		switch {
+		case outcome != "":
+			return done(outcome, "")
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return done(WatchTimeout, lastValues(ctx, expr, last))
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			return done(WatchFailed, err.Error())
+		}
+		// The API server closed the watch.
+		log.V(2).Info("watching again")
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchRestartDelay):
+		}
+	}
+}
+
+// watchKubectl runs a kubectl watch, and returns the outcome of the watch if
+// it ended, and the last version of the object.
+func watchKubectl(ctx context.Context, args []string, expr *kel.Expression) (string, *unstructured.Unstructured, error) {
+	kubeconfig, _ := ctx.Value(KubeconfigKey).(string)
+	workDir, _ := ctx.Value(WorkDirKey).(string)
+
+	// The watch is stopped once it ended.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Env = commandEnv(ctx)
+	cmd.Dir = workDir
+	if kubeconfig != "" {
+		kubeconfig, err := expandShellVar(kubeconfig)
+		if err != nil {
+			return "", nil, err
+		}
+		cmd.Env = append(cmd.Env, "KUBECONFIG="+kubeconfig)
+	}
+	// Don't wait for the processes started by kubectl once it is stopped.
+	cmd.WaitDelay = time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", nil, fmt.Errorf("running kubectl: %w", err)
+	}
+
+	outcome, obj, err := evalWatchEvents(ctx, stdout, expr)
+	if outcome != "" || err != nil {
+		cancel()
+		cmd.Wait()
+		return outcome, obj, err
+	}
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		return "", obj, fmt.Errorf("running kubectl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return "", obj, nil
+}
+
+// evalWatchEvents evaluates expr on the objects of the watch events of
+// kubectl --output-watch-events, until it is true or the object is deleted.
+// Objects on which the condition can't be evaluated, e.g. for lack of status,
+// don't end the watch.
+func evalWatchEvents(ctx context.Context, r io.Reader, expr *kel.Expression) (string, *unstructured.Unstructured, error) {
+	log := klog.FromContext(ctx)
+	decoder := json.NewDecoder(r)
+	var last *unstructured.Unstructured
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", last, nil
+			}
+			return "", last, fmt.Errorf("reading watch events: %w", err)
+		}
+		if event.Type == "DELETED" {
+			return WatchDeleted, last, nil
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(event.Object); err != nil {
+			return "", last, fmt.Errorf("parsing watched object: %w", err)
+		}
+		last = obj
+		out, err := expr.Eval(ctx, obj)
+		if err != nil {
+			log.V(2).Info("condition not evaluated", "err", err)
+			continue
+		}
+		if out.Type() != celtypes.BoolType {
+			return "", last, fmt.Errorf("condition is a %s, not a bool", out.Type().TypeName())
+		}
+		if out.Value().(bool) {
+			return WatchMet, last, nil
+		}
+	}
+}
+
+// lastValues describes the values of the terms of the condition on the last
+// version of the object, e.g. "self.status.readyReplicas=1".
+func lastValues(ctx context.Context, expr *kel.Expression, obj *unstructured.Unstructured) string {
+	if obj == nil {
+		return ""
+	}
+	printer, err := expr.BuildStatusPrinter(ctx)
+	if err != nil || printer == nil {
+		return ""
+	}
+	return printer(ctx, obj)
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kel"
+)
+
+const podReady = `self.status.conditions.exists(c, c.type == "Ready" && c.status == "True")`
+
+func podEvent(eventType, ready string) string {
+	return `{"type": "` + eventType + `", "object": {"kind": "Pod", "metadata": {"name": "web-0"}, "spec": {"priority": 0}, "status": {"phase": "Running", "conditions": [{"type": "Ready", "status": "` + ready + `"}]}}}` + "\n"
+}
+
+func TestEvalWatchEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		events    string
+		want      string
+		wantErr   bool
+	}{
+		{name: "met", condition: podReady, events: podEvent("ADDED", "False") + podEvent("MODIFIED", "True"), want: WatchMet},
+		{name: "still false", condition: podReady, events: podEvent("ADDED", "False")},
+		{name: "deleted", condition: podReady, events: podEvent("ADDED", "False") + podEvent("DELETED", "False"), want: WatchDeleted},
+		// Missing fields don't end the watch.
+		{name: "missing field", condition: `self.status.readyReplicas >= 1`, events: podEvent("ADDED", "True")},
+		{name: "numbers", condition: `self.spec.priority == 0`, events: podEvent("ADDED", "True"), want: WatchMet},
+		{name: "not a bool", condition: `self.status.phase`, events: podEvent("ADDED", "True"), wantErr: true},
+	}
+	env, err := kel.NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := kel.NewExpression(env, tt.condition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, obj, err := evalWatchEvents(context.Background(), strings.NewReader(tt.events), expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("evalWatchEvents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("evalWatchEvents() = %q, want %q", got, tt.want)
+			}
+			if obj == nil || obj.GetName() != "web-0" {
+				t.Errorf("evalWatchEvents() object = %v, want web-0", obj)
+			}
+		})
+	}
+}
+
+func TestWatchUntil(t *testing.T) {
+	dir := t.TempDir()
+	// The fake kubectl reports the pod not ready, then ready.
+	script := "echo '" + podEvent("ADDED", "False") + podEvent("MODIFIED", "True") + "'\nsleep 10\n"
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	events := make(chan *WatchEvent, 1)
+	watches := NewWatches(func(event *WatchEvent) { events <- event })
+	defer watches.Close()
+	ctx := context.WithValue(context.Background(), WatchesKey, watches)
+	tool := &WatchUntil{}
+
+	out, err := tool.Run(ctx, map[string]any{"resource": "pod/web-0", "namespace": "shop", "condition": podReady})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	result, ok := out.(*WatchUntilResult)
+	if !ok {
+		t.Fatalf("Run() = %+v, want a WatchUntilResult", out)
+	}
+	select {
+	case event := <-events:
+		if event.ID != result.ID || event.Outcome != WatchMet || event.Namespace != "shop" {
+			t.Errorf("event = %+v, want watch %s met", event, result.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watch didn't end")
+	}
+	if running := watches.List(); len(running) != 0 {
+		t.Errorf("running watches = %+v, want none", running)
+	}
+
+	for _, args := range []map[string]any{
+		{"resource": "web-0", "condition": podReady},
+		{"resource": "pod/web-0", "condition": "self.status.("},
+	} {
+		out, err := tool.Run(ctx, args)
+		if result, ok := out.(*ExecResult); err != nil || !ok || result.Error == "" {
+			t.Errorf("Run(%v) = %+v, %v, want an error result", args, out, err)
+		}
+	}
+	// Watches need a session.
+	out, _ = tool.Run(context.Background(), map[string]any{"resource": "pod/web-0", "condition": podReady})
+	if result, ok := out.(*ExecResult); !ok || result.Error == "" {
+		t.Errorf("Run() without watches = %+v, want an error result", out)
+	}
+}
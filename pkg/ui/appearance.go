@@ -0,0 +1,84 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Themes are the color themes of the UIs. The default theme follows the
+// light or dark preference of the system.
+var Themes = []string{"dark", "light", "high-contrast", "colorblind"}
+
+// FontSizes are the text sizes of the web UI.
+var FontSizes = []string{"small", "medium", "large", "x-large"}
+
+// Appearance holds the theming and accessibility options of the UIs.
+type Appearance struct {
+	// Theme is the color theme, one of Themes, or empty to follow the system.
+	// colorblind is a palette safe for red-green color blindness, which uses
+	// blue and orange instead of green and red.
+	Theme string `json:"theme,omitempty"`
+	// FontSize is the default text size of the web UI, one of FontSizes.
+	FontSize string `json:"fontSize,omitempty"`
+	// ReducedMotion disables the animations of the web UI.
+	ReducedMotion bool `json:"reducedMotion,omitempty"`
+	// ScreenReader makes the output of the terminal UI friendly to screen
+	// readers: plain text without colors, emojis, box-drawing characters
+	// or spinners.
+	ScreenReader bool `json:"screenReader,omitempty"`
+}
+
+// Validate checks that the options are known.
+func (a *Appearance) Validate() error {
+	if a.Theme != "" && !slices.Contains(Themes, a.Theme) {
+		return fmt.Errorf("invalid theme %q, expected one of %s", a.Theme, strings.Join(Themes, ", "))
+	}
+	if a.FontSize != "" && !slices.Contains(FontSizes, a.FontSize) {
+		return fmt.Errorf("invalid font size %q, expected one of %s", a.FontSize, strings.Join(FontSizes, ", "))
+	}
+	return nil
+}
+
+// terminalPalette maps the colors of the terminal UI to ANSI escape codes.
+func (a *Appearance) terminalPalette() map[colorValue]string {
+	switch {
+	case a.ScreenReader:
+		return map[colorValue]string{}
+	case a.Theme == "high-contrast":
+		return map[colorValue]string{colorGreen: "\033[1;92m", colorRed: "\033[1;91m", colorWhite: "\033[1;97m"}
+	case a.Theme == "colorblind":
+		// Blue and orange (yellow in 16 colors) are told apart with all
+		// common color blindnesses.
+		return map[colorValue]string{colorGreen: "\033[34m", colorRed: "\033[33m", colorWhite: "\033[37m"}
+	}
+	return map[colorValue]string{colorGreen: "\033[32m", colorRed: "\033[31m", colorWhite: "\033[37m"}
+}
+
+// markdownStyle returns the glamour style rendering the markdown of the
+// terminal UI, or "" for the style matching the background of the terminal.
+func (a *Appearance) markdownStyle() string {
+	switch {
+	case a.ScreenReader:
+		return "ascii"
+	case a.Theme == "dark" || a.Theme == "high-contrast":
+		return "dark"
+	case a.Theme == "light":
+		return "light"
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+const (
+	// sparklineWidth is the maximum number of blocks of a sparkline.
+	sparklineWidth = 40
+	// maxSeriesNameWidth is the width above which the names of series are cut.
+	maxSeriesNameWidth = 48
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// formatChart renders a chart as a sparkline per series, with the range and
+// the last value of the series, e.g.
+//
+//	cpu{pod="web-1"}  ▁▂▂▃▅▇█▆  0.12 – 0.98, last 0.87
+//
+// plain leaves out the emoji and the sparklines, for screen readers.
+func formatChart(chart *api.Chart, plain bool) string {
+	var sb strings.Builder
+	if plain {
+		sb.WriteString("\n  Chart: " + chart.Title + "\n")
+	} else {
+		sb.WriteString("\n  📈 " + chart.Title + "\n")
+	}
+
+	nameWidth := 0
+	for _, series := range chart.Series {
+		nameWidth = max(nameWidth, min(len(series.Name), maxSeriesNameWidth))
+	}
+	for _, series := range chart.Series {
+		values := make([]float64, len(series.Points))
+		for i, point := range series.Points {
+			values[i] = point.Value
+		}
+		name := series.Name
+		if len(name) > maxSeriesNameWidth {
+			name = name[:maxSeriesNameWidth-1] + "…"
+		}
+		lo, hi := minMax(values)
+		if plain {
+			fmt.Fprintf(&sb, "  %s: from %s to %s, last %s\n", name, formatValue(lo), formatValue(hi), formatValue(values[len(values)-1]))
+			continue
+		}
+		fmt.Fprintf(&sb, "  %-*s  %s  %s – %s, last %s\n", nameWidth, name, sparkline(values, sparklineWidth),
+			formatValue(lo), formatValue(hi), formatValue(values[len(values)-1]))
+	}
+	return sb.String()
+}
+
+// sparkline renders values as unicode blocks scaled between their minimum
+// and maximum. Values are averaged into at most width blocks.
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		buckets := make([]float64, width)
+		for i := range buckets {
+			start, end := i*len(values)/width, (i+1)*len(values)/width
+			sum := 0.0
+			for _, v := range values[start:end] {
+				sum += v
+			}
+			buckets[i] = sum / float64(end-start)
+		}
+		values = buckets
+	}
+
+	lo, hi := minMax(values)
+	var sb strings.Builder
+	for _, v := range values {
+		level := 0
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+		}
+		sb.WriteRune(sparkBlocks[level])
+	}
+	return sb.String()
+}
+
+func minMax(values []float64) (lo, hi float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	lo, hi = values[0], values[0]
+	for _, v := range values[1:] {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	return lo, hi
+}
+
+// formatValue formats a value with 3 significant digits, e.g. 0.123 or 1.5e+09.
+func formatValue(v float64) string {
+	return fmt.Sprintf("%.3g", v)
+}
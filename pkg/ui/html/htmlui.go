@@ -15,21 +15,27 @@
 package html
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/health"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
 	"github.com/charmbracelet/glamour"
 	"golang.org/x/sync/errgroup"
@@ -98,6 +104,19 @@ type HTMLUserInterface struct {
 	journal          journal.Recorder
 	markdownRenderer *glamour.TermRenderer
 	broadcaster      *Broadcaster
+
+	// streaming is the model text of the response being generated,
+	// meter its latest stats, and progress the phase the agent is in.
+	streamingMu sync.Mutex
+	streaming   string
+	meter       *api.StreamStats
+	progress    *progressInfo
+
+	// appearance is the default theme and accessibility options of the page.
+	appearance ui.Appearance
+
+	// oidc logs the users in, if the web UI requires a login.
+	oidc *oidcAuthenticator
 }
 
 var _ ui.UI = &HTMLUserInterface{}
@@ -120,6 +139,12 @@ func NewHTMLUserInterface(agent *agent.Agent, listenAddress string, journal jour
 	mux.HandleFunc("GET /messages-stream", u.serveMessagesStream)
 	mux.HandleFunc("POST /send-message", u.handlePOSTSendMessage)
 	mux.HandleFunc("POST /choose-option", u.handlePOSTChooseOption)
+	mux.HandleFunc("GET /sessions", u.serveSessions)
+	mux.HandleFunc("POST /add-note", u.handlePOSTAddNote)
+	mux.HandleFunc("POST /remove-note", u.handlePOSTRemoveNote)
+	mux.HandleFunc("GET /meta-commands", u.serveMetaCommands)
+	mux.HandleFunc("GET /report", u.serveReport)
+	health.NewChecker(agent.HealthChecks()...).Register(mux)
 
 	httpServerListener, err := net.Listen("tcp", listenAddress)
 	if err != nil {
@@ -159,20 +184,24 @@ func (u *HTMLUserInterface) Run(ctx context.Context) error {
 			select {
 			case <-gctx.Done():
 				return nil
-			case _, ok := <-u.agent.Output:
+			case msg, ok := <-u.agent.Output:
 				if !ok {
 					return nil // Channel closed
 				}
+				// Text deltas, stats and progress are not part of the session,
+				// we track them ourselves until the next message arrives, and
+				// only send what changed so that clients render the text as
+				// it is generated.
+				if m, ok := msg.(*api.Message); ok {
+					if event := u.trackStreaming(m); event != nil {
+						u.broadcaster.Broadcast(event)
+						continue
+					}
+				}
 				// We received a message from the agent. It's a signal that
 				// the state has changed. We fetch the entire current state and
 				// broadcast it to all connected clients.
-				jsonData, err := u.getCurrentStateJSON()
-				if err != nil {
-					// Don't return an error, just log it and continue
-					klog.Errorf("Error marshaling state for broadcast: %v", err)
-					continue
-				}
-				u.broadcaster.Broadcast(jsonData)
+				u.broadcastState()
 			}
 		}
 	})
@@ -197,12 +226,43 @@ func (u *HTMLUserInterface) Run(ctx context.Context) error {
 	return g.Wait()
 }
 
+// SetAppearance sets the default theme and accessibility options of the
+// page, which users can change in their browser. It must be called before Run.
+func (u *HTMLUserInterface) SetAppearance(appearance ui.Appearance) {
+	u.appearance = appearance
+}
+
+// SetOIDC requires the users to log in with an OpenID Connect identity
+// provider. Their identity is then the author of their messages and the
+// approver of the tool calls they decide on, and the first of them to send a
+// message owns the session. It must be called before Run.
+func (u *HTMLUserInterface) SetOIDC(ctx context.Context, config OIDCConfig) error {
+	oidc, err := newOIDCAuthenticator(ctx, config)
+	if err != nil {
+		return err
+	}
+	u.oidc = oidc
+	u.httpServer.Handler = oidc.handler(u.httpServer.Handler)
+	return nil
+}
+
 //go:embed index.html
 var indexHTML []byte
 
+// appearanceScript is the element of index.html holding the appearance
+// configured on the server.
+const appearanceScript = `<script id="appearance" type="application/json">%s</script>`
+
 func (u *HTMLUserInterface) serveIndex(w http.ResponseWriter, req *http.Request) {
+	// json.Marshal escapes <, > and &, so that the JSON can't end the script.
+	appearance, err := json.Marshal(u.appearance)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := bytes.Replace(indexHTML, []byte(fmt.Sprintf(appearanceScript, "{}")), []byte(fmt.Sprintf(appearanceScript, appearance)), 1)
 	w.Header().Set("Content-Type", "text/html")
-	w.Write(indexHTML)
+	w.Write(page)
 }
 
 func (u *HTMLUserInterface) serveMessagesStream(w http.ResponseWriter, req *http.Request) {
@@ -219,7 +279,8 @@ func (u *HTMLUserInterface) serveMessagesStream(w http.ResponseWriter, req *http
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
-	clientChan := make(chan []byte, 10)
+	// The buffer holds the deltas of the text being streamed, sent token by token.
+	clientChan := make(chan []byte, 100)
 	u.broadcaster.newClient <- clientChan
 	defer func() {
 		u.broadcaster.delClient <- clientChan
@@ -232,7 +293,7 @@ func (u *HTMLUserInterface) serveMessagesStream(w http.ResponseWriter, req *http
 	if err != nil {
 		log.Error(err, "getting initial state for SSE client")
 	} else {
-		fmt.Fprintf(w, "data: %s\n\n", initialData)
+		w.Write(sseEvent("", initialData))
 		flusher.Flush()
 	}
 
@@ -242,17 +303,32 @@ func (u *HTMLUserInterface) serveMessagesStream(w http.ResponseWriter, req *http
 			log.Info("SSE client disconnected")
 			return
 		case msg := <-clientChan:
-			fmt.Fprintf(w, "data: %s\n\n", msg)
+			w.Write(msg)
 			flusher.Flush()
 		}
 	}
 }
 
+// Limits on the images attached to a message.
+const (
+	maxImages     = 4
+	maxImageBytes = 5 << 20
+)
+
+// supportedImageTypes are the image types accepted by multimodal models.
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+	"image/gif":  true,
+}
+
 func (u *HTMLUserInterface) handlePOSTSendMessage(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	log := klog.FromContext(ctx)
 
-	if err := req.ParseForm(); err != nil {
+	req.Body = http.MaxBytesReader(w, req.Body, maxImages*maxImageBytes+1<<20)
+	if err := req.ParseMultipartForm(maxImageBytes); err != nil && !errors.Is(err, http.ErrNotMultipart) {
 		log.Error(err, "parsing form")
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -261,17 +337,61 @@ func (u *HTMLUserInterface) handlePOSTSendMessage(w http.ResponseWriter, req *ht
 	log.Info("got request", "values", req.Form)
 
 	q := req.FormValue("q")
-	if q == "" {
+	images, err := readImages(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q == "" && len(images) == 0 {
 		http.Error(w, "missing query", http.StatusBadRequest)
 		return
 	}
 
+	if user := userFromContext(ctx); user != "" {
+		if _, err := u.agent.ClaimSession(user); err != nil {
+			log.Error(err, "recording the owner of the session")
+		}
+	}
+
 	// Send the message to the agent
-	u.agent.Input <- &api.UserInputResponse{Query: q}
+	u.agent.Input <- &api.UserInputResponse{Query: q, Images: images, Author: operatorFromRequest(req)}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// readImages reads the images uploaded in the "image" fields of a multipart form.
+func readImages(req *http.Request) ([]api.Image, error) {
+	if req.MultipartForm == nil {
+		return nil, nil
+	}
+	files := req.MultipartForm.File["image"]
+	if len(files) > maxImages {
+		return nil, fmt.Errorf("too many images: at most %d can be attached", maxImages)
+	}
+	var images []api.Image
+	for _, header := range files {
+		if header.Size > maxImageBytes {
+			return nil, fmt.Errorf("image %q is too large: at most %d MiB are allowed", header.Filename, maxImageBytes>>20)
+		}
+		f, err := header.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening image %q: %w", header.Filename, err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading image %q: %w", header.Filename, err)
+		}
+		// Don't trust the type sent by the browser.
+		mimeType := http.DetectContentType(data)
+		if !supportedImageTypes[mimeType] {
+			return nil, fmt.Errorf("image %q has unsupported type %q", header.Filename, mimeType)
+		}
+		images = append(images, api.Image{Name: header.Filename, MIMEType: mimeType, Data: data})
+	}
+	return images, nil
+}
+
 func (u *HTMLUserInterface) getCurrentStateJSON() ([]byte, error) {
 	allMessages := u.agent.Session().AllMessages()
 	// Create a copy of the messages to avoid race conditions
@@ -285,13 +405,206 @@ func (u *HTMLUserInterface) getCurrentStateJSON() ([]byte, error) {
 
 	agentState := u.agent.Session().AgentState
 
+	u.streamingMu.Lock()
+	streaming := u.streaming
+	meter := u.meter
+	progress := u.progress
+	u.streamingMu.Unlock()
+
 	data := map[string]interface{}{
 		"messages":   messages,
 		"agentState": agentState,
+		"streaming":  streaming,
+		"meter":      meter,
+		"progress":   progress,
+		"notes":      u.agent.Notes(),
+		"tags":       u.agent.SessionTags(),
+		"approvals":  u.agent.PendingApprovals(),
 	}
 	return json.Marshal(data)
 }
 
+// progressInfo is the progress of the agent sent to the page, which shows the
+// time elapsed since it started.
+type progressInfo struct {
+	Label   string    `json:"label"`
+	Started time.Time `json:"started"`
+}
+
+// streamDelta is the event of a text delta.
+type streamDelta struct {
+	Text string `json:"text"`
+}
+
+// trackStreaming accumulates text deltas and keeps the latest stats and
+// progress, and resets them once any other message (normally the complete
+// text) is received. It returns the event to send to the clients for the
+// deltas, stats and progress, and nil for the other messages, after which the
+// whole state is sent.
+func (u *HTMLUserInterface) trackStreaming(message *api.Message) []byte {
+	u.streamingMu.Lock()
+	defer u.streamingMu.Unlock()
+	var name string
+	var payload any
+	switch message.Type {
+	case api.MessageTypeTextDelta:
+		u.streaming += message.Payload.(string)
+		name, payload = "delta", streamDelta{Text: message.Payload.(string)}
+	case api.MessageTypeStreamStats:
+		u.meter = message.Payload.(*api.StreamStats)
+		name, payload = "meter", u.meter
+	case api.MessageTypeProgress:
+		progress := message.Payload.(*api.Progress)
+		u.progress = &progressInfo{Label: progress.Label(), Started: progress.Started}
+		name, payload = "progress", u.progress
+	default:
+		u.streaming = ""
+		u.meter = nil
+		u.progress = nil
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		klog.Errorf("Error marshaling %s event: %v", name, err)
+		return nil
+	}
+	return sseEvent(name, data)
+}
+
+// sseEvent formats a Server-Sent Event. Unnamed events carry the whole state.
+func sseEvent(name string, data []byte) []byte {
+	var b bytes.Buffer
+	if name != "" {
+		fmt.Fprintf(&b, "event: %s\n", name)
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+	return b.Bytes()
+}
+
+// sessionInfo is the JSON representation of a persisted session served by the web UI.
+type sessionInfo struct {
+	ID string `json:"id"`
+	*sessions.Metadata
+}
+
+// serveSessions returns the persisted sessions and their metadata, including
+// the usage snapshot (context, namespaces, commands and modified resources).
+// The "tag" query parameters only return the sessions with all the tags.
+func (u *HTMLUserInterface) serveSessions(w http.ResponseWriter, req *http.Request) {
+	log := klog.FromContext(req.Context())
+	tags := req.URL.Query()["tag"]
+
+	manager, err := sessions.NewSessionManager()
+	if err != nil {
+		log.Error(err, "creating session manager")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sessionList, err := manager.ListSessions()
+	if err != nil {
+		log.Error(err, "listing sessions")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	infos := []sessionInfo{}
+	for _, session := range sessionList {
+		metadata, err := session.LoadMetadata()
+		if err != nil {
+			log.Error(err, "loading session metadata", "session", session.ID)
+			continue
+		}
+		if !metadata.HasTags(tags) {
+			continue
+		}
+		infos = append(infos, sessionInfo{ID: session.ID, Metadata: metadata})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(infos); err != nil {
+		log.Error(err, "writing sessions response")
+	}
+}
+
+// serveMetaCommands returns the catalog of the meta commands, listed by the
+// command palette, without the commands of terminals.
+func (u *HTMLUserInterface) serveMetaCommands(w http.ResponseWriter, req *http.Request) {
+	commands := slices.DeleteFunc(u.agent.MetaCommands(), func(command agent.MetaCommand) bool {
+		return command.TerminalOnly
+	})
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(commands); err != nil {
+		klog.FromContext(req.Context()).Error(err, "writing meta commands response")
+	}
+}
+
+// serveReport downloads the HTML report of the current session.
+func (u *HTMLUserInterface) serveReport(w http.ResponseWriter, req *http.Request) {
+	session := u.agent.Session()
+	var metadata *sessions.Metadata
+	if s, ok := session.ChatMessageStore.(*sessions.Session); ok {
+		var err error
+		if metadata, err = s.LoadMetadata(); err != nil {
+			klog.FromContext(req.Context()).Error(err, "loading session metadata", "session", s.ID)
+		}
+	}
+	var buf bytes.Buffer
+	if err := NewReport(session.ID, metadata, session.AllMessages()).Write(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	name := "kubectl-ai-report.html"
+	if session.ID != "" {
+		name = "kubectl-ai-" + session.ID + ".html"
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Write(buf.Bytes())
+}
+
+// handlePOSTAddNote pins the note in the "text" field to the session.
+func (u *HTMLUserInterface) handlePOSTAddNote(w http.ResponseWriter, req *http.Request) {
+	log := klog.FromContext(req.Context())
+
+	note := strings.TrimSpace(req.FormValue("text"))
+	if note == "" {
+		http.Error(w, "missing note", http.StatusBadRequest)
+		return
+	}
+	if err := u.agent.AddNote(note); err != nil {
+		log.Error(err, "adding note")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	u.broadcastState()
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePOSTRemoveNote removes the note at the position in the "index" field, starting at 1.
+func (u *HTMLUserInterface) handlePOSTRemoveNote(w http.ResponseWriter, req *http.Request) {
+	n, err := strconv.Atoi(req.FormValue("index"))
+	if err != nil {
+		http.Error(w, "invalid index", http.StatusBadRequest)
+		return
+	}
+	if err := u.agent.RemoveNote(n); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	u.broadcastState()
+	w.WriteHeader(http.StatusOK)
+}
+
+// broadcastState sends the current state to all connected clients.
+func (u *HTMLUserInterface) broadcastState() {
+	jsonData, err := u.getCurrentStateJSON()
+	if err != nil {
+		klog.Errorf("Error marshaling state for broadcast: %v", err)
+		return
+	}
+	u.broadcaster.Broadcast(sseEvent("", jsonData))
+}
+
 func (u *HTMLUserInterface) handlePOSTChooseOption(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	log := klog.FromContext(ctx)
@@ -316,12 +629,62 @@ func (u *HTMLUserInterface) handlePOSTChooseOption(w http.ResponseWriter, req *h
 		return
 	}
 
+	// A choice made after the calls were decided, e.g. from another browser,
+	// must not reach the agent while it is not waiting for one.
+	if len(u.agent.PendingApprovals()) == 0 {
+		http.Error(w, "no tool call is awaiting approval", http.StatusConflict)
+		return
+	}
+
 	// Send the choice to the agent
-	u.agent.Input <- &api.UserChoiceResponse{Choice: choiceIndex}
+	u.agent.Input <- &api.UserChoiceResponse{
+		Choice:   choiceIndex,
+		Approver: operatorFromRequest(req),
+		CallIDs:  req.Form["call"],
+		Command:  req.FormValue("command"),
+		Reason:   req.FormValue("reason"),
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// authenticatedUserHeaders are the headers identifying the user, as set by
+// common authenticating proxies (oauth2-proxy, Identity-Aware Proxy).
+var authenticatedUserHeaders = []string{
+	"X-Forwarded-Email",
+	"X-Forwarded-User",
+	"X-Auth-Request-Email",
+	"X-Auth-Request-User",
+	"X-Goog-Authenticated-User-Email",
+}
+
+// operatorFromRequest identifies the user sending a query or making a choice
+// in the web UI, for the audit trail of queries and approvals. Without an
+// OIDC login, it relies on an authenticating proxy in front of the web UI,
+// and falls back to the address of the client.
+func operatorFromRequest(req *http.Request) string {
+	if user := userFromContext(req.Context()); user != "" {
+		return user
+	}
+	for _, header := range authenticatedUserHeaders {
+		if user := req.Header.Get(header); user != "" {
+			// IAP prefixes the email with the identity provider, e.g. "accounts.google.com:".
+			if i := strings.LastIndex(user, ":"); i >= 0 {
+				user = user[i+1:]
+			}
+			return user
+		}
+	}
+	if user, _, ok := req.BasicAuth(); ok && user != "" {
+		return user
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "web:" + host
+}
+
 func (u *HTMLUserInterface) Close() error {
 	var errs []error
 	if u.httpServerListener != nil {
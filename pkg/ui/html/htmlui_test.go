@@ -0,0 +1,126 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package html
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+)
+
+func TestServeIndexAppearance(t *testing.T) {
+	u := &HTMLUserInterface{}
+	u.SetAppearance(ui.Appearance{Theme: "high-contrast", FontSize: "large", ReducedMotion: true})
+
+	w := httptest.NewRecorder()
+	u.serveIndex(w, httptest.NewRequest("GET", "/", nil))
+	want := `<script id="appearance" type="application/json">{"theme":"high-contrast","fontSize":"large","reducedMotion":true}</script>`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("index doesn't contain the appearance %s", want)
+	}
+}
+
+func TestServeSessionsTags(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	manager, err := sessions.NewSessionManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tags := range [][]string{{"incident-1234", "payments"}, {"incident-1234"}, nil} {
+		id := fmt.Sprintf("20250101-%04d", i)
+		session := &sessions.Session{ID: id, Path: filepath.Join(manager.BasePath, id)}
+		if err := os.MkdirAll(session.Path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := session.SaveMetadata(&sessions.Metadata{Tags: tags}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := &HTMLUserInterface{}
+	w := httptest.NewRecorder()
+	u.serveSessions(w, httptest.NewRequest("GET", "/sessions?tag=incident-1234&tag=payments", nil))
+	var infos []sessionInfo
+	if err := json.NewDecoder(w.Body).Decode(&infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || !slices.Equal(infos[0].Tags, []string{"incident-1234", "payments"}) {
+		t.Errorf("serveSessions() = %+v, want the session tagged incident-1234 and payments", infos)
+	}
+}
+
+func TestTrackStreaming(t *testing.T) {
+	u := &HTMLUserInterface{}
+	started := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		message *api.Message
+		want    string
+	}{
+		{&api.Message{Type: api.MessageTypeProgress, Payload: &api.Progress{Phase: api.ProgressPhaseThinking, Started: started}},
+			"event: progress\ndata: {\"label\":\"thinking\",\"started\":\"2025-01-01T12:00:00Z\"}\n\n"},
+		{&api.Message{Type: api.MessageTypeTextDelta, Payload: "Listing "},
+			"event: delta\ndata: {\"text\":\"Listing \"}\n\n"},
+		{&api.Message{Type: api.MessageTypeTextDelta, Payload: "the pods."},
+			"event: delta\ndata: {\"text\":\"the pods.\"}\n\n"},
+		{&api.Message{Type: api.MessageTypeProgress, Payload: &api.Progress{Phase: api.ProgressPhaseRunningTool, Detail: "kubectl get pods", Started: started}},
+			"event: progress\ndata: {\"label\":\"running kubectl get pods\",\"started\":\"2025-01-01T12:00:00Z\"}\n\n"},
+	} {
+		if got := string(u.trackStreaming(tc.message)); got != tc.want {
+			t.Errorf("trackStreaming(%s) = %q, want %q", tc.message.Type, got, tc.want)
+		}
+	}
+	if u.streaming != "Listing the pods." || u.progress.Label != "running kubectl get pods" {
+		t.Errorf("unexpected streaming state %q, %+v", u.streaming, u.progress)
+	}
+
+	// The other messages are sent with the whole state, once the streaming state is reset.
+	if event := u.trackStreaming(&api.Message{Type: api.MessageTypeToolCallResponse}); event != nil {
+		t.Errorf("unexpected event %q for a tool call response", event)
+	}
+	if u.streaming != "" || u.progress != nil {
+		t.Errorf("streaming state not reset: %q, %+v", u.streaming, u.progress)
+	}
+}
+
+func TestServeMetaCommands(t *testing.T) {
+	u := &HTMLUserInterface{agent: &agent.Agent{Aliases: map[string]string{"restarts": "list the pods restarting"}}}
+	w := httptest.NewRecorder()
+	u.serveMetaCommands(w, httptest.NewRequest("GET", "/meta-commands", nil))
+	var commands []agent.MetaCommand
+	if err := json.NewDecoder(w.Body).Decode(&commands); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]agent.MetaCommand{}
+	for _, command := range commands {
+		names[command.Name] = command
+	}
+	if _, ok := names["exit"]; ok {
+		t.Errorf("serveMetaCommands() lists exit, which only terminals handle")
+	}
+	if names["new-session"].Description == "" || names["restarts"].Query != "list the pods restarting" {
+		t.Errorf("serveMetaCommands() = %+v, want the built-in commands and the aliases", commands)
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package html
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/health"
+	"github.com/golang-jwt/jwt/v5"
+	"k8s.io/klog/v2"
+)
+
+// OIDCConfig configures the login of the users of the web UI with an OpenID
+// Connect identity provider, with the authorization code flow.
+type OIDCConfig struct {
+	// Issuer is the URL of the identity provider, serving its configuration
+	// at /.well-known/openid-configuration.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL registered with the identity provider,
+	// ending with /auth/callback. It is derived from the requests if empty,
+	// which requires proxies to set the Host and X-Forwarded-Proto headers.
+	RedirectURL string
+}
+
+// Paths of the login flow, served without authentication.
+const (
+	loginPath    = "/auth/login"
+	callbackPath = "/auth/callback"
+	logoutPath   = "/auth/logout"
+	userInfoPath = "/auth/userinfo"
+)
+
+const (
+	// sessionCookie holds the ID of the login session of a user.
+	sessionCookie = "kubectl-ai-session"
+	// loginCookie holds the state of a login in progress.
+	loginCookie = "kubectl-ai-login"
+
+	// loginSessionLifetime is how long users stay logged in.
+	loginSessionLifetime = 12 * time.Hour
+	// loginTimeout is how long users have to log in with the identity provider.
+	loginTimeout = 10 * time.Minute
+)
+
+// userContextKey is the context key of the user authenticated with OIDC.
+type userContextKey struct{}
+
+// oidcAuthenticator logs the users of the web UI in with an OpenID Connect
+// identity provider. The login sessions are kept in memory, users log in
+// again when kubectl-ai restarts.
+type oidcAuthenticator struct {
+	config     OIDCConfig
+	httpClient *http.Client
+
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+
+	mu sync.Mutex
+	// keys are the signing keys of the identity provider, by key ID.
+	keys map[string]any
+	// sessions are the users logged in, by session ID.
+	sessions map[string]loginSession
+	// logins are the logins in progress, by state.
+	logins map[string]pendingLogin
+}
+
+type loginSession struct {
+	user    string
+	expires time.Time
+}
+
+type pendingLogin struct {
+	nonce       string
+	redirectURL string
+	expires     time.Time
+}
+
+// newOIDCAuthenticator discovers the endpoints of the identity provider.
+func newOIDCAuthenticator(ctx context.Context, config OIDCConfig) (*oidcAuthenticator, error) {
+	a := &oidcAuthenticator{
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		keys:       map[string]any{},
+		sessions:   map[string]loginSession{},
+		logins:     map[string]pendingLogin{},
+	}
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := a.getJSON(ctx, strings.TrimSuffix(config.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("discovering OIDC issuer %q: %w", config.Issuer, err)
+	}
+	if discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("OIDC issuer %q advertises the issuer %q", config.Issuer, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC issuer %q doesn't advertise its authorization, token and keys endpoints", config.Issuer)
+	}
+	a.authorizationEndpoint = discovery.AuthorizationEndpoint
+	a.tokenEndpoint = discovery.TokenEndpoint
+	a.jwksURI = discovery.JWKSURI
+	return a, nil
+}
+
+func (a *oidcAuthenticator) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// handler requires the users to be logged in to reach next, and serves the
+// login flow.
+func (a *oidcAuthenticator) handler(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+loginPath, a.handleLogin)
+	mux.HandleFunc("GET "+callbackPath, a.handleCallback)
+	mux.HandleFunc("GET "+logoutPath, a.handleLogout)
+	mux.HandleFunc("GET "+userInfoPath, a.requireLogin(http.HandlerFunc(a.serveUserInfo)).ServeHTTP)
+	// The kubelet probes kubectl-ai without logging in.
+	mux.Handle("GET "+health.LivenessPath, next)
+	mux.Handle("GET "+health.ReadinessPath, next)
+	mux.Handle("/", a.requireLogin(next))
+	return mux
+}
+
+// requireLogin adds the logged in user to the context of the requests, and
+// redirects the page to the login, or rejects the other requests, if the
+// user isn't logged in.
+func (a *oidcAuthenticator) requireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, ok := a.user(req); ok {
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), userContextKey{}, user)))
+			return
+		}
+		if req.Method == "GET" && req.URL.Path == "/" {
+			http.Redirect(w, req, loginPath, http.StatusFound)
+			return
+		}
+		http.Error(w, "login required", http.StatusUnauthorized)
+	})
+}
+
+// user returns the user logged in with the session cookie of the request.
+func (a *oidcAuthenticator) user(req *http.Request) (string, bool) {
+	cookie, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	session, ok := a.sessions[cookie.Value]
+	if !ok || time.Now().After(session.expires) {
+		delete(a.sessions, cookie.Value)
+		return "", false
+	}
+	return session.user, true
+}
+
+func (a *oidcAuthenticator) serveUserInfo(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"user": userFromContext(req.Context()), "logout": logoutPath})
+}
+
+// handleLogin redirects the user to the identity provider.
+func (a *oidcAuthenticator) handleLogin(w http.ResponseWriter, req *http.Request) {
+	state, nonce := randomID(), randomID()
+	redirectURL := a.redirectURL(req)
+	a.mu.Lock()
+	now := time.Now()
+	for s, login := range a.logins {
+		if now.After(login.expires) {
+			delete(a.logins, s)
+		}
+	}
+	a.logins[state] = pendingLogin{nonce: nonce, redirectURL: redirectURL, expires: now.Add(loginTimeout)}
+	a.mu.Unlock()
+
+	// The state is also bound to the browser, so that a login started
+	// elsewhere can't be completed in it.
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Value:    state,
+		Path:     "/auth/",
+		MaxAge:   int(loginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(redirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {a.config.ClientID},
+		"redirect_uri":  {redirectURL},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	separator := "?"
+	if strings.Contains(a.authorizationEndpoint, "?") {
+		separator = "&"
+	}
+	http.Redirect(w, req, a.authorizationEndpoint+separator+query.Encode(), http.StatusFound)
+}
+
+// handleCallback completes the login, exchanging the authorization code for
+// an ID token identifying the user.
+func (a *oidcAuthenticator) handleCallback(w http.ResponseWriter, req *http.Request) {
+	log := klog.FromContext(req.Context())
+
+	if e := req.FormValue("error"); e != "" {
+		http.Error(w, fmt.Sprintf("login failed: %s %s", e, req.FormValue("error_description")), http.StatusUnauthorized)
+		return
+	}
+	state := req.FormValue("state")
+	cookie, err := req.Cookie(loginCookie)
+	if state == "" || err != nil || cookie.Value != state {
+		http.Error(w, "login failed: invalid state, log in again", http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	login, ok := a.logins[state]
+	delete(a.logins, state)
+	a.mu.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		http.Error(w, "login failed: the login expired, log in again", http.StatusBadRequest)
+		return
+	}
+
+	user, err := a.exchange(req.Context(), req.FormValue("code"), login)
+	if err != nil {
+		log.Error(err, "OIDC login failed")
+		http.Error(w, "login failed: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	log.Info("user logged in to the web UI", "user", user)
+
+	id := randomID()
+	a.mu.Lock()
+	a.sessions[id] = loginSession{user: user, expires: time.Now().Add(loginSessionLifetime)}
+	a.mu.Unlock()
+	secure := strings.HasPrefix(login.redirectURL, "https://")
+	http.SetCookie(w, &http.Cookie{Name: loginCookie, Path: "/auth/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(loginSessionLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, req, "/", http.StatusFound)
+}
+
+func (a *oidcAuthenticator) handleLogout(w http.ResponseWriter, req *http.Request) {
+	if cookie, err := req.Cookie(sessionCookie); err == nil {
+		a.mu.Lock()
+		delete(a.sessions, cookie.Value)
+		a.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, `<p>You are logged out of kubectl-ai. <a href="%s">Log in again</a></p>`, loginPath)
+}
+
+// redirectURL returns the URL of the callback of the login flow.
+func (a *oidcAuthenticator) redirectURL(req *http.Request) string {
+	if a.config.RedirectURL != "" {
+		return a.config.RedirectURL
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	return scheme + "://" + req.Host + callbackPath
+}
+
+// exchange exchanges the authorization code for the ID token of the user,
+// and returns their identity.
+func (a *oidcAuthenticator) exchange(ctx context.Context, code string, login pendingLogin) (string, error) {
+	if code == "" {
+		return "", errors.New("missing authorization code")
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {login.redirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", a.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("exchanging the authorization code: %w", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("exchanging the authorization code: %s: %w", resp.Status, err)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("exchanging the authorization code: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("the identity provider returned no ID token")
+	}
+	return a.verify(ctx, token.IDToken, login.nonce)
+}
+
+// idTokenClaims are the claims of an ID token identifying the user.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// verify checks the signature and the claims of an ID token, and returns the
+// identity of the user: their email, else their username, else their subject.
+func (a *oidcAuthenticator) verify(ctx context.Context, idToken, nonce string) (string, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return a.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(a.config.Issuer),
+		jwt.WithAudience(a.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return "", fmt.Errorf("invalid ID token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return "", errors.New("invalid ID token: the nonce doesn't match the login")
+	}
+	for _, user := range []string{claims.Email, claims.PreferredUsername, claims.Subject} {
+		if user != "" {
+			return user, nil
+		}
+	}
+	return "", errors.New("the ID token identifies no user")
+}
+
+// key returns the signing key of the identity provider with the key ID,
+// fetching the keys again when it is unknown, after a key rotation.
+func (a *oidcAuthenticator) key(ctx context.Context, kid string) (any, error) {
+	a.mu.Lock()
+	key, ok := a.keys[kid]
+	a.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := a.getJSON(ctx, a.jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching the keys of the identity provider: %w", err)
+	}
+	keys := map[string]any{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			klog.Warningf("ignoring key %q of the identity provider: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	a.mu.Lock()
+	a.keys = keys
+	a.mu.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// jsonWebKey is a public key of a JWKS (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// N and E are the modulus and the exponent of RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// Crv, X and Y are the curve and the coordinates of EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("the point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// userFromContext returns the user authenticated with OIDC, if any.
+func userFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userContextKey{}).(string)
+	return user
+}
+
+// randomID returns a random, unguessable identifier.
+func randomID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
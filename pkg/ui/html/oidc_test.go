@@ -0,0 +1,234 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package html
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeIdentityProvider is an OIDC identity provider issuing ID tokens for
+// alice, with the nonce of the latest authorization request.
+type fakeIdentityProvider struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	nonce string
+}
+
+func newFakeIdentityProvider(t *testing.T) *fakeIdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdentityProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, req *http.Request) {
+		if id, secret, _ := req.BasicAuth(); id != "kubectl-ai" || secret != "s3cr3t" || req.FormValue("code") != "code-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":   idp.URL,
+			"aud":   "kubectl-ai",
+			"sub":   "1234",
+			"email": "alice@example.com",
+			"nonce": idp.nonce,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newFakeIdentityProvider(t)
+	a, err := newOIDCAuthenticator(context.Background(), OIDCConfig{Issuer: idp.URL, ClientID: "kubectl-ai", ClientSecret: "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := a.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(operatorFromRequest(req)))
+	}))
+
+	// Users who aren't logged in are sent to the login.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != loginPath {
+		t.Fatalf("expected a redirection to the login, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/send-message", nil)
+	req.Header.Set("X-Forwarded-Email", "mallory@example.com")
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the message to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "http://kubectl-ai.example.com"+loginPath, nil))
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	if got, want := query.Get("redirect_uri"), "http://kubectl-ai.example.com/auth/callback"; got != want {
+		t.Errorf("redirect_uri = %q, want %q", got, want)
+	}
+	idp.nonce = query.Get("nonce")
+	loginCookies := w.Result().Cookies()
+
+	// A callback with another state is rejected.
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", callbackPath+"?code=code-1&state=forged", nil)
+	for _, cookie := range loginCookies {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the forged state to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", callbackPath+"?code=code-1&state="+url.QueryEscape(query.Get("state")), nil)
+	for _, cookie := range loginCookies {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected the login to succeed, got %d %s", w.Code, w.Body)
+	}
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatal("no session cookie was set")
+	}
+
+	// The identity of the logged in user can't be overridden by headers.
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/send-message", nil)
+	req.Header.Set("X-Forwarded-Email", "mallory@example.com")
+	req.AddCookie(session)
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "alice@example.com" {
+		t.Errorf("expected the message to be sent by alice, got %d %q", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", logoutPath, nil)
+	req.AddCookie(session)
+	handler.ServeHTTP(w, req)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", userInfoPath, nil)
+	req.AddCookie(session)
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the session to end with the logout, got %d", w.Code)
+	}
+}
+
+func TestOIDCVerify(t *testing.T) {
+	idp := newFakeIdentityProvider(t)
+	a, err := newOIDCAuthenticator(context.Background(), OIDCConfig{Issuer: idp.URL, ClientID: "kubectl-ai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(idp.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		want    string
+		wantErr bool
+	}{
+		{name: "username", claims: jwt.MapClaims{"iss": idp.URL, "aud": "kubectl-ai", "sub": "1234", "preferred_username": "bob", "nonce": "n", "exp": exp}, want: "bob"},
+		{name: "subject", claims: jwt.MapClaims{"iss": idp.URL, "aud": "kubectl-ai", "sub": "1234", "nonce": "n", "exp": exp}, want: "1234"},
+		{name: "other audience", claims: jwt.MapClaims{"iss": idp.URL, "aud": "other", "sub": "1234", "nonce": "n", "exp": exp}, wantErr: true},
+		{name: "other issuer", claims: jwt.MapClaims{"iss": "https://evil.example.com", "aud": "kubectl-ai", "sub": "1234", "nonce": "n", "exp": exp}, wantErr: true},
+		{name: "expired", claims: jwt.MapClaims{"iss": idp.URL, "aud": "kubectl-ai", "sub": "1234", "nonce": "n", "exp": time.Now().Add(-time.Hour).Unix()}, wantErr: true},
+		{name: "other nonce", claims: jwt.MapClaims{"iss": idp.URL, "aud": "kubectl-ai", "sub": "1234", "nonce": "replayed", "exp": exp}, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := a.verify(context.Background(), sign(tc.claims), "n")
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("verify() = %q, %v, want %q (error: %v)", got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+
+	// Tokens signed with another key are rejected.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": idp.URL, "aud": "kubectl-ai", "sub": "1234", "nonce": "n", "exp": exp})
+	token.Header["kid"] = "key-1"
+	forged, err := token.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.verify(context.Background(), forged, "n"); err == nil {
+		t.Error("expected a token signed with another key to be rejected")
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package html
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"markdown": renderMarkdown,
+	"time":     func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+}).Parse(reportHTML))
+
+// Report is an incident report of a saved session, rendered as a standalone
+// HTML page that can be attached to postmortems.
+type Report struct {
+	SessionID string
+	Metadata  *sessions.Metadata
+	// Query is the first query of the user.
+	Query string
+	// Summary is the last answer of the model, in markdown.
+	Summary   string
+	Citations []api.Citation
+	Warnings  []string
+	Timeline  []ReportEvent
+	Commands  []ReportCommand
+	Stats     api.StreamStats
+	// Generated is when the report was generated.
+	Generated time.Time
+}
+
+// ReportEvent is an entry of the timeline of the report.
+type ReportEvent struct {
+	Time time.Time
+	// Kind is one of "query", "answer", "command" and "error".
+	Kind string
+	Text string
+	// Author is the operator who sent a query.
+	Author string
+}
+
+// ReportCommand is a tool call run during the session.
+type ReportCommand struct {
+	Time     time.Time
+	Command  string
+	Approval *api.Approval
+	Output   string
+	// Failed is true if the command exited with an error.
+	Failed bool
+	// Diff holds the lines of the output when it is a diff, e.g. of kubectl diff.
+	Diff []DiffLine
+}
+
+// DiffLine is a line of a diff, Kind is "add", "del", "hunk" or "context".
+type DiffLine struct {
+	Kind string
+	Text string
+}
+
+// NewReport builds the report of a session from its messages.
+func NewReport(sessionID string, metadata *sessions.Metadata, messages []*api.Message) *Report {
+	r := &Report{SessionID: sessionID, Metadata: metadata, Generated: time.Now()}
+	for _, message := range messages {
+		switch message.Type {
+		case api.MessageTypeText:
+			text, _ := message.Payload.(string)
+			switch message.Source {
+			case api.MessageSourceUser:
+				if r.Query == "" {
+					r.Query = text
+				}
+				r.Timeline = append(r.Timeline, ReportEvent{Time: message.Timestamp, Kind: "query", Text: text, Author: message.Author})
+			case api.MessageSourceModel:
+				r.Summary = text
+				r.Citations = message.Citations
+				r.Warnings = message.Warnings
+				r.Timeline = append(r.Timeline, ReportEvent{Time: message.Timestamp, Kind: "answer", Text: firstLine(text)})
+			}
+			if message.Stats != nil {
+				r.Stats.Elapsed += message.Stats.Elapsed
+				r.Stats.InputTokens += message.Stats.InputTokens
+				r.Stats.OutputTokens += message.Stats.OutputTokens
+				r.Stats.Cost += message.Stats.Cost
+				r.Stats.TokensEstimated = r.Stats.TokensEstimated || message.Stats.TokensEstimated
+			}
+		case api.MessageTypeError:
+			text, _ := message.Payload.(string)
+			r.Timeline = append(r.Timeline, ReportEvent{Time: message.Timestamp, Kind: "error", Text: text})
+		case api.MessageTypeToolCallRequest:
+			command, _ := message.Payload.(string)
+			r.Commands = append(r.Commands, ReportCommand{Time: message.Timestamp, Command: command, Approval: message.Approval})
+			r.Timeline = append(r.Timeline, ReportEvent{Time: message.Timestamp, Kind: "command", Text: command})
+		case api.MessageTypeToolCallResponse:
+			if len(r.Commands) == 0 {
+				continue
+			}
+			command := &r.Commands[len(r.Commands)-1]
+			command.Output, command.Failed = toolOutput(message.Payload)
+			command.Diff = parseDiff(command.Output)
+		}
+	}
+	return r
+}
+
+// Write renders the report as HTML.
+func (r *Report) Write(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+// toolOutput returns the text of the result of a tool call, and whether the
+// call failed. Results loaded from a session are decoded from JSON, so
+// ExecResults are maps.
+func toolOutput(payload any) (string, bool) {
+	switch p := payload.(type) {
+	case string:
+		return p, false
+	case map[string]any:
+		stdout, _ := p["stdout"].(string)
+		stderr, _ := p["stderr"].(string)
+		errorText, _ := p["error"].(string)
+		exitCode, _ := p["exit_code"].(float64)
+		if stdout != "" || stderr != "" || errorText != "" {
+			output := stdout
+			for _, s := range []string{stderr, errorText} {
+				if s != "" {
+					output = strings.TrimRight(output, "\n") + "\n" + s
+				}
+			}
+			return strings.Trim(output, "\n"), exitCode != 0 || errorText != ""
+		}
+	}
+	b, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return fmt.Sprint(payload), false
+	}
+	return string(b), false
+}
+
+// parseDiff splits a unified diff into lines, it returns nil if the output
+// is not a diff.
+func parseDiff(output string) []DiffLine {
+	if !strings.Contains(output, "\n@@ ") || !(strings.HasPrefix(output, "diff ") || strings.HasPrefix(output, "--- ")) {
+		return nil
+	}
+	var lines []DiffLine
+	for _, line := range strings.Split(output, "\n") {
+		kind := "context"
+		switch {
+		case strings.HasPrefix(line, "@@"), strings.HasPrefix(line, "diff "),
+			strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			kind = "hunk"
+		case strings.HasPrefix(line, "+"):
+			kind = "add"
+		case strings.HasPrefix(line, "-"):
+			kind = "del"
+		}
+		lines = append(lines, DiffLine{Kind: kind, Text: line})
+	}
+	return lines
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// renderMarkdown renders markdown as HTML. Raw HTML in the markdown is
+// omitted, so that model output can't inject markup into the report.
+func renderMarkdown(text string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(text), &buf); err != nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+	return template.HTML(buf.String())
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package html
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestReport(t *testing.T) {
+	start := time.Date(2025, 8, 7, 10, 0, 0, 0, time.UTC)
+	diff := "diff -u -N /tmp/LIVE/apps.v1.Deployment.default.web /tmp/MERGED/apps.v1.Deployment.default.web\n--- /tmp/LIVE\n+++ /tmp/MERGED\n@@ -6 +6 @@\n-  replicas: 2\n+  replicas: 3"
+	messages := []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "scale web to 3", Timestamp: start, Author: "bob"},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl diff -f web.yaml", Timestamp: start.Add(time.Second)},
+		// Results loaded from a session are decoded from JSON.
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: map[string]any{"stdout": diff, "exit_code": float64(1)}},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl apply -f web.yaml", Timestamp: start.Add(2 * time.Second),
+			Approval: &api.Approval{Approver: "alice", Method: api.ApprovalMethodConfirmed}},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: map[string]any{"stdout": "deployment.apps/web configured\n"}},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeText, Payload: "Scaled **web** to 3 replicas.\n<script>alert(1)</script>", Timestamp: start.Add(3 * time.Second),
+			Stats: &api.StreamStats{Elapsed: 2 * time.Second, InputTokens: 1000, OutputTokens: 200, Cost: 0.0042}},
+	}
+	report := NewReport("20250807-510872", &sessions.Metadata{ProviderID: "gemini", ModelID: "gemini-2.5-pro", CreatedAt: start}, messages)
+
+	if report.Query != "scale web to 3" {
+		t.Errorf("Query = %q, want the first query", report.Query)
+	}
+	wantKinds := []string{"query", "command", "command", "answer"}
+	var kinds []string
+	for _, event := range report.Timeline {
+		kinds = append(kinds, event.Kind)
+	}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Errorf("timeline kinds = %v, want %v", kinds, wantKinds)
+	}
+	if len(report.Commands) != 2 {
+		t.Fatalf("got %d commands, want 2", len(report.Commands))
+	}
+	// kubectl diff exits with 1 when there are differences.
+	if got := report.Commands[0]; len(got.Diff) != 6 || got.Diff[4] != (DiffLine{Kind: "del", Text: "-  replicas: 2"}) || !got.Failed {
+		t.Errorf("diff command = %+v", got)
+	}
+	if got := report.Commands[1]; got.Diff != nil || got.Output != "deployment.apps/web configured" || got.Failed {
+		t.Errorf("apply command = %+v", got)
+	}
+
+	var b strings.Builder
+	if err := report.Write(&b); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	html := b.String()
+	for _, want := range []string{"<strong>bob:</strong> scale web to 3", "<strong>web</strong>", "approved by alice (confirmed)", "$0.0042", `<span class="add">&#43;  replicas: 3</span>`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>alert(1)</script>") {
+		t.Errorf("report contains raw HTML of the answer")
+	}
+}
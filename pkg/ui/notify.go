@@ -0,0 +1,90 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// notifier alerts the user of the TUI that the agent waits for them, while the
+// terminal is not focused.
+type notifier struct {
+	// terminal is the output of the TUI, the bell and the notifications
+	// escape sequences are written to.
+	terminal io.Writer
+	// desktop shows a desktop notification, if the platform supports it.
+	desktop func(title, body string) error
+}
+
+func newNotifier(terminal io.Writer) *notifier {
+	return &notifier{terminal: terminal, desktop: desktopNotification}
+}
+
+// notify rings the bell of the terminal, and shows a notification with the
+// OSC 9 (iTerm2, Windows Terminal, WezTerm, kitty) and OSC 777 (foot, Ghostty,
+// rxvt) escape sequences, ignored by the terminals that don't support them,
+// and on the desktop.
+func (n *notifier) notify(title, body string) {
+	title, body = notificationText(title), notificationText(body)
+	fmt.Fprintf(n.terminal, "\a\x1b]9;%s: %s\x07\x1b]777;notify;%s;%s\x07", title, body, title, body)
+	if n.desktop != nil {
+		if err := n.desktop(title, body); err != nil {
+			klog.V(1).Infof("error showing a desktop notification: %v", err)
+		}
+	}
+}
+
+// notificationText removes what would end the escape sequences from a text
+// shown in a notification: the control characters, and the separators of OSC 777.
+func notificationText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == ';' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// desktopNotification shows a notification with notify-send on Linux and the
+// BSDs, and with osascript on macOS. The command isn't waited for.
+func desktopNotification(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf(`display notification "%s" with title "%s"`, quote(body), quote(title)))
+	case "windows":
+		// The terminal sequences are the only notifications on Windows.
+		return nil
+	default:
+		path, err := exec.LookPath("notify-send")
+		if err != nil {
+			// e.g. on servers without a desktop.
+			return nil
+		}
+		cmd = exec.Command(path, "--app-name=kubectl-ai", title, body)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
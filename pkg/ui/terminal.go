@@ -25,6 +25,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
@@ -79,6 +81,35 @@ type TerminalUI struct {
 	useTTYForInput bool
 	// showToolOutput disables truncation of tool output.
 	showToolOutput bool
+	// statusMu guards the status line on stderr, showing the progress of
+	// the agent or the meter of the streamed response.
+	statusMu sync.Mutex
+	// statusShown is true while the status line is shown.
+	statusShown bool
+	// progress is the phase the agent is waiting on, animated on the status
+	// line until the next message.
+	progress *api.Progress
+	// meter is the latest stats of the streamed response.
+	meter *api.StreamStats
+
+	// out and errOut are where the UI writes, the standard output and error,
+	// along with the recording of the session if any.
+	out    io.Writer
+	errOut io.Writer
+	// recording receives the input read from the TTY, which the terminal
+	// echoes without going through out.
+	recording io.Writer
+
+	// streamText prints the text deltas of the model as they are received,
+	// instead of rendering the markdown of the complete text.
+	streamText bool
+	// streamed is the text printed so far for the response being streamed.
+	streamed string
+
+	// appearance holds the theme and the accessibility options, and palette
+	// the escape codes of its colors.
+	appearance Appearance
+	palette    map[colorValue]string
 
 	agent *agent.Agent
 }
@@ -111,16 +142,43 @@ func getCustomTerminalWidth() int {
 }
 
 func NewTerminalUI(agent *agent.Agent, useTTYForInput bool, showToolOutput bool, journal journal.Recorder) (*TerminalUI, error) {
-	width := getCustomTerminalWidth()
+	mdRenderer, err := newMarkdownRenderer(Appearance{})
+	if err != nil {
+		return nil, err
+	}
+
+	u := &TerminalUI{
+		markdownRenderer: mdRenderer,
+		journal:          journal,
+		useTTYForInput:   useTTYForInput, // Store this flag
+		agent:            agent,
+		showToolOutput:   showToolOutput,
+		out:              os.Stdout,
+		errOut:           os.Stderr,
+		palette:          (&Appearance{}).terminalPalette(),
+	}
+
+	return u, nil
+}
 
+// newMarkdownRenderer returns the renderer of the markdown of the model, in
+// the style of the appearance.
+func newMarkdownRenderer(appearance Appearance) (*glamour.TermRenderer, error) {
 	options := []glamour.TermRendererOption{
-		glamour.WithAutoStyle(),
 		glamour.WithPreservedNewLines(),
-		glamour.WithEmoji(),
+	}
+	if style := appearance.markdownStyle(); style != "" {
+		options = append(options, glamour.WithStandardStyle(style))
+	} else {
+		options = append(options, glamour.WithAutoStyle())
+	}
+	// Screen readers spell out emojis.
+	if !appearance.ScreenReader {
+		options = append(options, glamour.WithEmoji())
 	}
 
 	// Only add WordWrap if a valid width is configured
-	if width > 0 {
+	if width := getCustomTerminalWidth(); width > 0 {
 		options = append(options, glamour.WithWordWrap(width))
 	}
 
@@ -128,22 +186,45 @@ func NewTerminalUI(agent *agent.Agent, useTTYForInput bool, showToolOutput bool,
 	if err != nil {
 		return nil, fmt.Errorf("error initializing the markdown renderer: %w", err)
 	}
+	return mdRenderer, nil
+}
 
-	u := &TerminalUI{
-		markdownRenderer: mdRenderer,
-		journal:          journal,
-		useTTYForInput:   useTTYForInput, // Store this flag
-		agent:            agent,
-		showToolOutput:   showToolOutput,
+// SetAppearance applies the theme and the accessibility options of the
+// terminal. It must be called before Run.
+func (u *TerminalUI) SetAppearance(appearance Appearance) error {
+	mdRenderer, err := newMarkdownRenderer(appearance)
+	if err != nil {
+		return err
 	}
+	u.markdownRenderer = mdRenderer
+	u.appearance = appearance
+	u.palette = appearance.terminalPalette()
+	return nil
+}
 
-	return u, nil
+// RecordTo records what the terminal shows to w, e.g. the output of a
+// journal.CastRecorder. It must be called before Run.
+func (u *TerminalUI) RecordTo(w io.Writer) {
+	u.out = io.MultiWriter(os.Stdout, w)
+	u.errOut = io.MultiWriter(os.Stderr, w)
+	u.recording = w
+}
+
+// StreamText prints the text of the model as it is generated, without
+// rendering its markdown, so that the output of scripts shows the progress of
+// long tasks. It must be called before Run.
+func (u *TerminalUI) StreamText() {
+	u.streamText = true
 }
 
 func (u *TerminalUI) Run(ctx context.Context) error {
 	// Channel to signal when the agent has exited
 	agentExited := make(chan struct{})
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go u.renderProgress(ctx)
+
 	// Start a goroutine to handle agent output
 	go func() {
 		for {
@@ -199,10 +280,11 @@ func (u *TerminalUI) readlineInstance() (*readline.Instance, error) {
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:      ">>> ", // Default prompt for main input
 		Stdin:       os.Stdin,
-		Stdout:      os.Stdout,
-		Stderr:      os.Stderr,
+		Stdout:      u.out,
+		Stderr:      u.errOut,
 		HistoryFile: historyPath,
 		// History enabled by default
+		AutoComplete: u.metaCommandCompleter(),
 	})
 	if err != nil {
 		// Log warning or fallback if readline init fails?
@@ -215,6 +297,38 @@ func (u *TerminalUI) readlineInstance() (*readline.Instance, error) {
 	return u.rlInstance, nil
 }
 
+// metaCommandCompleter completes the meta commands of the agent with Tab,
+// e.g. "tag a" to "tag add ".
+func (u *TerminalUI) metaCommandCompleter() readline.AutoCompleter {
+	root := readline.NewPrefixCompleter()
+	if u.agent == nil {
+		return root
+	}
+	for _, command := range u.agent.MetaCommands() {
+		for _, name := range append([]string{command.Name}, command.Aliases...) {
+			addCompletion(root, strings.Fields(name))
+		}
+	}
+	return root
+}
+
+// addCompletion adds the words of a command under parent, sharing the
+// completions of the commands starting with the same words.
+func addCompletion(parent readline.PrefixCompleterInterface, words []string) {
+	if len(words) == 0 {
+		return
+	}
+	for _, child := range parent.GetChildren() {
+		if strings.TrimSpace(string(child.GetName())) == words[0] {
+			addCompletion(child, words[1:])
+			return
+		}
+	}
+	child := readline.PcItem(words[0])
+	parent.SetChildren(append(parent.GetChildren(), child))
+	addCompletion(child, words[1:])
+}
+
 func (u *TerminalUI) Close() error {
 	var errs []error
 
@@ -233,6 +347,44 @@ func (u *TerminalUI) Close() error {
 }
 
 func (u *TerminalUI) handleMessage(msg *api.Message) {
+	// The progress and the meter are a status line on stderr, rewritten while
+	// the agent waits on the model or a tool, and cleared before the next
+	// message is printed.
+	u.statusMu.Lock()
+	switch msg.Type {
+	case api.MessageTypeProgress:
+		u.progress, u.meter = nil, nil
+		// The terminal prompts for approvals, the prompt is the progress.
+		if progress := msg.Payload.(*api.Progress); progress.Phase != api.ProgressPhaseWaitingForApproval {
+			u.progress = progress
+		}
+		u.statusMu.Unlock()
+		return
+	case api.MessageTypeStreamStats:
+		// The meter is shown along with the spinner while the model is thinking.
+		u.meter = msg.Payload.(*api.StreamStats)
+		// The status line would be mixed up with the text being streamed.
+		if u.progress == nil && u.streamed == "" && !u.appearance.ScreenReader && term.IsTerminal(int(os.Stderr.Fd())) {
+			u.showStatus(u.meter.String())
+		}
+		u.statusMu.Unlock()
+		return
+	}
+	u.progress, u.meter = nil, nil
+	if u.statusShown {
+		fmt.Fprint(u.errOut, "\r\033[K")
+		u.statusShown = false
+	}
+	u.statusMu.Unlock()
+
+	// The streamed text ends before anything else is printed, e.g. when the
+	// stream failed.
+	isModelText := msg.Source == api.MessageSourceModel && msg.Type == api.MessageTypeText
+	if u.streamed != "" && msg.Type != api.MessageTypeTextDelta && !isModelText {
+		fmt.Fprintln(u.out)
+		u.streamed = ""
+	}
+
 	text := ""
 	var styleOptions []styleOption
 
@@ -247,14 +399,44 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 		case api.MessageSourceAgent:
 			styleOptions = append(styleOptions, renderMarkdown(), foreground(colorGreen))
 		case api.MessageSourceModel:
+			if u.streamed != "" {
+				// Only the end of the text, which wasn't streamed yet, is left
+				// to print. The text may differ from the streamed one when
+				// snippets were labeled, the labels are then left out.
+				rest, ok := strings.CutPrefix(text, u.streamed)
+				if !ok {
+					rest = ""
+				}
+				text = rest + formatCitations(msg.Citations) + formatWarnings(msg.Warnings, u.appearance.ScreenReader) + "\n"
+				u.streamed = ""
+				break
+			}
 			styleOptions = append(styleOptions, renderMarkdown())
+			text += formatCitations(msg.Citations) + formatWarnings(msg.Warnings, u.appearance.ScreenReader)
+		}
+	case api.MessageTypeTextDelta:
+		// The terminal renders markdown, which needs the complete text, unless
+		// the text is streamed.
+		if !u.streamText {
+			return
 		}
+		delta := msg.Payload.(string)
+		fmt.Fprint(u.out, delta)
+		u.streamed += delta
+		return
 	case api.MessageTypeError:
 		styleOptions = append(styleOptions, foreground(colorRed))
 		text = msg.Payload.(string)
 	case api.MessageTypeToolCallRequest:
 		styleOptions = append(styleOptions, foreground(colorGreen))
 		text = fmt.Sprintf("\n  Running: %s\n", msg.Payload.(string))
+	case api.MessageTypeChart:
+		chart, ok := msg.Payload.(*api.Chart)
+		if !ok {
+			return
+		}
+		styleOptions = append(styleOptions, foreground(colorGreen))
+		text = formatChart(chart, u.appearance.ScreenReader)
 	case api.MessageTypeToolCallResponse:
 		if !u.showToolOutput {
 			return
@@ -285,7 +467,7 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 			// keep reading input until we get a non-empty query
 			for {
 				var err error
-				fmt.Print("\n>>> ") // Print prompt manually
+				fmt.Fprint(u.out, "\n>>> ") // Print prompt manually
 				query, err = tReader.ReadString('\n')
 				if err != nil {
 					klog.Infof("TTY read error: %v", err)
@@ -303,6 +485,7 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 				}
 				break
 			}
+			u.recordInput(query)
 			klog.Infof("Sending TTY input to agent: %q", query)
 			u.agent.Input <- &api.UserInputResponse{Query: query}
 		} else {
@@ -343,12 +526,12 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 	case api.MessageTypeUserChoiceRequest:
 		choiceRequest := msg.Payload.(*api.UserChoiceRequest)
 		prompt, _ := u.markdownRenderer.Render(choiceRequest.Prompt)
-		fmt.Printf("\n%s\n", string(prompt))
+		fmt.Fprintf(u.out, "\n%s\n", string(prompt))
 
 		for i, option := range choiceRequest.Options {
-			fmt.Printf("  %d. %s\n", i+1, option.Label)
+			fmt.Fprintf(u.out, "  %d. %s\n", i+1, option.Label)
 		}
-		fmt.Println()
+		fmt.Fprintln(u.out)
 
 		var choice int
 		for {
@@ -360,7 +543,7 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 					klog.Errorf("Failed to get TTY reader: %v", err)
 					return
 				}
-				fmt.Print("Enter your choice: ")
+				fmt.Fprint(u.out, "Enter your choice: ")
 				line, err = tReader.ReadString('\n')
 				if err != nil {
 					klog.Infof("TTY read error: %v", err)
@@ -373,6 +556,7 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 					u.agent.Input <- fmt.Errorf("error reading from TTY: %w", err)
 					return
 				}
+				u.recordInput(line)
 			} else {
 				rlInstance, err := u.readlineInstance()
 				if err != nil {
@@ -412,9 +596,21 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 				break
 			}
 
-			fmt.Println("Invalid choice. Please try again.")
+			fmt.Fprintln(u.out, "Invalid choice. Please try again.")
+		}
+		var reason string
+		if choiceRequest.RequireReason && choiceRequest.Options[choice-1].Value != "no" {
+			for reason == "" {
+				line, err := u.readLine("Reason for breaking the change freeze: ")
+				if err != nil {
+					klog.Infof("Reason read error: %v", err)
+					u.agent.Input <- io.EOF
+					return
+				}
+				reason = strings.TrimSpace(line)
+			}
 		}
-		u.agent.Input <- &api.UserChoiceResponse{Choice: choice}
+		u.agent.Input <- &api.UserChoiceResponse{Choice: choice, Reason: reason}
 		return
 	default:
 		klog.Warningf("unsupported message type: %v", msg.Type)
@@ -437,27 +633,121 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 		}
 	}
 	reset := ""
-	switch computedStyle.Foreground {
-	case colorRed:
-		fmt.Printf("\033[31m")
-		reset += "\033[0m"
-	case colorGreen:
-		fmt.Printf("\033[32m")
-		reset += "\033[0m"
-	case colorWhite:
-		fmt.Printf("\033[37m")
-		reset += "\033[0m"
-
-	case "":
-	default:
-		klog.Info("foreground color not supported by TerminalUI", "color", computedStyle.Foreground)
+	if computedStyle.Foreground != "" {
+		code, ok := u.palette[computedStyle.Foreground]
+		if !ok && !u.appearance.ScreenReader {
+			klog.Info("foreground color not supported by TerminalUI", "color", computedStyle.Foreground)
+		}
+		if code != "" {
+			fmt.Fprint(u.out, code)
+			reset += "\033[0m"
+		}
+	}
+
+	fmt.Fprintf(u.out, "%s%s", printText, reset)
+}
+
+// readLine prompts for a line of input, from the TTY or readline.
+func (u *TerminalUI) readLine(prompt string) (string, error) {
+	if u.useTTYForInput {
+		tReader, err := u.ttyReader()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprint(u.out, prompt)
+		line, err := tReader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		u.recordInput(line)
+		return line, nil
+	}
+	rlInstance, err := u.readlineInstance()
+	if err != nil {
+		return "", err
 	}
+	rlInstance.SetPrompt(prompt)
+	return rlInstance.Readline()
+}
 
-	fmt.Printf("%s%s", printText, reset)
+// recordInput adds a line read from the TTY to the recording, as the terminal
+// showed it.
+func (u *TerminalUI) recordInput(line string) {
+	if u.recording != nil {
+		fmt.Fprint(u.recording, line)
+	}
+}
+
+// spinnerFrames animate the progress on the status line.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// renderProgress animates the progress of the agent on the status line,
+// with the elapsed time, until ctx is done.
+func (u *TerminalUI) renderProgress(ctx context.Context) {
+	// Screen readers would read the status line again on every frame.
+	if u.appearance.ScreenReader || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for frame := 0; ; frame++ {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			u.statusMu.Lock()
+			if u.progress != nil {
+				status := u.progress.Status(now)
+				if u.meter != nil {
+					status = string(u.progress.Phase) + " · " + u.meter.String()
+				}
+				u.showStatus(spinnerFrames[frame%len(spinnerFrames)] + " " + status)
+			}
+			u.statusMu.Unlock()
+		}
+	}
+}
+
+// showStatus rewrites the status line, statusMu must be held.
+func (u *TerminalUI) showStatus(status string) {
+	fmt.Fprintf(u.errOut, "\r\033[K\033[2m%s\033[0m", status)
+	u.statusShown = true
+}
+
+// formatWarnings formats the warnings of an answer as markdown, to be appended
+// to it. plain formats them without emoji, for screen readers.
+func formatWarnings(warnings []string, plain bool) string {
+	prefix := "⚠️ "
+	if plain {
+		prefix = "Warning: "
+	}
+	var b strings.Builder
+	for _, warning := range warnings {
+		b.WriteString("\n\n> " + prefix + warning)
+	}
+	return b.String()
+}
+
+// formatCitations formats the web pages cited by an answer as a markdown list
+// of sources, to be appended to it.
+func formatCitations(citations []api.Citation) string {
+	if len(citations) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nSources:\n")
+	for i, citation := range citations {
+		title := citation.Title
+		if title == "" {
+			title = citation.URI
+		}
+		fmt.Fprintf(&b, "\n%d. [%s](%s)", i+1, title, citation.URI)
+	}
+	return b.String()
 }
 
 func (u *TerminalUI) ClearScreen() {
-	fmt.Print("\033[H\033[2J")
+	fmt.Fprint(u.out, "\033[H\033[2J")
 }
 
 func formatToolCallResponse(payload map[string]any) string {
@@ -49,6 +49,7 @@ var (
 	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("170"))
 	paginationStyle   = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
 	quitTextStyle     = lipgloss.NewStyle().Margin(1, 0, 2, 4)
+	meterStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
 )
 
 type item string
@@ -95,33 +96,68 @@ func getCurrentUsername() string {
 
 // TUI is a rich terminal user interface for the agent.
 type TUI struct {
-	program *tea.Program
-	agent   *agent.Agent
+	agent *agent.Agent
+	// newTab starts the agents of the tabs opened by the user, tabs are disabled if nil.
+	newTab NewTabFunc
+	// notifier alerts the user of the approvals required while the terminal
+	// is not focused, notifications are disabled if nil.
+	notifier *notifier
 }
 
 func NewTUI(agent *agent.Agent) *TUI {
 	return &TUI{
-		program: tea.NewProgram(newModel(agent), tea.WithAltScreen()),
-		agent:   agent,
+		agent: agent,
 	}
 }
 
+// EnableTabs lets the user open tabs running other agents, whose sessions
+// are independent, e.g. to investigate another cluster. newTab starts the
+// agent of each tab.
+func (u *TUI) EnableTabs(newTab NewTabFunc) {
+	u.newTab = newTab
+}
+
+// EnableNotifications rings the bell of the terminal, and shows a terminal and
+// a desktop notification, when an agent waits for an approval while the
+// terminal is not focused. Terminals that don't report their focus are
+// considered focused.
+func (u *TUI) EnableNotifications() {
+	u.notifier = newNotifier(os.Stdout)
+}
+
 func (u *TUI) Run(ctx context.Context) error {
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case msg, ok := <-u.agent.Output:
-				if !ok {
+	var program *tea.Program
+	forward := func(id int, agent *agent.Agent) context.CancelFunc {
+		ctx, cancel := context.WithCancel(ctx)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
 					return
+				case msg, ok := <-agent.Output:
+					if !ok {
+						program.Send(tabExitedMsg{id: id})
+						return
+					}
+					program.Send(tabMsg{id: id, msg: msg})
 				}
-				u.program.Send(msg)
 			}
-		}
-	}()
+		}()
+		return cancel
+	}
+	m := newTabsModel(ctx, u.agent, u.newTab, forward)
+	programOptions := []tea.ProgramOption{tea.WithAltScreen()}
+	if u.notifier != nil {
+		m.notifier = u.notifier
+		programOptions = append(programOptions, tea.WithReportFocus())
+	}
+	program = tea.NewProgram(m, programOptions...)
+	m.tabs[0].cancel = forward(m.tabs[0].id, u.agent)
 
-	_, err := u.program.Run()
+	final, err := program.Run()
+	if m, ok := final.(tabsModel); ok {
+		m.closeAll()
+	}
 	return err
 }
 
@@ -157,6 +193,17 @@ type model struct {
 	messages []*api.Message
 	quitting bool
 
+	// streaming is the model text received so far for the response
+	// that is still being generated.
+	streaming string
+	// meter shows the stats of the response being generated, in place of the gap.
+	meter string
+	// progress is the phase the agent is waiting on, shown with the spinner
+	// in place of the gap until the next message.
+	progress *api.Progress
+	// spinning is true while the spinner ticks.
+	spinning bool
+
 	list     list.Model
 	choice   string
 	username string // cached username
@@ -205,6 +252,7 @@ Type a message and press Enter to send.`)
 		textarea: ta,
 		viewport: vp,
 		list:     l,
+		spinner:  spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(spinnerStyle)),
 		// a lipgloss style for the sender
 		senderStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
 		username:    getCurrentUsername(),
@@ -222,6 +270,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		tiCmd   tea.Cmd
 		vpCmd   tea.Cmd
 		listCmd tea.Cmd
+		spCmd   tea.Cmd
 	)
 
 	m.textarea, tiCmd = m.textarea.Update(msg)
@@ -253,9 +302,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.agent.Session().AgentState == api.AgentStateWaitingForInput {
 				i, ok := m.list.SelectedItem().(item)
 				if ok {
-					m.choice = string(i)
 					choiceIndex := m.list.Index()
-					m.agent.Input <- &api.UserChoiceResponse{Choice: choiceIndex + 1}
+					// Approving during a change freeze needs the reason typed in the text area.
+					var reason string
+					if choiceRequest := m.choiceRequest(); choiceRequest != nil && choiceRequest.RequireReason && choiceRequest.Options[choiceIndex].Value != "no" {
+						reason = strings.TrimSpace(m.textarea.Value())
+						if reason == "" {
+							return m, nil
+						}
+						m.textarea.Reset()
+					}
+					m.choice = string(i)
+					m.agent.Input <- &api.UserChoiceResponse{Choice: choiceIndex + 1, Reason: reason}
 				}
 				return m, nil
 			}
@@ -270,7 +328,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.textarea.Reset()
 			m.viewport.GotoBottom()
 		}
+	case spinner.TickMsg:
+		// The spinner stops once the agent is no longer waiting.
+		if m.progress == nil {
+			m.spinning = false
+			break
+		}
+		m.spinner, spCmd = m.spinner.Update(msg)
 	case *api.Message:
+		switch msg.Type {
+		case api.MessageTypeTextDelta:
+			m.streaming += msg.Payload.(string)
+		case api.MessageTypeStreamStats:
+			m.meter = msg.Payload.(*api.StreamStats).String()
+		case api.MessageTypeProgress:
+			m.progress = msg.Payload.(*api.Progress)
+			m.meter = ""
+			if !m.spinning {
+				m.spinning = true
+				spCmd = m.spinner.Tick
+			}
+		default:
+			m.streaming = ""
+			m.meter = ""
+			m.progress = nil
+		}
 		m.messages = m.agent.Session().AllMessages()
 		m.viewport.SetContent(strings.Join(m.renderedMessages(), "\n"))
 		m.viewport.GotoBottom()
@@ -281,7 +363,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
-	return m, tea.Batch(tiCmd, vpCmd, listCmd)
+	return m, tea.Batch(tiCmd, vpCmd, listCmd, spCmd)
 
 }
 
@@ -295,6 +377,13 @@ func (m model) renderedMessages() []string {
 		}
 		messages = append(messages, m.renderMessage(message))
 	}
+	if m.streaming != "" {
+		messages = append(messages, m.renderMessage(&api.Message{
+			Source:  api.MessageSourceModel,
+			Type:    api.MessageTypeText,
+			Payload: m.streaming,
+		}))
+	}
 	return messages
 }
 
@@ -302,26 +391,34 @@ func (m model) View() string {
 	if m.quitting {
 		return quitTextStyle.Render("Not safe to quit yet.")
 	}
+	separator := gap
+	switch {
+	case m.progress != nil:
+		status := m.progress.Status(time.Now())
+		if m.meter != "" {
+			status = string(m.progress.Phase) + " · " + m.meter
+		}
+		separator = "\n" + m.spinner.View() + " " + meterStyle.Render(status) + "\n"
+	case m.meter != "":
+		separator = "\n" + meterStyle.Render(m.meter) + "\n"
+	}
 	mainView := fmt.Sprintf(
 		"%s%s",
 		m.viewport.View(),
-		gap,
+		separator,
 	)
 	if m.agent.Session().AgentState == api.AgentStateWaitingForInput {
-		var choiceRequest *api.UserChoiceRequest
-		if len(m.messages) > 0 {
-			if lastMsg := m.messages[len(m.messages)-1]; lastMsg.Type == api.MessageTypeUserChoiceRequest {
-				choiceRequest = lastMsg.Payload.(*api.UserChoiceRequest)
-			}
-		}
-
-		if choiceRequest != nil {
+		if choiceRequest := m.choiceRequest(); choiceRequest != nil {
 			items := make([]list.Item, len(choiceRequest.Options))
 			for i, option := range choiceRequest.Options {
 				items[i] = item(option.Label)
 			}
 			m.list.SetItems(items)
 			m.list.Title = "Select an option:"
+			if choiceRequest.RequireReason {
+				m.list.Title = "Type the reason for breaking the change freeze, then select an option:"
+				mainView += m.textarea.View() + "\n"
+			}
 			mainView += listStyle.Render(m.list.View())
 		} else {
 			mainView += m.textarea.View()
@@ -332,11 +429,25 @@ func (m model) View() string {
 	return mainView
 }
 
+// choiceRequest returns the choice the user is asked to make, if any.
+func (m model) choiceRequest() *api.UserChoiceRequest {
+	if len(m.messages) == 0 {
+		return nil
+	}
+	if lastMsg := m.messages[len(m.messages)-1]; lastMsg.Type == api.MessageTypeUserChoiceRequest {
+		return lastMsg.Payload.(*api.UserChoiceRequest)
+	}
+	return nil
+}
+
 func (m model) renderMessage(message *api.Message) string {
 	sourceDisplayName := ""
 	switch message.Source {
 	case api.MessageSourceUser:
 		sourceDisplayName = m.username
+		if message.Author != "" {
+			sourceDisplayName = message.Author
+		}
 	case api.MessageSourceModel, api.MessageSourceAgent:
 		sourceDisplayName = "AI"
 	}
@@ -357,9 +468,11 @@ func (m model) renderMessage(message *api.Message) string {
 
 	switch p := message.Payload.(type) {
 	case string:
-		contentToRender = p
+		contentToRender = p + formatCitations(message.Citations) + formatWarnings(message.Warnings, false)
 	case *api.UserChoiceRequest:
 		contentToRender = p.Prompt
+	case *api.Chart:
+		return text + formatChart(p, false)
 	default:
 		return "" // Don't render unknown payload types
 	}
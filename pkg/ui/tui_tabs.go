@@ -0,0 +1,373 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// NewTabFunc starts the agent of a new tab of the TUI, in the given
+// kubeconfig context, or in the context of the first tab if empty. The
+// returned function closes the agent.
+type NewTabFunc func(ctx context.Context, kubeContext string) (*agent.Agent, func(), error)
+
+// The key bindings of the tabs don't clash with the ones of the text area.
+const (
+	keyNewTab   = "ctrl+o"
+	keyCloseTab = "ctrl+x"
+	keyNextTab  = "ctrl+right"
+	keyPrevTab  = "ctrl+left"
+)
+
+var (
+	tabStyle         = lipgloss.NewStyle().Padding(0, 1).Foreground(lipgloss.Color("241"))
+	activeTabStyle   = lipgloss.NewStyle().Padding(0, 1).Bold(true).Foreground(lipgloss.Color("255")).Background(lipgloss.Color("63"))
+	runningStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	needsApproval    = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	tabStatusStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	tabShortcutsHelp = fmt.Sprintf("%s new tab · %s/%s switch · %s close", keyNewTab, keyPrevTab, keyNextTab, keyCloseTab)
+)
+
+// tab is an agent session shown in a tab of the TUI.
+type tab struct {
+	id    int
+	title string
+	agent *agent.Agent
+	model model
+	// cancel stops forwarding the output of the agent to the tab.
+	cancel context.CancelFunc
+	// release closes the agent. It is nil for the first tab, whose agent is
+	// closed by the caller of the TUI.
+	release func()
+}
+
+func (t *tab) close() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	if t.release != nil {
+		t.release()
+	}
+}
+
+// tabMsg is an output of the agent of a tab.
+type tabMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// tabExitedMsg is sent when the agent of a tab exits, e.g. on `exit`.
+type tabExitedMsg struct {
+	id int
+}
+
+// tabOpenedMsg is sent once the agent of a new tab is started, or failed to.
+type tabOpenedMsg struct {
+	kubeContext string
+	agent       *agent.Agent
+	release     func()
+	err         error
+}
+
+// tabsModel shows the sessions of the tabs, one at a time, under a bar
+// listing the tabs and the state of their agents. Only the active tab gets
+// the key presses, the other tabs keep getting the outputs of their agents.
+type tabsModel struct {
+	ctx    context.Context
+	newTab NewTabFunc
+	// forward sends the output of the agent of a tab to the program, until cancelled.
+	forward func(id int, agent *agent.Agent) context.CancelFunc
+
+	tabs   []*tab
+	active int
+	nextID int
+
+	width, height int
+
+	// naming is true while the user types the kubeconfig context of a new tab.
+	naming    bool
+	nameInput textinput.Model
+	// status is shown in the tab bar, e.g. the error opening a tab.
+	status string
+
+	// notifier alerts the user of the approvals required while the terminal
+	// is not focused, if not nil.
+	notifier  *notifier
+	unfocused bool
+}
+
+func newTabsModel(ctx context.Context, agent *agent.Agent, newTab NewTabFunc, forward func(id int, agent *agent.Agent) context.CancelFunc) tabsModel {
+	nameInput := textinput.New()
+	nameInput.Prompt = "New tab in kubeconfig context (empty for the current one, esc to cancel): "
+	return tabsModel{
+		ctx:       ctx,
+		newTab:    newTab,
+		forward:   forward,
+		tabs:      []*tab{{id: 0, title: tabTitle(agent, ""), agent: agent, model: newModel(agent)}},
+		nextID:    1,
+		nameInput: nameInput,
+	}
+}
+
+// tabTitle names a tab after its kubeconfig context.
+func tabTitle(a *agent.Agent, kubeContext string) string {
+	if kubeContext != "" {
+		return kubeContext
+	}
+	if current, err := tools.CurrentContext(a.Kubeconfig); err == nil && current != "" {
+		return current
+	}
+	return "default"
+}
+
+// tabState describes what the agent of a tab is doing, so that the user
+// notices the background tabs waiting for an approval.
+func tabState(a *agent.Agent) string {
+	switch a.Session().AgentState {
+	case api.AgentStateRunning, api.AgentStateInitializing:
+		return runningStyle.Render("● running")
+	case api.AgentStateWaitingForInput:
+		return needsApproval.Render("▲ needs approval")
+	case api.AgentStateExited:
+		return "✕ exited"
+	default:
+		return "○ waiting"
+	}
+}
+
+func (m tabsModel) Init() tea.Cmd {
+	return m.tabs[0].model.Init()
+}
+
+func (m tabsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		var cmds []tea.Cmd
+		for _, t := range m.tabs {
+			cmds = append(cmds, m.updateTab(t, m.tabSize()))
+		}
+		return m, tea.Batch(cmds...)
+	case tea.FocusMsg:
+		m.unfocused = false
+		return m, nil
+	case tea.BlurMsg:
+		m.unfocused = true
+		return m, nil
+	case tabMsg:
+		if i := m.index(msg.id); i >= 0 {
+			return m, tea.Batch(m.updateTab(m.tabs[i], msg.msg), m.notifyApproval(m.tabs[i], msg.msg))
+		}
+		return m, nil
+	case tabExitedMsg:
+		if i := m.index(msg.id); i >= 0 {
+			return m.closeTab(i)
+		}
+		return m, nil
+	case tabOpenedMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Failed to open a tab: %v", msg.err)
+			return m, nil
+		}
+		t := &tab{id: m.nextID, title: tabTitle(msg.agent, msg.kubeContext), agent: msg.agent, model: newModel(msg.agent), release: msg.release}
+		m.nextID++
+		t.cancel = m.forward(t.id, t.agent)
+		m.tabs = append(m.tabs, t)
+		m.active = len(m.tabs) - 1
+		m.status = ""
+		return m, tea.Batch(t.model.Init(), m.updateTab(t, m.tabSize()))
+	case spinner.TickMsg:
+		// Each tab has its own spinner, which ignores the ticks of the others.
+		var cmds []tea.Cmd
+		for _, t := range m.tabs {
+			cmds = append(cmds, m.updateTab(t, msg))
+		}
+		return m, tea.Batch(cmds...)
+	case tea.KeyMsg:
+		if m.newTab == nil {
+			break
+		}
+		if m.naming {
+			return m.updateNaming(msg)
+		}
+		switch key := msg.String(); key {
+		case keyNewTab:
+			m.naming = true
+			m.status = ""
+			m.nameInput.Reset()
+			return m, m.nameInput.Focus()
+		case keyCloseTab:
+			if len(m.tabs) > 1 {
+				return m.closeTab(m.active)
+			}
+			return m, nil
+		case keyNextTab:
+			m.active = (m.active + 1) % len(m.tabs)
+			return m, nil
+		case keyPrevTab:
+			m.active = (m.active + len(m.tabs) - 1) % len(m.tabs)
+			return m, nil
+		default:
+			// alt+1 to alt+9 go to a tab.
+			if n, ok := strings.CutPrefix(key, "alt+"); ok && len(n) == 1 && n[0] >= '1' && n[0] <= '9' {
+				if i := int(n[0] - '1'); i < len(m.tabs) {
+					m.active = i
+				}
+				return m, nil
+			}
+		}
+	}
+
+	cmd := m.updateTab(m.tabs[m.active], msg)
+	if m.naming {
+		// e.g. the blinking of the cursor
+		var nameCmd tea.Cmd
+		m.nameInput, nameCmd = m.nameInput.Update(msg)
+		cmd = tea.Batch(cmd, nameCmd)
+	}
+	return m, cmd
+}
+
+// notifyApproval notifies the user that the agent of a tab asks for an
+// approval, if the terminal is not focused.
+func (m tabsModel) notifyApproval(t *tab, msg tea.Msg) tea.Cmd {
+	if message, ok := msg.(*api.Message); !ok || message.Type != api.MessageTypeUserChoiceRequest {
+		return nil
+	}
+	if m.notifier == nil || !m.unfocused {
+		return nil
+	}
+	body := "The agent is waiting for your approval"
+	if len(m.tabs) > 1 {
+		body += fmt.Sprintf(" in tab %d (%s)", m.index(t.id)+1, t.title)
+	}
+	n := m.notifier
+	return func() tea.Msg {
+		n.notify("kubectl-ai", body)
+		return nil
+	}
+}
+
+// updateNaming handles the key presses while the user types the kubeconfig
+// context of a new tab.
+func (m tabsModel) updateNaming(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.naming = false
+		m.nameInput.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		m.naming = false
+		m.nameInput.Blur()
+		kubeContext := strings.TrimSpace(m.nameInput.Value())
+		m.status = "Opening a tab..."
+		newTab, ctx := m.newTab, m.ctx
+		return m, func() tea.Msg {
+			a, release, err := newTab(ctx, kubeContext)
+			return tabOpenedMsg{kubeContext: kubeContext, agent: a, release: release, err: err}
+		}
+	}
+	var cmd tea.Cmd
+	m.nameInput, cmd = m.nameInput.Update(msg)
+	return m, cmd
+}
+
+func (m tabsModel) updateTab(t *tab, msg tea.Msg) tea.Cmd {
+	updated, cmd := t.model.Update(msg)
+	t.model = updated.(model)
+	return cmd
+}
+
+// tabSize is the size of the tabs, below the tab bar.
+func (m tabsModel) tabSize() tea.WindowSizeMsg {
+	height := m.height
+	if m.newTab != nil {
+		height -= lipgloss.Height(m.tabBar())
+	}
+	return tea.WindowSizeMsg{Width: m.width, Height: height}
+}
+
+func (m tabsModel) index(id int) int {
+	return slices.IndexFunc(m.tabs, func(t *tab) bool { return t.id == id })
+}
+
+// closeTab closes the i-th tab, and quits once the last one is closed.
+func (m tabsModel) closeTab(i int) (tea.Model, tea.Cmd) {
+	m.tabs[i].close()
+	m.tabs = slices.Delete(slices.Clone(m.tabs), i, i+1)
+	if len(m.tabs) == 0 {
+		return m, tea.Quit
+	}
+	if i < m.active || m.active == len(m.tabs) {
+		m.active--
+	}
+	return m, nil
+}
+
+// closeAll closes the tabs when the program exits.
+func (m tabsModel) closeAll() {
+	for _, t := range m.tabs {
+		t.close()
+	}
+}
+
+func (m tabsModel) View() string {
+	if len(m.tabs) == 0 {
+		return ""
+	}
+	view := m.tabs[m.active].model.View()
+	if m.newTab == nil {
+		return view
+	}
+	return m.tabBar() + "\n" + view
+}
+
+// tabBar lists the tabs and the state of their agents, or prompts for the
+// kubeconfig context of a new tab.
+func (m tabsModel) tabBar() string {
+	bar := lipgloss.NewStyle().MaxWidth(m.width).MaxHeight(1)
+	if m.naming {
+		return bar.Render(m.nameInput.View())
+	}
+	var b strings.Builder
+	for i, t := range m.tabs {
+		style := tabStyle
+		if i == m.active {
+			style = activeTabStyle
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%d %s", i+1, t.title)))
+		b.WriteString(tabState(t.agent) + " ")
+	}
+	switch {
+	case m.status != "":
+		b.WriteString(" " + tabStatusStyle.Render(m.status))
+	case len(m.tabs) == 1:
+		b.WriteString(" " + meterStyle.Render(tabShortcutsHelp))
+	}
+	return bar.Render(b.String())
+}